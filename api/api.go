@@ -194,6 +194,12 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 	// Consensus API Calls
 	if api.cs != nil {
 		router.GET("/consensus", api.consensusHandler)
+		router.GET("/consensus/forks", api.consensusForksHandler)
+		router.GET("/consensus/peers/heights", api.consensusPeersHeightsHandler)
+		router.GET("/consensus/transactions/:id", api.consensusTransactionHandler)
+		router.GET("/consensus/siacoinoutputs/:id", api.consensusSiacoinOutputHandler)
+		router.GET("/consensus/siafundoutputs/:id", api.consensusSiafundOutputHandler)
+		router.GET("/consensus/siafundbalance/:addr", api.consensusSiafundBalanceHandler)
 		router.POST("/consensus/validate/transactionset", api.consensusValidateTransactionsetHandler)
 	}
 
@@ -207,6 +213,10 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 	// Gateway API Calls
 	if api.gateway != nil {
 		router.GET("/gateway", api.gatewayHandler)
+		router.POST("/gateway", RequirePassword(api.gatewayHandlerPOST, requiredPassword))
+		router.GET("/gateway/peerreport", api.gatewayPeerReportHandler)
+		router.GET("/gateway/latency", api.gatewayLatencyHandler)
+		router.GET("/gateway/latency/:netaddress", api.gatewayPeerLatencyHandler)
 		router.POST("/gateway/connect/:netaddress", RequirePassword(api.gatewayConnectHandler, requiredPassword))
 		router.POST("/gateway/disconnect/:netaddress", RequirePassword(api.gatewayDisconnectHandler, requiredPassword))
 	}
@@ -214,10 +224,22 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 	// Host API Calls
 	if api.host != nil {
 		// Calls directly pertaining to the host.
-		router.GET("/host", api.hostHandlerGET)                                                   // Get the host status.
-		router.POST("/host", RequirePassword(api.hostHandlerPOST, requiredPassword))              // Change the settings of the host.
-		router.POST("/host/announce", RequirePassword(api.hostAnnounceHandler, requiredPassword)) // Announce the host to the network.
+		router.GET("/host", api.hostHandlerGET)                                                                    // Get the host status.
+		router.POST("/host", RequirePassword(api.hostHandlerPOST, requiredPassword))                               // Change the settings of the host.
+		router.POST("/host/announce", RequirePassword(api.hostAnnounceHandler, requiredPassword))                  // Announce the host to the network.
+		router.POST("/host/contract/:id/expire", RequirePassword(api.hostContractExpireHandler, requiredPassword)) // Terminate a storage obligation early.
+		router.GET("/host/contract/:id/profitability", api.hostContractProfitabilityHandler)                       // Get the revenue and costs of a storage obligation.
+		router.GET("/host/contract/:id/prooftest", api.hostContractProofTestHandler)                               // Test the storage proof of a storage obligation.
+		router.GET("/host/alerts", api.hostAlertsHandlerGET)                                                       // Get the active host alerts.
+		router.POST("/host/health", RequirePassword(api.hostHealthHandlerPOST, requiredPassword))                  // Run the self-diagnostic of the host.
+		router.GET("/host/proofdiagnostics", api.hostProofDiagnosticsHandlerGET)                                   // Get the reasons for missed storage proofs.
+		router.GET("/host/quarantine", api.hostQuarantineHandlerGET)                                               // Get the quarantined storage obligations.
+		router.POST("/host/contract/:id/quarantine", RequirePassword(api.hostContractQuarantineHandler, requiredPassword))
 		router.GET("/host/estimatescore", api.hostEstimateScoreGET)
+		router.GET("/host/contractsizes", api.hostContractSizesHandlerGET)
+		router.GET("/host/winddown", api.hostWindDownHandlerGET)
+		router.POST("/host/winddown", RequirePassword(api.hostWindDownHandlerPOST, requiredPassword))
+		router.POST("/host/winddown/compact", RequirePassword(api.hostWindDownCompactHandler, requiredPassword))
 
 		// Calls pertaining to the storage manager that the host uses.
 		router.GET("/host/storage", api.storageHandler)
@@ -225,6 +247,8 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 		router.POST("/host/storage/folders/remove", RequirePassword(api.storageFoldersRemoveHandler, requiredPassword))
 		router.POST("/host/storage/folders/resize", RequirePassword(api.storageFoldersResizeHandler, requiredPassword))
 		router.POST("/host/storage/sectors/delete/:merkleroot", RequirePassword(api.storageSectorsDeleteHandler, requiredPassword))
+		router.GET("/host/storage/sectors/:merkleroot", api.storageSectorHandler)
+		router.POST("/host/storage/sectors/lookup", api.storageSectorsLookupHandler)
 	}
 
 	// Miner API Calls
@@ -240,10 +264,20 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 	if api.renter != nil {
 		router.GET("/renter", api.renterHandlerGET)
 		router.POST("/renter", RequirePassword(api.renterHandlerPOST, requiredPassword))
+		router.GET("/renter/alerts", api.renterAlertsHandlerGET)
+		router.GET("/renter/cache", api.renterCacheHandlerGET)
+		router.POST("/renter/cache", RequirePassword(api.renterCacheHandlerPOST, requiredPassword))
 		router.GET("/renter/contracts", api.renterContractsHandler)
 		router.GET("/renter/downloads", api.renterDownloadsHandler)
 		router.GET("/renter/files", api.renterFilesHandler)
+		router.POST("/renter/hostcert", RequirePassword(api.renterHostCertHandlerPOST, requiredPassword))
+		router.GET("/renter/hostscores", api.renterHostScoresHandler)
+		router.GET("/renter/portfolios", api.renterPortfoliosHandler)
 		router.GET("/renter/prices", api.renterPricesHandler)
+		router.GET("/renter/redundancy", api.renterRedundancyHandlerGET)
+		router.POST("/renter/restore", RequirePassword(api.renterRestoreHandler, requiredPassword))
+		router.GET("/renter/usage", api.renterUsageHandler)
+		router.GET("/renter/utilization", api.renterUtilizationHandler)
 
 		// TODO: re-enable these routes once the new .sia format has been
 		// standardized and implemented.
@@ -252,11 +286,25 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 		// router.GET("/renter/share", RequirePassword(api.renterShareHandler, requiredPassword))
 		// router.GET("/renter/shareascii", RequirePassword(api.renterShareAsciiHandler, requiredPassword))
 
+		router.POST("/renter/contract/:id/cancel", RequirePassword(api.renterContractCancelHandler, requiredPassword))
+
+		router.POST("/renter/backup/*siapath", RequirePassword(api.renterBackupHandler, requiredPassword))
+		router.GET("/renter/checksum/*siapath", api.renterChecksumHandler)
 		router.POST("/renter/delete/*siapath", RequirePassword(api.renterDeleteHandler, requiredPassword))
+		router.GET("/renter/dir/*siapath", api.renterDirHandlerGET)
+		router.POST("/renter/dir/*siapath", RequirePassword(api.renterDirHandlerPOST, requiredPassword))
+		router.GET("/renter/file/*siapath", api.renterFileHandler)
 		router.GET("/renter/download/*siapath", RequirePassword(api.renterDownloadHandler, requiredPassword))
 		router.GET("/renter/downloadasync/*siapath", RequirePassword(api.renterDownloadAsyncHandler, requiredPassword))
+		router.POST("/renter/pin/*siapath", RequirePassword(api.renterPinHandler, requiredPassword))
+		router.POST("/renter/redundancy/*siapath", RequirePassword(api.renterRedundancyHandlerPOST, requiredPassword))
 		router.POST("/renter/rename/*siapath", RequirePassword(api.renterRenameHandler, requiredPassword))
 		router.POST("/renter/upload/*siapath", RequirePassword(api.renterUploadHandler, requiredPassword))
+		router.POST("/renter/uploaddirectory/*siapath", RequirePassword(api.renterUploadDirectoryHandler, requiredPassword))
+		router.POST("/renter/uploadurl/*siapath", RequirePassword(api.renterUploadURLHandler, requiredPassword))
+		router.GET("/renter/versionretention/*siapath", api.renterVersionRetentionHandlerGET)
+		router.POST("/renter/versionretention/*siapath", RequirePassword(api.renterVersionRetentionHandlerPOST, requiredPassword))
+		router.GET("/renter/versions/*siapath", api.renterVersionsHandler)
 
 		// HostDB endpoints.
 		router.GET("/hostdb/active", api.hostdbActiveHandler)
@@ -267,6 +315,7 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 	// Transaction pool API Calls
 	if api.tpool != nil {
 		router.GET("/tpool/fee", api.tpoolFeeHandlerGET)
+		router.GET("/tpool/feehistogram", api.tpoolFeeHistogramHandlerGET)
 		router.GET("/tpool/raw/:id", api.tpoolRawHandlerGET)
 		router.POST("/tpool/raw", api.tpoolRawHandlerPOST)
 
@@ -280,6 +329,7 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 		router.POST("/wallet/033x", RequirePassword(api.wallet033xHandler, requiredPassword))
 		router.GET("/wallet/address", RequirePassword(api.walletAddressHandler, requiredPassword))
 		router.GET("/wallet/addresses", api.walletAddressesHandler)
+		router.POST("/wallet/addresses/generate", RequirePassword(api.walletAddressesGenerateHandler, requiredPassword))
 		router.GET("/wallet/backup", RequirePassword(api.walletBackupHandler, requiredPassword))
 		router.POST("/wallet/init", RequirePassword(api.walletInitHandler, requiredPassword))
 		router.POST("/wallet/init/seed", RequirePassword(api.walletInitSeedHandler, requiredPassword))
@@ -289,12 +339,16 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 		router.POST("/wallet/siacoins", RequirePassword(api.walletSiacoinsHandler, requiredPassword))
 		router.POST("/wallet/siafunds", RequirePassword(api.walletSiafundsHandler, requiredPassword))
 		router.POST("/wallet/siagkey", RequirePassword(api.walletSiagkeyHandler, requiredPassword))
+		router.GET("/wallet/spending", api.walletSpendingHandler)
 		router.POST("/wallet/sweep/seed", RequirePassword(api.walletSweepSeedHandler, requiredPassword))
+		router.POST("/wallet/timelock", RequirePassword(api.walletTimelockHandler, requiredPassword))
 		router.GET("/wallet/transaction/:id", api.walletTransactionHandler)
 		router.GET("/wallet/transactions", api.walletTransactionsHandler)
 		router.GET("/wallet/transactions/:addr", api.walletTransactionsAddrHandler)
 		router.GET("/wallet/verify/address/:addr", api.walletVerifyAddressHandler)
 		router.POST("/wallet/unlock", RequirePassword(api.walletUnlockHandler, requiredPassword))
+		router.GET("/wallet/watch", api.walletWatchHandlerGET)
+		router.POST("/wallet/watch", RequirePassword(api.walletWatchHandlerPOST, requiredPassword))
 		router.POST("/wallet/changepassword", RequirePassword(api.walletChangePasswordHandler, requiredPassword))
 	}
 
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/bandwidth"
+	"github.com/NebulousLabs/Sia/modules/gateway"
+)
+
+// bandwidthLimited is implemented by the modules whose connections draw from
+// the node's bandwidth budget.
+type bandwidthLimited interface {
+	SetBandwidthManager(modules.BandwidthManager)
+}
+
+// TestBandwidthManagerGlobalLimit downloads a file from a real host while the
+// gateway, host, and renter of the node share a bandwidth manager, and checks
+// that the combined rate of the modules stays within the node's budget.
+func TestBandwidthManagerGlobalLimit(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	chunkSize := int(modules.SectorSize - crypto.TwofishOverhead)
+	st, path := setupTestDownload(t, 8*chunkSize, "test.dat", true)
+	defer st.server.panicClose()
+	orig, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Share a budget between the modules of the node. Downloading the file
+	// takes several seconds at the limit.
+	const limit = 16 << 10
+	bm, err := bandwidth.New(limit, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bm.Close()
+	for _, m := range []interface{}{st.gateway, st.host, st.renter} {
+		m.(bandwidthLimited).SetBandwidthManager(bm)
+	}
+
+	// Connect a peer to the gateway, so that the gateway uses the budget as
+	// well.
+	peer, err := gateway.New("localhost:0", false, filepath.Join(build.SiaTestingDir, "api", t.Name(), "peer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	if err := peer.Connect(st.gateway.Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	before := bm.Usage()
+	start := time.Now()
+	var buf bytes.Buffer
+	if err := st.renter.DownloadWriter("test.dat", &buf); err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(start)
+	after := bm.Usage()
+	if !bytes.Equal(buf.Bytes(), orig) {
+		t.Fatal("downloaded file does not match the original")
+	}
+
+	// The data of the file passes through the budget twice: the host
+	// uploads it, and the renter downloads it. Neither direction may exceed
+	// the limit, allowing for the burst of the buckets.
+	var uploaded, downloaded uint64
+	used := make(map[modules.BandwidthClass]uint64)
+	for _, u := range after {
+		uploaded += u.Uploaded
+		downloaded += u.Downloaded
+		used[u.Class] += u.Uploaded + u.Downloaded
+	}
+	for _, u := range before {
+		uploaded -= u.Uploaded
+		downloaded -= u.Downloaded
+		used[u.Class] -= u.Uploaded + u.Downloaded
+	}
+	for _, total := range []uint64{uploaded, downloaded} {
+		if rate := float64(total) / elapsed.Seconds(); rate > limit*1.25 {
+			t.Fatalf("combined rate of %.0f B/s exceeds the %v B/s budget", rate, limit)
+		}
+	}
+	if min := time.Duration(buf.Len()) * time.Second / limit; elapsed < min*8/10 {
+		t.Fatalf("download took %v, the budget allows no less than %v", elapsed, min)
+	}
+	if used[modules.BandwidthClassHost] < uint64(buf.Len()) || used[modules.BandwidthClassRenter] < uint64(buf.Len()) {
+		t.Fatal("download was not counted for the host and renter:", used)
+	}
+	var gatewayTotal uint64
+	for _, u := range after {
+		if u.Class == modules.BandwidthClassGateway {
+			gatewayTotal = u.Uploaded + u.Downloaded
+		}
+	}
+	if gatewayTotal == 0 {
+		t.Fatal("traffic of the gateway was not counted")
+	}
+}
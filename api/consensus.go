@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/julienschmidt/httprouter"
@@ -19,6 +20,57 @@ type ConsensusGET struct {
 	Difficulty   types.Currency    `json:"difficulty"`
 }
 
+// ConsensusForksGET contains the most recent reorgs of the consensus set, and
+// statistics about all of its reorgs.
+type ConsensusForksGET struct {
+	Forks   []modules.ForkEvent `json:"forks"`
+	Metrics modules.ForkMetrics `json:"metrics"`
+}
+
+// ConsensusPeersHeightsGET contains the height of the current block of each
+// connected peer, as last reported by the peer.
+type ConsensusPeersHeightsGET struct {
+	Heights map[modules.NetAddress]types.BlockHeight `json:"heights"`
+}
+
+// ConsensusTransactionGET contains the inclusion proof of a transaction in the
+// current path.
+type ConsensusTransactionGET struct {
+	InclusionProof modules.TransactionInclusionProof `json:"inclusionproof"`
+}
+
+// ConsensusSiacoinOutputGET contains an unspent siacoin output of the current
+// path.
+type ConsensusSiacoinOutputGET struct {
+	SiacoinOutput types.SiacoinOutput `json:"siacoinoutput"`
+}
+
+// ConsensusSiafundOutputGET contains an unspent siafund output of the current
+// path.
+type ConsensusSiafundOutputGET struct {
+	SiafundOutput types.SiafundOutput `json:"siafundoutput"`
+}
+
+// ConsensusSiafundBalanceGET contains the siafund balance of an address.
+type ConsensusSiafundBalanceGET struct {
+	SiafundBalance      types.Currency `json:"siafundbalance"`
+	SiacoinClaimBalance types.Currency `json:"siacoinclaimbalance"`
+}
+
+// inclusionProof returns the inclusion proof of the transaction with the
+// provided id, or nil if the consensus set cannot prove that the transaction
+// is in the current path.
+func (api *API) inclusionProof(id types.TransactionID) *modules.TransactionInclusionProof {
+	if api.cs == nil {
+		return nil
+	}
+	proof, err := api.cs.TransactionInclusionProof(id)
+	if err != nil {
+		return nil
+	}
+	return &proof
+}
+
 // consensusHandler handles the API calls to /consensus.
 func (api *API) consensusHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	cbid := api.cs.CurrentBlock().ID()
@@ -32,6 +84,98 @@ func (api *API) consensusHandler(w http.ResponseWriter, req *http.Request, _ htt
 	})
 }
 
+// consensusForksHandler handles the API calls to /consensus/forks.
+func (api *API) consensusForksHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, ConsensusForksGET{
+		Forks:   api.cs.ForkHistory(),
+		Metrics: api.cs.ForkResolutionMetrics(),
+	})
+}
+
+// consensusPeersHeightsHandler handles the API calls to
+// /consensus/peers/heights.
+func (api *API) consensusPeersHeightsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, ConsensusPeersHeightsGET{
+		Heights: api.cs.PeerChainHeights(),
+	})
+}
+
+// consensusTransactionHandler handles the API calls to
+// /consensus/transactions/:id.
+func (api *API) consensusTransactionHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	var id types.TransactionID
+	err := id.UnmarshalJSON([]byte("\"" + ps.ByName("id") + "\""))
+	if err != nil {
+		WriteError(w, Error{"could not decode transaction id: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	proof, err := api.cs.TransactionInclusionProof(id)
+	if err != nil {
+		WriteError(w, Error{"could not get inclusion proof: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, ConsensusTransactionGET{
+		InclusionProof: proof,
+	})
+}
+
+// consensusSiacoinOutputHandler handles the API calls to
+// /consensus/siacoinoutputs/:id.
+func (api *API) consensusSiacoinOutputHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	var id types.SiacoinOutputID
+	err := id.UnmarshalJSON([]byte("\"" + ps.ByName("id") + "\""))
+	if err != nil {
+		WriteError(w, Error{"could not decode siacoin output id: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	sco, err := api.cs.SiacoinOutputByID(id)
+	if err != nil {
+		WriteError(w, Error{"could not get siacoin output: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, ConsensusSiacoinOutputGET{
+		SiacoinOutput: sco,
+	})
+}
+
+// consensusSiafundOutputHandler handles the API calls to
+// /consensus/siafundoutputs/:id.
+func (api *API) consensusSiafundOutputHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	var id types.SiafundOutputID
+	err := id.UnmarshalJSON([]byte("\"" + ps.ByName("id") + "\""))
+	if err != nil {
+		WriteError(w, Error{"could not decode siafund output id: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	sfo, err := api.cs.SiafundOutputByID(id)
+	if err != nil {
+		WriteError(w, Error{"could not get siafund output: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, ConsensusSiafundOutputGET{
+		SiafundOutput: sfo,
+	})
+}
+
+// consensusSiafundBalanceHandler handles the API calls to
+// /consensus/siafundbalance/:addr.
+func (api *API) consensusSiafundBalanceHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	addr, err := scanAddress(ps.ByName("addr"))
+	if err != nil {
+		WriteError(w, Error{"could not decode address: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	sfBalance, sfClaimBalance, err := api.cs.SiafundBalance(addr)
+	if err != nil {
+		WriteError(w, Error{"could not get siafund balance: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, ConsensusSiafundBalanceGET{
+		SiafundBalance:      sfBalance,
+		SiacoinClaimBalance: sfClaimBalance,
+	})
+}
+
 // consensusValidateTransactionsetHandler handles the API calls to
 // /consensus/validate/transactionset.
 func (api *API) consensusValidateTransactionsetHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
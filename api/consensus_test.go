@@ -3,11 +3,13 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/url"
 	"testing"
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -40,6 +42,254 @@ func TestIntegrationConsensusGET(t *testing.T) {
 	}
 }
 
+// TestConsensusForksGET probes the GET call to /consensus/forks.
+func TestConsensusForksGET(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	var cfg ConsensusForksGET
+	if err := st.getAPI("/consensus/forks", &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Forks) != 0 || cfg.Metrics.ForkCount != 0 {
+		t.Fatal("expected no forks, got", cfg.Forks, cfg.Metrics)
+	}
+
+	// Mine two blocks on the parent of the current block, which makes them
+	// heavier than the current block and causes a reorg.
+	current := st.cs.CurrentBlock()
+	height := st.cs.Height()
+	parentID := current.ParentID
+	var fork []types.BlockID
+	for i := types.BlockHeight(0); i < 2; i++ {
+		target, _ := st.cs.ChildTarget(parentID)
+		b, _ := st.miner.SolveBlock(types.Block{
+			ParentID:     parentID,
+			Timestamp:    types.CurrentTimestamp(),
+			MinerPayouts: []types.SiacoinOutput{{Value: types.CalculateCoinbase(height + i)}},
+		}, target)
+		if err := st.cs.AcceptBlock(b); err != nil && i == 1 {
+			t.Fatal(err)
+		}
+		parentID = b.ID()
+		fork = append(fork, parentID)
+	}
+	if err := st.getAPI("/consensus/forks", &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Forks) != 1 {
+		t.Fatal("expected one fork, got", cfg.Forks)
+	}
+	f := cfg.Forks[0]
+	if len(f.RevertedBlocks) != 1 || f.RevertedBlocks[0] != current.ID() {
+		t.Error("wrong reverted blocks:", f.RevertedBlocks)
+	}
+	if len(f.AppliedBlocks) != 2 || f.AppliedBlocks[0] != fork[0] || f.AppliedBlocks[1] != fork[1] {
+		t.Error("wrong applied blocks:", f.AppliedBlocks)
+	}
+	m := cfg.Metrics
+	if m.ForkCount != 1 || m.MaxDepth != 1 || m.AverageDepth != 1 || m.DepthHistogram.Depth1 != 1 {
+		t.Errorf("wrong fork metrics: %+v", m)
+	}
+}
+
+// TestConsensusPeersHeightsGET probes the GET call to
+// /consensus/peers/heights.
+func TestConsensusPeersHeightsGET(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+	peer, err := blankServerTester(t.Name() + "-peer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.server.panicClose()
+
+	var cphg ConsensusPeersHeightsGET
+	if err := st.getAPI("/consensus/peers/heights", &cphg); err != nil {
+		t.Fatal(err)
+	}
+	if len(cphg.Heights) != 0 {
+		t.Fatal("expected no peer heights, got", cphg.Heights)
+	}
+
+	// The peer requests the blocks of st after connecting, reporting its
+	// current block.
+	if err := fullyConnectNodes([]*serverTester{st, peer}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := synchronizationCheck([]*serverTester{st, peer}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := synchronizationCheck([]*serverTester{st, peer}); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		if err := st.getAPI("/consensus/peers/heights", &cphg); err != nil {
+			return err
+		}
+		if h, ok := cphg.Heights[peer.gateway.Address()]; !ok || h != st.cs.Height() {
+			return fmt.Errorf("expected height %v for the peer, got %v", st.cs.Height(), cphg.Heights)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestConsensusTransactionGET probes the GET call to
+// /consensus/transactions/:id, and checks that /wallet/transaction/:id
+// includes the inclusion proof of confirmed transactions.
+func TestConsensusTransactionGET(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	txns, err := st.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn := txns[len(txns)-1]
+	if _, err := st.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	var ctg ConsensusTransactionGET
+	if err := st.getAPI("/consensus/transactions/"+txn.ID().String(), &ctg); err == nil {
+		t.Fatal("expected an error before the transaction index is enabled")
+	}
+	var wtg WalletTransactionGETid
+	if err := st.getAPI("/wallet/transaction/"+txn.ID().String(), &wtg); err != nil {
+		t.Fatal(err)
+	}
+	if wtg.InclusionProof != nil {
+		t.Fatal("inclusion proof was returned without a transaction index")
+	}
+
+	if err := st.cs.EnableTransactionIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/consensus/transactions/"+txn.ID().String(), &ctg); err != nil {
+		t.Fatal(err)
+	}
+	block, exists := st.cs.BlockAtHeight(ctg.InclusionProof.Height)
+	if !exists {
+		t.Fatal("proof is for an unknown height")
+	}
+	if !modules.VerifyTransactionInclusion(txn, ctg.InclusionProof, block.Header()) {
+		t.Fatal("inclusion proof did not verify")
+	}
+	if err := st.getAPI("/wallet/transaction/"+txn.ID().String(), &wtg); err != nil {
+		t.Fatal(err)
+	}
+	if wtg.InclusionProof == nil || !modules.VerifyTransactionInclusion(txn, *wtg.InclusionProof, block.Header()) {
+		t.Fatal("wallet did not return a valid inclusion proof")
+	}
+
+	// Unconfirmed transactions are not in the best chain.
+	txns, err = st.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unconfirmed := txns[len(txns)-1]
+	if err := st.getAPI("/consensus/transactions/"+unconfirmed.ID().String(), &ctg); err == nil {
+		t.Fatal("expected an error for an unconfirmed transaction")
+	}
+}
+
+// TestConsensusOutputGET probes the GET calls to /consensus/siacoinoutputs/:id
+// and /consensus/siafundoutputs/:id.
+func TestConsensusOutputGET(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	txns, err := st.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn := txns[len(txns)-1]
+	if _, err := st.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	var csog ConsensusSiacoinOutputGET
+	if err := st.getAPI("/consensus/siacoinoutputs/"+txn.SiacoinOutputID(0).String(), &csog); err != nil {
+		t.Fatal(err)
+	}
+	if csog.SiacoinOutput.Value.Cmp(types.SiacoinPrecision) != 0 {
+		t.Fatal("wrong siacoin output:", csog.SiacoinOutput)
+	}
+	if err := st.getAPI("/consensus/siacoinoutputs/"+txn.SiacoinInputs[0].ParentID.String(), &csog); err == nil {
+		t.Fatal("expected an error for a spent siacoin output")
+	}
+	if err := st.getAPI("/consensus/siacoinoutputs/foo", &csog); err == nil {
+		t.Fatal("expected an error for an invalid id")
+	}
+
+	var csfg ConsensusSiafundOutputGET
+	genesis := types.GenesisBlock.Transactions[0]
+	if err := st.getAPI("/consensus/siafundoutputs/"+genesis.SiafundOutputID(0).String(), &csfg); err != nil {
+		t.Fatal(err)
+	}
+	if !csfg.SiafundOutput.Value.Equals(genesis.SiafundOutputs[0].Value) {
+		t.Fatal("wrong siafund output:", csfg.SiafundOutput)
+	}
+}
+
+// TestConsensusSiafundBalanceGET probes the GET call to
+// /consensus/siafundbalance/:addr.
+func TestConsensusSiafundBalanceGET(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	var csbg ConsensusSiafundBalanceGET
+	genesis := types.GenesisSiafundAllocation[1]
+	if err := st.getAPI("/consensus/siafundbalance/"+genesis.UnlockHash.String(), &csbg); err != nil {
+		t.Fatal(err)
+	}
+	if !csbg.SiafundBalance.Equals(genesis.Value) {
+		t.Fatal("wrong siafund balance:", csbg.SiafundBalance)
+	}
+	if err := st.getAPI("/consensus/siafundbalance/foo", &csbg); err == nil {
+		t.Fatal("expected an error for an invalid address")
+	}
+}
+
 // TestConsensusValidateTransactionSet probes the POST call to
 // /consensus/validate/transactionset.
 func TestConsensusValidateTransactionSet(t *testing.T) {
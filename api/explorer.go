@@ -45,6 +45,10 @@ type (
 		SiafundInputOutputs                      []types.SiafundOutput     `json:"siafundinputoutputs"`                      // the outputs being spent
 		SiafundOutputIDs                         []types.SiafundOutputID   `json:"siafundoutputids"`
 		SiafundClaimOutputIDs                    []types.SiacoinOutputID   `json:"siafundclaimoutputids"`
+
+		// InclusionProof is only set if the consensus set indexes its
+		// transactions.
+		InclusionProof *modules.TransactionInclusionProof `json:"inclusionproof,omitempty"`
 	}
 
 	// ExplorerGET is the object returned as a response to a GET request to
@@ -167,6 +171,7 @@ func (api *API) buildExplorerTransaction(height types.BlockHeight, parent types.
 	for _, sfi := range txn.SiafundInputs {
 		et.SiafundClaimOutputIDs = append(et.SiafundClaimOutputIDs, sfi.ParentID.SiaClaimOutputID())
 	}
+	et.InclusionProof = api.inclusionProof(et.ID)
 	return et
 }
 
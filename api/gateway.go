@@ -1,7 +1,9 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/NebulousLabs/Sia/modules"
 
@@ -10,8 +12,28 @@ import (
 
 // GatewayGET contains the fields returned by a GET call to "/gateway".
 type GatewayGET struct {
-	NetAddress modules.NetAddress `json:"netaddress"`
-	Peers      []modules.Peer     `json:"peers"`
+	NetAddress  modules.NetAddress `json:"netaddress"`
+	Peers       []modules.Peer     `json:"peers"`
+	OnlineSince time.Time          `json:"onlinesince"`
+}
+
+// GatewayPeerReportGET contains the fields returned by a GET call to
+// "/gateway/peerreport".
+type GatewayPeerReportGET struct {
+	PreferredPeers int                 `json:"preferredpeers"`
+	Peers          []modules.PeerStats `json:"peers"`
+}
+
+// GatewayLatencyGET contains the fields returned by a GET call to
+// "/gateway/latency".
+type GatewayLatencyGET struct {
+	Peers []modules.PeerLatencyEntry `json:"peers"`
+}
+
+// GatewayPeerLatencyGET contains the fields returned by a GET call to
+// "/gateway/latency/:netaddress".
+type GatewayPeerLatencyGET struct {
+	Latency time.Duration `json:"latency"`
 }
 
 // gatewayHandler handles the API call asking for the gatway status.
@@ -23,13 +45,75 @@ func (api *API) gatewayHandler(w http.ResponseWriter, req *http.Request, _ httpr
 	if peers == nil {
 		peers = make([]modules.Peer, 0)
 	}
-	WriteJSON(w, GatewayGET{api.gateway.Address(), peers})
+	WriteJSON(w, GatewayGET{api.gateway.Address(), peers, api.gateway.OnlineSince()})
+}
+
+// gatewayHandlerPOST handles the API call to change the gateway's settings.
+func (api *API) gatewayHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	if req.FormValue("preferredpeers") != "" {
+		var n int
+		_, err := fmt.Sscan(req.FormValue("preferredpeers"), &n)
+		if err != nil {
+			WriteError(w, Error{"unable to parse preferredpeers: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		err = api.gateway.SetPreferredPeers(n)
+		if err != nil {
+			WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	WriteSuccess(w)
+}
+
+// gatewayPeerReportHandler handles the API call asking for the lifetime
+// statistics of the gateway's peers.
+func (api *API) gatewayPeerReportHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	peers := api.gateway.PeerReport()
+	if peers == nil {
+		peers = make([]modules.PeerStats, 0)
+	}
+	WriteJSON(w, GatewayPeerReportGET{
+		PreferredPeers: api.gateway.PreferredPeers(),
+		Peers:          peers,
+	})
+}
+
+// gatewayLatencyHandler handles the API call asking for the round trip times
+// of the gateway's peers.
+func (api *API) gatewayLatencyHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	peers := api.gateway.LatencyRanking()
+	if peers == nil {
+		peers = make([]modules.PeerLatencyEntry, 0)
+	}
+	WriteJSON(w, GatewayLatencyGET{Peers: peers})
+}
+
+// gatewayPeerLatencyHandler handles the API call asking for the round trip
+// time of a peer.
+func (api *API) gatewayPeerLatencyHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	addr, err := modules.ParseNetAddress(ps.ByName("netaddress"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	latency, err := api.gateway.PeerLatency(addr)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, GatewayPeerLatencyGET{Latency: latency})
 }
 
 // gatewayConnectHandler handles the API call to add a peer to the gateway.
 func (api *API) gatewayConnectHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
-	addr := modules.NetAddress(ps.ByName("netaddress"))
-	err := api.gateway.Connect(addr)
+	addr, err := modules.ParseNetAddress(ps.ByName("netaddress"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.gateway.Connect(addr)
 	if err != nil {
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
@@ -40,8 +124,12 @@ func (api *API) gatewayConnectHandler(w http.ResponseWriter, req *http.Request,
 
 // gatewayDisconnectHandler handles the API call to remove a peer from the gateway.
 func (api *API) gatewayDisconnectHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
-	addr := modules.NetAddress(ps.ByName("netaddress"))
-	err := api.gateway.Disconnect(addr)
+	addr, err := modules.ParseNetAddress(ps.ByName("netaddress"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.gateway.Disconnect(addr)
 	if err != nil {
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"net/url"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/build"
@@ -111,3 +112,64 @@ func TestGatewayPeerDisconnect(t *testing.T) {
 		t.Fatal("/gateway/disconnect did not disconnect from peer", peer.Address())
 	}
 }
+
+// TestGatewayPeerReport checks that /gateway/peerreport reports the peers that
+// the gateway has connected to, and that the preferred peer setting can be
+// changed through /gateway [POST].
+func TestGatewayPeerReport(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	peer, err := gateway.New("localhost:0", false, build.TempDir("api", t.Name()+"2", "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := peer.Close()
+		if err != nil {
+			panic(err)
+		}
+	}()
+	err = st.stdPostAPI("/gateway/connect/"+string(peer.Address()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var report GatewayPeerReportGET
+	if err := st.getAPI("/gateway/peerreport", &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.PreferredPeers != 0 {
+		t.Fatal("expected no preferred peers by default, got", report.PreferredPeers)
+	}
+	if len(report.Peers) != 1 || report.Peers[0].NetAddress != peer.Address() || !report.Peers[0].Connected {
+		t.Fatal("/gateway/peerreport did not report the connected peer:", report.Peers)
+	}
+
+	values := url.Values{}
+	values.Set("preferredpeers", "2")
+	if err := st.stdPostAPI("/gateway", values); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/gateway/peerreport", &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.PreferredPeers != 2 {
+		t.Fatal("preferred peer setting was not changed:", report.PreferredPeers)
+	}
+
+	// Invalid settings should be rejected.
+	for _, n := range []string{"-1", "1000", "foo"} {
+		values.Set("preferredpeers", n)
+		if err := st.stdPostAPI("/gateway", values); err == nil {
+			t.Error("expected an error for preferredpeers", n)
+		}
+	}
+}
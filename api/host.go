@@ -1,11 +1,14 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 
@@ -27,12 +30,46 @@ type (
 	// HostGET contains the information that is returned after a GET request to
 	// /host - a bunch of information about the status of the host.
 	HostGET struct {
-		ExternalSettings     modules.HostExternalSettings     `json:"externalsettings"`
-		FinancialMetrics     modules.HostFinancialMetrics     `json:"financialmetrics"`
-		InternalSettings     modules.HostInternalSettings     `json:"internalsettings"`
-		NetworkMetrics       modules.HostNetworkMetrics       `json:"networkmetrics"`
-		ConnectabilityStatus modules.HostConnectabilityStatus `json:"connectabilitystatus"`
-		WorkingStatus        modules.HostWorkingStatus        `json:"workingstatus"`
+		ExternalSettings     modules.HostExternalSettings         `json:"externalsettings"`
+		FinancialMetrics     modules.HostFinancialMetrics         `json:"financialmetrics"`
+		InternalSettings     modules.HostInternalSettings         `json:"internalsettings"`
+		EffectiveSettings    modules.HostEffectiveSettings        `json:"effectivesettings"`
+		NetworkMetrics       modules.HostNetworkMetrics           `json:"networkmetrics"`
+		SessionMemory        modules.HostSessionMemoryMetrics     `json:"sessionmemory"`
+		SectorDeletion       modules.HostSectorDeletionMetrics    `json:"sectordeletion"`
+		CollateralBudget     modules.HostCollateralBudgetStatus   `json:"collateralbudget"`
+		ProofConstruction    modules.HostProofConstructionMetrics `json:"proofconstruction"`
+		ConnectabilityStatus modules.HostConnectabilityStatus     `json:"connectabilitystatus"`
+		WorkingStatus        modules.HostWorkingStatus            `json:"workingstatus"`
+		WindDown             modules.HostWindDownStatus           `json:"winddown"`
+	}
+
+	// HostAlertsGET contains the alerts that are currently active on the host.
+	HostAlertsGET struct {
+		Alerts []modules.HostAlert `json:"alerts"`
+	}
+
+	// HostHealthPOST contains the results of the self-diagnostic of the host.
+	HostHealthPOST struct {
+		Healthy         bool                      `json:"healthy"`
+		StorageFolders  []modules.HostHealthCheck `json:"storagefolders"`
+		WalletBalance   modules.HostHealthCheck   `json:"walletbalance"`
+		ConsensusSynced modules.HostHealthCheck   `json:"consensussynced"`
+		Reachable       modules.HostHealthCheck   `json:"reachable"`
+	}
+
+	// HostProofDiagnosticsGET contains the number of storage obligations that
+	// the host has failed, grouped by the reason that the storage proof was
+	// missed.
+	HostProofDiagnosticsGET struct {
+		FailedObligations uint64                                       `json:"failedobligations"`
+		FailureReasons    map[modules.StorageProofFailureReason]uint64 `json:"failurereasons"`
+	}
+
+	// HostQuarantineGET contains the storage obligations that the host has
+	// quarantined because they failed an invariant check.
+	HostQuarantineGET struct {
+		Obligations []modules.HostQuarantinedObligation `json:"obligations"`
 	}
 
 	// HostEstimateScoreGET contains the information that is returned from a
@@ -42,6 +79,33 @@ type (
 		ConversionRate float64        `json:"conversionrate"`
 	}
 
+	// HostSectorGET contains whether the host has a sector, and where the
+	// sector is stored.
+	HostSectorGET struct {
+		Exists   bool                   `json:"exists"`
+		Location modules.SectorLocation `json:"location"`
+	}
+
+	// HostSectorsLookupPOST contains the locations of the sectors that the
+	// host has out of the sectors that were looked up.
+	HostSectorsLookupPOST struct {
+		Sectors []modules.SectorLocationEntry `json:"sectors"`
+	}
+
+	// HostWindDownGET contains the wind down status of the host, and the
+	// height at which each of its storage folders becomes free.
+	HostWindDownGET struct {
+		modules.HostWindDownStatus
+		StorageFolders []modules.HostStorageFolderRelease `json:"storagefolders"`
+		Compaction     modules.HostWindDownCompaction     `json:"compaction"`
+	}
+
+	// HostContractSizesGET contains the histogram of the sizes of the host's
+	// active contracts.
+	HostContractSizesGET struct {
+		Buckets []modules.SizeHistogramBucket `json:"buckets"`
+	}
+
 	// StorageGET contains the information that is returned after a GET request
 	// to /host/storage - a bunch of information about the status of storage
 	// management on the host.
@@ -67,20 +131,76 @@ func (api *API) hostHandlerGET(w http.ResponseWriter, req *http.Request, _ httpr
 	es := api.host.ExternalSettings()
 	fm := api.host.FinancialMetrics()
 	is := api.host.InternalSettings()
+	efs := api.host.EffectiveExternalSettings()
 	nm := api.host.NetworkMetrics()
+	sm := api.host.SessionMemoryMetrics()
+	sd := api.host.SectorDeletionMetrics()
+	cb := api.host.CollateralBudgetStatus()
+	pc := api.host.ProofConstructionMetrics()
 	cs := api.host.ConnectabilityStatus()
 	ws := api.host.WorkingStatus()
+	wd := api.host.WindDownStatus()
 	hg := HostGET{
 		ExternalSettings:     es,
 		FinancialMetrics:     fm,
 		InternalSettings:     is,
+		EffectiveSettings:    efs,
 		NetworkMetrics:       nm,
+		SessionMemory:        sm,
+		SectorDeletion:       sd,
+		CollateralBudget:     cb,
+		ProofConstruction:    pc,
 		ConnectabilityStatus: cs,
 		WorkingStatus:        ws,
+		WindDown:             wd,
 	}
 	WriteJSON(w, hg)
 }
 
+// hostAlertsHandlerGET handles GET requests to the /host/alerts API endpoint,
+// returning the alerts that are currently active on the host.
+func (api *API) hostAlertsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostAlertsGET{
+		Alerts: api.host.Alerts(),
+	})
+}
+
+// hostHealthHandlerPOST handles POST requests to the /host/health API
+// endpoint, running the self-diagnostic of the host.
+func (api *API) hostHealthHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	status, err := api.host.HealthCheck()
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, HostHealthPOST{
+		Healthy:         status.Healthy,
+		StorageFolders:  status.StorageFolders,
+		WalletBalance:   status.WalletBalance,
+		ConsensusSynced: status.ConsensusSynced,
+		Reachable:       status.Reachable,
+	})
+}
+
+// hostProofDiagnosticsHandlerGET handles GET requests to the
+// /host/proofdiagnostics API endpoint, returning a summary of the reasons that
+// the host missed storage proofs.
+func (api *API) hostProofDiagnosticsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	diagnostics := api.host.StorageProofDiagnostics()
+	WriteJSON(w, HostProofDiagnosticsGET{
+		FailedObligations: diagnostics.FailedObligations,
+		FailureReasons:    diagnostics.FailureReasons,
+	})
+}
+
+// hostQuarantineHandlerGET handles GET requests to the /host/quarantine API
+// endpoint, returning the storage obligations that the host has quarantined.
+func (api *API) hostQuarantineHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostQuarantineGET{
+		Obligations: api.host.QuarantinedObligations(),
+	})
+}
+
 // parseHostSettings a request's query strings and returns a
 // modules.HostInternalSettings configured with the request's query string
 // parameters.
@@ -119,14 +239,37 @@ func (api *API) parseHostSettings(req *http.Request) (modules.HostInternalSettin
 		}
 		settings.MaxReviseBatchSize = x
 	}
-	if req.FormValue("netaddress") != "" {
-		var x modules.NetAddress
-		_, err := fmt.Sscan(req.FormValue("netaddress"), &x)
+	if req.FormValue("maxsessionmemory") != "" {
+		var x uint64
+		_, err := fmt.Sscan(req.FormValue("maxsessionmemory"), &x)
 		if err != nil {
 			return modules.HostInternalSettings{}, nil
 		}
+		settings.MaxSessionMemory = x
+	}
+	if req.FormValue("netaddress") != "" {
+		x, err := modules.ParseNetAddress(req.FormValue("netaddress"))
+		if err != nil {
+			return modules.HostInternalSettings{}, err
+		}
 		settings.NetAddress = x
 	}
+	// Both TLS files must be set to enable TLS, and both must be cleared with
+	// an empty value to disable it.
+	if _, ok := req.Form["tlscertfile"]; ok {
+		settings.TLSCertFile = req.FormValue("tlscertfile")
+	}
+	if _, ok := req.Form["tlskeyfile"]; ok {
+		settings.TLSKeyFile = req.FormValue("tlskeyfile")
+	}
+	if req.FormValue("tlsrequired") != "" {
+		var x bool
+		_, err := fmt.Sscan(req.FormValue("tlsrequired"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, nil
+		}
+		settings.TLSRequired = x
+	}
 	if req.FormValue("windowsize") != "" {
 		var x types.BlockHeight
 		_, err := fmt.Sscan(req.FormValue("windowsize"), &x)
@@ -135,6 +278,22 @@ func (api *API) parseHostSettings(req *http.Request) (modules.HostInternalSettin
 		}
 		settings.WindowSize = x
 	}
+	if req.FormValue("obligationretentionblocks") != "" {
+		var x types.BlockHeight
+		_, err := fmt.Sscan(req.FormValue("obligationretentionblocks"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, nil
+		}
+		settings.ObligationRetentionBlocks = x
+	}
+	if req.FormValue("prooftestsamplesize") != "" {
+		var x uint64
+		_, err := fmt.Sscan(req.FormValue("prooftestsamplesize"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, nil
+		}
+		settings.ProofTestSampleSize = x
+	}
 
 	if req.FormValue("collateral") != "" {
 		var x types.Currency
@@ -160,6 +319,14 @@ func (api *API) parseHostSettings(req *http.Request) (modules.HostInternalSettin
 		}
 		settings.MaxCollateral = x
 	}
+	if req.FormValue("maxcollateralfraction") != "" {
+		var x float64
+		_, err := fmt.Sscan(req.FormValue("maxcollateralfraction"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, nil
+		}
+		settings.MaxCollateralFraction = x
+	}
 
 	if req.FormValue("mincontractprice") != "" {
 		var x types.Currency
@@ -194,6 +361,23 @@ func (api *API) parseHostSettings(req *http.Request) (modules.HostInternalSettin
 		settings.MinUploadBandwidthPrice = x
 	}
 
+	// The renter whitelist is a comma separated list of renter public keys.
+	// An empty value clears the whitelist.
+	if _, ok := req.Form["renterwhitelist"]; ok {
+		settings.RenterWhitelist = nil
+		for _, s := range strings.Split(req.FormValue("renterwhitelist"), ",") {
+			if s == "" {
+				continue
+			}
+			var spk types.SiaPublicKey
+			spk.LoadString(s)
+			if spk.String() != s {
+				return modules.HostInternalSettings{}, errors.New("invalid renter public key: " + s)
+			}
+			settings.RenterWhitelist = append(settings.RenterWhitelist, spk)
+		}
+	}
+
 	return settings, nil
 }
 
@@ -269,7 +453,11 @@ func (api *API) hostHandlerPOST(w http.ResponseWriter, req *http.Request, _ http
 func (api *API) hostAnnounceHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	var err error
 	if addr := req.FormValue("netaddress"); addr != "" {
-		err = api.host.AnnounceAddress(modules.NetAddress(addr))
+		var na modules.NetAddress
+		na, err = modules.ParseNetAddress(addr)
+		if err == nil {
+			err = api.host.AnnounceAddress(na)
+		}
 	} else {
 		err = api.host.Announce()
 	}
@@ -359,6 +547,74 @@ func (api *API) storageFoldersRemoveHandler(w http.ResponseWriter, req *http.Req
 	WriteSuccess(w)
 }
 
+// hostContractExpireHandler handles the API call to terminate a storage
+// obligation of the host before the end of its contract.
+func (api *API) hostContractExpireHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	id, err := scanHash(ps.ByName("id"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse contract id: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.host.ExpireContract(types.FileContractID(id), req.FormValue("reason"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// hostContractProfitabilityHandler handles GET requests to the
+// /host/contract/:id/profitability API endpoint, reporting the revenue and
+// costs of a storage obligation.
+func (api *API) hostContractProfitabilityHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	id, err := scanHash(ps.ByName("id"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse contract id: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	report, err := api.host.ContractProfitability(types.FileContractID(id))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, report)
+}
+
+// hostContractProofTestHandler handles GET requests to the
+// /host/contract/:id/prooftest API endpoint, constructing and verifying the
+// storage proof of a storage obligation without submitting it.
+func (api *API) hostContractProofTestHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	id, err := scanHash(ps.ByName("id"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse contract id: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	result, err := api.host.TestProof(types.FileContractID(id))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, result)
+}
+
+// hostContractQuarantineHandler handles POST requests to the
+// /host/contract/:id/quarantine API endpoint, applying a repair or resolution
+// action to a quarantined storage obligation.
+func (api *API) hostContractQuarantineHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	id, err := scanHash(ps.ByName("id"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse contract id: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	action := modules.HostQuarantineAction(req.FormValue("action"))
+	err = api.host.ResolveQuarantinedObligation(types.FileContractID(id), action, req.FormValue("reason"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // storageSectorsDeleteHandler handles the call to delete a sector from the
 // storage manager.
 func (api *API) storageSectorsDeleteHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
@@ -374,3 +630,109 @@ func (api *API) storageSectorsDeleteHandler(w http.ResponseWriter, req *http.Req
 	}
 	WriteSuccess(w)
 }
+
+// storageSectorHandler handles the call to look up a sector in the storage
+// manager.
+func (api *API) storageSectorHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	sectorRoot, err := scanHash(ps.ByName("merkleroot"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	exists, sl, err := api.host.HasSector(sectorRoot)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, HostSectorGET{
+		Exists:   exists,
+		Location: sl,
+	})
+}
+
+// storageSectorsLookupHandler handles the call to look up a batch of sectors
+// in the storage manager. The roots of the sectors are supplied in the POST
+// body.
+func (api *API) storageSectorsLookupHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var roots []crypto.Hash
+	err := json.NewDecoder(req.Body).Decode(&roots)
+	if err != nil {
+		WriteError(w, Error{"could not decode sector roots: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	locations, err := api.host.HasSectors(roots)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	// Sectors are listed in the order in which they were looked up, and a
+	// sector that is looked up more than once is only listed once.
+	sectors := []modules.SectorLocationEntry{}
+	for _, root := range roots {
+		if sl, exists := locations[root]; exists {
+			sectors = append(sectors, modules.SectorLocationEntry{Root: root, SectorLocation: sl})
+			delete(locations, root)
+		}
+	}
+	WriteJSON(w, HostSectorsLookupPOST{
+		Sectors: sectors,
+	})
+}
+
+// hostWindDownHandlerGET handles GET requests to the /host/winddown API
+// endpoint, returning the wind down status of the host, the height at which
+// each storage folder becomes free, and the progress of the compaction.
+func (api *API) hostWindDownHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	releases, err := api.host.StorageFolderReleases()
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	compaction := api.host.WindDownCompaction()
+	if compaction.Removed == nil {
+		compaction.Removed = []modules.StorageFolderMetadata{}
+	}
+	WriteJSON(w, HostWindDownGET{
+		HostWindDownStatus: api.host.WindDownStatus(),
+		StorageFolders:     releases,
+		Compaction:         compaction,
+	})
+}
+
+// hostContractSizesHandlerGET handles GET requests to the /host/contractsizes
+// API endpoint, returning the histogram of the sizes of the host's active
+// contracts.
+func (api *API) hostContractSizesHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostContractSizesGET{
+		Buckets: api.host.ContractSizeHistogram(),
+	})
+}
+
+// hostWindDownHandlerPOST handles POST requests to the /host/winddown API
+// endpoint, starting or stopping the wind down of the host.
+func (api *API) hostWindDownHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var enabled bool
+	_, err := fmt.Sscan(req.FormValue("enabled"), &enabled)
+	if err != nil {
+		WriteError(w, Error{"unable to parse enabled: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.host.SetWindDown(enabled)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// hostWindDownCompactHandler handles POST requests to the
+// /host/winddown/compact API endpoint, starting to remove the storage folders
+// whose sectors fit into the other storage folders.
+func (api *API) hostWindDownCompactHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	err := api.host.WindDownCompact()
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
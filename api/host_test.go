@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -16,6 +17,7 @@ import (
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/host/contractmanager"
 	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
 )
 
 var (
@@ -277,6 +279,42 @@ func TestConnectabilityStatus(t *testing.T) {
 	}
 }
 
+// TestHostNetAddressSetting checks that the netaddress setting of the host is
+// parsed with ParseNetAddress.
+func TestHostNetAddressSetting(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	for _, addr := range []string{"foo", "127.0.0.1", "127.0.0.1:0", "127.0.0.1:abc"} {
+		values := url.Values{}
+		values.Set("netaddress", addr)
+		if err := st.stdPostAPI("/host", values); err == nil {
+			t.Errorf("net address %q was accepted", addr)
+		}
+	}
+
+	values := url.Values{}
+	values.Set("netaddress", "[0:0:0:0:0:0:0:1]:9982")
+	if err := st.stdPostAPI("/host", values); err != nil {
+		t.Fatal(err)
+	}
+	var hg HostGET
+	if err := st.getAPI("/host", &hg); err != nil {
+		t.Fatal(err)
+	}
+	if hg.InternalSettings.NetAddress != "[::1]:9982" {
+		t.Fatal("net address was not parsed:", hg.InternalSettings.NetAddress)
+	}
+}
+
 // TestStorageHandler tests that host storage is being reported correctly.
 func TestStorageHandler(t *testing.T) {
 	if testing.Short() {
@@ -364,6 +402,285 @@ func TestStorageHandler(t *testing.T) {
 	}
 }
 
+// TestHostRenterWhitelist checks that a host with a renter whitelist refuses
+// to form contracts with other renters, while still serving its settings to
+// them.
+func TestHostRenterWhitelist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	// Announce the host and start accepting contracts.
+	if err := st.announceHost(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.acceptContracts(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.setHostStorage(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Invalid renter keys should be rejected.
+	whitelistValues := url.Values{}
+	whitelistValues.Set("renterwhitelist", "ed25519:zz")
+	if err := st.stdPostAPI("/host", whitelistValues); err == nil {
+		t.Fatal("expected an invalid renter key to be rejected")
+	}
+
+	// Whitelist a renter other than the one in the server tester.
+	_, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	whitelistValues.Set("renterwhitelist", spk.String())
+	if err := st.stdPostAPI("/host", whitelistValues); err != nil {
+		t.Fatal(err)
+	}
+	var hg HostGET
+	if err := st.getAPI("/host", &hg); err != nil {
+		t.Fatal(err)
+	}
+	if len(hg.InternalSettings.RenterWhitelist) != 1 || hg.InternalSettings.RenterWhitelist[0].String() != spk.String() {
+		t.Fatal("whitelist was not set:", hg.InternalSettings.RenterWhitelist)
+	}
+
+	// Set an allowance for the renter. The renter should see the host, but
+	// the host should refuse to form a contract with it.
+	allowanceValues := url.Values{}
+	allowanceValues.Set("funds", testFunds)
+	allowanceValues.Set("period", testPeriod)
+	if err = st.stdPostAPI("/renter", allowanceValues); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(50, time.Millisecond*100, func() error {
+		if err := st.getAPI("/host", &hg); err != nil {
+			return err
+		}
+		if hg.NetworkMetrics.UnauthorizedCalls == 0 {
+			return errors.New("host has not rejected the renter")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ah HostdbActiveGET
+	if err := st.getAPI("/hostdb/active", &ah); err != nil {
+		t.Fatal(err)
+	}
+	if len(ah.Hosts) != 1 {
+		t.Fatal("renter should still be able to scan the host, active hosts:", len(ah.Hosts))
+	}
+	var rc RenterContracts
+	if err := st.getAPI("/renter/contracts", &rc); err != nil {
+		t.Fatal(err)
+	}
+	if len(rc.Contracts) != 0 {
+		t.Fatal("host formed a contract with a renter that is not whitelisted")
+	}
+
+	// Whitelist the identity of the renter, after which the renter should be
+	// able to form a contract. The renter uses a new key in every contract,
+	// but keeps its identity.
+	var rg RenterGET
+	if err := st.getAPI("/renter", &rg); err != nil {
+		t.Fatal(err)
+	}
+	whitelistValues.Set("renterwhitelist", spk.String()+","+rg.Identity.String())
+	if err := st.stdPostAPI("/host", whitelistValues); err != nil {
+		t.Fatal(err)
+	}
+	// Setting the allowance again starts the contract maintenance, with a
+	// period that leaves time for an upload before the renewal.
+	const period = 10
+	allowanceValues.Set("period", strconv.Itoa(period))
+	if err = st.stdPostAPI("/renter", allowanceValues); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(50, time.Millisecond*250, func() error {
+		if err := st.getAPI("/renter/contracts", &rc); err != nil {
+			return err
+		}
+		if len(rc.Contracts) != 1 {
+			return errors.New("no contracts")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal("renter did not form a contract after it was whitelisted")
+	}
+	if err := st.getAPI("/host", &hg); err != nil {
+		t.Fatal(err)
+	}
+	unauthorized := hg.NetworkMetrics.UnauthorizedCalls
+
+	// Upload a file so that the contract is renewed, which forms a second
+	// contract with the host.
+	path := filepath.Join(st.dir, "test.dat")
+	if err := createRandFile(path, 1024); err != nil {
+		t.Fatal(err)
+	}
+	uploadValues := url.Values{}
+	uploadValues.Set("source", path)
+	if err := st.stdPostAPI("/renter/upload/test", uploadValues); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(200, 100*time.Millisecond, func() error {
+		var rf RenterFiles
+		if err := st.getAPI("/renter/files", &rf); err != nil {
+			return err
+		}
+		if len(rf.Files) != 1 || rf.Files[0].UploadProgress < 10 {
+			return fmt.Errorf("file has not been uploaded: %+v", rf.Files)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contractID := rc.Contracts[0].ID
+	for i := 0; i < period/2+1; i++ {
+		if _, err := st.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = build.Retry(200, 100*time.Millisecond, func() error {
+		if err := st.getAPI("/renter/contracts", &rc); err != nil {
+			return err
+		}
+		if len(rc.Contracts) != 1 || rc.Contracts[0].ID == contractID {
+			return errors.New("contract was not renewed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/host", &hg); err != nil {
+		t.Fatal(err)
+	}
+	if hg.NetworkMetrics.UnauthorizedCalls != unauthorized {
+		t.Fatal("host rejected the whitelisted renter")
+	}
+
+	// Clearing the whitelist allows any renter to form contracts.
+	whitelistValues.Set("renterwhitelist", "")
+	if err := st.stdPostAPI("/host", whitelistValues); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/host", &hg); err != nil {
+		t.Fatal(err)
+	}
+	if len(hg.InternalSettings.RenterWhitelist) != 0 {
+		t.Fatal("whitelist was not cleared:", hg.InternalSettings.RenterWhitelist)
+	}
+}
+
+// TestHostContractExpire checks that the host operator can terminate a storage
+// obligation through the API.
+func TestHostContractExpire(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+	if err := st.announceHost(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.acceptContracts(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.setHostStorage(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Have the renter form a contract with the host.
+	allowanceValues := url.Values{}
+	allowanceValues.Set("funds", testFunds)
+	allowanceValues.Set("period", testPeriod)
+	if err = st.stdPostAPI("/renter", allowanceValues); err != nil {
+		t.Fatal(err)
+	}
+	var rc RenterContracts
+	err = build.Retry(50, time.Millisecond*250, func() error {
+		if err := st.getAPI("/renter/contracts", &rc); err != nil {
+			return err
+		}
+		if len(rc.Contracts) != 1 {
+			return errors.New("no contracts")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := rc.Contracts[0].ID
+
+	expireValues := url.Values{}
+	if err := st.stdPostAPI("/host/contract/foo/expire", expireValues); err == nil {
+		t.Fatal("expected an invalid contract id to be rejected")
+	}
+	if err := st.stdPostAPI("/host/contract/"+id.String()+"/expire", expireValues); err == nil {
+		t.Fatal("expected an expiration without a reason to be rejected")
+	}
+	expireValues.Set("reason", "dmca")
+	if err := st.stdPostAPI("/host/contract/"+id.String()+"/expire", expireValues); err != nil {
+		t.Fatal(err)
+	}
+	sos := st.host.StorageObligations()
+	if len(sos) != 1 || sos[0].FailureReason != modules.ProofFailureExpiredByHost || sos[0].ExpirationReason != "dmca" {
+		t.Fatal("storage obligation was not expired:", sos)
+	}
+	if err := st.stdPostAPI("/host/contract/"+id.String()+"/expire", expireValues); err == nil {
+		t.Fatal("expected a resolved obligation to be rejected")
+	}
+}
+
+// TestHostQuarantine checks the API endpoints of the host's quarantined
+// storage obligations.
+func TestHostQuarantine(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	var hq HostQuarantineGET
+	if err := st.getAPI("/host/quarantine", &hq); err != nil {
+		t.Fatal(err)
+	}
+	if hq.Obligations == nil || len(hq.Obligations) != 0 {
+		t.Fatal("expected an empty list of quarantined obligations, got", hq.Obligations)
+	}
+
+	quarantineValues := url.Values{}
+	quarantineValues.Set("action", "recheck")
+	if err := st.stdPostAPI("/host/contract/foo/quarantine", quarantineValues); err == nil {
+		t.Fatal("expected an invalid contract id to be rejected")
+	}
+	id := types.FileContractID{1}
+	if err := st.stdPostAPI("/host/contract/"+id.String()+"/quarantine", quarantineValues); err == nil {
+		t.Fatal("expected an unknown contract to be rejected")
+	}
+	quarantineValues.Set("action", "foo")
+	if err := st.stdPostAPI("/host/contract/"+id.String()+"/quarantine", quarantineValues); err == nil {
+		t.Fatal("expected an unknown action to be rejected")
+	}
+}
+
 // TestAddFolderNoPath tests that an API call to add a storage folder fails if
 // no path was provided.
 func TestAddFolderNoPath(t *testing.T) {
@@ -1082,3 +1399,135 @@ func TestDeleteNonexistentSector(t *testing.T) {
 		t.Fatalf("expected error to be %v; got %v", crypto.ErrHashWrongLen, err)
 	}
 }
+
+// TestStorageSectorLookup probes the GET call to
+// /host/storage/sectors/:merkleroot and the POST call to
+// /host/storage/sectors/lookup.
+func TestStorageSectorLookup(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	storageFolder := filepath.Join(st.dir, "storage folder")
+	if err := os.MkdirAll(storageFolder, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.host.AddStorageFolder(storageFolder, modules.SectorSize*contractmanager.MinimumSectorsPerStorageFolder); err != nil {
+		t.Fatal(err)
+	}
+	data := fastrand.Bytes(int(modules.SectorSize))
+	root := crypto.MerkleRoot(data)
+	if err := st.host.AddSector(root, data); err != nil {
+		t.Fatal(err)
+	}
+	missing := crypto.HashObject("fake object")
+
+	var hsg HostSectorGET
+	if err := st.getAPI("/host/storage/sectors/"+root.String(), &hsg); err != nil {
+		t.Fatal(err)
+	}
+	if !hsg.Exists || hsg.Location.Path != storageFolder || hsg.Location.ReferenceCount != 1 {
+		t.Fatalf("wrong lookup for an added sector: %+v", hsg)
+	}
+	if err := st.getAPI("/host/storage/sectors/"+missing.String(), &hsg); err != nil {
+		t.Fatal(err)
+	}
+	if hsg.Exists {
+		t.Fatal("lookup found a sector that was never added")
+	}
+
+	roots, err := json.Marshal([]crypto.Hash{missing, root, root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := HttpPOST("http://"+st.server.listener.Addr().String()+"/host/storage/sectors/lookup", string(roots))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if non2xx(resp.StatusCode) {
+		t.Fatal(decodeError(resp))
+	}
+	var hslp HostSectorsLookupPOST
+	if err := json.NewDecoder(resp.Body).Decode(&hslp); err != nil {
+		t.Fatal(err)
+	}
+	if len(hslp.Sectors) != 1 || hslp.Sectors[0].Root != root || hslp.Sectors[0].Path != storageFolder {
+		t.Fatalf("wrong bulk lookup: %+v", hslp.Sectors)
+	}
+}
+
+// TestHostHealth checks that /host/health reports the self-diagnostic of the
+// host.
+func TestHostHealth(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	// The host has no storage folders yet.
+	var hh HostHealthPOST
+	if err := st.postAPI("/host/health", nil, &hh); err != nil {
+		t.Fatal(err)
+	}
+	if hh.Healthy || hh.StorageFolders == nil || len(hh.StorageFolders) != 0 {
+		t.Fatalf("host without storage folders should not be healthy: %+v", hh)
+	}
+	if !hh.WalletBalance.Passed || !hh.ConsensusSynced.Passed {
+		t.Fatalf("expected the wallet and consensus checks to pass: %+v", hh)
+	}
+
+	// The diagnostic writes to the storage folders, so it is not served over
+	// GET.
+	if err := st.getAPI("/host/health", &hh); err == nil {
+		t.Fatal("expected GET /host/health to fail")
+	}
+
+	if err := st.setHostStorage(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.postAPI("/host/health", nil, &hh); err != nil {
+		t.Fatal(err)
+	}
+	if !hh.Healthy || len(hh.StorageFolders) != 1 || !hh.StorageFolders[0].Passed {
+		t.Fatalf("host should be healthy: %+v", hh)
+	}
+}
+
+// TestHostContractSizes checks that /host/contractsizes returns every bucket
+// of the contract size histogram.
+func TestHostContractSizes(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	var hcs HostContractSizesGET
+	if err := st.getAPI("/host/contractsizes", &hcs); err != nil {
+		t.Fatal(err)
+	}
+	if len(hcs.Buckets) != 5 || hcs.Buckets[0].MaxSize != 1e6 || hcs.Buckets[4].MinSize != 1e9 || hcs.Buckets[4].MaxSize != 0 {
+		t.Fatalf("wrong buckets: %+v", hcs.Buckets)
+	}
+	for _, b := range hcs.Buckets {
+		if b.Contracts != 0 || b.Size != 0 {
+			t.Fatalf("host without contracts reported contracts: %+v", b)
+		}
+	}
+}
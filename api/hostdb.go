@@ -24,9 +24,11 @@ type (
 		Hosts []ExtendedHostDBEntry `json:"hosts"`
 	}
 
-	// HostdbAllGET lists all hosts that the renter is aware of.
+	// HostdbAllGET lists all hosts that the renter is aware of, or a page of
+	// them. TotalHosts is the number of hosts on all pages.
 	HostdbAllGET struct {
-		Hosts []ExtendedHostDBEntry `json:"hosts"`
+		Hosts      []ExtendedHostDBEntry `json:"hosts"`
+		TotalHosts int                   `json:"totalhosts"`
 	}
 
 	// HostdbHostsGET lists detailed statistics for a particular host, selected
@@ -34,6 +36,7 @@ type (
 	HostdbHostsGET struct {
 		Entry          ExtendedHostDBEntry        `json:"entry"`
 		ScoreBreakdown modules.HostScoreBreakdown `json:"scorebreakdown"`
+		CircuitBreaker modules.HostCircuitBreaker `json:"circuitbreaker"`
 	}
 )
 
@@ -74,10 +77,37 @@ func (api *API) hostdbActiveHandler(w http.ResponseWriter, req *http.Request, _
 	})
 }
 
-// hostdbAllHandler handles the API call asking for the list of all hosts.
+// hostdbAllHandler handles the API call asking for the list of all hosts. If
+// the 'pagesize' parameter is set, only the requested page of hosts is
+// returned.
 func (api *API) hostdbAllHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	// Get the set of all hosts and convert them into extended hosts.
-	hosts := api.renter.AllHosts()
+	var hosts []modules.HostDBEntry
+	var total int
+	if req.FormValue("pagesize") == "" {
+		hosts = api.renter.AllHosts()
+		total = len(hosts)
+	} else {
+		var page, pageSize int
+		_, err := fmt.Sscan(req.FormValue("pagesize"), &pageSize)
+		if err != nil {
+			WriteError(w, Error{"unable to parse pagesize: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if req.FormValue("page") != "" {
+			_, err = fmt.Sscan(req.FormValue("page"), &page)
+			if err != nil {
+				WriteError(w, Error{"unable to parse page: " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+		}
+		hosts, total, err = api.renter.AllHostsPaged(page, pageSize)
+		if err != nil {
+			WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Convert the hosts into extended hosts.
 	var extendedHosts []ExtendedHostDBEntry
 	for _, host := range hosts {
 		extendedHosts = append(extendedHosts, ExtendedHostDBEntry{
@@ -87,7 +117,8 @@ func (api *API) hostdbAllHandler(w http.ResponseWriter, req *http.Request, _ htt
 	}
 
 	WriteJSON(w, HostdbAllGET{
-		Hosts: extendedHosts,
+		Hosts:      extendedHosts,
+		TotalHosts: total,
 	})
 }
 
@@ -112,5 +143,6 @@ func (api *API) hostdbHostsHandler(w http.ResponseWriter, req *http.Request, ps
 	WriteJSON(w, HostdbHostsGET{
 		Entry:          extendedEntry,
 		ScoreBreakdown: breakdown,
+		CircuitBreaker: api.renter.HostCircuitBreaker(entry.PublicKey),
 	})
 }
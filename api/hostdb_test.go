@@ -138,8 +138,26 @@ func TestHostDBHostsAllHandler(t *testing.T) {
 	if err = st.getAPI("/hostdb/all", &ah); err != nil {
 		t.Fatal(err)
 	}
-	if len(ah.Hosts) != 1 {
-		t.Fatalf("expected 1 host, got %v", len(ah.Hosts))
+	if len(ah.Hosts) != 1 || ah.TotalHosts != 1 {
+		t.Fatalf("expected 1 host, got %v of %v", len(ah.Hosts), ah.TotalHosts)
+	}
+
+	// Page through the hosts.
+	if err = st.getAPI("/hostdb/all?page=0&pagesize=1", &ah); err != nil {
+		t.Fatal(err)
+	}
+	if len(ah.Hosts) != 1 || ah.TotalHosts != 1 {
+		t.Fatalf("expected 1 host on the first page, got %v of %v", len(ah.Hosts), ah.TotalHosts)
+	}
+	ah = HostdbAllGET{}
+	if err = st.getAPI("/hostdb/all?page=1&pagesize=1", &ah); err != nil {
+		t.Fatal(err)
+	}
+	if len(ah.Hosts) != 0 || ah.TotalHosts != 1 {
+		t.Fatalf("expected no hosts on the second page, got %v of %v", len(ah.Hosts), ah.TotalHosts)
+	}
+	if err = st.getAPI("/hostdb/all?pagesize=0", &ah); err == nil {
+		t.Fatal("expected an error for a page size of 0")
 	}
 }
 
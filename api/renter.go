@@ -4,10 +4,15 @@ package api
 // zeroing them out.
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -40,9 +45,11 @@ var (
 
 	// requiredParityPieces specifies the minimum number of parity pieces that
 	// must be used when uploading a file. This minimum exists to prevent users
-	// from shooting themselves in the foot.
+	// from shooting themselves in the foot. It is low enough to allow cheap
+	// erasure codes such as 10-of-12 for data that is not critical; the renter
+	// checks that there are enough contracts for the erasure code.
 	requiredParityPieces = build.Select(build.Var{
-		Standard: int(12),
+		Standard: int(1),
 		Dev:      int(0),
 		Testing:  int(0),
 	}).(int)
@@ -51,7 +58,7 @@ var (
 	// accepted by the renter when uploading a file. This minimum exists to
 	// prevent users from shooting themselves in the foot.
 	requiredRedundancy = build.Select(build.Var{
-		Standard: float64(2),
+		Standard: float64(1),
 		Dev:      float64(1),
 		Testing:  float64(1),
 	}).(float64)
@@ -72,10 +79,30 @@ type (
 		Settings         modules.RenterSettings     `json:"settings"`
 		FinancialMetrics modules.ContractorSpending `json:"financialmetrics"`
 		CurrentPeriod    types.BlockHeight          `json:"currentperiod"`
+		PendingRenewals  []types.FileContractID     `json:"pendingrenewals"`
+
+		// Identity is the public key that the renter identifies itself with
+		// to hosts, which can be added to the renter whitelist of a host.
+		Identity types.SiaPublicKey `json:"identity"`
+
+		// RepairEstimate covers the files of every portfolio, and is only
+		// reported if no portfolio is requested.
+		RepairEstimate modules.RepairEstimate `json:"repairestimate"`
+
+		// TransferSpeeds are the renter's current transfer speeds and their
+		// limits, which apply to every portfolio.
+		TransferSpeeds modules.RenterBandwidth `json:"transferspeeds"`
+
+		// CircuitBreakers are the circuit breakers of the hosts that
+		// requests have failed on since their last successful request.
+		CircuitBreakers []modules.HostCircuitBreaker `json:"circuitbreakers"`
 	}
 
 	// RenterContract represents a contract formed by the renter.
 	RenterContract struct {
+		// Whether the contract was cancelled. Cancelled contracts are not
+		// used for uploads, and are not renewed.
+		Cancelled bool `json:"cancelled"`
 		// Amount of contract funds that have been spent on downloads.
 		DownloadSpending types.Currency `json:"downloadspending"`
 		// Block height that the file contract ends on.
@@ -84,6 +111,8 @@ type (
 		Fees types.Currency `json:"fees"`
 		// Public key of the host the contract was formed with.
 		HostPublicKey types.SiaPublicKey `json:"hostpublickey"`
+		// Revision number that the host reported if the contract is stale.
+		HostRevisionNumber uint64 `json:"hostrevisionnumber"`
 		// ID of the file contract.
 		ID types.FileContractID `json:"id"`
 		// A signed transaction containing the most recent contract revision.
@@ -92,9 +121,15 @@ type (
 		NetAddress modules.NetAddress `json:"netaddress"`
 		// Remaining funds left for the renter to spend on uploads & downloads.
 		RenterFunds types.Currency `json:"renterfunds"`
+		// Revision number of the most recent contract revision.
+		RevisionNumber uint64 `json:"revisionnumber"`
 		// Size of the file contract, which is typically equal to the number of
 		// bytes that have been uploaded to the host.
 		Size uint64 `json:"size"`
+		// Whether the host has a more recent revision of the contract, which
+		// the renter could not adopt. Stale contracts are not used for
+		// uploads.
+		Stale bool `json:"stale"`
 		// Block height that the file contract began on.
 		StartHeight types.BlockHeight `json:"startheight"`
 		// Amount of contract funds that have been spent on storage.
@@ -115,22 +150,100 @@ type (
 		Downloads []DownloadInfo `json:"downloads"`
 	}
 
+	// RenterDirectory lists the directories and files directly inside of a
+	// directory of the renter.
+	RenterDirectory struct {
+		Directories []modules.DirectoryInfo `json:"directories"`
+		Files       []modules.FileInfo      `json:"files"`
+	}
+
+	// RenterFile lists a single file known to the renter.
+	RenterFile struct {
+		File modules.RenterFile `json:"file"`
+	}
+
 	// RenterFiles lists the files known to the renter.
 	RenterFiles struct {
 		Files []modules.FileInfo `json:"files"`
 	}
 
+	// RenterPortfolios lists the renter's allowance portfolios.
+	RenterPortfolios struct {
+		Portfolios []modules.RenterPortfolio `json:"portfolios"`
+	}
+
 	// RenterLoad lists files that were loaded into the renter.
 	RenterLoad struct {
 		FilesAdded []string `json:"filesadded"`
 	}
 
+	// RenterAlertsGET contains the alerts that are currently active on the
+	// renter.
+	RenterAlertsGET struct {
+		Alerts []modules.RenterAlert `json:"alerts"`
+	}
+
+	// RenterCacheGET contains the statistics of the renter's disk cache of
+	// downloaded chunks.
+	RenterCacheGET struct {
+		modules.CacheStats
+	}
+
+	// RenterHostScoresGET contains the scoring factors of every host that the
+	// renter knows, sorted by increasing weight.
+	RenterHostScoresGET struct {
+		Scores []modules.HostScore `json:"scores"`
+	}
+
+	// RenterUsageGET contains the storage and bandwidth used by the renter,
+	// broken down by siapath prefix.
+	RenterUsageGET struct {
+		modules.RenterUsage
+	}
+
+	// RenterUtilizationGET contains the utilization of the renter's
+	// contracts, keyed by contract ID.
+	RenterUtilizationGET struct {
+		Contracts map[string]modules.ContractUtilization `json:"contracts"`
+	}
+
 	// RenterPricesGET lists the data that is returned when a GET call is made
 	// to /renter/prices.
 	RenterPricesGET struct {
 		modules.RenterPriceEstimation
 	}
 
+	// RenterBackupPOST contains the manifest of a backup. Error lists the
+	// files that could not be backed up, if any.
+	RenterBackupPOST struct {
+		Manifest modules.BackupManifest `json:"manifest"`
+		Error    string                 `json:"error,omitempty"`
+	}
+
+	// RenterRedundancyChanges lists the progress of the renter's redundancy
+	// changes.
+	RenterRedundancyChanges struct {
+		Changes []modules.RedundancyChange `json:"changes"`
+	}
+
+	// RenterChecksum contains the checksum of a file that was computed when
+	// the file was uploaded.
+	RenterChecksum struct {
+		Checksum modules.FileChecksum `json:"checksum"`
+	}
+
+	// RenterVersions lists the versions of a file, oldest first.
+	RenterVersions struct {
+		Versions []modules.FileVersion `json:"versions"`
+	}
+
+	// RenterVersionRetention contains the version retention of a file or
+	// directory. MaxAge is in seconds.
+	RenterVersionRetention struct {
+		MaxVersions int    `json:"maxversions"`
+		MaxAge      uint64 `json:"maxage"`
+	}
+
 	// RenterShareASCII contains an ASCII-encoded .sia file.
 	RenterShareASCII struct {
 		ASCIIsia string `json:"asciisia"`
@@ -141,20 +254,55 @@ type (
 		SiaPath     string    `json:"siapath"`
 		Destination string    `json:"destination"`
 		Filesize    uint64    `json:"filesize"`
+		Offset      uint64    `json:"offset"`
+		Section     bool      `json:"section"`
 		Received    uint64    `json:"received"`
 		StartTime   time.Time `json:"starttime"`
 		Error       string    `json:"error"`
+
+		// ChecksumStatus is the result of verifying the downloaded data
+		// against the checksum of the file.
+		ChecksumStatus string `json:"checksumstatus"`
 	}
 )
 
 // renterHandlerGET handles the API call to /renter.
 func (api *API) renterHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	if name := req.FormValue("portfolio"); name != "" {
+		p, err := api.renter.Portfolio(name)
+		if err != nil {
+			WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+			return
+		}
+		WriteJSON(w, RenterGET{
+			Settings: modules.RenterSettings{
+				Allowance:              p.Allowance,
+				AutoRenewEnabled:       p.AutoRenewEnabled,
+				RenewalThresholdBlocks: p.RenewalThresholdBlocks,
+				UploadRetryPolicy:      api.renter.Settings().UploadRetryPolicy,
+				MaxDownloadSpeed:       api.renter.Settings().MaxDownloadSpeed,
+				MaxUploadSpeed:         api.renter.Settings().MaxUploadSpeed,
+				ChecksumAlgorithm:      api.renter.Settings().ChecksumAlgorithm,
+			},
+			FinancialMetrics: p.Spending,
+			CurrentPeriod:    p.CurrentPeriod,
+			PendingRenewals:  p.PendingRenewals,
+			Identity:         p.Identity,
+		})
+		return
+	}
+
 	settings := api.renter.Settings()
 	periodStart := api.renter.CurrentPeriod()
 	WriteJSON(w, RenterGET{
 		Settings:         settings,
 		FinancialMetrics: api.renter.PeriodSpending(),
 		CurrentPeriod:    periodStart,
+		PendingRenewals:  api.renter.PendingRenewals(),
+		Identity:         api.renter.Identity(),
+		RepairEstimate:   api.renter.RepairEstimate(),
+		TransferSpeeds:   api.renter.TransferSpeeds(),
+		CircuitBreakers:  api.renter.HostCircuitBreakers(),
 	})
 }
 
@@ -207,15 +355,148 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 		renewWindow = period / 2
 	}
 
-	// Set the settings in the renter.
-	err = api.renter.SetSettings(modules.RenterSettings{
-		Allowance: modules.Allowance{
-			Funds:       funds,
-			Hosts:       hosts,
-			Period:      period,
-			RenewWindow: renewWindow,
-		},
-	})
+	// Scan the renewal policy. (optional parameters) If they are not
+	// provided, the current policy of the renter or portfolio is kept. New
+	// portfolios renew automatically by default.
+	current := api.renter.Settings()
+	portfolio := req.FormValue("portfolio")
+	if portfolio != "" {
+		p, err := api.renter.Portfolio(portfolio)
+		if err == nil {
+			current.Allowance = p.Allowance
+			current.AutoRenewEnabled = p.AutoRenewEnabled
+			current.RenewalThresholdBlocks = p.RenewalThresholdBlocks
+		} else {
+			current.Allowance = modules.Allowance{}
+			current.AutoRenewEnabled = true
+			current.RenewalThresholdBlocks = 0
+		}
+	}
+	autoRenew := current.AutoRenewEnabled
+	if req.FormValue("autorenew") != "" {
+		_, err = fmt.Sscan(req.FormValue("autorenew"), &autoRenew)
+		if err != nil {
+			WriteError(w, Error{"unable to parse autorenew: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	renewalThreshold := current.RenewalThresholdBlocks
+	if req.FormValue("renewalthreshold") != "" {
+		_, err = fmt.Sscan(req.FormValue("renewalthreshold"), &renewalThreshold)
+		if err != nil {
+			WriteError(w, Error{"unable to parse renewalthreshold: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan the price boost factor. (optional parameter) If it is not
+	// provided, the current factor of the renter or portfolio is kept.
+	boostFactor := current.Allowance.AutoPriceBoostFactor
+	if req.FormValue("autopriceboostfactor") != "" {
+		_, err = fmt.Sscan(req.FormValue("autopriceboostfactor"), &boostFactor)
+		if err != nil {
+			WriteError(w, Error{"unable to parse autopriceboostfactor: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan the number of versions kept per file. (optional parameter) If it
+	// is not provided, the current number of the renter or portfolio is kept.
+	maxVersions := current.Allowance.MaxVersionsPerFile
+	if req.FormValue("maxversionsperfile") != "" {
+		_, err = fmt.Sscan(req.FormValue("maxversionsperfile"), &maxVersions)
+		if err != nil {
+			WriteError(w, Error{"unable to parse maxversionsperfile: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan the download speed limit. (optional parameter) The limit applies to
+	// the whole renter, so it cannot be set for a portfolio.
+	maxDownloadSpeed := current.MaxDownloadSpeed
+	if req.FormValue("maxdownloadspeed") != "" {
+		if portfolio != "" {
+			WriteError(w, Error{"maxdownloadspeed applies to every portfolio and cannot be set for a single portfolio"}, http.StatusBadRequest)
+			return
+		}
+		_, err = fmt.Sscan(req.FormValue("maxdownloadspeed"), &maxDownloadSpeed)
+		if err != nil {
+			WriteError(w, Error{"unable to parse maxdownloadspeed: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan the upload speed limit. (optional parameter) The limit applies to
+	// the whole renter, so it cannot be set for a portfolio.
+	maxUploadSpeed := current.MaxUploadSpeed
+	if req.FormValue("maxuploadspeed") != "" {
+		if portfolio != "" {
+			WriteError(w, Error{"maxuploadspeed applies to every portfolio and cannot be set for a single portfolio"}, http.StatusBadRequest)
+			return
+		}
+		_, err = fmt.Sscan(req.FormValue("maxuploadspeed"), &maxUploadSpeed)
+		if err != nil {
+			WriteError(w, Error{"unable to parse maxuploadspeed: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan the checksum algorithm. (optional parameter) The algorithm applies
+	// to every upload of the renter, so it cannot be set for a portfolio.
+	checksumAlgorithm := current.ChecksumAlgorithm
+	if req.FormValue("checksumalgorithm") != "" {
+		if portfolio != "" {
+			WriteError(w, Error{"checksumalgorithm applies to every portfolio and cannot be set for a single portfolio"}, http.StatusBadRequest)
+			return
+		}
+		checksumAlgorithm = req.FormValue("checksumalgorithm")
+	}
+
+	allowance := modules.Allowance{
+		Funds:       funds,
+		Hosts:       hosts,
+		Period:      period,
+		RenewWindow: renewWindow,
+
+		AutoPriceBoostFactor: boostFactor,
+		MaxVersionsPerFile:   maxVersions,
+	}
+
+	// Set the settings of the portfolio, creating it if necessary.
+	if portfolio != "" {
+		err = api.renter.SetPortfolio(modules.RenterPortfolio{
+			Name:                   portfolio,
+			Allowance:              allowance,
+			AutoRenewEnabled:       autoRenew,
+			RenewalThresholdBlocks: renewalThreshold,
+		})
+		if err != nil {
+			WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+			return
+		}
+		WriteSuccess(w)
+		return
+	}
+
+	// Set the settings in the renter. Only the optional settings that were
+	// provided are changed.
+	update := modules.RenterSettingsUpdate{Allowance: &allowance}
+	if req.FormValue("autorenew") != "" {
+		update.AutoRenewEnabled = &autoRenew
+	}
+	if req.FormValue("renewalthreshold") != "" {
+		update.RenewalThresholdBlocks = &renewalThreshold
+	}
+	if req.FormValue("maxdownloadspeed") != "" {
+		update.MaxDownloadSpeed = &maxDownloadSpeed
+	}
+	if req.FormValue("maxuploadspeed") != "" {
+		update.MaxUploadSpeed = &maxUploadSpeed
+	}
+	if req.FormValue("checksumalgorithm") != "" {
+		update.ChecksumAlgorithm = &checksumAlgorithm
+	}
+	err = api.renter.SetSettings(update)
 	if err != nil {
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
@@ -224,23 +505,36 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 }
 
 // renterContractsHandler handles the API call to request the Renter's contracts.
-func (api *API) renterContractsHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+func (api *API) renterContractsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	renterContracts := api.renter.Contracts()
+	if name := req.FormValue("portfolio"); name != "" {
+		var err error
+		renterContracts, err = api.renter.PortfolioContracts(name)
+		if err != nil {
+			WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
 	contracts := []RenterContract{}
-	for _, c := range api.renter.Contracts() {
+	for _, c := range renterContracts {
 		contracts = append(contracts, RenterContract{
-			DownloadSpending: c.DownloadSpending,
-			EndHeight:        c.EndHeight(),
-			Fees:             c.TxnFee.Add(c.SiafundFee).Add(c.ContractFee),
-			HostPublicKey:    c.HostPublicKey,
-			ID:               c.ID,
-			LastTransaction:  c.LastRevisionTxn,
-			NetAddress:       c.NetAddress,
-			RenterFunds:      c.RenterFunds(),
-			Size:             c.LastRevision.NewFileSize,
-			StartHeight:      c.StartHeight,
-			StorageSpending:  c.StorageSpending,
-			TotalCost:        c.TotalCost,
-			UploadSpending:   c.UploadSpending,
+			Cancelled:          c.Cancelled,
+			DownloadSpending:   c.DownloadSpending,
+			EndHeight:          c.EndHeight(),
+			Fees:               c.TxnFee.Add(c.SiafundFee).Add(c.ContractFee),
+			HostPublicKey:      c.HostPublicKey,
+			HostRevisionNumber: c.HostRevisionNumber,
+			ID:                 c.ID,
+			LastTransaction:    c.LastRevisionTxn,
+			NetAddress:         c.NetAddress,
+			RenterFunds:        c.RenterFunds(),
+			RevisionNumber:     c.LastRevision.NewRevisionNumber,
+			Size:               c.LastRevision.NewFileSize,
+			Stale:              c.Stale,
+			StartHeight:        c.StartHeight,
+			StorageSpending:    c.StorageSpending,
+			TotalCost:          c.TotalCost,
+			UploadSpending:     c.UploadSpending,
 		})
 	}
 	WriteJSON(w, RenterContracts{
@@ -248,6 +542,21 @@ func (api *API) renterContractsHandler(w http.ResponseWriter, _ *http.Request, _
 	})
 }
 
+// renterContractCancelHandler handles the API call to cancel a contract of
+// the renter.
+func (api *API) renterContractCancelHandler(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	id, err := scanHash(ps.ByName("id"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse contract id: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err := api.renter.CancelContract(types.FileContractID(id)); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // renterDownloadsHandler handles the API call to request the download queue.
 func (api *API) renterDownloadsHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
 	var downloads []DownloadInfo
@@ -256,9 +565,13 @@ func (api *API) renterDownloadsHandler(w http.ResponseWriter, _ *http.Request, _
 			SiaPath:     d.SiaPath,
 			Destination: d.Destination.Destination(),
 			Filesize:    d.Filesize,
+			Offset:      d.Offset,
+			Section:     d.Section,
 			StartTime:   d.StartTime,
 			Received:    d.Received,
 			Error:       d.Error,
+
+			ChecksumStatus: d.ChecksumStatus,
 		})
 	}
 	// sort the downloads by newest first
@@ -297,6 +610,162 @@ func (api *API) renterLoadAsciiHandler(w http.ResponseWriter, req *http.Request,
 	WriteJSON(w, RenterLoad{FilesAdded: files})
 }
 
+// renterPinHandler handles the API call to repair a file and wait until it
+// reaches a minimum redundancy.
+func (api *API) renterPinHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	minRedundancy, err := strconv.ParseFloat(req.FormValue("minredundancy"), 64)
+	if err != nil {
+		WriteError(w, Error{"unable to parse minredundancy: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.renter.PinFile(strings.TrimPrefix(ps.ByName("siapath"), "/"), minRedundancy)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	WriteSuccess(w)
+}
+
+// renterAlertsHandlerGET handles the API call to report the alerts that are
+// currently active on the renter.
+func (api *API) renterAlertsHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, RenterAlertsGET{
+		Alerts: api.renter.Alerts(),
+	})
+}
+
+// renterCacheHandlerGET handles the API call to report the statistics of the
+// renter's disk cache.
+func (api *API) renterCacheHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, RenterCacheGET{
+		CacheStats: api.renter.CacheStats(),
+	})
+}
+
+// renterHostScoresHandler handles the API call to report the scoring factors
+// of the hosts that the renter knows.
+func (api *API) renterHostScoresHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, RenterHostScoresGET{
+		Scores: api.renter.HostScores(),
+	})
+}
+
+// renterUsageHandler handles the API call to report the storage and bandwidth
+// used by the renter, broken down by siapath prefix.
+func (api *API) renterUsageHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	depth := 1
+	if req.FormValue("depth") != "" {
+		_, err := fmt.Sscan(req.FormValue("depth"), &depth)
+		if err != nil {
+			WriteError(w, Error{"unable to parse depth: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	usage, err := api.renter.UsageByPrefix(depth)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterUsageGET{
+		RenterUsage: usage,
+	})
+}
+
+// renterUtilizationHandler handles the API call to request the utilization of
+// the renter's contracts.
+func (api *API) renterUtilizationHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, RenterUtilizationGET{
+		Contracts: api.renter.ContractUtilization(),
+	})
+}
+
+// renterCacheHandlerPOST handles the API call to set the maximum size of the
+// renter's disk cache.
+func (api *API) renterCacheHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	size, err := strconv.ParseUint(req.FormValue("maxsize"), 10, 64)
+	if err != nil {
+		WriteError(w, Error{"unable to parse maxsize: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.renter.SetCacheSize(size)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	WriteSuccess(w)
+}
+
+// renterHostCertHandlerPOST handles the API call to pin the TLS certificate of
+// a host. An empty certificate unpins it.
+func (api *API) renterHostCertHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var hostKey types.SiaPublicKey
+	hostKey.LoadString(req.FormValue("hostkey"))
+	if hostKey.String() != req.FormValue("hostkey") {
+		WriteError(w, Error{"invalid host public key: " + req.FormValue("hostkey")}, http.StatusBadRequest)
+		return
+	}
+	var cert []byte
+	if req.FormValue("cert") != "" {
+		cert = []byte(req.FormValue("cert"))
+	}
+	err := api.renter.SetPinnedHostCert(hostKey, cert)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	WriteSuccess(w)
+}
+
+// renterRedundancyHandlerGET handles the API call to list the progress of the
+// renter's redundancy changes.
+func (api *API) renterRedundancyHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, RenterRedundancyChanges{
+		Changes: api.renter.RedundancyChanges(),
+	})
+}
+
+// renterRedundancyHandlerPOST handles the API call to re-encode a file, or
+// every file in a directory, to new erasure coding parameters, or to set the
+// target redundancy of a file.
+func (api *API) renterRedundancyHandlerPOST(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	siaPath := strings.TrimPrefix(ps.ByName("siapath"), "/")
+	if req.FormValue("redundancy") != "" {
+		redundancy, err := strconv.ParseFloat(req.FormValue("redundancy"), 64)
+		if err != nil {
+			WriteError(w, Error{"unable to parse redundancy: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		err = api.renter.SetFileRedundancy(siaPath, redundancy)
+		if err != nil {
+			WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+			return
+		}
+		WriteSuccess(w)
+		return
+	}
+
+	dataPieces, err := strconv.Atoi(req.FormValue("datapieces"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse datapieces: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	parityPieces, err := strconv.Atoi(req.FormValue("paritypieces"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse paritypieces: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.renter.ChangeRedundancy(siaPath, dataPieces, parityPieces)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	WriteSuccess(w)
+}
+
 // renterRenameHandler handles the API call to rename a file entry in the
 // renter.
 func (api *API) renterRenameHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
@@ -309,13 +778,178 @@ func (api *API) renterRenameHandler(w http.ResponseWriter, req *http.Request, ps
 	WriteSuccess(w)
 }
 
-// renterFilesHandler handles the API call to list all of the files.
+// renterFilesHandler handles the API call to list all of the files, or the
+// files that match the search parameters.
 func (api *API) renterFilesHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Scan the search parameters. (optional parameters)
+	query := req.FormValue("query")
+	if _, err := path.Match(query, ""); err != nil {
+		WriteError(w, Error{"unable to parse query: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	var opts modules.SearchOptions
+	for _, param := range []struct {
+		name string
+		time *time.Time
+	}{{"uploadedafter", &opts.UploadedAfter}, {"uploadedbefore", &opts.UploadedBefore}} {
+		if req.FormValue(param.name) == "" {
+			continue
+		}
+		var unix int64
+		if _, err := fmt.Sscan(req.FormValue(param.name), &unix); err != nil {
+			WriteError(w, Error{"unable to parse " + param.name + ": " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		*param.time = time.Unix(unix, 0)
+	}
+	if req.FormValue("minredundancy") != "" {
+		var err error
+		opts.MinRedundancy, err = strconv.ParseFloat(req.FormValue("minredundancy"), 64)
+		if err != nil {
+			WriteError(w, Error{"unable to parse minredundancy: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	files := api.renter.FileSearch(query, opts)
+	if name := req.FormValue("portfolio"); name != "" {
+		portfolioFiles := []modules.FileInfo{}
+		for _, f := range files {
+			if f.Portfolio == name {
+				portfolioFiles = append(portfolioFiles, f)
+			}
+		}
+		files = portfolioFiles
+	}
 	WriteJSON(w, RenterFiles{
-		Files: api.renter.FileList(),
+		Files: files,
 	})
 }
 
+// renterFileHandler handles the API call to return a single file. The piece
+// history of the file is only included if verbose is set.
+func (api *API) renterFileHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	var verbose bool
+	if v := req.FormValue("verbose"); v != "" {
+		var err error
+		verbose, err = scanBool(v)
+		if err != nil {
+			WriteError(w, Error{"unable to parse verbose: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	f, err := api.renter.File(strings.TrimPrefix(ps.ByName("siapath"), "/"), verbose)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterFile{
+		File: f,
+	})
+}
+
+// renterChecksumHandler handles the API call to request the checksum of a
+// file.
+func (api *API) renterChecksumHandler(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	checksum, err := api.renter.FileChecksum(strings.TrimPrefix(ps.ByName("siapath"), "/"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterChecksum{
+		Checksum: checksum,
+	})
+}
+
+// renterVersionsHandler handles the API call to list the versions of a file.
+func (api *API) renterVersionsHandler(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	versions := api.renter.ListVersions(strings.TrimPrefix(ps.ByName("siapath"), "/"))
+	if versions == nil {
+		WriteError(w, Error{renter.ErrUnknownPath.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterVersions{
+		Versions: versions,
+	})
+}
+
+// renterVersionRetentionHandlerGET handles the API call to get the version
+// retention of a file or directory.
+func (api *API) renterVersionRetentionHandlerGET(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	vr := api.renter.VersionRetention(strings.TrimPrefix(ps.ByName("siapath"), "/"))
+	WriteJSON(w, RenterVersionRetention{
+		MaxVersions: vr.MaxVersions,
+		MaxAge:      uint64(vr.MaxAge / time.Second),
+	})
+}
+
+// renterVersionRetentionHandlerPOST handles the API call to set or clear the
+// version retention of a directory.
+func (api *API) renterVersionRetentionHandlerPOST(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	dir := strings.TrimPrefix(ps.ByName("siapath"), "/")
+	if c := req.FormValue("clear"); c != "" {
+		clearRetention, err := scanBool(c)
+		if err != nil {
+			WriteError(w, Error{"unable to parse clear: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if clearRetention {
+			if err := api.renter.ClearVersionRetention(dir); err != nil {
+				WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+				return
+			}
+			WriteSuccess(w)
+			return
+		}
+	}
+
+	// Scan the retention. (optional parameters) Omitted limits are 0, which
+	// keeps no versions and previous versions of any age respectively.
+	var vr modules.VersionRetention
+	if mv := req.FormValue("maxversions"); mv != "" {
+		if _, err := fmt.Sscan(mv, &vr.MaxVersions); err != nil {
+			WriteError(w, Error{"unable to parse maxversions: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if ma := req.FormValue("maxage"); ma != "" {
+		var seconds uint64
+		if _, err := fmt.Sscan(ma, &seconds); err != nil {
+			WriteError(w, Error{"unable to parse maxage: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		vr.MaxAge = time.Duration(seconds) * time.Second
+	}
+	if err := api.renter.SetVersionRetention(dir, vr); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterPortfoliosHandler handles the API call to list the renter's allowance
+// portfolios.
+func (api *API) renterPortfoliosHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, RenterPortfolios{
+		Portfolios: api.renter.Portfolios(),
+	})
+}
+
+// portfolioSiaPath returns the siapath of an upload, placing it in the
+// namespace of the portfolio named by the optional 'portfolio' parameter.
+func (api *API) portfolioSiaPath(req *http.Request, ps httprouter.Params) (string, error) {
+	siapath := strings.TrimPrefix(ps.ByName("siapath"), "/")
+	name := req.FormValue("portfolio")
+	if name == "" {
+		return siapath, nil
+	}
+	p, err := api.renter.Portfolio(name)
+	if err != nil {
+		return "", err
+	}
+	return p.SiaPathPrefix + siapath, nil
+}
+
 // renterPricesHandler reports the expected costs of various actions given the
 // renter settings and the set of available hosts.
 func (api *API) renterPricesHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
@@ -324,10 +958,72 @@ func (api *API) renterPricesHandler(w http.ResponseWriter, req *http.Request, _
 	})
 }
 
+// renterDirHandlerGET handles the API call to list a directory.
+func (api *API) renterDirHandlerGET(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	dirs, files, err := api.renter.DirList(strings.TrimPrefix(ps.ByName("siapath"), "/"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterDirectory{
+		Directories: dirs,
+		Files:       files,
+	})
+}
+
+// renterDirHandlerPOST handles the API calls to create, delete, and rename a
+// directory.
+func (api *API) renterDirHandlerPOST(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	siaPath := strings.TrimPrefix(ps.ByName("siapath"), "/")
+	var err error
+	switch action := req.FormValue("action"); action {
+	case "create":
+		err = api.renter.CreateDir(siaPath)
+	case "delete":
+		var recursive bool
+		if r := req.FormValue("recursive"); r != "" {
+			recursive, err = scanBool(r)
+			if err != nil {
+				WriteError(w, Error{"unable to parse recursive: " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+		}
+		err = api.renter.DeleteDir(siaPath, recursive)
+	case "rename":
+		err = api.renter.RenameDir(siaPath, req.FormValue("newsiapath"))
+	default:
+		err = fmt.Errorf("unknown action %q, must be 'create', 'delete', or 'rename'", action)
+	}
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	WriteSuccess(w)
+}
+
 // renterDeleteHandler handles the API call to delete a file entry from the
 // renter.
 func (api *API) renterDeleteHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
-	err := api.renter.DeleteFile(strings.TrimPrefix(ps.ByName("siapath"), "/"))
+	siaPath := strings.TrimPrefix(ps.ByName("siapath"), "/")
+
+	// Scan the purge parameter. (optional parameter) If it is set, the
+	// previous versions of the file are deleted as well.
+	var purge bool
+	if p := req.FormValue("purge"); p != "" {
+		var err error
+		purge, err = scanBool(p)
+		if err != nil {
+			WriteError(w, Error{"unable to parse purge: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	var err error
+	if purge {
+		err = api.renter.PurgeFile(siaPath)
+	} else {
+		err = api.renter.DeleteFile(siaPath)
+	}
 	if err != nil {
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
@@ -344,12 +1040,29 @@ func (api *API) renterDownloadHandler(w http.ResponseWriter, req *http.Request,
 		return
 	}
 
+	// Parse the version parameter. (optional parameter) If it is not
+	// provided, the current version of the file is downloaded.
+	var version int
+	if req.FormValue("version") != "" {
+		_, err = fmt.Sscan(req.FormValue("version"), &version)
+		if err != nil {
+			WriteError(w, Error{"unable to parse version: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	download := func() error {
+		if version == 0 {
+			return api.renter.Download(params)
+		}
+		return api.renter.DownloadVersion(params, version)
+	}
+
 	if params.Async { // Create goroutine if `async` param set.
 		// check for errors for 5 seconds to catch validation errors (no file with
 		// that path, invalid parameters, insufficient hosts, etc)
 		errchan := make(chan error)
 		go func() {
-			errchan <- api.renter.Download(params)
+			errchan <- download()
 		}()
 		select {
 		case err = <-errchan:
@@ -360,7 +1073,7 @@ func (api *API) renterDownloadHandler(w http.ResponseWriter, req *http.Request,
 		case <-time.After(time.Millisecond * 100):
 		}
 	} else {
-		err := api.renter.Download(params)
+		err := download()
 		if err != nil {
 			WriteError(w, Error{"download failed: " + err.Error()}, http.StatusInternalServerError)
 			return
@@ -475,6 +1188,47 @@ func (api *API) renterShareAsciiHandler(w http.ResponseWriter, req *http.Request
 	})
 }
 
+// parseErasureCoder parses the optional 'datapieces' and 'paritypieces'
+// parameters of an upload request. A nil ErasureCoder is returned if neither
+// parameter was supplied, causing the renter to use its defaults.
+func parseErasureCoder(req *http.Request) (modules.ErasureCoder, error) {
+	if req.FormValue("datapieces") == "" && req.FormValue("paritypieces") == "" {
+		return nil, nil
+	}
+	// Check that both values have been supplied.
+	if req.FormValue("datapieces") == "" || req.FormValue("paritypieces") == "" {
+		return nil, errors.New("must provide both the datapieces paramaeter and the paritypieces parameter if specifying erasure coding parameters")
+	}
+
+	// Parse the erasure coding parameters.
+	var dataPieces, parityPieces int
+	_, err := fmt.Sscan(req.FormValue("datapieces"), &dataPieces)
+	if err != nil {
+		return nil, errors.New("unable to read parameter 'datapieces': " + err.Error())
+	}
+	_, err = fmt.Sscan(req.FormValue("paritypieces"), &parityPieces)
+	if err != nil {
+		return nil, errors.New("unable to read parameter 'paritypieces': " + err.Error())
+	}
+
+	// Verify that sane values for parityPieces and redundancy are being
+	// supplied.
+	if parityPieces < requiredParityPieces {
+		return nil, fmt.Errorf("a minimum of %v parity pieces is required, but %v parity pieces requested", parityPieces, requiredParityPieces)
+	}
+	redundancy := float64(dataPieces+parityPieces) / float64(dataPieces)
+	if float64(dataPieces+parityPieces)/float64(dataPieces) < requiredRedundancy {
+		return nil, fmt.Errorf("a redundancy of %.2f is required, but redundancy of %.2f supplied", redundancy, requiredRedundancy)
+	}
+
+	// Create the erasure coder.
+	ec, err := renter.NewRSCode(dataPieces, parityPieces)
+	if err != nil {
+		return nil, errors.New("unable to encode file using the provided parameters: " + err.Error())
+	}
+	return ec, nil
+}
+
 // renterUploadHandler handles the API call to upload a file.
 func (api *API) renterUploadHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	source := req.FormValue("source")
@@ -484,56 +1238,164 @@ func (api *API) renterUploadHandler(w http.ResponseWriter, req *http.Request, ps
 	}
 
 	// Check whether the erasure coding parameters have been supplied.
-	var ec modules.ErasureCoder
-	if req.FormValue("datapieces") != "" || req.FormValue("paritypieces") != "" {
-		// Check that both values have been supplied.
-		if req.FormValue("datapieces") == "" || req.FormValue("paritypieces") == "" {
-			WriteError(w, Error{"must provide both the datapieces paramaeter and the paritypieces parameter if specifying erasure coding parameters"}, http.StatusBadRequest)
-			return
-		}
+	ec, err := parseErasureCoder(req)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	siapath, err := api.portfolioSiaPath(req, ps)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Call the renter to upload the file.
+	err = api.renter.Upload(modules.FileUploadParams{
+		Source:      source,
+		SiaPath:     siapath,
+		ErasureCode: ec,
+	})
+	if err != nil {
+		WriteError(w, Error{"upload failed: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterUploadURLHandler handles the API call to upload the body of a URL.
+func (api *API) renterUploadURLHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	sourceURL := req.FormValue("url")
+	if sourceURL == "" {
+		WriteError(w, Error{"url must be provided"}, http.StatusBadRequest)
+		return
+	}
+	ec, err := parseErasureCoder(req)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	siapath, err := api.portfolioSiaPath(req, ps)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Call the renter to upload the URL. The upload continues in the
+	// background after the call returns, so it is not tied to the request.
+	err = api.renter.UploadFromURL(context.Background(), sourceURL, siapath, modules.FileUploadParams{
+		ErasureCode: ec,
+	})
+	if err != nil {
+		WriteError(w, Error{"upload failed: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterUploadDirectoryHandler handles the API call to upload a directory
+// tree.
+func (api *API) renterUploadDirectoryHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	source := req.FormValue("source")
+	if !filepath.IsAbs(source) {
+		WriteError(w, Error{"source must be an absolute path"}, http.StatusBadRequest)
+		return
+	}
+	ec, err := parseErasureCoder(req)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
 
-		// Parse the erasure coding parameters.
-		var dataPieces, parityPieces int
-		_, err := fmt.Sscan(req.FormValue("datapieces"), &dataPieces)
+	siapath, err := api.portfolioSiaPath(req, ps)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the optional parameters.
+	params := modules.DirectoryUploadParams{
+		LocalPath:   source,
+		SiaPath:     siapath,
+		ErasureCode: ec,
+		Recursive:   true,
+	}
+	if r := req.FormValue("recursive"); r != "" {
+		params.Recursive, err = scanBool(r)
 		if err != nil {
-			WriteError(w, Error{"unable to read parameter 'datapieces': " + err.Error()}, http.StatusBadRequest)
+			WriteError(w, Error{"unable to parse 'recursive' arg: " + err.Error()}, http.StatusBadRequest)
 			return
 		}
-		_, err = fmt.Sscan(req.FormValue("paritypieces"), &parityPieces)
+	}
+	if f := req.FormValue("followsymlinks"); f != "" {
+		params.FollowSymlinks, err = scanBool(f)
 		if err != nil {
-			WriteError(w, Error{"unable to read parameter 'paritypieces': " + err.Error()}, http.StatusBadRequest)
-			return
-		}
-
-		// Verify that sane values for parityPieces and redundancy are being
-		// supplied.
-		if parityPieces < requiredParityPieces {
-			WriteError(w, Error{fmt.Sprintf("a minimum of %v parity pieces is required, but %v parity pieces requested", parityPieces, requiredParityPieces)}, http.StatusBadRequest)
-			return
-		}
-		redundancy := float64(dataPieces+parityPieces) / float64(dataPieces)
-		if float64(dataPieces+parityPieces)/float64(dataPieces) < requiredRedundancy {
-			WriteError(w, Error{fmt.Sprintf("a redundancy of %.2f is required, but redundancy of %.2f supplied", redundancy, requiredRedundancy)}, http.StatusBadRequest)
+			WriteError(w, Error{"unable to parse 'followsymlinks' arg: " + err.Error()}, http.StatusBadRequest)
 			return
 		}
-
-		// Create the erasure coder.
-		ec, err = renter.NewRSCode(dataPieces, parityPieces)
+	}
+	if wk := req.FormValue("workers"); wk != "" {
+		_, err = fmt.Sscan(wk, &params.Workers)
 		if err != nil {
-			WriteError(w, Error{"unable to encode file using the provided parameters: " + err.Error()}, http.StatusBadRequest)
+			WriteError(w, Error{"unable to parse 'workers' arg: " + err.Error()}, http.StatusBadRequest)
 			return
 		}
 	}
 
-	// Call the renter to upload the file.
-	err := api.renter.Upload(modules.FileUploadParams{
-		Source:      source,
-		SiaPath:     strings.TrimPrefix(ps.ByName("siapath"), "/"),
-		ErasureCode: ec,
-	})
-	if err != nil {
+	// Call the renter to upload the directory. The upload is cancelled if the
+	// client disconnects.
+	result, err := api.renter.UploadDirectory(req.Context(), params)
+	if err != nil && result.Files == nil {
 		WriteError(w, Error{"upload failed: " + err.Error()}, http.StatusInternalServerError)
 		return
 	}
+	WriteJSON(w, result)
+}
+
+// renterBackupHandler handles the API call to back up a local directory tree
+// to Sia.
+func (api *API) renterBackupHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	source := req.FormValue("source")
+	if !filepath.IsAbs(source) {
+		WriteError(w, Error{"source must be an absolute path"}, http.StatusBadRequest)
+		return
+	}
+	siapath, err := api.portfolioSiaPath(req, ps)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	manifest, err := api.renter.BackupToSia(source, siapath)
+	if err != nil && manifest.SiaPath == "" {
+		WriteError(w, Error{"backup failed: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	resp := RenterBackupPOST{Manifest: manifest}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	WriteJSON(w, resp)
+}
+
+// renterRestoreHandler handles the API call to restore the files of a backup
+// manifest into a local directory.
+func (api *API) renterRestoreHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	destination := req.FormValue("destination")
+	if !filepath.IsAbs(destination) {
+		WriteError(w, Error{"destination must be an absolute path"}, http.StatusBadRequest)
+		return
+	}
+	var manifest modules.BackupManifest
+	if err := json.Unmarshal([]byte(req.FormValue("manifest")), &manifest); err != nil {
+		WriteError(w, Error{"unable to parse manifest: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	if err := api.renter.RestoreFromSia(manifest, destination); err != nil {
+		WriteError(w, Error{"restore failed: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
 	WriteSuccess(w)
 }
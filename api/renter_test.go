@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -15,6 +16,7 @@ import (
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/renter"
 	"github.com/NebulousLabs/Sia/modules/renter/contractor"
@@ -282,6 +284,157 @@ func TestValidDownloads(t *testing.T) {
 	}
 }
 
+// slowWriter is an io.Writer that sleeps before every write, and fails once
+// failAfter bytes have been written if failAfter is not zero.
+type slowWriter struct {
+	buf       bytes.Buffer
+	delay     time.Duration
+	failAfter int
+}
+
+var errSlowWriter = errors.New("slow writer failed")
+
+func (w *slowWriter) Write(b []byte) (int, error) {
+	time.Sleep(w.delay)
+	if w.failAfter != 0 && w.buf.Len()+len(b) > w.failAfter {
+		return 0, errSlowWriter
+	}
+	return w.buf.Write(b)
+}
+
+// TestRenterDownloadWriter tests streaming downloads of files and sections of
+// files to an io.Writer.
+func TestRenterDownloadWriter(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// The file is uploaded with a single data piece, so every chunk holds one
+	// piece of data.
+	chunkSize := int(modules.SectorSize - crypto.TwofishOverhead)
+	st, path := setupTestDownload(t, 5*chunkSize+100, "test.dat", true)
+	defer st.server.panicClose()
+	orig, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &slowWriter{delay: 10 * time.Millisecond}
+	if err := st.renter.DownloadWriter("test.dat", w); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(w.buf.Bytes(), orig) {
+		t.Fatal("streamed file does not match the original")
+	}
+
+	sections := []struct {
+		offset, length int
+	}{
+		{40, 10},
+		{0, chunkSize - 1},
+		{chunkSize - 1, 2},
+		{chunkSize + 20, 3 * chunkSize},
+		{4*chunkSize + 50, chunkSize + 50},
+	}
+	for _, section := range sections {
+		var buf bytes.Buffer
+		if err := st.renter.DownloadSection("test.dat", uint64(section.offset), uint64(section.length), &buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), orig[section.offset:section.offset+section.length]) {
+			t.Fatalf("streamed section %v does not match the original", section)
+		}
+	}
+
+	// A failing writer should report the bytes that were written before the
+	// failure.
+	w = &slowWriter{failAfter: 2*chunkSize + 1}
+	err = st.renter.DownloadWriter("test.dat", w)
+	streamErr, ok := err.(modules.DownloadStreamError)
+	if !ok || streamErr.Err != errSlowWriter || streamErr.BytesWritten != uint64(2*chunkSize) {
+		t.Fatal("expected a stream error after 2 chunks, got", err)
+	}
+
+	if err := st.renter.DownloadWriter("missing.dat", w); err != renter.ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+}
+
+// TestRenterDownloadSection tests downloads of sections of a file to an
+// io.Writer, and that they are recorded in the download queue.
+func TestRenterDownloadSection(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	chunkSize := int(modules.SectorSize - crypto.TwofishOverhead)
+	filesize := 3*chunkSize + 100
+	st, path := setupTestDownload(t, filesize, "test.dat", true)
+	defer st.server.panicClose()
+	orig, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sections := []struct {
+		offset, length int
+	}{
+		{40, 10},
+		{chunkSize - 1, 2},
+		{chunkSize + 20, chunkSize},
+		{filesize - 1, 1},
+		{0, filesize},
+	}
+	for _, section := range sections {
+		var buf bytes.Buffer
+		if err := st.renter.DownloadSection("test.dat", uint64(section.offset), uint64(section.length), &buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), orig[section.offset:section.offset+section.length]) {
+			t.Fatalf("downloaded section %v does not match the original", section)
+		}
+	}
+
+	// Out of range and empty sections are rejected.
+	invalid := []struct {
+		offset, length int
+		err            error
+	}{
+		{0, 0, renter.ErrZeroLengthSection},
+		{filesize, 1, renter.ErrSectionOutOfRange},
+		{filesize - 10, 11, renter.ErrSectionOutOfRange},
+	}
+	for _, section := range invalid {
+		var buf bytes.Buffer
+		if err := st.renter.DownloadSection("test.dat", uint64(section.offset), uint64(section.length), &buf); err != section.err {
+			t.Fatalf("expected %v for section %v, got %v", section.err, section, err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := st.renter.DownloadSection("missing.dat", 0, 1, &buf); err != renter.ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+
+	// The download queue should list the sections, newest first, and only
+	// the download of the whole file should not be a section.
+	var queue RenterDownloadQueue
+	if err := st.getAPI("/renter/downloads", &queue); err != nil {
+		t.Fatal(err)
+	}
+	if len(queue.Downloads) != len(sections) {
+		t.Fatalf("expected %v downloads, got %v", len(sections), len(queue.Downloads))
+	}
+	for i, d := range queue.Downloads {
+		section := sections[len(sections)-1-i]
+		whole := section.offset == 0 && section.length == filesize
+		if d.Offset != uint64(section.offset) || d.Filesize != uint64(section.length) || d.Received != uint64(section.length) || d.Section == whole || d.Destination != "writer" {
+			t.Fatalf("wrong download queue entry for section %v: %+v", section, d)
+		}
+	}
+}
+
 func runDownloadParamTest(t *testing.T, length, offset, filesize int) error {
 	ulSiaPath := "test.dat"
 
@@ -536,6 +689,24 @@ func TestRenterPaths(t *testing.T) {
 	if len(rf.Files) != 1 || rf.Files[0].SiaPath != "foo/bar/test" {
 		t.Fatal("/renter/files did not return correct file:", rf)
 	}
+
+	// The file should only be found by matching searches.
+	for query, found := range map[string]bool{
+		"query=foo/*/test": true,
+		"query=foo/*":      false,
+		"uploadedafter=1&uploadedbefore=99999999999": true,
+		"uploadedafter=99999999999":                  false,
+	} {
+		rf = RenterFiles{}
+		if err := st.getAPI("/renter/files?"+query, &rf); err != nil {
+			t.Fatal(err)
+		} else if (len(rf.Files) == 1) != found {
+			t.Errorf("search %q returned %v", query, rf.Files)
+		}
+	}
+	if err := st.getAPI("/renter/files?query=[", &rf); err == nil {
+		t.Fatal("expected a malformed query to be rejected")
+	}
 }
 
 // TestRenterConflicts tests that the renter handles naming conflicts properly.
@@ -2132,3 +2303,920 @@ func TestAdversarialPriceRenewal(t *testing.T) {
 		time.Sleep(time.Millisecond * 100)
 	}
 }
+
+// TestRenterPortfolioIsolation checks that a portfolio without funds cannot
+// use the contracts or funds of another portfolio, even though both
+// portfolios share the same renter and hosts.
+func TestRenterPortfolioIsolation(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	// Announce the host and start accepting contracts.
+	if err := st.announceHost(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.acceptContracts(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.setHostStorage(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fund the 'rich' portfolio and wait for it to form a contract.
+	allowanceValues := url.Values{}
+	allowanceValues.Set("funds", testFunds)
+	allowanceValues.Set("period", "10")
+	allowanceValues.Set("portfolio", "rich")
+	if err := st.stdPostAPI("/renter", allowanceValues); err != nil {
+		t.Fatal(err)
+	}
+	var richContracts RenterContracts
+	err = retry(50, 100*time.Millisecond, func() error {
+		if err := st.getAPI("/renter/contracts?portfolio=rich", &richContracts); err != nil {
+			return err
+		}
+		if len(richContracts.Contracts) != 1 {
+			return errors.New("rich portfolio has not formed a contract")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var richBefore RenterGET
+	if err := st.getAPI("/renter?portfolio=rich", &richBefore); err != nil {
+		t.Fatal(err)
+	}
+
+	// The 'poor' portfolio cannot afford a single sector, so setting its
+	// allowance fails, but the portfolio itself is created.
+	allowanceValues.Set("funds", "1")
+	allowanceValues.Set("portfolio", "poor")
+	if err := st.stdPostAPI("/renter", allowanceValues); err == nil || err.Error() != contractor.ErrInsufficientAllowance.Error() {
+		t.Fatal("expected ErrInsufficientAllowance, got", err)
+	}
+	var rp RenterPortfolios
+	if err := st.getAPI("/renter/portfolios", &rp); err != nil {
+		t.Fatal(err)
+	}
+	if len(rp.Portfolios) != 3 || rp.Portfolios[1].Name != "poor" || rp.Portfolios[2].Name != "rich" {
+		t.Fatal("unexpected portfolios:", rp.Portfolios)
+	}
+
+	// Upload a file into each portfolio.
+	path := filepath.Join(st.dir, "test.dat")
+	if err := createRandFile(path, 1024); err != nil {
+		t.Fatal(err)
+	}
+	uploadValues := url.Values{}
+	uploadValues.Set("source", path)
+	uploadValues.Set("datapieces", "1")
+	uploadValues.Set("paritypieces", "1")
+	for _, portfolio := range []string{"rich", "poor"} {
+		uploadValues.Set("portfolio", portfolio)
+		if err := st.stdPostAPI("/renter/upload/test", uploadValues); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The file of the rich portfolio should upload using the rich
+	// portfolio's contract.
+	err = retry(100, 100*time.Millisecond, func() error {
+		var rf RenterFiles
+		if err := st.getAPI("/renter/files?portfolio=rich", &rf); err != nil {
+			return err
+		}
+		if len(rf.Files) != 1 || rf.Files[0].SiaPath != "rich/test" || !rf.Files[0].Available {
+			return fmt.Errorf("rich file did not upload: %v", rf.Files)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The file of the poor portfolio should not make any progress, because
+	// the poor portfolio has no contracts of its own.
+	var rf RenterFiles
+	if err := st.getAPI("/renter/files?portfolio=poor", &rf); err != nil {
+		t.Fatal(err)
+	}
+	if len(rf.Files) != 1 || rf.Files[0].SiaPath != "poor/test" {
+		t.Fatal("poor file is missing:", rf.Files)
+	}
+	if rf.Files[0].UploadProgress != 0 || rf.Files[0].Available {
+		t.Fatal("poor file was uploaded using contracts of another portfolio:", rf.Files[0])
+	}
+
+	// The poor portfolio should not have any contracts or spending, and the
+	// rich portfolio should not have paid for any new contracts.
+	var poorContracts RenterContracts
+	if err := st.getAPI("/renter/contracts?portfolio=poor", &poorContracts); err != nil {
+		t.Fatal(err)
+	}
+	if len(poorContracts.Contracts) != 0 {
+		t.Fatal("poor portfolio has contracts:", poorContracts.Contracts)
+	}
+	var poor RenterGET
+	if err := st.getAPI("/renter?portfolio=poor", &poor); err != nil {
+		t.Fatal(err)
+	}
+	if !poor.FinancialMetrics.ContractSpending.IsZero() || !poor.FinancialMetrics.UploadSpending.IsZero() {
+		t.Fatal("poor portfolio spent money:", poor.FinancialMetrics)
+	}
+	var richAfter RenterGET
+	if err := st.getAPI("/renter?portfolio=rich", &richAfter); err != nil {
+		t.Fatal(err)
+	}
+	if richAfter.FinancialMetrics.ContractSpending.Cmp(richBefore.FinancialMetrics.ContractSpending) != 0 {
+		t.Fatal("rich portfolio paid for additional contracts")
+	}
+	if err := st.getAPI("/renter/contracts?portfolio=rich", &richContracts); err != nil {
+		t.Fatal(err)
+	}
+	if len(richContracts.Contracts) != 1 {
+		t.Fatal("rich portfolio formed additional contracts:", richContracts.Contracts)
+	}
+
+	// The default portfolio should be unaffected by either portfolio.
+	var defaultContracts RenterContracts
+	if err := st.getAPI("/renter/contracts", &defaultContracts); err != nil {
+		t.Fatal(err)
+	}
+	if len(defaultContracts.Contracts) != 0 {
+		t.Fatal("default portfolio has contracts:", defaultContracts.Contracts)
+	}
+}
+
+// TestRenterPinFile checks that /renter/pin blocks until a file reaches the
+// requested redundancy, and reports the achieved redundancy if the file cannot
+// reach it.
+func TestRenterPinFile(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	// Announce the host and start accepting contracts.
+	if err := st.announceHost(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.acceptContracts(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.setHostStorage(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set an allowance and wait for the renter to form a contract.
+	allowanceValues := url.Values{}
+	allowanceValues.Set("funds", testFunds)
+	allowanceValues.Set("period", testPeriod)
+	if err := st.stdPostAPI("/renter", allowanceValues); err != nil {
+		t.Fatal(err)
+	}
+	err = retry(50, 100*time.Millisecond, func() error {
+		var rc RenterContracts
+		if err := st.getAPI("/renter/contracts", &rc); err != nil {
+			return err
+		}
+		if len(rc.Contracts) != 1 {
+			return errors.New("no contracts")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pinning a file that does not exist should fail.
+	pinValues := url.Values{}
+	pinValues.Set("minredundancy", "1")
+	if err := st.stdPostAPI("/renter/pin/test", pinValues); err == nil || err.Error() != renter.ErrUnknownPath.Error() {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+
+	// Upload a file with a maximum redundancy of 2.
+	path := filepath.Join(st.dir, "test.dat")
+	if err := createRandFile(path, 1024); err != nil {
+		t.Fatal(err)
+	}
+	uploadValues := url.Values{}
+	uploadValues.Set("source", path)
+	uploadValues.Set("datapieces", "1")
+	uploadValues.Set("paritypieces", "1")
+	if err := st.stdPostAPI("/renter/upload/test", uploadValues); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pinning the file at a redundancy of 1 should block until the file is
+	// available.
+	if err := st.stdPostAPI("/renter/pin/test", pinValues); err != nil {
+		t.Fatal(err)
+	}
+	var rf RenterFiles
+	if err := st.getAPI("/renter/files", &rf); err != nil {
+		t.Fatal(err)
+	}
+	if len(rf.Files) != 1 || rf.Files[0].Redundancy < 1 || !rf.Files[0].Available {
+		t.Fatal("file was not repaired before /renter/pin returned:", rf.Files)
+	}
+
+	// The verbose file query should report that the pieces of the file were
+	// uploaded to the host.
+	var rfile RenterFile
+	if err := st.getAPI("/renter/file/test", &rfile); err != nil {
+		t.Fatal(err)
+	} else if rfile.File.SiaPath != "test" || rfile.File.PieceHistory != nil {
+		t.Fatal("unexpected non-verbose file:", rfile.File)
+	}
+	if err := st.getAPI("/renter/file/test?verbose=true", &rfile); err != nil {
+		t.Fatal(err)
+	}
+	if len(rfile.File.PieceHistory) == 0 {
+		t.Fatal("piece history is empty")
+	}
+	for _, ph := range rfile.File.PieceHistory {
+		if len(ph.Events) != 1 || !bytes.Equal(ph.Events[0].HostPublicKey.Key, st.host.PublicKey().Key) || ph.Events[0].Reason != "" {
+			t.Fatal("unexpected piece history:", ph)
+		}
+	}
+
+	// The renter only has a single host, so the file cannot reach a
+	// redundancy of 2.
+	pinValues.Set("minredundancy", "2")
+	err = st.stdPostAPI("/renter/pin/test", pinValues)
+	if err == nil || !strings.Contains(err.Error(), "only reached a redundancy of 1.00") {
+		t.Fatal("expected pinning to fail with the achieved redundancy, got", err)
+	}
+
+	// A redundancy beyond the file's erasure code should be rejected.
+	pinValues.Set("minredundancy", "3")
+	if err := st.stdPostAPI("/renter/pin/test", pinValues); err == nil {
+		t.Fatal("expected pinning beyond the erasure code's redundancy to fail")
+	}
+}
+
+// TestRenterRedundancy checks that a file can be re-encoded to new erasure
+// coding parameters, and that a redundancy change that cannot finish leaves
+// the file as it was.
+func TestRenterRedundancy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+	stH1, err := blankServerTester(t.Name() + " - Host 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stH1.server.panicClose()
+	stH2, err := blankServerTester(t.Name() + " - Host 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stH2.server.panicClose()
+
+	// Connect the nodes and announce three hosts.
+	sts := []*serverTester{st, stH1, stH2}
+	if err := fullyConnectNodes(sts); err != nil {
+		t.Fatal(err)
+	}
+	if err := fundAllNodes(sts); err != nil {
+		t.Fatal(err)
+	}
+	if err := addStorageToAllHosts(sts); err != nil {
+		t.Fatal(err)
+	}
+	if err := announceAllHosts(sts); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set an allowance and wait for the renter to form three contracts.
+	allowanceValues := url.Values{}
+	allowanceValues.Set("funds", testFunds)
+	allowanceValues.Set("hosts", "3")
+	allowanceValues.Set("period", testPeriod)
+	if err := st.stdPostAPI("/renter", allowanceValues); err != nil {
+		t.Fatal(err)
+	}
+	err = retry(50, 100*time.Millisecond, func() error {
+		var rc RenterContracts
+		if err := st.getAPI("/renter/contracts", &rc); err != nil {
+			return err
+		}
+		if len(rc.Contracts) != 3 {
+			return errors.New("not enough contracts")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing the redundancy of a file that does not exist should fail.
+	redundancyValues := url.Values{}
+	redundancyValues.Set("datapieces", "2")
+	redundancyValues.Set("paritypieces", "1")
+	if err := st.stdPostAPI("/renter/redundancy/test", redundancyValues); err == nil || err.Error() != renter.ErrUnknownPath.Error() {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+
+	// Upload a 1-of-2 file and wait for it to reach full redundancy.
+	path := filepath.Join(st.dir, "test.dat")
+	if err := createRandFile(path, 1024); err != nil {
+		t.Fatal(err)
+	}
+	origBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploadValues := url.Values{}
+	uploadValues.Set("source", path)
+	uploadValues.Set("datapieces", "1")
+	uploadValues.Set("paritypieces", "1")
+	if err := st.stdPostAPI("/renter/upload/test", uploadValues); err != nil {
+		t.Fatal(err)
+	}
+	err = retry(120, 250*time.Millisecond, func() error {
+		var rf RenterFiles
+		if err := st.getAPI("/renter/files", &rf); err != nil {
+			return err
+		}
+		if len(rf.Files) != 1 || rf.Files[0].Redundancy != 2 {
+			return errors.New("file not uploaded")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Remove the source file so that the data has to be downloaded from the
+	// hosts, and re-encode the file as 2-of-3.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.stdPostAPI("/renter/redundancy/test", redundancyValues); err != nil {
+		t.Fatal(err)
+	}
+	err = retry(120, 250*time.Millisecond, func() error {
+		var rrc RenterRedundancyChanges
+		if err := st.getAPI("/renter/redundancy", &rrc); err != nil {
+			return err
+		}
+		if len(rrc.Changes) != 1 || !rrc.Changes[0].Finished {
+			return errors.New("redundancy change did not finish")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rf RenterFiles
+	if err := st.getAPI("/renter/files", &rf); err != nil {
+		t.Fatal(err)
+	}
+	if len(rf.Files) != 1 || rf.Files[0].Redundancy != 1.5 || !rf.Files[0].Available {
+		t.Fatal("file was not re-encoded:", rf.Files)
+	}
+	downloadPath := filepath.Join(st.dir, "test-downloaded-1.dat")
+	if err := st.stdGetAPI("/renter/download/test?destination=" + downloadPath); err != nil {
+		t.Fatal(err)
+	}
+	downloadBytes, err := ioutil.ReadFile(downloadPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloadBytes, origBytes) {
+		t.Fatal("downloaded file does not match the uploaded file")
+	}
+
+	// Re-encoding the file as 1-of-4 cannot finish with three hosts. The file
+	// should keep its current erasure code and remain downloadable.
+	redundancyValues.Set("datapieces", "1")
+	redundancyValues.Set("paritypieces", "3")
+	if err := st.stdPostAPI("/renter/redundancy/test", redundancyValues); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.stdPostAPI("/renter/redundancy/test", redundancyValues); err == nil {
+		t.Fatal("expected starting a second redundancy change of the file to fail")
+	}
+	time.Sleep(5 * time.Second)
+	var rrc RenterRedundancyChanges
+	if err := st.getAPI("/renter/redundancy", &rrc); err != nil {
+		t.Fatal(err)
+	}
+	if len(rrc.Changes) != 1 || rrc.Changes[0].Finished || rrc.Changes[0].DataPieces != 1 || rrc.Changes[0].ChunksComplete != 0 {
+		t.Fatal("redundancy change should not have finished:", rrc.Changes)
+	}
+	if err := st.getAPI("/renter/files", &rf); err != nil {
+		t.Fatal(err)
+	}
+	if len(rf.Files) != 1 || rf.Files[0].Redundancy != 1.5 || !rf.Files[0].Available {
+		t.Fatal("file was changed by an unfinished redundancy change:", rf.Files)
+	}
+	downloadPath = filepath.Join(st.dir, "test-downloaded-2.dat")
+	if err := st.stdGetAPI("/renter/download/test?destination=" + downloadPath); err != nil {
+		t.Fatal(err)
+	}
+	downloadBytes, err = ioutil.ReadFile(downloadPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloadBytes, origBytes) {
+		t.Fatal("downloaded file does not match the uploaded file")
+	}
+}
+
+// TestRenterTargetRedundancy checks that the target redundancy of a file can
+// be set through /renter/redundancy, and that a target beyond the file's
+// erasure code starts a redundancy change.
+func TestRenterTargetRedundancy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	path := filepath.Join(st.dir, "test.dat")
+	if err := createRandFile(path, 1024); err != nil {
+		t.Fatal(err)
+	}
+	uploadValues := url.Values{}
+	uploadValues.Set("source", path)
+	uploadValues.Set("datapieces", "1")
+	uploadValues.Set("paritypieces", "1")
+	if err := st.stdPostAPI("/renter/upload/test", uploadValues); err != nil {
+		t.Fatal(err)
+	}
+	var rf RenterFiles
+	if err := st.getAPI("/renter/files", &rf); err != nil {
+		t.Fatal(err)
+	}
+	if len(rf.Files) != 1 || rf.Files[0].TargetRedundancy != 2 {
+		t.Fatal("expected a target redundancy of 2:", rf.Files)
+	}
+
+	redundancyValues := url.Values{}
+	redundancyValues.Set("redundancy", "0.5")
+	if err := st.stdPostAPI("/renter/redundancy/test", redundancyValues); err == nil {
+		t.Fatal("expected a target redundancy below 1 to be rejected")
+	}
+	redundancyValues.Set("redundancy", "1")
+	if err := st.stdPostAPI("/renter/redundancy/missing", redundancyValues); err == nil || err.Error() != renter.ErrUnknownPath.Error() {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+	if err := st.stdPostAPI("/renter/redundancy/test", redundancyValues); err != nil {
+		t.Fatal(err)
+	}
+	var rfile RenterFile
+	if err := st.getAPI("/renter/file/test", &rfile); err != nil {
+		t.Fatal(err)
+	}
+	if rfile.File.TargetRedundancy != 1 {
+		t.Fatal("expected a target redundancy of 1, got", rfile.File.TargetRedundancy)
+	}
+
+	// A target of 3 needs a 1-of-3 erasure code.
+	redundancyValues.Set("redundancy", "3")
+	if err := st.stdPostAPI("/renter/redundancy/test", redundancyValues); err != nil {
+		t.Fatal(err)
+	}
+	var rrc RenterRedundancyChanges
+	if err := st.getAPI("/renter/redundancy", &rrc); err != nil {
+		t.Fatal(err)
+	}
+	if len(rrc.Changes) != 1 || rrc.Changes[0].DataPieces != 1 || rrc.Changes[0].ParityPieces != 2 {
+		t.Fatal("expected a redundancy change to 1-of-3:", rrc.Changes)
+	}
+}
+
+// TestRenterCache checks that repeated downloads are served from the renter's
+// disk cache.
+func TestRenterCache(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, path := setupTestDownload(t, int(3*modules.SectorSize), "test", true)
+	defer st.server.panicClose()
+	origBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The cache is disabled in testing builds.
+	var rc RenterCacheGET
+	if err := st.getAPI("/renter/cache", &rc); err != nil {
+		t.Fatal(err)
+	}
+	if rc.MaxSize != 0 || rc.Entries != 0 {
+		t.Fatal("unexpected cache stats:", rc)
+	}
+	cacheValues := url.Values{}
+	cacheValues.Set("maxsize", "foo")
+	if err := st.stdPostAPI("/renter/cache", cacheValues); err == nil {
+		t.Fatal("expected an invalid maxsize to be rejected")
+	}
+	cacheValues.Set("maxsize", "1048576")
+	if err := st.stdPostAPI("/renter/cache", cacheValues); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first download should fetch the chunks from the host and cache
+	// them, and the second download should be served from the cache.
+	for i := 0; i < 2; i++ {
+		downloadPath := filepath.Join(st.dir, fmt.Sprintf("test-downloaded-%v.dat", i))
+		if err := st.stdGetAPI("/renter/download/test?destination=" + downloadPath); err != nil {
+			t.Fatal(err)
+		}
+		downloadBytes, err := ioutil.ReadFile(downloadPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(downloadBytes, origBytes) {
+			t.Fatal("downloaded file does not match the uploaded file")
+		}
+	}
+	if err := st.getAPI("/renter/cache", &rc); err != nil {
+		t.Fatal(err)
+	}
+	if rc.MaxSize != 1<<20 || rc.Entries == 0 || rc.Hits != rc.Entries || rc.Misses != rc.Entries {
+		t.Fatal("second download was not served from the cache:", rc)
+	}
+
+	// Deleting the file should remove its chunks from the cache.
+	if err := st.stdPostAPI("/renter/delete/test", url.Values{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/renter/cache", &rc); err != nil {
+		t.Fatal(err)
+	}
+	if rc.Entries != 0 || rc.Size != 0 {
+		t.Fatal("chunks of a deleted file are still cached:", rc)
+	}
+}
+
+// TestRenterChecksum checks that /renter/checksum reports the checksum of an
+// uploaded file, and that downloads report that they were verified against
+// it.
+func TestRenterChecksum(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, path := setupTestDownload(t, int(2*modules.SectorSize), "test", true)
+	defer st.server.panicClose()
+	origBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rc RenterChecksum
+	if err := st.getAPI("/renter/checksum/test", &rc); err != nil {
+		t.Fatal(err)
+	}
+	if rc.Checksum.Algorithm != modules.ChecksumAlgorithmBlake2b || rc.Checksum.Digest != crypto.HashBytes(origBytes) {
+		t.Fatal("wrong checksum:", rc.Checksum)
+	}
+	if err := st.getAPI("/renter/checksum/foo", &rc); err == nil || err.Error() != renter.ErrUnknownPath.Error() {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+
+	// A whole download and a section download should both be verified.
+	downloadPath := filepath.Join(st.dir, "test-downloaded.dat")
+	if err := st.stdGetAPI("/renter/download/test?destination=" + downloadPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.stdGetAPI("/renter/download/test?offset=10&length=100&destination=" + downloadPath + ".section"); err != nil {
+		t.Fatal(err)
+	}
+	var rdq RenterDownloadQueue
+	if err := st.getAPI("/renter/downloads", &rdq); err != nil {
+		t.Fatal(err)
+	}
+	if len(rdq.Downloads) != 2 {
+		t.Fatal("expected 2 downloads, got", len(rdq.Downloads))
+	}
+	for _, d := range rdq.Downloads {
+		if d.ChecksumStatus != modules.DownloadChecksumVerified || d.Error != "" {
+			t.Fatal("download was not verified:", d)
+		}
+	}
+	var rf RenterFile
+	if err := st.getAPI("/renter/file/test", &rf); err != nil {
+		t.Fatal(err)
+	} else if rf.File.AuditRequired {
+		t.Fatal("verified file was marked for an audit")
+	}
+}
+
+// TestRenterUsage checks that /renter/usage reports the storage and transfers
+// of the renter's files.
+func TestRenterUsage(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, _ := setupTestDownload(t, int(modules.SectorSize), "test", true)
+	defer st.server.panicClose()
+
+	if err := st.stdGetAPI("/renter/usage?depth=0"); err == nil {
+		t.Fatal("expected a depth of 0 to be rejected")
+	}
+	downloadPath := filepath.Join(st.dir, "test.dat")
+	if err := st.stdGetAPI("/renter/download/test?destination=" + downloadPath); err != nil {
+		t.Fatal(err)
+	}
+
+	var ru RenterUsageGET
+	if err := st.getAPI("/renter/usage", &ru); err != nil {
+		t.Fatal(err)
+	}
+	if ru.Depth != 1 || len(ru.Prefixes) != 1 || ru.Prefixes[0] != ru.Total {
+		t.Fatalf("expected the usage of a single prefix: %+v", ru)
+	}
+	if ru.Total.Files != 1 || ru.Total.LogicalBytes != modules.SectorSize || ru.Total.UploadBytes == 0 || ru.Total.DownloadBytes == 0 {
+		t.Fatalf("unexpected total usage: %+v", ru.Total)
+	}
+
+	// The same transfers are reported by the contracts.
+	var rug RenterUtilizationGET
+	if err := st.getAPI("/renter/utilization", &rug); err != nil {
+		t.Fatal(err)
+	}
+	var uploaded, downloaded uint64
+	for id, cu := range rug.Contracts {
+		if id != cu.ContractID.String() {
+			t.Fatal("utilization is keyed by the wrong ID:", id, cu.ContractID)
+		}
+		uploaded += cu.DataUploaded
+		downloaded += cu.DataDownloaded
+	}
+	if len(rug.Contracts) == 0 || uploaded != ru.Total.UploadBytes || downloaded != ru.Total.DownloadBytes {
+		t.Fatalf("contracts transferred %v and %v bytes, expected %+v", uploaded, downloaded, ru.Total)
+	}
+}
+
+// TestRenterVersions checks that uploading to a siapath that is in use creates
+// a new version of the file once versioning is enabled, and that the previous
+// version can still be downloaded.
+func TestRenterVersions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, path := setupTestDownload(t, int(modules.SectorSize), "test", true)
+	defer st.server.panicClose()
+
+	if err := st.stdGetAPI("/renter/versions/missing"); err == nil {
+		t.Fatal("expected listing the versions of a missing file to fail")
+	}
+
+	// Enable versioning and upload a new version of the file.
+	allowanceValues := url.Values{}
+	allowanceValues.Set("funds", testFunds)
+	allowanceValues.Set("period", "10")
+	allowanceValues.Set("maxversionsperfile", "1")
+	if err := st.stdPostAPI("/renter", allowanceValues); err != nil {
+		t.Fatal(err)
+	}
+	newPath := filepath.Join(st.dir, "test2")
+	if err := createRandFile(newPath, int(modules.SectorSize)); err != nil {
+		t.Fatal(err)
+	}
+	uploadValues := url.Values{}
+	uploadValues.Set("source", newPath)
+	uploadValues.Set("datapieces", "1")
+	uploadValues.Set("paritypieces", "1")
+	if err := st.stdPostAPI("/renter/upload/test", uploadValues); err != nil {
+		t.Fatal(err)
+	}
+
+	var rv RenterVersions
+	if err := st.getAPI("/renter/versions/test", &rv); err != nil {
+		t.Fatal(err)
+	}
+	if len(rv.Versions) != 2 || rv.Versions[0].Version != 1 || rv.Versions[0].Current || !rv.Versions[1].Current {
+		t.Fatalf("expected a previous and a current version, got %+v", rv.Versions)
+	}
+
+	// Download the previous version.
+	downloadPath := filepath.Join(st.dir, "test.dat")
+	if err := st.stdGetAPI("/renter/download/test?version=1&destination=" + downloadPath); err != nil {
+		t.Fatal(err)
+	}
+	orig, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	download, err := ioutil.ReadFile(downloadPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(orig, download) {
+		t.Fatal("data mismatch when downloading the previous version")
+	}
+	if err := st.stdGetAPI("/renter/download/test?version=3&destination=" + downloadPath); err == nil {
+		t.Fatal("expected downloading an unknown version to fail")
+	}
+
+	// The retention of a directory takes precedence over the allowance.
+	var rvr RenterVersionRetention
+	if err := st.getAPI("/renter/versionretention/test", &rvr); err != nil {
+		t.Fatal(err)
+	} else if rvr.MaxVersions != 1 || rvr.MaxAge != 0 {
+		t.Fatalf("expected the retention of the allowance, got %+v", rvr)
+	}
+	retentionValues := url.Values{}
+	retentionValues.Set("maxversions", "2")
+	retentionValues.Set("maxage", "3600")
+	if err := st.stdPostAPI("/renter/versionretention/", retentionValues); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/renter/versionretention/test", &rvr); err != nil {
+		t.Fatal(err)
+	} else if rvr.MaxVersions != 2 || rvr.MaxAge != 3600 {
+		t.Fatalf("expected the retention of the root directory, got %+v", rvr)
+	}
+
+	// Deleting the file keeps it as a previous version, and purging it
+	// removes all of its versions.
+	if err := st.stdPostAPI("/renter/delete/test", url.Values{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/renter/versions/test", &rv); err != nil {
+		t.Fatal(err)
+	} else if len(rv.Versions) != 2 || rv.Versions[1].Version != 2 || rv.Versions[1].Current {
+		t.Fatalf("expected two previous versions, got %+v", rv.Versions)
+	}
+	if err := st.stdPostAPI("/renter/delete/test", url.Values{"purge": {"true"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.stdGetAPI("/renter/versions/test"); err == nil {
+		t.Fatal("expected the versions of a purged file to be removed")
+	}
+	if err := st.stdPostAPI("/renter/versionretention/", url.Values{"clear": {"true"}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRenterDir probes the GET and POST calls to /renter/dir/*siapath.
+func TestRenterDir(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	createValues := url.Values{}
+	createValues.Set("action", "create")
+	if err := st.stdPostAPI("/renter/dir/foo/bar", createValues); err != nil {
+		t.Fatal(err)
+	}
+	var rd RenterDirectory
+	if err := st.getAPI("/renter/dir/", &rd); err != nil {
+		t.Fatal(err)
+	}
+	if len(rd.Directories) != 1 || rd.Directories[0].SiaPath != "foo" || len(rd.Files) != 0 {
+		t.Fatal("wrong listing of the root directory:", rd)
+	}
+
+	renameValues := url.Values{}
+	renameValues.Set("action", "rename")
+	renameValues.Set("newsiapath", "baz")
+	if err := st.stdPostAPI("/renter/dir/foo", renameValues); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/renter/dir/baz", &rd); err != nil {
+		t.Fatal(err)
+	}
+	if len(rd.Directories) != 1 || rd.Directories[0].SiaPath != "baz/bar" {
+		t.Fatal("wrong listing of a renamed directory:", rd)
+	}
+
+	deleteValues := url.Values{}
+	deleteValues.Set("action", "delete")
+	if err := st.stdPostAPI("/renter/dir/baz", deleteValues); err == nil {
+		t.Fatal("expected a directory that is not empty to be kept")
+	}
+	deleteValues.Set("recursive", "true")
+	if err := st.stdPostAPI("/renter/dir/baz", deleteValues); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/renter/dir/baz", &rd); err == nil {
+		t.Fatal("expected a deleted directory to be gone")
+	}
+	if err := st.stdPostAPI("/renter/dir/baz", url.Values{}); err == nil {
+		t.Fatal("expected an error for a missing action")
+	}
+}
+
+// TestRenterBackup backs up a directory tree through /renter/backup, and
+// checks that /renter/restore restores the files of the backup's manifest.
+func TestRenterBackup(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, _ := setupTestDownload(t, int(modules.SectorSize), "test", true)
+	defer st.server.panicClose()
+
+	source := filepath.Join(st.dir, "backupsource")
+	if err := os.MkdirAll(filepath.Join(source, "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"a", filepath.Join("sub", "b")}
+	for _, name := range names {
+		if err := createRandFile(filepath.Join(source, name), 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.stdPostAPI("/renter/backup/backup", url.Values{"source": {"backupsource"}}); err == nil {
+		t.Fatal("expected a backup of a relative path to fail")
+	}
+	var rb RenterBackupPOST
+	if err := st.postAPI("/renter/backup/backup", url.Values{"source": {source}}, &rb); err != nil {
+		t.Fatal(err)
+	}
+	if rb.Error != "" || len(rb.Manifest.Files) != 2 || rb.Manifest.Files[1].Path != "sub/b" {
+		t.Fatalf("wrong backup: %+v", rb)
+	}
+
+	// Wait for the backed up files and the manifest to become available.
+	err := retry(100, 200*time.Millisecond, func() error {
+		var rf RenterFiles
+		if err := st.getAPI("/renter/files", &rf); err != nil {
+			return err
+		}
+		for _, f := range rf.Files {
+			if !f.Available {
+				return fmt.Errorf("%v is not available", f.SiaPath)
+			}
+		}
+		if len(rf.Files) != 4 {
+			return fmt.Errorf("expected 4 files, got %v", len(rf.Files))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := json.Marshal(rb.Manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := filepath.Join(st.dir, "restored")
+	restoreValues := url.Values{}
+	restoreValues.Set("destination", restored)
+	restoreValues.Set("manifest", string(manifest))
+	if err := st.stdPostAPI("/renter/restore", restoreValues); err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range names {
+		orig, err := ioutil.ReadFile(filepath.Join(source, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		restoredData, err := ioutil.ReadFile(filepath.Join(restored, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(orig, restoredData) {
+			t.Fatal("restored file does not match the original:", name)
+		}
+		info, err := os.Stat(filepath.Join(restored, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(rb.Manifest.Files[i].ModTime) {
+			t.Fatal("modification time of the restored file was not restored:", name)
+		}
+	}
+}
@@ -331,6 +331,15 @@ func TestRemoteFileRepair(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// downloads of the user are not counted as repair spending
+	var rg RenterGET
+	if err = st.getAPI("/renter", &rg); err != nil {
+		t.Fatal(err)
+	}
+	if rg.FinancialMetrics.DownloadSpending.IsZero() || !rg.FinancialMetrics.RepairDownloadSpending.IsZero() {
+		t.Fatalf("unexpected download spending: %+v", rg.FinancialMetrics)
+	}
+
 	// remove the local copy of the file
 	err = build.Retry(50, time.Millisecond*200, func() error {
 		return os.Remove(path)
@@ -454,6 +463,14 @@ func TestRemoteFileRepair(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// the data of the repair was downloaded from the surviving host
+	if err = st.getAPI("/renter", &rg); err != nil {
+		t.Fatal(err)
+	}
+	if rg.FinancialMetrics.RepairDownloadSpending.IsZero() {
+		t.Fatal("repair download spending was not recorded")
+	}
+
 	// we have to wait a bit for the download loop to update with the new
 	// contracts. retry the download for up to 90 seconds.
 	downloadPath = filepath.Join(st.dir, "test-downloaded.dat")
@@ -1488,6 +1505,176 @@ func TestRenterRenew(t *testing.T) {
 	}
 }
 
+// TestRenterWalletLocked checks that the renter suspends contract renewal
+// while the wallet is locked, raising a single alert and keeping downloads
+// working, and that the missed renewal is performed as soon as the wallet is
+// unlocked.
+func TestRenterWalletLocked(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	// Announce the host and start accepting contracts.
+	if err := st.announceHost(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.acceptContracts(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.setHostStorage(); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		var ah HostdbActiveGET
+		if err := st.getAPI("/hostdb/active", &ah); err != nil {
+			return err
+		}
+		if len(ah.Hosts) != 1 {
+			return fmt.Errorf("expected 1 host, got %v", len(ah.Hosts))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Set an allowance and upload a file.
+	testPeriod := 10
+	allowanceValues := url.Values{}
+	allowanceValues.Set("funds", "10000000000000000000000000000") // 10k SC
+	allowanceValues.Set("period", strconv.Itoa(testPeriod))
+	if err := st.stdPostAPI("/renter", allowanceValues); err != nil {
+		t.Fatal(err)
+	}
+	var rc RenterContracts
+	err = build.Retry(50, 250*time.Millisecond, func() error {
+		if err := st.getAPI("/renter/contracts", &rc); err != nil {
+			return err
+		}
+		if len(rc.Contracts) != 1 {
+			return errors.New("no contracts")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contractID := rc.Contracts[0].ID
+	path := filepath.Join(st.dir, "test.dat")
+	if err := createRandFile(path, 1024); err != nil {
+		t.Fatal(err)
+	}
+	uploadValues := url.Values{}
+	uploadValues.Set("source", path)
+	if err := st.stdPostAPI("/renter/upload/test", uploadValues); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(200, 100*time.Millisecond, func() error {
+		var rf RenterFiles
+		if err := st.getAPI("/renter/files", &rf); err != nil {
+			return err
+		}
+		if len(rf.Files) != 1 || !rf.Files[0].Available {
+			return errors.New("file is not available")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Confirm the contract and lock the wallet.
+	if _, err := st.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.stdPostAPI("/wallet/lock", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Downloads do not need the wallet.
+	downpath := filepath.Join(st.dir, "testdown.dat")
+	if err := st.stdGetAPI("/renter/download/test?destination=" + downpath); err != nil {
+		t.Fatal(err)
+	}
+	orig, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	download, err := ioutil.ReadFile(downpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(orig, download) {
+		t.Fatal("data mismatch when downloading a file")
+	}
+
+	// Mine into the renewal window. The miner needs an unlocked wallet, so
+	// the blocks are solved directly.
+	for i := 0; i < testPeriod/2; i++ {
+		parentID := st.cs.CurrentBlock().ID()
+		target, _ := st.cs.ChildTarget(parentID)
+		b, _ := st.miner.SolveBlock(types.Block{
+			ParentID:     parentID,
+			Timestamp:    types.CurrentTimestamp(),
+			MinerPayouts: []types.SiacoinOutput{{Value: types.CalculateCoinbase(st.cs.Height() + 1)}},
+		}, target)
+		if err := st.cs.AcceptBlock(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A single alert should be raised, and the contract should not be renewed.
+	var ra RenterAlertsGET
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		if err := st.getAPI("/renter/alerts", &ra); err != nil {
+			return err
+		}
+		if len(ra.Alerts) != 1 || ra.Alerts[0].Category != modules.RenterAlertCategoryWalletLocked {
+			return fmt.Errorf("expected a wallet locked alert, got %v", ra.Alerts)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+	if err := st.getAPI("/renter/contracts", &rc); err != nil {
+		t.Fatal(err)
+	}
+	if len(rc.Contracts) != 1 || rc.Contracts[0].ID != contractID {
+		t.Fatal("contract was renewed while the wallet was locked:", rc.Contracts)
+	}
+
+	// Unlocking the wallet should clear the alert and renew the contract
+	// without waiting for another block.
+	if err := st.wallet.Unlock(st.walletKey); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if err := st.getAPI("/renter/contracts", &rc); err != nil {
+			return err
+		}
+		if len(rc.Contracts) != 1 || rc.Contracts[0].ID == contractID {
+			return errors.New("contract was not renewed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/renter/alerts", &ra); err != nil {
+		t.Fatal(err)
+	}
+	if len(ra.Alerts) != 0 {
+		t.Fatal("alert was not cleared after unlocking the wallet:", ra.Alerts)
+	}
+}
+
 // TestRenterAllowance sets up an integration test where a renter attempts to
 // download a file after changing the allowance.
 func TestRenterAllowance(t *testing.T) {
@@ -2666,3 +2853,144 @@ func TestRemoteFileRepairMassive(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestHostWindDown checks that a host that is winding down refuses to renew
+// its contracts, and that the renter stops using the contracts so that their
+// data is migrated.
+func TestHostWindDown(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	// Announce the host and start accepting contracts.
+	if err := st.announceHost(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.acceptContracts(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.setHostStorage(); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		var ah HostdbActiveGET
+		if err := st.getAPI("/hostdb/active", &ah); err != nil {
+			return err
+		}
+		if len(ah.Hosts) != 1 {
+			return fmt.Errorf("expected 1 host, got %v", len(ah.Hosts))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Set an allowance and upload a file.
+	testPeriod := 10
+	allowanceValues := url.Values{}
+	allowanceValues.Set("funds", "10000000000000000000000000000") // 10k SC
+	allowanceValues.Set("period", strconv.Itoa(testPeriod))
+	if err := st.stdPostAPI("/renter", allowanceValues); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(st.dir, "test.dat")
+	if err := createRandFile(path, 1024); err != nil {
+		t.Fatal(err)
+	}
+	uploadValues := url.Values{}
+	uploadValues.Set("source", path)
+	err = build.Retry(50, 250*time.Millisecond, func() error {
+		return st.stdPostAPI("/renter/upload/test", uploadValues)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(200, 100*time.Millisecond, func() error {
+		var rf RenterFiles
+		if err := st.getAPI("/renter/files", &rf); err != nil {
+			return err
+		}
+		if len(rf.Files) != 1 || !rf.Files[0].Available {
+			return errors.New("file is not available")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contractID := st.renter.Contracts()[0].ID
+	if _, err := st.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Wind down the host.
+	windDownValues := url.Values{}
+	windDownValues.Set("enabled", "true")
+	if err := st.stdPostAPI("/host/winddown", windDownValues); err != nil {
+		t.Fatal(err)
+	}
+	var hg HostGET
+	if err := st.getAPI("/host", &hg); err != nil {
+		t.Fatal(err)
+	}
+	if hg.InternalSettings.AcceptingContracts || !hg.WindDown.Active || hg.WindDown.RemainingObligations != 1 || hg.WindDown.FinalHeight == 0 {
+		t.Fatalf("wrong host status after winding down: %v %+v", hg.InternalSettings.AcceptingContracts, hg.WindDown)
+	}
+	acceptValues := url.Values{}
+	acceptValues.Set("acceptingcontracts", "true")
+	if err := st.stdPostAPI("/host", acceptValues); err == nil {
+		t.Fatal("host accepted contracts while winding down")
+	}
+	var hwd HostWindDownGET
+	if err := st.getAPI("/host/winddown", &hwd); err != nil {
+		t.Fatal(err)
+	}
+	if len(hwd.StorageFolders) != 1 || hwd.StorageFolders[0].Sectors != 1 || hwd.StorageFolders[0].FreeHeight != hg.WindDown.FinalHeight {
+		t.Fatalf("wrong storage folder schedule: %+v", hwd.StorageFolders)
+	}
+	if err := st.stdPostAPI("/host/winddown/compact", nil); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if err := st.getAPI("/host/winddown", &hwd); err != nil {
+			return err
+		}
+		if hwd.Compaction.Active {
+			return errors.New("compaction is still running")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hwd.Compaction.Removed) != 0 || hwd.Compaction.Error != "" {
+		t.Fatalf("the only storage folder was removed: %+v", hwd.Compaction)
+	}
+
+	// Mine into the renewal window. The renewal should be refused, and the
+	// renter should stop using the contract.
+	for i := 0; i < testPeriod/2; i++ {
+		if _, err := st.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		contracts := st.renter.Contracts()
+		if len(contracts) != 1 || contracts[0].ID != contractID {
+			return fmt.Errorf("expected the original contract, got %v", contracts)
+		}
+		if contracts[0].GoodForUpload || contracts[0].GoodForRenew {
+			return errors.New("contract with the winding down host is still in use")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
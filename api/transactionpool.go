@@ -18,6 +18,12 @@ type (
 		Maximum types.Currency `json:"maximum"`
 	}
 
+	// TpoolFeeHistogramGET contains the number of transactions in the
+	// transaction pool by the fee per byte of their transaction sets.
+	TpoolFeeHistogramGET struct {
+		Buckets []modules.FeeHistogramBucket `json:"buckets"`
+	}
+
 	// TpoolRawGET contains the requested transaction encoded to the raw
 	// format, along with the id of that transaction.
 	TpoolRawGET struct {
@@ -47,6 +53,14 @@ func (api *API) tpoolFeeHandlerGET(w http.ResponseWriter, req *http.Request, ps
 	})
 }
 
+// tpoolFeeHistogramHandlerGET returns the distribution of the fees paid by
+// the transactions in the transaction pool.
+func (api *API) tpoolFeeHistogramHandlerGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	WriteJSON(w, TpoolFeeHistogramGET{
+		Buckets: api.tpool.TransactionFeeHistogram(),
+	})
+}
+
 // tpoolRawHandlerGET will provide the raw byte representation of a
 // transaction that matches the input id.
 func (api *API) tpoolRawHandlerGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
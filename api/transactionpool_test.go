@@ -199,3 +199,38 @@ func TestTransactionPoolFee(t *testing.T) {
 		t.Fatal("fee mismatch")
 	}
 }
+
+// TestTransactionPoolFeeHistogram tests the /tpool/feehistogram endpoint.
+func TestTransactionPoolFeeHistogram(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	sendValues := url.Values{}
+	sendValues.Set("amount", types.SiacoinPrecision.String())
+	sendValues.Set("destination", types.UnlockHash{}.String())
+	if err := st.stdPostAPI("/wallet/siacoins", sendValues); err != nil {
+		t.Fatal(err)
+	}
+
+	var tfh TpoolFeeHistogramGET
+	if err := st.getAPI("/tpool/feehistogram", &tfh); err != nil {
+		t.Fatal(err)
+	}
+	if len(tfh.Buckets) == 0 {
+		t.Fatal("fee histogram has no buckets")
+	}
+	var count uint64
+	for _, b := range tfh.Buckets {
+		count += b.TxCount
+	}
+	if count != uint64(len(st.tpool.TransactionList())) {
+		t.Fatalf("histogram counts %v transactions, the pool has %v", count, len(st.tpool.TransactionList()))
+	}
+}
@@ -1,11 +1,14 @@
 package api
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
@@ -29,6 +32,11 @@ type (
 		SiafundBalance      types.Currency `json:"siafundbalance"`
 		SiacoinClaimBalance types.Currency `json:"siacoinclaimbalance"`
 
+		TimelockedSiacoinBalance types.Currency `json:"timelockedsiacoinbalance"`
+
+		WatchedSiacoinBalance types.Currency `json:"watchedsiacoinbalance"`
+		WatchedSiafundBalance types.Currency `json:"watchedsiafundbalance"`
+
 		DustThreshold types.Currency `json:"dustthreshold"`
 	}
 
@@ -44,6 +52,12 @@ type (
 		Addresses []types.UnlockHash `json:"addresses"`
 	}
 
+	// WalletAddressesGeneratePOST contains the addresses returned by a POST
+	// call to /wallet/addresses/generate.
+	WalletAddressesGeneratePOST struct {
+		Addresses []modules.GeneratedAddress `json:"addresses"`
+	}
+
 	// WalletInitPOST contains the primary seed that gets generated during a
 	// POST call to /wallet/init.
 	WalletInitPOST struct {
@@ -69,6 +83,12 @@ type (
 		AllSeeds           []string `json:"allseeds"`
 	}
 
+	// WalletSpendingGET contains the spending records returned by a GET call
+	// to /wallet/spending.
+	WalletSpendingGET struct {
+		Records []modules.SpendingRecord `json:"records"`
+	}
+
 	// WalletSweepPOST contains the coins and funds returned by a call to
 	// /wallet/sweep.
 	WalletSweepPOST struct {
@@ -76,10 +96,20 @@ type (
 		Funds types.Currency `json:"funds"`
 	}
 
+	// WalletTimelockPOST contains the transaction sent in the POST call to
+	// /wallet/timelock.
+	WalletTimelockPOST struct {
+		TransactionID types.TransactionID `json:"transactionid"`
+	}
+
 	// WalletTransactionGETid contains the transaction returned by a call to
 	// /wallet/transaction/:id
 	WalletTransactionGETid struct {
 		Transaction modules.ProcessedTransaction `json:"transaction"`
+
+		// InclusionProof is only set for confirmed transactions, and only if
+		// the consensus set indexes its transactions.
+		InclusionProof *modules.TransactionInclusionProof `json:"inclusionproof,omitempty"`
 	}
 
 	// WalletTransactionsGET contains the specified set of confirmed and
@@ -97,6 +127,12 @@ type (
 		UnconfirmedTransactions []modules.ProcessedTransaction `json:"unconfirmedtransactions"`
 	}
 
+	// WalletWatchGET contains the addresses watched by the wallet, returned
+	// by a GET call to /wallet/watch.
+	WalletWatchGET struct {
+		Addresses []types.UnlockHash `json:"addresses"`
+	}
+
 	// WalletVerifyAddressGET contains a bool indicating if the address passed to
 	// /wallet/verify/address/:addr is a valid address.
 	WalletVerifyAddressGET struct {
@@ -121,8 +157,9 @@ func encryptionKeys(seedStr string) (validKeys []crypto.TwofishKey) {
 
 // walletHander handles API calls to /wallet.
 func (api *API) walletHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	siacoinBal, siafundBal, siaclaimBal := api.wallet.ConfirmedBalance()
+	siacoinBal, siafundBal, siaclaimBal, timelockedBal := api.wallet.ConfirmedBalance()
 	siacoinsOut, siacoinsIn := api.wallet.UnconfirmedBalance()
+	watchedSiacoinBal, watchedSiafundBal := api.wallet.WatchedBalance()
 	dustThreshold := api.wallet.DustThreshold()
 	WriteJSON(w, WalletGET{
 		Encrypted:  api.wallet.Encrypted(),
@@ -136,6 +173,11 @@ func (api *API) walletHandler(w http.ResponseWriter, req *http.Request, _ httpro
 		SiafundBalance:      siafundBal,
 		SiacoinClaimBalance: siaclaimBal,
 
+		TimelockedSiacoinBalance: timelockedBal,
+
+		WatchedSiacoinBalance: watchedSiacoinBal,
+		WatchedSiafundBalance: watchedSiafundBal,
+
 		DustThreshold: dustThreshold,
 	})
 }
@@ -182,6 +224,24 @@ func (api *API) walletAddressesHandler(w http.ResponseWriter, req *http.Request,
 	})
 }
 
+// walletAddressesGenerateHandler handles API calls to
+// /wallet/addresses/generate.
+func (api *API) walletAddressesGenerateHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	count, err := strconv.ParseUint(req.FormValue("count"), 10, 64)
+	if err != nil || count == 0 {
+		WriteError(w, Error{"error when calling /wallet/addresses/generate: count must be a positive integer"}, http.StatusBadRequest)
+		return
+	}
+	addrs, err := api.wallet.GenerateAddressExport(count)
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/addresses/generate: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletAddressesGeneratePOST{
+		Addresses: addrs,
+	})
+}
+
 // walletBackupHandler handles API calls to /wallet/backup.
 func (api *API) walletBackupHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	destination := req.FormValue("destination")
@@ -449,6 +509,84 @@ func (api *API) walletSiafundsHandler(w http.ResponseWriter, req *http.Request,
 	})
 }
 
+// walletTimelockHandler handles API calls to /wallet/timelock.
+func (api *API) walletTimelockHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	amount, ok := scanAmount(req.FormValue("amount"))
+	if !ok {
+		WriteError(w, Error{"could not read 'amount' from POST call to /wallet/timelock"}, http.StatusBadRequest)
+		return
+	}
+	var unlockHeight types.BlockHeight
+	if _, err := fmt.Sscan(req.FormValue("unlockheight"), &unlockHeight); err != nil {
+		WriteError(w, Error{"could not read 'unlockheight' from POST call to /wallet/timelock"}, http.StatusBadRequest)
+		return
+	}
+
+	txn, err := api.wallet.TimelockFunds(amount, unlockHeight)
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/timelock: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, WalletTimelockPOST{
+		TransactionID: txn.ID(),
+	})
+}
+
+// walletSpendingHandler handles API calls to /wallet/spending.
+func (api *API) walletSpendingHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var start, end time.Time
+	for _, param := range []struct {
+		name string
+		t    *time.Time
+	}{{"start", &start}, {"end", &end}} {
+		str := req.FormValue(param.name)
+		if str == "" {
+			continue
+		}
+		unix, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			WriteError(w, Error{"parsing integer value for parameter `" + param.name + "` failed: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		*param.t = time.Unix(unix, 0)
+	}
+	format := req.FormValue("format")
+	if format != "" && format != "json" && format != "csv" {
+		WriteError(w, Error{"error when calling /wallet/spending: format must be 'json' or 'csv'"}, http.StatusBadRequest)
+		return
+	}
+	records, err := api.wallet.SpendingLog(start, end, req.FormValue("module"), modules.SpendingPurpose(req.FormValue("purpose")))
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/spending: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if format != "csv" {
+		WriteJSON(w, WalletSpendingGET{
+			Records: records,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"timestamp", "module", "purpose", "counterparty", "relatedid", "amount", "fee", "transactionid", "status", "confirmationheight"})
+	for _, r := range records {
+		cw.Write([]string{
+			strconv.FormatInt(r.Timestamp.Unix(), 10),
+			r.Module,
+			string(r.Purpose),
+			r.Counterparty,
+			r.RelatedID.String(),
+			r.Amount.String(),
+			r.Fee.String(),
+			r.TransactionID.String(),
+			string(r.Status),
+			strconv.FormatUint(uint64(r.ConfirmationHeight), 10),
+		})
+	}
+	cw.Flush()
+}
+
 // walletSweepSeedHandler handles API calls to /wallet/sweep/seed.
 func (api *API) walletSweepSeedHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	// Get the seed using the ditionary + phrase
@@ -490,7 +628,8 @@ func (api *API) walletTransactionHandler(w http.ResponseWriter, req *http.Reques
 		return
 	}
 	WriteJSON(w, WalletTransactionGETid{
-		Transaction: txn,
+		Transaction:    txn,
+		InclusionProof: api.inclusionProof(id),
 	})
 }
 
@@ -594,3 +733,24 @@ func (api *API) walletVerifyAddressHandler(w http.ResponseWriter, req *http.Requ
 	err := new(types.UnlockHash).LoadString(addrString)
 	WriteJSON(w, WalletVerifyAddressGET{Valid: err == nil})
 }
+
+// walletWatchHandlerGET handles GET calls to /wallet/watch.
+func (api *API) walletWatchHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, WalletWatchGET{
+		Addresses: api.wallet.WatchedAddresses(),
+	})
+}
+
+// walletWatchHandlerPOST handles POST calls to /wallet/watch.
+func (api *API) walletWatchHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	addr, err := scanAddress(req.FormValue("address"))
+	if err != nil {
+		WriteError(w, Error{"could not read 'address' from POST call to /wallet/watch"}, http.StatusBadRequest)
+		return
+	}
+	if err := api.wallet.WatchAddress(addr); err != nil {
+		WriteError(w, Error{"error when calling /wallet/watch: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
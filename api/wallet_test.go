@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -515,8 +516,8 @@ func TestIntegrationWalletLoadSeedPOST(t *testing.T) {
 	}
 
 	// Record starting balances.
-	oldBal, _, _ := st.wallet.ConfirmedBalance()
-	w2bal, _, _ := w2.ConfirmedBalance()
+	oldBal, _, _, _ := st.wallet.ConfirmedBalance()
+	w2bal, _, _, _ := w2.ConfirmedBalance()
 	if w2bal.IsZero() {
 		t.Fatal("second wallet's balance should not be zero")
 	}
@@ -532,7 +533,7 @@ func TestIntegrationWalletLoadSeedPOST(t *testing.T) {
 		t.Fatal(err)
 	}
 	// First wallet should now have balance of both wallets
-	bal, _, _ := st.wallet.ConfirmedBalance()
+	bal, _, _, _ := st.wallet.ConfirmedBalance()
 	if exp := oldBal.Add(w2bal); !bal.Equals(exp) {
 		t.Fatalf("wallet did not load seed correctly: expected %v coins, got %v", exp, bal)
 	}
@@ -1221,6 +1222,52 @@ func TestWalletVerifyAddress(t *testing.T) {
 	}
 }
 
+// TestWalletAddressesGenerate checks that the /wallet/addresses/generate
+// endpoint returns consecutive new addresses that are tracked by the wallet.
+func TestWalletAddressesGenerate(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	for _, count := range []string{"", "0", "-1", "foo"} {
+		err = st.stdPostAPI("/wallet/addresses/generate", url.Values{"count": {count}})
+		if err == nil {
+			t.Errorf("expected an error for count %q", count)
+		}
+	}
+
+	var wagp WalletAddressesGeneratePOST
+	err = st.postAPI("/wallet/addresses/generate", url.Values{"count": {"10"}}, &wagp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wagp.Addresses) != 10 {
+		t.Fatal("expected 10 addresses, got", len(wagp.Addresses))
+	}
+	var wag WalletAddressesGET
+	if err = st.getAPI("/wallet/addresses", &wag); err != nil {
+		t.Fatal(err)
+	}
+	known := make(map[types.UnlockHash]struct{})
+	for _, addr := range wag.Addresses {
+		known[addr] = struct{}{}
+	}
+	for i, addr := range wagp.Addresses {
+		if addr.Index != wagp.Addresses[0].Index+uint64(i) {
+			t.Error("addresses do not have consecutive indices")
+		}
+		if _, exists := known[addr.Address]; !exists {
+			t.Error("generated address is not tracked by the wallet")
+		}
+	}
+}
+
 // TestWalletChangePassword verifies that the /wallet/changepassword endpoint
 // works correctly and changes a wallet password.
 func TestWalletChangePassword(t *testing.T) {
@@ -1474,3 +1521,54 @@ func TestWalletGETDust(t *testing.T) {
 		t.Fatal("dustThreshold mismatch")
 	}
 }
+
+// TestWalletSpending probes the GET call to /wallet/spending.
+func TestWalletSpending(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	sendValues := url.Values{}
+	sendValues.Set("amount", types.SiacoinPrecision.String())
+	sendValues.Set("destination", types.UnlockHash{1}.String())
+	if err := st.stdPostAPI("/wallet/siacoins", sendValues); err != nil {
+		t.Fatal(err)
+	}
+
+	var wsg WalletSpendingGET
+	if err := st.getAPI("/wallet/spending?module=wallet&purpose=send", &wsg); err != nil {
+		t.Fatal(err)
+	}
+	if len(wsg.Records) != 1 || wsg.Records[0].Counterparty != (types.UnlockHash{1}).String() {
+		t.Fatal("wrong spending records:", wsg.Records)
+	}
+	amount := wsg.Records[0].Amount
+	if err := st.getAPI("/wallet/spending?module=host", &wsg); err != nil {
+		t.Fatal(err)
+	}
+	if len(wsg.Records) != 0 {
+		t.Fatal("expected no spending records of the host, got", len(wsg.Records))
+	}
+	if err := st.stdGetAPI("/wallet/spending?format=xml"); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+
+	resp, err := HttpGET("http://" + st.server.listener.Addr().String() + "/wallet/spending?purpose=send&format=csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	rows, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0][0] != "timestamp" || rows[1][1] != modules.WalletDir || rows[1][5] != amount.String() {
+		t.Fatal("wrong CSV spending log:", rows)
+	}
+}
@@ -39,6 +39,13 @@ var (
 		Run:   wrap(gatewaydisconnectcmd),
 	}
 
+	gatewayLatencyCmd = &cobra.Command{
+		Use:   "latency",
+		Short: "View the latency of the peers",
+		Long:  "Ping every connected peer and list them from fastest to slowest.",
+		Run:   wrap(gatewaylatencycmd),
+	}
+
 	gatewayListCmd = &cobra.Command{
 		Use:   "list",
 		Short: "View a list of peers",
@@ -88,6 +95,9 @@ func gatewaycmd() {
 	}
 	fmt.Println("Address:", info.NetAddress)
 	fmt.Println("Active peers:", len(info.Peers))
+	if !info.OnlineSince.IsZero() {
+		fmt.Println("Online since:", info.OnlineSince.Format("Jan 02 03:04 PM"))
+	}
 }
 
 // gatewaylistcmd is the handler for the command `siac gateway list`.
@@ -110,3 +120,27 @@ func gatewaylistcmd() {
 	}
 	w.Flush()
 }
+
+// gatewaylatencycmd is the handler for the command `siac gateway latency`.
+// Prints the round trip times of the connected peers.
+func gatewaylatencycmd() {
+	var gl api.GatewayLatencyGET
+	err := getAPI("/gateway/latency", &gl)
+	if err != nil {
+		die("Could not ping peers:", err)
+	}
+	if len(gl.Peers) == 0 {
+		fmt.Println("No peers to show.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Address	Latency")
+	for _, p := range gl.Peers {
+		latency := p.Latency.String()
+		if p.Error != "" {
+			latency = "ping failed: " + p.Error
+		}
+		fmt.Fprintf(w, "%v\t%v\n", p.NetAddress, latency)
+	}
+	w.Flush()
+}
@@ -46,12 +46,20 @@ Available settings:
      maxduration:          blocks
      maxdownloadbatchsize: bytes
      maxrevisebatchsize:   bytes
+     maxsessionmemory:     bytes
      netaddress:           string
+     tlscertfile:          string
+     tlskeyfile:           string
+     tlsrequired:          boolean
      windowsize:           blocks
 
-     collateral:       currency
-     collateralbudget: currency
-     maxcollateral:    currency
+     obligationretentionblocks: blocks
+     prooftestsamplesize:       number
+
+     collateral:            currency
+     collateralbudget:      currency
+     maxcollateral:         currency
+     maxcollateralfraction: number
 
      mincontractprice:          currency
      mindownloadbandwidthprice: currency / TB
@@ -60,9 +68,21 @@ Available settings:
 
 Currency units can be specified, e.g. 10SC; run 'siac help wallet' for details.
 
-Durations (maxduration and windowsize) must be specified in either blocks (b),
-hours (h), days (d), or weeks (w). A block is approximately 10 minutes, so one
-hour is six blocks, a day is 144 blocks, and a week is 1008 blocks.
+Durations (maxduration, windowsize, and obligationretentionblocks) must be
+specified in either blocks (b), hours (h), days (d), or weeks (w). A block is
+approximately 10 minutes, so one hour is six blocks, a day is 144 blocks, and a
+week is 1008 blocks. An obligationretentionblocks of 0 keeps resolved storage
+obligations forever.
+
+The host tests the storage proofs of prooftestsamplesize random obligations
+every week, raising an alert for every proof that fails. A prooftestsamplesize
+of 0 disables the weekly proof test.
+
+Setting tlscertfile and tlskeyfile enables TLS for renters that have pinned the
+host's certificate, and for renters that upgrade their sessions to TLS. A
+self-signed certificate is generated if neither file exists. Both must be set,
+and cleared with an empty value, together. Setting tlsrequired refuses every
+RPC other than the settings RPC on connections that are not encrypted.
 
 For a description of each parameter, see doc/API.md.
 
@@ -148,14 +168,8 @@ func hostcmd() {
 	// convert price from bytes/block to TB/Month
 	price := currencyUnits(is.MinStoragePrice.Mul(modules.BlockBytesPerMonthTerabyte))
 	// calculate total revenue
-	totalRevenue := fm.ContractCompensation.
-		Add(fm.StorageRevenue).
-		Add(fm.DownloadBandwidthRevenue).
-		Add(fm.UploadBandwidthRevenue)
-	totalPotentialRevenue := fm.PotentialContractCompensation.
-		Add(fm.PotentialStorageRevenue).
-		Add(fm.PotentialDownloadBandwidthRevenue).
-		Add(fm.PotentialUploadBandwidthRevenue)
+	totalRevenue := fm.TotalRevenue()
+	totalPotentialRevenue := fm.PotentialTotalRevenue()
 	// determine the display method for the net address.
 	netaddr := es.NetAddress
 	if is.NetAddress == "" {
@@ -175,6 +189,11 @@ func hostcmd() {
 		connectabilityString = "Host is not connectable (re-checks every few minutes)."
 	}
 
+	retention := "Forever"
+	if is.ObligationRetentionBlocks != 0 {
+		retention = fmt.Sprintf("%v Blocks", is.ObligationRetentionBlocks)
+	}
+
 	if hostVerbose {
 		// describe net address
 		fmt.Printf(`General Info:
@@ -185,12 +204,20 @@ Host Internal Settings:
 	maxduration:          %v Weeks
 	maxdownloadbatchsize: %v
 	maxrevisebatchsize:   %v
+	maxsessionmemory:     %v
 	netaddress:           %v
+	tlscertfile:          %v
+	tlskeyfile:           %v
+	tlsrequired:          %v
 	windowsize:           %v Hours
 
-	collateral:       %v / TB / Month
-	collateralbudget: %v
-	maxcollateral:    %v Per Contract
+	obligationretentionblocks: %v
+	prooftestsamplesize:       %v
+
+	collateral:            %v / TB / Month
+	collateralbudget:      %v
+	maxcollateral:         %v Per Contract
+	maxcollateralfraction: %v
 
 	mincontractprice:          %v
 	mindownloadbandwidthprice: %v / TB
@@ -215,6 +242,9 @@ Host Financials:
 	Upload Revenue:             %v
 	Potential Upload Revenue:   %v
 
+	Pruned Obligations:        %v (%v failed)
+	Pruned Before Block:       %v
+
 RPC Stats:
 	Error Calls:        %v
 	Unrecognized Calls: %v
@@ -223,17 +253,26 @@ RPC Stats:
 	Revise Calls:       %v
 	Settings Calls:     %v
 	FormContract Calls: %v
+
+Session Memory:
+	Used:       %v
+	High Water: %v
+	Limit:      %v
 `,
 			connectabilityString,
 
 			yesNo(is.AcceptingContracts), periodUnits(is.MaxDuration),
 			filesizeUnits(int64(is.MaxDownloadBatchSize)),
-			filesizeUnits(int64(is.MaxReviseBatchSize)), netaddr,
-			is.WindowSize/6,
+			filesizeUnits(int64(is.MaxReviseBatchSize)),
+			filesizeUnits(int64(is.MaxSessionMemory)), netaddr,
+			is.TLSCertFile, is.TLSKeyFile, yesNo(is.TLSRequired), is.WindowSize/6,
+
+			retention, is.ProofTestSampleSize,
 
 			currencyUnits(is.Collateral.Mul(modules.BlockBytesPerMonthTerabyte)),
 			currencyUnits(is.CollateralBudget),
 			currencyUnits(is.MaxCollateral),
+			is.MaxCollateralFraction,
 
 			currencyUnits(is.MinContractPrice),
 			currencyUnits(is.MinDownloadBandwidthPrice.Mul(modules.BytesPerTerabyte)),
@@ -256,9 +295,16 @@ RPC Stats:
 			currencyUnits(fm.UploadBandwidthRevenue),
 			currencyUnits(fm.PotentialUploadBandwidthRevenue),
 
+			fm.PrunedObligations, fm.PrunedFailedObligations,
+			fm.PrunedBeforeHeight,
+
 			nm.ErrorCalls, nm.UnrecognizedCalls, nm.DownloadCalls,
 			nm.RenewCalls, nm.ReviseCalls, nm.SettingsCalls,
-			nm.FormContractCalls)
+			nm.FormContractCalls,
+
+			filesizeUnits(int64(hg.SessionMemory.Used)),
+			filesizeUnits(int64(hg.SessionMemory.HighWater)),
+			filesizeUnits(int64(hg.SessionMemory.Limit)))
 	} else {
 		fmt.Printf(`Host info:
 	Connectability Status: %v
@@ -336,7 +382,7 @@ func hostconfigcmd(param, value string) {
 		value = c.String()
 
 	// bool (allow "yes" and "no")
-	case "acceptingcontracts":
+	case "acceptingcontracts", "tlsrequired":
 		switch strings.ToLower(value) {
 		case "yes":
 			value = "true"
@@ -345,14 +391,14 @@ func hostconfigcmd(param, value string) {
 		}
 
 	// duration (convert to blocks)
-	case "maxduration", "windowsize":
+	case "maxduration", "obligationretentionblocks", "windowsize":
 		value, err = parsePeriod(value)
 		if err != nil {
 			die("Could not parse "+param+":", err)
 		}
 
 	// other valid settings
-	case "maxdownloadbatchsize", "maxrevisebatchsize", "netaddress":
+	case "maxcollateralfraction", "maxdownloadbatchsize", "maxrevisebatchsize", "maxsessionmemory", "netaddress", "prooftestsamplesize", "tlscertfile", "tlskeyfile":
 
 	// invalid settings
 	default:
@@ -327,5 +327,12 @@ func hostdbviewcmd(pubkey string) {
 	fmt.Println("\n  Scan History Length:", len(info.Entry.ScanHistory))
 	fmt.Printf("  Overall Uptime:      %.3f\n", uptimeRatio)
 
+	cb := info.CircuitBreaker
+	fmt.Println("\n  Circuit Breaker:", cb.State)
+	if cb.State != modules.CircuitBreakerClosed {
+		fmt.Println("  Consecutive Failures:", cb.ConsecutiveFailures)
+		fmt.Println("  Next Probe:", cb.ProbeTime.Format("Jan 02 03:04 PM"))
+	}
+
 	fmt.Println()
 }
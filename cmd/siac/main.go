@@ -21,6 +21,7 @@ var (
 	hostVerbose       bool   // display additional host info
 	initForce         bool   // destroy and reencrypt the wallet on init if it already exists
 	initPassword      bool   // supply a custom password when creating a wallet
+	renterDeletePurge bool   // Delete the previous versions of a file as well.
 	renterListVerbose bool   // Show additional info about uploaded files.
 	renterShowHistory bool   // Show download history in addition to download queue.
 )
@@ -281,18 +282,19 @@ func main() {
 		renterDownloadsCmd, renterAllowanceCmd, renterSetAllowanceCmd,
 		renterContractsCmd, renterFilesListCmd, renterFilesRenameCmd,
 		renterFilesUploadCmd, renterUploadsCmd, renterExportCmd,
-		renterPricesCmd)
+		renterPricesCmd, renterSpendingCmd)
 
-	renterContractsCmd.AddCommand(renterContractsViewCmd)
+	renterContractsCmd.AddCommand(renterContractsCancelCmd, renterContractsViewCmd)
 	renterAllowanceCmd.AddCommand(renterAllowanceCancelCmd)
 
 	renterCmd.Flags().BoolVarP(&renterListVerbose, "verbose", "v", false, "Show additional file info such as redundancy")
+	renterFilesDeleteCmd.Flags().BoolVarP(&renterDeletePurge, "purge", "p", false, "Delete all previous versions of the file as well")
 	renterDownloadsCmd.Flags().BoolVarP(&renterShowHistory, "history", "H", false, "Show download history in addition to the download queue")
 	renterFilesListCmd.Flags().BoolVarP(&renterListVerbose, "verbose", "v", false, "Show additional file info such as redundancy")
 	renterExportCmd.AddCommand(renterExportContractTxnsCmd)
 
 	root.AddCommand(gatewayCmd)
-	gatewayCmd.AddCommand(gatewayConnectCmd, gatewayDisconnectCmd, gatewayAddressCmd, gatewayListCmd)
+	gatewayCmd.AddCommand(gatewayConnectCmd, gatewayDisconnectCmd, gatewayAddressCmd, gatewayLatencyCmd, gatewayListCmd)
 
 	root.AddCommand(consensusCmd)
 
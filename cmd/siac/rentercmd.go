@@ -43,6 +43,15 @@ var (
 		Run:   wrap(rentercontractscmd),
 	}
 
+	renterContractsCancelCmd = &cobra.Command{
+		Use:   "cancel [contract-id]",
+		Short: "Cancel the specified contract",
+		Long: `Cancel the specified contract. A cancelled contract is not used for uploads
+and is not renewed, and the files stored in it are repaired on other hosts. The
+funds remaining in the contract are lost.`,
+		Run: wrap(rentercontractscancelcmd),
+	}
+
 	renterContractsViewCmd = &cobra.Command{
 		Use:   "view [contract-id]",
 		Short: "View details of the specified contract",
@@ -61,7 +70,7 @@ var (
 		Use:     "delete [path]",
 		Aliases: []string{"rm"},
 		Short:   "Delete a file",
-		Long:    "Delete a file. Does not delete the file on disk.",
+		Long:    "Delete a file. Does not delete the file on disk. If the version retention of the file has an age limit, the file is kept as a previous version until it is too old, unless --purge is set.",
 		Run:     wrap(renterfilesdeletecmd),
 	}
 
@@ -102,6 +111,13 @@ var (
 		Run:   wrap(renterpricescmd),
 	}
 
+	renterSpendingCmd = &cobra.Command{
+		Use:   "spending",
+		Short: "View the spending of the current period",
+		Long:  "View where the funds of the current allowance period were spent, in total and for every contract of the period.",
+		Run:   wrap(renterspendingcmd),
+	}
+
 	renterSetAllowanceCmd = &cobra.Command{
 		Use:   "setallowance [amount] [period]",
 		Short: "Set the allowance",
@@ -147,17 +163,22 @@ func rentercmd() {
 		die("Could not get renter info:", err)
 	}
 	fm := rg.FinancialMetrics
-	unspent := fm.ContractSpending.Sub(fm.DownloadSpending).Sub(fm.StorageSpending).Sub(fm.UploadSpending)
 	fmt.Printf(`Renter info:
+	Identity:          %v
+	Contract Fees:     %v
 	Storage Spending:  %v
 	Upload Spending:   %v
-	Download Spending: %v
+	Download Spending: %v (%v for repairs)
 	Unspent Funds:     %v
+	Expected Return:   %v
 	Total Allocated:   %v
 
-`, currencyUnits(fm.StorageSpending), currencyUnits(fm.UploadSpending),
-		currencyUnits(fm.DownloadSpending), currencyUnits(unspent),
-		currencyUnits(fm.ContractSpending))
+`, rg.Identity.String(), currencyUnits(fm.ContractFees), currencyUnits(fm.StorageSpending), currencyUnits(fm.UploadSpending),
+		currencyUnits(fm.DownloadSpending), currencyUnits(fm.RepairDownloadSpending),
+		currencyUnits(fm.UnspentAllocated), currencyUnits(fm.ExpectedReturn), currencyUnits(fm.ContractSpending))
+	if re := rg.RepairEstimate; re.RemainingBytes > 0 {
+		fmt.Printf("Repair backlog: %v (%s)\n\n", filesizeUnits(int64(re.RemainingBytes)), repairEstimateStr(re.EstimatedTime, re.EstimatedTimeKnown))
+	}
 
 	// also list files
 	renterfileslistcmd()
@@ -190,10 +211,19 @@ func renteruploadscmd() {
 	}
 	fmt.Println("Uploading", len(filteredFiles), "files:")
 	for _, file := range filteredFiles {
-		fmt.Printf("%13s  %s (uploading, %0.2f%%)\n", filesizeUnits(int64(file.Filesize)), file.SiaPath, file.UploadProgress)
+		fmt.Printf("%13s  %s (uploading, %0.2f%%, %s)\n", filesizeUnits(int64(file.Filesize)), file.SiaPath, file.UploadProgress, repairEstimateStr(file.EstimatedRepairTime, file.EstimatedRepairTimeKnown))
 	}
 }
 
+// repairEstimateStr returns a description of an estimated time to full
+// redundancy.
+func repairEstimateStr(d time.Duration, known bool) string {
+	if !known {
+		return "time remaining unknown"
+	}
+	return "est. " + d.Round(time.Second).String() + " remaining"
+}
+
 // renterdownloadscmd is the handler for the command `siac renter downloads`.
 // Lists files currently downloading, and optionally previously downloaded
 // files if the -H or --history flag is specified.
@@ -311,7 +341,15 @@ func rentercontractscmd() {
 	fmt.Println("Contracts:")
 	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
 	fmt.Fprintln(w, "Host\tRemaining Funds\tSpent Funds\tSpent Fees\tData\tEnd Height\tID")
+	var stale int
 	for _, c := range rc.Contracts {
+		id := c.ID.String()
+		if c.Cancelled {
+			id += " (cancelled)"
+		} else if c.Stale {
+			id += fmt.Sprintf(" (stale: revision %v, host is at %v)", c.RevisionNumber, c.HostRevisionNumber)
+			stale++
+		}
 		fmt.Fprintf(w, "%v\t%8s\t%8s\t%8s\t%v\t%v\t%v\n",
 			c.NetAddress,
 			currencyUnits(c.RenterFunds),
@@ -319,11 +357,72 @@ func rentercontractscmd() {
 			currencyUnits(c.Fees),
 			filesizeUnits(int64(c.Size)),
 			c.EndHeight,
-			c.ID)
+			id)
+	}
+	w.Flush()
+	if stale > 0 {
+		fmt.Printf("\n%v contracts are stale: their hosts have more recent revisions, which could not be adopted. Stale contracts are not used for uploads.\n", stale)
+	}
+}
+
+// renterspendingcmd is the handler for the command `siac renter spending`. It
+// displays the spending of the current period, in total and per contract.
+func renterspendingcmd() {
+	var rg api.RenterGET
+	err := getAPI("/renter", &rg)
+	if err != nil {
+		die("Could not get renter info:", err)
+	}
+	fm := rg.FinancialMetrics
+	fmt.Printf(`Spending since block %v:
+	Total Allocated:   %v
+	Contract Fees:     %v
+	Storage Spending:  %v
+	Upload Spending:   %v
+	Download Spending: %v (%v for repairs)
+	Unspent Allocated: %v
+	Expected Return:   %v
+	Unspent Allowance: %v
+
+`, rg.CurrentPeriod, currencyUnits(fm.ContractSpending), currencyUnits(fm.ContractFees),
+		currencyUnits(fm.StorageSpending), currencyUnits(fm.UploadSpending),
+		currencyUnits(fm.DownloadSpending), currencyUnits(fm.RepairDownloadSpending),
+		currencyUnits(fm.UnspentAllocated), currencyUnits(fm.ExpectedReturn), currencyUnits(fm.Unspent))
+	if len(fm.Contracts) == 0 {
+		fmt.Println("No contracts have been formed this period.")
+		return
+	}
+	fmt.Println("Contracts:")
+	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Host	Fees	Storage	Upload	Download	Unspent	Expected Return	ID")
+	for _, c := range fm.Contracts {
+		id := c.ID.String()
+		if !c.Active {
+			id += " (inactive)"
+		}
+		fmt.Fprintf(w, "%v	%8s	%8s	%8s	%8s	%8s	%8s	%v\n",
+			c.NetAddress,
+			currencyUnits(c.ContractFees),
+			currencyUnits(c.StorageSpending),
+			currencyUnits(c.UploadSpending),
+			currencyUnits(c.DownloadSpending),
+			currencyUnits(c.UnspentAllocated),
+			currencyUnits(c.ExpectedReturn),
+			id)
 	}
 	w.Flush()
 }
 
+// rentercontractscancelcmd is the handler for the command `siac renter
+// contracts cancel <id>`. It cancels the specified contract.
+func rentercontractscancelcmd(cid string) {
+	err := post("/renter/contract/"+cid+"/cancel", "")
+	if err != nil {
+		die("Could not cancel contract:", err)
+	}
+	fmt.Println("Contract cancelled. The files stored in it will be repaired on other hosts.")
+}
+
 // rentercontractsviewcmd is the handler for the command `siac renter contracts <id>`.
 // It lists details of a specific contract.
 func rentercontractsviewcmd(cid string) {
@@ -355,6 +454,7 @@ Contract %v
   Remaining Funds:   %v
 
   File Size: %v
+  Revision:  %v
 `, rc.ID, rc.NetAddress, rc.HostPublicKey.String(), rc.StartHeight, rc.EndHeight,
 				currencyUnits(rc.TotalCost),
 				currencyUnits(rc.Fees),
@@ -363,7 +463,14 @@ Contract %v
 				currencyUnits(rc.StorageSpending),
 				currencyUnits(rc.DownloadSpending),
 				currencyUnits(rc.RenterFunds),
-				filesizeUnits(int64(rc.Size)))
+				filesizeUnits(int64(rc.Size)),
+				rc.RevisionNumber)
+			if rc.Cancelled {
+				fmt.Println("  Cancelled: the contract is not used for uploads and is not renewed")
+			}
+			if rc.Stale {
+				fmt.Printf("  Stale: the host is at revision %v and the contract is not used for uploads\n", rc.HostRevisionNumber)
+			}
 
 			printScoreBreakdown(&hostInfo)
 			return
@@ -376,7 +483,7 @@ Contract %v
 // renterfilesdeletecmd is the handler for the command `siac renter delete [path]`.
 // Removes the specified path from the Sia network.
 func renterfilesdeletecmd(path string) {
-	err := post("/renter/delete/"+path, "")
+	err := post("/renter/delete/"+path, fmt.Sprintf("purge=%v", renterDeletePurge))
 	if err != nil {
 		die("Could not delete file:", err)
 	}
@@ -454,7 +561,7 @@ func renterfileslistcmd() {
 	fmt.Println("Tracking", len(rf.Files), "files:")
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 	if renterListVerbose {
-		fmt.Fprintln(w, "File size\tAvailable\tProgress\tRedundancy\tRenewing\tSia path")
+		fmt.Fprintln(w, "File size\tAvailable\tProgress\tRedundancy\tErasure code\tRenewing\tSia path")
 	}
 	sort.Sort(bySiaPath(rf.Files))
 	for _, file := range rf.Files {
@@ -470,12 +577,16 @@ func renterfileslistcmd() {
 			if file.UploadProgress == -1 {
 				uploadProgressStr = "-"
 			}
-			fmt.Fprintf(w, "\t%s\t%8s\t%10s\t%s", availableStr, uploadProgressStr, redundancyStr, renewingStr)
+			erasureCodeStr := fmt.Sprintf("%v-of-%v", file.DataPieces, file.DataPieces+file.ParityPieces)
+			fmt.Fprintf(w, "\t%s\t%8s\t%10s\t%12s\t%s", availableStr, uploadProgressStr, redundancyStr, erasureCodeStr, renewingStr)
 		}
 		fmt.Fprintf(w, "\t%s", file.SiaPath)
 		if !renterListVerbose && !file.Available {
 			fmt.Fprintf(w, " (uploading, %0.2f%%)", file.UploadProgress)
 		}
+		if file.Versions > 0 {
+			fmt.Fprintf(w, " (%v previous versions, %s)", file.Versions, filesizeUnits(int64(file.VersionBytes)))
+		}
 		fmt.Fprintln(w, "")
 	}
 	w.Flush()
@@ -416,12 +416,16 @@ Unlock the wallet to view balance
 Confirmed Balance:   %v
 Unconfirmed Delta:  %v
 Exact:               %v H
+Timelocked:          %v
 Siafunds:            %v SF
 Siafund Claims:      %v H
+Watched:             %v, %v SF
 
 Estimated Fee:       %v / KB
 `, encStatus, currencyUnits(status.ConfirmedSiacoinBalance), delta,
-		status.ConfirmedSiacoinBalance, status.SiafundBalance, status.SiacoinClaimBalance,
+		status.ConfirmedSiacoinBalance, currencyUnits(status.TimelockedSiacoinBalance),
+		status.SiafundBalance, status.SiacoinClaimBalance,
+		currencyUnits(status.WatchedSiacoinBalance), status.WatchedSiafundBalance,
 		fees.Maximum.Mul64(1e3).HumanString())
 }
 
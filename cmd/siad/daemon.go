@@ -14,14 +14,17 @@ import (
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/bandwidth"
 	"github.com/NebulousLabs/Sia/modules/consensus"
 	"github.com/NebulousLabs/Sia/modules/explorer"
 	"github.com/NebulousLabs/Sia/modules/gateway"
 	"github.com/NebulousLabs/Sia/modules/host"
 	"github.com/NebulousLabs/Sia/modules/miner"
 	"github.com/NebulousLabs/Sia/modules/renter"
+	"github.com/NebulousLabs/Sia/modules/scheduler"
 	"github.com/NebulousLabs/Sia/modules/transactionpool"
 	"github.com/NebulousLabs/Sia/modules/wallet"
+	"github.com/NebulousLabs/Sia/persist"
 	"github.com/NebulousLabs/Sia/profile"
 	mnemonics "github.com/NebulousLabs/entropy-mnemonics"
 
@@ -29,6 +32,20 @@ import (
 	"golang.org/x/crypto/ssh/terminal"
 )
 
+const (
+	// renterBackupTask is the name of the task that backs up the metadata of
+	// the renter's files to renterBackupFile in the renter directory.
+	renterBackupTask     = "renterbackup"
+	renterBackupFile     = "filelist-backup.json"
+	renterBackupInterval = 24 * time.Hour
+)
+
+// bandwidthLimited is implemented by the modules whose connections draw from
+// the node's bandwidth budget.
+type bandwidthLimited interface {
+	SetBandwidthManager(modules.BandwidthManager)
+}
+
 // passwordPrompt securely reads a password from stdin.
 func passwordPrompt(prompt string) (string, error) {
 	fmt.Print(prompt)
@@ -119,6 +136,20 @@ func processConfig(config Config) (Config, error) {
 	return config, nil
 }
 
+// backupRenterFiles writes the metadata of all files of the renter to
+// path, replacing the previous backup.
+func backupRenterFiles(r modules.Renter, path string) error {
+	handle, err := persist.NewSafeFile(path)
+	if err != nil {
+		return err
+	}
+	defer handle.Close()
+	if err := r.ExportFileList(handle, modules.FileListFormatJSON); err != nil {
+		return err
+	}
+	return handle.CommitSync()
+}
+
 // unlockWallet is called on siad startup and attempts to automatically
 // unlock the wallet with the given password string.
 func unlockWallet(w modules.Wallet, password string) error {
@@ -173,6 +204,29 @@ func startDaemon(config Config) (err error) {
 		return err
 	}
 
+	// Create the scheduler before serving, so that the daemon routes can list
+	// the tasks that the modules register.
+	sched, err := scheduler.New(filepath.Join(config.Siad.SiaDir, modules.SchedulerDir))
+	if err != nil {
+		return err
+	}
+	srv.scheduler = sched
+
+	// Create the bandwidth manager before the modules, so that it is closed
+	// after them.
+	bm, err := bandwidth.New(config.Siad.MaxUploadSpeed, config.Siad.MaxDownloadSpeed)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		fmt.Println("Closing bandwidth manager...")
+		err := bm.Close()
+		if err != nil {
+			fmt.Println("Error during bandwidth manager shutdown:", err)
+		}
+	}()
+	srv.bandwidth = bm
+
 	servErrs := make(chan error)
 	go func() {
 		servErrs <- srv.Serve()
@@ -211,6 +265,13 @@ func startDaemon(config Config) (err error) {
 				fmt.Println("Error during consensus set shutdown:", err)
 			}
 		}()
+		if config.Siad.IndexTransactions {
+			fmt.Println("Indexing transactions...")
+			err = cs.EnableTransactionIndex()
+			if err != nil {
+				return err
+			}
+		}
 	}
 	var e modules.Explorer
 	if strings.Contains(config.Siad.Modules, "e") {
@@ -309,6 +370,34 @@ func startDaemon(config Config) (err error) {
 		}()
 	}
 
+	// Share the bandwidth budget between the modules that use the network.
+	// Connections that the modules opened while loading draw from the budget
+	// as well.
+	for _, m := range []interface{}{g, h, r} {
+		if bl, ok := m.(bandwidthLimited); ok {
+			bl.SetBandwidthManager(bm)
+		}
+	}
+
+	// Register the recurring tasks of the modules. The scheduler is closed
+	// before the modules, so that no task runs while they are closing.
+	if r != nil {
+		backupPath := filepath.Join(config.Siad.SiaDir, modules.RenterDir, renterBackupFile)
+		err = sched.RegisterTask(renterBackupTask, renterBackupInterval, func() error {
+			return backupRenterFiles(r, backupPath)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	defer func() {
+		fmt.Println("Closing scheduler...")
+		err := sched.Close()
+		if err != nil {
+			fmt.Println("Error during scheduler shutdown:", err)
+		}
+	}()
+
 	// Create the Sia API
 	a := api.New(
 		config.Siad.RequiredUserAgent,
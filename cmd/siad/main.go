@@ -37,11 +37,15 @@ type Config struct {
 		HostAddr     string
 		AllowAPIBind bool
 
+		IndexTransactions bool
 		Modules           string
 		NoBootstrap       bool
 		RequiredUserAgent string
 		AuthenticateAPI   bool
 
+		MaxDownloadSpeed int64
+		MaxUploadSpeed   int64
+
 		Profile    string
 		ProfileDir string
 		SiaDir     string
@@ -163,10 +167,13 @@ func main() {
 	root.Flags().StringVarP(&globalConfig.Siad.APIaddr, "api-addr", "", "localhost:9980", "which host:port the API server listens on")
 	root.Flags().StringVarP(&globalConfig.Siad.SiaDir, "sia-directory", "d", "", "location of the sia directory")
 	root.Flags().BoolVarP(&globalConfig.Siad.NoBootstrap, "no-bootstrap", "", false, "disable bootstrapping on this run")
+	root.Flags().BoolVarP(&globalConfig.Siad.IndexTransactions, "index-transactions", "", false, "index the transactions of the blockchain to serve inclusion proofs")
 	root.Flags().StringVarP(&globalConfig.Siad.Profile, "profile", "", "", "enable profiling with flags 'cmt' for CPU, memory, trace")
 	root.Flags().StringVarP(&globalConfig.Siad.RPCaddr, "rpc-addr", "", ":9981", "which port the gateway listens on")
 	root.Flags().StringVarP(&globalConfig.Siad.Modules, "modules", "M", "cghrtw", "enabled modules, see 'siad modules' for more info")
 	root.Flags().BoolVarP(&globalConfig.Siad.AuthenticateAPI, "authenticate-api", "", false, "enable API password protection")
+	root.Flags().Int64VarP(&globalConfig.Siad.MaxDownloadSpeed, "max-download-speed", "", 0, "limit the combined download speed of all modules in bytes per second (0 is unlimited)")
+	root.Flags().Int64VarP(&globalConfig.Siad.MaxUploadSpeed, "max-upload-speed", "", 0, "limit the combined upload speed of all modules in bytes per second (0 is unlimited)")
 	root.Flags().BoolVarP(&globalConfig.Siad.AllowAPIBind, "disable-api-security", "", false, "allow siad to listen on a non-localhost address (DANGEROUS)")
 
 	// Parse cmdline flags, overwriting both the default values and the config
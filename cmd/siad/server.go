@@ -20,6 +20,7 @@ import (
 
 	"github.com/NebulousLabs/Sia/api"
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/inconshreveable/go-update"
@@ -27,7 +28,11 @@ import (
 	"github.com/kardianos/osext"
 )
 
-var errEmptyUpdateResponse = errors.New("API call to https://api.github.com/repos/NebulousLabs/Sia/releases/latest is returning an empty response")
+var (
+	errEmptyUpdateResponse = errors.New("API call to https://api.github.com/repos/NebulousLabs/Sia/releases/latest is returning an empty response")
+	errNoBandwidthManager  = errors.New("the bandwidth manager is not loaded")
+	errNoScheduler         = errors.New("the scheduler is not loaded")
+)
 
 type (
 	// Server creates and serves a HTTP server that offers communication with a
@@ -36,6 +41,15 @@ type (
 		httpServer *http.Server
 		mux        *http.ServeMux
 		listener   net.Listener
+
+		// scheduler runs the recurring tasks of the daemon. It is set before
+		// the server starts serving, and is nil if no scheduler is loaded.
+		scheduler modules.Scheduler
+
+		// bandwidth shares the node's bandwidth budget between the modules.
+		// It is set before the server starts serving, and is nil if no
+		// bandwidth manager is loaded.
+		bandwidth modules.BandwidthManager
 	}
 
 	// SiaConstants is a struct listing all of the constants in use.
@@ -65,6 +79,17 @@ type (
 	DaemonVersion struct {
 		Version string `json:"version"`
 	}
+	// DaemonBandwidth describes the bandwidth budget of the node and the
+	// bandwidth consumed by each module.
+	DaemonBandwidth struct {
+		MaxUploadSpeed   int64                    `json:"maxuploadspeed"`
+		MaxDownloadSpeed int64                    `json:"maxdownloadspeed"`
+		Usage            []modules.BandwidthUsage `json:"usage"`
+	}
+	// DaemonTasks lists the recurring tasks of the daemon.
+	DaemonTasks struct {
+		Tasks []modules.ScheduledTask `json:"tasks"`
+	}
 	// UpdateInfo indicates whether an update is available, and to what
 	// version.
 	UpdateInfo struct {
@@ -354,6 +379,108 @@ func (srv *Server) daemonStopHandler(w http.ResponseWriter, _ *http.Request, _ h
 	}
 }
 
+// daemonTasksHandlerGET handles the API call that lists the recurring tasks
+// of the daemon.
+func (srv *Server) daemonTasksHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	if srv.scheduler == nil {
+		api.WriteError(w, api.Error{Message: errNoScheduler.Error()}, http.StatusBadRequest)
+		return
+	}
+	api.WriteJSON(w, DaemonTasks{
+		Tasks: srv.scheduler.Tasks(),
+	})
+}
+
+// daemonTasksHandlerPOST handles the API call that triggers, pauses,
+// resumes, or reschedules a recurring task of the daemon.
+func (srv *Server) daemonTasksHandlerPOST(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	if srv.scheduler == nil {
+		api.WriteError(w, api.Error{Message: errNoScheduler.Error()}, http.StatusBadRequest)
+		return
+	}
+	name := ps.ByName("name")
+
+	// Reschedule the task first, so that a task that is resumed in the same
+	// call resumes with its new interval.
+	if req.FormValue("interval") != "" {
+		var seconds uint64
+		if _, err := fmt.Sscan(req.FormValue("interval"), &seconds); err != nil {
+			api.WriteError(w, api.Error{Message: "unable to parse interval: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if err := srv.scheduler.RescheduleTask(name, time.Duration(seconds)*time.Second); err != nil {
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	var err error
+	switch action := req.FormValue("action"); action {
+	case "":
+		if req.FormValue("interval") == "" {
+			err = errors.New("either action or interval must be provided")
+		}
+	case "run":
+		err = srv.scheduler.RunTask(name)
+	case "pause":
+		err = srv.scheduler.PauseTask(name)
+	case "resume":
+		err = srv.scheduler.ResumeTask(name)
+	default:
+		err = errors.New("unknown action " + action + ", must be 'run', 'pause', or 'resume'")
+	}
+	if err != nil {
+		api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	api.WriteSuccess(w)
+}
+
+// daemonBandwidthHandlerGET handles the API call that returns the bandwidth
+// budget of the node and the bandwidth consumed by each module.
+func (srv *Server) daemonBandwidthHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	if srv.bandwidth == nil {
+		api.WriteError(w, api.Error{Message: errNoBandwidthManager.Error()}, http.StatusBadRequest)
+		return
+	}
+	upload, download := srv.bandwidth.Limits()
+	api.WriteJSON(w, DaemonBandwidth{
+		MaxUploadSpeed:   upload,
+		MaxDownloadSpeed: download,
+		Usage:            srv.bandwidth.Usage(),
+	})
+}
+
+// daemonBandwidthHandlerPOST handles the API call that changes the bandwidth
+// budget of the node until the daemon restarts.
+func (srv *Server) daemonBandwidthHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	if srv.bandwidth == nil {
+		api.WriteError(w, api.Error{Message: errNoBandwidthManager.Error()}, http.StatusBadRequest)
+		return
+	}
+	upload, download := srv.bandwidth.Limits()
+	for _, limit := range []struct {
+		name  string
+		value *int64
+	}{
+		{"maxuploadspeed", &upload},
+		{"maxdownloadspeed", &download},
+	} {
+		if req.FormValue(limit.name) == "" {
+			continue
+		}
+		if _, err := fmt.Sscan(req.FormValue(limit.name), limit.value); err != nil {
+			api.WriteError(w, api.Error{Message: "unable to parse " + limit.name + ": " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if err := srv.bandwidth.SetLimits(upload, download); err != nil {
+		api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	api.WriteSuccess(w)
+}
+
 func (srv *Server) daemonHandler(password string) http.Handler {
 	router := httprouter.New()
 
@@ -362,6 +489,10 @@ func (srv *Server) daemonHandler(password string) http.Handler {
 	router.GET("/daemon/update", srv.daemonUpdateHandlerGET)
 	router.POST("/daemon/update", srv.daemonUpdateHandlerPOST)
 	router.GET("/daemon/stop", api.RequirePassword(srv.daemonStopHandler, password))
+	router.GET("/daemon/tasks", srv.daemonTasksHandlerGET)
+	router.POST("/daemon/tasks/:name", api.RequirePassword(srv.daemonTasksHandlerPOST, password))
+	router.GET("/daemon/bandwidth", srv.daemonBandwidthHandlerGET)
+	router.POST("/daemon/bandwidth", api.RequirePassword(srv.daemonBandwidthHandlerPOST, password))
 
 	return router
 }
@@ -1,6 +1,20 @@
 package main
 
-import "testing"
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/bandwidth"
+	"github.com/NebulousLabs/Sia/modules/scheduler"
+)
 
 // TestLatestRelease tests that the latestRelease function properly processes a
 // set of GitHub releases, returning the release with the highest version
@@ -66,3 +80,119 @@ func TestLatestRelease(t *testing.T) {
 		}
 	}
 }
+
+// TestDaemonTasksHandler checks that the recurring tasks of the daemon can be
+// listed and controlled through the daemon routes.
+func TestDaemonTasksHandler(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	sched, err := scheduler.New(build.TempDir("siad", t.Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sched.Close()
+	if err := sched.RegisterTask("foo", time.Hour, func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{scheduler: sched}
+	handler := srv.daemonHandler("")
+
+	post := func(name string, values url.Values) int {
+		req := httptest.NewRequest("POST", "/daemon/tasks/"+name, strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := post("foo", url.Values{"action": {"pause"}, "interval": {"60"}}); code != http.StatusNoContent {
+		t.Fatal("unexpected status code:", code)
+	}
+	for _, values := range []url.Values{{"action": {"explode"}}, {"interval": {"soon"}}, {}} {
+		if code := post("foo", values); code != http.StatusBadRequest {
+			t.Errorf("expected %v to be rejected, got %v", values, code)
+		}
+	}
+	if code := post("bar", url.Values{"action": {"run"}}); code != http.StatusBadRequest {
+		t.Error("expected an unknown task to be rejected, got", code)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/daemon/tasks", nil))
+	var dt DaemonTasks
+	if err := json.NewDecoder(rec.Body).Decode(&dt); err != nil {
+		t.Fatal(err)
+	}
+	if len(dt.Tasks) != 1 || dt.Tasks[0].Name != "foo" || !dt.Tasks[0].Paused || dt.Tasks[0].Interval != time.Minute {
+		t.Fatal("unexpected tasks:", dt.Tasks)
+	}
+
+	// Paused tasks can still be triggered.
+	if code := post("foo", url.Values{"action": {"run"}}); code != http.StatusNoContent {
+		t.Fatal("unexpected status code:", code)
+	}
+	err = build.Retry(50, 20*time.Millisecond, func() error {
+		if tasks := sched.Tasks(); tasks[0].LastResult != modules.TaskResultSuccess {
+			return errors.New("task did not run")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a scheduler, the routes return an error.
+	rec = httptest.NewRecorder()
+	(&Server{}).daemonHandler("").ServeHTTP(rec, httptest.NewRequest("GET", "/daemon/tasks", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatal("expected an error without a scheduler, got", rec.Code)
+	}
+}
+
+// TestDaemonBandwidthHandler checks that the bandwidth budget of the node can
+// be read and changed through the daemon routes.
+func TestDaemonBandwidthHandler(t *testing.T) {
+	bm, err := bandwidth.New(100, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bm.Close()
+	srv := &Server{bandwidth: bm}
+	handler := srv.daemonHandler("")
+
+	post := func(values url.Values) int {
+		req := httptest.NewRequest("POST", "/daemon/bandwidth", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := post(url.Values{"maxdownloadspeed": {"300"}}); code != http.StatusNoContent {
+		t.Fatal("unexpected status code:", code)
+	}
+	for _, values := range []url.Values{{"maxuploadspeed": {"-1"}}, {"maxdownloadspeed": {"fast"}}} {
+		if code := post(values); code != http.StatusBadRequest {
+			t.Errorf("expected %v to be rejected, got %v", values, code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/daemon/bandwidth", nil))
+	var db DaemonBandwidth
+	if err := json.NewDecoder(rec.Body).Decode(&db); err != nil {
+		t.Fatal(err)
+	}
+	if db.MaxUploadSpeed != 100 || db.MaxDownloadSpeed != 300 {
+		t.Fatal("unexpected limits:", db.MaxUploadSpeed, db.MaxDownloadSpeed)
+	}
+	if len(db.Usage) != 3 || db.Usage[0].Class != modules.BandwidthClassGateway || db.Usage[1].Class != modules.BandwidthClassHost || db.Usage[2].Class != modules.BandwidthClassRenter {
+		t.Fatal("unexpected usage:", db.Usage)
+	}
+
+	// Without a bandwidth manager, the routes return an error.
+	rec = httptest.NewRecorder()
+	(&Server{}).daemonHandler("").ServeHTTP(rec, httptest.NewRequest("GET", "/daemon/bandwidth", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatal("expected an error without a bandwidth manager, got", rec.Code)
+	}
+}
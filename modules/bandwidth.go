@@ -0,0 +1,178 @@
+package modules
+
+import (
+	"net"
+	"sync"
+)
+
+const (
+	// BandwidthClassGateway is the bandwidth class of the gateway's peer
+	// connections, which relay blocks and transactions.
+	BandwidthClassGateway BandwidthClass = "gateway"
+
+	// BandwidthClassHost is the bandwidth class of the connections of
+	// renters to the host.
+	BandwidthClassHost BandwidthClass = "host"
+
+	// BandwidthClassRenter is the bandwidth class of the renter's
+	// connections to hosts, which includes repairs.
+	BandwidthClassRenter BandwidthClass = "renter"
+
+	// bandwidthChunkSize is the largest read or write of a limited connection
+	// that waits for the budget at once.
+	bandwidthChunkSize = 16 << 10
+)
+
+type (
+	// A BandwidthClass identifies the module whose connections draw from the
+	// node's bandwidth budget.
+	BandwidthClass string
+
+	// BandwidthUsage describes the bandwidth consumed by the connections of a
+	// bandwidth class. Uploaded and Downloaded are the totals since the
+	// manager started, the rates are the bytes per second of the last
+	// second.
+	BandwidthUsage struct {
+		Class        BandwidthClass `json:"class"`
+		Weight       uint64         `json:"weight"`
+		Uploaded     uint64         `json:"uploaded"`
+		Downloaded   uint64         `json:"downloaded"`
+		UploadRate   uint64         `json:"uploadrate"`
+		DownloadRate uint64         `json:"downloadrate"`
+	}
+
+	// A BandwidthManager shares a node-wide upload and download budget
+	// between the connections of the node's modules. While the budget is
+	// exhausted, the bandwidth is divided between the classes that are
+	// waiting for it by their weights. Modules may limit their connections
+	// further with their own limits.
+	BandwidthManager interface {
+		// WaitUpload blocks until n bytes of upload of the class fit the
+		// budget, or until cancel is closed.
+		WaitUpload(class BandwidthClass, n int, cancel <-chan struct{}) error
+
+		// WaitDownload blocks until n bytes of download of the class fit
+		// the budget, or until cancel is closed.
+		WaitDownload(class BandwidthClass, n int, cancel <-chan struct{}) error
+
+		// Limits returns the upload and download budgets in bytes per
+		// second. Zero means unlimited.
+		Limits() (upload, download int64)
+
+		// SetLimits sets the upload and download budgets in bytes per
+		// second. Zero means unlimited.
+		SetLimits(upload, download int64) error
+
+		// Usage returns the bandwidth consumption of every class.
+		Usage() []BandwidthUsage
+
+		// Close stops the manager. Waiting connections are released.
+		Close() error
+	}
+
+	// A BandwidthLimiter connects the connections of a module to the node's
+	// BandwidthManager. Modules limit every connection as it is opened, and
+	// the manager may be set later, so that connections which were opened
+	// before the manager was set draw from its budget as well. Until a
+	// manager is set, connections are not limited.
+	BandwidthLimiter struct {
+		class   BandwidthClass
+		manager BandwidthManager
+		mu      sync.RWMutex
+	}
+
+	// limitedConn is a connection whose reads and writes draw from the
+	// budget of the manager of a BandwidthLimiter.
+	limitedConn struct {
+		net.Conn
+		bl        *BandwidthLimiter
+		closeChan chan struct{}
+		closeOnce sync.Once
+	}
+)
+
+// NewBandwidthLimiter returns a BandwidthLimiter for the connections of class.
+func NewBandwidthLimiter(class BandwidthClass) *BandwidthLimiter {
+	return &BandwidthLimiter{class: class}
+}
+
+// SetManager sets the BandwidthManager that the connections of the limiter
+// draw from. A nil manager stops limiting them.
+func (bl *BandwidthLimiter) SetManager(bm BandwidthManager) {
+	bl.mu.Lock()
+	bl.manager = bm
+	bl.mu.Unlock()
+}
+
+// Manager returns the BandwidthManager of the limiter, or nil if none is set.
+func (bl *BandwidthLimiter) Manager() BandwidthManager {
+	bl.mu.RLock()
+	defer bl.mu.RUnlock()
+	return bl.manager
+}
+
+// LimitConn returns conn with its reads and writes limited by the manager of
+// the limiter. A nil limiter does not limit conn.
+func (bl *BandwidthLimiter) LimitConn(conn net.Conn) net.Conn {
+	if bl == nil {
+		return conn
+	}
+	return &limitedConn{
+		Conn:      conn,
+		bl:        bl,
+		closeChan: make(chan struct{}),
+	}
+}
+
+// Read reads from the connection, and then waits until the download budget
+// allows the bytes that were read.
+func (c *limitedConn) Read(b []byte) (int, error) {
+	bm := c.bl.Manager()
+	if bm == nil {
+		return c.Conn.Read(b)
+	}
+	if len(b) > bandwidthChunkSize {
+		b = b[:bandwidthChunkSize]
+	}
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		if waitErr := bm.WaitDownload(c.bl.class, n, c.closeChan); waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return n, err
+}
+
+// Write waits until the upload budget allows each chunk of b, and writes it
+// to the connection.
+func (c *limitedConn) Write(b []byte) (int, error) {
+	var written int
+	for len(b) > 0 {
+		bm := c.bl.Manager()
+		if bm == nil {
+			n, err := c.Conn.Write(b)
+			return written + n, err
+		}
+		chunk := b
+		if len(chunk) > bandwidthChunkSize {
+			chunk = chunk[:bandwidthChunkSize]
+		}
+		if err := bm.WaitUpload(c.bl.class, len(chunk), c.closeChan); err != nil {
+			return written, err
+		}
+		n, err := c.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// Close closes the connection, and releases its reads and writes that are
+// waiting for the budget.
+func (c *limitedConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closeChan) })
+	return c.Conn.Close()
+}
@@ -0,0 +1,448 @@
+// Package bandwidth shares the upload and download budget of the node between
+// the connections of its modules. Each direction is a token bucket that is
+// refilled at the budget's rate. While connections are waiting for a bucket,
+// the bandwidth is granted to the classes in proportion to their weights, so
+// that host traffic is served before the renter's repairs, and the gateway's
+// relaying is served last, without any class being starved.
+package bandwidth
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	siasync "github.com/NebulousLabs/Sia/sync"
+)
+
+const (
+	// burstDuration is the amount of the budget that a bucket can save up
+	// while its connections are idle.
+	burstDuration = 100 * time.Millisecond
+
+	// defaultWeight is the weight of a class that has no weight in
+	// classWeights.
+	defaultWeight = 1
+
+	// rateInterval is the interval over which the rates of the classes are
+	// measured.
+	rateInterval = time.Second
+
+	// scheduleInterval is the interval at which the buckets are refilled
+	// while connections are waiting for them.
+	scheduleInterval = 10 * time.Millisecond
+)
+
+var (
+	// classWeights are the shares of the budget that the classes receive
+	// while the budget is exhausted.
+	classWeights = map[modules.BandwidthClass]uint64{
+		modules.BandwidthClassHost:    4,
+		modules.BandwidthClassRenter:  2,
+		modules.BandwidthClassGateway: 1,
+	}
+
+	errNegativeLimit = errors.New("bandwidth limit cannot be negative")
+	errWaitCanceled  = errors.New("connection was canceled while waiting for bandwidth")
+)
+
+type (
+	// A request is a connection that is waiting for n bytes of a bucket.
+	request struct {
+		n       uint64
+		granted chan struct{}
+	}
+
+	// A queue holds the requests of a class for a bucket. vtime is the
+	// virtual time of the class, which advances by the bytes granted to the
+	// class divided by its weight. The class with the lowest virtual time
+	// is served next.
+	queue struct {
+		requests []*request
+		vtime    float64
+	}
+
+	// A bucket is the budget of one direction.
+	bucket struct {
+		rate       int64
+		tokens     float64
+		lastRefill time.Time
+
+		// queues holds the waiting requests of each class. vtime is the
+		// virtual time of the bucket, which is the virtual time of the last
+		// class that was served.
+		queues map[modules.BandwidthClass]*queue
+		vtime  float64
+	}
+
+	// A classUsage counts the bandwidth of a class.
+	classUsage struct {
+		uploaded     uint64
+		downloaded   uint64
+		uploadRate   uint64
+		downloadRate uint64
+
+		// lastUploaded and lastDownloaded are the totals at the last rate
+		// measurement.
+		lastUploaded   uint64
+		lastDownloaded uint64
+	}
+
+	// A Manager divides the node's upload and download budget between the
+	// connections of the modules.
+	Manager struct {
+		upload   bucket
+		download bucket
+
+		usage     map[modules.BandwidthClass]*classUsage
+		lastRates time.Time
+
+		// wake is signaled when a request is queued.
+		wake chan struct{}
+
+		mu sync.Mutex
+		tg siasync.ThreadGroup
+	}
+)
+
+// weight returns the weight of class.
+func weight(class modules.BandwidthClass) uint64 {
+	if w, ok := classWeights[class]; ok {
+		return w
+	}
+	return defaultWeight
+}
+
+// New returns a Manager with an upload and a download budget in bytes per
+// second. A budget of zero is unlimited.
+func New(upload, download int64) (*Manager, error) {
+	if upload < 0 || download < 0 {
+		return nil, errNegativeLimit
+	}
+	now := time.Now()
+	m := &Manager{
+		upload:    newBucket(upload, now),
+		download:  newBucket(download, now),
+		usage:     make(map[modules.BandwidthClass]*classUsage),
+		lastRates: now,
+		wake:      make(chan struct{}, 1),
+	}
+	for class := range classWeights {
+		m.usage[class] = new(classUsage)
+	}
+	go m.threadedSchedule()
+	return m, nil
+}
+
+// newBucket returns a full bucket of rate bytes per second.
+func newBucket(rate int64, now time.Time) bucket {
+	b := bucket{
+		rate:       rate,
+		lastRefill: now,
+		queues:     make(map[modules.BandwidthClass]*queue),
+	}
+	b.tokens = b.burst()
+	return b
+}
+
+// burst returns the largest number of tokens that the bucket can hold.
+func (b *bucket) burst() float64 {
+	return float64(b.rate) * burstDuration.Seconds()
+}
+
+// waiting reports whether any request is waiting for the bucket.
+func (b *bucket) waiting() bool {
+	for _, q := range b.queues {
+		if len(q.requests) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// refill adds the tokens of the time since the last refill to the bucket.
+func (b *bucket) refill(now time.Time) {
+	b.tokens += float64(b.rate) * now.Sub(b.lastRefill).Seconds()
+	if burst := b.burst(); b.tokens > burst {
+		b.tokens = burst
+	}
+	b.lastRefill = now
+}
+
+// setRate sets the rate of the bucket. All waiting requests are granted if
+// the bucket becomes unlimited.
+func (b *bucket) setRate(rate int64, now time.Time) {
+	b.refill(now)
+	b.rate = rate
+	if burst := b.burst(); b.tokens > burst {
+		b.tokens = burst
+	}
+	if rate == 0 {
+		for _, q := range b.queues {
+			for _, req := range q.requests {
+				close(req.granted)
+			}
+			q.requests = nil
+		}
+	}
+}
+
+// enqueue adds req to the queue of class. A class that was idle starts at
+// the virtual time of the bucket, so that it cannot claim the bandwidth that
+// it did not use while it was idle.
+func (b *bucket) enqueue(class modules.BandwidthClass, req *request) {
+	q, ok := b.queues[class]
+	if !ok {
+		q = new(queue)
+		b.queues[class] = q
+	}
+	if len(q.requests) == 0 && q.vtime < b.vtime {
+		q.vtime = b.vtime
+	}
+	q.requests = append(q.requests, req)
+}
+
+// dequeue removes req from the queue of class, reporting whether it was
+// still waiting.
+func (b *bucket) dequeue(class modules.BandwidthClass, req *request) bool {
+	q := b.queues[class]
+	for i, r := range q.requests {
+		if r == req {
+			q.requests = append(q.requests[:i], q.requests[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// grant grants the waiting requests while the bucket has tokens. The tokens
+// of the bucket may become negative, which delays the next grant until they
+// are paid for.
+func (b *bucket) grant() {
+	for b.tokens > 0 {
+		var next *queue
+		var nextClass modules.BandwidthClass
+		for class, q := range b.queues {
+			if len(q.requests) == 0 {
+				continue
+			}
+			if next == nil || q.vtime < next.vtime || (q.vtime == next.vtime && class < nextClass) {
+				next, nextClass = q, class
+			}
+		}
+		if next == nil {
+			return
+		}
+		req := next.requests[0]
+		next.requests = next.requests[1:]
+		b.tokens -= float64(req.n)
+		b.vtime = next.vtime
+		next.vtime += float64(req.n) / float64(weight(nextClass))
+		close(req.granted)
+	}
+}
+
+// classUsage returns the usage of class, adding it if it does not exist. The
+// caller must hold the lock.
+func (m *Manager) classUsage(class modules.BandwidthClass) *classUsage {
+	cu, ok := m.usage[class]
+	if !ok {
+		cu = new(classUsage)
+		m.usage[class] = cu
+	}
+	return cu
+}
+
+// updateRates measures the rates of the classes since the last measurement.
+// The caller must hold the lock.
+func (m *Manager) updateRates(now time.Time) {
+	elapsed := now.Sub(m.lastRates).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	for _, cu := range m.usage {
+		cu.uploadRate = uint64(float64(cu.uploaded-cu.lastUploaded) / elapsed)
+		cu.downloadRate = uint64(float64(cu.downloaded-cu.lastDownloaded) / elapsed)
+		cu.lastUploaded = cu.uploaded
+		cu.lastDownloaded = cu.downloaded
+	}
+	m.lastRates = now
+}
+
+// threadedSchedule refills the buckets and grants the waiting requests, and
+// measures the rates of the classes.
+func (m *Manager) threadedSchedule() {
+	if err := m.tg.Add(); err != nil {
+		return
+	}
+	defer m.tg.Done()
+
+	for {
+		m.mu.Lock()
+		now := time.Now()
+		for _, b := range []*bucket{&m.upload, &m.download} {
+			b.refill(now)
+			b.grant()
+		}
+		if now.Sub(m.lastRates) >= rateInterval {
+			m.updateRates(now)
+		}
+		wait := time.Until(m.lastRates.Add(rateInterval))
+		if m.upload.waiting() || m.download.waiting() {
+			wait = scheduleInterval
+		}
+		m.mu.Unlock()
+
+		t := time.NewTimer(wait)
+		select {
+		case <-m.tg.StopChan():
+			t.Stop()
+			return
+		case <-m.wake:
+		case <-t.C:
+		}
+		t.Stop()
+	}
+}
+
+// managedCount adds n bytes to the usage of class.
+func (m *Manager) managedCount(class modules.BandwidthClass, n int, upload bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cu := m.classUsage(class)
+	if upload {
+		cu.uploaded += uint64(n)
+	} else {
+		cu.downloaded += uint64(n)
+	}
+}
+
+// managedWait waits until b grants n bytes to class, or until cancel is
+// closed.
+func (m *Manager) managedWait(b *bucket, class modules.BandwidthClass, n int, cancel <-chan struct{}) error {
+	if n <= 0 {
+		return nil
+	}
+	if err := m.tg.Add(); err != nil {
+		// A stopped manager no longer limits the connections.
+		return nil
+	}
+	defer m.tg.Done()
+
+	m.mu.Lock()
+	if b.rate == 0 {
+		m.mu.Unlock()
+		return nil
+	}
+	// Serve the request immediately if nobody is waiting and the bucket has
+	// tokens.
+	b.refill(time.Now())
+	if b.tokens > 0 && !b.waiting() {
+		b.tokens -= float64(n)
+		m.mu.Unlock()
+		return nil
+	}
+	req := &request{
+		n:       uint64(n),
+		granted: make(chan struct{}),
+	}
+	b.enqueue(class, req)
+	m.mu.Unlock()
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+
+	select {
+	case <-req.granted:
+		return nil
+	case <-m.tg.StopChan():
+		return nil
+	case <-cancel:
+	}
+	m.mu.Lock()
+	waiting := b.dequeue(class, req)
+	m.mu.Unlock()
+	if !waiting {
+		// The request was granted while it was canceled.
+		return nil
+	}
+	return errWaitCanceled
+}
+
+// WaitUpload blocks until n bytes of upload of class fit the budget, or until
+// cancel is closed.
+func (m *Manager) WaitUpload(class modules.BandwidthClass, n int, cancel <-chan struct{}) error {
+	if err := m.managedWait(&m.upload, class, n, cancel); err != nil {
+		return err
+	}
+	m.managedCount(class, n, true)
+	return nil
+}
+
+// WaitDownload blocks until n bytes of download of class fit the budget, or
+// until cancel is closed. The bytes have already been read, so they count
+// towards the usage of class even if the wait is canceled.
+func (m *Manager) WaitDownload(class modules.BandwidthClass, n int, cancel <-chan struct{}) error {
+	m.managedCount(class, n, false)
+	return m.managedWait(&m.download, class, n, cancel)
+}
+
+// Limits returns the upload and download budgets in bytes per second.
+func (m *Manager) Limits() (upload, download int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.upload.rate, m.download.rate
+}
+
+// SetLimits sets the upload and download budgets in bytes per second. A
+// budget of zero is unlimited. The new budgets apply to the waiting
+// connections as well.
+func (m *Manager) SetLimits(upload, download int64) error {
+	if upload < 0 || download < 0 {
+		return errNegativeLimit
+	}
+	if err := m.tg.Add(); err != nil {
+		return err
+	}
+	defer m.tg.Done()
+
+	m.mu.Lock()
+	now := time.Now()
+	m.upload.setRate(upload, now)
+	m.download.setRate(download, now)
+	m.mu.Unlock()
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Usage returns the bandwidth consumption of every class, sorted by class.
+func (m *Manager) Usage() []modules.BandwidthUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	usage := make([]modules.BandwidthUsage, 0, len(m.usage))
+	for class, cu := range m.usage {
+		usage = append(usage, modules.BandwidthUsage{
+			Class:        class,
+			Weight:       weight(class),
+			Uploaded:     cu.uploaded,
+			Downloaded:   cu.downloaded,
+			UploadRate:   cu.uploadRate,
+			DownloadRate: cu.downloadRate,
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].Class < usage[j].Class
+	})
+	return usage
+}
+
+// Close stops the manager. Waiting connections are released, and the
+// connections are no longer limited.
+func (m *Manager) Close() error {
+	return m.tg.Stop()
+}
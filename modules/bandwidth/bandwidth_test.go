@@ -0,0 +1,165 @@
+package bandwidth
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// saturate reads from a connection that is limited by bl until stop
+// is closed. The other end of the connection writes as fast as it can.
+func saturate(bl *modules.BandwidthLimiter, stop <-chan struct{}) {
+	server, client := net.Pipe()
+	go func() {
+		data := fastrand.Bytes(64 << 10)
+		for {
+			if _, err := server.Write(data); err != nil {
+				return
+			}
+		}
+	}()
+	conn := bl.LimitConn(client)
+	go func() {
+		<-stop
+		conn.Close()
+		server.Close()
+	}()
+	buf := make([]byte, 64<<10)
+	for {
+		if _, err := conn.Read(buf); err != nil {
+			return
+		}
+	}
+}
+
+// TestManagerGlobalLimit checks that the combined download rate of the host,
+// renter, and gateway stays within the budget of the manager, and that the
+// budget is shared between them in the order of their weights.
+func TestManagerGlobalLimit(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	const (
+		limit    = 1 << 20
+		duration = 2 * time.Second
+	)
+	m, err := New(0, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	// Saturate the budget with two connections of every class.
+	classes := []modules.BandwidthClass{modules.BandwidthClassHost, modules.BandwidthClassRenter, modules.BandwidthClassGateway}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, class := range classes {
+		bl := modules.NewBandwidthLimiter(class)
+		bl.SetManager(m)
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				saturate(bl, stop)
+			}()
+		}
+	}
+
+	// Measure the usage after the initial burst of the bucket.
+	time.Sleep(duration / 4)
+	before := m.Usage()
+	start := time.Now()
+	time.Sleep(duration)
+	after := m.Usage()
+	elapsed := time.Since(start)
+	close(stop)
+	wg.Wait()
+
+	downloaded := make(map[modules.BandwidthClass]uint64)
+	var total uint64
+	for i, u := range after {
+		downloaded[u.Class] = u.Downloaded - before[i].Downloaded
+		total += downloaded[u.Class]
+		if u.Uploaded != 0 {
+			t.Fatal("download was counted as upload:", u)
+		}
+	}
+	rate := float64(total) / elapsed.Seconds()
+	if rate > limit*1.1 || rate < limit*0.7 {
+		t.Fatalf("combined rate of %.0f B/s is not within the tolerance of the %v B/s budget", rate, limit)
+	}
+	host, renter, gateway := downloaded[modules.BandwidthClassHost], downloaded[modules.BandwidthClassRenter], downloaded[modules.BandwidthClassGateway]
+	if !(host > renter && renter > gateway && gateway > 0) {
+		t.Fatalf("budget was not shared by weight: host %v, renter %v, gateway %v", host, renter, gateway)
+	}
+}
+
+// TestManagerSetLimits checks that the limits of the manager can be changed
+// while connections are waiting for them, and that waits can be canceled.
+func TestManagerSetLimits(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	if _, err := New(-1, 0); err != errNegativeLimit {
+		t.Fatal("expected errNegativeLimit, got", err)
+	}
+	m, err := New(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if err := m.SetLimits(0, -1); err != errNegativeLimit {
+		t.Fatal("expected errNegativeLimit, got", err)
+	}
+
+	// After the small burst of the bucket has been used, the bucket refills
+	// at 1 B/s, so the wait has to be canceled.
+	if err := m.WaitUpload(modules.BandwidthClassGateway, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	cancel := make(chan struct{})
+	errChan := make(chan error)
+	go func() {
+		errChan <- m.WaitUpload(modules.BandwidthClassHost, 1<<20, cancel)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(cancel)
+	if err := <-errChan; err != errWaitCanceled {
+		t.Fatal("expected errWaitCanceled, got", err)
+	}
+
+	// A waiting connection is released once the limit is removed.
+	go func() {
+		errChan <- m.WaitUpload(modules.BandwidthClassRenter, 1<<20, nil)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := m.SetLimits(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiting connection was not released")
+	}
+	if up, down := m.Limits(); up != 0 || down != 0 {
+		t.Fatal("wrong limits:", up, down)
+	}
+	for _, u := range m.Usage() {
+		if u.Class == modules.BandwidthClassRenter && u.Uploaded != 1<<20 {
+			t.Fatal("upload was not counted:", u)
+		} else if u.Class == modules.BandwidthClassHost && u.Uploaded != 0 {
+			t.Fatal("canceled upload was counted:", u)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package modules
 
 import (
 	"errors"
+	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -50,6 +52,20 @@ var (
 	// in a fork that is the heaviest known fork - the consensus set has not
 	// changed as a result of seeing the block.
 	ErrNonExtendingBlock = errors.New("block does not extend the longest fork")
+
+	// ErrNoSuchOutput is returned when an output is requested that is not an
+	// unspent output of the current path.
+	ErrNoSuchOutput = errors.New("output does not exist or has been spent")
+
+	// ErrTransactionIndexDisabled is returned when the location of a
+	// transaction is requested from a consensus set that does not index its
+	// transactions.
+	ErrTransactionIndexDisabled = errors.New("transaction index is not enabled")
+
+	// ErrTransactionNotInBestChain is returned when the location of a
+	// transaction is requested, but the transaction is not in any block of the
+	// current path.
+	ErrTransactionNotInBestChain = errors.New("transaction is not in best chain")
 )
 
 type (
@@ -175,6 +191,45 @@ type (
 		Adjusted  types.Currency
 	}
 
+	// A ForkEvent describes a reorg of the consensus set, in which blocks of
+	// the current path were reverted to switch to a heavier fork. The blocks
+	// are listed in the order in which they were reverted and applied.
+	ForkEvent struct {
+		RevertedBlocks []types.BlockID `json:"revertedblocks"`
+		AppliedBlocks  []types.BlockID `json:"appliedblocks"`
+		Timestamp      time.Time       `json:"timestamp"`
+	}
+
+	// ForkMetrics summarizes the reorgs of the consensus set. The depth of a
+	// reorg is the number of blocks that it reverted.
+	ForkMetrics struct {
+		ForkCount      uint64             `json:"forkcount"`
+		MaxDepth       uint64             `json:"maxdepth"`
+		AverageDepth   float64            `json:"averagedepth"`
+		DepthHistogram ForkDepthHistogram `json:"depthhistogram"`
+	}
+
+	// ForkDepthHistogram counts reorgs by their depth.
+	ForkDepthHistogram struct {
+		Depth1      uint64 `json:"depth1"`
+		Depth2To5   uint64 `json:"depth2to5"`
+		Depth6To10  uint64 `json:"depth6to10"`
+		DepthOver10 uint64 `json:"depthover10"`
+	}
+
+	// A TransactionInclusionProof proves that a transaction is in the block
+	// with id BlockID. MerklePath is the Merkle proof of the transaction's leaf
+	// in the Merkle tree of the block, whose leaves are the miner payouts of
+	// the block followed by its transactions.
+	TransactionInclusionProof struct {
+		BlockID         types.BlockID     `json:"blockid"`
+		Height          types.BlockHeight `json:"height"`
+		Index           uint64            `json:"index"`
+		NumMinerPayouts uint64            `json:"numminerpayouts"`
+		NumTransactions uint64            `json:"numtransactions"`
+		MerklePath      []crypto.Hash     `json:"merklepath"`
+	}
+
 	// A ConsensusSet accepts blocks and builds an understanding of network
 	// consensus.
 	ConsensusSet interface {
@@ -210,13 +265,30 @@ type (
 		// blockchain.
 		CurrentBlock() types.Block
 
+		// EnableTransactionIndex makes the consensus set index the
+		// transactions of its current path, so that inclusion proofs can be
+		// built for them. The index is persisted and stays enabled.
+		EnableTransactionIndex() error
+
 		// Flush will cause the consensus set to finish all in-progress
 		// routines.
 		Flush() error
 
+		// ForkHistory returns the most recent reorgs of the consensus set
+		// since it was started, oldest first.
+		ForkHistory() []ForkEvent
+
+		// ForkResolutionMetrics returns statistics about all reorgs of the
+		// consensus set since it was started.
+		ForkResolutionMetrics() ForkMetrics
+
 		// Height returns the current height of consensus.
 		Height() types.BlockHeight
 
+		// PeerChainHeights returns the height of the most recent block that
+		// each connected peer has reported as its current block.
+		PeerChainHeights() map[NetAddress]types.BlockHeight
+
 		// Synced returns true if the consensus set is synced with the network.
 		Synced() bool
 
@@ -230,10 +302,32 @@ type (
 		// risk of mining invalid blocks.
 		MinimumValidChildTimestamp(types.BlockID) (types.Timestamp, bool)
 
+		// ReplayFrom sends the subscriber a single consensus change that
+		// applies every block in the current path from the provided height to
+		// the current block. Nothing is sent if an error is returned.
+		ReplayFrom(ConsensusSetSubscriber, types.BlockHeight) error
+
+		// SiacoinOutputByID returns the unspent siacoin output with the
+		// provided id, or ErrNoSuchOutput if there is none.
+		SiacoinOutputByID(types.SiacoinOutputID) (types.SiacoinOutput, error)
+
+		// SiafundOutputByID returns the unspent siafund output with the
+		// provided id, or ErrNoSuchOutput if there is none.
+		SiafundOutputByID(types.SiafundOutputID) (types.SiafundOutput, error)
+
+		// SiafundBalance returns the number of siafunds in the unspent
+		// siafund outputs of an address, and the siacoins that spending the
+		// outputs would claim.
+		SiafundBalance(types.UnlockHash) (sfBalance, sfClaimBalance types.Currency, err error)
+
 		// StorageProofSegment returns the segment to be used in the storage proof for
 		// a given file contract.
 		StorageProofSegment(types.FileContractID) (uint64, error)
 
+		// TransactionInclusionProof returns a proof that the transaction with
+		// the provided id is in a block of the current path.
+		TransactionInclusionProof(types.TransactionID) (TransactionInclusionProof, error)
+
 		// TryTransactionSet checks whether the transaction set would be valid if
 		// it were added in the next block. A consensus change is returned
 		// detailing the diffs that would result from the application of the
@@ -261,3 +355,14 @@ func (cc ConsensusChange) Append(cc2 ConsensusChange) ConsensusChange {
 		DelayedSiacoinOutputDiffs: append(cc.DelayedSiacoinOutputDiffs, cc2.DelayedSiacoinOutputDiffs...),
 	}
 }
+
+// VerifyTransactionInclusion returns true if proof proves that txn is in the
+// block with the provided header.
+func VerifyTransactionInclusion(txn types.Transaction, proof TransactionInclusionProof, header types.BlockHeader) bool {
+	if header.ID() != proof.BlockID || proof.Index >= proof.NumTransactions {
+		return false
+	}
+	numLeaves := proof.NumMinerPayouts + proof.NumTransactions
+	leafIndex := proof.NumMinerPayouts + proof.Index
+	return crypto.VerifySegment(encoding.Marshal(txn), proof.MerklePath, numLeaves, leafIndex, header.MerkleRoot)
+}
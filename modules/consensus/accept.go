@@ -337,6 +337,7 @@ func (cs *ConsensusSet) managedAcceptBlocks(blocks []types.Block) (blockchainExt
 	// Update the subscribers with all of the consensus changes. First combine
 	// the changes into a single set.
 	for _, change := range changes {
+		cs.recordFork(change)
 		cs.updateSubscribers(change)
 	}
 
@@ -328,3 +328,134 @@ func TestBuriedBadFork(t *testing.T) {
 		t.Fatal("a bad block failed to cause an error")
 	}
 }
+
+// TestForkHistory checks that reorgs are recorded in the fork history, and
+// that only the most recent reorgs are kept.
+func TestForkHistory(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rs := createReorgSets(t.Name())
+	defer rs.Close()
+
+	rs.cstMain.testSimpleBlock()
+	if len(rs.cstMain.cs.ForkHistory()) != 0 {
+		t.Fatal("fork history is not empty before any reorg")
+	}
+
+	// Record the current path of cstMain, which should be reverted by the
+	// reorg to cstAlt.
+	var mainPath []types.BlockID
+	for i := rs.cstMain.cs.dbBlockHeight(); i > 0; i-- {
+		id, err := rs.cstMain.cs.dbGetPath(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mainPath = append(mainPath, id)
+	}
+	rs.save()
+	rs.extend()
+	history := rs.cstMain.cs.ForkHistory()
+	if len(history) != 1 {
+		t.Fatal("expected one fork event, got", len(history))
+	}
+	event := history[0]
+	if len(event.RevertedBlocks) != len(mainPath) {
+		t.Fatalf("expected %v reverted blocks, got %v", len(mainPath), len(event.RevertedBlocks))
+	}
+	for i := range mainPath {
+		if event.RevertedBlocks[i] != mainPath[i] {
+			t.Fatal("reverted blocks do not match the previous path")
+		}
+	}
+	if len(event.AppliedBlocks) == 0 || event.AppliedBlocks[len(event.AppliedBlocks)-1] != rs.cstAlt.cs.CurrentBlock().ID() {
+		t.Fatal("applied blocks do not end at the new current block")
+	}
+	if event.Timestamp.IsZero() {
+		t.Fatal("fork event has no timestamp")
+	}
+	rs.restore()
+	if len(rs.cstMain.cs.ForkHistory()) != 2 {
+		t.Fatal("restoring the original path was not recorded")
+	}
+
+	// Blocks that extend the current path are not reorgs.
+	if _, err := rs.cstMain.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rs.cstMain.cs.ForkHistory()) != 2 {
+		t.Fatal("extending the current path was recorded as a reorg")
+	}
+
+	// Once the history is full, the oldest events should be replaced.
+	cs := rs.cstMain.cs
+	cs.mu.Lock()
+	for i := 0; i < maxForkHistory; i++ {
+		cs.recordFork(changeEntry{RevertedBlocks: []types.BlockID{{byte(i)}}})
+	}
+	cs.mu.Unlock()
+	history = cs.ForkHistory()
+	if len(history) != maxForkHistory {
+		t.Fatalf("expected %v fork events, got %v", maxForkHistory, len(history))
+	}
+	for i, event := range history {
+		if event.RevertedBlocks[0] != (types.BlockID{byte(i)}) {
+			t.Fatal("fork history is not ordered oldest first")
+		}
+	}
+}
+
+// TestForkResolutionMetrics checks that every reorg is counted in the fork
+// metrics, by its depth.
+func TestForkResolutionMetrics(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rs := createReorgSets(t.Name())
+	defer rs.Close()
+
+	rs.cstMain.testSimpleBlock()
+	cs := rs.cstMain.cs
+	if m := cs.ForkResolutionMetrics(); m != (modules.ForkMetrics{}) {
+		t.Fatalf("expected empty fork metrics before any reorg, got %+v", m)
+	}
+	depth := uint64(cs.dbBlockHeight())
+	rs.save()
+	rs.extend()
+	m := cs.ForkResolutionMetrics()
+	if m.ForkCount != 1 || m.MaxDepth != depth || m.AverageDepth != float64(depth) {
+		t.Fatalf("wrong fork metrics after a reorg of depth %v: %+v", depth, m)
+	}
+
+	// Reorgs that no longer fit in the fork history are still counted.
+	before := m
+	cs.mu.Lock()
+	for _, d := range []int{1, 2, 5, 6, 10, 11} {
+		for i := 0; i < maxForkHistory; i++ {
+			cs.recordFork(changeEntry{RevertedBlocks: make([]types.BlockID, d)})
+		}
+	}
+	cs.mu.Unlock()
+	n := uint64(maxForkHistory)
+	expected := before.DepthHistogram
+	expected.Depth1 += n
+	expected.Depth2To5 += 2 * n
+	expected.Depth6To10 += 2 * n
+	expected.DepthOver10 += n
+	m = cs.ForkResolutionMetrics()
+	if m.ForkCount != before.ForkCount+6*n || m.DepthHistogram != expected {
+		t.Fatalf("wrong fork metrics: %+v", m)
+	}
+	maxDepth := uint64(11)
+	if depth > maxDepth {
+		maxDepth = depth
+	}
+	if m.MaxDepth != maxDepth {
+		t.Fatal("wrong maximum fork depth:", m.MaxDepth)
+	}
+	if avg := float64(depth+35*n) / float64(1+6*n); m.AverageDepth != avg {
+		t.Fatalf("expected an average depth of %v, got %v", avg, m.AverageDepth)
+	}
+}
@@ -81,6 +81,23 @@ type ConsensusSet struct {
 	// whether the consensus set is synced with the network.
 	synced bool
 
+	// forkHistory is a ring buffer of the most recent reorgs, holding at most
+	// maxForkHistory events. forkHistoryNext is the index of the oldest
+	// event once the buffer is full.
+	forkHistory     []modules.ForkEvent
+	forkHistoryNext int
+
+	// forkMetrics counts every reorg since the consensus set was started,
+	// including those that no longer fit in the fork history. forkDepthTotal
+	// is the sum of the depths of the reorgs.
+	forkMetrics    modules.ForkMetrics
+	forkDepthTotal uint64
+
+	// peerHeights is the height of the most recent block that each peer has
+	// reported as its current block. It is pruned of disconnected peers by
+	// PeerChainHeights.
+	peerHeights map[modules.NetAddress]types.BlockHeight
+
 	// Interfaces to abstract the dependencies of the ConsensusSet.
 	marshaler       marshaler
 	blockRuleHelper blockRuleHelper
@@ -115,7 +132,8 @@ func New(gateway modules.Gateway, bootstrap bool, persistDir string) (*Consensus
 			DiffsGenerated: true,
 		},
 
-		dosBlocks: make(map[types.BlockID]struct{}),
+		dosBlocks:   make(map[types.BlockID]struct{}),
+		peerHeights: make(map[modules.NetAddress]types.BlockHeight),
 
 		marshaler:       stdMarshaler{},
 		blockRuleHelper: stdBlockRuleHelper{},
@@ -270,6 +288,28 @@ func (cs *ConsensusSet) Flush() error {
 	return cs.tg.Flush()
 }
 
+// ForkHistory returns the most recent reorgs of the consensus set since it was
+// started, oldest first.
+func (cs *ConsensusSet) ForkHistory() []modules.ForkEvent {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	history := make([]modules.ForkEvent, 0, len(cs.forkHistory))
+	history = append(history, cs.forkHistory[cs.forkHistoryNext:]...)
+	return append(history, cs.forkHistory[:cs.forkHistoryNext]...)
+}
+
+// ForkResolutionMetrics returns statistics about the reorgs of the consensus
+// set since it was started.
+func (cs *ConsensusSet) ForkResolutionMetrics() modules.ForkMetrics {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	metrics := cs.forkMetrics
+	if metrics.ForkCount > 0 {
+		metrics.AverageDepth = float64(cs.forkDepthTotal) / float64(metrics.ForkCount)
+	}
+	return metrics
+}
+
 // Height returns the height of the consensus set.
 func (cs *ConsensusSet) Height() (height types.BlockHeight) {
 	// A call to a closed database can cause undefined behavior.
@@ -342,6 +382,78 @@ func (cs *ConsensusSet) MinimumValidChildTimestamp(id types.BlockID) (timestamp
 	return timestamp, exists
 }
 
+// SiacoinOutputByID returns the unspent siacoin output with the provided id,
+// or modules.ErrNoSuchOutput if there is none. Siacoin outputs that have not
+// matured yet, such as miner payouts, are not unspent outputs.
+func (cs *ConsensusSet) SiacoinOutputByID(id types.SiacoinOutputID) (sco types.SiacoinOutput, err error) {
+	err = cs.tg.Add()
+	if err != nil {
+		return types.SiacoinOutput{}, err
+	}
+	defer cs.tg.Done()
+
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		sco, err = getSiacoinOutput(tx, id)
+		return err
+	})
+	if err == errNilItem {
+		return types.SiacoinOutput{}, modules.ErrNoSuchOutput
+	}
+	return sco, err
+}
+
+// SiafundOutputByID returns the unspent siafund output with the provided id,
+// or modules.ErrNoSuchOutput if there is none.
+func (cs *ConsensusSet) SiafundOutputByID(id types.SiafundOutputID) (sfo types.SiafundOutput, err error) {
+	err = cs.tg.Add()
+	if err != nil {
+		return types.SiafundOutput{}, err
+	}
+	defer cs.tg.Done()
+
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		sfo, err = getSiafundOutput(tx, id)
+		return err
+	})
+	if err == errNilItem {
+		return types.SiafundOutput{}, modules.ErrNoSuchOutput
+	}
+	return sfo, err
+}
+
+// SiafundBalance returns the number of siafunds in the unspent siafund outputs
+// of addr, and the siacoins that spending the outputs would claim from the
+// siafund pool. Siafund outputs are rare, so the whole set is scanned.
+func (cs *ConsensusSet) SiafundBalance(addr types.UnlockHash) (sfBalance, sfClaimBalance types.Currency, err error) {
+	err = cs.tg.Add()
+	if err != nil {
+		return types.Currency{}, types.Currency{}, err
+	}
+	defer cs.tg.Done()
+
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		pool := getSiafundPool(tx)
+		return tx.Bucket(SiafundOutputs).ForEach(func(k, _ []byte) error {
+			var id types.SiafundOutputID
+			copy(id[:], k)
+			sfo, err := getSiafundOutput(tx, id)
+			if err != nil {
+				return err
+			}
+			if sfo.UnlockHash != addr {
+				return nil
+			}
+			sfBalance = sfBalance.Add(sfo.Value)
+			sfClaimBalance = sfClaimBalance.Add(pool.Sub(sfo.ClaimStart).Div(types.SiafundCount).Mul(sfo.Value))
+			return nil
+		})
+	})
+	if err != nil {
+		return types.Currency{}, types.Currency{}, err
+	}
+	return sfBalance, sfClaimBalance, nil
+}
+
 // StorageProofSegment returns the segment to be used in the storage proof for
 // a given file contract.
 func (cs *ConsensusSet) StorageProofSegment(fcid types.FileContractID) (index uint64, err error) {
@@ -12,6 +12,7 @@ import (
 	"github.com/NebulousLabs/Sia/modules/transactionpool"
 	"github.com/NebulousLabs/Sia/modules/wallet"
 	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
 	"github.com/NebulousLabs/fastrand"
 )
 
@@ -69,7 +70,7 @@ func (cst *consensusSetTester) addSiafunds() {
 	}
 
 	// Check that the siafunds made it to the wallet.
-	_, siafundBalance, _ := cst.wallet.ConfirmedBalance()
+	_, siafundBalance, _, _ := cst.wallet.ConfirmedBalance()
 	if !siafundBalance.Equals64(1e3) {
 		panic("wallet does not have the siafunds")
 	}
@@ -199,3 +200,115 @@ func TestDatabaseClosing(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+// TestOutputByID checks that SiacoinOutputByID and SiafundOutputByID return
+// the unspent outputs of the current path, and ErrNoSuchOutput for outputs
+// that have been spent or have not matured.
+func TestOutputByID(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	txns, err := cst.wallet.SendSiacoins(types.SiacoinPrecision, randAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn := txns[len(txns)-1]
+	if _, err := cst.cs.SiacoinOutputByID(txn.SiacoinOutputID(0)); err != modules.ErrNoSuchOutput {
+		t.Fatal("expected ErrNoSuchOutput for an unconfirmed output, got", err)
+	}
+	if _, err := cst.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	sco, err := cst.cs.SiacoinOutputByID(txn.SiacoinOutputID(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sco.Value.Cmp(txn.SiacoinOutputs[0].Value) != 0 || sco.UnlockHash != txn.SiacoinOutputs[0].UnlockHash {
+		t.Fatalf("wrong siacoin output: %+v", sco)
+	}
+	for _, sci := range txn.SiacoinInputs {
+		if _, err := cst.cs.SiacoinOutputByID(sci.ParentID); err != modules.ErrNoSuchOutput {
+			t.Fatal("expected ErrNoSuchOutput for a spent output, got", err)
+		}
+	}
+	if _, err := cst.cs.SiacoinOutputByID(cst.cs.CurrentBlock().MinerPayoutID(0)); err != modules.ErrNoSuchOutput {
+		t.Fatal("expected ErrNoSuchOutput for an immature miner payout, got", err)
+	}
+
+	// The testing genesis siafund output 2 was spent by addSiafunds.
+	genesis := cst.cs.blockRoot.Block.Transactions[0]
+	sfo, err := cst.cs.SiafundOutputByID(genesis.SiafundOutputID(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sfo.Value.Equals(genesis.SiafundOutputs[0].Value) {
+		t.Fatalf("wrong siafund output: %+v", sfo)
+	}
+	if _, err := cst.cs.SiafundOutputByID(genesis.SiafundOutputID(2)); err != modules.ErrNoSuchOutput {
+		t.Fatal("expected ErrNoSuchOutput for a spent siafund output, got", err)
+	}
+}
+
+// TestSiafundBalance checks that SiafundBalance sums the siafund outputs of
+// an address, and the siacoins that they would claim from the siafund pool.
+func TestSiafundBalance(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := blankConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	addr := types.GenesisSiafundAllocation[1].UnlockHash
+	sfBalance, sfClaimBalance, err := cst.cs.SiafundBalance(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sfBalance.Equals(types.GenesisSiafundAllocation[1].Value) || !sfClaimBalance.IsZero() {
+		t.Fatal("wrong genesis siafund balance:", sfBalance, sfClaimBalance)
+	}
+
+	// Add a second output to the address, created after the pool has grown,
+	// and grow the pool further.
+	cst.cs.db.Update(func(tx *bolt.Tx) error {
+		setSiafundPool(tx, types.SiafundCount.Mul64(3))
+		return nil
+	})
+	cst.cs.dbAddSiafundOutput(types.SiafundOutputID{1}, types.SiafundOutput{
+		Value:      types.NewCurrency64(10),
+		UnlockHash: addr,
+		ClaimStart: types.SiafundCount.Mul64(3),
+	})
+	cst.cs.db.Update(func(tx *bolt.Tx) error {
+		setSiafundPool(tx, types.SiafundCount.Mul64(5))
+		return nil
+	})
+	sfBalance, sfClaimBalance, err = cst.cs.SiafundBalance(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedBalance := types.GenesisSiafundAllocation[1].Value.Add(types.NewCurrency64(10))
+	expectedClaim := types.GenesisSiafundAllocation[1].Value.Mul64(5).Add(types.NewCurrency64(10 * 2))
+	if !sfBalance.Equals(expectedBalance) || !sfClaimBalance.Equals(expectedClaim) {
+		t.Fatalf("wrong siafund balance: expected %v and %v, got %v and %v", expectedBalance, expectedClaim, sfBalance, sfClaimBalance)
+	}
+
+	// Addresses without siafunds have a zero balance.
+	sfBalance, sfClaimBalance, err = cst.cs.SiafundBalance(randAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sfBalance.IsZero() || !sfClaimBalance.IsZero() {
+		t.Fatal("random address has a siafund balance:", sfBalance, sfClaimBalance)
+	}
+}
@@ -194,6 +194,7 @@ func commitDiffSet(tx *bolt.Tx, pb *processedBlock, dir modules.DiffDirection) {
 	commitNodeDiffs(tx, pb, dir)
 	deleteObsoleteDelayedOutputMaps(tx, pb, dir)
 	updateCurrentPath(tx, pb, dir)
+	updateTransactionIndex(tx, pb, dir)
 }
 
 // generateAndApplyDiff will verify the block and then integrate it into the
@@ -2,6 +2,7 @@ package consensus
 
 import (
 	"errors"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
@@ -11,6 +12,14 @@ import (
 
 var (
 	errExternalRevert = errors.New("cannot revert to block outside of current path")
+
+	// maxForkHistory is the number of reorgs that are kept in the fork
+	// history of the consensus set.
+	maxForkHistory = build.Select(build.Var{
+		Dev:      50,
+		Standard: 100,
+		Testing:  5,
+	}).(int)
 )
 
 // backtrackToCurrentPath traces backwards from 'pb' until it reaches a block
@@ -114,3 +123,43 @@ func (cs *ConsensusSet) forkBlockchain(tx *bolt.Tx, newBlock *processedBlock) (r
 	}
 	return revertedBlocks, appliedBlocks, nil
 }
+
+// recordForkDepth adds a reorg that reverted depth blocks to the fork metrics.
+func (cs *ConsensusSet) recordForkDepth(depth uint64) {
+	cs.forkMetrics.ForkCount++
+	cs.forkDepthTotal += depth
+	if depth > cs.forkMetrics.MaxDepth {
+		cs.forkMetrics.MaxDepth = depth
+	}
+	switch {
+	case depth <= 1:
+		cs.forkMetrics.DepthHistogram.Depth1++
+	case depth <= 5:
+		cs.forkMetrics.DepthHistogram.Depth2To5++
+	case depth <= 10:
+		cs.forkMetrics.DepthHistogram.Depth6To10++
+	default:
+		cs.forkMetrics.DepthHistogram.DepthOver10++
+	}
+}
+
+// recordFork adds the change to the fork history if it reverted blocks,
+// replacing the oldest event once the history is full.
+func (cs *ConsensusSet) recordFork(ce changeEntry) {
+	if len(ce.RevertedBlocks) == 0 {
+		return
+	}
+	event := modules.ForkEvent{
+		RevertedBlocks: ce.RevertedBlocks,
+		AppliedBlocks:  ce.AppliedBlocks,
+		Timestamp:      time.Now(),
+	}
+	cs.log.Printf("Reorg: reverted %v blocks, applied %v blocks\n", len(ce.RevertedBlocks), len(ce.AppliedBlocks))
+	cs.recordForkDepth(uint64(len(ce.RevertedBlocks)))
+	if len(cs.forkHistory) < maxForkHistory {
+		cs.forkHistory = append(cs.forkHistory, event)
+		return
+	}
+	cs.forkHistory[cs.forkHistoryNext] = event
+	cs.forkHistoryNext = (cs.forkHistoryNext + 1) % maxForkHistory
+}
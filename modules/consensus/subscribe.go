@@ -1,13 +1,22 @@
 package consensus
 
 import (
+	"errors"
+
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 
 	siasync "github.com/NebulousLabs/Sia/sync"
 	"github.com/NebulousLabs/bolt"
 )
 
+var (
+	// errReplayHeight is returned by ReplayFrom if the replay would start
+	// above the current height of the consensus set.
+	errReplayHeight = errors.New("cannot replay from a height above the current block height")
+)
+
 // computeConsensusChange computes the consensus change from the change entry
 // at index 'i' in the change log. If i is out of bounds, an error is returned.
 func (cs *ConsensusSet) computeConsensusChange(tx *bolt.Tx, ce changeEntry) (modules.ConsensusChange, error) {
@@ -262,3 +271,53 @@ func (cs *ConsensusSet) Unsubscribe(subscriber modules.ConsensusSetSubscriber) {
 		}
 	}
 }
+
+// ReplayFrom sends the subscriber a consensus change that applies every block
+// in the current path from the block at 'height' to the current block. This
+// allows a subscriber to rebuild its state from a past block.
+//
+// The replay is transactional: the whole replay is computed before anything is
+// sent, and it is delivered as a single consensus change. If the replay cannot
+// be computed, an error is returned and the subscriber receives nothing. A
+// subscriber that processes each consensus change atomically will therefore
+// either apply the whole replay or none of it. The ID of the change is the ID
+// of the most recent change in the change log, so that a subscriber which
+// persists the ID can resubscribe from it later.
+func (cs *ConsensusSet) ReplayFrom(subscriber modules.ConsensusSetSubscriber, height types.BlockHeight) error {
+	err := cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cs.tg.Done()
+
+	// The lock is held while the change is sent so that no other change can
+	// reach the subscriber in the middle of the replay.
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	var cc modules.ConsensusChange
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		currentHeight := blockHeight(tx)
+		if height > currentHeight {
+			return errReplayHeight
+		}
+		var ce changeEntry
+		for h := height; h <= currentHeight; h++ {
+			id, err := getPath(tx, h)
+			if err != nil {
+				return err
+			}
+			ce.AppliedBlocks = append(ce.AppliedBlocks, id)
+		}
+		cc, err = cs.computeConsensusChange(tx, ce)
+		if err != nil {
+			return err
+		}
+		copy(cc.ID[:], tx.Bucket(ChangeLog).Get(ChangeLogTailID))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	subscriber.ProcessConsensusChange(cc)
+	return nil
+}
@@ -148,3 +148,67 @@ func TestUnsubscribe(t *testing.T) {
 		t.Error("mock subscriber was not correctly unsubscribed")
 	}
 }
+
+// TestReplayFrom checks that ReplayFrom sends a single consensus change that
+// matches the changes the subscriber received for the replayed blocks.
+func TestReplayFrom(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// Subscribe a mock subscriber so that it has a record of every change.
+	ms := newMockSubscriber()
+	err = cst.cs.ConsensusSetSubscribe(&ms, modules.ConsensusChangeBeginning, cst.cs.tg.StopChan())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.cs.Unsubscribe(&ms)
+
+	// Replay the chain from the middle into a second subscriber.
+	height := cst.cs.Height() / 2
+	replay := newMockSubscriber()
+	err = cst.cs.ReplayFrom(&replay, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replay.updates) != 1 {
+		t.Fatal("expected the replay to be a single consensus change, got", len(replay.updates))
+	}
+
+	// The test chain has no reorgs, so the change for every block is in
+	// ms.updates at the index of its height.
+	var expected modules.ConsensusChange
+	for _, cc := range ms.updates[height:] {
+		expected = expected.Append(cc)
+	}
+	cc := replay.updates[0]
+	if len(cc.RevertedBlocks) != 0 || len(cc.AppliedBlocks) != len(expected.AppliedBlocks) {
+		t.Fatal("replay has the wrong blocks:", len(cc.RevertedBlocks), len(cc.AppliedBlocks))
+	}
+	for i := range cc.AppliedBlocks {
+		if cc.AppliedBlocks[i].ID() != expected.AppliedBlocks[i].ID() {
+			t.Fatal("replay applied the wrong block at index", i)
+		}
+	}
+	if len(cc.SiacoinOutputDiffs) != len(expected.SiacoinOutputDiffs) || len(cc.DelayedSiacoinOutputDiffs) != len(expected.DelayedSiacoinOutputDiffs) {
+		t.Error("replay has the wrong diffs")
+	}
+	if cc.ID != ms.updates[len(ms.updates)-1].ID {
+		t.Error("replay should have the id of the most recent consensus change")
+	}
+
+	// Replaying from above the current height should not send anything.
+	err = cst.cs.ReplayFrom(&replay, cst.cs.Height()+1)
+	if err != errReplayHeight {
+		t.Fatal("expected errReplayHeight, got", err)
+	}
+	if len(replay.updates) != 1 {
+		t.Error("failed replay was sent to the subscriber")
+	}
+}
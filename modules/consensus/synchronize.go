@@ -206,6 +206,7 @@ func (cs *ConsensusSet) managedReceiveBlocks(conn modules.PeerConn) (returnErr e
 	// Read blocks off of the wire and add them to the consensus set until
 	// there are no more blocks available.
 	moreAvailable := true
+	var lastBlock types.BlockID
 	for moreAvailable {
 		// Read a slice of blocks from the wire.
 		var newBlocks []types.Block
@@ -219,6 +220,7 @@ func (cs *ConsensusSet) managedReceiveBlocks(conn modules.PeerConn) (returnErr e
 			continue
 		}
 		stalled = false
+		lastBlock = newBlocks[len(newBlocks)-1].ID()
 
 		// Call managedAcceptBlock instead of AcceptBlock so as not to broadcast
 		// every block.
@@ -233,6 +235,11 @@ func (cs *ConsensusSet) managedReceiveBlocks(conn modules.PeerConn) (returnErr e
 			return acceptErr
 		}
 	}
+	// The last block that the peer sent is its current block. If the peer
+	// sent no blocks, its current block is unknown.
+	if lastBlock != (types.BlockID{}) {
+		cs.managedRecordPeerBlock(conn.RPCAddr(), lastBlock, 0)
+	}
 	return nil
 }
 
@@ -291,6 +298,8 @@ func (cs *ConsensusSet) rpcSendBlocks(conn modules.PeerConn) error {
 	if err != nil {
 		return err
 	}
+	// The first of the known blocks is the current block of the requester.
+	cs.managedRecordPeerBlock(conn.RPCAddr(), knownBlocks[0], 0)
 
 	// Find the most recent block from knownBlocks in the current path.
 	found := false
@@ -426,6 +435,11 @@ func (cs *ConsensusSet) threadedRPCRelayHeader(conn modules.PeerConn) error {
 		return cs.validateHeader(boltTxWrapper{tx}, h)
 	})
 	cs.mu.RUnlock()
+	if err == nil || err == modules.ErrBlockKnown {
+		// The peer relays the header of its new current block, and the
+		// parent of a valid header is known.
+		cs.managedRecordPeerBlock(conn.RPCAddr(), h.ParentID, 1)
+	}
 	// WARN: orphan multithreading logic (dangerous areas, see below)
 	//
 	// If the header is valid and extends the heaviest chain, fetch the
@@ -628,6 +642,50 @@ func (cs *ConsensusSet) threadedInitialBlockchainDownload() error {
 	return nil
 }
 
+// managedRecordPeerBlock records the height of the current block of the peer
+// at addr, which is depth blocks above the block with the provided id. Blocks
+// that are not in the block map are ignored, because their height is unknown.
+func (cs *ConsensusSet) managedRecordPeerBlock(addr modules.NetAddress, id types.BlockID, depth types.BlockHeight) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	_ = cs.db.View(func(tx *bolt.Tx) error {
+		pb, err := getBlockMap(tx, id)
+		if err == nil {
+			cs.peerHeights[addr] = pb.Height + depth
+		}
+		return nil
+	})
+}
+
+// PeerChainHeights returns the height of the most recent block that each
+// connected peer has reported as its current block. Peers are included once
+// they have relayed a block or exchanged blocks with the consensus set.
+func (cs *ConsensusSet) PeerChainHeights() map[modules.NetAddress]types.BlockHeight {
+	if err := cs.tg.Add(); err != nil {
+		return nil
+	}
+	defer cs.tg.Done()
+
+	// Forget the heights of peers that have disconnected. The gateway is
+	// queried before acquiring the lock, because the gateway calls into the
+	// consensus set while holding its own lock.
+	connected := make(map[modules.NetAddress]struct{})
+	for _, p := range cs.gateway.Peers() {
+		connected[p.NetAddress] = struct{}{}
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	heights := make(map[modules.NetAddress]types.BlockHeight, len(cs.peerHeights))
+	for addr, height := range cs.peerHeights {
+		if _, ok := connected[addr]; !ok {
+			delete(cs.peerHeights, addr)
+			continue
+		}
+		heights[addr] = height
+	}
+	return heights
+}
+
 // Synced returns true if the consensus set is synced with the network.
 func (cs *ConsensusSet) Synced() bool {
 	err := cs.tg.Add()
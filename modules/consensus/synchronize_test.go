@@ -1476,6 +1476,75 @@ func TestThreadedReceiveBlocksStalls(t *testing.T) {
 	// TODO: Test that threadedReceiveBlocks doesn't error with errSendBlocksStalled if it successfully received one block.
 }
 
+// TestPeerChainHeights checks that the consensus set records the heights that
+// peers report while synchronizing and relaying blocks, and forgets them when
+// the peers disconnect.
+func TestPeerChainHeights(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst1, err := blankConsensusSetTester(t.Name() + "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst1.Close()
+	cst2, err := blankConsensusSetTester(t.Name() + "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst2.Close()
+	if len(cst1.cs.PeerChainHeights()) != 0 {
+		t.Fatal("expected no peer heights before connecting")
+	}
+
+	checkHeight := func(height types.BlockHeight) error {
+		return build.Retry(50, 100*time.Millisecond, func() error {
+			heights := cst1.cs.PeerChainHeights()
+			if h, ok := heights[cst2.gateway.Address()]; !ok || h != height {
+				return fmt.Errorf("expected height %v for the peer, got %v", height, heights)
+			}
+			return nil
+		})
+	}
+
+	// Synchronizing with a peer should record the height of its current
+	// block.
+	for i := 0; i < 3; i++ {
+		if _, err := cst2.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cst1.gateway.Connect(cst2.gateway.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkHeight(cst2.cs.Height()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Relaying a block should update the height.
+	if _, err := cst2.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkHeight(cst2.cs.Height()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Disconnected peers should be forgotten.
+	if err := cst1.gateway.Disconnect(cst2.gateway.Address()); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		if heights := cst1.cs.PeerChainHeights(); len(heights) != 0 {
+			return fmt.Errorf("expected no peer heights after disconnecting, got %v", heights)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 // TestIntegrationSendBlocksStalls tests that the SendBlocks RPC fails with
 // errSendBlockStalled when the RPC timesout and the requesting end has
 // received 0 blocks.
@@ -0,0 +1,194 @@
+package consensus
+
+// txnindex.go implements the optional transaction index of the consensus set.
+// When the index is enabled, the location of every transaction in the current
+// path is kept in the database, and is updated as blocks are applied and
+// reverted. Transactions of reverted blocks are therefore never reported as
+// confirmed, and a transaction that is reverted and applied again in a new
+// block is reported at its new location.
+
+import (
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// TransactionIndex is a database bucket that maps the ids of the
+	// transactions in the current path to their locations. The bucket only
+	// exists if the transaction index is enabled.
+	TransactionIndex = []byte("TransactionIndex")
+)
+
+// txnLocation is the position of a transaction within a block.
+type txnLocation struct {
+	BlockID types.BlockID
+	Index   uint64
+}
+
+// getTxnLocations returns the locations of the transaction with the provided
+// id in the current path, oldest first. Transactions without inputs can
+// appear in more than one block, so there may be multiple locations.
+func getTxnLocations(b *bolt.Bucket, id types.TransactionID) []txnLocation {
+	var locations []txnLocation
+	if locBytes := b.Get(id[:]); locBytes != nil {
+		err := encoding.Unmarshal(locBytes, &locations)
+		if build.DEBUG && err != nil {
+			panic(err)
+		}
+	}
+	return locations
+}
+
+// putTxnLocations sets the locations of the transaction with the provided id,
+// removing the transaction from the index if there are none.
+func putTxnLocations(b *bolt.Bucket, id types.TransactionID, locations []txnLocation) {
+	var err error
+	if len(locations) == 0 {
+		err = b.Delete(id[:])
+	} else {
+		err = b.Put(id[:], encoding.Marshal(locations))
+	}
+	if build.DEBUG && err != nil {
+		panic(err)
+	}
+}
+
+// indexBlockTransactions adds the transactions of pb to the transaction index.
+func indexBlockTransactions(b *bolt.Bucket, pb *processedBlock) {
+	bid := pb.Block.ID()
+	for i, txn := range pb.Block.Transactions {
+		id := txn.ID()
+		locations := append(getTxnLocations(b, id), txnLocation{
+			BlockID: bid,
+			Index:   uint64(i),
+		})
+		putTxnLocations(b, id, locations)
+	}
+}
+
+// updateTransactionIndex adds the transactions of pb to the transaction index
+// if pb is being applied, and removes them if pb is being reverted. Nothing
+// happens if the index is not enabled.
+func updateTransactionIndex(tx *bolt.Tx, pb *processedBlock, dir modules.DiffDirection) {
+	b := tx.Bucket(TransactionIndex)
+	if b == nil {
+		return
+	}
+	if dir == modules.DiffApply {
+		indexBlockTransactions(b, pb)
+		return
+	}
+	bid := pb.Block.ID()
+	for _, txn := range pb.Block.Transactions {
+		id := txn.ID()
+		var locations []txnLocation
+		for _, loc := range getTxnLocations(b, id) {
+			if loc.BlockID != bid {
+				locations = append(locations, loc)
+			}
+		}
+		putTxnLocations(b, id, locations)
+	}
+}
+
+// transactionInclusionProof builds the inclusion proof of the transaction with
+// the provided id.
+func transactionInclusionProof(tx *bolt.Tx, id types.TransactionID) (modules.TransactionInclusionProof, error) {
+	b := tx.Bucket(TransactionIndex)
+	if b == nil {
+		return modules.TransactionInclusionProof{}, modules.ErrTransactionIndexDisabled
+	}
+	locations := getTxnLocations(b, id)
+	if len(locations) == 0 {
+		return modules.TransactionInclusionProof{}, modules.ErrTransactionNotInBestChain
+	}
+	loc := locations[0]
+	pb, err := getBlockMap(tx, loc.BlockID)
+	if err != nil {
+		return modules.TransactionInclusionProof{}, err
+	}
+
+	// Build the Merkle tree of the block the same way as Block.MerkleRoot.
+	numPayouts := uint64(len(pb.Block.MinerPayouts))
+	tree := crypto.NewTree()
+	tree.SetIndex(numPayouts + loc.Index)
+	for _, payout := range pb.Block.MinerPayouts {
+		tree.PushObject(payout)
+	}
+	for _, txn := range pb.Block.Transactions {
+		tree.PushObject(txn)
+	}
+	_, proofSet, _, _ := tree.Prove()
+	path := make([]crypto.Hash, len(proofSet)-1)
+	for i, p := range proofSet[1:] {
+		copy(path[i][:], p)
+	}
+	return modules.TransactionInclusionProof{
+		BlockID:         loc.BlockID,
+		Height:          pb.Height,
+		Index:           loc.Index,
+		NumMinerPayouts: numPayouts,
+		NumTransactions: uint64(len(pb.Block.Transactions)),
+		MerklePath:      path,
+	}, nil
+}
+
+// EnableTransactionIndex creates the transaction index and adds the
+// transactions of the current path to it. Once enabled, the index is kept up
+// to date with the current path, also across restarts. Enabling an index that
+// is already enabled has no effect.
+func (cs *ConsensusSet) EnableTransactionIndex() error {
+	err := cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cs.tg.Done()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(TransactionIndex) != nil {
+			return nil
+		}
+		b, err := tx.CreateBucket(TransactionIndex)
+		if err != nil {
+			return err
+		}
+		for height := types.BlockHeight(0); height <= blockHeight(tx); height++ {
+			id, err := getPath(tx, height)
+			if err != nil {
+				return err
+			}
+			pb, err := getBlockMap(tx, id)
+			if err != nil {
+				return err
+			}
+			indexBlockTransactions(b, pb)
+		}
+		return nil
+	})
+}
+
+// TransactionInclusionProof returns a proof that the transaction with the
+// provided id is in a block of the current path. If the transaction is in
+// more than one block, the proof is for the oldest one.
+func (cs *ConsensusSet) TransactionInclusionProof(id types.TransactionID) (proof modules.TransactionInclusionProof, err error) {
+	err = cs.tg.Add()
+	if err != nil {
+		return modules.TransactionInclusionProof{}, err
+	}
+	defer cs.tg.Done()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		proof, err = transactionInclusionProof(tx, id)
+		return err
+	})
+	return proof, err
+}
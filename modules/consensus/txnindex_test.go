@@ -0,0 +1,169 @@
+package consensus
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/gateway"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// checkInclusionProof checks that the consensus set of cst proves that txn is
+// in its current path.
+func (cst *consensusSetTester) checkInclusionProof(txn types.Transaction) (modules.TransactionInclusionProof, error) {
+	proof, err := cst.cs.TransactionInclusionProof(txn.ID())
+	if err != nil {
+		return proof, err
+	}
+	block, exists := cst.cs.BlockAtHeight(proof.Height)
+	if !exists || block.ID() != proof.BlockID {
+		return proof, errors.New("proof is not for a block of the current path")
+	}
+	if block.Transactions[proof.Index].ID() != txn.ID() {
+		return proof, errors.New("proof has the wrong transaction index")
+	}
+	if !modules.VerifyTransactionInclusion(txn, proof, block.Header()) {
+		return proof, errors.New("valid proof was rejected")
+	}
+	return proof, nil
+}
+
+// TestTransactionInclusionProof checks that inclusion proofs can be built for
+// the transactions of the current path once the transaction index is enabled.
+func TestTransactionInclusionProof(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// Confirm a transaction before the index is enabled.
+	txns, err := cst.wallet.SendSiacoins(types.SiacoinPrecision, randAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := txns[len(txns)-1]
+	if _, err := cst.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cst.cs.TransactionInclusionProof(before.ID()); err != modules.ErrTransactionIndexDisabled {
+		t.Fatal("expected ErrTransactionIndexDisabled, got", err)
+	}
+
+	// Enabling the index should add the transactions that are already in the
+	// current path.
+	if err := cst.cs.EnableTransactionIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cst.cs.EnableTransactionIndex(); err != nil {
+		t.Fatal(err)
+	}
+	genesis := cst.cs.blockRoot.Block.Transactions[0]
+	if _, err := cst.checkInclusionProof(genesis); err != nil {
+		t.Fatal(err)
+	}
+	proof, err := cst.checkInclusionProof(before)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Proofs should not verify for other transactions or other positions.
+	block, _ := cst.cs.BlockAtHeight(proof.Height)
+	tampered := before
+	tampered.ArbitraryData = append(tampered.ArbitraryData, []byte("tampered"))
+	if modules.VerifyTransactionInclusion(tampered, proof, block.Header()) {
+		t.Error("proof verified for a different transaction")
+	}
+	badIndex := proof
+	badIndex.Index = proof.NumTransactions
+	if modules.VerifyTransactionInclusion(before, badIndex, block.Header()) {
+		t.Error("proof verified for an out of bounds index")
+	}
+	parent, _ := cst.cs.BlockAtHeight(proof.Height - 1)
+	if modules.VerifyTransactionInclusion(before, proof, parent.Header()) {
+		t.Error("proof verified against the wrong block")
+	}
+
+	// Transactions confirmed after the index is enabled should be indexed.
+	txns, err = cst.wallet.SendSiacoins(types.SiacoinPrecision, randAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := txns[len(txns)-1]
+	if _, err := cst.cs.TransactionInclusionProof(after.ID()); err != modules.ErrTransactionNotInBestChain {
+		t.Fatal("expected ErrTransactionNotInBestChain for an unconfirmed transaction, got", err)
+	}
+	if _, err := cst.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cst.checkInclusionProof(after); err != nil {
+		t.Fatal(err)
+	}
+
+	// The index should stay enabled after a restart.
+	cst.cs.Close()
+	g, err := gateway.New("localhost:0", false, build.TempDir(modules.ConsensusDir, t.Name(), "restart", modules.GatewayDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cst.cs, err = New(g, false, filepath.Join(cst.persistDir, modules.ConsensusDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cst.checkInclusionProof(after); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestTransactionInclusionProofReorg checks that the transactions of reverted
+// blocks are removed from the transaction index.
+func TestTransactionInclusionProofReorg(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rs := createReorgSets(t.Name())
+	defer rs.Close()
+
+	if err := rs.cstMain.cs.EnableTransactionIndex(); err != nil {
+		t.Fatal(err)
+	}
+	txns, err := rs.cstMain.wallet.SendSiacoins(types.SiacoinPrecision, randAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn := txns[len(txns)-1]
+	if _, err := rs.cstMain.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	proof, err := rs.cstMain.checkInclusionProof(txn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// After a reorg to a chain that does not contain the transaction, the
+	// transaction should not be in the best chain.
+	rs.save()
+	rs.extend()
+	if _, err := rs.cstMain.cs.TransactionInclusionProof(txn.ID()); err != modules.ErrTransactionNotInBestChain {
+		t.Fatal("expected ErrTransactionNotInBestChain after the reorg, got", err)
+	}
+
+	// After reorging back, the transaction should be found in its original
+	// block again.
+	rs.restore()
+	restored, err := rs.cstMain.checkInclusionProof(txn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.BlockID != proof.BlockID || restored.Index != proof.Index {
+		t.Fatal("transaction was not restored to its original location")
+	}
+}
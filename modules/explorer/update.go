@@ -499,10 +499,10 @@ func dbCalculateBlockFacts(tx *bolt.Tx, cs modules.ConsensusSet, block types.Blo
 
 	bf.MinerPayoutCount += uint64(len(block.MinerPayouts))
 	bf.TransactionCount += uint64(len(block.Transactions))
+	bf.SiacoinOutputCount += uint64(block.SiacoinOutputCount())
+	bf.FileContractCount += uint64(block.FileContractCount())
 	for _, txn := range block.Transactions {
 		bf.SiacoinInputCount += uint64(len(txn.SiacoinInputs))
-		bf.SiacoinOutputCount += uint64(len(txn.SiacoinOutputs))
-		bf.FileContractCount += uint64(len(txn.FileContracts))
 		bf.FileContractRevisionCount += uint64(len(txn.FileContractRevisions))
 		bf.StorageProofCount += uint64(len(txn.StorageProofs))
 		bf.SiafundInputCount += uint64(len(txn.SiafundInputs))
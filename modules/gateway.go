@@ -2,6 +2,7 @@ package modules
 
 import (
 	"net"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 )
@@ -72,6 +73,33 @@ type (
 		Version    string     `json:"version"`
 	}
 
+	// PeerStats contains the lifetime statistics that the gateway has
+	// collected about a peer. Peers are identified by the address that they
+	// can be dialed on.
+	PeerStats struct {
+		NetAddress NetAddress `json:"netaddress"`
+		Connected  bool       `json:"connected"`
+		Preferred  bool       `json:"preferred"`
+
+		// ConnectedTime includes the duration of the current connection.
+		ConnectedTime                time.Duration     `json:"connectedtime"`
+		BlocksFirstReceived          uint64            `json:"blocksfirstreceived"`
+		TransactionSetsFirstReceived uint64            `json:"transactionsetsfirstreceived"`
+		DisconnectReasons            map[string]uint64 `json:"disconnectreasons"`
+		AverageRTT                   time.Duration     `json:"averagertt"`
+
+		FirstSeen time.Time `json:"firstseen"`
+		LastSeen  time.Time `json:"lastseen"`
+	}
+
+	// PeerLatencyEntry contains the round trip time of a ping to a connected
+	// peer. If the ping failed, Error is set and Latency is zero.
+	PeerLatencyEntry struct {
+		NetAddress NetAddress    `json:"netaddress"`
+		Latency    time.Duration `json:"latency"`
+		Error      string        `json:"error,omitempty"`
+	}
+
 	// A PeerConn is the connection type used when communicating with peers during
 	// an RPC. It is identical to a net.Conn with the additional RPCAddr method.
 	// This method acts as an identifier for peers and is the address that the
@@ -106,6 +134,33 @@ type (
 		// Peers returns the addresses that the Gateway is currently connected to.
 		Peers() []Peer
 
+		// OnlineSince returns the time of the first successful outbound peer
+		// connection in the current session, or the zero time if there was
+		// none.
+		OnlineSince() time.Time
+
+		// PeerReport returns the lifetime statistics of every peer that the
+		// Gateway knows about, ranked from most to least useful.
+		PeerReport() []PeerStats
+
+		// PreferredPeers returns the number of top ranked peers that the
+		// Gateway keeps as outbound peers.
+		PreferredPeers() int
+
+		// SetPreferredPeers sets the number of top ranked peers that the
+		// Gateway keeps as outbound peers. Preferred peers are reconnected
+		// whenever they are not connected, including right after startup.
+		SetPreferredPeers(int) error
+
+		// PeerLatency pings a connected peer and returns the round trip time
+		// of the ping.
+		PeerLatency(NetAddress) (time.Duration, error)
+
+		// LatencyRanking pings every connected peer and returns their round
+		// trip times, from fastest to slowest. Peers that could not be
+		// pinged are listed last.
+		LatencyRanking() []PeerLatencyEntry
+
 		// RegisterRPC registers a function to handle incoming connections that
 		// supply the given RPC ID.
 		RegisterRPC(string, RPCFunc)
@@ -32,5 +32,5 @@ func (g *Gateway) dial(addr modules.NetAddress) (net.Conn, error) {
 		return nil, err
 	}
 	conn.SetDeadline(time.Now().Add(connStdDeadline))
-	return conn, nil
+	return g.bandwidthLimiter.LimitConn(conn), nil
 }
@@ -84,6 +84,22 @@ var (
 		Testing:  500 * time.Millisecond,
 	}).(time.Duration)
 
+	// maxPeerStats defines the maximum number of peers whose statistics are
+	// kept by the gateway.
+	maxPeerStats = build.Select(build.Var{
+		Standard: int(1000),
+		Dev:      int(200),
+		Testing:  int(20),
+	}).(int)
+
+	// peerStatsExpiration defines how long the statistics of a peer are kept
+	// after the gateway has last seen the peer.
+	peerStatsExpiration = build.Select(build.Var{
+		Standard: 90 * 24 * time.Hour,
+		Dev:      24 * time.Hour,
+		Testing:  time.Minute,
+	}).(time.Duration)
+
 	// peerRPCDelay defines the amount of time waited between each RPC accepted
 	// from a peer. Without this delay, a peer can force us to spin up thousands
 	// of goroutines per second.
@@ -159,6 +175,14 @@ var (
 		Testing:  3 * time.Second,
 	}).(time.Duration)
 
+	// preferredPeerDelay defines the amount of time that is waited between
+	// attempts to reconnect to preferred peers that are not connected.
+	preferredPeerDelay = build.Select(build.Var{
+		Standard: 1 * time.Minute,
+		Dev:      10 * time.Second,
+		Testing:  500 * time.Millisecond,
+	}).(time.Duration)
+
 	// unwawntedLocalPeerDelay defines the amount of time that is waited
 	// between iterations of the permanentPeerManager if the gateway has at
 	// least a few outbound peers, but is not well connected, and the recently
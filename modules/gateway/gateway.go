@@ -145,6 +145,20 @@ type Gateway struct {
 	peers  map[modules.NetAddress]*peer
 	peerTG siasync.ThreadGroup
 
+	// peerStats contains the lifetime statistics of known peers, and
+	// preferredPeers is the number of top ranked peers that the gateway keeps
+	// as outbound peers.
+	peerStats      map[modules.NetAddress]*peerStats
+	preferredPeers int
+
+	// onlineSince is the time of the first successful outbound connection in
+	// the current session. It is not persisted.
+	onlineSince time.Time
+
+	// bandwidthLimiter limits the connections of peers by the node's
+	// bandwidth budget.
+	bandwidthLimiter *modules.BandwidthLimiter
+
 	// Utilities.
 	log        *persist.Logger
 	mu         sync.RWMutex
@@ -176,6 +190,12 @@ func (g *Gateway) Address() modules.NetAddress {
 	return g.myAddr
 }
 
+// SetBandwidthManager sets the manager of the node's bandwidth budget that the
+// connections of peers draw from. A nil manager stops limiting them.
+func (g *Gateway) SetBandwidthManager(bm modules.BandwidthManager) {
+	g.bandwidthLimiter.SetManager(bm)
+}
+
 // Close saves the state of the Gateway and stops its listener process.
 func (g *Gateway) Close() error {
 	if err := g.threads.Stop(); err != nil {
@@ -198,8 +218,11 @@ func New(addr string, bootstrap bool, persistDir string) (*Gateway, error) {
 		handlers: make(map[rpcID]modules.RPCFunc),
 		initRPCs: make(map[string]modules.RPCFunc),
 
-		nodes: make(map[modules.NetAddress]*node),
-		peers: make(map[modules.NetAddress]*peer),
+		nodes:     make(map[modules.NetAddress]*node),
+		peers:     make(map[modules.NetAddress]*peer),
+		peerStats: make(map[modules.NetAddress]*peerStats),
+
+		bandwidthLimiter: modules.NewBandwidthLimiter(modules.BandwidthClassGateway),
 
 		persistDir: persistDir,
 	}
@@ -234,10 +257,12 @@ func New(addr string, bootstrap bool, persistDir string) (*Gateway, error) {
 	// Register RPCs.
 	g.RegisterRPC("ShareNodes", g.shareNodes)
 	g.RegisterConnectCall("ShareNodes", g.requestNodes)
+	g.RegisterRPC("Ping", g.rpcPing)
 	// Establish the de-registration of the RPCs.
 	g.threads.OnStop(func() {
 		g.UnregisterRPC("ShareNodes")
 		g.UnregisterConnectCall("ShareNodes")
+		g.UnregisterRPC("Ping")
 	})
 
 	// Load the old node list. If it doesn't exist, no problem, but if it does,
@@ -245,6 +270,9 @@ func New(addr string, bootstrap bool, persistDir string) (*Gateway, error) {
 	if loadErr := g.load(); loadErr != nil && !os.IsNotExist(loadErr) {
 		return nil, loadErr
 	}
+	if loadErr := g.loadPeerStats(); loadErr != nil && !os.IsNotExist(loadErr) {
+		return nil, loadErr
+	}
 	// Spawn the thread to periodically save the gateway.
 	go g.threadedSaveLoop()
 	// Make sure that the gateway saves after shutdown.
@@ -314,6 +342,14 @@ func New(addr string, bootstrap bool, persistDir string) (*Gateway, error) {
 	})
 	go g.permanentNodePurger(nodePurgerClosedChan)
 
+	// Spawn the preferred peer manager and provide tools for ensuring clean
+	// shutdown.
+	preferredPeerManagerClosedChan := make(chan struct{})
+	g.threads.OnStop(func() {
+		<-preferredPeerManagerClosedChan
+	})
+	go g.permanentPreferredPeerManager(preferredPeerManagerClosedChan)
+
 	// Spawn threads to take care of port forwarding and hostname discovery.
 	go g.threadedForwardPort(g.port)
 	go g.threadedLearnHostname()
@@ -0,0 +1,109 @@
+package gateway
+
+// latency.go measures the round trip times of the gateway's peers. A ping
+// sends the current time to the peer, which echoes it back. Peers that do not
+// support the ping RPC close the stream instead, which fails the ping without
+// disconnecting the peer. Every measurement is added to the statistics of the
+// peer, so they are included in the average round trip time of the peer
+// report.
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// errBadPingEcho is returned if a peer does not echo the timestamp of a ping.
+var errBadPingEcho = errors.New("peer did not echo the ping")
+
+// rpcPing is an RPC that echoes the timestamp sent by the caller.
+func (g *Gateway) rpcPing(conn modules.PeerConn) error {
+	conn.SetDeadline(time.Now().Add(connStdDeadline))
+	var timestamp int64
+	if err := encoding.ReadObject(conn, &timestamp, 8); err != nil {
+		return err
+	}
+	return encoding.WriteObject(conn, timestamp)
+}
+
+// managedPeerLatency pings the peer at addr and records the round trip time of
+// the ping.
+func (g *Gateway) managedPeerLatency(addr modules.NetAddress) (time.Duration, error) {
+	var rtt time.Duration
+	err := g.managedRPC(addr, "Ping", func(conn modules.PeerConn) error {
+		conn.SetDeadline(time.Now().Add(connStdDeadline))
+		start := time.Now()
+		timestamp := start.UnixNano()
+		if err := encoding.WriteObject(conn, timestamp); err != nil {
+			return err
+		}
+		var echo int64
+		if err := encoding.ReadObject(conn, &echo, 8); err != nil {
+			return err
+		}
+		rtt = time.Since(start)
+		if echo != timestamp {
+			return errBadPingEcho
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	g.mu.Lock()
+	g.recordPeerRTT(addr, rtt)
+	g.mu.Unlock()
+	return rtt, nil
+}
+
+// PeerLatency pings the connected peer at addr and returns the round trip time
+// of the ping.
+func (g *Gateway) PeerLatency(addr modules.NetAddress) (time.Duration, error) {
+	if err := g.threads.Add(); err != nil {
+		return 0, err
+	}
+	defer g.threads.Done()
+	return g.managedPeerLatency(addr)
+}
+
+// LatencyRanking pings every connected peer in parallel and returns their
+// round trip times, ordered from the fastest to the slowest peer. Peers that
+// could not be pinged are listed last.
+func (g *Gateway) LatencyRanking() []modules.PeerLatencyEntry {
+	if err := g.threads.Add(); err != nil {
+		return nil
+	}
+	defer g.threads.Done()
+
+	peers := g.Peers()
+	entries := make([]modules.PeerLatencyEntry, len(peers))
+	var wg sync.WaitGroup
+	for i, p := range peers {
+		wg.Add(1)
+		go func(i int, addr modules.NetAddress) {
+			defer wg.Done()
+			rtt, err := g.managedPeerLatency(addr)
+			entries[i] = modules.PeerLatencyEntry{NetAddress: addr, Latency: rtt}
+			if err != nil {
+				entries[i].Error = err.Error()
+			}
+		}(i, p.NetAddress)
+	}
+	wg.Wait()
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if (a.Error == "") != (b.Error == "") {
+			return a.Error == ""
+		} else if a.Latency != b.Latency {
+			return a.Latency < b.Latency
+		}
+		return a.NetAddress < b.NetAddress
+	})
+	return entries
+}
@@ -0,0 +1,65 @@
+package gateway
+
+import (
+	"testing"
+)
+
+// TestPeerLatency checks that the round trip times of peers are measured and
+// recorded, and that peers that do not answer the ping are ranked last
+// without being disconnected.
+func TestPeerLatency(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+	g3 := newNamedTestingGateway(t, "3")
+	defer g3.Close()
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if err := g1.Connect(g3.Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := g1.PeerLatency("1.2.3.4:5678"); err == nil {
+		t.Fatal("unconnected peer was pinged")
+	}
+	rtt, err := g1.PeerLatency(g2.Address())
+	if err != nil {
+		t.Fatal(err)
+	} else if rtt <= 0 {
+		t.Fatal("wrong round trip time:", rtt)
+	}
+	g1.mu.RLock()
+	samples := g1.peerStats[g2.Address()].RTTSamples
+	g1.mu.RUnlock()
+	if samples != 2 {
+		t.Fatal("expected the handshake and the ping to be recorded, got", samples)
+	}
+
+	// A peer without the ping RPC is listed last.
+	g3.UnregisterRPC("Ping")
+	defer g3.RegisterRPC("Ping", g3.rpcPing)
+	ranking := g1.LatencyRanking()
+	if len(ranking) != 2 {
+		t.Fatal("expected 2 peers in the ranking, got", len(ranking))
+	}
+	if ranking[0].NetAddress != g2.Address() || ranking[0].Error != "" || ranking[0].Latency <= 0 {
+		t.Fatal("wrong first peer in the ranking:", ranking[0])
+	}
+	if ranking[1].NetAddress != g3.Address() || ranking[1].Error == "" || ranking[1].Latency != 0 {
+		t.Fatal("wrong last peer in the ranking:", ranking[1])
+	}
+	var connected bool
+	for _, p := range g1.Peers() {
+		connected = connected || p.NetAddress == g3.Address()
+	}
+	if !connected {
+		t.Fatal("peer without the ping RPC was disconnected")
+	}
+}
@@ -68,6 +68,7 @@ func (p *peer) accept() (modules.PeerConn, error) {
 // to handle its requests.
 func (g *Gateway) addPeer(p *peer) {
 	g.peers[p.NetAddress] = p
+	g.recordPeerConnect(p.NetAddress)
 	go g.threadedListenPeer(p)
 }
 
@@ -102,7 +103,7 @@ func (g *Gateway) permanentListen(closeChan chan struct{}) {
 			return
 		}
 
-		go g.threadedAcceptConn(conn)
+		go g.threadedAcceptConn(g.bandwidthLimiter.LimitConn(conn))
 
 		// Sleep after each accept. This limits the rate at which the Gateway
 		// will accept new connections. The intent here is to prevent new
@@ -334,6 +335,7 @@ func (g *Gateway) acceptPeer(p *peer) {
 	kick := addrs[fastrand.Intn(len(addrs))]
 
 	g.peers[kick].sess.Close()
+	g.recordPeerDisconnect(kick, disconnectReasonKicked)
 	delete(g.peers, kick)
 	g.log.Printf("INFO: disconnected from %v to make room for %v\n", kick, p.NetAddress)
 	g.addPeer(p)
@@ -494,12 +496,15 @@ func (g *Gateway) managedConnect(addr modules.NetAddress) error {
 		return err
 	}
 
-	// Perform peer initialization.
+	// Perform peer initialization. The version handshake is a single round
+	// trip, so its duration is recorded as the round trip time of the peer.
+	handshakeStart := time.Now()
 	remoteVersion, err := connectVersionHandshake(conn, build.Version)
 	if err != nil {
 		conn.Close()
 		return err
 	}
+	rtt := time.Since(handshakeStart)
 
 	if build.VersionCmp(remoteVersion, sessionUpgradeVersion) >= 0 {
 		err = g.managedConnectv130Peer(conn, remoteVersion, addr)
@@ -532,6 +537,10 @@ func (g *Gateway) managedConnect(addr modules.NetAddress) error {
 	})
 	g.addNode(addr)
 	g.nodes[addr].WasOutboundPeer = true
+	g.recordPeerRTT(addr, rtt)
+	if g.onlineSince.IsZero() {
+		g.onlineSince = time.Now()
+	}
 
 	if err := g.saveSync(); err != nil {
 		g.log.Println("ERROR: Unable to save new outbound peer to gateway:", err)
@@ -586,6 +595,7 @@ func (g *Gateway) Disconnect(addr modules.NetAddress) error {
 	g.mu.Lock()
 	// Peer is removed from the peer list as well as the node list, to prevent
 	// the node from being re-connected while looking for a replacement peer.
+	g.recordPeerDisconnect(addr, disconnectReasonManual)
 	delete(g.peers, addr)
 	delete(g.nodes, addr)
 	g.mu.Unlock()
@@ -594,6 +604,15 @@ func (g *Gateway) Disconnect(addr modules.NetAddress) error {
 	return nil
 }
 
+// OnlineSince returns the time of the first successful outbound connection to
+// a peer in the current session. The zero time is returned if the Gateway has
+// not connected to a peer yet.
+func (g *Gateway) OnlineSince() time.Time {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.onlineSince
+}
+
 // Peers returns the addresses currently connected to the Gateway.
 func (g *Gateway) Peers() []modules.Peer {
 	g.mu.RLock()
@@ -291,6 +291,45 @@ func TestListen(t *testing.T) {
 }
 
 // TestConnect verifies that connecting peers will add peer relationships to
+// TestOnlineSince checks that OnlineSince reports the time of the first
+// outbound connection, and that it is not changed by later connections or by
+// inbound connections.
+func TestOnlineSince(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+
+	if !g1.OnlineSince().IsZero() {
+		t.Fatal("OnlineSince is set before connecting to a peer:", g1.OnlineSince())
+	}
+	before := time.Now()
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	onlineSince := g1.OnlineSince()
+	if onlineSince.Before(before) || onlineSince.After(time.Now()) {
+		t.Fatal("OnlineSince is not the time of the connection:", onlineSince)
+	}
+	if !g2.OnlineSince().IsZero() {
+		t.Fatal("OnlineSince is set by an inbound connection:", g2.OnlineSince())
+	}
+
+	if err := g1.Disconnect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if !g1.OnlineSince().Equal(onlineSince) {
+		t.Fatal("OnlineSince changed after reconnecting:", g1.OnlineSince())
+	}
+}
+
 // the gateway, and that certain edge cases are properly handled.
 func TestConnect(t *testing.T) {
 	if testing.Short() {
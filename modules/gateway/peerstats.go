@@ -0,0 +1,311 @@
+package gateway
+
+// peerstats.go tracks lifetime statistics about the gateway's peers, keyed by
+// the address that a peer can be dialed on. The statistics are used to rank
+// peers by how useful they have been to the gateway. The top ranked peers can
+// be pinned as preferred peers, which the gateway reconnects to as outbound
+// peers whenever they are not connected, including right after startup.
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+const (
+	// The reasons for which the gateway disconnected from a peer.
+	disconnectReasonClosed   = "closed"
+	disconnectReasonKicked   = "kicked"
+	disconnectReasonManual   = "manual"
+	disconnectReasonRPC      = "rpcfailed"
+	disconnectReasonShutdown = "shutdown"
+)
+
+var (
+	// errBadPreferredPeers is returned if the number of preferred peers is
+	// out of range.
+	errBadPreferredPeers = fmt.Errorf("number of preferred peers must be between 0 and %v", wellConnectedThreshold)
+
+	// relayHeaderID and relayTransactionSetID identify the RPCs that peers use
+	// to relay blocks and transaction sets. Their handlers only return a nil
+	// error if the relayed object was new to the node.
+	relayHeaderID         = handlerName("RelayHeader")
+	relayTransactionSetID = handlerName("RelayTransactionSet")
+)
+
+// peerStats contains the lifetime statistics of a peer.
+type peerStats struct {
+	NetAddress                   modules.NetAddress `json:"netaddress"`
+	ConnectedTime                time.Duration      `json:"connectedtime"`
+	BlocksFirstReceived          uint64             `json:"blocksfirstreceived"`
+	TransactionSetsFirstReceived uint64             `json:"transactionsetsfirstreceived"`
+	DisconnectReasons            map[string]uint64  `json:"disconnectreasons"`
+	RTTSamples                   uint64             `json:"rttsamples"`
+	TotalRTT                     time.Duration      `json:"totalrtt"`
+	FirstSeen                    time.Time          `json:"firstseen"`
+	LastSeen                     time.Time          `json:"lastseen"`
+
+	// connectedSince is the start of the part of the current connection that
+	// has not been added to ConnectedTime yet. It is zero if the peer is not
+	// connected.
+	connectedSince time.Time
+}
+
+// connectedTime returns the total time that the gateway has been connected to
+// the peer, including the current connection.
+func (ps *peerStats) connectedTime(now time.Time) time.Duration {
+	if ps.connectedSince.IsZero() {
+		return ps.ConnectedTime
+	}
+	return ps.ConnectedTime + now.Sub(ps.connectedSince)
+}
+
+// relayed returns the number of new blocks and transaction sets that the
+// peer has relayed to the gateway.
+func (ps *peerStats) relayed() uint64 {
+	return ps.BlocksFirstReceived + ps.TransactionSetsFirstReceived
+}
+
+// morePeerUseful returns true if peer a has been more useful than peer b.
+// Peers are ranked by the number of new blocks they relayed, then by the
+// number of new transaction sets they relayed, and then by the time they have
+// been connected.
+func morePeerUseful(a, b *peerStats, now time.Time) bool {
+	if a.BlocksFirstReceived != b.BlocksFirstReceived {
+		return a.BlocksFirstReceived > b.BlocksFirstReceived
+	} else if a.TransactionSetsFirstReceived != b.TransactionSetsFirstReceived {
+		return a.TransactionSetsFirstReceived > b.TransactionSetsFirstReceived
+	} else if at, bt := a.connectedTime(now), b.connectedTime(now); at != bt {
+		return at > bt
+	}
+	return a.NetAddress < b.NetAddress
+}
+
+// peerStatsFor returns the statistics of the peer at addr, creating them if
+// the peer is not known yet.
+func (g *Gateway) peerStatsFor(addr modules.NetAddress) *peerStats {
+	ps, exists := g.peerStats[addr]
+	if !exists {
+		now := time.Now()
+		ps = &peerStats{
+			NetAddress:        addr,
+			DisconnectReasons: make(map[string]uint64),
+			FirstSeen:         now,
+			LastSeen:          now,
+		}
+		g.peerStats[addr] = ps
+	}
+	return ps
+}
+
+// recordPeerConnect marks the start of a connection to the peer at addr.
+func (g *Gateway) recordPeerConnect(addr modules.NetAddress) {
+	ps := g.peerStatsFor(addr)
+	now := time.Now()
+	ps.ConnectedTime = ps.connectedTime(now)
+	ps.connectedSince = now
+	ps.LastSeen = now
+}
+
+// recordPeerDisconnect marks the end of the connection to the peer at addr.
+// Nothing is recorded if the peer is not connected, which makes sure that a
+// connection that is torn down by multiple threads is only counted once.
+func (g *Gateway) recordPeerDisconnect(addr modules.NetAddress, reason string) {
+	ps, exists := g.peerStats[addr]
+	if !exists || ps.connectedSince.IsZero() {
+		return
+	}
+	now := time.Now()
+	ps.ConnectedTime = ps.connectedTime(now)
+	ps.connectedSince = time.Time{}
+	ps.DisconnectReasons[reason]++
+	ps.LastSeen = now
+}
+
+// recordPeerRTT adds a round trip time measurement of the peer at addr.
+func (g *Gateway) recordPeerRTT(addr modules.NetAddress, rtt time.Duration) {
+	ps := g.peerStatsFor(addr)
+	ps.RTTSamples++
+	ps.TotalRTT += rtt
+	ps.LastSeen = time.Now()
+}
+
+// recordPeerRelay credits the peer at addr if the RPC that it called on the
+// gateway relayed a new block or transaction set.
+func (g *Gateway) recordPeerRelay(addr modules.NetAddress, id rpcID) {
+	switch id {
+	case relayHeaderID:
+		g.peerStatsFor(addr).BlocksFirstReceived++
+	case relayTransactionSetID:
+		g.peerStatsFor(addr).TransactionSetsFirstReceived++
+	default:
+		return
+	}
+	g.peerStats[addr].LastSeen = time.Now()
+}
+
+// foldConnectedTime adds the time that the connected peers have been
+// connected since the last call to their ConnectedTime, so that the saved
+// statistics include the current connections.
+func (g *Gateway) foldConnectedTime(now time.Time) {
+	for _, ps := range g.peerStats {
+		if !ps.connectedSince.IsZero() {
+			ps.ConnectedTime = ps.connectedTime(now)
+			ps.connectedSince = now
+		}
+	}
+}
+
+// prunePeerStats removes the statistics of peers that have not been seen for
+// peerStatsExpiration. If more than maxPeerStats peers remain, the least
+// recently seen peers are removed as well. Connected peers are never pruned.
+func (g *Gateway) prunePeerStats(now time.Time) {
+	var candidates []*peerStats
+	for addr, ps := range g.peerStats {
+		if !ps.connectedSince.IsZero() {
+			continue
+		}
+		if now.Sub(ps.LastSeen) > peerStatsExpiration {
+			delete(g.peerStats, addr)
+			continue
+		}
+		candidates = append(candidates, ps)
+	}
+	excess := len(g.peerStats) - maxPeerStats
+	if excess <= 0 {
+		return
+	} else if excess > len(candidates) {
+		excess = len(candidates)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].LastSeen.Before(candidates[j].LastSeen)
+	})
+	for _, ps := range candidates[:excess] {
+		delete(g.peerStats, ps.NetAddress)
+	}
+}
+
+// rankedPeerStats returns the statistics of all known peers, ordered from most
+// to least useful.
+func (g *Gateway) rankedPeerStats(now time.Time) []*peerStats {
+	ranked := make([]*peerStats, 0, len(g.peerStats))
+	for _, ps := range g.peerStats {
+		ranked = append(ranked, ps)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		return morePeerUseful(ranked[i], ranked[j], now)
+	})
+	return ranked
+}
+
+// preferredPeerAddrs returns the addresses of the preferred peers, which are
+// the g.preferredPeers top ranked peers. Peers that have never relayed a new
+// block or transaction set are not preferred.
+func (g *Gateway) preferredPeerAddrs(now time.Time) []modules.NetAddress {
+	var addrs []modules.NetAddress
+	for _, ps := range g.rankedPeerStats(now) {
+		if len(addrs) >= g.preferredPeers || ps.relayed() == 0 {
+			break
+		}
+		addrs = append(addrs, ps.NetAddress)
+	}
+	return addrs
+}
+
+// permanentPreferredPeerManager connects to the preferred peers that the
+// gateway is not connected to as outbound peers. It makes its first attempt
+// right after startup, and then retries every preferredPeerDelay.
+func (g *Gateway) permanentPreferredPeerManager(closedChan chan struct{}) {
+	// Send a signal upon shutdown.
+	defer close(closedChan)
+
+	for {
+		g.mu.RLock()
+		var addrs []modules.NetAddress
+		for _, addr := range g.preferredPeerAddrs(time.Now()) {
+			if p, exists := g.peers[addr]; !exists || p.Inbound {
+				addrs = append(addrs, addr)
+			}
+		}
+		g.mu.RUnlock()
+
+		for _, addr := range addrs {
+			if g.threads.Add() != nil {
+				return
+			}
+			g.log.Debugln("[PPPM] Connecting to preferred peer", addr)
+			g.managedPeerManagerConnect(addr)
+			g.threads.Done()
+		}
+		if !g.managedSleep(preferredPeerDelay) {
+			return
+		}
+	}
+}
+
+// PeerReport returns the lifetime statistics of every peer that the gateway
+// knows about, ranked from most to least useful.
+func (g *Gateway) PeerReport() []modules.PeerStats {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	now := time.Now()
+	preferred := make(map[modules.NetAddress]struct{})
+	for _, addr := range g.preferredPeerAddrs(now) {
+		preferred[addr] = struct{}{}
+	}
+	var report []modules.PeerStats
+	for _, ps := range g.rankedPeerStats(now) {
+		_, isPreferred := preferred[ps.NetAddress]
+		reasons := make(map[string]uint64, len(ps.DisconnectReasons))
+		for reason, n := range ps.DisconnectReasons {
+			reasons[reason] = n
+		}
+		var averageRTT time.Duration
+		if ps.RTTSamples > 0 {
+			averageRTT = ps.TotalRTT / time.Duration(ps.RTTSamples)
+		}
+		report = append(report, modules.PeerStats{
+			NetAddress: ps.NetAddress,
+			Connected:  !ps.connectedSince.IsZero(),
+			Preferred:  isPreferred,
+
+			ConnectedTime:                ps.connectedTime(now),
+			BlocksFirstReceived:          ps.BlocksFirstReceived,
+			TransactionSetsFirstReceived: ps.TransactionSetsFirstReceived,
+			DisconnectReasons:            reasons,
+			AverageRTT:                   averageRTT,
+
+			FirstSeen: ps.FirstSeen,
+			LastSeen:  ps.LastSeen,
+		})
+	}
+	return report
+}
+
+// PreferredPeers returns the number of top ranked peers that the gateway keeps
+// as outbound peers.
+func (g *Gateway) PreferredPeers() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.preferredPeers
+}
+
+// SetPreferredPeers sets the number of top ranked peers that the gateway keeps
+// as outbound peers.
+func (g *Gateway) SetPreferredPeers(n int) error {
+	if err := g.threads.Add(); err != nil {
+		return err
+	}
+	defer g.threads.Done()
+	if n < 0 || n > wellConnectedThreshold {
+		return errBadPreferredPeers
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.preferredPeers = n
+	return g.saveSync()
+}
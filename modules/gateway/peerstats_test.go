@@ -0,0 +1,220 @@
+package gateway
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestPeerStatsRecording checks that connections, disconnections, round trip
+// times, and relays are recorded in the statistics of a peer.
+func TestPeerStatsRecording(t *testing.T) {
+	g := &Gateway{
+		peerStats: make(map[modules.NetAddress]*peerStats),
+	}
+	addr := modules.NetAddress("1.2.3.4:9981")
+
+	g.recordPeerConnect(addr)
+	g.recordPeerRTT(addr, 10*time.Millisecond)
+	g.recordPeerRTT(addr, 30*time.Millisecond)
+	g.recordPeerRelay(addr, relayHeaderID)
+	g.recordPeerRelay(addr, relayTransactionSetID)
+	g.recordPeerRelay(addr, relayTransactionSetID)
+	g.recordPeerRelay(addr, handlerName("ShareNodes"))
+	time.Sleep(10 * time.Millisecond)
+
+	// A connection that is torn down twice should only be counted once.
+	g.recordPeerDisconnect(addr, disconnectReasonKicked)
+	g.recordPeerDisconnect(addr, disconnectReasonClosed)
+
+	report := g.PeerReport()
+	if len(report) != 1 {
+		t.Fatal("expected one peer in the report, got", len(report))
+	}
+	ps := report[0]
+	if ps.NetAddress != addr || ps.Connected {
+		t.Error("wrong peer in report:", ps.NetAddress, ps.Connected)
+	}
+	if ps.ConnectedTime < 10*time.Millisecond {
+		t.Error("connected time was not recorded:", ps.ConnectedTime)
+	}
+	if ps.AverageRTT != 20*time.Millisecond {
+		t.Error("wrong average RTT:", ps.AverageRTT)
+	}
+	if ps.BlocksFirstReceived != 1 || ps.TransactionSetsFirstReceived != 2 {
+		t.Error("relays were not recorded:", ps.BlocksFirstReceived, ps.TransactionSetsFirstReceived)
+	}
+	if len(ps.DisconnectReasons) != 1 || ps.DisconnectReasons[disconnectReasonKicked] != 1 {
+		t.Error("wrong disconnect reasons:", ps.DisconnectReasons)
+	}
+
+	// Reconnecting should add to the connected time of the peer.
+	g.recordPeerConnect(addr)
+	time.Sleep(10 * time.Millisecond)
+	report = g.PeerReport()
+	if !report[0].Connected || report[0].ConnectedTime < ps.ConnectedTime+10*time.Millisecond {
+		t.Error("current connection is not included in the report:", report[0].Connected, report[0].ConnectedTime)
+	}
+}
+
+// TestPeerStatsRanking checks that peers are ranked by their usefulness and
+// that only useful peers can be preferred.
+func TestPeerStatsRanking(t *testing.T) {
+	g := &Gateway{
+		peerStats: map[modules.NetAddress]*peerStats{
+			"1.1.1.1:1": {NetAddress: "1.1.1.1:1", ConnectedTime: time.Hour},
+			"2.2.2.2:2": {NetAddress: "2.2.2.2:2", BlocksFirstReceived: 1},
+			"3.3.3.3:3": {NetAddress: "3.3.3.3:3", TransactionSetsFirstReceived: 50},
+			"4.4.4.4:4": {NetAddress: "4.4.4.4:4", BlocksFirstReceived: 1, ConnectedTime: time.Minute},
+		},
+		preferredPeers: 8,
+	}
+	expected := []modules.NetAddress{"4.4.4.4:4", "2.2.2.2:2", "3.3.3.3:3", "1.1.1.1:1"}
+	report := g.PeerReport()
+	for i, ps := range report {
+		if ps.NetAddress != expected[i] {
+			t.Fatalf("peer %v should be %v, got %v", i, expected[i], ps.NetAddress)
+		}
+		// The peer that never relayed anything should not be preferred.
+		if ps.Preferred != (i < 3) {
+			t.Errorf("peer %v has the wrong preferred status", ps.NetAddress)
+		}
+	}
+
+	g.preferredPeers = 1
+	preferred := g.preferredPeerAddrs(time.Now())
+	if len(preferred) != 1 || preferred[0] != "4.4.4.4:4" {
+		t.Error("wrong preferred peers:", preferred)
+	}
+}
+
+// TestPrunePeerStats checks that expired and excess peer statistics are
+// pruned, and that connected peers are never pruned.
+func TestPrunePeerStats(t *testing.T) {
+	now := time.Now()
+	g := &Gateway{
+		peerStats: make(map[modules.NetAddress]*peerStats),
+	}
+	for i := 0; i < maxPeerStats+5; i++ {
+		addr := modules.NetAddress("1.2.3.4:" + strconv.Itoa(i+1))
+		g.peerStats[addr] = &peerStats{
+			NetAddress: addr,
+			LastSeen:   now.Add(-time.Duration(i) * time.Second),
+		}
+	}
+	expired := &peerStats{NetAddress: "5.5.5.5:5", LastSeen: now.Add(-2 * peerStatsExpiration)}
+	connected := &peerStats{NetAddress: "6.6.6.6:6", LastSeen: now.Add(-2 * peerStatsExpiration), connectedSince: now}
+	g.peerStats[expired.NetAddress] = expired
+	g.peerStats[connected.NetAddress] = connected
+
+	g.prunePeerStats(now)
+	if len(g.peerStats) != maxPeerStats {
+		t.Fatalf("expected %v peers after pruning, got %v", maxPeerStats, len(g.peerStats))
+	}
+	if _, exists := g.peerStats[expired.NetAddress]; exists {
+		t.Error("expired peer was not pruned")
+	}
+	if _, exists := g.peerStats[connected.NetAddress]; !exists {
+		t.Error("connected peer was pruned")
+	}
+	// The least recently seen peers should have been pruned.
+	for i := maxPeerStats - 1; i < maxPeerStats+5; i++ {
+		if _, exists := g.peerStats[modules.NetAddress("1.2.3.4:"+strconv.Itoa(i+1))]; exists {
+			t.Error("least recently seen peer was not pruned:", i)
+		}
+	}
+}
+
+// TestPreferredPeersPersist checks that the peer statistics and the preferred
+// peer setting survive a restart, and that the gateway reconnects to its
+// preferred peers after the restart.
+func TestPreferredPeersPersist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+
+	// Register a handler that accepts every relayed transaction set, and have
+	// g2 relay a transaction set to g1.
+	g1.RegisterRPC("RelayTransactionSet", func(conn modules.PeerConn) error {
+		var s string
+		return encoding.ReadObject(conn, &s, 100)
+	})
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	err := build.Retry(50, 100*time.Millisecond, func() error {
+		if len(g2.Peers()) != 1 {
+			return errors.New("g2 is not connected to g1")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = g2.RPC(g2.Peers()[0].NetAddress, "RelayTransactionSet", func(conn modules.PeerConn) error {
+		return encoding.WriteObject(conn, "transactions")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		report := g1.PeerReport()
+		if len(report) != 1 || report[0].TransactionSetsFirstReceived != 1 {
+			return errors.New("relay was not recorded")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g1.SetPreferredPeers(wellConnectedThreshold + 1); err != errBadPreferredPeers {
+		t.Fatal("expected errBadPreferredPeers, got", err)
+	}
+	if err := g1.SetPreferredPeers(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := g1.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restart g1. The statistics of g2 should have been kept, and g1 should
+	// reconnect to g2 as an outbound peer.
+	g1, err = New("localhost:0", false, g1.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g1.Close()
+	if g1.PreferredPeers() != 1 {
+		t.Fatal("preferred peer setting was not persisted:", g1.PreferredPeers())
+	}
+	report := g1.PeerReport()
+	if len(report) != 1 || report[0].NetAddress != g2.Address() {
+		t.Fatal("peer statistics were not persisted:", report)
+	}
+	if report[0].TransactionSetsFirstReceived != 1 || report[0].ConnectedTime == 0 || report[0].AverageRTT == 0 {
+		t.Error("peer statistics were not persisted:", report[0])
+	}
+	if report[0].DisconnectReasons[disconnectReasonShutdown] != 1 {
+		t.Error("shutdown was not recorded as the disconnect reason:", report[0].DisconnectReasons)
+	}
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		peers := g1.Peers()
+		if len(peers) != 1 || peers[0].NetAddress != g2.Address() || peers[0].Inbound {
+			return errors.New("g1 did not reconnect to its preferred peer")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -14,6 +14,10 @@ const (
 
 	// nodesFile is the name of the file that contains all seen nodes.
 	nodesFile = "nodes.json"
+
+	// peerStatsFile is the name of the file that contains the statistics of
+	// the gateway's peers.
+	peerStatsFile = "peerstats.json"
 )
 
 // persistMetadata contains the header and version strings that identify the
@@ -23,6 +27,20 @@ var persistMetadata = persist.Metadata{
 	Version: "1.3.0",
 }
 
+// peerStatsMetadata contains the header and version strings that identify the
+// peer statistics file.
+var peerStatsMetadata = persist.Metadata{
+	Header:  "Sia Peer Stats",
+	Version: "1.3.1",
+}
+
+// peerStatsPersist contains the peer statistics and the preferred peer
+// setting that are saved to disk.
+type peerStatsPersist struct {
+	PreferredPeers int          `json:"preferredpeers"`
+	Peers          []*peerStats `json:"peers"`
+}
+
 // persistData returns the data in the Gateway that will be saved to disk.
 func (g *Gateway) persistData() (nodes []*node) {
 	for _, node := range g.nodes {
@@ -45,10 +63,48 @@ func (g *Gateway) load() error {
 	return nil
 }
 
+// peerStatsPersistData returns the peer statistics that will be saved to disk.
+// The statistics are pruned, and the time of the current connections is added
+// to the connected time of the peers.
+func (g *Gateway) peerStatsPersistData() peerStatsPersist {
+	now := time.Now()
+	g.foldConnectedTime(now)
+	g.prunePeerStats(now)
+	data := peerStatsPersist{
+		PreferredPeers: g.preferredPeers,
+		Peers:          make([]*peerStats, 0, len(g.peerStats)),
+	}
+	for _, ps := range g.peerStats {
+		data.Peers = append(data.Peers, ps)
+	}
+	return data
+}
+
+// loadPeerStats loads the peer statistics from disk.
+func (g *Gateway) loadPeerStats() error {
+	var data peerStatsPersist
+	err := persist.LoadJSON(peerStatsMetadata, &data, filepath.Join(g.persistDir, peerStatsFile))
+	if err != nil {
+		return err
+	}
+	g.preferredPeers = data.PreferredPeers
+	for _, ps := range data.Peers {
+		if ps.DisconnectReasons == nil {
+			ps.DisconnectReasons = make(map[string]uint64)
+		}
+		g.peerStats[ps.NetAddress] = ps
+	}
+	return nil
+}
+
 // saveSync stores the Gateway's persistent data on disk, and then syncs to
 // disk to minimize the possibility of data loss.
 func (g *Gateway) saveSync() error {
-	return persist.SaveJSON(persistMetadata, g.persistData(), filepath.Join(g.persistDir, nodesFile))
+	err := persist.SaveJSON(persistMetadata, g.persistData(), filepath.Join(g.persistDir, nodesFile))
+	if err != nil {
+		return err
+	}
+	return persist.SaveJSON(peerStatsMetadata, g.peerStatsPersistData(), filepath.Join(g.persistDir, peerStatsFile))
 }
 
 // threadedSaveLoop periodically saves the gateway.
@@ -50,6 +50,7 @@ func (g *Gateway) managedRPC(addr modules.NetAddress, name string, fn modules.RP
 		g.log.Debugf("Could not initiate RPC with %v; disconnecting", addr)
 		peer.sess.Close()
 		g.mu.Lock()
+		g.recordPeerDisconnect(addr, disconnectReasonRPC)
 		delete(g.peers, addr)
 		g.mu.Unlock()
 		return err
@@ -150,14 +151,17 @@ func (g *Gateway) threadedListenPeer(p *peer) {
 		defer close(connClosedChan)
 
 		// Listen for a stop signal.
+		reason := disconnectReasonClosed
 		select {
 		case <-g.threads.StopChan():
+			reason = disconnectReasonShutdown
 		case <-peerCloseChan:
 		}
 
 		// Close the session and remove p from the peer list.
 		p.sess.Close()
 		g.mu.Lock()
+		g.recordPeerDisconnect(p.NetAddress, reason)
 		delete(g.peers, p.NetAddress)
 		g.mu.Unlock()
 	}()
@@ -218,6 +222,11 @@ func (g *Gateway) threadedHandleConn(conn modules.PeerConn) {
 
 	// call fn
 	err = fn(conn)
+	if err == nil && (id == relayHeaderID || id == relayTransactionSetID) {
+		g.mu.Lock()
+		g.recordPeerRelay(conn.RPCAddr(), id)
+		g.mu.Unlock()
+	}
 	// don't log benign errors
 	if err == modules.ErrDuplicateTransactionSet || err == modules.ErrBlockKnown {
 		err = nil
@@ -1,6 +1,16 @@
 package modules
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -10,6 +20,49 @@ const (
 )
 
 var (
+	// ErrInvalidHumanPrice is returned when converting a human readable price
+	// that is negative, infinite, or not a number.
+	ErrInvalidHumanPrice = errors.New("price must be a non-negative, finite number")
+
+	// HostAlertCategoryCollateral is used for alerts about collateral that
+	// the host has lost.
+	HostAlertCategoryCollateral = HostAlertCategory("collateral")
+
+	// HostAlertCategoryCollateralBudget is used for alerts about the host
+	// being unable to accept contracts because the collateral budget has been
+	// exhausted.
+	HostAlertCategoryCollateralBudget = HostAlertCategory("collateral budget")
+
+	// HostAlertCategoryProofConstruction is used for alerts about storage
+	// obligations that are too large for the host to construct their storage
+	// proofs in time.
+	HostAlertCategoryProofConstruction = HostAlertCategory("proof construction")
+
+	// HostAlertCategoryProofTest is used for alerts about storage obligations
+	// whose storage proofs failed the weekly proof test.
+	HostAlertCategoryProofTest = HostAlertCategory("proof test")
+
+	// HostAlertCategoryQuarantine is used for alerts about storage
+	// obligations that the host has quarantined because they failed an
+	// invariant check.
+	HostAlertCategoryQuarantine = HostAlertCategory("quarantine")
+
+	// HostAlertCategoryStorageFolder is used for alerts about storage folders
+	// that are returning errors on reads or writes.
+	HostAlertCategoryStorageFolder = HostAlertCategory("storage folder")
+
+	// HostAlertCategoryStorageProof is used for alerts about storage proofs
+	// that the host has failed to get onto the blockchain.
+	HostAlertCategoryStorageProof = HostAlertCategory("storage proof")
+
+	// HostAlertSeverityCritical is used for alerts which indicate that the
+	// host is losing money.
+	HostAlertSeverityCritical = HostAlertSeverity("critical")
+
+	// HostAlertSeverityWarning is used for alerts which indicate that the host
+	// is not operating as intended, but is not yet losing money.
+	HostAlertSeverityWarning = HostAlertSeverity("warning")
+
 	// BlockBytesPerMonthTerabyte is the conversion rate between block-bytes and month-TB.
 	BlockBytesPerMonthTerabyte = BytesPerTerabyte.Mul64(4320)
 
@@ -30,6 +83,58 @@ var (
 	// netaddress.
 	HostConnectabilityStatusNotConnectable = HostConnectabilityStatus("not connectable")
 
+	// HostObligationViolationMissingSectors is the violation of an obligation
+	// that has sectors which are missing from the host's storage.
+	HostObligationViolationMissingSectors = HostObligationViolation("missing sectors")
+
+	// HostObligationViolationOriginSet is the violation of an obligation
+	// whose origin transaction set does not contain a well formed file
+	// contract.
+	HostObligationViolationOriginSet = HostObligationViolation("malformed origin transaction set")
+
+	// HostObligationViolationRevisionSet is the violation of an obligation
+	// whose revision transaction set does not end with a well formed file
+	// contract revision.
+	HostObligationViolationRevisionSet = HostObligationViolation("malformed revision transaction set")
+
+	// HostObligationViolationRevisionTransition is the violation of an
+	// obligation whose most recent revision is not a revision that the host
+	// would have accepted for its file contract.
+	HostObligationViolationRevisionTransition = HostObligationViolation("invalid revision transition")
+
+	// HostObligationViolationSectorRoots is the violation of an obligation
+	// whose sector roots do not match the file size or the Merkle root of its
+	// most recent revision.
+	HostObligationViolationSectorRoots = HostObligationViolation("sector roots do not match revision")
+
+	// HostQuarantineActionFail resolves a quarantined obligation by failing
+	// it, as ExpireContract does.
+	HostQuarantineActionFail = HostQuarantineAction("fail")
+
+	// HostQuarantineActionRebuild repairs a quarantined obligation by
+	// rebuilding its revision transaction set from the most recent revision
+	// in its history that matches its sector roots.
+	HostQuarantineActionRebuild = HostQuarantineAction("rebuild")
+
+	// HostQuarantineActionRecheck checks a quarantined obligation again, and
+	// releases it if it no longer violates an invariant, for example after
+	// the storage folder that holds its missing sectors has been restored.
+	HostQuarantineActionRecheck = HostQuarantineAction("recheck")
+
+	// HostQuarantineActionRelease releases a quarantined obligation without
+	// checking it again.
+	HostQuarantineActionRelease = HostQuarantineAction("release")
+
+	// HostSettingOverrideAutoAddress is the reason of an override of the net
+	// address, used when the host has no configured net address and serves
+	// the address that it detected automatically.
+	HostSettingOverrideAutoAddress = HostSettingOverrideReason("auto-detected address")
+
+	// HostSettingOverrideCollateralFraction is the reason of an override of
+	// the collateral, used when the collateral is clamped by the host's
+	// MaxCollateralFraction.
+	HostSettingOverrideCollateralFraction = HostSettingOverrideReason("max collateral fraction")
+
 	// HostWorkingStatusChecking is returned from WorkingStatus() if the host is
 	// still determining if it is working, that is, if settings calls are
 	// incrementing.
@@ -44,9 +149,144 @@ var (
 	// received more than workingThreshold settings calls over the duration of
 	// workingStatusFrequency.
 	HostWorkingStatusWorking = HostWorkingStatus("working")
+
+	// ProofFailureEmptyContract is the failure reason of an obligation that
+	// ended without any data, so there was nothing to prove.
+	ProofFailureEmptyContract = StorageProofFailureReason("empty contract")
+
+	// ProofFailureExpiredByHost is the failure reason of an obligation that
+	// was terminated early by the host operator with ExpireContract.
+	ProofFailureExpiredByHost = StorageProofFailureReason("expired by host")
+
+	// ProofFailureFeeTooHigh is the failure reason of an obligation whose
+	// storage proof was not submitted because the transaction fee exceeded the
+	// value of the obligation.
+	ProofFailureFeeTooHigh = StorageProofFailureReason("fee too high")
+
+	// ProofFailureHostOffline is the failure reason of an obligation for which
+	// the host never attempted a storage proof, which happens when the host is
+	// offline or not synced during the proof window.
+	ProofFailureHostOffline = StorageProofFailureReason("host offline")
+
+	// ProofFailureReorged is the failure reason of an obligation whose storage
+	// proof was confirmed, but then removed from the blockchain by a reorg.
+	ProofFailureReorged = StorageProofFailureReason("reorged out")
+
+	// ProofFailureSectorUnreadable is the failure reason of an obligation whose
+	// storage proof could not be built because the sector could not be read.
+	ProofFailureSectorUnreadable = StorageProofFailureReason("sector unreadable")
+
+	// ProofFailureTransactionRejected is the failure reason of an obligation
+	// whose storage proof transaction could not be created or was rejected by
+	// the transaction pool.
+	ProofFailureTransactionRejected = StorageProofFailureReason("transaction rejected")
+
+	// ProofFailureUnconfirmed is the failure reason of an obligation whose
+	// storage proof was submitted, but never confirmed.
+	ProofFailureUnconfirmed = StorageProofFailureReason("transaction never confirmed")
+
+	// ProofFailureWalletLocked is the failure reason of an obligation whose
+	// storage proof could not be funded because the wallet was locked.
+	ProofFailureWalletLocked = StorageProofFailureReason("wallet locked")
+)
+
+// The stages of a proof test, in the order in which they are run.
+const (
+	// ProofTestStageSegmentSelection is the selection of the segment that is
+	// proven, and of the sector that contains it.
+	ProofTestStageSegmentSelection = ProofTestStage("segment selection")
+
+	// ProofTestStageSectorRead is the read of the sector that contains the
+	// segment.
+	ProofTestStageSectorRead = ProofTestStage("sector read")
+
+	// ProofTestStageProofConstruction is the construction of the Merkle
+	// proof of the segment.
+	ProofTestStageProofConstruction = ProofTestStage("proof construction")
+
+	// ProofTestStageVerification is the verification of the proof against
+	// the Merkle root of the contract.
+	ProofTestStageVerification = ProofTestStage("verification")
 )
 
 type (
+	// HostAlert describes a condition on the host that requires the attention
+	// of the operator. Alerts with the same category and cause are
+	// deduplicated, repeated occurrences only increment the Occurrences
+	// counter.
+	HostAlert struct {
+		Severity HostAlertSeverity `json:"severity"`
+		Category HostAlertCategory `json:"category"`
+		Message  string            `json:"message"`
+
+		// Cause identifies the storage obligation or storage folder that
+		// triggered the alert. If the alert has occurred multiple times, the
+		// cause is the most recent one. The cause is empty for alerts that
+		// relate to the host as a whole.
+		Cause string `json:"cause"`
+
+		// Occurrences is the number of times the condition was observed since
+		// the alert was first raised.
+		Occurrences uint64 `json:"occurrences"`
+
+		// Cleared is set when the alert is sent to subscribers because the
+		// condition has resolved. Alerts returned by Alerts() are never
+		// cleared.
+		Cleared bool `json:"cleared"`
+	}
+
+	// HostAlertCategory identifies the part of the host that an alert relates
+	// to.
+	HostAlertCategory string
+
+	// HostAlertSeverity indicates how urgently an alert should be acted upon.
+	// Can be one of "warning" or "critical".
+	HostAlertSeverity string
+
+	// A HostAlertSubscriber receives alerts from the host as they are raised
+	// and cleared.
+	HostAlertSubscriber interface {
+		// ProcessHostAlert is called when an alert is first raised, and again
+		// with the Cleared field set when the condition has resolved. The
+		// host holds its lock while calling ProcessHostAlert, subscribers
+		// should not call back into the host from within ProcessHostAlert.
+		ProcessHostAlert(HostAlert)
+	}
+
+	// A HostAlerter reports conditions on the host which the operator should
+	// know about, such as failed storage proofs or failing storage folders.
+	HostAlerter interface {
+		// Alerts returns the alerts that are currently active on the host.
+		Alerts() []HostAlert
+
+		// AlertSubscribe adds a subscriber to the host. The subscriber will be
+		// sent all currently active alerts before AlertSubscribe returns.
+		AlertSubscribe(HostAlertSubscriber)
+
+		// AlertUnsubscribe removes a subscriber from the host.
+		AlertUnsubscribe(HostAlertSubscriber)
+	}
+
+	// HostContractAssessment is the host's verdict on a proposed file
+	// contract. If the contract is not accepted, RejectReason explains why.
+	// ExpectedRevenue is the contract price that the host would earn by
+	// forming the contract, and RequiredCollateral is the collateral that the
+	// host would have to add to it.
+	HostContractAssessment struct {
+		Accepted           bool           `json:"accepted"`
+		RejectReason       string         `json:"rejectreason"`
+		ExpectedRevenue    types.Currency `json:"expectedrevenue"`
+		RequiredCollateral types.Currency `json:"requiredcollateral"`
+	}
+
+	// HostEffectiveSettings contains the external settings that the host
+	// currently serves to renters, along with an explanation of every field
+	// that differs from the host's internal settings.
+	HostEffectiveSettings struct {
+		Settings  HostExternalSettings  `json:"settings"`
+		Overrides []HostSettingOverride `json:"overrides"`
+	}
+
 	// HostFinancialMetrics provides financial statistics for the host,
 	// including money that is locked in contracts. Though verbose, these
 	// statistics should provide a clear picture of where the host's money is
@@ -75,6 +315,16 @@ type (
 		PotentialDownloadBandwidthRevenue types.Currency `json:"potentialdownloadbandwidthrevenue"`
 		PotentialUploadBandwidthRevenue   types.Currency `json:"potentialuploadbandwidthrevenue"`
 		UploadBandwidthRevenue            types.Currency `json:"uploadbandwidthrevenue"`
+
+		// Resolved storage obligations are pruned from the host's database
+		// once they are older than ObligationRetentionBlocks, their revenue
+		// remains in the metrics above. PrunedObligations is the number of
+		// pruned obligations, of which PrunedFailedObligations failed. Every
+		// obligation that was resolved before PrunedBeforeHeight has been
+		// pruned.
+		PrunedObligations       uint64            `json:"prunedobligations"`
+		PrunedFailedObligations uint64            `json:"prunedfailedobligations"`
+		PrunedBeforeHeight      types.BlockHeight `json:"prunedbeforeheight"`
 	}
 
 	// HostInternalSettings contains a list of settings that can be changed.
@@ -83,13 +333,49 @@ type (
 		MaxDownloadBatchSize uint64            `json:"maxdownloadbatchsize"`
 		MaxDuration          types.BlockHeight `json:"maxduration"`
 		MaxReviseBatchSize   uint64            `json:"maxrevisebatchsize"`
+		MaxSessionMemory     uint64            `json:"maxsessionmemory"`
 		NetAddress           NetAddress        `json:"netaddress"`
 		WindowSize           types.BlockHeight `json:"windowsize"`
 
+		// ObligationRetentionBlocks is the number of blocks that resolved
+		// storage obligations are kept in the host's database. Older
+		// obligations are pruned, and are only counted in the financial
+		// metrics. 0 means that resolved obligations are kept forever.
+		ObligationRetentionBlocks types.BlockHeight `json:"obligationretentionblocks"`
+
+		// ProofTestSampleSize is the number of unresolved storage obligations
+		// whose storage proofs the host constructs and verifies every week,
+		// without submitting them, to find unprovable obligations before
+		// their proof windows open. 0 disables the weekly proof test.
+		ProofTestSampleSize uint64 `json:"prooftestsamplesize"`
+
+		// TLSCertFile and TLSKeyFile are the paths of the PEM encoded TLS
+		// certificate and key of the host. If they are set, renters that
+		// have pinned the certificate connect to the host over TLS. A
+		// self-signed certificate is generated if neither file exists.
+		TLSCertFile string `json:"tlscertfile"`
+		TLSKeyFile  string `json:"tlskeyfile"`
+
+		// TLSRequired refuses every RPC other than the settings RPC on
+		// connections that are not encrypted with TLS. It requires
+		// TLSCertFile and TLSKeyFile to be set.
+		TLSRequired bool `json:"tlsrequired"`
+
+		// RenterWhitelist restricts the renters that can form and renew
+		// contracts with the host. If the whitelist is empty, any renter can
+		// form contracts. The host's settings are served to every renter.
+		RenterWhitelist []types.SiaPublicKey `json:"renterwhitelist"`
+
 		Collateral       types.Currency `json:"collateral"`
 		CollateralBudget types.Currency `json:"collateralbudget"`
 		MaxCollateral    types.Currency `json:"maxcollateral"`
 
+		// MaxCollateralFraction limits the collateral of each contract to a
+		// fraction of the renter's payout. The collateral that is offered to
+		// renters is clamped to MaxCollateralFraction * MinStoragePrice per
+		// byte per block. 0 means that the collateral is not limited.
+		MaxCollateralFraction float64 `json:"maxcollateralfraction"`
+
 		MinContractPrice          types.Currency `json:"mincontractprice"`
 		MinDownloadBandwidthPrice types.Currency `json:"mindownloadbandwidthprice"`
 		MinStoragePrice           types.Currency `json:"minstorageprice"`
@@ -97,7 +383,9 @@ type (
 	}
 
 	// HostNetworkMetrics reports the quantity of each type of RPC call that
-	// has been made to the host.
+	// has been made to the host, and the bandwidth that the RPCs consumed.
+	// Download bandwidth is the number of bytes that the host received from
+	// renters, upload bandwidth is the number of bytes that the host sent.
 	HostNetworkMetrics struct {
 		DownloadCalls     uint64 `json:"downloadcalls"`
 		ErrorCalls        uint64 `json:"errorcalls"`
@@ -105,7 +393,189 @@ type (
 		RenewCalls        uint64 `json:"renewcalls"`
 		ReviseCalls       uint64 `json:"revisecalls"`
 		SettingsCalls     uint64 `json:"settingscalls"`
+		UnauthorizedCalls uint64 `json:"unauthorizedcalls"`
 		UnrecognizedCalls uint64 `json:"unrecognizedcalls"`
+
+		// CollateralClampedCalls is the number of contract formations and
+		// renewals that were accepted with collateral clamped by the host's
+		// MaxCollateralFraction.
+		CollateralClampedCalls uint64 `json:"collateralclampedcalls"`
+
+		// LegacyCalls is the number of connections that called an RPC without
+		// negotiating an RPC version, and VersionCalls is the number of
+		// versioned sessions for each negotiated RPC version.
+		LegacyCalls  uint64            `json:"legacycalls"`
+		VersionCalls map[uint64]uint64 `json:"versioncalls"`
+
+		// UniqueRenters is the number of distinct renters, by identity key,
+		// that have unresolved storage obligations with the host, and
+		// UniqueSettingsCallers is the number of distinct IP addresses that
+		// have called the settings RPC within the last 24 hours.
+		UniqueRenters         uint64 `json:"uniquerenters"`
+		UniqueSettingsCallers uint64 `json:"uniquesettingscallers"`
+
+		// The totals are the sum of the bandwidth in RPCBandwidth.
+		DownloadBandwidthConsumed uint64                  `json:"downloadbandwidthconsumed"`
+		UploadBandwidthConsumed   uint64                  `json:"uploadbandwidthconsumed"`
+		RPCBandwidth              HostRPCBandwidthMetrics `json:"rpcbandwidth"`
+	}
+
+	// HostRPCBandwidth reports the number of bytes that the host has received
+	// and sent during one type of RPC.
+	HostRPCBandwidth struct {
+		DownloadBandwidth uint64 `json:"downloadbandwidth"`
+		UploadBandwidth   uint64 `json:"uploadbandwidth"`
+	}
+
+	// HostRPCBandwidthMetrics breaks down the bandwidth consumed by the host
+	// by the type of RPC. Other contains the bandwidth of connections that did
+	// not call a recognized RPC.
+	HostRPCBandwidthMetrics struct {
+		Download     HostRPCBandwidth `json:"download"`
+		FormContract HostRPCBandwidth `json:"formcontract"`
+		Renew        HostRPCBandwidth `json:"renew"`
+		Revise       HostRPCBandwidth `json:"revise"`
+		Settings     HostRPCBandwidth `json:"settings"`
+		Other        HostRPCBandwidth `json:"other"`
+	}
+
+	// HostSessionMemoryMetrics reports the amount of memory that is in use by
+	// the host's RPC sessions, in bytes.
+	HostSessionMemoryMetrics struct {
+		Limit     uint64 `json:"limit"`
+		Used      uint64 `json:"used"`
+		HighWater uint64 `json:"highwater"`
+	}
+
+	// HostObligationViolation names the invariant that a quarantined storage
+	// obligation violates.
+	HostObligationViolation string
+
+	// HostQuarantineAction is an action that the host operator can apply to
+	// a quarantined storage obligation to repair or resolve it.
+	HostQuarantineAction string
+
+	// HostQuarantinedObligation is a storage obligation that the host has
+	// quarantined because it failed an invariant check. Quarantined
+	// obligations are excluded from the host's normal processing: the host
+	// does not submit their revisions or storage proofs, and renters cannot
+	// revise or download from them, until the operator repairs or resolves
+	// them. Detail describes the violation, and Metadata is the obligation as
+	// it is stored in the host's database, without its sector roots. Sectors
+	// is the number of sector roots of the obligation.
+	HostQuarantinedObligation struct {
+		ID               types.FileContractID    `json:"id"`
+		Violation        HostObligationViolation `json:"violation"`
+		Detail           string                  `json:"detail"`
+		QuarantineHeight types.BlockHeight       `json:"quarantineheight"`
+		Sectors          uint64                  `json:"sectors"`
+		Metadata         json.RawMessage         `json:"metadata"`
+	}
+
+	// HostHealthCheck is the result of one of the self-diagnostic checks of
+	// the host. Error describes why the check failed.
+	HostHealthCheck struct {
+		Name   string `json:"name"`
+		Passed bool   `json:"passed"`
+		Error  string `json:"error,omitempty"`
+	}
+
+	// HostHealthStatus is the result of the self-diagnostic of the host. The
+	// host is healthy if it passed every check. StorageFolders has a check for
+	// every storage folder of the host, named by the path of the folder.
+	HostHealthStatus struct {
+		Healthy         bool              `json:"healthy"`
+		StorageFolders  []HostHealthCheck `json:"storagefolders"`
+		WalletBalance   HostHealthCheck   `json:"walletbalance"`
+		ConsensusSynced HostHealthCheck   `json:"consensussynced"`
+		Reachable       HostHealthCheck   `json:"reachable"`
+	}
+
+	// HostSettingOverride describes a field of the host's external settings
+	// whose value differs from the corresponding internal setting. Field is
+	// the JSON name of the external setting, and the values are formatted as
+	// they are in JSON.
+	HostSettingOverride struct {
+		Field          string                    `json:"field"`
+		InternalValue  string                    `json:"internalvalue"`
+		EffectiveValue string                    `json:"effectivevalue"`
+		Reason         HostSettingOverrideReason `json:"reason"`
+	}
+
+	// HostSettingOverrideReason explains why an external setting of the host
+	// differs from the corresponding internal setting.
+	HostSettingOverrideReason string
+
+	// HostCollateralBudgetStatus reports how much of the host's collateral
+	// budget is committed to storage obligations. Locked is the collateral of
+	// obligations whose contracts have been confirmed, and Pending is the
+	// collateral of obligations whose contracts are still unconfirmed plus the
+	// collateral of contracts that are being negotiated. Remaining is the part
+	// of the budget that is available to new contracts.
+	HostCollateralBudgetStatus struct {
+		Budget    types.Currency `json:"budget"`
+		Locked    types.Currency `json:"locked"`
+		Risked    types.Currency `json:"risked"`
+		Pending   types.Currency `json:"pending"`
+		Remaining types.Currency `json:"remaining"`
+	}
+
+	// HostProofConstructionMetrics reports the host's estimate of how long it
+	// takes to construct the storage proofs of its obligations. The estimate
+	// assumes that all of an obligation's data is read at ReadThroughput, the
+	// throughput of the slowest storage folder. Revisions that would make the
+	// estimate of an obligation exceed WindowFraction of its proof window are
+	// rejected. MaxProofTime and MaxObligationSize are the limits for a
+	// contract with the host's current WindowSize.
+	HostProofConstructionMetrics struct {
+		ReadThroughput    uint64        `json:"readthroughput"` // bytes per second
+		WindowFraction    float64       `json:"windowfraction"`
+		MaxProofTime      time.Duration `json:"maxprooftime"`
+		MaxObligationSize uint64        `json:"maxobligationsize"` // bytes
+
+		// ObligationsOverThreshold lists the unresolved obligations whose
+		// estimate exceeds the limit for their proof window.
+		ObligationsOverThreshold []HostProofConstructionEstimate `json:"obligationsoverthreshold"`
+	}
+
+	// HostProofConstructionEstimate is the estimated time to construct the
+	// storage proof of an obligation, and the limit for its proof window.
+	HostProofConstructionEstimate struct {
+		ID            types.FileContractID `json:"id"`
+		Size          uint64               `json:"size"` // bytes
+		EstimatedTime time.Duration        `json:"estimatedtime"`
+		MaxTime       time.Duration        `json:"maxtime"`
+	}
+
+	// ProofTestStage is a stage of the construction of a storage proof by
+	// TestProof.
+	ProofTestStage string
+
+	// ProofTestResult is the result of constructing and verifying the storage
+	// proof of an obligation without submitting it. The segment is chosen as
+	// if the current block were the trigger block of the contract. If the
+	// test failed, FailedStage is the stage that failed and Error describes
+	// why. The durations are zero for the stages that were not reached.
+	ProofTestResult struct {
+		ContractID   types.FileContractID `json:"contractid"`
+		Success      bool                 `json:"success"`
+		SegmentIndex uint64               `json:"segmentindex"`
+		SectorIndex  uint64               `json:"sectorindex"`
+		FailedStage  ProofTestStage       `json:"failedstage,omitempty"`
+		Error        string               `json:"error,omitempty"`
+
+		SegmentSelectionTime  time.Duration `json:"segmentselectiontime"`
+		SectorReadTime        time.Duration `json:"sectorreadtime"`
+		ProofConstructionTime time.Duration `json:"proofconstructiontime"`
+		VerificationTime      time.Duration `json:"verificationtime"`
+	}
+
+	// HostSectorDeletionMetrics reports the sectors that are queued for
+	// removal from the host's storage. Queued sectors still occupy storage
+	// until they are removed.
+	HostSectorDeletionMetrics struct {
+		QueuedSectors uint64 `json:"queuedsectors"`
+		QueuedBytes   uint64 `json:"queuedbytes"`
 	}
 
 	// StorageObligation contains information about a storage obligation that
@@ -119,6 +589,101 @@ type (
 		ProofConstructed    bool   `json:"proofconstructed"`
 		ProofConfirmed      bool   `json:"proofconfirmed"`
 		ObligationStatus    uint64 `json:"obligationstatus"`
+
+		// FailureReason is set when the obligation has failed, and explains
+		// why the storage proof was missed. FailureError is the last error
+		// that the host encountered while attempting the storage proof.
+		FailureReason StorageProofFailureReason `json:"failurereason"`
+		FailureError  string                    `json:"failureerror"`
+
+		// ExpirationReason is the reason given by the host operator when the
+		// obligation was terminated early with ExpireContract.
+		ExpirationReason string `json:"expirationreason"`
+	}
+
+	// StorageProofFailureReason explains why the host failed to get a storage
+	// proof onto the blockchain.
+	StorageProofFailureReason string
+
+	// HostStorageProofDiagnostics summarizes the storage obligations that the
+	// host has failed, keyed by the reason that the storage proof was missed.
+	HostStorageProofDiagnostics struct {
+		FailedObligations uint64                               `json:"failedobligations"`
+		FailureReasons    map[StorageProofFailureReason]uint64 `json:"failurereasons"`
+	}
+
+	// HostWindDownStatus reports the progress of a host that is winding down.
+	// A host that is winding down does not form or renew contracts, and keeps
+	// its storage until its remaining obligations resolve. LockedCollateral
+	// is the collateral of the unresolved obligations, and FinalHeight is the
+	// proof deadline of the last of them, or 0 if there are none.
+	HostWindDownStatus struct {
+		Active               bool              `json:"active"`
+		RemainingObligations uint64            `json:"remainingobligations"`
+		LockedCollateral     types.Currency    `json:"lockedcollateral"`
+		FinalHeight          types.BlockHeight `json:"finalheight"`
+	}
+
+	// HostWindDownCompaction reports the progress of the compaction of the
+	// storage of a host that is winding down. Current is the storage folder
+	// that is being removed, and its ProgressNumerator and
+	// ProgressDenominator report how much of its data has been moved.
+	// Removed lists the folders that have been removed so far, and Error is
+	// the error that stopped the compaction, if any.
+	HostWindDownCompaction struct {
+		Active  bool                    `json:"active"`
+		Current *StorageFolderMetadata  `json:"current"`
+		Removed []StorageFolderMetadata `json:"removedfolders"`
+		Error   string                  `json:"error"`
+	}
+
+	// SizeHistogramBucket is a bucket of the histogram of the sizes of the
+	// host's active contracts. It holds the contracts whose size is at least
+	// MinSize and less than MaxSize, where a MaxSize of 0 means that the
+	// bucket has no upper bound. Size is the total size of the data of the
+	// contracts, and LockedCollateral is their total locked collateral.
+	SizeHistogramBucket struct {
+		MinSize          uint64         `json:"minsize"`
+		MaxSize          uint64         `json:"maxsize"`
+		Contracts        uint64         `json:"contracts"`
+		Size             uint64         `json:"size"`
+		LockedCollateral types.Currency `json:"lockedcollateral"`
+	}
+
+	// ProfitabilityReport summarizes the revenue and costs of a storage
+	// obligation. The revenue of a contract is paid once its storage proof
+	// is confirmed. Until then, the storage revenue is split by the part of
+	// the contract that has elapsed into the revenue earned so far and
+	// EstimatedFutureRevenue. NetProfit is the revenue minus the transaction
+	// fees and the lost collateral. As a Currency cannot be negative, Loss is
+	// set if the costs exceed the revenue, in which case NetProfit is the
+	// amount that was lost.
+	ProfitabilityReport struct {
+		ContractID       types.FileContractID `json:"contractid"`
+		ObligationStatus uint64               `json:"obligationstatus"`
+
+		ContractCompensation   types.Currency `json:"contractcompensation"`
+		StorageRevenue         types.Currency `json:"storagerevenue"`
+		BandwidthRevenue       types.Currency `json:"bandwidthrevenue"`
+		EstimatedFutureRevenue types.Currency `json:"estimatedfuturerevenue"`
+
+		LockedCollateral types.Currency `json:"lockedcollateral"`
+		LostCollateral   types.Currency `json:"lostcollateral"`
+		TransactionFees  types.Currency `json:"transactionfees"`
+
+		NetProfit types.Currency `json:"netprofit"`
+		Loss      bool           `json:"loss"`
+	}
+
+	// HostStorageFolderRelease is the height at which a storage folder no
+	// longer holds sectors of unresolved storage obligations, and can be
+	// removed without moving data. Sectors is the number of such sectors in
+	// the folder. A FreeHeight of 0 means that the folder is free already.
+	HostStorageFolderRelease struct {
+		Index      uint16            `json:"index"`
+		Path       string            `json:"path"`
+		Sectors    uint64            `json:"sectors"`
+		FreeHeight types.BlockHeight `json:"freeheight"`
 	}
 
 	// HostWorkingStatus reports the working state of a host. Can be one of
@@ -139,6 +704,35 @@ type (
 		// AnnounceAddress submits an announcement using the given address.
 		AnnounceAddress(NetAddress) error
 
+		// CollateralBudgetStatus returns how much of the host's collateral
+		// budget is committed to storage obligations and how much remains.
+		CollateralBudgetStatus() HostCollateralBudgetStatus
+
+		// ContractProfitability returns the revenue, collateral, and fees of
+		// the storage obligation of a file contract, and whether the contract
+		// is expected to make a profit.
+		ContractProfitability(id types.FileContractID) (ProfitabilityReport, error)
+
+		// ContractSizeHistogram returns the number, total size, and total
+		// locked collateral of the host's active contracts, bucketed by the
+		// size of their data.
+		ContractSizeHistogram() []SizeHistogramBucket
+
+		// EffectiveExternalSettings returns the external settings that the
+		// settings RPC would serve right now, along with the reason for every
+		// field that differs from the internal settings.
+		EffectiveExternalSettings() HostEffectiveSettings
+
+		// ExpireContract terminates a storage obligation before the end of its
+		// contract, removing the sectors of the obligation from the host. The
+		// host will not submit a storage proof for the contract, so the
+		// collateral at risk in the contract is lost. The reason is logged and
+		// reported in StorageObligations.
+		ExpireContract(id types.FileContractID, reason string) error
+
+		// ExportSettings writes the host's internal settings to w as JSON.
+		ExportSettings(w io.Writer) error
+
 		// ExternalSettings returns the settings of the host as seen by an
 		// untrusted node querying the host for settings.
 		ExternalSettings() HostExternalSettings
@@ -146,6 +740,17 @@ type (
 		// FinancialMetrics returns the financial statistics of the host.
 		FinancialMetrics() HostFinancialMetrics
 
+		// HealthCheck checks that the host is ready to accept new contracts:
+		// that every storage folder is writable, that the wallet can pay for
+		// an announcement, that the consensus set is synced, and that the
+		// host is reachable at its address.
+		HealthCheck() (HostHealthStatus, error)
+
+		// ImportSettings reads internal settings that were written by
+		// ExportSettings from r and applies them like SetInternalSettings.
+		// Every setting must be present in r.
+		ImportSettings(r io.Reader) error
+
 		// InternalSettings returns the host's internal settings, including
 		// potentially private or sensitive information.
 		InternalSettings() HostInternalSettings
@@ -154,16 +759,75 @@ type (
 		// have been made to the host.
 		NetworkMetrics() HostNetworkMetrics
 
+		// ProofConstructionMetrics returns the host's estimate of how long it
+		// takes to construct storage proofs, and the obligations whose
+		// estimate exceeds the limit for their proof window.
+		ProofConstructionMetrics() HostProofConstructionMetrics
+
 		// PublicKey returns the public key of the host.
 		PublicKey() types.SiaPublicKey
 
+		// QuarantinedObligations returns the storage obligations that the
+		// host has quarantined because they failed an invariant check.
+		QuarantinedObligations() []HostQuarantinedObligation
+
+		// ResolveQuarantinedObligation applies a repair or resolution action
+		// to a quarantined storage obligation. The reason is required by
+		// HostQuarantineActionFail, and is reported like the reason of
+		// ExpireContract.
+		ResolveQuarantinedObligation(id types.FileContractID, action HostQuarantineAction, reason string) error
+
+		// SectorDeletionMetrics returns the number of sectors that are queued
+		// for removal from the host's storage.
+		SectorDeletionMetrics() HostSectorDeletionMetrics
+
+		// SessionMemoryMetrics returns the amount of memory currently in use
+		// by RPC sessions and the highest usage since the host started.
+		SessionMemoryMetrics() HostSessionMemoryMetrics
+
 		// SetInternalSettings sets the hosting parameters of the host.
 		SetInternalSettings(HostInternalSettings) error
 
+		// SetNetAddress sets the address that the host announces, without
+		// changing the other internal settings. The host announces the
+		// address if it changed.
+		SetNetAddress(NetAddress) error
+
+		// SetWindDown starts or stops winding down the host. While winding
+		// down, the host does not accept new contracts and refuses renewals
+		// with ErrHostWindingDown, and AcceptingContracts cannot be enabled.
+		// Stopping the wind down does not start accepting contracts again.
+		SetWindDown(bool) error
+
+		// StorageAllocationIter streams the location of every sector of the
+		// host's storage obligations. The channel is closed once every sector
+		// has been sent, or when the host shuts down.
+		StorageAllocationIter() <-chan SectorLocationEntry
+
+		// StorageAllocationMap returns the location of every sector of the
+		// host's storage obligations, keyed by sector root. The map can be
+		// very large, StorageAllocationIter should be preferred.
+		StorageAllocationMap() map[crypto.Hash]SectorLocation
+
+		// StorageFolderReleases returns the height at which each storage
+		// folder no longer holds sectors of unresolved storage obligations.
+		StorageFolderReleases() ([]HostStorageFolderRelease, error)
+
 		// StorageObligations returns the set of storage obligations held by
-		// the host.
+		// the host. Resolved obligations that have been pruned are not
+		// included, FinancialMetrics reports how many were pruned.
 		StorageObligations() []StorageObligation
 
+		// StorageProofDiagnostics returns the number of failed storage
+		// obligations, grouped by the reason that the storage proof was
+		// missed.
+		StorageProofDiagnostics() HostStorageProofDiagnostics
+
+		// TestProof constructs the storage proof of an obligation as if the
+		// current block were the trigger block of its contract, and verifies
+		// it against the Merkle root of the contract without submitting it.
+		TestProof(id types.FileContractID) (ProofTestResult, error)
+
 		// ConnectabilityStatus returns the connectability status of the host, that
 		// is, if it can connect to itself on the configured NetAddress.
 		ConnectabilityStatus() HostConnectabilityStatus
@@ -172,8 +836,109 @@ type (
 		// settings calls are increasing.
 		WorkingStatus() HostWorkingStatus
 
+		// WindDownCompact starts to remove the storage folders whose sectors
+		// fit into the remaining capacity of the other folders in the
+		// background, starting with the folder that holds the fewest sectors,
+		// so that their disks can be freed before the obligations resolve.
+		WindDownCompact() error
+
+		// WindDownCompaction returns the progress of the last compaction
+		// that was started by WindDownCompact.
+		WindDownCompaction() HostWindDownCompaction
+
+		// WindDownStatus returns whether the host is winding down, along
+		// with the collateral and obligations that remain.
+		WindDownStatus() HostWindDownStatus
+
+		// VerifyContractFormation checks whether the host would accept a file
+		// contract proposed by a renter in the FormContract RPC. Checks that
+		// depend on the renter's transaction set, such as the transaction
+		// fees and the renter's signatures, are not performed.
+		VerifyContractFormation(proposal types.FileContract) (HostContractAssessment, error)
+
 		// The storage manager provides an interface for adding and removing
 		// storage folders and data sectors to the host.
 		StorageManager
+
+		// The alerter reports conditions on the host that need the attention
+		// of the operator.
+		HostAlerter
 	}
 )
+
+// TotalRevenue returns the sum of the host's contract compensation, storage
+// revenue, and bandwidth revenue.
+func (fm HostFinancialMetrics) TotalRevenue() types.Currency {
+	return fm.ContractCompensation.
+		Add(fm.StorageRevenue).
+		Add(fm.DownloadBandwidthRevenue).
+		Add(fm.UploadBandwidthRevenue)
+}
+
+// PotentialTotalRevenue returns the sum of the host's potential contract
+// compensation, storage revenue, and bandwidth revenue.
+func (fm HostFinancialMetrics) PotentialTotalRevenue() types.Currency {
+	return fm.PotentialContractCompensation.
+		Add(fm.PotentialStorageRevenue).
+		Add(fm.PotentialDownloadBandwidthRevenue).
+		Add(fm.PotentialUploadBandwidthRevenue)
+}
+
+// Validate checks that the settings are consistent, returning an error that
+// names every invalid setting. Checks that depend on the state of the host,
+// such as whether it has an unlock hash, are left to SetInternalSettings.
+func (s HostInternalSettings) Validate() error {
+	var errs []error
+	if s.NetAddress != "" {
+		if err := s.NetAddress.IsValid(); err != nil {
+			errs = append(errs, errors.New("invalid NetAddress: "+err.Error()))
+		}
+	}
+	if s.WindowSize == 0 {
+		errs = append(errs, errors.New("WindowSize cannot be zero"))
+	}
+	if s.MaxDuration <= s.WindowSize {
+		errs = append(errs, errors.New("MaxDuration must be greater than WindowSize"))
+	}
+	if s.ObligationRetentionBlocks != 0 && s.ObligationRetentionBlocks < types.MaturityDelay {
+		// Obligations are reopened when a reorg undoes their resolution,
+		// which requires them to still be in the database.
+		errs = append(errs, fmt.Errorf("ObligationRetentionBlocks must be 0 or at least %v", types.MaturityDelay))
+	}
+	if (s.TLSCertFile == "") != (s.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLSCertFile and TLSKeyFile must be set together"))
+	}
+	if s.TLSRequired && s.TLSCertFile == "" {
+		errs = append(errs, errors.New("TLSRequired requires TLSCertFile and TLSKeyFile to be set"))
+	}
+	if s.MaxCollateralFraction < 0 {
+		errs = append(errs, errors.New("MaxCollateralFraction cannot be negative"))
+	} else if math.IsNaN(s.MaxCollateralFraction) || math.IsInf(s.MaxCollateralFraction, 0) {
+		errs = append(errs, errors.New("MaxCollateralFraction must be a finite number"))
+	}
+	return build.JoinErrors(errs, "; ")
+}
+
+// StoragePriceToConsensus converts a storage price in siacoins per terabyte
+// per month to hastings per byte per block.
+func StoragePriceToConsensus(siacoinsMonthTB uint64) types.Currency {
+	return types.SiacoinPrecision.Mul64(siacoinsMonthTB).Div(BlockBytesPerMonthTerabyte)
+}
+
+// StoragePriceToConsensusFromFloat converts a storage price in siacoins per
+// terabyte per month to hastings per byte per block, allowing for fractional
+// prices. The float is scaled by SiacoinPrecision exactly, so the only loss of
+// precision is the truncation of the result to a whole number of hastings per
+// byte per block. For integer inputs the result is identical to
+// StoragePriceToConsensus. ErrInvalidHumanPrice is returned for negative,
+// infinite, and NaN inputs.
+func StoragePriceToConsensusFromFloat(siacoinsMonthTB float64) (types.Currency, error) {
+	if math.IsNaN(siacoinsMonthTB) || math.IsInf(siacoinsMonthTB, 0) || siacoinsMonthTB < 0 {
+		return types.Currency{}, ErrInvalidHumanPrice
+	}
+	// A float64 has a 53 bit mantissa and SiacoinPrecision needs fewer than 80
+	// bits, so 256 bits of precision keep the product exact.
+	precision := new(big.Float).SetPrec(256).SetInt(types.SiacoinPrecision.Big())
+	hastingsMonthTB, _ := new(big.Float).SetPrec(256).Mul(precision, big.NewFloat(siacoinsMonthTB)).Int(nil)
+	return types.NewCurrency(hastingsMonthTB).Div(BlockBytesPerMonthTerabyte), nil
+}
@@ -0,0 +1,182 @@
+package host
+
+// alerts.go keeps track of conditions on the host that the operator should
+// know about immediately, such as failed storage proofs, lost collateral,
+// failing storage folders, and an exhausted collateral budget. Alerts are
+// deduplicated by their category and cause, and are cleared as soon as the
+// host observes that the underlying condition has resolved.
+
+import (
+	"fmt"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// alertKey uniquely identifies an alert on the host. Raising an alert with a
+// key that is already active will not create a new alert.
+type alertKey struct {
+	category modules.HostAlertCategory
+	cause    string
+}
+
+// keyForAlert returns the key that is used to deduplicate an alert. Storage
+// proof and collateral alerts are deduplicated across all obligations, because
+// a single problem such as a failing disk will typically cause many
+// obligations to fail at once.
+func keyForAlert(alert modules.HostAlert) alertKey {
+	switch alert.Category {
+	case modules.HostAlertCategoryStorageProof, modules.HostAlertCategoryCollateral:
+		return alertKey{category: alert.Category}
+	default:
+		return alertKey{category: alert.Category, cause: alert.Cause}
+	}
+}
+
+// raiseAlert adds an alert to the host. If an alert with the same key is
+// already active, the existing alert is updated in place and subscribers are
+// not notified a second time.
+func (h *Host) raiseAlert(alert modules.HostAlert) {
+	key := keyForAlert(alert)
+	existing, exists := h.alerts[key]
+	if exists {
+		existing.Cause = alert.Cause
+		existing.Message = alert.Message
+		existing.Severity = alert.Severity
+		existing.Occurrences++
+		h.alerts[key] = existing
+		return
+	}
+
+	alert.Occurrences = 1
+	alert.Cleared = false
+	h.alerts[key] = alert
+	h.log.Printf("ALERT: %v %v: %v\n", alert.Severity, alert.Category, alert.Message)
+	for _, subscriber := range h.alertSubscribers {
+		subscriber.ProcessHostAlert(alert)
+	}
+}
+
+// clearAlert removes the alert with the provided category and cause from the
+// host, notifying subscribers that the condition has resolved. Clearing an
+// alert that is not active is a no-op.
+func (h *Host) clearAlert(category modules.HostAlertCategory, cause string) {
+	key := keyForAlert(modules.HostAlert{Category: category, Cause: cause})
+	alert, exists := h.alerts[key]
+	if !exists {
+		return
+	}
+	delete(h.alerts, key)
+
+	alert.Cleared = true
+	for _, subscriber := range h.alertSubscribers {
+		subscriber.ProcessHostAlert(alert)
+	}
+}
+
+// managedRaiseAlert calls raiseAlert while holding the host lock.
+func (h *Host) managedRaiseAlert(alert modules.HostAlert) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.raiseAlert(alert)
+}
+
+// updateCollateralBudgetAlert clears the collateral budget alert if the host
+// has enough room left in its collateral budget to accept a contract at its
+// maximum collateral.
+func (h *Host) updateCollateralBudgetAlert() {
+	required := h.financialMetrics.LockedStorageCollateral.Add(h.settings.MaxCollateral)
+	if required.Cmp(h.settings.CollateralBudget) <= 0 {
+		h.clearAlert(modules.HostAlertCategoryCollateralBudget, "")
+	}
+}
+
+// updateStorageFolderAlerts checks the health statistics of each storage
+// folder. A folder that has reported new failed reads or writes since the
+// previous check raises an alert, and a folder that has not reported any new
+// failures has its alert cleared. updateStorageFolderAlerts is called once per
+// block.
+func (h *Host) updateStorageFolderAlerts() {
+	seen := make(map[string]struct{})
+	for _, sf := range h.StorageFolders() {
+		seen[sf.Path] = struct{}{}
+		failures := sf.FailedReads + sf.FailedWrites
+		prevFailures, tracked := h.storageFolderFailures[sf.Path]
+		h.storageFolderFailures[sf.Path] = failures
+		if !tracked {
+			// The first observation of a folder only establishes a baseline,
+			// failures from before the host started are not reported.
+			continue
+		}
+
+		if failures > prevFailures {
+			h.raiseAlert(modules.HostAlert{
+				Severity: modules.HostAlertSeverityWarning,
+				Category: modules.HostAlertCategoryStorageFolder,
+				Message:  fmt.Sprintf("storage folder has %v failed reads and %v failed writes", sf.FailedReads, sf.FailedWrites),
+				Cause:    sf.Path,
+			})
+		} else {
+			h.clearAlert(modules.HostAlertCategoryStorageFolder, sf.Path)
+		}
+	}
+
+	// Clear the alerts of any storage folders that have been removed.
+	for path := range h.storageFolderFailures {
+		if _, exists := seen[path]; !exists {
+			delete(h.storageFolderFailures, path)
+			h.clearAlert(modules.HostAlertCategoryStorageFolder, path)
+		}
+	}
+}
+
+// Alerts returns the alerts that are currently active on the host.
+func (h *Host) Alerts() []modules.HostAlert {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	err := h.tg.Add()
+	if err != nil {
+		build.Critical("Call to Alerts after close")
+	}
+	defer h.tg.Done()
+
+	alerts := make([]modules.HostAlert, 0, len(h.alerts))
+	for _, alert := range h.alerts {
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+// AlertSubscribe adds a subscriber to the host. All currently active alerts
+// are sent to the subscriber before AlertSubscribe returns.
+func (h *Host) AlertSubscribe(subscriber modules.HostAlertSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Check that this subscriber is not already subscribed.
+	for _, s := range h.alertSubscribers {
+		if s == subscriber {
+			build.Critical("refusing to double-subscribe subscriber")
+		}
+	}
+	h.alertSubscribers = append(h.alertSubscribers, subscriber)
+
+	// Send the new subscriber the set of active alerts.
+	for _, alert := range h.alerts {
+		subscriber.ProcessHostAlert(alert)
+	}
+}
+
+// AlertUnsubscribe removes a subscriber from the host. If the subscriber is
+// not subscribed, AlertUnsubscribe does nothing.
+func (h *Host) AlertUnsubscribe(subscriber modules.HostAlertSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := range h.alertSubscribers {
+		if h.alertSubscribers[i] == subscriber {
+			h.alertSubscribers = append(h.alertSubscribers[0:i], h.alertSubscribers[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// alertRecorder is a HostAlertSubscriber that records every alert it
+// receives.
+type alertRecorder struct {
+	alerts []modules.HostAlert
+}
+
+// ProcessHostAlert implements modules.HostAlertSubscriber.
+func (ar *alertRecorder) ProcessHostAlert(alert modules.HostAlert) {
+	ar.alerts = append(ar.alerts, alert)
+}
+
+// TestHostAlerts checks that alerts are deduplicated, cleared, and sent to
+// subscribers.
+func TestHostAlerts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := blankHostTester("TestHostAlerts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	var ar alertRecorder
+	ht.host.AlertSubscribe(&ar)
+	if len(ar.alerts) != 0 {
+		t.Fatal("subscriber received alerts from a fresh host")
+	}
+
+	// Raise the same storage proof alert several times with different
+	// obligations, only a single alert should be active.
+	ht.host.mu.Lock()
+	for _, cause := range []string{"a", "b", "c"} {
+		ht.host.raiseAlert(modules.HostAlert{
+			Severity: modules.HostAlertSeverityCritical,
+			Category: modules.HostAlertCategoryStorageProof,
+			Message:  "the host missed a storage proof",
+			Cause:    cause,
+		})
+	}
+	ht.host.mu.Unlock()
+	alerts := ht.host.Alerts()
+	if len(alerts) != 1 {
+		t.Fatal("expected one active alert, got", len(alerts))
+	}
+	if alerts[0].Occurrences != 3 || alerts[0].Cause != "c" {
+		t.Error("alert was not updated correctly:", alerts[0])
+	}
+	if len(ar.alerts) != 1 {
+		t.Fatal("subscriber should have been notified exactly once, got", len(ar.alerts))
+	}
+
+	// Storage folder alerts are kept separately for each folder.
+	ht.host.mu.Lock()
+	for _, path := range []string{"/foo", "/bar", "/foo"} {
+		ht.host.raiseAlert(modules.HostAlert{
+			Severity: modules.HostAlertSeverityWarning,
+			Category: modules.HostAlertCategoryStorageFolder,
+			Cause:    path,
+		})
+	}
+	ht.host.mu.Unlock()
+	if len(ht.host.Alerts()) != 3 {
+		t.Fatal("expected three active alerts, got", len(ht.host.Alerts()))
+	}
+
+	// Clear the storage proof alert and one of the folder alerts.
+	ht.host.mu.Lock()
+	ht.host.clearAlert(modules.HostAlertCategoryStorageProof, "")
+	ht.host.clearAlert(modules.HostAlertCategoryStorageFolder, "/foo")
+	ht.host.clearAlert(modules.HostAlertCategoryStorageFolder, "/baz")
+	ht.host.mu.Unlock()
+	alerts = ht.host.Alerts()
+	if len(alerts) != 1 || alerts[0].Cause != "/bar" {
+		t.Fatal("wrong alerts remaining after clearing:", alerts)
+	}
+	if len(ar.alerts) != 5 {
+		t.Fatal("subscriber received the wrong number of alerts:", len(ar.alerts))
+	}
+	if !ar.alerts[3].Cleared || !ar.alerts[4].Cleared {
+		t.Error("subscriber was not told that the alerts were cleared")
+	}
+
+	// A new subscriber should receive the active alerts, and an unsubscribed
+	// subscriber should receive nothing further.
+	var ar2 alertRecorder
+	ht.host.AlertSubscribe(&ar2)
+	if len(ar2.alerts) != 1 {
+		t.Fatal("new subscriber did not receive the active alerts")
+	}
+	ht.host.AlertUnsubscribe(&ar)
+	ht.host.mu.Lock()
+	ht.host.clearAlert(modules.HostAlertCategoryStorageFolder, "/bar")
+	ht.host.mu.Unlock()
+	if len(ar.alerts) != 5 {
+		t.Error("unsubscribed subscriber received an alert")
+	}
+	if len(ar2.alerts) != 2 {
+		t.Error("subscriber did not receive the cleared alert")
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 var (
@@ -65,6 +66,7 @@ func (h *Host) managedAnnounce(addr modules.NetAddress) error {
 		txnBuilder.Drop()
 		return err
 	}
+	h.managedRecordSpending(modules.SpendingPurposeAnnouncement, types.FileContractID{}, fee, txnSet)
 
 	h.mu.Lock()
 	h.announced = true
@@ -146,3 +148,50 @@ func (h *Host) AnnounceAddress(addr modules.NetAddress) error {
 	h.mu.Unlock()
 	return nil
 }
+
+// SetNetAddress sets the address that the host announces, leaving the other
+// internal settings unchanged. The host must be reachable at the address. If
+// the address changed, the host announces the new address. An empty address
+// makes the host use its automatically detected address again.
+func (h *Host) SetNetAddress(addr modules.NetAddress) error {
+	err := h.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer h.tg.Done()
+
+	if addr != "" {
+		err = addr.IsStdValid()
+		if err != nil {
+			return build.ExtendErr("net address not updated, invalid net address", err)
+		}
+		if addr.IsLocal() && build.Release != "testing" {
+			return errors.New("net address not updated, address is local")
+		}
+		err = h.managedCheckConnectable(addr)
+		if err != nil {
+			return build.ExtendErr("net address not updated, host is not reachable at "+string(addr), err)
+		}
+	}
+
+	h.mu.Lock()
+	changed := h.settings.NetAddress != addr
+	if changed {
+		h.settings.NetAddress = addr
+		h.revisionNumber++
+		h.announced = false
+		err = h.saveSync()
+	}
+	h.mu.Unlock()
+	if err != nil {
+		return errors.New("net address updated, but failed saving to disk: " + err.Error())
+	} else if !changed {
+		return nil
+	}
+
+	err = h.Announce()
+	if err != nil {
+		return build.ExtendErr("net address updated, but announcement failed", err)
+	}
+	return nil
+}
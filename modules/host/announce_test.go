@@ -2,6 +2,7 @@ package host
 
 import (
 	"bytes"
+	"reflect"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/modules"
@@ -136,3 +137,63 @@ func TestHostAnnounceAddress(t *testing.T) {
 		t.Error("announcement has wrong host key")
 	}
 }
+
+// TestHostSetNetAddress checks that SetNetAddress only changes the net address
+// of the host, announces the new address, and rejects unreachable addresses.
+func TestHostSetNetAddress(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	af, err := newAnnouncementFinder(ht.cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer af.Close()
+
+	// Addresses that are invalid or at which the host is not reachable should
+	// be rejected.
+	settings := ht.host.InternalSettings()
+	for _, addr := range []modules.NetAddress{"foo", "localhost:1"} {
+		if err := ht.host.SetNetAddress(addr); err == nil {
+			t.Errorf("expected %q to be rejected", addr)
+		}
+	}
+	if ht.host.InternalSettings().NetAddress != settings.NetAddress {
+		t.Fatal("net address changed after a rejected address")
+	}
+
+	// The host is reachable at its auto address, which should be set and
+	// announced.
+	addr := ht.host.autoAddress
+	if err := ht.host.SetNetAddress(addr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ht.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if len(af.netAddresses) != 1 || af.netAddresses[0] != addr {
+		t.Fatal("could not find announcement of the new address:", af.netAddresses)
+	}
+	settings.NetAddress = addr
+	if got := ht.host.InternalSettings(); !reflect.DeepEqual(got, settings) {
+		t.Fatal("settings other than the net address changed")
+	}
+
+	// Setting the same address again should not announce it again.
+	if err := ht.host.SetNetAddress(addr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ht.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if len(af.netAddresses) != 1 {
+		t.Fatal("unchanged address was announced again")
+	}
+}
@@ -0,0 +1,75 @@
+package host
+
+import (
+	"net"
+	"sync/atomic"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// rpcBandwidth tracks the number of bytes that the host has read from and
+// written to renters during one type of RPC.
+type rpcBandwidth struct {
+	atomicRead    uint64
+	atomicWritten uint64
+}
+
+// metrics returns the bandwidth that has been consumed by the RPC type.
+func (bw *rpcBandwidth) metrics() modules.HostRPCBandwidth {
+	return modules.HostRPCBandwidth{
+		DownloadBandwidth: atomic.LoadUint64(&bw.atomicRead),
+		UploadBandwidth:   atomic.LoadUint64(&bw.atomicWritten),
+	}
+}
+
+// SetBandwidthManager sets the manager of the node's bandwidth budget that the
+// connections of renters draw from. A nil manager stops limiting them.
+func (h *Host) SetBandwidthManager(bm modules.BandwidthManager) {
+	h.bandwidthLimiter.SetManager(bm)
+}
+
+// countingConn is a net.Conn that counts the bytes read from and written to
+// the underlying connection. The type of the RPC is not known until the RPC
+// specifier has been read, so the bytes are held by the countingConn until
+// attribute is called, after which they are added to the rpcBandwidth of the
+// RPC as they are transferred.
+type countingConn struct {
+	net.Conn
+	read      uint64
+	written   uint64
+	bandwidth *rpcBandwidth
+}
+
+// Read implements io.Reader.
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if c.bandwidth != nil {
+		atomic.AddUint64(&c.bandwidth.atomicRead, uint64(n))
+	} else {
+		c.read += uint64(n)
+	}
+	return n, err
+}
+
+// Write implements io.Writer.
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if c.bandwidth != nil {
+		atomic.AddUint64(&c.bandwidth.atomicWritten, uint64(n))
+	} else {
+		c.written += uint64(n)
+	}
+	return n, err
+}
+
+// attribute assigns the bytes transferred over the connection to the provided
+// rpcBandwidth, including the bytes that have already been transferred. A
+// connection can only be attributed once.
+func (c *countingConn) attribute(bw *rpcBandwidth) {
+	if c.bandwidth != nil {
+		return
+	}
+	atomic.AddUint64(&bw.atomicRead, c.read)
+	atomic.AddUint64(&bw.atomicWritten, c.written)
+	c.bandwidth = bw
+}
@@ -0,0 +1,124 @@
+package host
+
+import (
+	"encoding/json"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+// errCollateralFractionExceeded is returned if a file contract is provided
+// which would require the host to supply more collateral than the host's
+// MaxCollateralFraction allows relative to the renter's payout.
+var errCollateralFractionExceeded = ErrorInternal("file contract proposal expects the host to pay more collateral than its maximum collateral fraction allows")
+
+// collateralRate returns the collateral per byte per block that the host
+// offers to renters. If MaxCollateralFraction is set, the collateral is
+// clamped to MaxCollateralFraction * MinStoragePrice. Renters size the host's
+// collateral as (renter payout / storage price) * collateral, so the clamped
+// rate keeps the collateral of a contract within MaxCollateralFraction * renter
+// payout.
+func collateralRate(settings modules.HostInternalSettings) types.Currency {
+	if settings.MaxCollateralFraction <= 0 {
+		return settings.Collateral
+	}
+	limit := settings.MinStoragePrice.MulFloat(settings.MaxCollateralFraction)
+	if limit.Cmp(settings.Collateral) < 0 {
+		return limit
+	}
+	return settings.Collateral
+}
+
+// collateralClamped returns whether the collateral offered to renters is
+// lowered by the host's MaxCollateralFraction.
+func collateralClamped(settings modules.HostInternalSettings) bool {
+	return collateralRate(settings).Cmp(settings.Collateral) < 0
+}
+
+// withinCollateralFraction returns whether the collateral of a proposed file
+// contract is at most MaxCollateralFraction times the renter's payout. The
+// number of valid proof outputs must already have been checked.
+func withinCollateralFraction(settings modules.HostInternalSettings, fc types.FileContract, collateral types.Currency) bool {
+	if settings.MaxCollateralFraction <= 0 {
+		return true
+	}
+	return collateral.Cmp(fc.ValidProofOutputs[0].Value.MulFloat(settings.MaxCollateralFraction)) <= 0
+}
+
+// committedCollateral returns the collateral that counts against the host's
+// collateral budget: the collateral locked in storage obligations and the
+// collateral reserved by contracts that are being negotiated.
+func (h *Host) committedCollateral() types.Currency {
+	return h.financialMetrics.LockedStorageCollateral.Add(h.reservedCollateral)
+}
+
+// managedReserveCollateral reserves collateral for a file contract that is
+// being negotiated, returning errCollateralBudgetExceeded if the collateral
+// does not fit in the remaining collateral budget. The reservation must be
+// released with managedReleaseCollateral when the negotiation ends.
+func (h *Host) managedReserveCollateral(collateral types.Currency) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.committedCollateral().Add(collateral).Cmp(h.settings.CollateralBudget) > 0 {
+		return errCollateralBudgetExceeded
+	}
+	h.reservedCollateral = h.reservedCollateral.Add(collateral)
+	return nil
+}
+
+// managedReleaseCollateral releases collateral that was reserved with
+// managedReserveCollateral.
+func (h *Host) managedReleaseCollateral(collateral types.Currency) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.reservedCollateral.Cmp(collateral) < 0 {
+		h.log.Critical("releasing more collateral than has been reserved")
+		h.reservedCollateral = types.ZeroCurrency
+		return
+	}
+	h.reservedCollateral = h.reservedCollateral.Sub(collateral)
+}
+
+// CollateralBudgetStatus returns how much of the host's collateral budget is
+// committed to storage obligations and how much remains.
+func (h *Host) CollateralBudgetStatus() modules.HostCollateralBudgetStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	// Sum the collateral of the unresolved obligations whose contracts have
+	// not been confirmed yet.
+	var unconfirmed types.Currency
+	err := h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+			var so storageObligation
+			err := json.Unmarshal(soBytes, &so)
+			if err != nil {
+				return build.ExtendErr("unable to unmarshal storage obligation:", err)
+			}
+			if so.ObligationStatus == obligationUnresolved && !so.OriginConfirmed {
+				unconfirmed = unconfirmed.Add(so.LockedCollateral)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		h.log.Println(build.ExtendErr("database failed to provide storage obligations:", err))
+	}
+
+	locked := h.financialMetrics.LockedStorageCollateral
+	if locked.Cmp(unconfirmed) < 0 {
+		unconfirmed = locked
+	}
+	status := modules.HostCollateralBudgetStatus{
+		Budget:  h.settings.CollateralBudget,
+		Locked:  locked.Sub(unconfirmed),
+		Risked:  h.financialMetrics.RiskedStorageCollateral,
+		Pending: unconfirmed.Add(h.reservedCollateral),
+	}
+	if committed := h.committedCollateral(); committed.Cmp(status.Budget) < 0 {
+		status.Remaining = status.Budget.Sub(committed)
+	}
+	return status
+}
@@ -0,0 +1,182 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestCollateralBudgetStatus checks that reserved collateral and the
+// collateral of unconfirmed contracts are reported as pending and count
+// against the collateral budget.
+func TestCollateralBudgetStatus(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	sc := func(n uint64) types.Currency { return types.SiacoinPrecision.Mul64(n) }
+	settings := ht.host.InternalSettings()
+	settings.AcceptingContracts = true
+	settings.CollateralBudget = sc(100)
+	settings.MaxCollateral = sc(100)
+	if err := ht.host.SetInternalSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	checkStatus := func(locked, pending, remaining types.Currency) {
+		t.Helper()
+		status := ht.host.CollateralBudgetStatus()
+		if !status.Budget.Equals(sc(100)) || !status.Locked.Equals(locked) || !status.Pending.Equals(pending) || !status.Remaining.Equals(remaining) {
+			t.Fatalf("wrong collateral budget status: budget %v, locked %v, pending %v, remaining %v", status.Budget, status.Locked, status.Pending, status.Remaining)
+		}
+	}
+	checkStatus(types.ZeroCurrency, types.ZeroCurrency, sc(100))
+
+	// A second reservation that does not fit in the remaining budget should
+	// be rejected, and so should a proposal that needs the same collateral.
+	if err := ht.host.managedReserveCollateral(sc(60)); err != nil {
+		t.Fatal(err)
+	}
+	checkStatus(types.ZeroCurrency, sc(60), sc(40))
+	if err := ht.host.managedReserveCollateral(sc(60)); err != errCollateralBudgetExceeded {
+		t.Fatal("expected errCollateralBudgetExceeded, got", err)
+	}
+	ht.host.mu.RLock()
+	windowStart := ht.host.blockHeight + revisionSubmissionBuffer + 10
+	unlockHash := ht.host.unlockHash
+	ht.host.mu.RUnlock()
+	hostPayout := settings.MinContractPrice.Add(sc(60))
+	assessment, err := ht.host.VerifyContractFormation(types.FileContract{
+		WindowStart: windowStart,
+		WindowEnd:   windowStart + settings.WindowSize,
+		ValidProofOutputs: []types.SiacoinOutput{
+			{Value: types.SiacoinPrecision},
+			{Value: hostPayout, UnlockHash: unlockHash},
+		},
+		MissedProofOutputs: []types.SiacoinOutput{
+			{Value: types.SiacoinPrecision},
+			{Value: hostPayout, UnlockHash: unlockHash},
+			{Value: types.ZeroCurrency},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assessment.Accepted || assessment.RejectReason != errCollateralBudgetExceeded.Error() {
+		t.Error("proposal was not rejected for exceeding the collateral budget:", assessment.RejectReason)
+	}
+
+	// The collateral of an unconfirmed obligation is pending, and becomes
+	// locked once the contract is confirmed.
+	so, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	so.LockedCollateral = sc(30)
+	ht.host.managedLockStorageObligation(so.id())
+	err = ht.host.managedAddStorageObligation(so)
+	ht.host.managedUnlockStorageObligation(so.id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStatus(types.ZeroCurrency, sc(90), sc(10))
+	ht.host.managedReleaseCollateral(sc(60))
+	checkStatus(types.ZeroCurrency, sc(30), sc(70))
+	if _, err := ht.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	checkStatus(sc(30), types.ZeroCurrency, sc(70))
+}
+
+// TestMaxCollateralFraction checks that the host clamps the collateral that
+// it offers to MaxCollateralFraction * MinStoragePrice, and that it rejects
+// contracts whose collateral exceeds MaxCollateralFraction * renter payout.
+func TestMaxCollateralFraction(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	settings := ht.host.InternalSettings()
+	settings.AcceptingContracts = true
+	settings.MaxCollateralFraction = -1
+	if err := ht.host.SetInternalSettings(settings); err == nil {
+		t.Fatal("expected a negative MaxCollateralFraction to be rejected")
+	}
+
+	// A fraction above Collateral / MinStoragePrice does not clamp the
+	// collateral.
+	settings.Collateral = types.NewCurrency64(300)
+	settings.MinStoragePrice = types.NewCurrency64(100)
+	settings.MaxCollateralFraction = 4
+	if err := ht.host.SetInternalSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if es := ht.host.ExternalSettings(); !es.Collateral.Equals(settings.Collateral) {
+		t.Fatal("collateral should not be clamped, got", es.Collateral)
+	}
+
+	settings.MaxCollateralFraction = 1.5
+	if err := ht.host.SetInternalSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if es := ht.host.ExternalSettings(); !es.Collateral.Equals64(150) {
+		t.Fatal("expected the collateral to be clamped to 150, got", es.Collateral)
+	}
+	overrides := ht.host.EffectiveExternalSettings().Overrides
+	var found bool
+	for _, o := range overrides {
+		if o.Field == "collateral" {
+			found = o.Reason == modules.HostSettingOverrideCollateralFraction && o.InternalValue == "300" && o.EffectiveValue == "150"
+		}
+	}
+	if !found {
+		t.Fatal("clamped collateral was not reported as an override:", overrides)
+	}
+
+	// Proposals are checked against the renter's payout.
+	ht.host.mu.RLock()
+	windowStart := ht.host.blockHeight + revisionSubmissionBuffer + 10
+	unlockHash := ht.host.unlockHash
+	ht.host.mu.RUnlock()
+	proposal := func(renterPayout, collateral types.Currency) types.FileContract {
+		hostPayout := settings.MinContractPrice.Add(collateral)
+		return types.FileContract{
+			WindowStart: windowStart,
+			WindowEnd:   windowStart + settings.WindowSize,
+			ValidProofOutputs: []types.SiacoinOutput{
+				{Value: renterPayout},
+				{Value: hostPayout, UnlockHash: unlockHash},
+			},
+			MissedProofOutputs: []types.SiacoinOutput{
+				{Value: renterPayout},
+				{Value: hostPayout, UnlockHash: unlockHash},
+				{Value: types.ZeroCurrency},
+			},
+		}
+	}
+	renterPayout := types.SiacoinPrecision
+	assessment, err := ht.host.VerifyContractFormation(proposal(renterPayout, renterPayout.MulFloat(1.5)))
+	if err != nil {
+		t.Fatal(err)
+	} else if !assessment.Accepted {
+		t.Fatal("proposal within the collateral fraction was rejected:", assessment.RejectReason)
+	}
+	assessment, err = ht.host.VerifyContractFormation(proposal(renterPayout, renterPayout.MulFloat(1.5).Add(types.NewCurrency64(1))))
+	if err != nil {
+		t.Fatal(err)
+	} else if assessment.Accepted || assessment.RejectReason != errCollateralFractionExceeded.Error() {
+		t.Fatal("proposal exceeding the collateral fraction was not rejected:", assessment.RejectReason)
+	}
+}
@@ -16,6 +16,13 @@ const (
 	// support 6 month contracts when Sia leaves beta.
 	defaultMaxDuration = 144 * 30 * 6 // 6 months.
 
+	// defaultReadThroughput is the read throughput in bytes per second that
+	// is assumed for storage folders that have not served any sector reads
+	// yet. Throughput is measured from the sector reads, which only happen
+	// when renters download data, so the default is set to a conservative
+	// value for a slow spinning disk.
+	defaultReadThroughput = 20e6
+
 	// fileContractNegotiationTimeout indicates the amount of time that a
 	// renter has to negotiate a file contract with the host. A timeout is
 	// necessary to limit the impact of DoS attacks.
@@ -26,6 +33,12 @@ const (
 	// connection.
 	iteratedConnectionTime = 1200 * time.Second
 
+	// proofConstructionWindowFraction is the fraction of an obligation's proof
+	// window that the estimated construction time of its storage proof may
+	// take. The rest of the window is left for getting the proof onto the
+	// blockchain, even if the host was offline for part of the window.
+	proofConstructionWindowFraction = 0.5
+
 	// resubmissionTimeout defines the number of blocks that a host will wait
 	// before attempting to resubmit a transaction to the blockchain.
 	// Typically, this transaction will contain either a file contract, a file
@@ -115,6 +128,17 @@ var (
 	// with a number like 65 MiB.
 	defaultMaxReviseBatchSize = 17 * (1 << 20)
 
+	// defaultMaxSessionMemory defines the maximum number of bytes that all of
+	// the host's concurrent RPC sessions are allowed to hold in memory at
+	// once. The budget covers upload and download batches as well as the
+	// sector roots of the obligations being revised. 1 GiB leaves room for
+	// dozens of full revise batches in parallel.
+	defaultMaxSessionMemory = build.Select(build.Var{
+		Dev:      uint64(256 * (1 << 20)),
+		Standard: uint64(1 << 30),
+		Testing:  uint64(256 * (1 << 20)),
+	}).(uint64)
+
 	// defaultStoragePrice defines the starting price for hosts selling
 	// storage. We try to match a number that is both reasonably profitable and
 	// reasonably competitive.
@@ -180,6 +204,22 @@ var (
 		Testing:  time.Second * 3,
 	}).(time.Duration)
 
+	// obligationPruneInterval is how often the host prunes resolved storage
+	// obligations that are older than ObligationRetentionBlocks.
+	obligationPruneInterval = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: time.Hour,
+		Testing:  time.Millisecond * 100,
+	}).(time.Duration)
+
+	// proofTestInterval is how often the host tests the storage proofs of a
+	// sample of its obligations, if ProofTestSampleSize is set.
+	proofTestInterval = build.Select(build.Var{
+		Dev:      time.Minute * 10,
+		Standard: time.Hour * 24 * 7,
+		Testing:  time.Second * 3,
+	}).(time.Duration)
+
 	// revisionSubmissionBuffer describes the number of blocks ahead of time
 	// that the host will submit a file contract revision. The host will not
 	// accept any more revisions once inside the submission buffer.
@@ -197,6 +237,32 @@ var (
 		Testing:  time.Millisecond,
 	}).(time.Duration)
 
+	// sectorDeletionBatchSize is the maximum number of queued sectors that
+	// the host removes from the storage manager at once.
+	sectorDeletionBatchSize = build.Select(build.Var{
+		Dev:      100,
+		Standard: 250,
+		Testing:  10,
+	}).(int)
+
+	// sectorDeletionInterval is how long the host waits between removing
+	// batches of queued sectors, so that removing the sectors of expired
+	// storage obligations does not starve the host's other disk activity.
+	sectorDeletionInterval = build.Select(build.Var{
+		Dev:      time.Millisecond * 100,
+		Standard: time.Second,
+		Testing:  time.Millisecond * 10,
+	}).(time.Duration)
+
+	// sessionMemoryTimeout defines how long an RPC session will wait for
+	// memory to become available before rejecting the renter's request
+	// because the host is busy.
+	sessionMemoryTimeout = build.Select(build.Var{
+		Dev:      time.Second * 10,
+		Standard: time.Second * 30,
+		Testing:  time.Second * 3,
+	}).(time.Duration)
+
 	// workingStatusFirstCheck defines how frequently the Host's working status
 	// check runs
 	workingStatusFirstCheck = build.Select(build.Var{
@@ -235,6 +301,12 @@ var (
 	// using the id.
 	bucketActionItems = []byte("BucketActionItems")
 
+	// bucketSectorDeletions contains the sectors that are queued for removal
+	// from the storage manager. The key is a sector root, and the value is the
+	// number of times that the sector is to be removed, as a big endian
+	// uint64.
+	bucketSectorDeletions = []byte("BucketSectorDeletions")
+
 	// bucketStorageObligations contains a set of serialized
 	// 'storageObligations' sorted by their file contract id.
 	bucketStorageObligations = []byte("BucketStorageObligations")
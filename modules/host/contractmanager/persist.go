@@ -19,6 +19,12 @@ type (
 		Index uint16
 		Path  string
 		Usage []uint64
+
+		FailedReads      uint64
+		FailedWrites     uint64
+		SuccessfulReads  uint64
+		SuccessfulWrites uint64
+		LastError        string
 	}
 
 	// savedSettings contains fields that are saved atomically to disk inside
@@ -35,6 +41,12 @@ func (sf *storageFolder) savedStorageFolder() savedStorageFolder {
 		Index: sf.index,
 		Path:  sf.path,
 		Usage: make([]uint64, len(sf.usage)),
+
+		FailedReads:      atomic.LoadUint64(&sf.atomicFailedReads),
+		FailedWrites:     atomic.LoadUint64(&sf.atomicFailedWrites),
+		SuccessfulReads:  atomic.LoadUint64(&sf.atomicSuccessfulReads),
+		SuccessfulWrites: atomic.LoadUint64(&sf.atomicSuccessfulWrites),
+		LastError:        sf.lastError(),
 	}
 	copy(ssf.Usage, sf.usage)
 	return ssf
@@ -76,16 +88,23 @@ func (cm *ContractManager) loadSettings() error {
 		sf.index = ss.StorageFolders[i].Index
 		sf.path = ss.StorageFolders[i].Path
 		sf.usage = ss.StorageFolders[i].Usage
+		sf.atomicFailedReads = ss.StorageFolders[i].FailedReads
+		sf.atomicFailedWrites = ss.StorageFolders[i].FailedWrites
+		sf.atomicSuccessfulReads = ss.StorageFolders[i].SuccessfulReads
+		sf.atomicSuccessfulWrites = ss.StorageFolders[i].SuccessfulWrites
+		sf.atomicLastError.Store(ss.StorageFolders[i].LastError)
 		sf.metadataFile, err = cm.dependencies.openFile(filepath.Join(ss.StorageFolders[i].Path, metadataFile), os.O_RDWR, 0700)
 		if err != nil {
 			// Mark the folder as unavailable and log an error.
 			atomic.StoreUint64(&sf.atomicUnavailable, 1)
+			sf.atomicLastError.Store(err.Error())
 			cm.log.Printf("ERROR: unable to open the %v sector metadata file: %v\n", sf.path, err)
 		}
 		sf.sectorFile, err = cm.dependencies.openFile(filepath.Join(ss.StorageFolders[i].Path, sectorFile), os.O_RDWR, 0700)
 		if err != nil {
 			// Mark the folder as unavailable and log an error.
 			atomic.StoreUint64(&sf.atomicUnavailable, 1)
+			sf.atomicLastError.Store(err.Error())
 			cm.log.Printf("ERROR: unable to open the %v sector file: %v\n", sf.path, err)
 			if sf.metadataFile != nil {
 				sf.metadataFile.Close()
@@ -103,7 +122,7 @@ func (cm *ContractManager) loadSectorLocations(sf *storageFolder) {
 	// Read the sector lookup table for this storage folder into memory.
 	sectorLookupBytes, err := readFullMetadata(sf.metadataFile, len(sf.usage)*storageFolderGranularity)
 	if err != nil {
-		atomic.AddUint64(&sf.atomicFailedReads, 1)
+		sf.recordFailedRead(err)
 		atomic.StoreUint64(&sf.atomicUnavailable, 1)
 		err = build.ComposeErrors(err, sf.metadataFile.Close())
 		err = build.ComposeErrors(err, sf.sectorFile.Close())
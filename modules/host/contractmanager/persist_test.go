@@ -2,6 +2,7 @@ package contractmanager
 
 import (
 	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
 	"sync"
@@ -654,3 +655,104 @@ func TestFolderRechecker(t *testing.T) {
 		t.Error("the storage folder growth does not seem to have worked")
 	}
 }
+
+// TestStorageFolderMetricsPersist checks that the usage and failure statistics
+// of a storage folder are reported by StorageFolderMetrics and survive a
+// restart of the contract manager.
+func TestStorageFolderMetricsPersist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add a storage folder and a sector to the contract manager.
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*storageFolderGranularity*2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, data := randSector()
+	err = cmt.cm.AddSector(root, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cmt.cm.ReadSector(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Record some failures against the storage folder.
+	sfms := cmt.cm.StorageFolderMetrics()
+	if len(sfms) != 1 {
+		t.Fatal("expecting one storage folder, got", len(sfms))
+	}
+	cmt.cm.wal.mu.Lock()
+	sf := cmt.cm.storageFolders[sfms[0].Index]
+	sf.recordFailedRead(errors.New("read failure"))
+	sf.recordFailedWrite(errors.New("write failure"))
+	cmt.cm.wal.mu.Unlock()
+
+	// checkMetrics checks that the metrics of the storage folder match the
+	// expected values.
+	checkMetrics := func() {
+		sfms := cmt.cm.StorageFolderMetrics()
+		if len(sfms) != 1 {
+			t.Fatal("expecting one storage folder, got", len(sfms))
+		}
+		sfm := sfms[0]
+		if sfm.SectorsUsed != 1 || sfm.BytesUsed != modules.SectorSize {
+			t.Error("wrong usage reported:", sfm.SectorsUsed, sfm.BytesUsed)
+		}
+		if sfm.FailedReads != 1 || sfm.FailedWrites != 1 {
+			t.Error("wrong failure counts reported:", sfm.FailedReads, sfm.FailedWrites)
+		}
+		if sfm.SuccessfulReads == 0 || sfm.SuccessfulWrites == 0 {
+			t.Error("successful operations were not reported:", sfm.SuccessfulReads, sfm.SuccessfulWrites)
+		}
+		if sfm.LastError != "write failure" {
+			t.Error("wrong last error reported:", sfm.LastError)
+		}
+	}
+	checkMetrics()
+	if sfms := cmt.cm.StorageFolderMetrics(); sfms[0].ReadThroughput == 0 {
+		t.Error("read throughput was not measured")
+	}
+
+	// Commit the WAL so that the new statistics are included in the settings
+	// file, then restart the contract manager and check that the statistics
+	// were persisted.
+	cmt.cm.wal.mu.Lock()
+	cmt.cm.wal.commit()
+	cmt.cm.wal.mu.Unlock()
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMetrics()
+	if sfms := cmt.cm.StorageFolderMetrics(); sfms[0].ReadThroughput != 0 {
+		t.Error("read throughput should be measured again after a restart:", sfms[0].ReadThroughput)
+	}
+
+	// Resetting the folder's health should clear the statistics.
+	err = cmt.cm.ResetStorageFolderHealth(sfms[0].Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfms = cmt.cm.StorageFolderMetrics()
+	if sfms[0].FailedReads != 0 || sfms[0].FailedWrites != 0 || sfms[0].LastError != "" {
+		t.Error("health statistics were not reset:", sfms[0])
+	}
+}
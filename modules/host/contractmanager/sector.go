@@ -5,6 +5,7 @@ import (
 	"errors"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -153,15 +154,90 @@ func (cm *ContractManager) ReadSector(root crypto.Hash) ([]byte, error) {
 	}
 
 	// Read the sector.
+	start := time.Now()
 	sectorData, err := readSector(sf.sectorFile, sl.index)
 	if err != nil {
-		atomic.AddUint64(&sf.atomicFailedReads, 1)
+		sf.recordFailedRead(err)
 		return nil, build.ExtendErr("unable to fetch sector", err)
 	}
-	atomic.AddUint64(&sf.atomicSuccessfulReads, 1)
+	sf.recordRead(time.Since(start))
 	return sectorData, nil
 }
 
+// sectorLocation returns the location of the sector with the provided id. The
+// caller must hold the lock of the WAL.
+func (cm *ContractManager) sectorLocation(id sectorID) (modules.SectorLocation, error) {
+	sl, exists := cm.sectorLocations[id]
+	if !exists {
+		return modules.SectorLocation{}, ErrSectorNotFound
+	}
+	sf, exists := cm.storageFolders[sl.storageFolder]
+	if !exists {
+		cm.log.Critical("Unable to load storage folder despite having sector metadata")
+		return modules.SectorLocation{}, ErrSectorNotFound
+	}
+	return modules.SectorLocation{
+		StorageFolder:  sf.index,
+		Path:           sf.path,
+		Offset:         uint64(sl.index) * modules.SectorSize,
+		ReferenceCount: sl.count,
+	}, nil
+}
+
+// SectorLocation returns the storage folder and the byte offset within the
+// storage folder's sector file at which the sector with the provided root is
+// stored.
+func (cm *ContractManager) SectorLocation(root crypto.Hash) (modules.SectorLocation, error) {
+	err := cm.tg.Add()
+	if err != nil {
+		return modules.SectorLocation{}, err
+	}
+	defer cm.tg.Done()
+	id := cm.managedSectorID(root)
+
+	cm.wal.mu.Lock()
+	defer cm.wal.mu.Unlock()
+	return cm.sectorLocation(id)
+}
+
+// HasSector returns whether the contract manager has the sector with the
+// provided root, and if so, where the sector is stored.
+func (cm *ContractManager) HasSector(root crypto.Hash) (bool, modules.SectorLocation, error) {
+	locations, err := cm.HasSectors([]crypto.Hash{root})
+	if err != nil {
+		return false, modules.SectorLocation{}, err
+	}
+	sl, exists := locations[root]
+	return exists, sl, nil
+}
+
+// HasSectors returns the location of each of the provided roots that the
+// contract manager has. The locations are looked up under a single lock, so
+// they are consistent with each other.
+func (cm *ContractManager) HasSectors(roots []crypto.Hash) (map[crypto.Hash]modules.SectorLocation, error) {
+	err := cm.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer cm.tg.Done()
+	ids := make([]sectorID, len(roots))
+	for i, root := range roots {
+		ids[i] = cm.managedSectorID(root)
+	}
+
+	locations := make(map[crypto.Hash]modules.SectorLocation)
+	cm.wal.mu.Lock()
+	defer cm.wal.mu.Unlock()
+	for i, id := range ids {
+		sl, err := cm.sectorLocation(id)
+		if err != nil {
+			continue
+		}
+		locations[roots[i]] = sl
+	}
+	return locations, nil
+}
+
 // managedLockSector grabs a sector lock.
 func (wal *writeAheadLog) managedLockSector(id sectorID) {
 	wal.mu.Lock()
@@ -94,7 +94,7 @@ func (wal *writeAheadLog) managedAddPhysicalSector(id sectorID, data []byte, cou
 			err = writeSector(sf.sectorFile, sectorIndex, data)
 			if err != nil {
 				wal.cm.log.Printf("ERROR: Unable to write sector for folder %v: %v\n", sf.path, err)
-				atomic.AddUint64(&sf.atomicFailedWrites, 1)
+				sf.recordFailedWrite(err)
 				wal.mu.Lock()
 				sf.clearUsage(sectorIndex)
 				delete(sf.availableSectors, id)
@@ -112,7 +112,7 @@ func (wal *writeAheadLog) managedAddPhysicalSector(id sectorID, data []byte, cou
 			err = wal.writeSectorMetadata(sf, su)
 			if err != nil {
 				wal.cm.log.Printf("ERROR: Unable to write sector metadata for folder %v: %v\n", sf.path, err)
-				atomic.AddUint64(&sf.atomicFailedWrites, 1)
+				sf.recordFailedWrite(err)
 				wal.mu.Lock()
 				sf.clearUsage(sectorIndex)
 				delete(sf.availableSectors, id)
@@ -359,7 +359,7 @@ func (wal *writeAheadLog) writeSectorMetadata(sf *storageFolder, su sectorUpdate
 	err := writeSectorMetadata(sf.metadataFile, su.Index, su.ID, su.Count)
 	if err != nil {
 		wal.cm.log.Printf("ERROR: unable to write sector metadata to folder %v when adding sector: %v\n", su.Folder, err)
-		atomic.AddUint64(&sf.atomicFailedWrites, 1)
+		sf.recordFailedWrite(err)
 		return err
 	}
 	atomic.AddUint64(&sf.atomicSuccessfulWrites, 1)
@@ -1906,3 +1906,83 @@ func TestFailingStorageFolder(t *testing.T) {
 		}
 	}
 }
+
+// TestHasSectors checks that HasSector and HasSectors report the location and
+// reference count of the sectors in the contract manager.
+func TestHasSectors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add one sector twice, and another sector once.
+	root1, data1 := randSector()
+	root2, data2 := randSector()
+	missing, _ := randSector()
+	for _, su := range []modules.SectorUpdate{{Root: root1, Data: data1}, {Root: root1, Data: data1}, {Root: root2, Data: data2}} {
+		err = cmt.cm.AddSector(su.Root, su.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exists, sl, err := cmt.cm.HasSector(root1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatal("HasSector did not find an added sector")
+	}
+	if sl.ReferenceCount != 2 || sl.Path != storageFolderDir {
+		t.Fatalf("wrong location for virtual sector: %+v", sl)
+	}
+	exists, _, err = cmt.cm.HasSector(missing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("HasSector found a sector that was never added")
+	}
+
+	locations, err := cmt.cm.HasSectors([]crypto.Hash{root1, missing, root2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locations) != 2 {
+		t.Fatal("expected 2 locations, got", len(locations))
+	}
+	if locations[root2].ReferenceCount != 1 {
+		t.Fatal("wrong reference count for sector:", locations[root2].ReferenceCount)
+	}
+	if locations[root1].Offset == locations[root2].Offset {
+		t.Fatal("different sectors have the same offset")
+	}
+
+	// Removing a sector lowers its reference count.
+	err = cmt.cm.RemoveSector(root1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, sl, err = cmt.cm.HasSector(root1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sl.ReferenceCount != 1 {
+		t.Fatal("wrong reference count after removing a sector:", sl.ReferenceCount)
+	}
+}
@@ -0,0 +1,460 @@
+package contractmanager
+
+// sectorupdatebatch.go implements atomic batches of sector additions and
+// removals. Every sector in a batch is written to the WAL in a single state
+// change, which means that the whole batch is committed by a single sync
+// instead of one sync per sector. If any sector in the batch cannot be added
+// or removed, the whole batch is reverted.
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+var (
+	// errMalformedSector is returned if a sector in a batch has the wrong
+	// size.
+	errMalformedSector = errors.New("malformed sector")
+)
+
+// batchSector is a sector that is being added to or removed from the
+// contract manager as part of a batch. Sectors that appear multiple times in
+// a batch are merged into a single batchSector.
+type batchSector struct {
+	id   sectorID
+	data []byte
+	n    uint16
+
+	// physical indicates that a new physical sector is being created for the
+	// sector. The storage folder 'sf' is holding an RLock until the sector has
+	// been appended to the WAL.
+	physical bool
+	sf       *storageFolder
+	old      sectorLocation
+	new      sectorLocation
+}
+
+// update returns the sector update that moves the sector to its new location.
+func (bs *batchSector) update() sectorUpdate {
+	return sectorUpdate{
+		Count:  bs.new.count,
+		ID:     bs.id,
+		Folder: bs.new.storageFolder,
+		Index:  bs.new.index,
+	}
+}
+
+// revert returns the sector update that moves the sector back to its old
+// location. The old count of a new physical sector is zero.
+func (bs *batchSector) revert() sectorUpdate {
+	return sectorUpdate{
+		Count:  bs.old.count,
+		ID:     bs.id,
+		Folder: bs.new.storageFolder,
+		Index:  bs.new.index,
+	}
+}
+
+// managedBatchSectors merges the sectors of a batch by their sector id. The
+// returned sectors are sorted by id, which is the order in which their locks
+// must be acquired to prevent deadlocks between batches.
+func (cm *ContractManager) managedBatchSectors(roots []crypto.Hash, data [][]byte) ([]*batchSector, error) {
+	merged := make(map[sectorID]*batchSector)
+	for i, root := range roots {
+		id := cm.managedSectorID(root)
+		bs, exists := merged[id]
+		if !exists {
+			bs = &batchSector{id: id}
+			merged[id] = bs
+		}
+		if bs.n == 65535 {
+			return nil, errMaxVirtualSectors
+		}
+		bs.n++
+		if data != nil && bs.data == nil {
+			bs.data = data[i]
+		}
+	}
+	bss := make([]*batchSector, 0, len(merged))
+	for _, bs := range merged {
+		bss = append(bss, bs)
+	}
+	sort.Slice(bss, func(i, j int) bool {
+		return bytes.Compare(bss[i].id[:], bss[j].id[:]) < 0
+	})
+	return bss, nil
+}
+
+// managedLockSectors grabs the sector locks of every sector in a batch.
+func (wal *writeAheadLog) managedLockSectors(bss []*batchSector) {
+	for _, bs := range bss {
+		wal.managedLockSector(bs.id)
+	}
+}
+
+// managedUnlockSectors releases the sector locks of every sector in a batch.
+func (wal *writeAheadLog) managedUnlockSectors(bss []*batchSector) {
+	for _, bs := range bss {
+		wal.managedUnlockSector(bs.id)
+	}
+}
+
+// parallelBatch calls fn for each of the provided sectors, using at
+// most maxSectorBatchThreads threads. The first error is returned.
+func parallelBatch(bss []*batchSector, fn func(*batchSector) error) error {
+	var wg sync.WaitGroup
+	var errMu sync.Mutex
+	var firstErr error
+	semaphore := make(chan struct{}, maxSectorBatchThreads)
+	for _, bs := range bss {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(bs *batchSector) {
+			defer wg.Done()
+			defer func() {
+				<-semaphore
+			}()
+			if err := fn(bs); err != nil {
+				errMu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMu.Unlock()
+			}
+		}(bs)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// releasePhysicalSectors clears the usage of the new physical sectors of a
+// batch that was not appended to the WAL, and releases their storage folders.
+func (wal *writeAheadLog) releasePhysicalSectors(bss []*batchSector) {
+	for _, bs := range bss {
+		if !bs.physical {
+			continue
+		}
+		bs.sf.clearUsage(bs.new.index)
+		delete(bs.sf.availableSectors, bs.id)
+		bs.sf.mu.RUnlock()
+	}
+}
+
+// managedAddSectors adds a batch of sectors to the contract manager. Sectors
+// that already exist get their virtual count increased, every other sector is
+// written to a new physical location. The caller must hold the sector locks
+// of every sector in the batch.
+func (wal *writeAheadLog) managedAddSectors(bss []*batchSector) error {
+	// Reserve a location for every sector in the batch. The WAL lock is held
+	// for the whole reservation so that the storage folders cannot fill up
+	// halfway through.
+	var physical, virtual []*batchSector
+	err := func() error {
+		wal.mu.Lock()
+		defer wal.mu.Unlock()
+		for _, bs := range bss {
+			location, exists := wal.cm.sectorLocations[bs.id]
+			if exists {
+				sf, exists := wal.cm.storageFolders[location.storageFolder]
+				if !exists || atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
+					wal.releasePhysicalSectors(physical)
+					return errStorageFolderNotFound
+				}
+				if uint64(location.count)+uint64(bs.n) > 65535 {
+					wal.releasePhysicalSectors(physical)
+					return errMaxVirtualSectors
+				}
+				bs.sf = sf
+				bs.old = location
+				bs.new = location
+				bs.new.count += bs.n
+				virtual = append(virtual, bs)
+				continue
+			}
+
+			sf, _ := vacancyStorageFolder(wal.cm.availableStorageFolders())
+			if sf == nil {
+				wal.releasePhysicalSectors(physical)
+				return errInsufficientStorageForSector
+			}
+			sectorIndex, err := randFreeSector(sf.usage)
+			if err != nil {
+				sf.mu.RUnlock()
+				wal.releasePhysicalSectors(physical)
+				wal.cm.log.Critical("a storage folder with full usage was returned from vacancyStorageFolder")
+				return err
+			}
+			// Set the usage, but mark it as uncommitted.
+			sf.setUsage(sectorIndex)
+			sf.availableSectors[bs.id] = sectorIndex
+			bs.physical = true
+			bs.sf = sf
+			bs.new = sectorLocation{
+				index:         sectorIndex,
+				storageFolder: sf.index,
+				count:         bs.n,
+			}
+			physical = append(physical, bs)
+		}
+		return nil
+	}()
+	if err != nil {
+		return err
+	}
+
+	// Write the new physical sectors and their metadata to disk. Nothing
+	// references these locations until the batch is appended to the WAL, so
+	// the writes are performed before the append.
+	err = parallelBatch(physical, func(bs *batchSector) error {
+		err := writeSector(bs.sf.sectorFile, bs.new.index, bs.data)
+		if err != nil {
+			wal.cm.log.Printf("ERROR: Unable to write sector for folder %v: %v\n", bs.sf.path, err)
+			bs.sf.recordFailedWrite(err)
+			return errDiskTrouble
+		}
+		err = wal.writeSectorMetadata(bs.sf, bs.update())
+		if err != nil {
+			return errDiskTrouble
+		}
+		return nil
+	})
+	if err != nil {
+		wal.mu.Lock()
+		wal.releasePhysicalSectors(physical)
+		wal.mu.Unlock()
+		return err
+	}
+
+	// Append the whole batch to the WAL as a single change.
+	updates := make([]sectorUpdate, 0, len(bss))
+	for _, bs := range bss {
+		updates = append(updates, bs.update())
+	}
+	wal.mu.Lock()
+	wal.appendChange(stateChange{
+		SectorUpdates: updates,
+	})
+	for _, bs := range bss {
+		wal.cm.sectorLocations[bs.id] = bs.new
+		if bs.physical {
+			delete(bs.sf.availableSectors, bs.id)
+			bs.sf.mu.RUnlock()
+		}
+	}
+	syncChan := wal.syncChan
+	wal.mu.Unlock()
+	<-syncChan
+
+	// Update the metadata of the virtual sectors on disk. Metadata is updated
+	// after the sync so that there is no risk of obliterating the previous
+	// count in the event that the change is not fully committed during
+	// unclean shutdown.
+	err = parallelBatch(virtual, func(bs *batchSector) error {
+		return wal.writeSectorMetadata(bs.sf, bs.update())
+	})
+	if err == nil {
+		return nil
+	}
+
+	// Revert the whole batch. Committing the reverting change restores the
+	// metadata of the sectors that were already updated.
+	reverts := make([]sectorUpdate, 0, len(bss))
+	for _, bs := range bss {
+		reverts = append(reverts, bs.revert())
+	}
+	wal.mu.Lock()
+	wal.appendChange(stateChange{
+		SectorUpdates: reverts,
+	})
+	for _, bs := range bss {
+		if bs.physical {
+			delete(wal.cm.sectorLocations, bs.id)
+			bs.sf.availableSectors[bs.id] = bs.new.index
+		} else {
+			wal.cm.sectorLocations[bs.id] = bs.old
+		}
+	}
+	syncChan = wal.syncChan
+	wal.mu.Unlock()
+	<-syncChan
+
+	// Only clear the usage of the physical sectors after the revert has been
+	// committed.
+	wal.mu.Lock()
+	for _, bs := range physical {
+		bs.sf.clearUsage(bs.new.index)
+		delete(bs.sf.availableSectors, bs.id)
+	}
+	wal.mu.Unlock()
+	return build.ExtendErr("unable to write sector metadata during addSectors call", err)
+}
+
+// managedRemoveSectors removes a batch of sectors from the contract manager.
+// The caller must hold the sector locks of every sector in the batch.
+func (wal *writeAheadLog) managedRemoveSectors(bss []*batchSector) error {
+	// Check that every sector in the batch can be removed before informing
+	// the WAL of any removal.
+	err := func() error {
+		wal.mu.Lock()
+		defer wal.mu.Unlock()
+		for _, bs := range bss {
+			location, exists := wal.cm.sectorLocations[bs.id]
+			if !exists || location.count < bs.n {
+				return ErrSectorNotFound
+			}
+			sf, exists := wal.cm.storageFolders[location.storageFolder]
+			if !exists || atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
+				wal.cm.log.Critical("deleting a sector from a storage folder that does not exist?")
+				return errStorageFolderNotFound
+			}
+			bs.sf = sf
+			bs.old = location
+			bs.new = location
+			bs.new.count -= bs.n
+		}
+		return nil
+	}()
+	if err != nil {
+		return err
+	}
+
+	// Inform the WAL of the whole batch and update the in-memory
+	// representation of the sectors.
+	updates := make([]sectorUpdate, 0, len(bss))
+	var remaining []*batchSector
+	for _, bs := range bss {
+		updates = append(updates, bs.update())
+		if bs.new.count != 0 {
+			remaining = append(remaining, bs)
+		}
+	}
+	wal.mu.Lock()
+	wal.appendChange(stateChange{
+		SectorUpdates: updates,
+	})
+	for _, bs := range bss {
+		if bs.new.count == 0 {
+			// Delete the sector and mark it as available.
+			delete(wal.cm.sectorLocations, bs.id)
+			bs.sf.availableSectors[bs.id] = bs.new.index
+		} else {
+			wal.cm.sectorLocations[bs.id] = bs.new
+		}
+	}
+	syncChan := wal.syncChan
+	wal.mu.Unlock()
+	// synchronize before updating the metadata or clearing the usage.
+	<-syncChan
+
+	// Update the metadata of the sectors that still have virtual copies.
+	err = parallelBatch(remaining, func(bs *batchSector) error {
+		return wal.writeSectorMetadata(bs.sf, bs.update())
+	})
+	if err != nil {
+		// Revert the whole batch. Committing the reverting change restores
+		// the metadata of the sectors that were already updated.
+		reverts := make([]sectorUpdate, 0, len(bss))
+		for _, bs := range bss {
+			reverts = append(reverts, bs.revert())
+		}
+		wal.mu.Lock()
+		wal.appendChange(stateChange{
+			SectorUpdates: reverts,
+		})
+		for _, bs := range bss {
+			wal.cm.sectorLocations[bs.id] = bs.old
+			delete(bs.sf.availableSectors, bs.id)
+		}
+		syncChan = wal.syncChan
+		wal.mu.Unlock()
+		<-syncChan
+		return build.ExtendErr("failed to write sector metadata", err)
+	}
+
+	// Only update the usage after the removal has been committed to disk
+	// entirely, to prevent the sector data from being overwritten in the
+	// event of unclean shutdown.
+	wal.mu.Lock()
+	for _, bs := range bss {
+		if bs.new.count == 0 {
+			bs.sf.clearUsage(bs.new.index)
+			delete(bs.sf.availableSectors, bs.id)
+		}
+	}
+	wal.mu.Unlock()
+	return nil
+}
+
+// AddSectors adds a batch of sectors to the contract manager. Either every
+// sector in the batch is added, or none of them are.
+func (cm *ContractManager) AddSectors(sectors []modules.SectorUpdate) error {
+	// Prevent shutdown until this function completes.
+	err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cm.tg.Done()
+
+	roots := make([]crypto.Hash, len(sectors))
+	data := make([][]byte, len(sectors))
+	for i, sector := range sectors {
+		roots[i] = sector.Root
+		data[i] = sector.Data
+	}
+	bss, err := cm.managedBatchSectors(roots, data)
+	if err != nil || len(bss) == 0 {
+		return err
+	}
+
+	// Hold the sector locks of the whole batch throughout the duration of
+	// the function.
+	cm.wal.managedLockSectors(bss)
+	defer cm.wal.managedUnlockSectors(bss)
+
+	// The data of new physical sectors must be complete. This is checked
+	// after grabbing the locks, because only then is it known which of the
+	// sectors are new.
+	cm.wal.mu.Lock()
+	for _, bs := range bss {
+		if _, exists := cm.sectorLocations[bs.id]; !exists && uint64(len(bs.data)) != modules.SectorSize {
+			cm.wal.mu.Unlock()
+			return errMalformedSector
+		}
+	}
+	cm.wal.mu.Unlock()
+
+	err = cm.wal.managedAddSectors(bss)
+	if err != nil {
+		cm.log.Println("ERROR: Unable to add sectors:", err)
+		return err
+	}
+	return nil
+}
+
+// RemoveSectors removes a batch of sectors from the contract manager. A root
+// that appears multiple times in the batch is removed multiple times. Either
+// every sector in the batch is removed, or none of them are.
+func (cm *ContractManager) RemoveSectors(sectorRoots []crypto.Hash) error {
+	// Prevent shutdown until this function completes.
+	err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cm.tg.Done()
+
+	bss, err := cm.managedBatchSectors(sectorRoots, nil)
+	if err != nil || len(bss) == 0 {
+		return err
+	}
+	cm.wal.managedLockSectors(bss)
+	defer cm.wal.managedUnlockSectors(bss)
+
+	return cm.wal.managedRemoveSectors(bss)
+}
@@ -0,0 +1,87 @@
+package contractmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// benchmarkSectorBatchSize is the number of sectors that are added and
+// removed in each iteration of the sector batch benchmarks.
+const benchmarkSectorBatchSize = 64
+
+// newBenchmarkContractManager creates a contract manager with a storage folder
+// that can hold benchmarkSectorBatchSize sectors, along with a batch of
+// sectors to add to it.
+func newBenchmarkContractManager(b *testing.B, name string) (*contractManagerTester, []modules.SectorUpdate) {
+	cmt, err := newContractManagerTester(name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		b.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*benchmarkSectorBatchSize)
+	if err != nil {
+		b.Fatal(err)
+	}
+	sectors := make([]modules.SectorUpdate, benchmarkSectorBatchSize)
+	for i := range sectors {
+		sectors[i].Root, sectors[i].Data = randSector()
+	}
+	return cmt, sectors
+}
+
+// sectorRoots returns the roots of the provided sectors.
+func sectorRoots(sectors []modules.SectorUpdate) []crypto.Hash {
+	roots := make([]crypto.Hash, len(sectors))
+	for i := range sectors {
+		roots[i] = sectors[i].Root
+	}
+	return roots
+}
+
+// BenchmarkAddRemoveSectorSerial adds and then removes 64 sectors one at a
+// time, which requires one WAL sync per sector.
+func BenchmarkAddRemoveSectorSerial(b *testing.B) {
+	cmt, sectors := newBenchmarkContractManager(b, "BenchmarkAddRemoveSectorSerial"+strconv.Itoa(b.N))
+	defer cmt.panicClose()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, sector := range sectors {
+			if err := cmt.cm.AddSector(sector.Root, sector.Data); err != nil {
+				b.Fatal(err)
+			}
+		}
+		for _, sector := range sectors {
+			if err := cmt.cm.RemoveSector(sector.Root); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkAddRemoveSectors adds and then removes 64 sectors as batches, which
+// requires one WAL sync per batch.
+func BenchmarkAddRemoveSectors(b *testing.B) {
+	cmt, sectors := newBenchmarkContractManager(b, "BenchmarkAddRemoveSectors"+strconv.Itoa(b.N))
+	defer cmt.panicClose()
+	roots := sectorRoots(sectors)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := cmt.cm.AddSectors(sectors); err != nil {
+			b.Fatal(err)
+		}
+		if err := cmt.cm.RemoveSectors(roots); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package contractmanager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// checkSectorCounts checks that the contract manager holds each sector the
+// provided number of times, and that the data of each sector can be read.
+func checkSectorCounts(cm *ContractManager, counts map[crypto.Hash]uint16, data map[crypto.Hash][]byte) error {
+	for root, count := range counts {
+		cm.wal.mu.Lock()
+		sl, exists := cm.sectorLocations[cm.managedSectorID(root)]
+		cm.wal.mu.Unlock()
+		if count == 0 {
+			if exists {
+				return errors.New("removed sector is still in the contract manager")
+			}
+			continue
+		}
+		if !exists || sl.count != count {
+			return fmt.Errorf("sector has count %v, expected %v", sl.count, count)
+		}
+		sectorData, err := cm.ReadSector(root)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(sectorData, data[root]) {
+			return errors.New("wrong sector data")
+		}
+	}
+	return nil
+}
+
+// TestAddSectors checks that a batch of sectors is added atomically, and that
+// the batch survives a restart.
+func TestAddSectors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester("TestAddSectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*storageFolderGranularity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add one sector individually, then add a batch that contains it again
+	// along with a new sector that appears twice.
+	existingRoot, existingData := randSector()
+	err = cmt.cm.AddSector(existingRoot, existingData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[crypto.Hash][]byte{existingRoot: existingData}
+	counts := map[crypto.Hash]uint16{existingRoot: 2}
+	batch := []modules.SectorUpdate{{Root: existingRoot}}
+	for i := 0; i < 8; i++ {
+		root, sectorData := randSector()
+		data[root] = sectorData
+		counts[root] = 1
+		batch = append(batch, modules.SectorUpdate{Root: root, Data: sectorData})
+	}
+	batch = append(batch, batch[1])
+	counts[batch[1].Root] = 2
+	err = cmt.cm.AddSectors(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSectorCounts(cmt.cm, counts, data); err != nil {
+		t.Fatal(err)
+	}
+	sfs := cmt.cm.StorageFolders()
+	if sfs[0].CapacityRemaining != sfs[0].Capacity-9*modules.SectorSize {
+		t.Fatal("nine physical sectors should be stored:", sfs[0].Capacity, sfs[0].CapacityRemaining)
+	}
+
+	// A batch that does not fit should be rejected entirely.
+	var large []modules.SectorUpdate
+	for i := 0; i < storageFolderGranularity; i++ {
+		root, sectorData := randSector()
+		counts[root] = 0
+		large = append(large, modules.SectorUpdate{Root: root, Data: sectorData})
+	}
+	large = append(large, modules.SectorUpdate{Root: existingRoot})
+	err = cmt.cm.AddSectors(large)
+	if err != errInsufficientStorageForSector {
+		t.Fatal("expected errInsufficientStorageForSector, got", err)
+	}
+	if err := checkSectorCounts(cmt.cm, counts, data); err != nil {
+		t.Fatal(err)
+	}
+	if sfs2 := cmt.cm.StorageFolders(); sfs2[0].CapacityRemaining != sfs[0].CapacityRemaining {
+		t.Fatal("rejected batch consumed storage:", sfs2[0].CapacityRemaining, sfs[0].CapacityRemaining)
+	}
+
+	// A new sector with the wrong size should be rejected.
+	root, _ := randSector()
+	err = cmt.cm.AddSectors([]modules.SectorUpdate{{Root: root, Data: []byte{1}}})
+	if err != errMalformedSector {
+		t.Fatal("expected errMalformedSector, got", err)
+	}
+
+	// Restart the contract manager and check that the batch was persisted.
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSectorCounts(cmt.cm, counts, data); err != nil {
+		t.Fatal(err)
+	}
+	if sfs2 := cmt.cm.StorageFolders(); sfs2[0].CapacityRemaining != sfs[0].CapacityRemaining {
+		t.Fatal("capacity changed after restart:", sfs2[0].CapacityRemaining, sfs[0].CapacityRemaining)
+	}
+}
+
+// TestRemoveSectors checks that a batch of sectors is removed atomically, and
+// that the removal survives a restart.
+func TestRemoveSectors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester("TestRemoveSectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*storageFolderGranularity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add eight sectors, the first of which is added three times.
+	data := make(map[crypto.Hash][]byte)
+	counts := make(map[crypto.Hash]uint16)
+	var roots []crypto.Hash
+	var batch []modules.SectorUpdate
+	for i := 0; i < 8; i++ {
+		root, sectorData := randSector()
+		data[root] = sectorData
+		counts[root] = 1
+		roots = append(roots, root)
+		batch = append(batch, modules.SectorUpdate{Root: root, Data: sectorData})
+	}
+	batch = append(batch, batch[0], batch[0])
+	counts[roots[0]] = 3
+	err = cmt.cm.AddSectors(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing a batch that contains a missing sector, or that removes a
+	// sector more often than it was added, should not remove anything.
+	missing, _ := randSector()
+	err = cmt.cm.RemoveSectors([]crypto.Hash{roots[1], roots[2], missing})
+	if err != ErrSectorNotFound {
+		t.Fatal("expected ErrSectorNotFound, got", err)
+	}
+	err = cmt.cm.RemoveSectors([]crypto.Hash{roots[1], roots[2], roots[2]})
+	if err != ErrSectorNotFound {
+		t.Fatal("expected ErrSectorNotFound, got", err)
+	}
+	if err := checkSectorCounts(cmt.cm, counts, data); err != nil {
+		t.Fatal(err)
+	}
+
+	// Remove two copies of the first sector and all of the next three
+	// sectors.
+	err = cmt.cm.RemoveSectors([]crypto.Hash{roots[0], roots[1], roots[0], roots[2], roots[3]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts[roots[0]] = 1
+	counts[roots[1]] = 0
+	counts[roots[2]] = 0
+	counts[roots[3]] = 0
+	if err := checkSectorCounts(cmt.cm, counts, data); err != nil {
+		t.Fatal(err)
+	}
+	sfs := cmt.cm.StorageFolders()
+	if sfs[0].CapacityRemaining != sfs[0].Capacity-5*modules.SectorSize {
+		t.Fatal("five physical sectors should remain:", sfs[0].Capacity, sfs[0].CapacityRemaining)
+	}
+
+	// Restart the contract manager and check that the removal was persisted.
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSectorCounts(cmt.cm, counts, data); err != nil {
+		t.Fatal(err)
+	}
+	if sfs2 := cmt.cm.StorageFolders(); sfs2[0].CapacityRemaining != sfs[0].CapacityRemaining {
+		t.Fatal("capacity changed after restart:", sfs2[0].CapacityRemaining, sfs[0].CapacityRemaining)
+	}
+}
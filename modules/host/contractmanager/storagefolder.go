@@ -6,6 +6,7 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync/atomic"
 	"time"
 
@@ -87,11 +88,20 @@ type storageFolder struct {
 	atomicProgressNumerator   uint64
 	atomicProgressDenominator uint64
 
-	// Disk statistics for this boot cycle.
+	// Disk statistics for this storage folder. The statistics are saved to
+	// disk alongside the folder, so that intermittent failures accumulate
+	// across restarts.
 	atomicFailedReads      uint64
 	atomicFailedWrites     uint64
 	atomicSuccessfulReads  uint64
 	atomicSuccessfulWrites uint64
+	atomicLastError        atomic.Value // string
+
+	// Read throughput statistics for this storage folder, measured by timing
+	// the sector reads. The statistics are not saved to disk, because the
+	// throughput of a drive can change between restarts.
+	atomicReadBytes       uint64
+	atomicReadNanoseconds uint64
 
 	// Atomic bool indicating whether or not the storage folder is available. If
 	// the storage folder is not available, it will still be loaded but return
@@ -125,6 +135,48 @@ type storageFolder struct {
 	sectorFile   file
 }
 
+// recordFailedRead increments the failed read counter of the storage folder
+// and records the error that caused the failure.
+func (sf *storageFolder) recordFailedRead(err error) {
+	atomic.AddUint64(&sf.atomicFailedReads, 1)
+	sf.atomicLastError.Store(err.Error())
+}
+
+// recordRead adds a successful sector read that took 'elapsed' to the read
+// statistics of the storage folder.
+func (sf *storageFolder) recordRead(elapsed time.Duration) {
+	atomic.AddUint64(&sf.atomicSuccessfulReads, 1)
+	atomic.AddUint64(&sf.atomicReadBytes, modules.SectorSize)
+	atomic.AddUint64(&sf.atomicReadNanoseconds, uint64(elapsed.Nanoseconds()))
+}
+
+// readThroughput returns the average throughput of the sector reads of the
+// storage folder in bytes per second, or zero if no reads have been measured.
+func (sf *storageFolder) readThroughput() uint64 {
+	bytes := atomic.LoadUint64(&sf.atomicReadBytes)
+	nanos := atomic.LoadUint64(&sf.atomicReadNanoseconds)
+	if bytes == 0 {
+		return 0
+	} else if nanos == 0 {
+		nanos = 1
+	}
+	return uint64(float64(bytes) / float64(nanos) * 1e9)
+}
+
+// recordFailedWrite increments the failed write counter of the storage folder
+// and records the error that caused the failure.
+func (sf *storageFolder) recordFailedWrite(err error) {
+	atomic.AddUint64(&sf.atomicFailedWrites, 1)
+	sf.atomicLastError.Store(err.Error())
+}
+
+// lastError returns the most recent error encountered by the storage folder,
+// or the empty string if no error has been recorded.
+func (sf *storageFolder) lastError() string {
+	lastErr, _ := sf.atomicLastError.Load().(string)
+	return lastErr
+}
+
 // mostSignificantBit returns the index of the most significant bit of an input
 // value.
 func mostSignificantBit(i uint64) uint64 {
@@ -347,6 +399,7 @@ func (cm *ContractManager) ResetStorageFolderHealth(index uint16) error {
 	atomic.StoreUint64(&sf.atomicFailedWrites, 0)
 	atomic.StoreUint64(&sf.atomicSuccessfulReads, 0)
 	atomic.StoreUint64(&sf.atomicSuccessfulWrites, 0)
+	sf.atomicLastError.Store("")
 	return nil
 }
 
@@ -386,6 +439,29 @@ func (cm *ContractManager) ResizeStorageFolder(index uint16, newSize uint64, for
 	return cm.wal.growStorageFolder(index, newSectorCount)
 }
 
+// metadata returns the metadata of the storage folder. The caller must hold
+// the WAL lock.
+func (sf *storageFolder) metadata() modules.StorageFolderMetadata {
+	return modules.StorageFolderMetadata{
+		ProgressNumerator:   atomic.LoadUint64(&sf.atomicProgressNumerator),
+		ProgressDenominator: atomic.LoadUint64(&sf.atomicProgressDenominator),
+
+		FailedReads:      atomic.LoadUint64(&sf.atomicFailedReads),
+		FailedWrites:     atomic.LoadUint64(&sf.atomicFailedWrites),
+		SuccessfulReads:  atomic.LoadUint64(&sf.atomicSuccessfulReads),
+		SuccessfulWrites: atomic.LoadUint64(&sf.atomicSuccessfulWrites),
+		LastError:        sf.lastError(),
+		ReadThroughput:   sf.readThroughput(),
+
+		Capacity:          modules.SectorSize * 64 * uint64(len(sf.usage)),
+		CapacityRemaining: ((64 * uint64(len(sf.usage))) - sf.sectors) * modules.SectorSize,
+		SectorsUsed:       sf.sectors,
+		BytesUsed:         sf.sectors * modules.SectorSize,
+		Index:             sf.index,
+		Path:              sf.path,
+	}
+}
+
 // StorageFolders will return a list of storage folders in the host, each
 // containing information about the storage folder and any operations currently
 // being executed on the storage folder.
@@ -403,20 +479,7 @@ func (cm *ContractManager) StorageFolders() []modules.StorageFolderMetadata {
 	var smfs []modules.StorageFolderMetadata
 	for _, sf := range cm.storageFolders {
 		// Grab the non-computational data.
-		sfm := modules.StorageFolderMetadata{
-			ProgressNumerator:   atomic.LoadUint64(&sf.atomicProgressNumerator),
-			ProgressDenominator: atomic.LoadUint64(&sf.atomicProgressDenominator),
-
-			FailedReads:      atomic.LoadUint64(&sf.atomicFailedReads),
-			FailedWrites:     atomic.LoadUint64(&sf.atomicFailedWrites),
-			SuccessfulReads:  atomic.LoadUint64(&sf.atomicSuccessfulReads),
-			SuccessfulWrites: atomic.LoadUint64(&sf.atomicSuccessfulWrites),
-
-			Capacity:          modules.SectorSize * 64 * uint64(len(sf.usage)),
-			CapacityRemaining: ((64 * uint64(len(sf.usage))) - sf.sectors) * modules.SectorSize,
-			Index:             sf.index,
-			Path:              sf.path,
-		}
+		sfm := sf.metadata()
 
 		// Set some of the values to extreme numbers if the storage folder is
 		// unavailable, to flag the user's attention.
@@ -430,3 +493,25 @@ func (cm *ContractManager) StorageFolders() []modules.StorageFolderMetadata {
 	}
 	return smfs
 }
+
+// StorageFolderMetrics returns the usage and health statistics of each storage
+// folder, sorted by index. The failure counters are persisted, so they include
+// failures from previous runs of the contract manager.
+func (cm *ContractManager) StorageFolderMetrics() []modules.StorageFolderMetadata {
+	err := cm.tg.Add()
+	if err != nil {
+		return nil
+	}
+	defer cm.tg.Done()
+	cm.wal.mu.Lock()
+	defer cm.wal.mu.Unlock()
+
+	smfs := make([]modules.StorageFolderMetadata, 0, len(cm.storageFolders))
+	for _, sf := range cm.storageFolders {
+		smfs = append(smfs, sf.metadata())
+	}
+	sort.Slice(smfs, func(i, j int) bool {
+		return smfs[i].Index < smfs[j].Index
+	})
+	return smfs
+}
@@ -34,7 +34,7 @@ func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
 	// new storage folder.
 	sectorData, err := readSector(oldFolder.sectorFile, oldLocation.index)
 	if err != nil {
-		atomic.AddUint64(&oldFolder.atomicFailedReads, 1)
+		oldFolder.recordFailedRead(err)
 		return build.ExtendErr("unable to read sector selected for migration", err)
 	}
 	atomic.AddUint64(&oldFolder.atomicSuccessfulReads, 1)
@@ -93,7 +93,7 @@ func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
 			err = writeSector(sf.sectorFile, sectorIndex, sectorData)
 			if err != nil {
 				wal.cm.log.Printf("ERROR: Unable to write sector for folder %v: %v\n", sf.path, err)
-				atomic.AddUint64(&sf.atomicFailedWrites, 1)
+				sf.recordFailedWrite(err)
 				wal.mu.Lock()
 				sf.clearUsage(sectorIndex)
 				delete(sf.availableSectors, id)
@@ -111,7 +111,7 @@ func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
 			err = wal.writeSectorMetadata(sf, su)
 			if err != nil {
 				wal.cm.log.Printf("ERROR: Unable to write sector metadata for folder %v: %v\n", sf.path, err)
-				atomic.AddUint64(&sf.atomicFailedWrites, 1)
+				sf.recordFailedWrite(err)
 				wal.mu.Lock()
 				sf.clearUsage(sectorIndex)
 				delete(sf.availableSectors, id)
@@ -174,7 +174,7 @@ func (wal *writeAheadLog) managedEmptyStorageFolder(sfIndex uint16, startingPoin
 	// what sectors are in which locations.
 	sectorLookupBytes, err := readFullMetadata(sf.metadataFile, len(sf.usage)*storageFolderGranularity)
 	if err != nil {
-		atomic.AddUint64(&sf.atomicFailedReads, 1)
+		sf.recordFailedRead(err)
 		return 0, build.ExtendErr("unable to read sector metadata", err)
 	}
 	atomic.AddUint64(&sf.atomicSuccessfulReads, 1)
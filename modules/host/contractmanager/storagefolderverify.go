@@ -0,0 +1,116 @@
+package contractmanager
+
+import (
+	"errors"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	siasync "github.com/NebulousLabs/Sia/sync"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+var (
+	// errInvalidSampleRate is returned if a storage folder is verified with a
+	// sample rate that is not greater than 0 and at most 1.
+	errInvalidSampleRate = errors.New("sample rate must be greater than 0 and at most 1")
+
+	// errStorageFolderUnavailable is returned if a storage folder that is not
+	// available is verified.
+	errStorageFolderUnavailable = errors.New("storage folder is unavailable")
+)
+
+// managedVerifySector reads the sector with the provided id from the storage
+// folder, returning the Merkle root of its data. The sector is skipped if it
+// has been removed or moved to another storage folder since the sample was
+// taken.
+func (cm *ContractManager) managedVerifySector(sf *storageFolder, id sectorID) (root crypto.Hash, sl sectorLocation, skipped bool, err error) {
+	cm.wal.managedLockSector(id)
+	defer cm.wal.managedUnlockSector(id)
+
+	cm.wal.mu.Lock()
+	sl, exists := cm.sectorLocations[id]
+	cm.wal.mu.Unlock()
+	if !exists || sl.storageFolder != sf.index {
+		return crypto.Hash{}, sl, true, nil
+	}
+
+	start := time.Now()
+	sectorData, err := readSector(sf.sectorFile, sl.index)
+	if err != nil {
+		sf.recordFailedRead(err)
+		return crypto.Hash{}, sl, false, err
+	}
+	sf.recordRead(time.Since(start))
+	return crypto.MerkleRoot(sectorData), sl, false, nil
+}
+
+// VerifyStorageFolder reads a random sample of the sectors in the storage
+// folder at path, and returns the sectors whose Merkle roots do not match the
+// sector ids in the metadata of the contract manager. sampleRate is the
+// fraction of the sectors that are read, rounded up so that at least one
+// sector of a non-empty folder is read. Sectors that cannot be read are
+// returned with an empty ActualRoot.
+func (cm *ContractManager) VerifyStorageFolder(path string, sampleRate float64) ([]modules.SectorError, error) {
+	if !(sampleRate > 0 && sampleRate <= 1) {
+		return nil, errInvalidSampleRate
+	}
+	err := cm.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer cm.tg.Done()
+
+	// Find the storage folder and the sectors that it holds.
+	var sf *storageFolder
+	var ids []sectorID
+	cm.wal.mu.Lock()
+	for _, folder := range cm.storageFolders {
+		if folder.path == path {
+			sf = folder
+			break
+		}
+	}
+	if sf != nil {
+		for id, sl := range cm.sectorLocations {
+			if sl.storageFolder == sf.index {
+				ids = append(ids, id)
+			}
+		}
+	}
+	cm.wal.mu.Unlock()
+	if sf == nil {
+		return nil, errStorageFolderNotFound
+	}
+	if atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
+		return nil, errStorageFolderUnavailable
+	}
+
+	// Read a random sample of the sectors.
+	sampleSize := int(math.Ceil(float64(len(ids)) * sampleRate))
+	sectorErrors := []modules.SectorError{}
+	for _, i := range fastrand.Perm(len(ids))[:sampleSize] {
+		select {
+		case <-cm.tg.StopChan():
+			return nil, siasync.ErrStopped
+		default:
+		}
+
+		root, sl, skipped, err := cm.managedVerifySector(sf, ids[i])
+		if skipped || (err == nil && cm.managedSectorID(root) == ids[i]) {
+			continue
+		}
+		sectorErrors = append(sectorErrors, modules.SectorError{
+			SectorID:   modules.SectorID(ids[i]),
+			Offset:     uint64(sl.index) * modules.SectorSize,
+			ActualRoot: root,
+		})
+	}
+	if len(sectorErrors) > 0 {
+		cm.log.Printf("Verification of storage folder %v found %v corrupted sectors in a sample of %v\n", path, len(sectorErrors), sampleSize)
+	}
+	return sectorErrors, nil
+}
@@ -0,0 +1,115 @@
+package contractmanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestVerifyStorageFolder checks that VerifyStorageFolder finds the sectors
+// of a storage folder whose data has been corrupted on disk.
+func TestVerifyStorageFolder(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roots []crypto.Hash
+	for i := 0; i < 10; i++ {
+		root, data := randSector()
+		if err := cmt.cm.AddSector(root, data); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+
+	// Invalid sample rates and unknown folders are rejected.
+	for _, rate := range []float64{0, -0.5, 1.5} {
+		if _, err := cmt.cm.VerifyStorageFolder(storageFolderDir, rate); err != errInvalidSampleRate {
+			t.Errorf("expected errInvalidSampleRate for a sample rate of %v, got %v", rate, err)
+		}
+	}
+	if _, err := cmt.cm.VerifyStorageFolder(filepath.Join(cmt.persistDir, "foo"), 1); err != errStorageFolderNotFound {
+		t.Fatal("expected errStorageFolderNotFound, got", err)
+	}
+
+	// An intact storage folder has no corrupted sectors.
+	sectorErrors, err := cmt.cm.VerifyStorageFolder(storageFolderDir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sectorErrors) != 0 {
+		t.Fatal("intact storage folder has corrupted sectors:", sectorErrors)
+	}
+
+	// Corrupt two of the sectors on disk.
+	f, err := os.OpenFile(filepath.Join(storageFolderDir, sectorFile), os.O_RDWR, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupted := make(map[uint64]crypto.Hash)
+	for _, root := range roots[:2] {
+		sl, err := cmt.cm.SectorLocation(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteAt(fastrand.Bytes(64), int64(sl.Offset)); err != nil {
+			t.Fatal(err)
+		}
+		corrupted[sl.Offset] = root
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Checking every sector finds both corrupted sectors.
+	sectorErrors, err = cmt.cm.VerifyStorageFolder(storageFolderDir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sectorErrors) != 2 {
+		t.Fatal("expected 2 corrupted sectors, got", len(sectorErrors))
+	}
+	for _, se := range sectorErrors {
+		root, exists := corrupted[se.Offset]
+		if !exists {
+			t.Fatal("intact sector reported as corrupted:", se.Offset)
+		}
+		if se.SectorID != modules.SectorID(cmt.cm.managedSectorID(root)) {
+			t.Error("corrupted sector has the wrong id")
+		}
+		if se.ActualRoot == root || se.ActualRoot == (crypto.Hash{}) {
+			t.Error("corrupted sector has the wrong actual root:", se.ActualRoot)
+		}
+		if se.ExpectedRoot != (crypto.Hash{}) {
+			t.Error("contract manager should not know the expected root")
+		}
+	}
+
+	// A sample reads a fraction of the sectors, rounded up.
+	reads := cmt.cm.StorageFolders()[0].SuccessfulReads
+	if _, err := cmt.cm.VerifyStorageFolder(storageFolderDir, 0.25); err != nil {
+		t.Fatal(err)
+	}
+	if n := cmt.cm.StorageFolders()[0].SuccessfulReads - reads; n != 3 {
+		t.Fatal("expected a sample of 3 sectors, got", n)
+	}
+}
@@ -0,0 +1,43 @@
+package host
+
+import (
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+var (
+	// contractSizeBounds are the exclusive upper bounds of the buckets of the
+	// contract size histogram. The last bucket holds the contracts of at least
+	// the last bound.
+	contractSizeBounds = []uint64{1e6, 10e6, 100e6, 1e9}
+)
+
+// ContractSizeHistogram returns the number, total size, and total locked
+// collateral of the host's unresolved storage obligations, bucketed by the
+// size of their data.
+func (h *Host) ContractSizeHistogram() []modules.SizeHistogramBucket {
+	buckets := make([]modules.SizeHistogramBucket, len(contractSizeBounds)+1)
+	for i, bound := range contractSizeBounds {
+		buckets[i].MaxSize = bound
+		buckets[i+1].MinSize = bound
+	}
+	err := h.tg.Add()
+	if err != nil {
+		return buckets
+	}
+	defer h.tg.Done()
+
+	err = h.managedUnresolvedObligations(func(so storageObligation) {
+		size := so.fileSize()
+		i := 0
+		for i < len(contractSizeBounds) && size >= contractSizeBounds[i] {
+			i++
+		}
+		buckets[i].Contracts++
+		buckets[i].Size += size
+		buckets[i].LockedCollateral = buckets[i].LockedCollateral.Add(so.LockedCollateral)
+	})
+	if err != nil {
+		h.log.Println("Unable to read the storage obligations for the contract size histogram:", err)
+	}
+	return buckets
+}
@@ -0,0 +1,73 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+// TestContractSizeHistogram checks that the unresolved storage obligations of
+// the host are counted in the bucket of their size.
+func TestContractSizeHistogram(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// The last obligation has been revised to a larger size, and the
+	// resolved obligation is not counted.
+	sizes := []uint64{0, 999999, 1e6, 50e6, 1e9, 1e9}
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		for i, size := range sizes {
+			so := storageObligation{
+				OriginTransactionSet: []types.Transaction{{
+					FileContracts: []types.FileContract{{FileSize: size}},
+					ArbitraryData: [][]byte{{byte(i)}},
+				}},
+				LockedCollateral: types.NewCurrency64(uint64(i + 1)),
+			}
+			switch i {
+			case len(sizes) - 2:
+				so.ObligationStatus = obligationSucceeded
+			case len(sizes) - 1:
+				so.OriginTransactionSet[0].FileContracts[0].FileSize = 1
+				so.RevisionTransactionSet = []types.Transaction{{
+					FileContractRevisions: []types.FileContractRevision{{NewFileSize: size}},
+				}}
+			}
+			if err := putStorageObligation(tx, so); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		min, max, contracts, size, collateral uint64
+	}{
+		{0, 1e6, 2, 999999, 3},
+		{1e6, 10e6, 1, 1e6, 3},
+		{10e6, 100e6, 1, 50e6, 4},
+		{100e6, 1e9, 0, 0, 0},
+		{1e9, 0, 1, 1e9, 6},
+	}
+	buckets := ht.host.ContractSizeHistogram()
+	if len(buckets) != len(expected) {
+		t.Fatal("wrong number of buckets:", len(buckets))
+	}
+	for i, e := range expected {
+		b := buckets[i]
+		if b.MinSize != e.min || b.MaxSize != e.max || b.Contracts != e.contracts || b.Size != e.size || !b.LockedCollateral.Equals64(e.collateral) {
+			t.Errorf("wrong bucket %v: %+v", i, b)
+		}
+	}
+}
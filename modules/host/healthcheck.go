@@ -0,0 +1,112 @@
+package host
+
+// healthcheck.go implements the self-diagnostic of the host, which an operator
+// can run before accepting new contracts. Every check is run even if an earlier
+// check fails, so that all of the problems of the host are reported at once.
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/fastrand"
+)
+
+var (
+	// errHealthCheckNotSynced is returned by the health check if the consensus
+	// set is not synced.
+	errHealthCheckNotSynced = errors.New("consensus set is not synced")
+
+	// errHealthCheckLowBalance is returned by the health check if the
+	// confirmed balance of the wallet does not cover the fee of a host
+	// announcement.
+	errHealthCheckLowBalance = errors.New("wallet balance is too low to pay for a host announcement")
+)
+
+// healthCheckFile is the name of the file that the health check writes to each
+// storage folder.
+const healthCheckFile = "siahosthealthcheck.tmp"
+
+// newHealthCheck returns the result of the named check that failed with err,
+// or passed if err is nil.
+func newHealthCheck(name string, err error) modules.HostHealthCheck {
+	hc := modules.HostHealthCheck{
+		Name:   name,
+		Passed: err == nil,
+	}
+	if err != nil {
+		hc.Error = err.Error()
+	}
+	return hc
+}
+
+// managedCheckStorageFolderWrite writes a small file to the storage folder at
+// path and removes it again.
+func (h *Host) managedCheckStorageFolderWrite(path string) error {
+	filename := filepath.Join(path, healthCheckFile)
+	err := h.dependencies.writeFile(filename, fastrand.Bytes(4096), 0600)
+	if err != nil {
+		return err
+	}
+	return h.dependencies.removeFile(filename)
+}
+
+// managedCheckAnnouncementBalance returns an error if the wallet cannot pay the
+// fee of a host announcement.
+func (h *Host) managedCheckAnnouncementBalance() error {
+	if !h.wallet.Unlocked() {
+		return errAnnWalletLocked
+	}
+	// The fee is estimated the same way as in managedAnnounce.
+	_, fee := h.tpool.FeeEstimation()
+	fee = fee.Mul64(600)
+	balance, _, _, _ := h.wallet.ConfirmedBalance()
+	if balance.Cmp(fee) < 0 {
+		return errHealthCheckLowBalance
+	}
+	return nil
+}
+
+// managedCheckReachable returns an error if the host cannot connect to itself
+// at the address that it announces.
+func (h *Host) managedCheckReachable() error {
+	h.mu.RLock()
+	addr := h.settings.NetAddress
+	if addr == "" {
+		addr = h.autoAddress
+	}
+	h.mu.RUnlock()
+	if addr == "" {
+		return errUnknownAddress
+	}
+	return h.managedCheckConnectable(addr)
+}
+
+// HealthCheck checks that the host is ready to accept new contracts. The host
+// is only healthy if it has at least one storage folder.
+func (h *Host) HealthCheck() (modules.HostHealthStatus, error) {
+	err := h.tg.Add()
+	if err != nil {
+		return modules.HostHealthStatus{}, err
+	}
+	defer h.tg.Done()
+
+	var status modules.HostHealthStatus
+	status.StorageFolders = []modules.HostHealthCheck{}
+	for _, sf := range h.StorageFolders() {
+		status.StorageFolders = append(status.StorageFolders, newHealthCheck(sf.Path, h.managedCheckStorageFolderWrite(sf.Path)))
+	}
+	status.WalletBalance = newHealthCheck("walletbalance", h.managedCheckAnnouncementBalance())
+	var syncErr error
+	if !h.cs.Synced() {
+		syncErr = errHealthCheckNotSynced
+	}
+	status.ConsensusSynced = newHealthCheck("consensussynced", syncErr)
+	status.Reachable = newHealthCheck("reachable", h.managedCheckReachable())
+
+	status.Healthy = len(status.StorageFolders) > 0 && status.WalletBalance.Passed && status.ConsensusSynced.Passed && status.Reachable.Passed
+	for _, hc := range status.StorageFolders {
+		status.Healthy = status.Healthy && hc.Passed
+	}
+	return status, nil
+}
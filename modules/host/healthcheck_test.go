@@ -0,0 +1,78 @@
+package host
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// dependencyErrHealthCheckWrite is a dependency set that returns an error when
+// the health check writes to a storage folder.
+type dependencyErrHealthCheckWrite struct {
+	productionDependencies
+}
+
+func (d dependencyErrHealthCheckWrite) writeFile(s string, b []byte, fm os.FileMode) error {
+	if filepath.Base(s) == healthCheckFile {
+		return mockErrWriteFile
+	}
+	return d.productionDependencies.writeFile(s, b, fm)
+}
+
+// TestHealthCheck checks the self-diagnostic of a healthy host, and of a host
+// whose storage folders cannot be written and whose wallet is locked.
+func TestHealthCheck(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	status, err := ht.host.HealthCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Healthy {
+		t.Fatalf("host should be healthy: %+v", status)
+	}
+	if len(status.StorageFolders) != len(ht.host.StorageFolders()) {
+		t.Fatal("expected a check for every storage folder, got", len(status.StorageFolders))
+	}
+	for _, hc := range status.StorageFolders {
+		if _, err := os.Stat(filepath.Join(hc.Name, healthCheckFile)); !os.IsNotExist(err) {
+			t.Error("health check file was not removed from", hc.Name)
+		}
+	}
+
+	// Break the storage folders and lock the wallet of another host.
+	ht2, err := newMockHostTester(dependencyErrHealthCheckWrite{}, t.Name()+"-broken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht2.Close()
+	if err := ht2.wallet.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	status, err = ht2.host.HealthCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Healthy {
+		t.Fatal("host with broken storage folders should not be healthy")
+	}
+	for _, hc := range status.StorageFolders {
+		if hc.Passed || hc.Error != mockErrWriteFile.Error() {
+			t.Errorf("storage folder check should have failed: %+v", hc)
+		}
+	}
+	if status.WalletBalance.Passed || status.WalletBalance.Error != errAnnWalletLocked.Error() {
+		t.Errorf("wallet check should have failed: %+v", status.WalletBalance)
+	}
+	if !status.ConsensusSynced.Passed || !status.Reachable.Passed {
+		t.Errorf("unrelated checks should have passed: %+v", status)
+	}
+}
@@ -64,11 +64,13 @@ package host
 // TODO: update_test.go has commented out tests.
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -116,13 +118,39 @@ var (
 type Host struct {
 	// RPC Metrics - atomic variables need to be placed at the top to preserve
 	// compatibility with 32bit systems. These values are not persistent.
-	atomicDownloadCalls     uint64
-	atomicErroredCalls      uint64
-	atomicFormContractCalls uint64
-	atomicRenewCalls        uint64
-	atomicReviseCalls       uint64
-	atomicSettingsCalls     uint64
-	atomicUnrecognizedCalls uint64
+	atomicCollateralClampedCalls uint64
+	atomicDownloadCalls          uint64
+	atomicErroredCalls           uint64
+	atomicFormContractCalls      uint64
+	atomicLegacyCalls            uint64
+	atomicRenewCalls             uint64
+	atomicReviseCalls            uint64
+	atomicSettingsCalls          uint64
+	atomicUnauthorizedCalls      uint64
+	atomicUnrecognizedCalls      uint64
+
+	// Bandwidth consumed by each type of RPC. Connections that are not a
+	// recognized RPC are counted towards otherBandwidth. These values are not
+	// persistent.
+	downloadBandwidth     rpcBandwidth
+	formContractBandwidth rpcBandwidth
+	renewBandwidth        rpcBandwidth
+	reviseBandwidth       rpcBandwidth
+	settingsBandwidth     rpcBandwidth
+	otherBandwidth        rpcBandwidth
+
+	// bandwidthLimiter limits the connections of renters by the node's
+	// bandwidth budget.
+	bandwidthLimiter *modules.BandwidthLimiter
+
+	// The number of versioned sessions for each negotiated RPC version. The
+	// map is protected by mu, and is not persistent.
+	rpcVersionCalls map[uint64]uint64
+
+	// The time of the most recent settings call from each IP address that
+	// has called the settings RPC within settingsCallerWindow. The callers
+	// have their own lock, and are not persistent.
+	settingsCallers *settingsCallers
 
 	// Error management. There are a few different types of errors returned by
 	// the host. These errors intentionally not persistent, so that the logging
@@ -157,14 +185,45 @@ type Host struct {
 	financialMetrics     modules.HostFinancialMetrics
 	settings             modules.HostInternalSettings
 	revisionNumber       uint64
+	revenueSequence      uint64 // Sequence number of the last revenue transition, see revenue.go.
+	windDown             bool   // Set while the host is winding down, see winddown.go.
+	compaction           modules.HostWindDownCompaction
+	lastProofTest        time.Time // Time of the last proof test, see prooftest.go.
 	workingStatus        modules.HostWorkingStatus
 	connectabilityStatus modules.HostConnectabilityStatus
 
+	// reservedCollateral is the collateral of the file contracts that are
+	// being negotiated. It is counted against the collateral budget so that
+	// simultaneous negotiations cannot be approved against the same remaining
+	// budget.
+	reservedCollateral types.Currency
+
+	// tlsConfig is the TLS configuration of the certificate in TLSCertFile,
+	// or nil if TLS is disabled, see tls.go.
+	tlsConfig *tls.Config
+
 	// A map of storage obligations that are currently being modified. Locks on
 	// storage obligations can be long-running, and each storage obligation can
 	// be locked separately.
 	lockedStorageObligations map[types.FileContractID]*siasync.TryMutex
 
+	// Alert tracking. The failure counts of each storage folder are kept so
+	// that new failures can be detected between blocks. These values are not
+	// persistent.
+	alerts                map[alertKey]modules.HostAlert
+	alertSubscribers      []modules.HostAlertSubscriber
+	storageFolderFailures map[string]uint64
+
+	// sessionMemory tracks the memory used by concurrent RPC sessions. It has
+	// its own lock, so that waiting sessions do not hold the host lock.
+	sessionMemory sessionMemory
+
+	// queuedSectorDeletions is the number of sectors in the sector deletion
+	// queue. sectorDeletionsQueued is signaled when sectors are added to the
+	// queue.
+	queuedSectorDeletions uint64
+	sectorDeletionsQueued chan struct{}
+
 	// Utilities.
 	db         *persist.BoltDatabase
 	listener   net.Listener
@@ -223,6 +282,15 @@ func newHost(dependencies dependencies, cs modules.ConsensusSet, tpool modules.T
 		dependencies: dependencies,
 
 		lockedStorageObligations: make(map[types.FileContractID]*siasync.TryMutex),
+		rpcVersionCalls:          make(map[uint64]uint64),
+		settingsCallers:          newSettingsCallers(),
+
+		alerts:                make(map[alertKey]modules.HostAlert),
+		storageFolderFailures: make(map[string]uint64),
+
+		sectorDeletionsQueued: make(chan struct{}, 1),
+
+		bandwidthLimiter: modules.NewBandwidthLimiter(modules.BandwidthClassHost),
 
 		persistDir: persistDir,
 	}
@@ -283,6 +351,37 @@ func newHost(dependencies dependencies, cs modules.ConsensusSet, tpool modules.T
 		}
 	})
 
+	// Start removing the sectors in the sector deletion queue. The thread
+	// must stop before the storage manager is closed.
+	threadedDeleteSectorsClosedChan := make(chan struct{})
+	go h.threadedDeleteSectors(threadedDeleteSectorsClosedChan)
+	h.tg.OnStop(func() {
+		<-threadedDeleteSectorsClosedChan
+	})
+
+	// Check the invariants of the storage obligations, quarantining the
+	// obligations that violate them.
+	go h.threadedCheckObligations()
+
+	// Forget the settings callers that fell out of the window.
+	go h.threadedPruneSettingsCallers()
+
+	// Start pruning old resolved storage obligations. The thread must stop
+	// before the database is closed.
+	threadedPruneObligationsClosedChan := make(chan struct{})
+	go h.threadedPruneObligations(threadedPruneObligationsClosedChan)
+	h.tg.OnStop(func() {
+		<-threadedPruneObligationsClosedChan
+	})
+
+	// Start testing the storage proofs of a sample of the storage
+	// obligations. The thread must stop before the database is closed.
+	threadedTestProofsClosedChan := make(chan struct{})
+	go h.threadedTestProofs(threadedTestProofsClosedChan)
+	h.tg.OnStop(func() {
+		<-threadedTestProofsClosedChan
+	})
+
 	// Initialize the networking.
 	err = h.initNetworking(listenerAddress)
 	if err != nil {
@@ -302,6 +401,20 @@ func (h *Host) Close() error {
 	return h.tg.Stop()
 }
 
+// EffectiveExternalSettings returns the external settings that the host would
+// currently serve to a renter, explaining every field that differs from the
+// internal settings.
+func (h *Host) EffectiveExternalSettings() modules.HostEffectiveSettings {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	err := h.tg.Add()
+	if err != nil {
+		build.Critical("Call to EffectiveExternalSettings after close")
+	}
+	defer h.tg.Done()
+	return h.effectiveSettings()
+}
+
 // ExternalSettings returns the hosts external settings. These values cannot be
 // set by the user (host is configured through InternalSettings), and are the
 // values that get displayed to other hosts on the network.
@@ -364,6 +477,11 @@ func (h *Host) SetInternalSettings(settings modules.HostInternalSettings) error
 	}
 	defer h.tg.Done()
 
+	// A host that is winding down does not accept new file contracts.
+	if settings.AcceptingContracts && h.windDown {
+		return errWindDownAcceptingContracts
+	}
+
 	// The host should not be accepting file contracts if it does not have an
 	// unlock hash.
 	if settings.AcceptingContracts {
@@ -373,11 +491,17 @@ func (h *Host) SetInternalSettings(settings modules.HostInternalSettings) error
 		}
 	}
 
-	if settings.NetAddress != "" {
-		err := settings.NetAddress.IsValid()
-		if err != nil {
-			return errors.New("internal settings not updated, invalid NetAddress: " + err.Error())
-		}
+	err = settings.Validate()
+	if err != nil {
+		return errors.New("internal settings not updated: " + err.Error())
+	}
+
+	// The TLS certificate is loaded before the settings are changed, so that
+	// settings with an unusable certificate are rejected. The certificate is
+	// reloaded on every change, so that a replaced certificate is picked up.
+	tlsConfig, err := h.loadTLSConfig(settings.TLSCertFile, settings.TLSKeyFile)
+	if err != nil {
+		return errors.New("internal settings not updated: " + err.Error())
 	}
 
 	// Check if the net address for the host has changed. If it has, and it's
@@ -387,8 +511,13 @@ func (h *Host) SetInternalSettings(settings modules.HostInternalSettings) error
 		h.announced = false
 	}
 
+	// The whitelist is copied so that the caller cannot modify it without
+	// calling SetInternalSettings.
+	settings.RenterWhitelist = append([]types.SiaPublicKey(nil), settings.RenterWhitelist...)
 	h.settings = settings
+	h.tlsConfig = tlsConfig
 	h.revisionNumber++
+	h.updateCollateralBudgetAlert()
 
 	err = h.saveSync()
 	if err != nil {
@@ -406,5 +535,7 @@ func (h *Host) InternalSettings() modules.HostInternalSettings {
 		return modules.HostInternalSettings{}
 	}
 	defer h.tg.Done()
-	return h.settings
+	settings := h.settings
+	settings.RenterWhitelist = append([]types.SiaPublicKey(nil), settings.RenterWhitelist...)
+	return settings
 }
@@ -2,8 +2,10 @@ package host
 
 import (
 	// "errors"
+	"net"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/build"
@@ -422,6 +424,67 @@ func TestSetAndGetInternalSettings(t *testing.T) {
 	ht.host = rebootHost
 }
 
+// TestEffectiveExternalSettings checks that the effective settings match the
+// settings served by the settings RPC, and that the automatically detected net
+// address is reported as an override.
+func TestEffectiveExternalSettings(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// The host has no configured net address, so the automatically detected
+	// address is served instead.
+	es := ht.host.EffectiveExternalSettings()
+	if len(es.Overrides) != 1 {
+		t.Fatal("expected one override, got", es.Overrides)
+	}
+	o := es.Overrides[0]
+	if o.Field != "netaddress" || o.InternalValue != "" || o.EffectiveValue != string(ht.host.autoAddress) || o.Reason != modules.HostSettingOverrideAutoAddress {
+		t.Error("net address override reported incorrectly:", o)
+	}
+
+	// The effective settings should be exactly what the settings RPC serves.
+	var pk crypto.PublicKey
+	copy(pk[:], ht.host.publicKey.Key)
+	renterConn, hostConn := net.Pipe()
+	go func() {
+		ht.host.managedRPCSettings(hostConn)
+		hostConn.Close()
+	}()
+	var served modules.HostExternalSettings
+	err = crypto.ReadSignedObject(renterConn, &served, modules.NegotiateMaxHostExternalSettingsLen, pk)
+	renterConn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(served, es.Settings) {
+		t.Errorf("effective settings do not match the served settings:\n%v\n%v", es.Settings, served)
+	}
+	if next := ht.host.EffectiveExternalSettings(); next.Settings.RevisionNumber != served.RevisionNumber+1 {
+		t.Error("effective settings do not report the next revision number:", next.Settings.RevisionNumber)
+	}
+
+	// Once a net address is configured, nothing is overridden.
+	settings := ht.host.InternalSettings()
+	settings.NetAddress = "foo.com:1234"
+	if err := ht.host.SetInternalSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	es = ht.host.EffectiveExternalSettings()
+	if len(es.Overrides) != 0 {
+		t.Error("expected no overrides, got", es.Overrides)
+	}
+	if es.Settings.NetAddress != settings.NetAddress {
+		t.Error("configured net address was not served:", es.Settings.NetAddress)
+	}
+}
+
 /*
 // TestSetAndGetSettings checks that the functions for interacting with the
 // hosts settings object are working as expected.
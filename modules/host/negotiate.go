@@ -1,12 +1,18 @@
 package host
 
 import (
+	"bytes"
+	"net"
+	"sync/atomic"
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
 )
 
 var (
@@ -125,6 +131,20 @@ var (
 	// formation.
 	errMismatchedHostPayouts = ErrorCommunication("rejected because host valid and missed payouts are not the same value")
 
+	// errProofConstructionTooSlow is returned if a revision would make the
+	// storage proof of a contract take longer to construct than the host can
+	// afford within the proof window.
+	errProofConstructionTooSlow = ErrorCommunication("rejected because the host could not construct the storage proof of the contract in time, spread the data across more contracts")
+
+	// errInvalidRenterIdentity is returned if the renter does not sign the
+	// challenge of RPCRenterIdentity with the ed25519 key it identifies with.
+	errInvalidRenterIdentity = ErrorCommunication("renter identity is not signed by its key")
+
+	// errRenterNotAuthorized is returned if the host has a renter whitelist
+	// and a renter that is not on the whitelist tries to form or renew a
+	// contract.
+	errRenterNotAuthorized = ErrorCommunication("renter is not authorized to form contracts with this host")
+
 	// errSmallWindow is returned if the renter suggests a storage proof window
 	// that is too small.
 	errSmallWindow = ErrorCommunication("rejected for small window size")
@@ -163,8 +183,9 @@ func createRevisionSignature(fcr types.FileContractRevision, renterSig types.Tra
 // managedFinalizeContract will take a file contract, add the host's
 // collateral, and then try submitting the file contract to the transaction
 // pool. If there is no error, the completed transaction set will be returned
-// to the caller.
-func (h *Host) managedFinalizeContract(builder modules.TransactionBuilder, renterPK crypto.PublicKey, renterSignatures []types.TransactionSignature, renterRevisionSignature types.TransactionSignature, initialSectorRoots []crypto.Hash, hostCollateral, hostInitialRevenue, hostInitialRisk types.Currency) ([]types.TransactionSignature, types.TransactionSignature, types.FileContractID, error) {
+// to the caller. renterID is the identity key of the renter, which is recorded
+// in the storage obligation.
+func (h *Host) managedFinalizeContract(builder modules.TransactionBuilder, renterPK crypto.PublicKey, renterID types.SiaPublicKey, renterSignatures []types.TransactionSignature, renterRevisionSignature types.TransactionSignature, initialSectorRoots []crypto.Hash, hostCollateral, hostInitialRevenue, hostInitialRisk types.Currency) ([]types.TransactionSignature, types.TransactionSignature, types.FileContractID, error) {
 	for _, sig := range renterSignatures {
 		builder.AddTransactionSignature(sig)
 	}
@@ -220,6 +241,8 @@ func (h *Host) managedFinalizeContract(builder modules.TransactionBuilder, rente
 
 		OriginTransactionSet:   fullTxnSet,
 		RevisionTransactionSet: []types.Transaction{revisionTransaction},
+
+		RenterIdentity: renterID,
 	}
 
 	// Get a lock on the storage obligation.
@@ -277,3 +300,56 @@ func (h *Host) managedFinalizeContract(builder modules.TransactionBuilder, rente
 	}
 	return hostTxnSignatures, revisionTransaction.TransactionSignatures[1], so.id(), nil
 }
+
+// managedCheckRenterAuthorized returns errRenterNotAuthorized if the host has a
+// renter whitelist which does not contain the identity key of the renter.
+// Renters that did not identify themselves in the session are not authorized.
+// Rejections are counted in the host's network metrics.
+func (h *Host) managedCheckRenterAuthorized(renterID types.SiaPublicKey) error {
+	h.mu.RLock()
+	whitelist := h.settings.RenterWhitelist
+	h.mu.RUnlock()
+	if len(whitelist) == 0 {
+		return nil
+	}
+
+	for _, allowed := range whitelist {
+		if len(renterID.Key) > 0 && allowed.Algorithm == renterID.Algorithm && bytes.Equal(allowed.Key, renterID.Key) {
+			return nil
+		}
+	}
+	atomic.AddUint64(&h.atomicUnauthorizedCalls, 1)
+	return errRenterNotAuthorized
+}
+
+// managedRPCRenterIdentity reads the identity key of the renter in a versioned
+// session on conn. The renter signs a random challenge to prove that it holds
+// the key.
+func (h *Host) managedRPCRenterIdentity(conn net.Conn) (types.SiaPublicKey, error) {
+	var challenge [32]byte
+	fastrand.Read(challenge[:])
+	err := encoding.WriteObject(conn, challenge)
+	if err != nil {
+		return types.SiaPublicKey{}, ErrorConnection(err.Error())
+	}
+	var ri modules.RenterIdentity
+	err = encoding.ReadObject(conn, &ri, modules.NegotiateMaxRenterIdentitySize)
+	if err != nil {
+		return types.SiaPublicKey{}, ErrorConnection(err.Error())
+	}
+
+	h.mu.RLock()
+	hostKey := h.publicKey
+	h.mu.RUnlock()
+	var pk crypto.PublicKey
+	if ri.PublicKey.Algorithm != types.SignatureEd25519 || len(ri.PublicKey.Key) != len(pk) {
+		modules.WriteNegotiationRejection(conn, errInvalidRenterIdentity) // Error ignored to preserve type in extendErr
+		return types.SiaPublicKey{}, errInvalidRenterIdentity
+	}
+	copy(pk[:], ri.PublicKey.Key)
+	if crypto.VerifyHash(modules.RenterIdentityHash(challenge, hostKey), pk, ri.Signature) != nil {
+		modules.WriteNegotiationRejection(conn, errInvalidRenterIdentity) // Error ignored to preserve type in extendErr
+		return types.SiaPublicKey{}, errInvalidRenterIdentity
+	}
+	return ri.PublicKey, modules.WriteNegotiationAcceptance(conn)
+}
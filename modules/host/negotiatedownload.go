@@ -65,6 +65,12 @@ func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation) er
 	// for the renter.
 	existingRevision := so.RevisionTransactionSet[len(so.RevisionTransactionSet)-1].FileContractRevisions[0]
 	var payload [][]byte
+	var payloadMemory uint64
+	defer func() {
+		if payloadMemory > 0 {
+			h.managedReleaseSessionMemory(payloadMemory)
+		}
+	}()
 	err = func() error {
 		// Check that the length of each file is in-bounds, and that the total
 		// size being requested is acceptable.
@@ -87,6 +93,14 @@ func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation) er
 			return extendErr("payment verification failed: ", err)
 		}
 
+		// Each requested sector is read into memory in full, so memory must
+		// be reserved for every sector before the payload is built.
+		err = h.managedRequestSessionMemory(uint64(len(requests)) * modules.SectorSize)
+		if err != nil {
+			return extendErr("unable to reserve memory for download: ", err)
+		}
+		payloadMemory = uint64(len(requests)) * modules.SectorSize
+
 		// Load the sectors and build the data payload.
 		for _, request := range requests {
 			sectorData, err := h.ReadSector(request.MerkleRoot)
@@ -239,7 +253,7 @@ func (h *Host) managedRPCDownload(conn net.Conn) error {
 	// Perform the file contract revision exchange, giving the renter the most
 	// recent file contract revision and getting the storage obligation that
 	// will be used to pay for the data.
-	_, so, err := h.managedRPCRecentRevision(conn)
+	_, so, err := h.managedRPCRecentRevision(conn, nil)
 	if err != nil {
 		return extendErr("failed RPCRecentRevision during RPCDownload: ", err)
 	}
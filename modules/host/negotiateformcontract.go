@@ -2,6 +2,7 @@ package host
 
 import (
 	"net"
+	"sync/atomic"
 	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
@@ -19,6 +20,10 @@ var (
 	// would require the host to supply more collateral than the host allows
 	// per file contract.
 	errMaxCollateralReached = ErrorInternal("file contract proposal expects the host to pay more than the maximum allowed collateral")
+
+	// errNotAcceptingContracts is returned if a file contract is proposed to a
+	// host that is not accepting new file contracts.
+	errNotAcceptingContracts = ErrorInternal("host is not accepting new file contracts")
 )
 
 // contractCollateral returns the amount of collateral that the host is
@@ -63,8 +68,9 @@ func (h *Host) managedAddCollateral(settings modules.HostInternalSettings, txnSe
 // managedRPCFormContract accepts a file contract from a renter, checks the
 // file contract for compliance with the host settings, and then commits to the
 // file contract, creating a storage obligation and submitting the contract to
-// the blockchain.
-func (h *Host) managedRPCFormContract(conn net.Conn) error {
+// the blockchain. renterID is the identity key of the renter, if the renter
+// identified itself in the session.
+func (h *Host) managedRPCFormContract(conn net.Conn, renterID types.SiaPublicKey) error {
 	// Send the host settings to the renter.
 	err := h.managedRPCSettings(conn)
 	if err != nil {
@@ -106,6 +112,13 @@ func (h *Host) managedRPCFormContract(conn net.Conn) error {
 		return extendErr("could not read renter public key: ", ErrorConnection(err.Error()))
 	}
 
+	// Private hosts only form contracts with the renters on their whitelist.
+	err = h.managedCheckRenterAuthorized(renterID)
+	if err != nil {
+		modules.WriteNegotiationRejection(conn, err) // Error ignored to preserve type in extendErr
+		return extendErr("renter is not authorized: ", err)
+	}
+
 	// The host verifies that the file contract coming over the wire is
 	// acceptable.
 	err = h.managedVerifyNewContract(txnSet, renterPK)
@@ -115,6 +128,24 @@ func (h *Host) managedRPCFormContract(conn net.Conn) error {
 		modules.WriteNegotiationRejection(conn, err) // Error ignored to preserve type in extendErr
 		return extendErr("contract verification failed: ", err)
 	}
+	if collateralClamped(settings) {
+		atomic.AddUint64(&h.atomicCollateralClampedCalls, 1)
+	}
+	// Reserve the host's collateral until the contract has been finalized, so
+	// that simultaneous negotiations cannot all be approved against the same
+	// remaining collateral budget.
+	reservedCollateral := contractCollateral(settings, txnSet[len(txnSet)-1].FileContracts[0])
+	err = h.managedReserveCollateral(reservedCollateral)
+	if err != nil {
+		modules.WriteNegotiationRejection(conn, err) // Error ignored to preserve type in extendErr
+		return extendErr("failed to reserve collateral: ", err)
+	}
+	reserved := true
+	defer func() {
+		if reserved {
+			h.managedReleaseCollateral(reservedCollateral)
+		}
+	}()
 	// The host adds collateral to the transaction.
 	txnBuilder, newParents, newInputs, newOutputs, err := h.managedAddCollateral(settings, txnSet)
 	if err != nil {
@@ -171,7 +202,11 @@ func (h *Host) managedRPCFormContract(conn net.Conn) error {
 	h.mu.RLock()
 	hostCollateral := contractCollateral(h.settings, txnSet[len(txnSet)-1].FileContracts[0])
 	h.mu.RUnlock()
-	hostTxnSignatures, hostRevisionSignature, newSOID, err := h.managedFinalizeContract(txnBuilder, renterPK, renterTxnSignatures, renterRevisionSignature, nil, hostCollateral, types.ZeroCurrency, types.ZeroCurrency)
+	hostTxnSignatures, hostRevisionSignature, newSOID, err := h.managedFinalizeContract(txnBuilder, renterPK, renterID, renterTxnSignatures, renterRevisionSignature, nil, hostCollateral, types.ZeroCurrency, types.ZeroCurrency)
+	// A finalized contract locks its collateral in the storage obligation, so
+	// the reservation is no longer needed.
+	h.managedReleaseCollateral(reservedCollateral)
+	reserved = false
 	if err != nil {
 		// The incoming file contract is not acceptable to the host, indicate
 		// why to the renter.
@@ -210,13 +245,54 @@ func (h *Host) managedVerifyNewContract(txnSet []types.Transaction, renterPK cry
 
 	h.mu.RLock()
 	blockHeight := h.blockHeight
-	lockedStorageCollateral := h.financialMetrics.LockedStorageCollateral
+	committedCollateral := h.committedCollateral()
 	publicKey := h.publicKey
 	settings := h.settings
 	unlockHash := h.unlockHash
 	h.mu.RUnlock()
 	fc := txnSet[len(txnSet)-1].FileContracts[0]
 
+	err := h.verifyContractProposal(fc, settings, blockHeight, committedCollateral, unlockHash)
+	if err == errCollateralBudgetExceeded {
+		h.managedRaiseAlert(modules.HostAlert{
+			Severity: modules.HostAlertSeverityWarning,
+			Category: modules.HostAlertCategoryCollateralBudget,
+			Message:  "a file contract was rejected because the collateral budget has been exhausted",
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	// The unlock hash for the file contract must match the unlock hash that
+	// the host knows how to spend.
+	expectedUH := types.UnlockConditions{
+		PublicKeys: []types.SiaPublicKey{
+			types.Ed25519PublicKey(renterPK),
+			publicKey,
+		},
+		SignaturesRequired: 2,
+	}.UnlockHash()
+	if fc.UnlockHash != expectedUH {
+		return errBadUnlockHash
+	}
+
+	// Check that the transaction set has enough fees on it to get into the
+	// blockchain.
+	setFee := modules.CalculateFee(txnSet)
+	minFee, _ := h.tpool.FeeEstimation()
+	if setFee.Cmp(minFee) < 0 {
+		return errLowTransactionFees
+	}
+	return nil
+}
+
+// verifyContractProposal checks that the terms of a proposed file contract are
+// acceptable under the provided host settings. The host state that the checks
+// depend on is provided by the caller, so the host lock does not need to be
+// held. committedCollateral is the collateral that already counts against the
+// collateral budget.
+func (h *Host) verifyContractProposal(fc types.FileContract, settings modules.HostInternalSettings, blockHeight types.BlockHeight, committedCollateral types.Currency, unlockHash types.UnlockHash) error {
 	// A new file contract should have a file size of zero.
 	if fc.FileSize != 0 {
 		return errBadFileSize
@@ -270,31 +346,55 @@ func (h *Host) managedVerifyNewContract(txnSet []types.Transaction, renterPK cry
 	if expectedCollateral.Cmp(settings.MaxCollateral) > 0 {
 		return errMaxCollateralReached
 	}
+	if !withinCollateralFraction(settings, fc, expectedCollateral) {
+		return errCollateralFractionExceeded
+	}
 	// Check that the host has enough room in the collateral budget to add this
 	// collateral.
-	if lockedStorageCollateral.Add(expectedCollateral).Cmp(settings.CollateralBudget) > 0 {
+	if committedCollateral.Add(expectedCollateral).Cmp(settings.CollateralBudget) > 0 {
 		return errCollateralBudgetExceeded
 	}
+	return nil
+}
 
-	// The unlock hash for the file contract must match the unlock hash that
-	// the host knows how to spend.
-	expectedUH := types.UnlockConditions{
-		PublicKeys: []types.SiaPublicKey{
-			types.Ed25519PublicKey(renterPK),
-			publicKey,
-		},
-		SignaturesRequired: 2,
-	}.UnlockHash()
-	if fc.UnlockHash != expectedUH {
-		return errBadUnlockHash
+// VerifyContractFormation checks whether the host would accept a file contract
+// proposed by a renter in the FormContract RPC, using the host's current
+// settings. A rejected contract is not an error; the reason for the rejection
+// is reported in the assessment. The unlock hash of the contract and the fees
+// of the renter's transaction set are not checked, because they are not part
+// of the proposal.
+func (h *Host) VerifyContractFormation(proposal types.FileContract) (modules.HostContractAssessment, error) {
+	err := h.tg.Add()
+	if err != nil {
+		return modules.HostContractAssessment{}, err
 	}
+	defer h.tg.Done()
 
-	// Check that the transaction set has enough fees on it to get into the
-	// blockchain.
-	setFee := modules.CalculateFee(txnSet)
-	minFee, _ := h.tpool.FeeEstimation()
-	if setFee.Cmp(minFee) < 0 {
-		return errLowTransactionFees
+	h.mu.RLock()
+	blockHeight := h.blockHeight
+	committedCollateral := h.committedCollateral()
+	settings := h.settings
+	unlockHash := h.unlockHash
+	h.mu.RUnlock()
+
+	assessment := modules.HostContractAssessment{
+		ExpectedRevenue: settings.MinContractPrice,
 	}
-	return nil
+	// The collateral can only be computed if the host's payout covers the
+	// contract price.
+	if len(proposal.ValidProofOutputs) == 2 && proposal.ValidProofOutputs[1].Value.Cmp(settings.MinContractPrice) >= 0 {
+		assessment.RequiredCollateral = contractCollateral(settings, proposal)
+	}
+
+	if !settings.AcceptingContracts {
+		err = errNotAcceptingContracts
+	} else {
+		err = h.verifyContractProposal(proposal, settings, blockHeight, committedCollateral, unlockHash)
+	}
+	if err != nil {
+		assessment.RejectReason = err.Error()
+		return assessment, nil
+	}
+	assessment.Accepted = true
+	return assessment, nil
 }
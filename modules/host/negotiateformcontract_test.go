@@ -0,0 +1,110 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestVerifyContractFormation checks that VerifyContractFormation accepts a
+// well formed contract proposal and reports why bad proposals are rejected.
+func TestVerifyContractFormation(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestVerifyContractFormation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	settings := ht.host.InternalSettings()
+	settings.AcceptingContracts = true
+	err = ht.host.SetInternalSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.mu.RLock()
+	blockHeight := ht.host.blockHeight
+	unlockHash := ht.host.unlockHash
+	ht.host.mu.RUnlock()
+
+	// proposal creates a contract in which the host is paid the contract
+	// price and puts up 'collateral'.
+	proposal := func(collateral types.Currency) types.FileContract {
+		hostPayout := settings.MinContractPrice.Add(collateral)
+		windowStart := blockHeight + revisionSubmissionBuffer + 10
+		return types.FileContract{
+			WindowStart: windowStart,
+			WindowEnd:   windowStart + settings.WindowSize,
+			ValidProofOutputs: []types.SiacoinOutput{
+				{Value: types.SiacoinPrecision},
+				{Value: hostPayout, UnlockHash: unlockHash},
+			},
+			MissedProofOutputs: []types.SiacoinOutput{
+				{Value: types.SiacoinPrecision},
+				{Value: hostPayout, UnlockHash: unlockHash},
+				{Value: types.ZeroCurrency},
+			},
+		}
+	}
+
+	// A well formed proposal should be accepted.
+	collateral := types.SiacoinPrecision.Mul64(10)
+	assessment, err := ht.host.VerifyContractFormation(proposal(collateral))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assessment.Accepted || assessment.RejectReason != "" {
+		t.Fatal("well formed proposal was rejected:", assessment.RejectReason)
+	}
+	if !assessment.ExpectedRevenue.Equals(settings.MinContractPrice) {
+		t.Error("wrong expected revenue:", assessment.ExpectedRevenue)
+	}
+	if !assessment.RequiredCollateral.Equals(collateral) {
+		t.Error("wrong required collateral:", assessment.RequiredCollateral)
+	}
+
+	// Proposals that break the host's rules should be rejected with the
+	// matching reason.
+	tooMuchCollateral := proposal(settings.MaxCollateral.Add(types.NewCurrency64(1)))
+	earlyWindow := proposal(collateral)
+	earlyWindow.WindowStart = blockHeight + 1
+	nonEmpty := proposal(collateral)
+	nonEmpty.FileSize = 1
+	badPayout := proposal(collateral)
+	badPayout.ValidProofOutputs[1].UnlockHash = types.UnlockHash{1}
+	tests := []struct {
+		fc  types.FileContract
+		err error
+	}{
+		{tooMuchCollateral, errMaxCollateralReached},
+		{earlyWindow, errEarlyWindow},
+		{nonEmpty, errBadFileSize},
+		{badPayout, errBadPayoutUnlockHashes},
+	}
+	for i, test := range tests {
+		assessment, err := ht.host.VerifyContractFormation(test.fc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if assessment.Accepted || assessment.RejectReason != test.err.Error() {
+			t.Errorf("test %v: expected rejection %q, got %v %q", i, test.err, assessment.Accepted, assessment.RejectReason)
+		}
+	}
+
+	// A host that is not accepting contracts should reject every proposal.
+	settings.AcceptingContracts = false
+	err = ht.host.SetInternalSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assessment, err = ht.host.VerifyContractFormation(proposal(collateral))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assessment.Accepted || assessment.RejectReason != errNotAcceptingContracts.Error() {
+		t.Fatal("host accepted a contract while not accepting contracts:", assessment.RejectReason)
+	}
+}
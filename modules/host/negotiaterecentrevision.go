@@ -50,15 +50,32 @@ func (h *Host) managedVerifyChallengeResponse(fcid types.FileContractID, challen
 	// Fetch the storage obligation, which has the revision, which has the
 	// renter's public key.
 	h.mu.RLock()
-	defer h.mu.RUnlock()
 	err = h.db.View(func(tx *bolt.Tx) error {
 		so, err = getStorageObligation(tx, fcid)
 		return err
 	})
+	h.mu.RUnlock()
 	if err != nil {
 		err = extendErr("could not fetch "+fcid.String()+": ", ErrorInternal(err.Error()))
 		return storageObligation{}, types.FileContractRevision{}, nil, err
 	}
+	// The sectors of a resolved obligation are no longer stored, so the
+	// contract cannot be revised or downloaded from.
+	if so.ObligationStatus != obligationUnresolved {
+		err = extendErr("could not use "+fcid.String()+": ", ErrorCommunication(errObligationResolved.Error()))
+		return storageObligation{}, types.FileContractRevision{}, nil, err
+	}
+	// The revision of an obligation that violates an invariant cannot be
+	// trusted, so the obligation cannot be used until the operator has
+	// repaired it.
+	if !h.managedCheckObligation(fcid, so, false) {
+		err = extendErr("could not use "+fcid.String()+": ", ErrorCommunication(errObligationQuarantined.Error()))
+		return storageObligation{}, types.FileContractRevision{}, nil, err
+	}
+	if len(so.RevisionTransactionSet) == 0 {
+		err = extendErr("could not use "+fcid.String()+": ", ErrorInternal(errObligationNotRevised.Error()))
+		return storageObligation{}, types.FileContractRevision{}, nil, err
+	}
 
 	// Pull out the file contract revision and the revision's signatures from
 	// the transaction.
@@ -96,8 +113,10 @@ func (h *Host) managedVerifyChallengeResponse(fcid types.FileContractID, challen
 // revision, including signatures, to the renter, for the file contract with
 // the id given by the renter.
 //
-// The storage obligation is returned under a storage obligation lock.
-func (h *Host) managedRPCRecentRevision(conn net.Conn) (types.FileContractID, storageObligation, error) {
+// The storage obligation is returned under a storage obligation lock. If
+// refusal is not nil, the renter is sent refusal instead of the revision once
+// the challenge has been verified, and refusal is returned.
+func (h *Host) managedRPCRecentRevision(conn net.Conn, refusal error) (types.FileContractID, storageObligation, error) {
 	// Set the negotiation deadline.
 	conn.SetDeadline(time.Now().Add(modules.NegotiateRecentRevisionTime))
 
@@ -139,6 +158,11 @@ func (h *Host) managedRPCRecentRevision(conn net.Conn) (types.FileContractID, st
 		}
 	}()
 
+	if refusal != nil {
+		err = modules.WriteNegotiationRejection(conn, refusal)
+		return types.FileContractID{}, storageObligation{}, err
+	}
+
 	// Send the file contract revision and the corresponding signatures to the
 	// renter.
 	err = modules.WriteNegotiationAcceptance(conn)
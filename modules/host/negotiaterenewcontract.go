@@ -3,6 +3,7 @@ package host
 import (
 	"errors"
 	"net"
+	"sync/atomic"
 	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
@@ -73,11 +74,20 @@ func (h *Host) managedAddRenewCollateral(so storageObligation, settings modules.
 	return builder, newParents, newInputs, newOutputs, nil
 }
 
-// managedRenewContract accepts a request to renew a file contract.
-func (h *Host) managedRPCRenewContract(conn net.Conn) error {
+// managedRenewContract accepts a request to renew a file contract. renterID is
+// the identity key of the renter, if the renter identified itself in the
+// session.
+func (h *Host) managedRPCRenewContract(conn net.Conn, renterID types.SiaPublicKey) error {
 	// Perform the recent revision protocol to get the file contract being
-	// revised.
-	_, so, err := h.managedRPCRecentRevision(conn)
+	// revised. A host that is winding down refuses the renewal once the
+	// renter has proven that it owns the contract.
+	var refusal error
+	h.mu.RLock()
+	if h.windDown {
+		refusal = modules.ErrHostWindingDown
+	}
+	h.mu.RUnlock()
+	_, so, err := h.managedRPCRecentRevision(conn, refusal)
 	if err != nil {
 		return extendErr("failed RPCRecentRevision during RPCRenewContract: ", err)
 	}
@@ -118,8 +128,16 @@ func (h *Host) managedRPCRenewContract(conn net.Conn) error {
 		return extendErr("unable to read renter public key: ", ErrorConnection(err.Error()))
 	}
 
+	// Private hosts only renew contracts with the renters on their whitelist.
+	err = h.managedCheckRenterAuthorized(renterID)
+	if err != nil {
+		modules.WriteNegotiationRejection(conn, err) // Error is ignored to preserve type for extendErr
+		return extendErr("renter is not authorized: ", err)
+	}
+
 	h.mu.RLock()
 	settings := h.externalSettings()
+	clamped := collateralClamped(h.settings)
 	h.mu.RUnlock()
 
 	// Verify that the transaction coming over the wire is a proper renewal.
@@ -128,6 +146,23 @@ func (h *Host) managedRPCRenewContract(conn net.Conn) error {
 		modules.WriteNegotiationRejection(conn, err) // Error is ignored to preserve type for extendErr
 		return extendErr("verification of renewal failed: ", err)
 	}
+	if clamped {
+		atomic.AddUint64(&h.atomicCollateralClampedCalls, 1)
+	}
+	// Reserve the host's collateral until the renewal has been finalized, as
+	// is done when a contract is formed.
+	reservedCollateral := renewContractCollateral(so, settings, txnSet[len(txnSet)-1].FileContracts[0])
+	err = h.managedReserveCollateral(reservedCollateral)
+	if err != nil {
+		modules.WriteNegotiationRejection(conn, err) // Error is ignored to preserve type for extendErr
+		return extendErr("failed to reserve collateral: ", err)
+	}
+	reserved := true
+	defer func() {
+		if reserved {
+			h.managedReleaseCollateral(reservedCollateral)
+		}
+	}()
 	txnBuilder, newParents, newInputs, newOutputs, err := h.managedAddRenewCollateral(so, settings, txnSet)
 	if err != nil {
 		modules.WriteNegotiationRejection(conn, err) // Error is ignored to preserve type for extendErr
@@ -186,7 +221,16 @@ func (h *Host) managedRPCRenewContract(conn net.Conn) error {
 	renewRevenue := renewBasePrice(so, settings, fc)
 	renewRisk := renewBaseCollateral(so, settings, fc)
 	h.mu.RUnlock()
-	hostTxnSignatures, hostRevisionSignature, newSOID, err := h.managedFinalizeContract(txnBuilder, renterPK, renterTxnSignatures, renterRevisionSignature, so.SectorRoots, renewCollateral, renewRevenue, renewRisk)
+	// The renewed contract keeps the identity of the renter of the old
+	// contract if the renter did not identify itself this time.
+	if len(renterID.Key) == 0 {
+		renterID = so.RenterIdentity
+	}
+	hostTxnSignatures, hostRevisionSignature, newSOID, err := h.managedFinalizeContract(txnBuilder, renterPK, renterID, renterTxnSignatures, renterRevisionSignature, so.SectorRoots, renewCollateral, renewRevenue, renewRisk)
+	// A finalized renewal locks its collateral in the new storage
+	// obligation, so the reservation is no longer needed.
+	h.managedReleaseCollateral(reservedCollateral)
+	reserved = false
 	if err != nil {
 		modules.WriteNegotiationRejection(conn, err) // Error is ignored to preserve type for extendErr
 		return extendErr("failed to finalize contract: ", err)
@@ -225,7 +269,7 @@ func (h *Host) managedVerifyRenewedContract(so storageObligation, txnSet []types
 	blockHeight := h.blockHeight
 	externalSettings := h.externalSettings()
 	internalSettings := h.settings
-	lockedStorageCollateral := h.financialMetrics.LockedStorageCollateral
+	committedCollateral := h.committedCollateral()
 	publicKey := h.publicKey
 	unlockHash := h.unlockHash
 	h.mu.RUnlock()
@@ -267,9 +311,17 @@ func (h *Host) managedVerifyRenewedContract(so storageObligation, txnSet []types
 	if expectedCollateral.Cmp(externalSettings.MaxCollateral) > 0 {
 		return errMaxCollateralReached
 	}
+	if !withinCollateralFraction(internalSettings, fc, expectedCollateral) {
+		return errCollateralFractionExceeded
+	}
 	// Check that the host has enough room in the collateral budget to add this
 	// collateral.
-	if lockedStorageCollateral.Add(expectedCollateral).Cmp(internalSettings.CollateralBudget) > 0 {
+	if committedCollateral.Add(expectedCollateral).Cmp(internalSettings.CollateralBudget) > 0 {
+		h.managedRaiseAlert(modules.HostAlert{
+			Severity: modules.HostAlertSeverityWarning,
+			Category: modules.HostAlertCategoryCollateralBudget,
+			Message:  "a file contract renewal was rejected because the collateral budget has been exhausted",
+		})
 		return errCollateralBudgetExceeded
 	}
 	// Check that the missed proof outputs contain enough money, and that the
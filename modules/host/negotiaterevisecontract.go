@@ -2,6 +2,8 @@ package host
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"time"
 
@@ -43,32 +45,67 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 	blockHeight := h.blockHeight
 	h.mu.RUnlock()
 
-	// The renter is going to send its intended modifications, followed by the
-	// file contract revision that pays for them.
-	var modifications []modules.RevisionAction
-	var revision types.FileContractRevision
-	err = encoding.ReadObject(conn, &modifications, settings.MaxReviseBatchSize)
+	// Reserve memory for the incoming batch and for the sector roots of the
+	// obligation before reading anything from the renter.
+	batchMemory := settings.MaxReviseBatchSize + modules.NegotiateMaxFileContractRevisionSize + uint64(len(so.SectorRoots))*crypto.HashSize
+	err = h.managedRequestSessionMemory(batchMemory)
 	if err != nil {
-		return extendErr("unable to read revision modifications: ", ErrorConnection(err.Error()))
+		modules.WriteNegotiationRejection(conn, err) // Error is ignored so that the error type can be preserved in extendErr.
+		return extendErr("unable to reserve memory for revision: ", err)
 	}
-	err = encoding.ReadObject(conn, &revision, modules.NegotiateMaxFileContractRevisionSize)
+	defer h.managedReleaseSessionMemory(batchMemory)
+
+	// The renter is going to send its intended modifications, followed by the
+	// file contract revision that pays for them. The modifications are decoded
+	// and applied one at a time as they arrive, so the root of each new sector
+	// is computed and folded into the contract's Merkle root while the rest of
+	// the batch is still being received. Once a modification is found to be
+	// invalid, the remaining modifications are still read from the connection
+	// to keep the protocol in sync, but they are not applied.
+	ms, err := newModificationStream(conn, settings.MaxReviseBatchSize)
 	if err != nil {
-		return extendErr("unable to read proposed revision: ", ErrorConnection(err.Error()))
+		return extendErr("unable to read revision modifications: ", ErrorConnection(err.Error()))
 	}
-
-	// First read all of the modifications. Then make the modifications, but
-	// with the ability to reverse them. Then verify the file contract revision
-	// correctly accounts for the changes.
+	var modifyMemory uint64
+	defer func() {
+		if modifyMemory > 0 {
+			h.managedReleaseSessionMemory(modifyMemory)
+		}
+	}()
 	var bandwidthRevenue types.Currency // Upload bandwidth.
 	var storageRevenue types.Currency
 	var newCollateral types.Currency
 	var sectorsRemoved []crypto.Hash
 	var sectorsGained []crypto.Hash
 	var gainedSectorData [][]byte
-	err = func() error {
-		for _, modification := range modifications {
-			// Check that the index points to an existing sector root. If the type
-			// is ActionInsert, we permit inserting at the end.
+	var modificationErr error
+	var sra sectorRootsAccumulator
+	prevSectors := len(so.SectorRoots)
+	for i := 0; ; i++ {
+		modification, more, err := ms.next()
+		if err != nil {
+			return extendErr("unable to read revision modifications: ", ErrorConnection(err.Error()))
+		} else if !more {
+			break
+		} else if modificationErr != nil {
+			continue
+		}
+
+		// Each modify action reads a full sector from disk in addition to the
+		// data that was sent by the renter, so additional memory must be
+		// reserved.
+		if modification.Type == modules.ActionModify {
+			err = h.managedRequestSessionMemory(modules.SectorSize)
+			if err != nil {
+				modificationErr = extendErr("unable to reserve memory for revision: ", err)
+				continue
+			}
+			modifyMemory += modules.SectorSize
+		}
+
+		err = func() error {
+			// Check that the index points to an existing sector root. If the
+			// type is ActionInsert, we permit inserting at the end.
 			if modification.Type == modules.ActionInsert {
 				if modification.SectorIndex > uint64(len(so.SectorRoots)) {
 					return errBadModificationIndex
@@ -85,8 +122,10 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 			case modules.ActionDelete:
 				// There is no financial information to change, it is enough to
 				// remove the sector.
+				sra.touch(modification.SectorIndex)
 				sectorsRemoved = append(sectorsRemoved, so.SectorRoots[modification.SectorIndex])
 				so.SectorRoots = append(so.SectorRoots[0:modification.SectorIndex], so.SectorRoots[modification.SectorIndex+1:]...)
+				sra.fold(so.SectorRoots, modification.SectorIndex)
 			case modules.ActionInsert:
 				// Check that the sector size is correct.
 				if uint64(len(modification.Data)) != modules.SectorSize {
@@ -98,13 +137,20 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 				blockBytesCurrency := types.NewCurrency64(uint64(blocksRemaining)).Mul64(modules.SectorSize)
 				bandwidthRevenue = bandwidthRevenue.Add(settings.MinUploadBandwidthPrice.Mul64(modules.SectorSize))
 				storageRevenue = storageRevenue.Add(settings.MinStoragePrice.Mul(blockBytesCurrency))
-				newCollateral = newCollateral.Add(settings.Collateral.Mul(blockBytesCurrency))
+				newCollateral = newCollateral.Add(collateralRate(settings).Mul(blockBytesCurrency))
 
 				// Insert the sector into the root list.
+				sra.touch(modification.SectorIndex)
 				newRoot := crypto.MerkleRoot(modification.Data)
 				sectorsGained = append(sectorsGained, newRoot)
 				gainedSectorData = append(gainedSectorData, modification.Data)
-				so.SectorRoots = append(so.SectorRoots[:modification.SectorIndex], append([]crypto.Hash{newRoot}, so.SectorRoots[modification.SectorIndex:]...)...)
+				// The root is inserted in place to avoid copying the full set
+				// of sector roots for every insertion.
+				so.SectorRoots = append(so.SectorRoots, crypto.Hash{})
+				copy(so.SectorRoots[modification.SectorIndex+1:], so.SectorRoots[modification.SectorIndex:])
+				so.SectorRoots[modification.SectorIndex] = newRoot
+				sra.fold(so.SectorRoots, modification.SectorIndex+1)
+				sra.recordInsert(so.SectorRoots, i)
 			case modules.ActionModify:
 				// Check that the offset and length are okay. Length is already
 				// known to be appropriately small, but the offset needs to be
@@ -126,18 +172,46 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 
 				// Update the sectors removed and gained to indicate that the old
 				// sector has been replaced with a new sector.
+				sra.touch(modification.SectorIndex)
 				newRoot := crypto.MerkleRoot(sector)
 				sectorsRemoved = append(sectorsRemoved, so.SectorRoots[modification.SectorIndex])
 				sectorsGained = append(sectorsGained, newRoot)
 				gainedSectorData = append(gainedSectorData, sector)
 				so.SectorRoots[modification.SectorIndex] = newRoot
+				sra.fold(so.SectorRoots, modification.SectorIndex+1)
 			default:
 				return errUnknownModification
 			}
+			return nil
+		}()
+		if err != nil {
+			modificationErr = extendErr(fmt.Sprintf("modification %v: ", i), err)
 		}
+	}
+	var revision types.FileContractRevision
+	err = encoding.ReadObject(conn, &revision, modules.NegotiateMaxFileContractRevisionSize)
+	if err != nil {
+		return extendErr("unable to read proposed revision: ", ErrorConnection(err.Error()))
+	}
+
+	// Verify that the file contract revision correctly accounts for the
+	// changes.
+	err = modificationErr
+	if err == nil && len(so.SectorRoots) > prevSectors {
+		// Revisions that grow the obligation are refused once its storage
+		// proof would take too long to construct. Revisions that do not grow
+		// the obligation are still accepted, so that the renter can move
+		// data out of it.
+		err = h.managedCheckProofConstructionTime(*so)
+	}
+	if err == nil {
 		newRevenue := storageRevenue.Add(bandwidthRevenue)
-		return extendErr("unable to verify updated contract: ", verifyRevision(*so, revision, blockHeight, newRevenue, newCollateral))
-	}()
+		err = verifyRevision(*so, revision, blockHeight, newRevenue, newCollateral, sra.root(so.SectorRoots))
+		if err == errBadFileMerkleRoot {
+			err = sra.explainMismatch(revision.NewFileMerkleRoot)
+		}
+		err = extendErr("unable to verify updated contract: ", err)
+	}
 	if err != nil {
 		modules.WriteNegotiationRejection(conn, err) // Error is ignored so that the error type can be preserved in extendErr.
 		return extendErr("rejected proposed modifications: ", err)
@@ -200,7 +274,7 @@ func (h *Host) managedRPCReviseContract(conn net.Conn) error {
 	// Perform the file contract revision exchange, giving the renter the most
 	// recent file contract revision and getting the storage obligation that
 	// will be used to pay for the data.
-	_, so, err := h.managedRPCRecentRevision(conn)
+	_, so, err := h.managedRPCRecentRevision(conn, nil)
 	if err != nil {
 		return extendErr("failed RPCRecentRevision during RPCReviseContract: ", err)
 	}
@@ -224,9 +298,143 @@ func (h *Host) managedRPCReviseContract(conn net.Conn) error {
 	return nil
 }
 
+// newSectorRootsTree returns a cached Merkle tree that accepts sector roots as
+// leaves. The tree only keeps O(log n) subtree roots in memory, so the roots of
+// large obligations can be streamed through it.
+func newSectorRootsTree() *crypto.CachedMerkleTree {
+	log2SectorSize := uint64(0)
+	for 1<<log2SectorSize < (modules.SectorSize / crypto.SegmentSize) {
+		log2SectorSize++
+	}
+	return crypto.NewCachedTree(log2SectorSize)
+}
+
+// sectorRootsMerkleRoot returns the file Merkle root of a set of sector roots.
+func sectorRootsMerkleRoot(roots []crypto.Hash) crypto.Hash {
+	ct := newSectorRootsTree()
+	for _, root := range roots {
+		ct.Push(root)
+	}
+	return ct.Root()
+}
+
+// A modificationStream decodes the revision actions of a batch one at a time
+// as they arrive from the renter. The renter sends the batch as a single
+// length-prefixed object, so a stream can be used in place of reading the
+// whole batch with encoding.ReadObject.
+type modificationStream struct {
+	dec       *encoding.Decoder
+	r         *io.LimitedReader
+	remaining uint64
+}
+
+// newModificationStream reads the length prefix of a batch of revision actions
+// from r and returns a stream that decodes the actions. An error is returned if
+// the batch is larger than maxLen.
+func newModificationStream(r io.Reader, maxLen uint64) (*modificationStream, error) {
+	prefix := make([]byte, 8)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, err
+	}
+	dataLen := encoding.DecUint64(prefix)
+	if dataLen > maxLen {
+		return nil, fmt.Errorf("length %d exceeds maxLen of %d", dataLen, maxLen)
+	}
+	ms := &modificationStream{
+		r: &io.LimitedReader{R: r, N: int64(dataLen)},
+	}
+	ms.dec = encoding.NewDecoder(ms.r)
+	if err := ms.dec.Decode(&ms.remaining); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+// next decodes the next revision action of the batch. Once every action has
+// been decoded, next discards any trailing bytes of the batch so that the
+// following object can be read from the connection, and returns false.
+func (ms *modificationStream) next() (modules.RevisionAction, bool, error) {
+	var action modules.RevisionAction
+	if ms.remaining == 0 {
+		_, err := io.Copy(ioutil.Discard, ms.r)
+		return action, false, err
+	}
+	ms.remaining--
+	err := ms.dec.Decode(&action)
+	return action, true, err
+}
+
+// A sectorRootsAccumulator maintains the Merkle root of an obligation's sector
+// roots while a revision modifies them. Every root that precedes all of the
+// modified indices is folded into a cached tree, so when sectors are appended
+// to an obligation, the existing roots are folded in once and each new root is
+// folded in as soon as its sector arrives. Modifying a root that has already
+// been folded in requires the tree to be rebuilt.
+type sectorRootsAccumulator struct {
+	tree   *crypto.CachedMerkleTree
+	folded uint64
+
+	// insertRoots holds the Merkle root of the obligation after each insert
+	// action that appended a sector, keyed by the index of the action. The
+	// roots are used to explain a mismatching Merkle root.
+	insertRoots map[crypto.Hash]int
+}
+
+// touch must be called before the root at 'index' is modified, inserted, or
+// deleted.
+func (sra *sectorRootsAccumulator) touch(index uint64) {
+	if index < sra.folded {
+		sra.tree = nil
+		sra.folded = 0
+	}
+}
+
+// fold folds roots[sra.folded:upTo] into the tree.
+func (sra *sectorRootsAccumulator) fold(roots []crypto.Hash, upTo uint64) {
+	if sra.tree == nil {
+		sra.tree = newSectorRootsTree()
+	}
+	for ; sra.folded < upTo; sra.folded++ {
+		sra.tree.Push(roots[sra.folded])
+	}
+}
+
+// recordInsert records the Merkle root of the obligation after the insert
+// action at index 'action' if every root has been folded into the tree, which
+// is the case when the insert appended a sector.
+func (sra *sectorRootsAccumulator) recordInsert(roots []crypto.Hash, action int) {
+	if sra.folded != uint64(len(roots)) {
+		return
+	}
+	if sra.insertRoots == nil {
+		sra.insertRoots = make(map[crypto.Hash]int)
+	}
+	sra.insertRoots[sra.tree.Root()] = action
+}
+
+// root returns the Merkle root of the provided sector roots, which must be the
+// roots that the accumulator has been tracking.
+func (sra *sectorRootsAccumulator) root(roots []crypto.Hash) crypto.Hash {
+	sra.fold(roots, uint64(len(roots)))
+	return sra.tree.Root()
+}
+
+// explainMismatch returns an error for a revision whose Merkle root does not
+// match the obligation. If the renter's root matches the obligation as it was
+// after one of the appended sectors, the renter's root is missing the sectors
+// that followed, and the first of them is identified.
+func (sra *sectorRootsAccumulator) explainMismatch(renterRoot crypto.Hash) error {
+	action, exists := sra.insertRoots[renterRoot]
+	if !exists {
+		return errBadFileMerkleRoot
+	}
+	return extendErr(fmt.Sprintf("Merkle root does not include the sectors added after modification %v: ", action), errBadFileMerkleRoot)
+}
+
 // verifyRevision checks that the revision pays the host correctly, and that
-// the revision does not attempt any malicious or unexpected changes.
-func verifyRevision(so storageObligation, revision types.FileContractRevision, blockHeight types.BlockHeight, expectedExchange, expectedCollateral types.Currency) error {
+// the revision does not attempt any malicious or unexpected changes. The
+// Merkle root of the obligation's sector roots is provided by the caller.
+func verifyRevision(so storageObligation, revision types.FileContractRevision, blockHeight types.BlockHeight, expectedExchange, expectedCollateral types.Currency, merkleRoot crypto.Hash) error {
 	// Check that the revision is well-formed.
 	if len(revision.NewValidProofOutputs) != 2 || len(revision.NewMissedProofOutputs) != 3 {
 		return errBadContractOutputCounts
@@ -308,17 +516,9 @@ func verifyRevision(so storageObligation, revision types.FileContractRevision, b
 		return errBadRevisionNumber
 	}
 
-	// The Merkle root is checked last because it is the most expensive check.
-	log2SectorSize := uint64(0)
-	for 1<<log2SectorSize < (modules.SectorSize / crypto.SegmentSize) {
-		log2SectorSize++
-	}
-	ct := crypto.NewCachedTree(log2SectorSize)
-	for _, root := range so.SectorRoots {
-		ct.Push(root)
-	}
-	expectedMerkleRoot := ct.Root()
-	if revision.NewFileMerkleRoot != expectedMerkleRoot {
+	// The Merkle root is checked last so that a mismatch can be explained by
+	// the caller.
+	if revision.NewFileMerkleRoot != merkleRoot {
 		return errBadFileMerkleRoot
 	}
 
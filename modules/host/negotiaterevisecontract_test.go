@@ -0,0 +1,168 @@
+package host
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// randomRoot returns a random sector root.
+func randomRoot() (h crypto.Hash) {
+	fastrand.Read(h[:])
+	return
+}
+
+// TestSectorRootsAccumulator checks that the incremental Merkle root of a set
+// of sector roots is identical to the root computed from scratch for random
+// sequences of insertions, deletions, and modifications.
+func TestSectorRootsAccumulator(t *testing.T) {
+	for trial := 0; trial < 250; trial++ {
+		roots := make([]crypto.Hash, fastrand.Intn(20))
+		for i := range roots {
+			roots[i] = randomRoot()
+		}
+
+		// Half of the trials only check the root at the end, the other half
+		// check the root after every modification, which folds every root
+		// into the tree before the next modification.
+		checkEveryStep := trial%2 == 0
+		var sra sectorRootsAccumulator
+		for op := 0; op < 30; op++ {
+			switch n := fastrand.Intn(4); {
+			case n < 2 || len(roots) == 0:
+				// Insert, favoring appends as uploads typically do.
+				index := uint64(len(roots))
+				if n == 1 {
+					index = uint64(fastrand.Intn(len(roots) + 1))
+				}
+				sra.touch(index)
+				roots = append(roots, crypto.Hash{})
+				copy(roots[index+1:], roots[index:])
+				roots[index] = randomRoot()
+				sra.fold(roots, index+1)
+				sra.recordInsert(roots, op)
+			case n == 2:
+				index := uint64(fastrand.Intn(len(roots)))
+				sra.touch(index)
+				roots = append(roots[:index], roots[index+1:]...)
+				sra.fold(roots, index)
+			default:
+				index := uint64(fastrand.Intn(len(roots)))
+				sra.touch(index)
+				roots[index] = randomRoot()
+				sra.fold(roots, index+1)
+			}
+			if checkEveryStep && sra.root(roots) != sectorRootsMerkleRoot(roots) {
+				t.Fatalf("trial %v: incremental root does not match after operation %v", trial, op)
+			}
+		}
+		if sra.root(roots) != sectorRootsMerkleRoot(roots) {
+			t.Fatalf("trial %v: incremental root does not match", trial)
+		}
+	}
+}
+
+// TestSectorRootsAccumulatorExplainMismatch checks that a Merkle root which
+// is missing appended sectors is reported with the last sector it includes.
+func TestSectorRootsAccumulatorExplainMismatch(t *testing.T) {
+	roots := []crypto.Hash{randomRoot(), randomRoot()}
+	var sra sectorRootsAccumulator
+	var intermediate []crypto.Hash
+	for i := 0; i < 3; i++ {
+		index := uint64(len(roots))
+		sra.touch(index)
+		roots = append(roots, randomRoot())
+		sra.fold(roots, index+1)
+		sra.recordInsert(roots, i)
+		intermediate = append(intermediate, sectorRootsMerkleRoot(roots))
+	}
+	if sra.root(roots) != intermediate[2] {
+		t.Fatal("final root does not match")
+	}
+
+	err := sra.explainMismatch(intermediate[0])
+	if !strings.Contains(err.Error(), "bad file merkle root") || !strings.Contains(err.Error(), "after modification 0") {
+		t.Fatal("mismatch was not explained:", err)
+	}
+	if err := sra.explainMismatch(randomRoot()); err != errBadFileMerkleRoot {
+		t.Fatal("unexpected error for an unrelated root:", err)
+	}
+}
+
+// TestModificationStream checks that decoding a batch of revision actions one
+// at a time is equivalent to decoding the batch with encoding.ReadObject, and
+// that the object following the batch can be read afterwards.
+func TestModificationStream(t *testing.T) {
+	actions := []modules.RevisionAction{
+		{Type: modules.ActionInsert, SectorIndex: 0, Data: fastrand.Bytes(64)},
+		{Type: modules.ActionDelete, SectorIndex: 3},
+		{Type: modules.ActionModify, SectorIndex: 1, Offset: 12, Data: fastrand.Bytes(7)},
+	}
+	revision := types.FileContractRevision{NewRevisionNumber: 5}
+
+	// Encode the batch followed by a revision, as the renter does. A second
+	// encoding pads the batch with trailing bytes, which must be discarded.
+	var buf, padded bytes.Buffer
+	encoding.WriteObject(&buf, actions)
+	encoding.WriteObject(&buf, revision)
+	encoding.WritePrefix(&padded, append(encoding.Marshal(actions), 1, 2, 3))
+	encoding.WriteObject(&padded, revision)
+
+	for _, b := range [][]byte{buf.Bytes(), padded.Bytes()} {
+		r := bytes.NewReader(b)
+		ms, err := newModificationStream(r, 1<<20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded []modules.RevisionAction
+		for {
+			action, more, err := ms.next()
+			if err != nil {
+				t.Fatal(err)
+			} else if !more {
+				break
+			}
+			decoded = append(decoded, action)
+		}
+		if !reflect.DeepEqual(decoded, actions) {
+			t.Fatal("decoded actions do not match")
+		}
+		var decodedRevision types.FileContractRevision
+		err = encoding.ReadObject(r, &decodedRevision, modules.NegotiateMaxFileContractRevisionSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decodedRevision.NewRevisionNumber != revision.NewRevisionNumber {
+			t.Fatal("revision was not decoded correctly")
+		}
+	}
+
+	// A batch that is larger than the limit should be rejected before any of
+	// it is read.
+	if _, err := newModificationStream(bytes.NewReader(buf.Bytes()), 8); err == nil {
+		t.Fatal("expected batch to be rejected for its size")
+	}
+
+	// A batch that claims more actions than it contains should fail to decode
+	// rather than reading past the end of the batch.
+	var short bytes.Buffer
+	encoding.WritePrefix(&short, encoding.MarshalAll(uint64(5), actions[0]))
+	encoding.WriteObject(&short, revision)
+	ms, err := newModificationStream(bytes.NewReader(short.Bytes()), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ms.next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ms.next(); err == nil {
+		t.Fatal("expected decoding past the end of the batch to fail")
+	}
+}
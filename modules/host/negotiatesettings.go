@@ -44,7 +44,7 @@ func (h *Host) externalSettings() modules.HostExternalSettings {
 		UnlockHash:           h.unlockHash,
 		WindowSize:           h.settings.WindowSize,
 
-		Collateral:    h.settings.Collateral,
+		Collateral:    collateralRate(h.settings),
 		MaxCollateral: h.settings.MaxCollateral,
 
 		ContractPrice:          h.settings.MinContractPrice,
@@ -57,6 +57,38 @@ func (h *Host) externalSettings() modules.HostExternalSettings {
 	}
 }
 
+// effectiveSettings returns the external settings that the settings RPC would
+// serve if it were called now, along with the fields of the external settings
+// that differ from the internal settings of the host.
+func (h *Host) effectiveSettings() modules.HostEffectiveSettings {
+	hes := h.externalSettings()
+	// managedRPCSettings increments the revision number before serving the
+	// settings.
+	hes.RevisionNumber++
+
+	overrides := []modules.HostSettingOverride{}
+	if hes.NetAddress != h.settings.NetAddress {
+		overrides = append(overrides, modules.HostSettingOverride{
+			Field:          "netaddress",
+			InternalValue:  string(h.settings.NetAddress),
+			EffectiveValue: string(hes.NetAddress),
+			Reason:         modules.HostSettingOverrideAutoAddress,
+		})
+	}
+	if !hes.Collateral.Equals(h.settings.Collateral) {
+		overrides = append(overrides, modules.HostSettingOverride{
+			Field:          "collateral",
+			InternalValue:  h.settings.Collateral.String(),
+			EffectiveValue: hes.Collateral.String(),
+			Reason:         modules.HostSettingOverrideCollateralFraction,
+		})
+	}
+	return modules.HostEffectiveSettings{
+		Settings:  hes,
+		Overrides: overrides,
+	}
+}
+
 // managedRPCSettings is an rpc that returns the host's settings.
 func (h *Host) managedRPCSettings(conn net.Conn) error {
 	// Set the negotiation deadline.
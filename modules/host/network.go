@@ -15,6 +15,8 @@ package host
 // have to keep all the files following a renew in order to get the money.
 
 import (
+	"crypto/tls"
+	"errors"
 	"net"
 	"sync/atomic"
 	"time"
@@ -25,8 +27,61 @@ import (
 	"github.com/NebulousLabs/Sia/types"
 )
 
-// rpcSettingsDeprecated is a specifier for a deprecated settings request.
-var rpcSettingsDeprecated = types.Specifier{'S', 'e', 't', 't', 'i', 'n', 'g', 's'}
+var (
+	// errTLSRequired is sent to renters in a versioned session that call an
+	// RPC other than the settings RPC unencrypted while TLSRequired is set.
+	errTLSRequired = errors.New("host only serves RPCs over TLS")
+
+	// errUnrecognizedRPC is sent to renters in a versioned session that call
+	// an RPC that the host does not serve.
+	errUnrecognizedRPC = errors.New("host does not serve the requested RPC")
+
+	// errUnsupportedRPCVersion is sent to renters that select an RPC version
+	// that the host does not support.
+	errUnsupportedRPCVersion = errors.New("host does not support the selected RPC version")
+
+	// hostRPCs are the RPCs that the host advertises in versioned sessions.
+	hostRPCs = []types.Specifier{
+		modules.RPCDownload,
+		modules.RPCFormContract,
+		modules.RPCRenewContract,
+		modules.RPCRenterIdentity,
+		modules.RPCReviseContract,
+		modules.RPCSettings,
+	}
+
+	// rpcSettingsDeprecated is a specifier for a deprecated settings request.
+	rpcSettingsDeprecated = types.Specifier{'S', 'e', 't', 't', 'i', 'n', 'g', 's'}
+)
+
+// negotiateRPCVersion performs the host's side of the handshake that starts a
+// versioned session, advertising rpcs, and returns the RPC version that the
+// renter selected.
+func negotiateRPCVersion(conn net.Conn, rpcs []types.Specifier) (uint64, error) {
+	err := encoding.WriteObject(conn, modules.RPCVersions{
+		Versions: modules.SupportedRPCVersions,
+		RPCs:     rpcs,
+	})
+	if err != nil {
+		return 0, err
+	}
+	var version uint64
+	err = encoding.ReadObject(conn, &version, 8)
+	if err != nil {
+		return 0, err
+	}
+	for _, v := range modules.SupportedRPCVersions {
+		if v == version {
+			return version, modules.WriteNegotiationAcceptance(conn)
+		}
+	}
+	if version == 0 {
+		// The renter does not support any of the versions of the host, and
+		// has ended the session.
+		return 0, modules.ErrNoCommonRPCVersion
+	}
+	return 0, modules.WriteNegotiationRejection(conn, errUnsupportedRPCVersion)
+}
 
 // threadedUpdateHostname periodically runs 'managedLearnHostname', which
 // checks if the host's hostname has changed, and makes an updated host
@@ -103,6 +158,20 @@ func (h *Host) threadedTrackWorkingStatus(closeChan chan struct{}) {
 	}
 }
 
+// managedCheckConnectable returns an error if the host cannot connect to
+// itself at addr.
+func (h *Host) managedCheckConnectable(addr modules.NetAddress) error {
+	dialer := &net.Dialer{
+		Cancel:  h.tg.StopChan(),
+		Timeout: connectabilityCheckTimeout,
+	}
+	conn, err := dialer.Dial("tcp", string(addr))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
 // threadedTrackConnectabilityStatus periodically checks if the host is
 // connectable at its netaddress.
 func (h *Host) threadedTrackConnectabilityStatus(closeChan chan struct{}) {
@@ -127,17 +196,10 @@ func (h *Host) threadedTrackConnectabilityStatus(closeChan chan struct{}) {
 			activeAddr = userAddr
 		}
 
-		dialer := &net.Dialer{
-			Cancel:  h.tg.StopChan(),
-			Timeout: connectabilityCheckTimeout,
-		}
-		conn, err := dialer.Dial("tcp", string(activeAddr))
-
 		var status modules.HostConnectabilityStatus
-		if err != nil {
+		if h.managedCheckConnectable(activeAddr) != nil {
 			status = modules.HostConnectabilityStatusNotConnectable
 		} else {
-			conn.Close()
 			status = modules.HostConnectabilityStatusConnectable
 		}
 		h.mu.Lock()
@@ -242,12 +304,36 @@ func (h *Host) initNetworking(address string) (err error) {
 
 // threadedHandleConn handles an incoming connection to the host, typically an
 // RPC.
-func (h *Host) threadedHandleConn(conn net.Conn) {
+func (h *Host) threadedHandleConn(rawConn net.Conn) {
 	err := h.tg.Add()
 	if err != nil {
 		return
 	}
 	defer h.tg.Done()
+	rawConn = h.bandwidthLimiter.LimitConn(rawConn)
+
+	// Renters that have pinned the certificate of the host start the
+	// connection with a TLS handshake.
+	upgradedConn, err := h.managedUpgradeConn(rawConn)
+	if err != nil {
+		atomic.AddUint64(&h.atomicUnrecognizedCalls, 1)
+		h.log.Debugf("WARN: incoming conn %v could not be upgraded to TLS: %v", rawConn.RemoteAddr(), err)
+		rawConn.Close()
+		return
+	}
+
+	// Count the bandwidth consumed by the connection. Connections that are not
+	// attributed to an RPC by the time they are closed are counted as other
+	// bandwidth. The RPC is served on rpcConn, which is upgraded to TLS if
+	// the renter calls RPCUpgradeTLS.
+	conn := &countingConn{Conn: upgradedConn}
+	defer conn.attribute(&h.otherBandwidth)
+	var rpcConn net.Conn = conn
+	_, secure := upgradedConn.(*tls.Conn)
+	h.mu.RLock()
+	tlsEnabled := h.tlsConfig != nil
+	tlsRequired := h.settings.TLSRequired
+	h.mu.RUnlock()
 
 	// Close the conn on host.Close or when the method terminates, whichever comes
 	// first.
@@ -269,35 +355,115 @@ func (h *Host) threadedHandleConn(conn net.Conn) {
 		return
 	}
 
-	// Read a specifier indicating which action is being called.
+	// Read a specifier indicating which action is being called. Renters that
+	// start a versioned session negotiate the RPC version first, and then send
+	// the specifier of the RPC. Legacy sessions, which send the specifier of
+	// the RPC right away, are still served while renters upgrade.
 	var id types.Specifier
 	if err := encoding.ReadObject(conn, &id, 16); err != nil {
 		atomic.AddUint64(&h.atomicUnrecognizedCalls, 1)
 		h.log.Debugf("WARN: incoming conn %v was malformed: %v", conn.RemoteAddr(), err)
 		return
 	}
+	var version uint64
+	var renterID types.SiaPublicKey
+	if id == modules.RPCVersion {
+		// Renters can only upgrade sessions that are not encrypted yet.
+		rpcs := hostRPCs
+		if tlsEnabled && !secure {
+			rpcs = append(append([]types.Specifier(nil), hostRPCs...), modules.RPCUpgradeTLS)
+		}
+		version, err = negotiateRPCVersion(conn, rpcs)
+		if err != nil {
+			atomic.AddUint64(&h.atomicErroredCalls, 1)
+			h.managedLogError(extendErr("error with "+conn.RemoteAddr().String()+": ", ErrorCommunication("RPC version negotiation failed: "+err.Error())))
+			return
+		}
+		h.mu.Lock()
+		h.rpcVersionCalls[version]++
+		h.mu.Unlock()
+		if err := encoding.ReadObject(conn, &id, 16); err != nil {
+			atomic.AddUint64(&h.atomicUnrecognizedCalls, 1)
+			h.log.Debugf("WARN: incoming conn %v was malformed: %v", conn.RemoteAddr(), err)
+			return
+		}
+		if id == modules.RPCUpgradeTLS && tlsEnabled && !secure {
+			rpcConn, err = h.managedRPCUpgradeTLS(conn)
+			if err != nil {
+				atomic.AddUint64(&h.atomicErroredCalls, 1)
+				h.managedLogError(extendErr("error with "+conn.RemoteAddr().String()+": ", ErrorCommunication("TLS upgrade failed: "+err.Error())))
+				return
+			}
+			secure = true
+			if err := encoding.ReadObject(rpcConn, &id, 16); err != nil {
+				atomic.AddUint64(&h.atomicUnrecognizedCalls, 1)
+				h.log.Debugf("WARN: incoming conn %v was malformed: %v", conn.RemoteAddr(), err)
+				return
+			}
+		}
+		// Renters identify themselves after the session has been upgraded to
+		// TLS, so that the identity is not sent in the clear.
+		if id == modules.RPCRenterIdentity {
+			renterID, err = h.managedRPCRenterIdentity(rpcConn)
+			if err != nil {
+				atomic.AddUint64(&h.atomicErroredCalls, 1)
+				h.managedLogError(extendErr("error with "+conn.RemoteAddr().String()+": ", ErrorCommunication("renter identification failed: "+err.Error())))
+				return
+			}
+			if err := encoding.ReadObject(rpcConn, &id, 16); err != nil {
+				atomic.AddUint64(&h.atomicUnrecognizedCalls, 1)
+				h.log.Debugf("WARN: incoming conn %v was malformed: %v", conn.RemoteAddr(), err)
+				return
+			}
+		}
+	} else {
+		atomic.AddUint64(&h.atomicLegacyCalls, 1)
+	}
+
+	// In secure-only mode, only the settings RPC is served unencrypted. The
+	// settings are public and signed by the host, and renters need them to
+	// find the host.
+	if tlsRequired && !secure && id != modules.RPCSettings {
+		atomic.AddUint64(&h.atomicUnrecognizedCalls, 1)
+		h.log.Debugf("WARN: incoming conn %v called RPC \"%v\" without TLS", conn.RemoteAddr(), id)
+		if version > 0 {
+			modules.WriteNegotiationRejection(rpcConn, errTLSRequired)
+		}
+		return
+	}
 
 	switch id {
 	case modules.RPCDownload:
 		atomic.AddUint64(&h.atomicDownloadCalls, 1)
-		err = extendErr("incoming RPCDownload failed: ", h.managedRPCDownload(conn))
+		conn.attribute(&h.downloadBandwidth)
+		err = extendErr("incoming RPCDownload failed: ", h.managedRPCDownload(rpcConn))
 	case modules.RPCRenewContract:
 		atomic.AddUint64(&h.atomicRenewCalls, 1)
-		err = extendErr("incoming RPCRenewContract failed: ", h.managedRPCRenewContract(conn))
+		conn.attribute(&h.renewBandwidth)
+		err = extendErr("incoming RPCRenewContract failed: ", h.managedRPCRenewContract(rpcConn, renterID))
 	case modules.RPCFormContract:
 		atomic.AddUint64(&h.atomicFormContractCalls, 1)
-		err = extendErr("incoming RPCFormContract failed: ", h.managedRPCFormContract(conn))
+		conn.attribute(&h.formContractBandwidth)
+		err = extendErr("incoming RPCFormContract failed: ", h.managedRPCFormContract(rpcConn, renterID))
 	case modules.RPCReviseContract:
 		atomic.AddUint64(&h.atomicReviseCalls, 1)
-		err = extendErr("incoming RPCReviseContract failed: ", h.managedRPCReviseContract(conn))
+		conn.attribute(&h.reviseBandwidth)
+		err = extendErr("incoming RPCReviseContract failed: ", h.managedRPCReviseContract(rpcConn))
 	case modules.RPCSettings:
 		atomic.AddUint64(&h.atomicSettingsCalls, 1)
-		err = extendErr("incoming RPCSettings failed: ", h.managedRPCSettings(conn))
+		conn.attribute(&h.settingsBandwidth)
+		h.settingsCallers.record(conn.RemoteAddr(), time.Now())
+		err = extendErr("incoming RPCSettings failed: ", h.managedRPCSettings(rpcConn))
 	case rpcSettingsDeprecated:
 		h.log.Debugln("Received deprecated settings call")
 	default:
 		h.log.Debugf("WARN: incoming conn %v requested unknown RPC \"%v\"", conn.RemoteAddr(), id)
 		atomic.AddUint64(&h.atomicUnrecognizedCalls, 1)
+		if version > 0 {
+			// Renters in a versioned session are told that the RPC is not
+			// served, so that they can fall back to another RPC.
+			modules.WriteNegotiationRejection(rpcConn, errUnrecognizedRPC)
+		}
 	}
 	if err != nil {
 		atomic.AddUint64(&h.atomicErroredCalls, 1)
@@ -345,13 +511,51 @@ func (h *Host) NetAddress() modules.NetAddress {
 func (h *Host) NetworkMetrics() modules.HostNetworkMetrics {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	return modules.HostNetworkMetrics{
+	nm := modules.HostNetworkMetrics{
 		DownloadCalls:     atomic.LoadUint64(&h.atomicDownloadCalls),
 		ErrorCalls:        atomic.LoadUint64(&h.atomicErroredCalls),
 		FormContractCalls: atomic.LoadUint64(&h.atomicFormContractCalls),
 		RenewCalls:        atomic.LoadUint64(&h.atomicRenewCalls),
 		ReviseCalls:       atomic.LoadUint64(&h.atomicReviseCalls),
 		SettingsCalls:     atomic.LoadUint64(&h.atomicSettingsCalls),
+		UnauthorizedCalls: atomic.LoadUint64(&h.atomicUnauthorizedCalls),
 		UnrecognizedCalls: atomic.LoadUint64(&h.atomicUnrecognizedCalls),
+
+		CollateralClampedCalls: atomic.LoadUint64(&h.atomicCollateralClampedCalls),
+
+		LegacyCalls:  atomic.LoadUint64(&h.atomicLegacyCalls),
+		VersionCalls: make(map[uint64]uint64, len(h.rpcVersionCalls)),
+
+		RPCBandwidth: modules.HostRPCBandwidthMetrics{
+			Download:     h.downloadBandwidth.metrics(),
+			FormContract: h.formContractBandwidth.metrics(),
+			Renew:        h.renewBandwidth.metrics(),
+			Revise:       h.reviseBandwidth.metrics(),
+			Settings:     h.settingsBandwidth.metrics(),
+			Other:        h.otherBandwidth.metrics(),
+		},
+	}
+	for version, calls := range h.rpcVersionCalls {
+		nm.VersionCalls[version] = calls
+	}
+	nm.UniqueSettingsCallers = h.settingsCallers.count(time.Now())
+	renters, err := h.uniqueRenters()
+	if err != nil {
+		h.log.Println("Unable to count the renters of the host:", err)
+	}
+	nm.UniqueRenters = renters
+	// The totals are computed from the breakdown so that they always equal
+	// its sum.
+	for _, bw := range []modules.HostRPCBandwidth{
+		nm.RPCBandwidth.Download,
+		nm.RPCBandwidth.FormContract,
+		nm.RPCBandwidth.Renew,
+		nm.RPCBandwidth.Revise,
+		nm.RPCBandwidth.Settings,
+		nm.RPCBandwidth.Other,
+	} {
+		nm.DownloadBandwidthConsumed += bw.DownloadBandwidth
+		nm.UploadBandwidthConsumed += bw.UploadBandwidth
 	}
+	return nm
 }
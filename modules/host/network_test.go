@@ -1,12 +1,18 @@
 package host
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 // blockingPortForward is a dependency set that causes the host port forward
@@ -143,3 +149,165 @@ func TestHostConnectabilityStatus(t *testing.T) {
 		t.Fatal("expected connectability state to flip to HostConnectabilityStatusConnectable")
 	}
 }
+
+// TestRPCBandwidth checks that the bandwidth of each connection is attributed
+// to the RPC that was called, and that the totals equal the sum of the
+// breakdown.
+func TestRPCBandwidth(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// call dials the host, calls the RPC, and reads the response until the
+	// host closes the connection. The number of bytes read is returned.
+	call := func(rpc types.Specifier) uint64 {
+		conn, err := net.Dial("tcp", string(ht.host.ExternalSettings().NetAddress))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if err := encoding.WriteObject(conn, rpc); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := ioutil.ReadAll(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return uint64(len(resp))
+	}
+	settingsResp := call(modules.RPCSettings)
+	if settingsResp == 0 {
+		t.Fatal("host did not respond to the settings RPC")
+	}
+	if resp := call(types.Specifier{'f', 'o', 'o'}); resp != 0 {
+		t.Fatal("host responded to an unrecognized RPC")
+	}
+
+	// An RPC specifier is sent with an 8 byte length prefix.
+	var nm modules.HostNetworkMetrics
+	err = build.Retry(100, 10*time.Millisecond, func() error {
+		nm = ht.host.NetworkMetrics()
+		if nm.RPCBandwidth.Other.DownloadBandwidth != 24 {
+			return errors.New("unrecognized RPC was not counted")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := modules.HostRPCBandwidthMetrics{
+		Settings: modules.HostRPCBandwidth{DownloadBandwidth: 24, UploadBandwidth: settingsResp},
+		Other:    modules.HostRPCBandwidth{DownloadBandwidth: 24},
+	}
+	if nm.RPCBandwidth != expected {
+		t.Errorf("expected bandwidth %+v, got %+v", expected, nm.RPCBandwidth)
+	}
+	if nm.DownloadBandwidthConsumed != 48 || nm.UploadBandwidthConsumed != settingsResp {
+		t.Error("totals do not equal the sum of the breakdown:", nm.DownloadBandwidthConsumed, nm.UploadBandwidthConsumed)
+	}
+	if nm.UniqueSettingsCallers != 1 {
+		t.Error("expected 1 settings caller, got", nm.UniqueSettingsCallers)
+	}
+}
+
+// TestRPCVersionNegotiation checks that renters can negotiate an RPC version
+// before calling an RPC, that legacy sessions are still served, and that the
+// sessions are counted per negotiated version.
+func TestRPCVersionNegotiation(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	dial := func() net.Conn {
+		conn, err := net.Dial("tcp", string(ht.host.ExternalSettings().NetAddress))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+
+	// Call the settings RPC in a legacy session.
+	conn := dial()
+	if err := encoding.WriteObject(conn, modules.RPCSettings); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := ioutil.ReadAll(conn); err != nil || len(resp) == 0 {
+		t.Fatal("host did not respond to the legacy settings RPC:", err)
+	}
+	conn.Close()
+
+	// Call the settings RPC in a versioned session.
+	conn = dial()
+	version, hv, err := modules.NegotiateRPCVersion(conn, modules.SupportedRPCVersions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 || len(hv.Versions) != 1 || len(hv.RPCs) != len(hostRPCs) {
+		t.Fatalf("unexpected negotiation result: version %v, %+v", version, hv)
+	}
+	if err := encoding.WriteObject(conn, modules.RPCSettings); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := ioutil.ReadAll(conn); err != nil || len(resp) == 0 {
+		t.Fatal("host did not respond to the versioned settings RPC:", err)
+	}
+	conn.Close()
+
+	// Unknown RPCs in a versioned session should be rejected with an error.
+	conn = dial()
+	if _, _, err := modules.NegotiateRPCVersion(conn, modules.SupportedRPCVersions); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoding.WriteObject(conn, types.Specifier{'f', 'o', 'o'}); err != nil {
+		t.Fatal(err)
+	}
+	if err := modules.ReadNegotiationAcceptance(conn); err == nil || err.Error() != errUnrecognizedRPC.Error() {
+		t.Fatal("expected errUnrecognizedRPC, got", err)
+	}
+	conn.Close()
+
+	// Versions that the host does not support should be rejected.
+	conn = dial()
+	if _, _, err := modules.NegotiateRPCVersion(conn, []uint64{99}); err != modules.ErrNoCommonRPCVersion {
+		t.Fatal("expected ErrNoCommonRPCVersion, got", err)
+	}
+	conn.Close()
+	conn = dial()
+	var hostVersions modules.RPCVersions
+	if err := encoding.WriteObject(conn, modules.RPCVersion); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoding.ReadObject(conn, &hostVersions, modules.NegotiateMaxRPCVersionsSize); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoding.WriteObject(conn, uint64(99)); err != nil {
+		t.Fatal(err)
+	}
+	if err := modules.ReadNegotiationAcceptance(conn); err == nil || err.Error() != errUnsupportedRPCVersion.Error() {
+		t.Fatal("expected errUnsupportedRPCVersion, got", err)
+	}
+	conn.Close()
+
+	err = build.Retry(100, 10*time.Millisecond, func() error {
+		nm := ht.host.NetworkMetrics()
+		if nm.LegacyCalls != 1 || nm.VersionCalls[1] != 2 || len(nm.VersionCalls) != 1 || nm.UnrecognizedCalls != 1 {
+			return fmt.Errorf("unexpected metrics: legacy %v, versioned %v, unrecognized %v", nm.LegacyCalls, nm.VersionCalls, nm.UnrecognizedCalls)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
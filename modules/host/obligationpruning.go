@@ -0,0 +1,106 @@
+package host
+
+// obligationpruning.go removes storage obligations that were resolved more
+// than ObligationRetentionBlocks blocks ago from the host's database, so that
+// the database does not grow forever. The revenue of a resolved obligation is
+// already part of the financial metrics, so only the number of pruned
+// obligations is added to the metrics before the obligations are deleted.
+//
+// The financial metrics are saved in the settings file, so they cannot be
+// updated atomically with the database. Every pruning pass prunes all of the
+// obligations that were resolved before a cutoff height, and the settings file
+// records the cutoff as PrunedBeforeHeight before the obligations are
+// deleted. If the host crashes before the obligations are deleted, the next
+// pass deletes them without counting them again.
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// managedPruneObligations prunes the resolved storage obligations that are
+// older than the host's ObligationRetentionBlocks. Unresolved obligations are
+// never pruned.
+func (h *Host) managedPruneObligations() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	retention := h.settings.ObligationRetentionBlocks
+	if retention == 0 || h.blockHeight <= retention {
+		return nil
+	}
+	cutoff := h.blockHeight - retention
+
+	var pruned []types.FileContractID
+	var counted, failed uint64
+	err := h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+			var so storageObligation
+			err := json.Unmarshal(soBytes, &so)
+			if err != nil {
+				return err
+			}
+			if so.ObligationStatus == obligationUnresolved || so.ResolutionHeight >= cutoff {
+				return nil
+			}
+			pruned = append(pruned, so.id())
+			if so.ResolutionHeight < h.financialMetrics.PrunedBeforeHeight {
+				// The obligation was counted by a pass that did not finish
+				// deleting it.
+				return nil
+			}
+			counted++
+			if so.ObligationStatus == obligationFailed {
+				failed++
+			}
+			return nil
+		})
+	})
+	if err != nil || len(pruned) == 0 {
+		return err
+	}
+
+	h.financialMetrics.PrunedObligations += counted
+	h.financialMetrics.PrunedFailedObligations += failed
+	h.financialMetrics.PrunedBeforeHeight = cutoff
+	err = h.saveSync()
+	if err != nil {
+		return err
+	}
+	err = h.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketStorageObligations)
+		for _, id := range pruned {
+			err := b.Delete(id[:])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	h.log.Printf("Pruned %v storage obligations that were resolved before block %v\n", len(pruned), cutoff)
+	return nil
+}
+
+// threadedPruneObligations prunes old resolved storage obligations every
+// obligationPruneInterval.
+func (h *Host) threadedPruneObligations(closeChan chan struct{}) {
+	defer close(closeChan)
+	for {
+		err := h.managedPruneObligations()
+		if err != nil {
+			h.log.Println("Unable to prune storage obligations:", err)
+		}
+		select {
+		case <-h.tg.StopChan():
+			return
+		case <-time.After(obligationPruneInterval):
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package host
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// TestPruneObligations checks that the host prunes resolved storage
+// obligations once they are older than ObligationRetentionBlocks, that
+// unresolved obligations are never pruned, and that the pruned obligations
+// are counted in the financial metrics exactly once.
+func TestPruneObligations(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	retention := types.MaturityDelay
+	for i := types.BlockHeight(0); i <= retention; i++ {
+		if _, err := ht.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ht.host.mu.RLock()
+	height := ht.host.blockHeight
+	ht.host.mu.RUnlock()
+
+	// putObligations puts obligations with the provided statuses and
+	// resolution heights into the database.
+	var next uint64
+	putObligations := func(obligations ...storageObligation) {
+		err := ht.host.db.Update(func(tx *bolt.Tx) error {
+			for _, so := range obligations {
+				next++
+				so.OriginTransactionSet = []types.Transaction{{
+					FileContracts: []types.FileContract{{FileSize: next}},
+				}}
+				if err := putStorageObligation(tx, so); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	putObligations(
+		storageObligation{ObligationStatus: obligationSucceeded, ResolutionHeight: 0},
+		storageObligation{ObligationStatus: obligationFailed, ResolutionHeight: height - retention - 1},
+		storageObligation{ObligationStatus: obligationRejected, ResolutionHeight: 1},
+		storageObligation{ObligationStatus: obligationSucceeded, ResolutionHeight: height - retention},
+		storageObligation{ObligationStatus: obligationFailed, ResolutionHeight: height},
+		storageObligation{ObligationStatus: obligationUnresolved},
+	)
+
+	// Nothing is pruned until a retention is set.
+	if err := ht.host.managedPruneObligations(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(ht.host.StorageObligations()); n != 6 {
+		t.Fatal("expected 6 obligations before setting a retention, got", n)
+	}
+
+	settings := ht.host.InternalSettings()
+	settings.ObligationRetentionBlocks = retention
+	if err := ht.host.SetInternalSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		if n := len(ht.host.StorageObligations()); n != 3 {
+			return errors.New("obligations were not pruned")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unresolved int
+	for _, so := range ht.host.StorageObligations() {
+		if so.ObligationStatus == uint64(obligationUnresolved) {
+			unresolved++
+		}
+	}
+	if unresolved != 1 {
+		t.Fatal("unresolved obligation was pruned")
+	}
+	fm := ht.host.FinancialMetrics()
+	if fm.PrunedObligations != 3 || fm.PrunedFailedObligations != 1 || fm.PrunedBeforeHeight != height-retention {
+		t.Fatalf("wrong pruning metrics: %v %v %v", fm.PrunedObligations, fm.PrunedFailedObligations, fm.PrunedBeforeHeight)
+	}
+	diagnostics := ht.host.StorageProofDiagnostics()
+	if diagnostics.FailedObligations != 2 {
+		t.Error("expected 2 failed obligations, got", diagnostics.FailedObligations)
+	}
+
+	// An obligation from before PrunedBeforeHeight was already counted by a
+	// pass that did not finish deleting it, so it is deleted without being
+	// counted again.
+	putObligations(storageObligation{ObligationStatus: obligationFailed, ResolutionHeight: 0})
+	if err := ht.host.managedPruneObligations(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(ht.host.StorageObligations()); n != 3 {
+		t.Fatal("expected 3 obligations, got", n)
+	}
+	if fm := ht.host.FinancialMetrics(); fm.PrunedObligations != 3 || fm.PrunedFailedObligations != 1 {
+		t.Fatalf("obligation was counted twice: %v %v", fm.PrunedObligations, fm.PrunedFailedObligations)
+	}
+
+	// The metrics survive a restart.
+	if err := ht.host.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ht.host, err = New(ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fm := ht.host.FinancialMetrics(); fm.PrunedObligations != 3 || fm.PrunedBeforeHeight != height-retention {
+		t.Fatalf("pruning metrics were not persisted: %v %v", fm.PrunedObligations, fm.PrunedBeforeHeight)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -24,11 +25,14 @@ type persistence struct {
 	Announced        bool                         `json:"announced"`
 	AutoAddress      modules.NetAddress           `json:"autoaddress"`
 	FinancialMetrics modules.HostFinancialMetrics `json:"financialmetrics"`
+	LastProofTest    time.Time                    `json:"lastprooftest"`
 	PublicKey        types.SiaPublicKey           `json:"publickey"`
+	RevenueSequence  uint64                       `json:"revenuesequence"`
 	RevisionNumber   uint64                       `json:"revisionnumber"`
 	SecretKey        crypto.SecretKey             `json:"secretkey"`
 	Settings         modules.HostInternalSettings `json:"settings"`
 	UnlockHash       types.UnlockHash             `json:"unlockhash"`
+	WindDown         bool                         `json:"winddown"`
 }
 
 // persistData returns the data in the Host that will be saved to disk.
@@ -42,11 +46,14 @@ func (h *Host) persistData() persistence {
 		Announced:        h.announced,
 		AutoAddress:      h.autoAddress,
 		FinancialMetrics: h.financialMetrics,
+		LastProofTest:    h.lastProofTest,
 		PublicKey:        h.publicKey,
+		RevenueSequence:  h.revenueSequence,
 		RevisionNumber:   h.revisionNumber,
 		SecretKey:        h.secretKey,
 		Settings:         h.settings,
 		UnlockHash:       h.unlockHash,
+		WindDown:         h.windDown,
 	}
 }
 
@@ -58,6 +65,7 @@ func (h *Host) establishDefaults() error {
 		MaxDownloadBatchSize: uint64(defaultMaxDownloadBatchSize),
 		MaxDuration:          defaultMaxDuration,
 		MaxReviseBatchSize:   uint64(defaultMaxReviseBatchSize),
+		MaxSessionMemory:     defaultMaxSessionMemory,
 		WindowSize:           defaultWindowSize,
 
 		Collateral:       defaultCollateral,
@@ -98,7 +106,9 @@ func (h *Host) loadPersistObject(p *persistence) {
 		h.autoAddress = ""
 	}
 	h.financialMetrics = p.FinancialMetrics
+	h.lastProofTest = p.LastProofTest
 	h.publicKey = p.PublicKey
+	h.revenueSequence = p.RevenueSequence
 	h.revisionNumber = p.RevisionNumber
 	h.secretKey = p.SecretKey
 	h.settings = p.Settings
@@ -106,7 +116,13 @@ func (h *Host) loadPersistObject(p *persistence) {
 		h.log.Printf("WARN: NetAddress '%v' loaded from persist is invalid: %v", p.Settings.NetAddress, err)
 		h.settings.NetAddress = ""
 	}
+	tlsConfig, err := h.loadTLSConfig(p.Settings.TLSCertFile, p.Settings.TLSKeyFile)
+	if err != nil {
+		h.log.Println("WARN: TLS is disabled, the TLS certificate could not be loaded:", err)
+	}
+	h.tlsConfig = tlsConfig
 	h.unlockHash = p.UnlockHash
+	h.windDown = p.WindDown
 }
 
 // initDB will check that the database has been initialized and if not, will
@@ -129,6 +145,7 @@ func (h *Host) initDB() (err error) {
 		// database needs to be initialized. Create the database buckets.
 		buckets := [][]byte{
 			bucketActionItems,
+			bucketSectorDeletions,
 			bucketStorageObligations,
 		}
 		for _, bucket := range buckets {
@@ -172,6 +189,13 @@ func (h *Host) load() error {
 		return err
 	}
 
+	// Replay the revenue transitions that were committed to the database after
+	// the settings file was last saved.
+	err = h.replayRevenueTransitions()
+	if err != nil {
+		return err
+	}
+
 	// Get the contract count by observing all of the incomplete storage
 	// obligations in the database.
 	h.financialMetrics.ContractCount = 0
@@ -193,6 +217,12 @@ func (h *Host) load() error {
 		return err
 	}
 
+	// Count the sectors that are still queued for removal.
+	h.queuedSectorDeletions, err = h.countSectorDeletions()
+	if err != nil {
+		return err
+	}
+
 	return h.initConsensusSubscription()
 }
 
@@ -0,0 +1,82 @@
+package host
+
+import (
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// profitabilityReport computes the profitability of so at the provided block
+// height.
+func profitabilityReport(so storageObligation, height types.BlockHeight) modules.ProfitabilityReport {
+	report := modules.ProfitabilityReport{
+		ContractID:       so.id(),
+		ObligationStatus: uint64(so.ObligationStatus),
+		LockedCollateral: so.LockedCollateral,
+		TransactionFees:  so.TransactionFeesAdded,
+	}
+	switch so.ObligationStatus {
+	case obligationUnresolved:
+		// The storage revenue is earned over the duration of the contract,
+		// the other revenue has been earned once it was paid in a revision.
+		report.ContractCompensation = so.ContractCost
+		report.BandwidthRevenue = so.PotentialDownloadRevenue.Add(so.PotentialUploadRevenue)
+		report.StorageRevenue = so.PotentialStorageRevenue
+		expiration := so.expiration()
+		if height < expiration && so.NegotiationHeight < expiration {
+			var elapsed types.BlockHeight
+			if height > so.NegotiationHeight {
+				elapsed = height - so.NegotiationHeight
+			}
+			duration := expiration - so.NegotiationHeight
+			report.StorageRevenue = so.PotentialStorageRevenue.Mul64(uint64(elapsed)).Div64(uint64(duration))
+			report.EstimatedFutureRevenue = so.PotentialStorageRevenue.Sub(report.StorageRevenue)
+		}
+	case obligationSucceeded:
+		report.ContractCompensation = so.ContractCost
+		report.BandwidthRevenue = so.PotentialDownloadRevenue.Add(so.PotentialUploadRevenue)
+		report.StorageRevenue = so.PotentialStorageRevenue
+	case obligationFailed:
+		// The revenue of the contract is lost together with the risked
+		// collateral.
+		report.LostCollateral = so.RiskedCollateral
+	case obligationRejected:
+		// The contract never made it onto the blockchain, so neither the
+		// fees nor the collateral were spent.
+		report.TransactionFees = types.ZeroCurrency
+	}
+
+	revenue := report.ContractCompensation.Add(report.StorageRevenue).Add(report.BandwidthRevenue).Add(report.EstimatedFutureRevenue)
+	costs := report.TransactionFees.Add(report.LostCollateral)
+	if revenue.Cmp(costs) >= 0 {
+		report.NetProfit = revenue.Sub(costs)
+	} else {
+		report.NetProfit = costs.Sub(revenue)
+		report.Loss = true
+	}
+	return report
+}
+
+// ContractProfitability returns the revenue, collateral, and transaction fees
+// of the storage obligation of the file contract id, and its expected net
+// profit. Obligations that have been pruned cannot be reported.
+func (h *Host) ContractProfitability(id types.FileContractID) (modules.ProfitabilityReport, error) {
+	if err := h.tg.Add(); err != nil {
+		return modules.ProfitabilityReport{}, err
+	}
+	defer h.tg.Done()
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var so storageObligation
+	err := h.db.View(func(tx *bolt.Tx) error {
+		var err error
+		so, err = getStorageObligation(tx, id)
+		return err
+	})
+	if err != nil {
+		return modules.ProfitabilityReport{}, err
+	}
+	return profitabilityReport(so, h.blockHeight), nil
+}
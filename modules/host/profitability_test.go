@@ -0,0 +1,72 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestProfitabilityReport checks the profitability of obligations in each
+// status, including an obligation whose fees exceed its revenue.
+func TestProfitabilityReport(t *testing.T) {
+	so := storageObligation{
+		ContractCost:             types.NewCurrency64(10),
+		LockedCollateral:         types.NewCurrency64(500),
+		PotentialDownloadRevenue: types.NewCurrency64(20),
+		PotentialStorageRevenue:  types.NewCurrency64(100),
+		PotentialUploadRevenue:   types.NewCurrency64(5),
+		RiskedCollateral:         types.NewCurrency64(200),
+		TransactionFeesAdded:     types.NewCurrency64(15),
+		NegotiationHeight:        100,
+		OriginTransactionSet: []types.Transaction{{
+			FileContracts: []types.FileContract{{WindowStart: 200}},
+		}},
+	}
+
+	// Halfway through the contract, half of the storage revenue has been
+	// earned.
+	report := profitabilityReport(so, 150)
+	if report.ContractID != so.id() || !report.StorageRevenue.Equals64(50) || !report.EstimatedFutureRevenue.Equals64(50) {
+		t.Fatalf("wrong storage revenue halfway through the contract: %+v", report)
+	}
+	if !report.BandwidthRevenue.Equals64(25) || !report.ContractCompensation.Equals64(10) || !report.LockedCollateral.Equals64(500) {
+		t.Fatalf("wrong revenue or collateral: %+v", report)
+	}
+	if !report.NetProfit.Equals64(10+100+25-15) || report.Loss {
+		t.Fatalf("wrong net profit of an unresolved obligation: %+v", report)
+	}
+
+	// At the end of the contract, the storage revenue has been earned.
+	report = profitabilityReport(so, 200)
+	if !report.StorageRevenue.Equals64(100) || !report.EstimatedFutureRevenue.IsZero() {
+		t.Fatalf("wrong storage revenue at the end of the contract: %+v", report)
+	}
+	so.ObligationStatus = obligationSucceeded
+	report = profitabilityReport(so, 150)
+	if !report.StorageRevenue.Equals64(100) || !report.EstimatedFutureRevenue.IsZero() || !report.NetProfit.Equals64(120) {
+		t.Fatalf("wrong profitability of a succeeded obligation: %+v", report)
+	}
+
+	// A failed obligation loses its revenue and its risked collateral.
+	so.ObligationStatus = obligationFailed
+	report = profitabilityReport(so, 250)
+	if !report.StorageRevenue.IsZero() || !report.LostCollateral.Equals64(200) || !report.NetProfit.Equals64(215) || !report.Loss {
+		t.Fatalf("wrong profitability of a failed obligation: %+v", report)
+	}
+
+	// A rejected obligation never paid its fees.
+	so.ObligationStatus = obligationRejected
+	report = profitabilityReport(so, 250)
+	if !report.TransactionFees.IsZero() || !report.NetProfit.IsZero() || report.Loss {
+		t.Fatalf("wrong profitability of a rejected obligation: %+v", report)
+	}
+
+	// Fees that exceed the revenue of an unresolved obligation make it a
+	// loss.
+	so.ObligationStatus = obligationUnresolved
+	so.TransactionFeesAdded = types.NewCurrency64(1000)
+	report = profitabilityReport(so, 150)
+	if !report.NetProfit.Equals64(1000-135) || !report.Loss {
+		t.Fatalf("fees exceeding the revenue are not reported as a loss: %+v", report)
+	}
+}
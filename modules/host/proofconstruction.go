@@ -0,0 +1,148 @@
+package host
+
+// proofconstruction.go estimates how long it takes the host to construct the
+// storage proof of an obligation. The estimate is conservative: it assumes
+// that all of the obligation's data is read from the slowest storage folder of
+// the host. An obligation whose estimate takes up too much of its proof window
+// is at risk of missing its storage proof, so the host refuses revisions that
+// grow an obligation past that point, and raises an alert for obligations that
+// are already past it, for example because a drive has become slower.
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+// readThroughput returns the read throughput of the slowest of the provided
+// storage folders in bytes per second. Folders without measured reads are
+// assumed to have a throughput of defaultReadThroughput.
+func readThroughput(folders []modules.StorageFolderMetadata) uint64 {
+	slowest := uint64(0)
+	for _, sf := range folders {
+		throughput := sf.ReadThroughput
+		if throughput == 0 {
+			throughput = defaultReadThroughput
+		}
+		if slowest == 0 || throughput < slowest {
+			slowest = throughput
+		}
+	}
+	if slowest == 0 {
+		slowest = defaultReadThroughput
+	}
+	return slowest
+}
+
+// maxProofTime returns the longest that the construction of a storage proof
+// may take for a proof window of windowSize blocks.
+func maxProofTime(windowSize types.BlockHeight) time.Duration {
+	window := time.Duration(windowSize) * time.Duration(types.BlockFrequency) * time.Second
+	return time.Duration(float64(window) * proofConstructionWindowFraction)
+}
+
+// proofTimeEstimate returns the estimated time to read 'size' bytes at
+// 'throughput' bytes per second.
+func proofTimeEstimate(size, throughput uint64) time.Duration {
+	return time.Duration(float64(size) / float64(throughput) * float64(time.Second))
+}
+
+// proofConstructionEstimate returns the estimated time to construct the
+// storage proof of an obligation, and the limit for its proof window.
+func proofConstructionEstimate(so storageObligation, throughput uint64) modules.HostProofConstructionEstimate {
+	size := uint64(len(so.SectorRoots)) * modules.SectorSize
+	return modules.HostProofConstructionEstimate{
+		ID:            so.id(),
+		Size:          size,
+		EstimatedTime: proofTimeEstimate(size, throughput),
+		MaxTime:       maxProofTime(so.proofDeadline() - so.expiration()),
+	}
+}
+
+// managedCheckProofConstructionTime returns errProofConstructionTooSlow if the
+// storage proof of the obligation is estimated to take too long to construct.
+func (h *Host) managedCheckProofConstructionTime(so storageObligation) error {
+	estimate := proofConstructionEstimate(so, readThroughput(h.StorageFolders()))
+	if estimate.EstimatedTime > estimate.MaxTime {
+		return errProofConstructionTooSlow
+	}
+	return nil
+}
+
+// obligationsOverProofThreshold returns the estimates of the unresolved
+// obligations whose storage proofs are estimated to take too long to
+// construct. Quarantined obligations are skipped, their storage proofs are not
+// constructed until they are released.
+func (h *Host) obligationsOverProofThreshold(throughput uint64) (over []modules.HostProofConstructionEstimate, err error) {
+	err = h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+			var so storageObligation
+			err := json.Unmarshal(soBytes, &so)
+			if err != nil {
+				return build.ExtendErr("unable to unmarshal storage obligation:", err)
+			}
+			if so.ObligationStatus != obligationUnresolved || so.quarantined() {
+				return nil
+			}
+			if estimate := proofConstructionEstimate(so, throughput); estimate.EstimatedTime > estimate.MaxTime {
+				over = append(over, estimate)
+			}
+			return nil
+		})
+	})
+	return over, err
+}
+
+// updateProofConstructionAlert raises an alert listing the obligations whose
+// storage proofs are estimated to take too long to construct, or clears the
+// alert if there are none. updateProofConstructionAlert is called once per
+// block.
+func (h *Host) updateProofConstructionAlert() {
+	over, err := h.obligationsOverProofThreshold(readThroughput(h.StorageFolders()))
+	if err != nil {
+		h.log.Println("Unable to estimate the storage proof construction times:", err)
+		return
+	}
+	if len(over) == 0 {
+		h.clearAlert(modules.HostAlertCategoryProofConstruction, "")
+		return
+	}
+	ids := make([]string, len(over))
+	for i, estimate := range over {
+		ids[i] = estimate.ID.String()
+	}
+	h.raiseAlert(modules.HostAlert{
+		Severity: modules.HostAlertSeverityWarning,
+		Category: modules.HostAlertCategoryProofConstruction,
+		Message:  fmt.Sprintf("the storage proofs of %v obligations are estimated to take longer than %v%% of their proof window to construct: %v", len(over), proofConstructionWindowFraction*100, strings.Join(ids, ", ")),
+	})
+}
+
+// ProofConstructionMetrics returns the host's estimate of how long it takes to
+// construct storage proofs, and the obligations whose estimate exceeds the
+// limit for their proof window.
+func (h *Host) ProofConstructionMetrics() modules.HostProofConstructionMetrics {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	throughput := readThroughput(h.StorageFolders())
+	maxTime := maxProofTime(h.settings.WindowSize)
+	over, err := h.obligationsOverProofThreshold(throughput)
+	if err != nil {
+		h.log.Println("Unable to estimate the storage proof construction times:", err)
+	}
+	return modules.HostProofConstructionMetrics{
+		ReadThroughput:    throughput,
+		WindowFraction:    proofConstructionWindowFraction,
+		MaxProofTime:      maxTime,
+		MaxObligationSize: uint64(maxTime.Seconds() * float64(throughput)),
+
+		ObligationsOverThreshold: over,
+	}
+}
@@ -0,0 +1,124 @@
+package host
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+// TestProofConstructionEstimate checks the throughput and time limits that
+// are used to estimate the construction time of storage proofs.
+func TestProofConstructionEstimate(t *testing.T) {
+	folders := []modules.StorageFolderMetadata{{ReadThroughput: 300e6}, {ReadThroughput: 100e6}, {ReadThroughput: 200e6}}
+	if tp := readThroughput(folders); tp != 100e6 {
+		t.Error("expected the throughput of the slowest folder, got", tp)
+	}
+	if tp := readThroughput(nil); tp != defaultReadThroughput {
+		t.Error("expected the default throughput without folders, got", tp)
+	}
+	unmeasured := append(folders, modules.StorageFolderMetadata{})
+	if tp := readThroughput(unmeasured); tp != defaultReadThroughput {
+		t.Error("a folder without reads should use the default throughput, got", tp)
+	}
+
+	if est := proofTimeEstimate(250e6, 100e6); est != 2500*time.Millisecond {
+		t.Error("wrong estimate:", est)
+	}
+	window := time.Duration(types.BlockFrequency) * time.Second
+	if limit := maxProofTime(10); limit != time.Duration(float64(10*window)*proofConstructionWindowFraction) {
+		t.Error("wrong limit:", limit)
+	}
+	if limit := maxProofTime(0); limit != 0 {
+		t.Error("expected no time for an empty window, got", limit)
+	}
+}
+
+// TestProofConstructionLimit checks that obligations which are too large to
+// prove in time are refused further growth, reported in the metrics, and
+// raise an alert.
+func TestProofConstructionLimit(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	so, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedLockStorageObligation(so.id())
+	err = ht.host.managedAddStorageObligation(so)
+	ht.host.managedUnlockStorageObligation(so.id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.managedCheckProofConstructionTime(so); err != nil {
+		t.Fatal("small obligation was refused:", err)
+	}
+	if pcm := ht.host.ProofConstructionMetrics(); len(pcm.ObligationsOverThreshold) != 0 || pcm.MaxProofTime != maxProofTime(ht.host.InternalSettings().WindowSize) || pcm.ReadThroughput == 0 {
+		t.Fatal("wrong proof construction metrics:", pcm)
+	}
+
+	// Give the obligation twice as many sector roots as the host can prove in
+	// time. The sectors do not need to exist, because only the size of the
+	// obligation is used for the estimate.
+	numRoots := 2*ht.host.ProofConstructionMetrics().MaxObligationSize/modules.SectorSize + 1
+	so.SectorRoots = make([]crypto.Hash, numRoots)
+	if err := ht.host.managedCheckProofConstructionTime(so); err != errProofConstructionTooSlow {
+		t.Fatal("expected errProofConstructionTooSlow, got", err)
+	}
+	ht.host.mu.Lock()
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		return putStorageObligation(tx, so)
+	})
+	ht.host.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcm := ht.host.ProofConstructionMetrics()
+	if len(pcm.ObligationsOverThreshold) != 1 {
+		t.Fatal("expected one obligation over the threshold, got", len(pcm.ObligationsOverThreshold))
+	}
+	if est := pcm.ObligationsOverThreshold[0]; est.ID != so.id() || est.Size != numRoots*modules.SectorSize || est.EstimatedTime <= est.MaxTime {
+		t.Error("wrong estimate for the obligation:", est)
+	}
+
+	// The alert is raised on the next block, and cleared once the obligation
+	// is small enough again.
+	hasAlert := func() bool {
+		for _, alert := range ht.host.Alerts() {
+			if alert.Category == modules.HostAlertCategoryProofConstruction {
+				return true
+			}
+		}
+		return false
+	}
+	if _, err := ht.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if !hasAlert() {
+		t.Fatal("no alert was raised for the obligation")
+	}
+	so.SectorRoots = nil
+	ht.host.mu.Lock()
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		return putStorageObligation(tx, so)
+	})
+	ht.host.updateProofConstructionAlert()
+	ht.host.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasAlert() {
+		t.Error("alert was not cleared")
+	}
+}
@@ -0,0 +1,250 @@
+package host
+
+// prooftest.go constructs and verifies the storage proof of an obligation
+// without submitting it, so that an operator can find out whether the host is
+// able to prove an obligation long before its proof window opens. The segment
+// that is proven is chosen as if the current block were the trigger block of
+// the contract, so every test proves a different segment. If
+// ProofTestSampleSize is set, the host tests a random sample of its
+// obligations every week and raises an alert for every obligation that fails.
+// The week is counted from the last test, across restarts of the host.
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+	"github.com/NebulousLabs/fastrand"
+)
+
+var (
+	// errProofTestEmptyObligation is returned by the segment selection of a
+	// proof test if the obligation does not store any data, in which case no
+	// storage proof is needed.
+	errProofTestEmptyObligation = errors.New("obligation does not store any data")
+
+	// errProofTestSegmentOutOfRange is returned by the segment selection of
+	// a proof test if the segment is not covered by the sector roots of the
+	// obligation.
+	errProofTestSegmentOutOfRange = errors.New("segment is not covered by the sector roots of the obligation")
+
+	// errProofTestInvalidProof is returned by the verification of a proof
+	// test if the proof does not match the Merkle root of the contract.
+	errProofTestInvalidProof = errors.New("storage proof does not match the Merkle root of the contract")
+)
+
+// proofTestSegment returns the index of the segment that a storage proof of
+// the obligation would prove if triggerID were its trigger block. The index is
+// computed like the consensus set computes it.
+func proofTestSegment(triggerID types.BlockID, so storageObligation) (uint64, error) {
+	if so.fileSize() == 0 {
+		return 0, errProofTestEmptyObligation
+	}
+	seed := crypto.HashAll(triggerID, so.id())
+	numSegments := int64(crypto.CalculateLeaves(so.fileSize()))
+	seedInt := new(big.Int).SetBytes(seed[:])
+	return seedInt.Mod(seedInt, big.NewInt(numSegments)).Uint64(), nil
+}
+
+// managedTestProof runs the stages of a proof test for so, stopping at the
+// first stage that fails. The caller must hold the lock of the storage
+// obligation.
+func (h *Host) managedTestProof(so storageObligation) modules.ProofTestResult {
+	result := modules.ProofTestResult{ContractID: so.id()}
+	fail := func(stage modules.ProofTestStage, err error) modules.ProofTestResult {
+		result.FailedStage = stage
+		result.Error = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	segmentIndex, err := proofTestSegment(h.cs.CurrentBlock().ID(), so)
+	result.SegmentIndex = segmentIndex
+	result.SectorIndex = segmentIndex / (modules.SectorSize / crypto.SegmentSize)
+	if err == nil && result.SectorIndex >= uint64(len(so.SectorRoots)) {
+		err = errProofTestSegmentOutOfRange
+	}
+	result.SegmentSelectionTime = time.Since(start)
+	if err != nil {
+		return fail(modules.ProofTestStageSegmentSelection, err)
+	}
+
+	start = time.Now()
+	sectorBytes, err := h.ReadSector(so.SectorRoots[result.SectorIndex])
+	result.SectorReadTime = time.Since(start)
+	if err != nil {
+		return fail(modules.ProofTestStageSectorRead, err)
+	}
+
+	// Build the proof the same way as threadedHandleActionItem.
+	start = time.Now()
+	sectorSegment := segmentIndex % (modules.SectorSize / crypto.SegmentSize)
+	base, cachedHashSet := crypto.MerkleProof(sectorBytes, sectorSegment)
+	ct := newSectorRootsTree()
+	ct.SetIndex(segmentIndex)
+	for _, root := range so.SectorRoots {
+		ct.Push(root)
+	}
+	sp := types.StorageProof{
+		ParentID: so.id(),
+		HashSet:  ct.Prove(base, cachedHashSet),
+	}
+	copy(sp.Segment[:], base)
+	result.ProofConstructionTime = time.Since(start)
+
+	start = time.Now()
+	valid := crypto.VerifySegment(sp.Segment[:], sp.HashSet, crypto.CalculateLeaves(so.fileSize()), segmentIndex, so.merkleRoot())
+	result.VerificationTime = time.Since(start)
+	if !valid {
+		return fail(modules.ProofTestStageVerification, errProofTestInvalidProof)
+	}
+	result.Success = true
+	return result
+}
+
+// TestProof constructs the storage proof of the obligation of the file
+// contract id as if the current block were the trigger block of the contract,
+// and verifies it against the Merkle root of the contract. The proof is not
+// submitted. An error is only returned if the test could not be run, a proof
+// that cannot be constructed or verified is reported in the result.
+func (h *Host) TestProof(id types.FileContractID) (modules.ProofTestResult, error) {
+	if err := h.tg.Add(); err != nil {
+		return modules.ProofTestResult{}, err
+	}
+	defer h.tg.Done()
+
+	err := h.managedTryLockStorageObligation(id)
+	if err != nil {
+		return modules.ProofTestResult{}, err
+	}
+	defer h.managedUnlockStorageObligation(id)
+	var so storageObligation
+	h.mu.RLock()
+	err = h.db.View(func(tx *bolt.Tx) error {
+		so, err = getStorageObligation(tx, id)
+		return err
+	})
+	h.mu.RUnlock()
+	if err != nil {
+		return modules.ProofTestResult{}, err
+	}
+	return h.managedTestProof(so), nil
+}
+
+// proofTestAlert returns the alert that is raised for an obligation that
+// failed a proof test.
+func proofTestAlert(result modules.ProofTestResult) modules.HostAlert {
+	return modules.HostAlert{
+		Severity: modules.HostAlertSeverityWarning,
+		Category: modules.HostAlertCategoryProofTest,
+		Message:  fmt.Sprintf("the storage proof of segment %v failed at the %v stage: %v", result.SegmentIndex, result.FailedStage, result.Error),
+		Cause:    result.ContractID.String(),
+	}
+}
+
+// managedTestProofSample tests the storage proofs of up to
+// ProofTestSampleSize random unresolved obligations, raising an alert for
+// every obligation that fails and clearing the alert of every obligation that
+// passes. Quarantined obligations and obligations that are in use are
+// skipped.
+func (h *Host) managedTestProofSample() {
+	h.mu.RLock()
+	sampleSize := h.settings.ProofTestSampleSize
+	h.mu.RUnlock()
+	if sampleSize == 0 {
+		return
+	}
+	soids, err := h.managedStorageObligationIDs()
+	if err != nil {
+		h.log.Println("Unable to list storage obligations for the proof test:", err)
+		return
+	}
+
+	var tested uint64
+	for _, i := range fastrand.Perm(len(soids)) {
+		if tested >= sampleSize {
+			return
+		}
+		soid := soids[i]
+		if h.managedTryLockStorageObligation(soid) != nil {
+			continue
+		}
+		var so storageObligation
+		h.mu.RLock()
+		err := h.db.View(func(tx *bolt.Tx) error {
+			so, err = getStorageObligation(tx, soid)
+			return err
+		})
+		h.mu.RUnlock()
+		if err != nil || so.ObligationStatus != obligationUnresolved || so.quarantined() || len(so.SectorRoots) == 0 {
+			h.managedUnlockStorageObligation(soid)
+			continue
+		}
+		result := h.managedTestProof(so)
+		h.managedUnlockStorageObligation(soid)
+		tested++
+
+		h.mu.Lock()
+		if result.Success {
+			h.clearAlert(modules.HostAlertCategoryProofTest, soid.String())
+		} else {
+			h.raiseAlert(proofTestAlert(result))
+		}
+		h.mu.Unlock()
+
+		select {
+		case <-h.tg.StopChan():
+			return
+		default:
+		}
+	}
+}
+
+// proofTestWait returns how long to wait for the next proof test, if the last
+// proof test ran at last.
+func proofTestWait(last time.Time) time.Duration {
+	wait := proofTestInterval - time.Since(last)
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// threadedTestProofs tests the storage proofs of a sample of the host's
+// obligations every proofTestInterval. The time of the last test is persisted,
+// so that restarting the host does not postpone the next test. A host that has
+// never run a test waits a full interval.
+func (h *Host) threadedTestProofs(closeChan chan struct{}) {
+	defer close(closeChan)
+	h.mu.Lock()
+	if h.lastProofTest.IsZero() {
+		h.lastProofTest = time.Now()
+		if err := h.saveSync(); err != nil {
+			h.log.Println("Unable to save the time of the last proof test:", err)
+		}
+	}
+	last := h.lastProofTest
+	h.mu.Unlock()
+	for {
+		select {
+		case <-h.tg.StopChan():
+			return
+		case <-time.After(proofTestWait(last)):
+		}
+		h.managedTestProofSample()
+
+		h.mu.Lock()
+		h.lastProofTest = time.Now()
+		last = h.lastProofTest
+		err := h.saveSync()
+		h.mu.Unlock()
+		if err != nil {
+			h.log.Println("Unable to save the time of the last proof test:", err)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package host
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+// TestTestProof checks that the proof test succeeds for a healthy obligation,
+// reports the stage that fails for obligations that cannot be proven, and
+// that the weekly sample raises an alert for every failed obligation.
+func TestTestProof(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	var obligations []storageObligation
+	for i := 0; i < 3; i++ {
+		so, _, err := ht.addSectorObligation()
+		if err != nil {
+			t.Fatal(err)
+		}
+		obligations = append(obligations, so)
+	}
+	healthy, missing, wrongRoot := obligations[0], obligations[1], obligations[2]
+	err = ht.host.RemoveSector(missing.SectorRoots[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongRoot.RevisionTransactionSet[0].FileContractRevisions[0].NewFileMerkleRoot = crypto.Hash{1}
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		return putStorageObligation(tx, wrongRoot)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ht.host.TestProof(healthy.id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || result.FailedStage != "" || result.ContractID != healthy.id() || result.SectorIndex != 0 || result.SegmentIndex >= modules.SectorSize/crypto.SegmentSize {
+		t.Fatalf("wrong result of a healthy obligation: %+v", result)
+	}
+	result, err = ht.host.TestProof(missing.id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success || result.FailedStage != modules.ProofTestStageSectorRead || result.Error == "" || result.ProofConstructionTime != 0 {
+		t.Fatalf("wrong result of an obligation with a missing sector: %+v", result)
+	}
+	result, err = ht.host.TestProof(wrongRoot.id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success || result.FailedStage != modules.ProofTestStageVerification || result.Error != errProofTestInvalidProof.Error() {
+		t.Fatalf("wrong result of an obligation with the wrong Merkle root: %+v", result)
+	}
+	if _, err := ht.host.TestProof(types.FileContractID{1}); err != errNoStorageObligation {
+		t.Fatal("expected errNoStorageObligation, got", err)
+	}
+
+	// proofTestAlerts returns the causes of the active proof test alerts.
+	proofTestAlerts := func() map[string]bool {
+		causes := make(map[string]bool)
+		for _, alert := range ht.host.Alerts() {
+			if alert.Category == modules.HostAlertCategoryProofTest {
+				causes[alert.Cause] = true
+			}
+		}
+		return causes
+	}
+
+	// The weekly proof test is disabled by default.
+	ht.host.managedTestProofSample()
+	if alerts := proofTestAlerts(); len(alerts) != 0 {
+		t.Fatal("proof test ran while it was disabled:", alerts)
+	}
+	ht.host.mu.Lock()
+	ht.host.settings.ProofTestSampleSize = 3
+	ht.host.mu.Unlock()
+	ht.host.managedTestProofSample()
+	if alerts := proofTestAlerts(); len(alerts) != 2 || !alerts[missing.id().String()] || !alerts[wrongRoot.id().String()] {
+		t.Fatal("wrong proof test alerts:", alerts)
+	}
+}
+
+// TestProofTestSchedule checks that the time of the last proof test survives
+// a restart of the host, and that the next test is scheduled from it.
+func TestProofTestSchedule(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	if wait := proofTestWait(time.Now()); wait <= proofTestInterval/2 || wait > proofTestInterval {
+		t.Fatal("a test that just ran should wait a full interval, got", wait)
+	}
+	if wait := proofTestWait(time.Now().Add(-proofTestInterval / 2)); wait > proofTestInterval/2 {
+		t.Fatal("the wait should be counted from the last test, got", wait)
+	}
+	if wait := proofTestWait(time.Now().Add(-2 * proofTestInterval)); wait != 0 {
+		t.Fatal("an overdue test should run right away, got", wait)
+	}
+
+	// A new host starts counting from its first start.
+	ht.host.mu.RLock()
+	first := ht.host.lastProofTest
+	ht.host.mu.RUnlock()
+	if first.IsZero() {
+		t.Fatal("the time of the first proof test was not set")
+	}
+
+	// Restarting the host keeps the time of the last test.
+	last := time.Now().Add(-time.Hour).Round(0)
+	ht.host.mu.Lock()
+	ht.host.lastProofTest = last
+	err = ht.host.saveSync()
+	ht.host.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ht.host, err = New(ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.mu.RLock()
+	loaded := ht.host.lastProofTest
+	ht.host.mu.RUnlock()
+	if !loaded.Equal(last) {
+		t.Fatalf("expected the last proof test at %v, got %v", last, loaded)
+	}
+}
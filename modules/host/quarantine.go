@@ -0,0 +1,414 @@
+package host
+
+// quarantine.go implements the quarantine of storage obligations that fail an
+// invariant check. A storage obligation can end up in a state that the rest of
+// the host does not expect, for example after a bug or disk corruption, and
+// processing such an obligation either panics or carries the broken state
+// until the storage proof is due. Instead, the obligation is checked before
+// its action items are handled and before renters can use it, and if it
+// violates an invariant it is quarantined: the violation is recorded in the
+// obligation, an alert is raised, and the obligation is skipped by the host
+// until the operator repairs or resolves it. All other obligations are
+// unaffected.
+//
+// The host cannot fetch the sector roots of an obligation from the renter,
+// because the host protocol has no RPC that the host can call. Obligations
+// with sector roots that do not match their revision can only be repaired from
+// the revisions that the host has stored, or resolved by the operator.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// errNoRevisionMatchesRoots is returned when a quarantined obligation is
+	// rebuilt, but none of the revisions that the host has stored for it
+	// matches its sector roots.
+	errNoRevisionMatchesRoots = errors.New("no stored revision of the storage obligation matches its sector roots")
+
+	// errObligationNotQuarantined is returned when a quarantine action is
+	// applied to a storage obligation that is not quarantined.
+	errObligationNotQuarantined = errors.New("storage obligation is not quarantined")
+
+	// errObligationNotRevised is returned when a renter attempts to use a
+	// storage obligation that the host has no revision for.
+	errObligationNotRevised = errors.New("storage obligation has no revision")
+
+	// errObligationQuarantined is returned when a renter attempts to use a
+	// storage obligation that is quarantined.
+	errObligationQuarantined = errors.New("storage obligation is quarantined by the host")
+
+	// errUnknownQuarantineAction is returned when an unknown action is applied
+	// to a quarantined storage obligation.
+	errUnknownQuarantineAction = errors.New("unknown quarantine action")
+)
+
+// insaneOutputCounts returns whether a file contract or revision with the
+// provided numbers of valid and missed proof outputs is malformed. Contracts
+// formed by the host have a void output in the missed proof outputs, which
+// contracts formed by older hosts do not have.
+func insaneOutputCounts(valid, missed int) bool {
+	return valid != 2 || (missed != 2 && missed != 3)
+}
+
+// quarantined returns whether the obligation is quarantined.
+func (so storageObligation) quarantined() bool {
+	return so.QuarantineViolation != ""
+}
+
+// checkRevision checks that rev is a well formed revision of the file
+// contract soid, and that it is a revision that the host would have accepted
+// for the file contract fc.
+func checkRevision(soid types.FileContractID, fc types.FileContract, rev types.FileContractRevision) (modules.HostObligationViolation, error) {
+	if insaneOutputCounts(len(rev.NewValidProofOutputs), len(rev.NewMissedProofOutputs)) {
+		return modules.HostObligationViolationRevisionSet, errInsaneFileContractRevisionOutputCounts
+	}
+	if rev.ParentID != soid {
+		return modules.HostObligationViolationRevisionTransition, fmt.Errorf("revision is for file contract %v", rev.ParentID)
+	}
+	if rev.NewRevisionNumber <= fc.RevisionNumber {
+		return modules.HostObligationViolationRevisionTransition, fmt.Errorf("revision number %v does not follow revision number %v of the file contract", rev.NewRevisionNumber, fc.RevisionNumber)
+	}
+	if rev.NewWindowStart != fc.WindowStart || rev.NewWindowEnd != fc.WindowEnd {
+		return modules.HostObligationViolationRevisionTransition, errors.New("revision changes the storage proof window of the file contract")
+	}
+	return "", nil
+}
+
+// checkSectorRoots checks that roots match the file size and the Merkle root
+// of rev.
+func checkSectorRoots(roots []crypto.Hash, rev types.FileContractRevision) (modules.HostObligationViolation, error) {
+	if size := uint64(len(roots)) * modules.SectorSize; size != rev.NewFileSize {
+		return modules.HostObligationViolationSectorRoots, fmt.Errorf("%v sector roots cover %v bytes, but the revision has a file size of %v bytes", len(roots), size, rev.NewFileSize)
+	}
+	if len(roots) > 0 && sectorRootsMerkleRoot(roots) != rev.NewFileMerkleRoot {
+		return modules.HostObligationViolationSectorRoots, errors.New("the Merkle root of the sector roots does not match the Merkle root of the revision")
+	}
+	return "", nil
+}
+
+// checkInvariants checks the invariants of an unresolved storage obligation
+// that is stored under soid, returning the violated invariant and a
+// description of the violation.
+func (so storageObligation) checkInvariants(soid types.FileContractID) (modules.HostObligationViolation, error) {
+	// The final transaction of the origin transaction set should have one file
+	// contract, with two valid and two or three missed proof outputs.
+	if len(so.OriginTransactionSet) == 0 {
+		return modules.HostObligationViolationOriginSet, errInsaneOriginSetSize
+	}
+	originTxn := so.OriginTransactionSet[len(so.OriginTransactionSet)-1]
+	if len(originTxn.FileContracts) != 1 {
+		return modules.HostObligationViolationOriginSet, errInsaneOriginSetFileContract
+	}
+	fc := originTxn.FileContracts[0]
+	if insaneOutputCounts(len(fc.ValidProofOutputs), len(fc.MissedProofOutputs)) {
+		return modules.HostObligationViolationOriginSet, errInsaneFileContractOutputCounts
+	}
+	if id := originTxn.FileContractID(0); id != soid {
+		return modules.HostObligationViolationOriginSet, fmt.Errorf("origin transaction set creates file contract %v", id)
+	}
+
+	// An obligation that has not been revised has nothing to check the sector
+	// roots against. Otherwise, the final transaction of the revision
+	// transaction set should have one revision of the file contract.
+	if len(so.RevisionTransactionSet) == 0 {
+		return "", nil
+	}
+	revisionTxn := so.RevisionTransactionSet[len(so.RevisionTransactionSet)-1]
+	if len(revisionTxn.FileContractRevisions) != 1 {
+		return modules.HostObligationViolationRevisionSet, errInsaneRevisionSetRevisionCount
+	}
+	rev := revisionTxn.FileContractRevisions[0]
+	if violation, err := checkRevision(soid, fc, rev); violation != "" {
+		return violation, err
+	}
+	return checkSectorRoots(so.SectorRoots, rev)
+}
+
+// obligationViolation checks the invariants of an unresolved storage
+// obligation, including that all of its sectors are stored by the host.
+func (h *Host) obligationViolation(soid types.FileContractID, so storageObligation) (modules.HostObligationViolation, error) {
+	if violation, err := so.checkInvariants(soid); violation != "" {
+		return violation, err
+	}
+	var missing []crypto.Hash
+	for _, root := range so.SectorRoots {
+		if _, err := h.SectorLocation(root); err != nil {
+			missing = append(missing, root)
+		}
+	}
+	if len(missing) > 0 {
+		return modules.HostObligationViolationMissingSectors, fmt.Errorf("%v of %v sectors are missing from storage, including %v", len(missing), len(so.SectorRoots), missing[0])
+	}
+	return "", nil
+}
+
+// quarantineAlert returns the alert for a storage obligation that is
+// quarantined because of violation.
+func quarantineAlert(soid types.FileContractID, violation modules.HostObligationViolation) modules.HostAlert {
+	return modules.HostAlert{
+		Severity: modules.HostAlertSeverityCritical,
+		Category: modules.HostAlertCategoryQuarantine,
+		Message:  "a storage obligation was quarantined: " + string(violation),
+		Cause:    soid.String(),
+	}
+}
+
+// quarantineObligation moves a storage obligation into quarantine. The caller
+// must hold the host lock and the lock of the storage obligation.
+func (h *Host) quarantineObligation(soid types.FileContractID, so storageObligation, violation modules.HostObligationViolation, violationErr error) error {
+	so.QuarantineViolation = violation
+	so.QuarantineDetail = violationErr.Error()
+	so.QuarantineHeight = h.blockHeight
+	err := h.db.Update(func(tx *bolt.Tx) error {
+		return putStorageObligationWithID(tx, soid, so)
+	})
+	if err != nil {
+		return err
+	}
+	h.log.Printf("Quarantined storage obligation %v: %v: %v\n", soid, violation, violationErr)
+	h.raiseAlert(quarantineAlert(soid, violation))
+	return nil
+}
+
+// releaseObligation moves a storage obligation out of quarantine. The action
+// items of the obligation were skipped while it was quarantined, so another
+// action item is queued to resume its processing. The caller must hold the
+// host lock and the lock of the storage obligation.
+func (h *Host) releaseObligation(soid types.FileContractID, so storageObligation) error {
+	so.QuarantineViolation = ""
+	so.QuarantineDetail = ""
+	so.QuarantineHeight = 0
+	err := h.db.Update(func(tx *bolt.Tx) error {
+		return putStorageObligationWithID(tx, soid, so)
+	})
+	if err != nil {
+		return err
+	}
+	h.log.Printf("Released storage obligation %v from quarantine\n", soid)
+	h.clearAlert(modules.HostAlertCategoryQuarantine, soid.String())
+	return h.queueActionItem(h.blockHeight+1, soid)
+}
+
+// managedCheckObligation checks an unresolved storage obligation before it is
+// processed, quarantining the obligation if it violates an invariant.
+// Checking that the sectors of the obligation are stored requires a lookup
+// per sector, so it is only done if checkSectors is set. The caller must hold
+// the lock of the storage obligation. managedCheckObligation returns false if
+// the obligation is quarantined.
+func (h *Host) managedCheckObligation(soid types.FileContractID, so storageObligation, checkSectors bool) bool {
+	if so.quarantined() {
+		return false
+	}
+	var violation modules.HostObligationViolation
+	var violationErr error
+	if checkSectors {
+		violation, violationErr = h.obligationViolation(soid, so)
+	} else {
+		violation, violationErr = so.checkInvariants(soid)
+	}
+	if violation == "" {
+		return true
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	err := h.quarantineObligation(soid, so, violation, violationErr)
+	if err != nil {
+		h.log.Println("Unable to quarantine storage obligation:", err)
+	}
+	return false
+}
+
+// threadedCheckObligations checks the invariants of every unresolved storage
+// obligation when the host starts, so that broken obligations are quarantined
+// before their storage proofs are due. Alerts are not persisted, so they are
+// raised again for the obligations that were already quarantined. Obligations
+// that are in use are skipped, they are checked when they are used.
+func (h *Host) threadedCheckObligations() {
+	err := h.tg.Add()
+	if err != nil {
+		return
+	}
+	defer h.tg.Done()
+
+	soids, err := h.managedStorageObligationIDs()
+	if err != nil {
+		h.log.Println("Unable to list storage obligations for the invariant check:", err)
+		return
+	}
+	for _, soid := range soids {
+		if h.managedTryLockStorageObligation(soid) != nil {
+			continue
+		}
+		var so storageObligation
+		h.mu.RLock()
+		err := h.db.View(func(tx *bolt.Tx) error {
+			so, err = getStorageObligation(tx, soid)
+			return err
+		})
+		h.mu.RUnlock()
+		if err == nil && so.ObligationStatus == obligationUnresolved && so.quarantined() {
+			h.managedRaiseAlert(quarantineAlert(soid, so.QuarantineViolation))
+		} else if err == nil && so.ObligationStatus == obligationUnresolved {
+			h.managedCheckObligation(soid, so, true)
+		}
+		h.managedUnlockStorageObligation(soid)
+
+		select {
+		case <-h.tg.StopChan():
+			return
+		default:
+		}
+	}
+}
+
+// rebuildRevisionSet rebuilds the revision transaction set of a storage
+// obligation from the most recent well formed revision in the set that
+// matches the sector roots of the obligation, dropping the transactions that
+// follow it. The host only stores the most recent revision and its parents,
+// so the revisions that are available for the rebuild are limited.
+func (so *storageObligation) rebuildRevisionSet(soid types.FileContractID) error {
+	if violation, err := so.checkInvariants(soid); violation == modules.HostObligationViolationOriginSet {
+		return build.ExtendErr("the origin transaction set of the storage obligation cannot be rebuilt", err)
+	}
+	fc := so.OriginTransactionSet[len(so.OriginTransactionSet)-1].FileContracts[0]
+	for i := len(so.RevisionTransactionSet) - 1; i >= 0; i-- {
+		txn := so.RevisionTransactionSet[i]
+		if len(txn.FileContractRevisions) != 1 {
+			continue
+		}
+		rev := txn.FileContractRevisions[0]
+		if violation, _ := checkRevision(soid, fc, rev); violation != "" {
+			continue
+		}
+		if violation, _ := checkSectorRoots(so.SectorRoots, rev); violation != "" {
+			continue
+		}
+		so.RevisionTransactionSet = so.RevisionTransactionSet[:i+1]
+		return nil
+	}
+	return errNoRevisionMatchesRoots
+}
+
+// QuarantinedObligations returns the storage obligations that the host has
+// quarantined because they failed an invariant check.
+func (h *Host) QuarantinedObligations() []modules.HostQuarantinedObligation {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	quarantined := []modules.HostQuarantinedObligation{}
+	err := h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).ForEach(func(idBytes, soBytes []byte) error {
+			var so storageObligation
+			err := json.Unmarshal(soBytes, &so)
+			if err != nil {
+				return build.ExtendErr("unable to unmarshal storage obligation:", err)
+			}
+			if !so.quarantined() || so.ObligationStatus != obligationUnresolved {
+				return nil
+			}
+			qo := modules.HostQuarantinedObligation{
+				Violation:        so.QuarantineViolation,
+				Detail:           so.QuarantineDetail,
+				QuarantineHeight: so.QuarantineHeight,
+				Sectors:          uint64(len(so.SectorRoots)),
+			}
+			copy(qo.ID[:], idBytes)
+			so.SectorRoots = nil
+			qo.Metadata, err = json.Marshal(so)
+			if err != nil {
+				return err
+			}
+			quarantined = append(quarantined, qo)
+			return nil
+		})
+	})
+	if err != nil {
+		h.log.Println(build.ExtendErr("database failed to provide quarantined storage obligations:", err))
+	}
+	return quarantined
+}
+
+// ResolveQuarantinedObligation applies a repair or resolution action to a
+// quarantined storage obligation. Repaired obligations are only released from
+// quarantine if they pass the invariant checks, otherwise the new violation is
+// recorded and returned.
+func (h *Host) ResolveQuarantinedObligation(soid types.FileContractID, action modules.HostQuarantineAction, reason string) error {
+	switch action {
+	case modules.HostQuarantineActionFail:
+		if reason == "" {
+			return errNoExpirationReason
+		}
+	case modules.HostQuarantineActionRebuild, modules.HostQuarantineActionRecheck, modules.HostQuarantineActionRelease:
+	default:
+		return errUnknownQuarantineAction
+	}
+	err := h.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer h.tg.Done()
+
+	err = h.managedTryLockStorageObligation(soid)
+	if err != nil {
+		return err
+	}
+	defer h.managedUnlockStorageObligation(soid)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var so storageObligation
+	err = h.db.View(func(tx *bolt.Tx) error {
+		so, err = getStorageObligation(tx, soid)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if so.ObligationStatus != obligationUnresolved {
+		return errObligationResolved
+	}
+	if !so.quarantined() {
+		return errObligationNotQuarantined
+	}
+
+	switch action {
+	case modules.HostQuarantineActionFail:
+		if violation, err := so.checkInvariants(soid); violation == modules.HostObligationViolationOriginSet {
+			return build.ExtendErr("a storage obligation with a malformed origin transaction set cannot be failed", err)
+		}
+		h.log.Printf("Failing quarantined storage obligation %v at the request of the operator: %v\n", soid, reason)
+		h.clearAlert(modules.HostAlertCategoryQuarantine, soid.String())
+		so.QuarantineViolation = ""
+		so.QuarantineDetail = ""
+		so.ExpirationReason = reason
+		return h.removeStorageObligation(so, obligationFailed)
+	case modules.HostQuarantineActionRelease:
+		return h.releaseObligation(soid, so)
+	case modules.HostQuarantineActionRebuild:
+		err = so.rebuildRevisionSet(soid)
+		if err != nil {
+			return err
+		}
+	}
+
+	violation, violationErr := h.obligationViolation(soid, so)
+	if violation != "" {
+		err = h.quarantineObligation(soid, so, violation, violationErr)
+		if err != nil {
+			return err
+		}
+		return build.ExtendErr("storage obligation is still quarantined: "+string(violation), violationErr)
+	}
+	return h.releaseObligation(soid, so)
+}
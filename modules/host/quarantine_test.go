@@ -0,0 +1,397 @@
+package host
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// TestCheckInvariants probes the invariant checks of storage obligations with
+// every known kind of corruption.
+func TestCheckInvariants(t *testing.T) {
+	fc := types.FileContract{
+		WindowStart:        10,
+		WindowEnd:          20,
+		ValidProofOutputs:  make([]types.SiacoinOutput, 2),
+		MissedProofOutputs: make([]types.SiacoinOutput, 2),
+	}
+	originTxn := types.Transaction{FileContracts: []types.FileContract{fc}}
+	soid := originTxn.FileContractID(0)
+	roots := []crypto.Hash{{1}, {2}}
+	rev := types.FileContractRevision{
+		ParentID:              soid,
+		NewRevisionNumber:     1,
+		NewFileSize:           2 * modules.SectorSize,
+		NewFileMerkleRoot:     sectorRootsMerkleRoot(roots),
+		NewWindowStart:        fc.WindowStart,
+		NewWindowEnd:          fc.WindowEnd,
+		NewValidProofOutputs:  make([]types.SiacoinOutput, 2),
+		NewMissedProofOutputs: make([]types.SiacoinOutput, 2),
+	}
+	// newSO returns a storage obligation with the provided revision.
+	newSO := func(rev types.FileContractRevision) storageObligation {
+		return storageObligation{
+			OriginTransactionSet:   []types.Transaction{originTxn},
+			RevisionTransactionSet: []types.Transaction{{FileContractRevisions: []types.FileContractRevision{rev}}},
+			SectorRoots:            roots,
+		}
+	}
+
+	wrongParent := rev
+	wrongParent.ParentID = types.FileContractID{1}
+	oldRevision := rev
+	oldRevision.NewRevisionNumber = 0
+	movedWindow := rev
+	movedWindow.NewWindowEnd++
+	wrongOutputs := rev
+	wrongOutputs.NewValidProofOutputs = nil
+	voidOutput := rev
+	voidOutput.NewMissedProofOutputs = make([]types.SiacoinOutput, 3)
+	tooManyOutputs := rev
+	tooManyOutputs.NewMissedProofOutputs = make([]types.SiacoinOutput, 4)
+	wrongSize := rev
+	wrongSize.NewFileSize = modules.SectorSize
+	wrongRoot := rev
+	wrongRoot.NewFileMerkleRoot = crypto.Hash{3}
+
+	unrevised := newSO(rev)
+	unrevised.RevisionTransactionSet = nil
+	noOrigin := newSO(rev)
+	noOrigin.OriginTransactionSet = nil
+	twoContracts := newSO(rev)
+	twoContracts.OriginTransactionSet = []types.Transaction{{FileContracts: []types.FileContract{fc, fc}}}
+	noRevision := newSO(rev)
+	noRevision.RevisionTransactionSet = append(noRevision.RevisionTransactionSet, types.Transaction{})
+
+	tests := []struct {
+		name      string
+		so        storageObligation
+		violation modules.HostObligationViolation
+	}{
+		{"valid", newSO(rev), ""},
+		{"unrevised", unrevised, ""},
+		{"no origin", noOrigin, modules.HostObligationViolationOriginSet},
+		{"two contracts", twoContracts, modules.HostObligationViolationOriginSet},
+		{"no revision", noRevision, modules.HostObligationViolationRevisionSet},
+		{"wrong outputs", newSO(wrongOutputs), modules.HostObligationViolationRevisionSet},
+		{"void output", newSO(voidOutput), ""},
+		{"too many outputs", newSO(tooManyOutputs), modules.HostObligationViolationRevisionSet},
+		{"wrong parent", newSO(wrongParent), modules.HostObligationViolationRevisionTransition},
+		{"old revision", newSO(oldRevision), modules.HostObligationViolationRevisionTransition},
+		{"moved window", newSO(movedWindow), modules.HostObligationViolationRevisionTransition},
+		{"wrong size", newSO(wrongSize), modules.HostObligationViolationSectorRoots},
+		{"wrong root", newSO(wrongRoot), modules.HostObligationViolationSectorRoots},
+	}
+	for _, test := range tests {
+		violation, err := test.so.checkInvariants(soid)
+		if violation != test.violation {
+			t.Errorf("%v: expected violation %q, got %q (%v)", test.name, test.violation, violation, err)
+		}
+		if (violation == "") != (err == nil) {
+			t.Errorf("%v: violation %q does not match error %v", test.name, violation, err)
+		}
+	}
+
+	// A storage obligation stored under a different id is quarantined.
+	if violation, _ := newSO(rev).checkInvariants(types.FileContractID{1}); violation != modules.HostObligationViolationOriginSet {
+		t.Error("obligation with the wrong id was not flagged:", violation)
+	}
+
+	// Rebuilding drops the revisions that do not match the sector roots.
+	so := newSO(rev)
+	so.RevisionTransactionSet = append(so.RevisionTransactionSet, newSO(wrongRoot).RevisionTransactionSet...)
+	if err := so.rebuildRevisionSet(soid); err != nil {
+		t.Fatal(err)
+	}
+	if len(so.RevisionTransactionSet) != 1 {
+		t.Fatal("revision set was not truncated:", len(so.RevisionTransactionSet))
+	}
+	if violation, err := so.checkInvariants(soid); violation != "" {
+		t.Fatal("rebuilt obligation violates an invariant:", err)
+	}
+	so = newSO(wrongRoot)
+	if err := so.rebuildRevisionSet(soid); err != errNoRevisionMatchesRoots {
+		t.Fatal("expected errNoRevisionMatchesRoots, got", err)
+	}
+}
+
+// addSectorObligation adds a storage obligation with one sector and a valid
+// revision to the host, returning the obligation and the data of its sector.
+func (ht *hostTester) addSectorObligation() (storageObligation, []byte, error) {
+	so, err := ht.newTesterStorageObligation()
+	if err != nil {
+		return storageObligation{}, nil, err
+	}
+	ht.host.managedLockStorageObligation(so.id())
+	defer ht.host.managedUnlockStorageObligation(so.id())
+	err = ht.host.managedAddStorageObligation(so)
+	if err != nil {
+		return storageObligation{}, nil, err
+	}
+
+	sectorRoot, sectorData := randSector()
+	so.SectorRoots = []crypto.Hash{sectorRoot}
+	validPayouts, missedPayouts := so.payouts()
+	so.RevisionTransactionSet = []types.Transaction{{
+		FileContractRevisions: []types.FileContractRevision{{
+			ParentID:          so.id(),
+			UnlockConditions:  types.UnlockConditions{},
+			NewRevisionNumber: 1,
+
+			NewFileSize:           uint64(len(sectorData)),
+			NewFileMerkleRoot:     sectorRoot,
+			NewWindowStart:        so.expiration(),
+			NewWindowEnd:          so.proofDeadline(),
+			NewValidProofOutputs:  validPayouts,
+			NewMissedProofOutputs: missedPayouts,
+			NewUnlockHash:         types.UnlockConditions{}.UnlockHash(),
+		}},
+	}}
+	ht.host.mu.Lock()
+	err = ht.host.modifyStorageObligation(so, nil, []crypto.Hash{sectorRoot}, [][]byte{sectorData})
+	ht.host.mu.Unlock()
+	return so, sectorData, err
+}
+
+// TestQuarantineObligations checks that storage obligations with every known
+// kind of corruption are quarantined and listed, that the other obligations
+// of the host are unaffected, and that the quarantine actions repair or
+// resolve the obligations.
+func TestQuarantineObligations(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	var obligations []storageObligation
+	var missingData []byte
+	for i := 0; i < 6; i++ {
+		so, data, err := ht.addSectorObligation()
+		if err != nil {
+			t.Fatal(err)
+		}
+		obligations = append(obligations, so)
+		if i == 1 {
+			missingData = data
+		}
+	}
+	healthy, missing, roots, transition, revisionSet, origin := obligations[0], obligations[1], obligations[2], obligations[3], obligations[4], obligations[5]
+	originID := origin.id()
+
+	// putObligation puts a corrupted obligation into the database.
+	putObligation := func(soid types.FileContractID, so storageObligation) {
+		err := ht.host.db.Update(func(tx *bolt.Tx) error {
+			return putStorageObligationWithID(tx, soid, so)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Corrupt the obligations.
+	err = ht.host.RemoveSector(missing.SectorRoots[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	badRevision := roots.RevisionTransactionSet[0]
+	badRevision.FileContractRevisions = []types.FileContractRevision{badRevision.FileContractRevisions[0]}
+	badRevision.FileContractRevisions[0].NewRevisionNumber++
+	badRevision.FileContractRevisions[0].NewFileMerkleRoot = crypto.Hash{1}
+	roots.RevisionTransactionSet = append(roots.RevisionTransactionSet, badRevision)
+	putObligation(roots.id(), roots)
+	transition.RevisionTransactionSet[0].FileContractRevisions[0].ParentID = healthy.id()
+	putObligation(transition.id(), transition)
+	revisionSet.RevisionTransactionSet = append(revisionSet.RevisionTransactionSet, types.Transaction{})
+	putObligation(revisionSet.id(), revisionSet)
+	origin.OriginTransactionSet = []types.Transaction{{}}
+	putObligation(originID, origin)
+
+	for _, soid := range []types.FileContractID{healthy.id(), missing.id(), roots.id(), transition.id(), revisionSet.id(), originID} {
+		ht.host.threadedHandleActionItem(soid)
+	}
+
+	// checkQuarantine checks that exactly the expected obligations are
+	// quarantined, and that there is an alert for each of them.
+	checkQuarantine := func(expected map[types.FileContractID]modules.HostObligationViolation) {
+		t.Helper()
+		quarantined := ht.host.QuarantinedObligations()
+		if len(quarantined) != len(expected) {
+			t.Fatalf("expected %v quarantined obligations, got %v", len(expected), len(quarantined))
+		}
+		for _, qo := range quarantined {
+			if qo.Violation != expected[qo.ID] {
+				t.Errorf("obligation %v has violation %q, expected %q: %v", qo.ID, qo.Violation, expected[qo.ID], qo.Detail)
+			}
+			if qo.Detail == "" || len(qo.Metadata) == 0 || qo.QuarantineHeight == 0 {
+				t.Errorf("quarantined obligation %v is missing information: %+v", qo.ID, qo)
+			}
+		}
+		var alerts int
+		for _, alert := range ht.host.Alerts() {
+			if alert.Category != modules.HostAlertCategoryQuarantine {
+				continue
+			}
+			alerts++
+			var found bool
+			for soid := range expected {
+				found = found || alert.Cause == soid.String()
+			}
+			if !found {
+				t.Error("unexpected quarantine alert for", alert.Cause)
+			}
+		}
+		if alerts != len(expected) {
+			t.Errorf("expected %v quarantine alerts, got %v", len(expected), alerts)
+		}
+	}
+	expected := map[types.FileContractID]modules.HostObligationViolation{
+		missing.id():     modules.HostObligationViolationMissingSectors,
+		roots.id():       modules.HostObligationViolationSectorRoots,
+		transition.id():  modules.HostObligationViolationRevisionTransition,
+		revisionSet.id(): modules.HostObligationViolationRevisionSet,
+		originID:         modules.HostObligationViolationOriginSet,
+	}
+	checkQuarantine(expected)
+
+	// Renters cannot use quarantined obligations.
+	_, _, _, err = ht.host.managedVerifyChallengeResponse(transition.id(), crypto.Hash{}, crypto.Signature{})
+	if err == nil || !strings.Contains(err.Error(), errObligationQuarantined.Error()) {
+		t.Fatal("expected errObligationQuarantined, got", err)
+	}
+
+	// The other obligations of the host are unaffected, and keep being
+	// processed until they are resolved. The quarantined obligations are not
+	// resolved.
+	for i := ht.host.blockHeight; i <= healthy.proofDeadline(); i++ {
+		if _, err := ht.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		return ht.host.db.View(func(tx *bolt.Tx) error {
+			so, err := getStorageObligation(tx, healthy.id())
+			if err != nil {
+				return err
+			}
+			if so.quarantined() || so.ObligationStatus == obligationUnresolved {
+				return errors.New("healthy obligation was not resolved")
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkQuarantine(expected)
+
+	// Unknown actions are rejected, and obligations need a reason to fail.
+	if err := ht.host.ResolveQuarantinedObligation(missing.id(), "foo", ""); err != errUnknownQuarantineAction {
+		t.Fatal("expected errUnknownQuarantineAction, got", err)
+	}
+	if err := ht.host.ResolveQuarantinedObligation(missing.id(), modules.HostQuarantineActionFail, ""); err != errNoExpirationReason {
+		t.Fatal("expected errNoExpirationReason, got", err)
+	}
+	if err := ht.host.ResolveQuarantinedObligation(healthy.id(), modules.HostQuarantineActionRecheck, ""); err != errObligationResolved {
+		t.Fatal("expected errObligationResolved, got", err)
+	}
+
+	// Rechecking an obligation with missing sectors only releases it once the
+	// sectors are back.
+	if err := ht.host.ResolveQuarantinedObligation(missing.id(), modules.HostQuarantineActionRecheck, ""); err == nil {
+		t.Fatal("obligation with missing sectors was released")
+	}
+	checkQuarantine(expected)
+	if err := ht.host.AddSector(missing.SectorRoots[0], missingData); err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.ResolveQuarantinedObligation(missing.id(), modules.HostQuarantineActionRecheck, ""); err != nil {
+		t.Fatal(err)
+	}
+	delete(expected, missing.id())
+	checkQuarantine(expected)
+
+	// Rebuilding drops the revision that does not match the sector roots, but
+	// cannot repair an obligation without a valid revision.
+	if err := ht.host.ResolveQuarantinedObligation(roots.id(), modules.HostQuarantineActionRebuild, ""); err != nil {
+		t.Fatal(err)
+	}
+	delete(expected, roots.id())
+	if err := ht.host.ResolveQuarantinedObligation(transition.id(), modules.HostQuarantineActionRebuild, ""); err != errNoRevisionMatchesRoots {
+		t.Fatal("expected errNoRevisionMatchesRoots, got", err)
+	}
+	checkQuarantine(expected)
+
+	// Failing an obligation resolves it, unless the obligation has no file
+	// contract to fail.
+	if err := ht.host.ResolveQuarantinedObligation(transition.id(), modules.HostQuarantineActionFail, "corrupted"); err != nil {
+		t.Fatal(err)
+	}
+	delete(expected, transition.id())
+	if err := ht.host.ResolveQuarantinedObligation(originID, modules.HostQuarantineActionFail, "corrupted"); err == nil {
+		t.Fatal("obligation without a file contract was failed")
+	}
+	err = ht.host.db.View(func(tx *bolt.Tx) error {
+		so, err := getStorageObligation(tx, transition.id())
+		if err != nil {
+			return err
+		}
+		if so.ObligationStatus != obligationFailed || so.ExpirationReason != "corrupted" {
+			return errors.New("quarantined obligation was not failed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Releasing an obligation does not check it again.
+	if err := ht.host.ResolveQuarantinedObligation(revisionSet.id(), modules.HostQuarantineActionRelease, ""); err != nil {
+		t.Fatal(err)
+	}
+	delete(expected, revisionSet.id())
+	checkQuarantine(expected)
+
+	// Quarantined obligations stay quarantined after a restart, and the host
+	// checks the other obligations when it starts.
+	if err := ht.host.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ht.host, err = New(ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected[revisionSet.id()] = modules.HostObligationViolationRevisionSet
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		if len(ht.host.QuarantinedObligations()) != len(expected) {
+			return errors.New("released obligation was not quarantined again")
+		}
+		var alerts int
+		for _, alert := range ht.host.Alerts() {
+			if alert.Category == modules.HostAlertCategoryQuarantine {
+				alerts++
+			}
+		}
+		if alerts != len(expected) {
+			return errors.New("quarantine alerts were not raised again")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkQuarantine(expected)
+}
@@ -0,0 +1,137 @@
+package host
+
+// revenue.go moves the revenue of storage obligations out of the potential
+// revenue of the host when the obligations are resolved, and back when a reorg
+// undoes the storage proof that an obligation was resolved by.
+//
+// The financial metrics are saved in the settings file, while the obligations
+// are saved in the database, so the two cannot be updated atomically. Instead,
+// every transition is stamped with a sequence number in the same database
+// update that changes the status of the obligation, and the settings file
+// records the sequence number of the last transition that its financial
+// metrics include. The settings file is saved right after every transition, so
+// at most one transition per obligation is missing from the settings file
+// after a crash, and load replays it.
+
+import (
+	"encoding/json"
+
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// applyResolution moves the revenue and collateral of an obligation that has
+// been resolved with the status so.ObligationStatus out of the potential
+// revenue and risked collateral of fm.
+func applyResolution(fm *modules.HostFinancialMetrics, so storageObligation) {
+	fm.ContractCount--
+	if so.ObligationStatus == obligationRejected {
+		if fm.TransactionFeeExpenses.Cmp(so.TransactionFeesAdded) < 0 {
+			// The fees of the obligation were never counted, so neither was
+			// its revenue.
+			return
+		}
+		fm.TransactionFeeExpenses = fm.TransactionFeeExpenses.Sub(so.TransactionFeesAdded)
+	}
+
+	// Remove the obligation statistics as potential risk and income.
+	fm.PotentialContractCompensation = fm.PotentialContractCompensation.Sub(so.ContractCost)
+	fm.LockedStorageCollateral = fm.LockedStorageCollateral.Sub(so.LockedCollateral)
+	fm.PotentialStorageRevenue = fm.PotentialStorageRevenue.Sub(so.PotentialStorageRevenue)
+	fm.PotentialDownloadBandwidthRevenue = fm.PotentialDownloadBandwidthRevenue.Sub(so.PotentialDownloadRevenue)
+	fm.PotentialUploadBandwidthRevenue = fm.PotentialUploadBandwidthRevenue.Sub(so.PotentialUploadRevenue)
+	fm.RiskedStorageCollateral = fm.RiskedStorageCollateral.Sub(so.RiskedCollateral)
+
+	switch so.ObligationStatus {
+	case obligationSucceeded:
+		// Add the obligation statistics as actual income.
+		fm.ContractCompensation = fm.ContractCompensation.Add(so.ContractCost)
+		fm.StorageRevenue = fm.StorageRevenue.Add(so.PotentialStorageRevenue)
+		fm.DownloadBandwidthRevenue = fm.DownloadBandwidthRevenue.Add(so.PotentialDownloadRevenue)
+		fm.UploadBandwidthRevenue = fm.UploadBandwidthRevenue.Add(so.PotentialUploadRevenue)
+	case obligationFailed:
+		// Add the obligation statistics as loss.
+		fm.LostStorageCollateral = fm.LostStorageCollateral.Add(so.RiskedCollateral)
+		fm.LostRevenue = fm.LostRevenue.Add(so.ContractCost).Add(so.PotentialStorageRevenue).Add(so.PotentialDownloadRevenue).Add(so.PotentialUploadRevenue)
+	}
+}
+
+// revertResolution undoes applyResolution for an obligation that succeeded or
+// failed with the status so.RevertedStatus, returning its revenue and
+// collateral to the potential revenue and risked collateral of fm.
+func revertResolution(fm *modules.HostFinancialMetrics, so storageObligation) {
+	switch so.RevertedStatus {
+	case obligationSucceeded:
+		fm.ContractCompensation = fm.ContractCompensation.Sub(so.ContractCost)
+		fm.StorageRevenue = fm.StorageRevenue.Sub(so.PotentialStorageRevenue)
+		fm.DownloadBandwidthRevenue = fm.DownloadBandwidthRevenue.Sub(so.PotentialDownloadRevenue)
+		fm.UploadBandwidthRevenue = fm.UploadBandwidthRevenue.Sub(so.PotentialUploadRevenue)
+	case obligationFailed:
+		fm.LostStorageCollateral = fm.LostStorageCollateral.Sub(so.RiskedCollateral)
+		fm.LostRevenue = fm.LostRevenue.Sub(so.ContractCost).Sub(so.PotentialStorageRevenue).Sub(so.PotentialDownloadRevenue).Sub(so.PotentialUploadRevenue)
+	}
+
+	fm.ContractCount++
+	fm.PotentialContractCompensation = fm.PotentialContractCompensation.Add(so.ContractCost)
+	fm.LockedStorageCollateral = fm.LockedStorageCollateral.Add(so.LockedCollateral)
+	fm.PotentialStorageRevenue = fm.PotentialStorageRevenue.Add(so.PotentialStorageRevenue)
+	fm.PotentialDownloadBandwidthRevenue = fm.PotentialDownloadBandwidthRevenue.Add(so.PotentialDownloadRevenue)
+	fm.PotentialUploadBandwidthRevenue = fm.PotentialUploadBandwidthRevenue.Add(so.PotentialUploadRevenue)
+	fm.RiskedStorageCollateral = fm.RiskedStorageCollateral.Add(so.RiskedCollateral)
+}
+
+// reopenStorageObligation marks a resolved obligation as unresolved again,
+// because a reorg has changed whether its storage proof is on the blockchain.
+// The caller must apply revertResolution to the financial metrics once tx has
+// been committed. reopenStorageObligation returns false if the status of the
+// obligation does not depend on the storage proof.
+func (h *Host) reopenStorageObligation(tx *bolt.Tx, so *storageObligation, seq uint64) (bool, error) {
+	if so.ObligationStatus != obligationSucceeded && so.ObligationStatus != obligationFailed {
+		return false, nil
+	}
+	if so.ExpirationReason != "" {
+		// Obligations expired by the operator stay failed.
+		return false, nil
+	}
+	h.log.Printf("Reorg changed the storage proof of %v, which was resolved at height %v, reopening the obligation\n", so.id(), so.ResolutionHeight)
+	so.RevertedStatus = so.ObligationStatus
+	so.ObligationStatus = obligationUnresolved
+	so.RevenueSequence = seq
+	return true, putStorageObligation(tx, *so)
+}
+
+// replayRevenueTransitions applies the transitions of obligations that were
+// committed to the database, but are missing from the financial metrics
+// loaded from the settings file.
+func (h *Host) replayRevenueTransitions() error {
+	var replayed int
+	err := h.db.View(func(tx *bolt.Tx) error {
+		saved := h.revenueSequence
+		return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+			var so storageObligation
+			err := json.Unmarshal(soBytes, &so)
+			if err != nil {
+				return err
+			}
+			if so.RevenueSequence <= saved {
+				return nil
+			}
+			if so.ObligationStatus == obligationUnresolved {
+				revertResolution(&h.financialMetrics, so)
+			} else {
+				applyResolution(&h.financialMetrics, so)
+			}
+			if so.RevenueSequence > h.revenueSequence {
+				h.revenueSequence = so.RevenueSequence
+			}
+			replayed++
+			return nil
+		})
+	})
+	if err != nil || replayed == 0 {
+		return err
+	}
+	h.log.Printf("Replayed %v storage obligation revenue transitions that were missing from the financial metrics\n", replayed)
+	return h.saveSync()
+}
@@ -0,0 +1,177 @@
+package host
+
+// sectordeletion.go implements the queue of sectors that are waiting to be
+// removed from the storage manager. Removing the sectors of a large storage
+// obligation can take a long time, and the obligation is removed while the
+// host lock is held, which would stall the host's consensus subscription.
+// Instead, the sectors of a removed obligation are added to a persistent
+// queue in the same database transaction that removes the sector roots from
+// the obligation, and a background thread removes the queued sectors in small
+// batches.
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/bolt"
+)
+
+// queuedSectorDeletion is a sector that is to be removed from the storage
+// manager 'count' times.
+type queuedSectorDeletion struct {
+	root  crypto.Hash
+	count uint64
+}
+
+// queueSectorDeletions adds the provided sector roots to the sector deletion
+// queue. A root that appears multiple times is removed multiple times.
+func queueSectorDeletions(tx *bolt.Tx, roots []crypto.Hash) error {
+	bucket := tx.Bucket(bucketSectorDeletions)
+	for _, root := range roots {
+		var count uint64
+		if v := bucket.Get(root[:]); len(v) == 8 {
+			count = binary.BigEndian.Uint64(v)
+		}
+		var v [8]byte
+		binary.BigEndian.PutUint64(v[:], count+1)
+		err := bucket.Put(root[:], v[:])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countSectorDeletions returns the number of sectors in the sector deletion
+// queue.
+func (h *Host) countSectorDeletions() (queued uint64, err error) {
+	err = h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSectorDeletions).ForEach(func(_, v []byte) error {
+			if len(v) == 8 {
+				queued += binary.BigEndian.Uint64(v)
+			}
+			return nil
+		})
+	})
+	return queued, err
+}
+
+// managedNextSectorDeletions returns up to sectorDeletionBatchSize sectors
+// from the sector deletion queue. The sectors remain in the queue until they
+// are removed.
+func (h *Host) managedNextSectorDeletions() (deletions []queuedSectorDeletion, err error) {
+	err = h.db.View(func(tx *bolt.Tx) error {
+		var total int
+		cursor := tx.Bucket(bucketSectorDeletions).Cursor()
+		for k, v := cursor.First(); k != nil && total < sectorDeletionBatchSize; k, v = cursor.Next() {
+			var d queuedSectorDeletion
+			copy(d.root[:], k)
+			if len(v) == 8 {
+				d.count = binary.BigEndian.Uint64(v)
+			}
+			if remaining := uint64(sectorDeletionBatchSize - total); d.count > remaining {
+				d.count = remaining
+			}
+			deletions = append(deletions, d)
+			total += int(d.count)
+		}
+		return nil
+	})
+	return deletions, err
+}
+
+// managedDeleteSectors removes the provided sectors from the storage manager
+// and then from the sector deletion queue.
+func (h *Host) managedDeleteSectors(deletions []queuedSectorDeletion) error {
+	var roots []crypto.Hash
+	for _, d := range deletions {
+		for i := uint64(0); i < d.count; i++ {
+			roots = append(roots, d.root)
+		}
+	}
+	if h.RemoveSectors(roots) != nil {
+		// The batch is rejected entirely if any of the sectors cannot be
+		// removed, for example because it is missing. Remove the sectors one
+		// at a time instead, ignoring the sectors that cannot be removed so
+		// that they do not stay in the queue forever.
+		for _, root := range roots {
+			_ = h.RemoveSector(root)
+		}
+	}
+
+	err := h.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketSectorDeletions)
+		for _, d := range deletions {
+			var count uint64
+			if v := bucket.Get(d.root[:]); len(v) == 8 {
+				count = binary.BigEndian.Uint64(v)
+			}
+			if count <= d.count {
+				if err := bucket.Delete(d.root[:]); err != nil {
+					return err
+				}
+				continue
+			}
+			var v [8]byte
+			binary.BigEndian.PutUint64(v[:], count-d.count)
+			if err := bucket.Put(d.root[:], v[:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	if h.queuedSectorDeletions >= uint64(len(roots)) {
+		h.queuedSectorDeletions -= uint64(len(roots))
+	} else {
+		h.queuedSectorDeletions = 0
+	}
+	h.mu.Unlock()
+	return nil
+}
+
+// threadedDeleteSectors removes the queued sectors from the storage manager,
+// waiting sectorDeletionInterval between batches.
+func (h *Host) threadedDeleteSectors(closeChan chan struct{}) {
+	defer close(closeChan)
+	for {
+		deletions, err := h.managedNextSectorDeletions()
+		if err == nil && len(deletions) > 0 {
+			err = h.managedDeleteSectors(deletions)
+		}
+		if err != nil {
+			h.log.Println("Unable to remove queued sectors:", err)
+		}
+
+		// Wait for more sectors to be queued if the queue is empty, otherwise
+		// wait before removing the next batch.
+		wait := time.After(sectorDeletionInterval)
+		if err == nil && len(deletions) == 0 {
+			wait = nil
+		}
+		select {
+		case <-h.tg.StopChan():
+			return
+		case <-h.sectorDeletionsQueued:
+		case <-wait:
+		}
+	}
+}
+
+// SectorDeletionMetrics returns the number of sectors that are queued for
+// removal from the storage manager. The queued sectors still count towards the
+// storage that is used by the host.
+func (h *Host) SectorDeletionMetrics() modules.HostSectorDeletionMetrics {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return modules.HostSectorDeletionMetrics{
+		QueuedSectors: h.queuedSectorDeletions,
+		QueuedBytes:   h.queuedSectorDeletions * modules.SectorSize,
+	}
+}
@@ -0,0 +1,121 @@
+package host
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/bolt"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// usedStorage returns the number of bytes stored by the host.
+func usedStorage(h *Host) uint64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	total, remaining := h.capacity()
+	return total - remaining
+}
+
+// TestSectorDeletionQueue checks that queued sectors are removed from the
+// storage manager in the background, and that the queue survives a restart.
+func TestSectorDeletionQueue(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Add three sectors, one of them twice.
+	var roots []crypto.Hash
+	var firstData []byte
+	for i := 0; i < 3; i++ {
+		data := fastrand.Bytes(int(modules.SectorSize))
+		root := crypto.MerkleRoot(data)
+		if err := ht.host.AddSector(root, data); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+		if i == 0 {
+			firstData = data
+		}
+	}
+	if err := ht.host.AddSector(roots[0], firstData); err != nil {
+		t.Fatal(err)
+	}
+	roots = append(roots, roots[0])
+	if used := usedStorage(ht.host); used != 3*modules.SectorSize {
+		t.Fatal("expected three sectors to be stored, got", used/modules.SectorSize)
+	}
+
+	// Queue the sectors without waking up the deletion thread, then restart
+	// the host. The queued sectors should be counted and removed after the
+	// restart.
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		return queueSectorDeletions(tx, roots)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued, err := ht.host.countSectorDeletions(); err != nil || queued != 4 {
+		t.Fatal("expected 4 queued sectors, got", queued, err)
+	}
+	if err := ht.host.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ht.host, err = New(ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 50*time.Millisecond, func() error {
+		if sd := ht.host.SectorDeletionMetrics(); sd.QueuedSectors != 0 || sd.QueuedBytes != 0 {
+			return errors.New("sectors are still queued")
+		} else if used := usedStorage(ht.host); used != 0 {
+			return errors.New("sectors were not removed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued, err := ht.host.countSectorDeletions(); err != nil || queued != 0 {
+		t.Fatal("expected the queue to be empty, got", queued, err)
+	}
+
+	// Queuing a sector that does not exist should not block the queue.
+	data := fastrand.Bytes(int(modules.SectorSize))
+	root := crypto.MerkleRoot(data)
+	if err := ht.host.AddSector(root, data); err != nil {
+		t.Fatal(err)
+	}
+	missing := crypto.Hash{1}
+	ht.host.mu.Lock()
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		return queueSectorDeletions(tx, []crypto.Hash{missing, root})
+	})
+	ht.host.queuedSectorDeletions += 2
+	ht.host.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.sectorDeletionsQueued <- struct{}{}
+	err = build.Retry(100, 50*time.Millisecond, func() error {
+		if sd := ht.host.SectorDeletionMetrics(); sd.QueuedSectors != 0 {
+			return errors.New("sectors are still queued")
+		} else if used := usedStorage(ht.host); used != 0 {
+			return errors.New("sector was not removed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
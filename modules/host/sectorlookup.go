@@ -0,0 +1,69 @@
+package host
+
+import (
+	"encoding/json"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/bolt"
+)
+
+// HasSector returns whether the host has the sector with the provided root,
+// and if so, where the sector is stored and which unresolved storage
+// obligations reference it.
+func (h *Host) HasSector(root crypto.Hash) (bool, modules.SectorLocation, error) {
+	locations, err := h.HasSectors([]crypto.Hash{root})
+	if err != nil {
+		return false, modules.SectorLocation{}, err
+	}
+	sl, exists := locations[root]
+	return exists, sl, nil
+}
+
+// HasSectors returns the location of each of the provided roots that the host
+// has, along with the unresolved storage obligations that reference each
+// sector. The storage obligations are read in a single pass, no matter how
+// many roots are provided.
+func (h *Host) HasSectors(roots []crypto.Hash) (map[crypto.Hash]modules.SectorLocation, error) {
+	err := h.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer h.tg.Done()
+
+	locations, err := h.StorageManager.HasSectors(roots)
+	if err != nil || len(locations) == 0 {
+		return locations, err
+	}
+	err = h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+			var so storageObligation
+			err := json.Unmarshal(soBytes, &so)
+			if err != nil {
+				return build.ExtendErr("unable to unmarshal storage obligation:", err)
+			}
+			if so.ObligationStatus != obligationUnresolved {
+				return nil
+			}
+			soid := so.id()
+			for _, root := range so.SectorRoots {
+				sl, exists := locations[root]
+				if !exists {
+					continue
+				}
+				// A root can appear more than once in the same obligation.
+				if n := len(sl.Obligations); n > 0 && sl.Obligations[n-1] == soid {
+					continue
+				}
+				sl.Obligations = append(sl.Obligations, soid)
+				locations[root] = sl
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
@@ -0,0 +1,87 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestHasSectors checks that the host reports the storage obligations that
+// reference a sector along with its location.
+func TestHasSectors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	var roots []crypto.Hash
+	for i := 0; i < 2; i++ {
+		data := fastrand.Bytes(int(modules.SectorSize))
+		root := crypto.MerkleRoot(data)
+		if err := ht.host.AddSector(root, data); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+	missing := crypto.Hash{1}
+
+	// The first obligation references the first sector, and the second
+	// obligation references both sectors.
+	var soids []types.FileContractID
+	for _, sectorRoots := range [][]crypto.Hash{roots[:1], roots} {
+		so, err := ht.newTesterStorageObligation()
+		if err != nil {
+			t.Fatal(err)
+		}
+		so.SectorRoots = sectorRoots
+		ht.host.managedLockStorageObligation(so.id())
+		err = ht.host.managedAddStorageObligation(so)
+		ht.host.managedUnlockStorageObligation(so.id())
+		if err != nil {
+			t.Fatal(err)
+		}
+		soids = append(soids, so.id())
+	}
+
+	locations, err := ht.host.HasSectors([]crypto.Hash{roots[0], missing, roots[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locations) != 2 {
+		t.Fatal("expected 2 locations, got", len(locations))
+	}
+	sl := locations[roots[0]]
+	if sl.ReferenceCount != 3 || len(sl.Obligations) != 2 {
+		t.Fatalf("wrong location for the first sector: %+v", sl)
+	}
+	if !(sl.Obligations[0] == soids[0] && sl.Obligations[1] == soids[1]) && !(sl.Obligations[0] == soids[1] && sl.Obligations[1] == soids[0]) {
+		t.Fatal("wrong obligations for the first sector:", sl.Obligations)
+	}
+	sl = locations[roots[1]]
+	if sl.ReferenceCount != 2 || len(sl.Obligations) != 1 || sl.Obligations[0] != soids[1] {
+		t.Fatalf("wrong location for the second sector: %+v", sl)
+	}
+
+	exists, sl, err := ht.host.HasSector(roots[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists || len(sl.Obligations) != 1 {
+		t.Fatalf("wrong result from HasSector: %v %+v", exists, sl)
+	}
+	exists, _, err = ht.host.HasSector(missing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("HasSector found a sector that was never added")
+	}
+}
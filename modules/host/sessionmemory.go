@@ -0,0 +1,126 @@
+package host
+
+// sessionmemory.go is responsible for limiting the amount of memory that can
+// be used by concurrent RPC sessions. Each session requests memory for its
+// buffers (batches of sector data, sector roots of large obligations, etc.)
+// before allocating them, and returns the memory when it is done. If the
+// budget is exhausted, the session waits briefly for memory to be returned,
+// and is rejected with errHostBusy if no memory becomes available.
+
+import (
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+var (
+	// errHostBusy is returned if a session could not get enough memory to
+	// process a renter's request within sessionMemoryTimeout.
+	errHostBusy = ErrorInternal("host is busy, not enough memory is available to process the request")
+)
+
+// sessionMemory tracks the amount of memory in use by RPC sessions.
+type sessionMemory struct {
+	used      uint64
+	highWater uint64
+
+	// available is closed and set to nil every time memory is returned, waking
+	// up any sessions that are waiting for memory.
+	available chan struct{}
+	mu        sync.Mutex
+}
+
+// request attempts to reserve 'amount' bytes of memory without exceeding
+// 'limit', waiting until 'timeout' has elapsed or 'stop' is closed. To
+// prevent large requests from starving, a request that exceeds the limit on
+// its own is granted once no other memory is in use.
+func (sm *sessionMemory) request(amount, limit uint64, timeout time.Duration, stop <-chan struct{}) bool {
+	deadline := time.After(timeout)
+	for {
+		sm.mu.Lock()
+		if sm.used+amount <= limit || sm.used == 0 {
+			sm.used += amount
+			if sm.used > sm.highWater {
+				sm.highWater = sm.used
+			}
+			sm.mu.Unlock()
+			return true
+		}
+		if sm.available == nil {
+			sm.available = make(chan struct{})
+		}
+		available := sm.available
+		sm.mu.Unlock()
+
+		select {
+		case <-available:
+		case <-deadline:
+			return false
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// release returns 'amount' bytes of memory to the budget.
+func (sm *sessionMemory) release(amount uint64) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if amount > sm.used {
+		build.Critical("session memory released more memory than was requested")
+		amount = sm.used
+	}
+	sm.used -= amount
+	if sm.available != nil {
+		close(sm.available)
+		sm.available = nil
+	}
+}
+
+// sessionMemoryLimit returns the number of bytes that RPC sessions are allowed
+// to use at once.
+func (h *Host) sessionMemoryLimit() uint64 {
+	if h.settings.MaxSessionMemory == 0 {
+		return defaultMaxSessionMemory
+	}
+	return h.settings.MaxSessionMemory
+}
+
+// managedRequestSessionMemory reserves memory for an RPC session. The memory
+// must be returned with managedReleaseSessionMemory once the session no longer
+// needs it. errHostBusy is returned if the memory could not be reserved in
+// time.
+func (h *Host) managedRequestSessionMemory(amount uint64) error {
+	h.mu.RLock()
+	limit := h.sessionMemoryLimit()
+	h.mu.RUnlock()
+
+	if !h.sessionMemory.request(amount, limit, sessionMemoryTimeout, h.tg.StopChan()) {
+		return errHostBusy
+	}
+	return nil
+}
+
+// managedReleaseSessionMemory returns memory that was reserved by
+// managedRequestSessionMemory.
+func (h *Host) managedReleaseSessionMemory(amount uint64) {
+	h.sessionMemory.release(amount)
+}
+
+// SessionMemoryMetrics returns the amount of memory that is currently in use
+// by RPC sessions, along with the highest usage since startup.
+func (h *Host) SessionMemoryMetrics() modules.HostSessionMemoryMetrics {
+	h.mu.RLock()
+	limit := h.sessionMemoryLimit()
+	h.mu.RUnlock()
+
+	h.sessionMemory.mu.Lock()
+	defer h.sessionMemory.mu.Unlock()
+	return modules.HostSessionMemoryMetrics{
+		Limit:     limit,
+		Used:      h.sessionMemory.used,
+		HighWater: h.sessionMemory.highWater,
+	}
+}
@@ -0,0 +1,64 @@
+package host
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSessionMemory checks that the session memory accountant enforces its
+// limit, wakes up waiting sessions when memory is released, and tracks the
+// high water mark.
+func TestSessionMemory(t *testing.T) {
+	var sm sessionMemory
+	stop := make(chan struct{})
+
+	// Requests within the limit should be granted immediately.
+	if !sm.request(60, 100, time.Second, stop) {
+		t.Fatal("request within the limit was rejected")
+	}
+	if !sm.request(40, 100, time.Second, stop) {
+		t.Fatal("request within the limit was rejected")
+	}
+
+	// A request beyond the limit should time out.
+	if sm.request(1, 100, 10*time.Millisecond, stop) {
+		t.Fatal("request beyond the limit was granted")
+	}
+
+	// A waiting request should be granted once enough memory is released.
+	granted := make(chan bool)
+	go func() {
+		granted <- sm.request(50, 100, time.Second, stop)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	sm.release(60)
+	if !<-granted {
+		t.Fatal("waiting request was not granted after memory was released")
+	}
+	if sm.used != 90 || sm.highWater != 100 {
+		t.Fatal("unexpected memory usage:", sm.used, sm.highWater)
+	}
+
+	// Closing the stop channel should abort a waiting request.
+	go func() {
+		granted <- sm.request(50, 100, time.Minute, stop)
+	}()
+	close(stop)
+	if <-granted {
+		t.Fatal("request was granted after stop was closed")
+	}
+
+	// A request that is larger than the limit is granted once no other memory
+	// is in use.
+	sm.release(90)
+	if !sm.request(150, 100, time.Second, make(chan struct{})) {
+		t.Fatal("large request was rejected while no memory was in use")
+	}
+	if sm.highWater != 150 {
+		t.Fatal("high water mark was not updated:", sm.highWater)
+	}
+	sm.release(150)
+	if sm.used != 0 {
+		t.Fatal("memory was not released:", sm.used)
+	}
+}
@@ -0,0 +1,94 @@
+package host
+
+// settingsfile.go implements the export and import of the host's internal
+// settings, which makes it easy to move a host to a new machine or to
+// configure several hosts identically. The settings are written as the JSON
+// encoding of modules.HostInternalSettings, in which currencies are strings,
+// so no precision is lost.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// settingsFileFields returns the JSON names of the fields of
+// modules.HostInternalSettings, in the order of the fields.
+func settingsFileFields() []string {
+	t := reflect.TypeOf(modules.HostInternalSettings{})
+	names := make([]string, t.NumField())
+	for i := range names {
+		names[i] = strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+	}
+	return names
+}
+
+// ExportSettings writes the internal settings of the host to w.
+func (h *Host) ExportSettings(w io.Writer) error {
+	if err := h.tg.Add(); err != nil {
+		return err
+	}
+	defer h.tg.Done()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(h.InternalSettings())
+}
+
+// ImportSettings reads internal settings that were written by ExportSettings
+// from r, and applies them with SetInternalSettings. Settings files with
+// unknown, missing, malformed, or invalid settings are rejected with an error
+// for every such setting, and the settings of the host are left unchanged.
+func (h *Host) ImportSettings(r io.Reader) error {
+	if err := h.tg.Add(); err != nil {
+		return err
+	}
+	defer h.tg.Done()
+
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&fields); err != nil {
+		return build.ExtendErr("unable to decode settings file", err)
+	}
+
+	// Decode the settings one at a time, so that every problem with the file
+	// is reported.
+	var settings modules.HostInternalSettings
+	var errs []error
+	v := reflect.ValueOf(&settings).Elem()
+	names := settingsFileFields()
+	known := make(map[string]struct{}, len(names))
+	for i, name := range names {
+		known[name] = struct{}{}
+		raw, exists := fields[name]
+		if !exists {
+			errs = append(errs, fmt.Errorf("missing setting %q", name))
+			continue
+		}
+		if err := json.Unmarshal(raw, v.Field(i).Addr().Interface()); err != nil {
+			errs = append(errs, fmt.Errorf("invalid setting %q: %v", name, err))
+		}
+	}
+	var unknown []string
+	for name := range fields {
+		if _, exists := known[name]; !exists {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, fmt.Errorf("unknown setting %q", name))
+	}
+	if len(errs) == 0 {
+		errs = append(errs, settings.Validate())
+	}
+	if err := build.JoinErrors(errs, "; "); err != nil {
+		return build.ExtendErr("settings file is invalid", err)
+	}
+	return h.SetInternalSettings(settings)
+}
@@ -0,0 +1,92 @@
+package host
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestExportImportSettings checks that exported settings can be imported
+// again, and that invalid settings files are rejected setting by setting.
+func TestExportImportSettings(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Export settings with a price that does not fit in a float64.
+	settings := ht.host.InternalSettings()
+	settings.MinStoragePrice = types.SiacoinPrecision.Mul64(123456789).Add(types.NewCurrency64(1))
+	settings.RenterWhitelist = []types.SiaPublicKey{{Algorithm: types.SignatureEd25519, Key: []byte{1, 2, 3}}}
+	if err := ht.host.SetInternalSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := ht.host.ExportSettings(&buf); err != nil {
+		t.Fatal(err)
+	}
+	exported := buf.Bytes()
+
+	// Change the settings, then import the exported settings.
+	changed := settings
+	changed.MinStoragePrice = types.ZeroCurrency
+	changed.RenterWhitelist = nil
+	if err := ht.host.SetInternalSettings(changed); err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.ImportSettings(bytes.NewReader(exported)); err != nil {
+		t.Fatal(err)
+	}
+	if got := ht.host.InternalSettings(); !reflect.DeepEqual(got, settings) {
+		t.Fatalf("imported settings do not match:\n%+v\n%+v", got, settings)
+	}
+
+	// Settings files with unknown, missing, and invalid settings should be
+	// rejected with an error naming every problem.
+	var fields map[string]interface{}
+	if err := json.Unmarshal(exported, &fields); err != nil {
+		t.Fatal(err)
+	}
+	delete(fields, "windowsize")
+	fields["foo"] = 1
+	fields["mincontractprice"] = "1.5"
+	invalid, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ht.host.ImportSettings(bytes.NewReader(invalid))
+	if err == nil {
+		t.Fatal("expected the settings file to be rejected")
+	}
+	for _, want := range []string{`missing setting "windowsize"`, `unknown setting "foo"`, `invalid setting "mincontractprice"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got %q", want, err)
+		}
+	}
+
+	// Settings that fail the validation of SetInternalSettings should be
+	// rejected as well.
+	delete(fields, "foo")
+	fields["windowsize"] = 1
+	fields["mincontractprice"] = "0"
+	fields["maxcollateralfraction"] = -1
+	invalid, err = json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.ImportSettings(bytes.NewReader(invalid)); err == nil || !strings.Contains(err.Error(), "MaxCollateralFraction") {
+		t.Fatal("expected a negative MaxCollateralFraction to be rejected, got", err)
+	}
+	if got := ht.host.InternalSettings(); !reflect.DeepEqual(got, settings) {
+		t.Fatal("settings changed after a rejected import")
+	}
+}
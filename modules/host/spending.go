@@ -0,0 +1,21 @@
+package host
+
+import (
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// managedRecordSpending records a transaction set of the host in the spending
+// log of the wallet once the transaction pool has accepted it. id is the file
+// contract of the spending, if there is one.
+func (h *Host) managedRecordSpending(purpose modules.SpendingPurpose, id types.FileContractID, fee types.Currency, txnSet []types.Transaction) {
+	err := h.wallet.RecordSpending(modules.SpendingRecord{
+		Module:    modules.HostDir,
+		Purpose:   purpose,
+		RelatedID: id,
+		Fee:       fee,
+	}, txnSet)
+	if err != nil {
+		h.log.Println("WARN: failed to record spending:", err)
+	}
+}
@@ -0,0 +1,108 @@
+package host
+
+import (
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+// managedStorageObligationIDs returns the ids of every storage obligation in
+// the database.
+func (h *Host) managedStorageObligationIDs() (soids []types.FileContractID, err error) {
+	err = h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).ForEach(func(idBytes, _ []byte) error {
+			var soid types.FileContractID
+			copy(soid[:], idBytes)
+			soids = append(soids, soid)
+			return nil
+		})
+	})
+	return soids, err
+}
+
+// threadedStreamStorageAllocation sends the location of every sector of the
+// host's storage obligations down the provided channel, closing the channel
+// when done. The storage obligations are read one at a time so that the
+// database is not held open while the caller consumes the channel.
+func (h *Host) threadedStreamStorageAllocation(locations chan<- modules.SectorLocationEntry) {
+	defer close(locations)
+	err := h.tg.Add()
+	if err != nil {
+		return
+	}
+	defer h.tg.Done()
+
+	soids, err := h.managedStorageObligationIDs()
+	if err != nil {
+		h.log.Println("Unable to list storage obligations for the storage allocation:", err)
+		return
+	}
+	for _, soid := range soids {
+		var roots []crypto.Hash
+		err := h.db.View(func(tx *bolt.Tx) error {
+			so, err := getStorageObligation(tx, soid)
+			roots = so.SectorRoots
+			return err
+		})
+		if err == errNoStorageObligation {
+			continue
+		} else if err != nil {
+			h.log.Println("Unable to load storage obligation for the storage allocation:", err)
+			return
+		}
+
+		for _, root := range roots {
+			sl, err := h.SectorLocation(root)
+			if err != nil {
+				h.log.Debugf("Unable to locate sector %v of storage obligation %v: %v", root, soid, err)
+				continue
+			}
+			select {
+			case locations <- modules.SectorLocationEntry{Root: root, SectorLocation: sl}:
+			case <-h.tg.StopChan():
+				return
+			}
+		}
+	}
+}
+
+// StorageAllocationIter returns a channel that streams the location of every
+// sector of the host's storage obligations. A sector that belongs to multiple
+// storage obligations is sent once for each obligation, and sectors that
+// cannot be found in the storage manager are skipped.
+func (h *Host) StorageAllocationIter() <-chan modules.SectorLocationEntry {
+	locations := make(chan modules.SectorLocationEntry)
+	go h.threadedStreamStorageAllocation(locations)
+	return locations
+}
+
+// StorageAllocationMap returns the location of every sector of the host's
+// storage obligations, keyed by sector root.
+func (h *Host) StorageAllocationMap() map[crypto.Hash]modules.SectorLocation {
+	locations := make(map[crypto.Hash]modules.SectorLocation)
+	for entry := range h.StorageAllocationIter() {
+		locations[entry.Root] = entry.SectorLocation
+	}
+	return locations
+}
+
+// VerifyStorageFolder verifies a sample of the sectors of the storage folder at
+// path with the storage manager, and fills in the expected roots of the
+// corrupted sectors from the sector roots of the host's storage obligations.
+func (h *Host) VerifyStorageFolder(path string, sampleRate float64) ([]modules.SectorError, error) {
+	sectorErrors, err := h.StorageManager.VerifyStorageFolder(path, sampleRate)
+	if err != nil || len(sectorErrors) == 0 {
+		return sectorErrors, err
+	}
+	corrupted := make(map[uint64]int)
+	for i, se := range sectorErrors {
+		corrupted[se.Offset] = i
+	}
+	for entry := range h.StorageAllocationIter() {
+		if i, exists := corrupted[entry.Offset]; exists && entry.Path == path {
+			sectorErrors[i].ExpectedRoot = entry.Root
+		}
+	}
+	return sectorErrors, nil
+}
@@ -0,0 +1,152 @@
+package host
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestStorageAllocation checks that the storage allocation reports where the
+// sectors of the host's storage obligations are stored on disk.
+func TestStorageAllocation(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Store three sectors and put them into two storage obligations, one of
+	// them shared. A fourth root is missing from the storage manager.
+	sectors := make(map[crypto.Hash][]byte)
+	var roots []crypto.Hash
+	for i := 0; i < 3; i++ {
+		data := fastrand.Bytes(int(modules.SectorSize))
+		root := crypto.MerkleRoot(data)
+		if err := ht.host.AddSector(root, data); err != nil {
+			t.Fatal(err)
+		}
+		sectors[root] = data
+		roots = append(roots, root)
+	}
+	sos := []storageObligation{
+		{SectorRoots: []crypto.Hash{roots[0], roots[1]}},
+		{SectorRoots: []crypto.Hash{roots[1], roots[2], {1}}},
+	}
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		for i := range sos {
+			sos[i].OriginTransactionSet = []types.Transaction{{
+				FileContracts: []types.FileContract{{}},
+				ArbitraryData: [][]byte{{byte(i)}},
+			}}
+			if err := putStorageObligation(tx, sos[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entries int
+	for entry := range ht.host.StorageAllocationIter() {
+		entries++
+		if _, exists := sectors[entry.Root]; !exists {
+			t.Error("unexpected sector in the storage allocation:", entry.Root)
+		}
+	}
+	if entries != 4 {
+		t.Fatal("expected 4 entries in the storage allocation, got", entries)
+	}
+
+	// Every sector should be at the reported offset of the reported folder.
+	locations := ht.host.StorageAllocationMap()
+	if len(locations) != len(sectors) {
+		t.Fatal("expected the location of 3 sectors, got", len(locations))
+	}
+	folders := make(map[uint16]string)
+	for _, sf := range ht.host.StorageFolders() {
+		folders[sf.Index] = sf.Path
+	}
+	for root, sl := range locations {
+		if folders[sl.StorageFolder] != sl.Path {
+			t.Error("sector reported with the wrong storage folder path:", sl)
+		}
+		f, err := os.Open(filepath.Join(sl.Path, "siahostdata.dat"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := make([]byte, modules.SectorSize)
+		_, err = f.ReadAt(data, int64(sl.Offset))
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, sectors[root]) {
+			t.Error("sector is not stored at the reported offset:", sl.Offset)
+		}
+	}
+}
+
+// TestHostVerifyStorageFolder checks that the host fills in the expected roots
+// of the corrupted sectors of a storage folder.
+func TestHostVerifyStorageFolder(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	so, _, err := ht.addSectorObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := so.SectorRoots[0]
+	sl, exists := ht.host.StorageAllocationMap()[root]
+	if !exists {
+		t.Fatal("sector of the storage obligation is not in the storage allocation")
+	}
+	sectorErrors, err := ht.host.VerifyStorageFolder(sl.Path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sectorErrors) != 0 {
+		t.Fatal("intact storage folder has corrupted sectors:", sectorErrors)
+	}
+
+	// Corrupt the sector on disk.
+	f, err := os.OpenFile(filepath.Join(sl.Path, "siahostdata.dat"), os.O_RDWR, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteAt(fastrand.Bytes(64), int64(sl.Offset))
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sectorErrors, err = ht.host.VerifyStorageFolder(sl.Path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sectorErrors) != 1 {
+		t.Fatal("expected 1 corrupted sector, got", len(sectorErrors))
+	}
+	if se := sectorErrors[0]; se.ExpectedRoot != root || se.ActualRoot == root || se.Offset != sl.Offset {
+		t.Fatalf("corrupted sector was reported incorrectly: %+v", se)
+	}
+}
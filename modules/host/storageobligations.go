@@ -31,6 +31,7 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"fmt"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -98,6 +99,15 @@ var (
 	// is not found in the database.
 	errNoStorageObligation = errors.New("storage obligation not found in database")
 
+	// errNoExpirationReason is returned by ExpireContract if no reason for
+	// expiring the contract is provided.
+	errNoExpirationReason = errors.New("a reason for expiring the contract must be provided")
+
+	// errObligationResolved is returned when an operation requires an
+	// unresolved storage obligation, but the obligation has already succeeded,
+	// failed, or been rejected.
+	errObligationResolved = errors.New("storage obligation has already been resolved")
+
 	// errObligationUnlocked is returned when a storage obligation is being
 	// removed from lock, but is already unlocked.
 	errObligationUnlocked = errors.New("storage obligation is unlocked, and should not be getting unlocked")
@@ -145,6 +155,59 @@ type storageObligation struct {
 	ProofConstructed    bool
 	ProofConfirmed      bool
 	ObligationStatus    storageObligationStatus
+
+	// Variables explaining why a storage proof was missed. ProofFailure and
+	// ProofError record the stage and the error of the most recent failed
+	// attempt at a storage proof, and ProofReverted is set when a confirmed
+	// storage proof is reverted by a reorg. Once the obligation has failed,
+	// ProofFailure is replaced by the final failure reason.
+	ProofFailure  modules.StorageProofFailureReason
+	ProofError    string
+	ProofReverted bool
+
+	// ExpirationReason is set when the host operator terminates the obligation
+	// early with ExpireContract.
+	ExpirationReason string
+
+	// Variables recording the move of the revenue of the obligation out of
+	// the potential revenue of the host. ResolutionHeight is the block height
+	// at which the obligation was resolved, RevertedStatus is the status that
+	// a reorg most recently undid, and RevenueSequence is the sequence number
+	// of the most recent transition, which is compared against the financial
+	// metrics in the settings file at startup.
+	ResolutionHeight types.BlockHeight
+	RevertedStatus   storageObligationStatus
+	RevenueSequence  uint64
+
+	// Variables describing why the obligation is quarantined. An obligation
+	// is quarantined when it fails an invariant check, and is excluded from
+	// the host's normal processing until the operator repairs or resolves it.
+	QuarantineViolation modules.HostObligationViolation
+	QuarantineDetail    string
+	QuarantineHeight    types.BlockHeight
+
+	// RenterIdentity is the identity key of the renter of the contract, if
+	// the renter identified itself when it formed or renewed the contract.
+	RenterIdentity types.SiaPublicKey
+}
+
+// failureReason determines why the storage proof of a failed obligation was
+// missed, based on how far the host got with the storage proof.
+func (so storageObligation) failureReason() modules.StorageProofFailureReason {
+	if so.ExpirationReason != "" {
+		return modules.ProofFailureExpiredByHost
+	} else if so.ProofReverted && !so.ProofConfirmed {
+		return modules.ProofFailureReorged
+	} else if len(so.SectorRoots) == 0 {
+		return modules.ProofFailureEmptyContract
+	} else if so.ProofConstructed {
+		return modules.ProofFailureUnconfirmed
+	} else if so.ProofFailure != "" {
+		return so.ProofFailure
+	}
+	// The host never got far enough to record a failure, which means that no
+	// attempt at a storage proof was made during the proof window.
+	return modules.ProofFailureHostOffline
 }
 
 // getStorageObligation fetches a storage obligation from the database tx.
@@ -163,11 +226,18 @@ func getStorageObligation(tx *bolt.Tx, soid types.FileContractID) (so storageObl
 // putStorageObligation places a storage obligation into the database,
 // overwriting the existing storage obligation if there is one.
 func putStorageObligation(tx *bolt.Tx, so storageObligation) error {
+	return putStorageObligationWithID(tx, so.id(), so)
+}
+
+// putStorageObligationWithID places a storage obligation into the database
+// under soid. Unlike putStorageObligation, the id is not derived from the
+// origin transaction set of the obligation, which may be malformed if the
+// obligation is quarantined.
+func putStorageObligationWithID(tx *bolt.Tx, soid types.FileContractID, so storageObligation) error {
 	soBytes, err := json.Marshal(so)
 	if err != nil {
 		return err
 	}
-	soid := so.id()
 	return tx.Bucket(bucketStorageObligations).Put(soid[:], soBytes)
 }
 
@@ -193,56 +263,6 @@ func (so storageObligation) id() types.FileContractID {
 	return so.OriginTransactionSet[len(so.OriginTransactionSet)-1].FileContractID(0)
 }
 
-// isSane checks that required assumptions about the storage obligation are
-// correct.
-func (so storageObligation) isSane() error {
-	// There should be an origin transaction set.
-	if len(so.OriginTransactionSet) == 0 {
-		build.Critical("origin transaction set is empty")
-		return errInsaneOriginSetSize
-	}
-
-	// The final transaction of the origin transaction set should have one file
-	// contract.
-	final := len(so.OriginTransactionSet) - 1
-	fcCount := len(so.OriginTransactionSet[final].FileContracts)
-	if fcCount != 1 {
-		build.Critical("wrong number of file contracts associated with storage obligation:", fcCount)
-		return errInsaneOriginSetFileContract
-	}
-
-	// The file contract in the final transaction of the origin transaction set
-	// should have two valid proof outputs and two missed proof outputs.
-	lenVPOs := len(so.OriginTransactionSet[final].FileContracts[0].ValidProofOutputs)
-	lenMPOs := len(so.OriginTransactionSet[final].FileContracts[0].MissedProofOutputs)
-	if lenVPOs != 2 || lenMPOs != 2 {
-		build.Critical("file contract has wrong number of VPOs and MPOs, expecting 2 each:", lenVPOs, lenMPOs)
-		return errInsaneFileContractOutputCounts
-	}
-
-	// If there is a revision transaction set, there should be one file
-	// contract revision in the final transaction.
-	if len(so.RevisionTransactionSet) > 0 {
-		final = len(so.OriginTransactionSet) - 1
-		fcrCount := len(so.OriginTransactionSet[final].FileContractRevisions)
-		if fcrCount != 1 {
-			build.Critical("wrong number of file contract revisions in final transaction of revision transaction set:", fcrCount)
-			return errInsaneRevisionSetRevisionCount
-		}
-
-		// The file contract revision in the final transaction of the revision
-		// transaction set should have two valid proof outputs and two missed
-		// proof outputs.
-		lenVPOs = len(so.RevisionTransactionSet[final].FileContractRevisions[0].NewValidProofOutputs)
-		lenMPOs = len(so.RevisionTransactionSet[final].FileContractRevisions[0].NewMissedProofOutputs)
-		if lenVPOs != 2 || lenMPOs != 2 {
-			build.Critical("file contract has wrong number of VPOs and MPOs, expecting 2 each:", lenVPOs, lenMPOs)
-			return errInsaneFileContractRevisionOutputCounts
-		}
-	}
-	return nil
-}
-
 // merkleRoot returns the file merkle root of a storage obligation.
 func (so storageObligation) merkleRoot() crypto.Hash {
 	if len(so.RevisionTransactionSet) > 0 {
@@ -392,6 +412,7 @@ func (h *Host) managedAddStorageObligation(so storageObligation) error {
 		h.log.Println("Failed to add storage obligation, transaction set was not accepted:", err)
 		return err
 	}
+	h.managedRecordSpending(modules.SpendingPurposeContractCollateral, soid, so.TransactionFeesAdded, so.OriginTransactionSet)
 
 	// Queue the action items.
 	h.mu.Lock()
@@ -457,22 +478,17 @@ func (h *Host) modifyStorageObligation(so storageObligation, sectorsRemoved []cr
 	// and left to consistency checks and user actions to fix (will reduce host
 	// capacity, but will not inhibit the host's ability to submit storage
 	// proofs)
-	var i int
-	var err error
-	for i = range sectorsGained {
-		err = h.AddSector(sectorsGained[i], gainedSectorData[i])
-		if err != nil {
-			break
+	// The sectors are added and removed in atomic batches, which means that
+	// a failed addition does not need to be cleaned up.
+	gained := make([]modules.SectorUpdate, len(sectorsGained))
+	for i := range sectorsGained {
+		gained[i] = modules.SectorUpdate{
+			Root: sectorsGained[i],
+			Data: gainedSectorData[i],
 		}
 	}
+	err := h.AddSectors(gained)
 	if err != nil {
-		// Because there was an error, all of the sectors that got added need
-		// to be reverted.
-		for j := 0; j < i; j++ {
-			// Error is not checked because there's nothing useful that can be
-			// done about an error.
-			_ = h.RemoveSector(sectorsGained[j])
-		}
 		return err
 	}
 	// Update the database to contain the new storage obligation.
@@ -490,20 +506,21 @@ func (h *Host) modifyStorageObligation(so storageObligation, sectorsRemoved []cr
 	})
 	if err != nil {
 		// Because there was an error, all of the sectors that got added need
-		// to be reverted.
-		for i := range sectorsGained {
-			// Error is not checked because there's nothing useful that can be
-			// done about an error.
-			_ = h.RemoveSector(sectorsGained[i])
-		}
+		// to be reverted. Error is not checked because there's nothing useful
+		// that can be done about an error.
+		_ = h.RemoveSectors(sectorsGained)
 		return err
 	}
-	// Call removeSector for all of the sectors that have been removed.
-	for k := range sectorsRemoved {
-		// Error is not checkeed because there's nothing useful that can be
-		// done about an error. Failing to remove a sector is not a terrible
-		// place to be, especially if the host can run consistency checks.
-		_ = h.RemoveSector(sectorsRemoved[k])
+	// Remove all of the sectors that have been removed. If the batch fails,
+	// the sectors are removed one at a time so that a single missing sector
+	// does not prevent the others from being removed. Errors are not checked
+	// because there's nothing useful that can be done about an error.
+	// Failing to remove a sector is not a terrible place to be, especially if
+	// the host can run consistency checks.
+	if h.RemoveSectors(sectorsRemoved) != nil {
+		for k := range sectorsRemoved {
+			_ = h.RemoveSector(sectorsRemoved[k])
+		}
 	}
 
 	// Update the financial information for the storage obligation - remove the
@@ -531,69 +548,112 @@ func (h *Host) modifyStorageObligation(so storageObligation, sectorsRemoved []cr
 // removeStorageObligation will remove a storage obligation from the host,
 // either due to failure or success.
 func (h *Host) removeStorageObligation(so storageObligation, sos storageObligationStatus) error {
-	// Error is not checked, we want to call remove on every sector even if
-	// there are problems - disk health information will be updated.
-	_ = h.RemoveSectorBatch(so.SectorRoots)
+	// The sectors of the obligation are queued for removal when the
+	// obligation is saved below, and removed by threadedDeleteSectors.
+	sectorRoots := so.SectorRoots
 
-	// Update the host revenue metrics based on the status of the obligation.
 	if sos == obligationUnresolved {
 		h.log.Critical("storage obligation 'unresolved' during call to removeStorageObligation, id", so.id())
 	}
 	if sos == obligationRejected {
-		if h.financialMetrics.TransactionFeeExpenses.Cmp(so.TransactionFeesAdded) >= 0 {
-			h.financialMetrics.TransactionFeeExpenses = h.financialMetrics.TransactionFeeExpenses.Sub(so.TransactionFeesAdded)
-
-			// Remove the obligation statistics as potential risk and income.
-			h.log.Printf("Rejecting storage obligation expiring at block %v, current height is %v. Potential revenue is %v.\n", so.expiration(), h.blockHeight, h.financialMetrics.PotentialContractCompensation.Add(h.financialMetrics.PotentialStorageRevenue).Add(h.financialMetrics.PotentialDownloadBandwidthRevenue).Add(h.financialMetrics.PotentialUploadBandwidthRevenue))
-			h.financialMetrics.PotentialContractCompensation = h.financialMetrics.PotentialContractCompensation.Sub(so.ContractCost)
-			h.financialMetrics.LockedStorageCollateral = h.financialMetrics.LockedStorageCollateral.Sub(so.LockedCollateral)
-			h.financialMetrics.PotentialStorageRevenue = h.financialMetrics.PotentialStorageRevenue.Sub(so.PotentialStorageRevenue)
-			h.financialMetrics.PotentialDownloadBandwidthRevenue = h.financialMetrics.PotentialDownloadBandwidthRevenue.Sub(so.PotentialDownloadRevenue)
-			h.financialMetrics.PotentialUploadBandwidthRevenue = h.financialMetrics.PotentialUploadBandwidthRevenue.Sub(so.PotentialUploadRevenue)
-			h.financialMetrics.RiskedStorageCollateral = h.financialMetrics.RiskedStorageCollateral.Sub(so.RiskedCollateral)
-		}
+		h.log.Printf("Rejecting storage obligation expiring at block %v, current height is %v. Potential revenue is %v.\n", so.expiration(), h.blockHeight, h.financialMetrics.PotentialTotalRevenue())
 	}
 	if sos == obligationSucceeded {
-		// Remove the obligation statistics as potential risk and income.
 		h.log.Printf("Successfully submitted a storage proof. Revenue is %v.\n", so.ContractCost.Add(so.PotentialStorageRevenue).Add(so.PotentialDownloadRevenue).Add(so.PotentialUploadRevenue))
-		h.financialMetrics.PotentialContractCompensation = h.financialMetrics.PotentialContractCompensation.Sub(so.ContractCost)
-		h.financialMetrics.LockedStorageCollateral = h.financialMetrics.LockedStorageCollateral.Sub(so.LockedCollateral)
-		h.financialMetrics.PotentialStorageRevenue = h.financialMetrics.PotentialStorageRevenue.Sub(so.PotentialStorageRevenue)
-		h.financialMetrics.PotentialDownloadBandwidthRevenue = h.financialMetrics.PotentialDownloadBandwidthRevenue.Sub(so.PotentialDownloadRevenue)
-		h.financialMetrics.PotentialUploadBandwidthRevenue = h.financialMetrics.PotentialUploadBandwidthRevenue.Sub(so.PotentialUploadRevenue)
-		h.financialMetrics.RiskedStorageCollateral = h.financialMetrics.RiskedStorageCollateral.Sub(so.RiskedCollateral)
-
-		// Add the obligation statistics as actual income.
-		h.financialMetrics.ContractCompensation = h.financialMetrics.ContractCompensation.Add(so.ContractCost)
-		h.financialMetrics.StorageRevenue = h.financialMetrics.StorageRevenue.Add(so.PotentialStorageRevenue)
-		h.financialMetrics.DownloadBandwidthRevenue = h.financialMetrics.DownloadBandwidthRevenue.Add(so.PotentialDownloadRevenue)
-		h.financialMetrics.UploadBandwidthRevenue = h.financialMetrics.UploadBandwidthRevenue.Add(so.PotentialUploadRevenue)
+
+		// A successful storage proof means that the host is able to get
+		// proofs onto the blockchain again.
+		h.clearAlert(modules.HostAlertCategoryStorageProof, "")
+		h.clearAlert(modules.HostAlertCategoryCollateral, "")
 	}
 	if sos == obligationFailed {
-		// Remove the obligation statistics as potential risk and income.
 		h.log.Printf("Missed storage proof. Revenue would have been %v.\n", so.ContractCost.Add(so.PotentialStorageRevenue).Add(so.PotentialDownloadRevenue).Add(so.PotentialUploadRevenue))
-		h.financialMetrics.PotentialContractCompensation = h.financialMetrics.PotentialContractCompensation.Sub(so.ContractCost)
-		h.financialMetrics.LockedStorageCollateral = h.financialMetrics.LockedStorageCollateral.Sub(so.LockedCollateral)
-		h.financialMetrics.PotentialStorageRevenue = h.financialMetrics.PotentialStorageRevenue.Sub(so.PotentialStorageRevenue)
-		h.financialMetrics.PotentialDownloadBandwidthRevenue = h.financialMetrics.PotentialDownloadBandwidthRevenue.Sub(so.PotentialDownloadRevenue)
-		h.financialMetrics.PotentialUploadBandwidthRevenue = h.financialMetrics.PotentialUploadBandwidthRevenue.Sub(so.PotentialUploadRevenue)
-		h.financialMetrics.RiskedStorageCollateral = h.financialMetrics.RiskedStorageCollateral.Sub(so.RiskedCollateral)
 
-		// Add the obligation statistics as loss.
-		h.financialMetrics.LostStorageCollateral = h.financialMetrics.LostStorageCollateral.Add(so.RiskedCollateral)
-		h.financialMetrics.LostRevenue = h.financialMetrics.LostRevenue.Add(so.ContractCost).Add(so.PotentialStorageRevenue).Add(so.PotentialDownloadRevenue).Add(so.PotentialUploadRevenue)
+		// Record why the storage proof was missed.
+		so.ProofFailure = so.failureReason()
+		h.log.Printf("Storage proof for %v was missed: %v %v\n", so.id(), so.ProofFailure, so.ProofError)
+	}
+	if sos == obligationFailed && so.ProofFailure != modules.ProofFailureExpiredByHost {
+		// Alert the operator, unless the operator expired the obligation.
+		h.raiseAlert(modules.HostAlert{
+			Severity: modules.HostAlertSeverityCritical,
+			Category: modules.HostAlertCategoryStorageProof,
+			Message:  "the host missed a storage proof",
+			Cause:    so.id().String(),
+		})
+		if !so.RiskedCollateral.IsZero() {
+			h.raiseAlert(modules.HostAlert{
+				Severity: modules.HostAlertSeverityCritical,
+				Category: modules.HostAlertCategoryCollateral,
+				Message:  fmt.Sprintf("the host lost %v of collateral", so.RiskedCollateral),
+				Cause:    so.id().String(),
+			})
+		}
 	}
 
 	// Update the storage obligation to be finalized but still in-database. The
 	// obligation status is updated so that the user can see how the obligation
 	// ended up, and the sector roots are removed because they are large
 	// objects with little purpose once storage proofs are no longer needed.
-	h.financialMetrics.ContractCount--
+	// The revenue of the obligation is only moved out of the potential revenue
+	// once the new status has been committed, and the settings file is saved
+	// right away so that a restart does not replay the move.
 	so.ObligationStatus = sos
 	so.SectorRoots = nil
-	return h.db.Update(func(tx *bolt.Tx) error {
+	so.ResolutionHeight = h.blockHeight
+	so.RevenueSequence = h.revenueSequence + 1
+	err := h.db.Update(func(tx *bolt.Tx) error {
+		err := queueSectorDeletions(tx, sectorRoots)
+		if err != nil {
+			return err
+		}
+		return putStorageObligation(tx, so)
+	})
+	if err != nil {
+		return err
+	}
+	h.revenueSequence = so.RevenueSequence
+	applyResolution(&h.financialMetrics, so)
+	h.updateCollateralBudgetAlert()
+	err = h.saveSync()
+	if err != nil {
+		h.log.Println("Error saving the host after resolving a storage obligation:", err)
+	}
+	h.queuedSectorDeletions += uint64(len(sectorRoots))
+	select {
+	case h.sectorDeletionsQueued <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// managedRecordProofFailure records the stage and the error of a failed
+// attempt at a storage proof in the storage obligation, so that the failure
+// can be explained if the storage proof ends up being missed.
+func (h *Host) managedRecordProofFailure(soid types.FileContractID, reason modules.StorageProofFailureReason, proofErr error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	err := h.db.Update(func(tx *bolt.Tx) error {
+		so, err := getStorageObligation(tx, soid)
+		if err != nil {
+			return err
+		}
+		so.ProofFailure = reason
+		so.ProofError = proofErr.Error()
 		return putStorageObligation(tx, so)
 	})
+	if err != nil {
+		h.log.Println("Error recording storage proof failure:", err)
+	}
+}
+
+// managedFundingFailure returns the failure reason for an error that occurred
+// while funding or signing a storage proof transaction.
+func (h *Host) managedFundingFailure() modules.StorageProofFailureReason {
+	if !h.wallet.Unlocked() {
+		return modules.ProofFailureWalletLocked
+	}
+	return modules.ProofFailureTransactionRejected
 }
 
 // threadedHandleActionItem will look at a storage obligation and determine
@@ -620,7 +680,11 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID) {
 		return err
 	})
 	h.mu.RUnlock()
-	if err != nil {
+	if err == errNoStorageObligation {
+		// The obligation was resolved and then pruned, the action item is
+		// left over from before it was resolved.
+		return
+	} else if err != nil {
 		h.log.Println("Could not get storage obligation:", err)
 		return
 	}
@@ -631,6 +695,12 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID) {
 		return
 	}
 
+	// Quarantined obligations, and obligations that violate an invariant, are
+	// left to the operator.
+	if !h.managedCheckObligation(soid, so, true) {
+		return
+	}
+
 	// Check whether the file contract has been seen. If not, resubmit and
 	// queue another action item. Check for death. (signature should have a
 	// kill height)
@@ -733,6 +803,8 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID) {
 		err = h.tpool.AcceptTransactionSet(feeAddedRevisionTransactionSet)
 		if err != nil {
 			h.log.Println("Error submitting transaction to transaction pool", err)
+		} else {
+			h.managedRecordSpending(modules.SpendingPurposeRevisionFee, so.id(), requiredFee, feeAddedRevisionTransactionSet)
 		}
 		so.TransactionFeesAdded = so.TransactionFeesAdded.Add(requiredFee)
 		// return
@@ -743,6 +815,19 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID) {
 	if !so.ProofConfirmed && blockHeight >= so.expiration()+resubmissionTimeout {
 		h.log.Debugln("Host is attempting a storage proof for", so.id())
 
+		// An obligation that was reopened by a reorg no longer has its
+		// sectors, but the reverted storage proof may be confirmed again
+		// before the window closes. Check again once the window has closed.
+		if len(so.SectorRoots) == 0 && so.RevertedStatus == obligationSucceeded && so.proofDeadline() >= blockHeight {
+			h.mu.Lock()
+			err := h.queueActionItem(so.proofDeadline()+1, so.id())
+			h.mu.Unlock()
+			if err != nil {
+				h.log.Println("Error queuing action item:", err)
+			}
+			return
+		}
+
 		// If the window has closed, the host has failed and the obligation can
 		// be removed.
 		if so.proofDeadline() < blockHeight || len(so.SectorRoots) == 0 {
@@ -761,6 +846,7 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID) {
 		segmentIndex, err := h.cs.StorageProofSegment(so.id())
 		if err != nil {
 			h.log.Debugln("Host got an error when fetching a storage proof segment:", err)
+			h.managedRecordProofFailure(so.id(), modules.ProofFailureTransactionRejected, err)
 			return
 		}
 		sectorIndex := segmentIndex / (modules.SectorSize / crypto.SegmentSize)
@@ -769,6 +855,7 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID) {
 		sectorBytes, err := h.ReadSector(sectorRoot)
 		if err != nil {
 			h.log.Debugln(err)
+			h.managedRecordProofFailure(so.id(), modules.ProofFailureSectorUnreadable, err)
 			return
 		}
 
@@ -777,11 +864,7 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID) {
 		base, cachedHashSet := crypto.MerkleProof(sectorBytes, sectorSegment)
 
 		// Using the sector, build a cached root.
-		log2SectorSize := uint64(0)
-		for 1<<log2SectorSize < (modules.SectorSize / crypto.SegmentSize) {
-			log2SectorSize++
-		}
-		ct := crypto.NewCachedTree(log2SectorSize)
+		ct := newSectorRootsTree()
 		ct.SetIndex(segmentIndex)
 		for _, root := range so.SectorRoots {
 			ct.Push(root)
@@ -800,6 +883,7 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID) {
 			// There's no sense submitting the storage proof if the fee is more
 			// than the anticipated revenue.
 			h.log.Debugln("Host not submitting storage proof due to a value that does not sufficiently exceed the fee cost")
+			h.managedRecordProofFailure(so.id(), modules.ProofFailureFeeTooHigh, fmt.Errorf("fee of %v per byte exceeds obligation value of %v", feeRecommendation, so.value()))
 			return
 		}
 		txnSize := uint64(len(encoding.Marshal(sp)) + 300)
@@ -807,6 +891,7 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID) {
 		err = builder.FundSiacoins(requiredFee)
 		if err != nil {
 			h.log.Println("Host error when funding a storage proof transaction fee:", err)
+			h.managedRecordProofFailure(so.id(), h.managedFundingFailure(), err)
 			return
 		}
 		builder.AddMinerFee(requiredFee)
@@ -814,14 +899,18 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID) {
 		storageProofSet, err := builder.Sign(true)
 		if err != nil {
 			h.log.Println("Host error when signing the storage proof transaction:", err)
+			h.managedRecordProofFailure(so.id(), h.managedFundingFailure(), err)
 			return
 		}
 		err = h.tpool.AcceptTransactionSet(storageProofSet)
 		if err != nil {
 			h.log.Println("Host unable to submit storage proof transaction to transaction pool:", err)
+			h.managedRecordProofFailure(so.id(), modules.ProofFailureTransactionRejected, err)
 			return
 		}
+		h.managedRecordSpending(modules.SpendingPurposeStorageProof, so.id(), requiredFee, storageProofSet)
 		so.TransactionFeesAdded = so.TransactionFeesAdded.Add(requiredFee)
+		so.ProofConstructed = true
 
 		// Queue another action item to check whether the storage proof
 		// got confirmed.
@@ -833,28 +922,83 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID) {
 		}
 	}
 
-	// Save the storage obligation to account for any fee changes.
+	// Save the storage obligation to account for any fee changes. The
+	// obligation is read again, because the consensus set may have confirmed
+	// or reverted the storage proof since it was fetched.
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	err = h.db.Update(func(tx *bolt.Tx) error {
-		soBytes, err := json.Marshal(so)
+		current, err := getStorageObligation(tx, soid)
 		if err != nil {
 			return err
 		}
-		return tx.Bucket(bucketStorageObligations).Put(soid[:], soBytes)
+		current.TransactionFeesAdded = so.TransactionFeesAdded
+		current.ProofConstructed = so.ProofConstructed
+		so = current
+		return putStorageObligation(tx, so)
 	})
 	if err != nil {
 		h.log.Println("Error updating the storage obligations", err)
+		return
 	}
 
 	// Check if all items have succeeded with the required confirmations. Report
 	// success, delete the obligation.
-	if so.ProofConfirmed && blockHeight >= so.proofDeadline() {
+	if so.ObligationStatus == obligationUnresolved && so.ProofConfirmed && blockHeight >= so.proofDeadline() {
 		h.log.Println("file contract complete, id", so.id())
-		h.mu.Lock()
-		h.removeStorageObligation(so, obligationSucceeded)
-		h.mu.Unlock()
+		err = h.removeStorageObligation(so, obligationSucceeded)
+		if err != nil {
+			h.log.Println("Error removing storage obligation:", err)
+		}
 	}
 }
 
+// ExpireContract terminates the storage obligation of a file contract before
+// the end of the contract, for example to comply with a legal request. The
+// obligation is marked as failed and its sectors are queued for removal. The
+// host cannot return collateral to itself without the renter's cooperation,
+// the collateral that is locked but not at risk is returned through the
+// missed proof outputs of the contract, and the risked collateral is lost.
+func (h *Host) ExpireContract(soid types.FileContractID, reason string) error {
+	if reason == "" {
+		return errNoExpirationReason
+	}
+	err := h.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer h.tg.Done()
+
+	err = h.managedTryLockStorageObligation(soid)
+	if err != nil {
+		return err
+	}
+	defer h.managedUnlockStorageObligation(soid)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var so storageObligation
+	err = h.db.View(func(tx *bolt.Tx) error {
+		so, err = getStorageObligation(tx, soid)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if so.ObligationStatus != obligationUnresolved {
+		return errObligationResolved
+	}
+	if so.quarantined() {
+		// Quarantined obligations are failed with
+		// ResolveQuarantinedObligation, which checks that their file contract
+		// can be failed.
+		return errObligationQuarantined
+	}
+	h.log.Printf("Expiring storage obligation %v at the request of the operator: %v\n", soid, reason)
+	so.ExpirationReason = reason
+	return h.removeStorageObligation(so, obligationFailed)
+}
+
 // StorageObligations fetches the set of storage obligations in the host and
 // returns metadata on them.
 func (h *Host) StorageObligations() (sos []modules.StorageObligation) {
@@ -879,6 +1023,11 @@ func (h *Host) StorageObligations() (sos []modules.StorageObligation) {
 				ProofConfirmed:      so.ProofConfirmed,
 				ObligationStatus:    uint64(so.ObligationStatus),
 			}
+			if so.ObligationStatus == obligationFailed {
+				mso.FailureReason = so.ProofFailure
+				mso.FailureError = so.ProofError
+				mso.ExpirationReason = so.ExpirationReason
+			}
 			sos = append(sos, mso)
 			return nil
 		})
@@ -893,3 +1042,38 @@ func (h *Host) StorageObligations() (sos []modules.StorageObligation) {
 
 	return sos
 }
+
+// StorageProofDiagnostics returns the number of storage obligations that the
+// host has failed, grouped by the reason that the storage proof was missed.
+// Obligations that failed before failure reasons were recorded, and
+// obligations that have been pruned, are only included in the total.
+func (h *Host) StorageProofDiagnostics() modules.HostStorageProofDiagnostics {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	diagnostics := modules.HostStorageProofDiagnostics{
+		FailedObligations: h.financialMetrics.PrunedFailedObligations,
+		FailureReasons:    make(map[modules.StorageProofFailureReason]uint64),
+	}
+	err := h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+			var so storageObligation
+			err := json.Unmarshal(soBytes, &so)
+			if err != nil {
+				return build.ExtendErr("unable to unmarshal storage obligation:", err)
+			}
+			if so.ObligationStatus != obligationFailed {
+				return nil
+			}
+			diagnostics.FailedObligations++
+			if so.ProofFailure != "" {
+				diagnostics.FailureReasons[so.ProofFailure]++
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		h.log.Println(build.ExtendErr("database failed to provide storage obligations:", err))
+	}
+	return diagnostics
+}
@@ -6,10 +6,16 @@ package host
 // correctly.
 
 import (
+	"errors"
+	"fmt"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
 	"github.com/NebulousLabs/Sia/types"
 	"github.com/NebulousLabs/fastrand"
 
@@ -735,3 +741,193 @@ func TestAutoRevisionSubmission(t *testing.T) {
 		t.Fatal("the host should be reporting revenue after a successful storage proof")
 	}
 }
+
+// TestStorageObligationRevenueReorg checks that the revenue of a storage
+// obligation is realized exactly once when a reorg reverts and reapplies the
+// block with its storage proof, and when the host restarts with financial
+// metrics that were saved before the revenue was realized.
+func TestStorageObligationRevenueReorg(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Form a contract with a single sector.
+	so, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedLockStorageObligation(so.id())
+	err = ht.host.managedAddStorageObligation(so)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sectorRoot, sectorData := randSector()
+	so.SectorRoots = []crypto.Hash{sectorRoot}
+	sectorCost := types.SiacoinPrecision.Mul64(550)
+	so.PotentialStorageRevenue = so.PotentialStorageRevenue.Add(sectorCost)
+	validPayouts, missedPayouts := so.payouts()
+	validPayouts[0].Value = validPayouts[0].Value.Sub(sectorCost)
+	validPayouts[1].Value = validPayouts[1].Value.Add(sectorCost)
+	missedPayouts[0].Value = missedPayouts[0].Value.Sub(sectorCost)
+	missedPayouts[1].Value = missedPayouts[1].Value.Add(sectorCost)
+	revisionSet := []types.Transaction{{
+		FileContractRevisions: []types.FileContractRevision{{
+			ParentID:          so.id(),
+			UnlockConditions:  types.UnlockConditions{},
+			NewRevisionNumber: 1,
+
+			NewFileSize:           uint64(len(sectorData)),
+			NewFileMerkleRoot:     sectorRoot,
+			NewWindowStart:        so.expiration(),
+			NewWindowEnd:          so.proofDeadline(),
+			NewValidProofOutputs:  validPayouts,
+			NewMissedProofOutputs: missedPayouts,
+			NewUnlockHash:         types.UnlockConditions{}.UnlockHash(),
+		}},
+	}}
+	err = ht.host.modifyStorageObligation(so, nil, []crypto.Hash{sectorRoot}, [][]byte{sectorData})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedUnlockStorageObligation(so.id())
+	err = ht.tpool.AcceptTransactionSet(revisionSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	soid := so.id()
+
+	// checkRevenue checks that the revenue of the obligation is counted
+	// exactly once, as realized revenue if the obligation has succeeded and as
+	// potential revenue otherwise. The action items of the host run in the
+	// background, so the check is retried.
+	checkRevenue := func(status storageObligationStatus) {
+		t.Helper()
+		err := build.Retry(50, 100*time.Millisecond, func() error {
+			var so storageObligation
+			err := ht.host.db.View(func(tx *bolt.Tx) error {
+				so, err = getStorageObligation(tx, soid)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			if so.ObligationStatus != status {
+				return fmt.Errorf("expected obligation status %v, got %v", status, so.ObligationStatus)
+			}
+			fm := ht.host.FinancialMetrics()
+			realized, potential, contracts := sectorCost, types.ZeroCurrency, uint64(0)
+			if status == obligationUnresolved {
+				realized, potential, contracts = potential, realized, 1
+			}
+			if !fm.StorageRevenue.Equals(realized) || !fm.PotentialStorageRevenue.Equals(potential) {
+				return fmt.Errorf("revenue does not balance: realized %v, potential %v", fm.StorageRevenue, fm.PotentialStorageRevenue)
+			}
+			if !fm.LostRevenue.IsZero() || fm.ContractCount != contracts {
+				return fmt.Errorf("unexpected lost revenue %v or contract count %v", fm.LostRevenue, fm.ContractCount)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Mine until the storage proof is confirmed, and then until the host
+	// finalizes the obligation, keeping the blocks from the one with the proof
+	// onwards. The host submits the proof in the background once the proof
+	// window is open, so the proof is waited for before the next block is
+	// mined.
+	var blocks []types.Block
+	var proofMined bool
+	for ht.host.blockHeight <= so.proofDeadline() {
+		if !proofMined && ht.host.blockHeight >= so.expiration()+resubmissionTimeout {
+			err := build.Retry(50, 100*time.Millisecond, func() error {
+				for _, txn := range ht.tpool.TransactionList() {
+					if len(txn.StorageProofs) > 0 {
+						return nil
+					}
+				}
+				return errors.New("storage proof is not in the transaction pool")
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		b, err := ht.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ht.host.tg.Flush()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, txn := range b.Transactions {
+			proofMined = proofMined || len(txn.StorageProofs) > 0
+		}
+		if proofMined {
+			blocks = append(blocks, b)
+		}
+	}
+	if len(blocks) == 0 {
+		t.Fatal("storage proof was not confirmed")
+	}
+	checkRevenue(obligationSucceeded)
+
+	// Revert the blocks from the one with the storage proof onwards. The
+	// revenue of the obligation should be potential revenue again.
+	ht.host.mu.RLock()
+	recentChange := ht.host.recentChange
+	ht.host.mu.RUnlock()
+	reverted := make([]types.Block, len(blocks))
+	for i := range blocks {
+		reverted[i] = blocks[len(blocks)-1-i]
+	}
+	ht.host.ProcessConsensusChange(modules.ConsensusChange{ID: recentChange, RevertedBlocks: reverted})
+	checkRevenue(obligationUnresolved)
+	ht.host.mu.RLock()
+	unrealized := ht.host.persistData()
+	ht.host.mu.RUnlock()
+
+	// Reapply the blocks. The revenue should be realized again, but only once.
+	ht.host.ProcessConsensusChange(modules.ConsensusChange{ID: recentChange, AppliedBlocks: blocks})
+	checkRevenue(obligationSucceeded)
+
+	// Emulate a crash between committing the obligation and saving the
+	// settings file by restoring the financial metrics from before the
+	// revenue was realized. The host should replay the transition on startup.
+	ht.host.mu.RLock()
+	p := ht.host.persistData()
+	ht.host.mu.RUnlock()
+	err = ht.host.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.FinancialMetrics = unrealized.FinancialMetrics
+	p.RevenueSequence = unrealized.RevenueSequence
+	err = persist.SaveJSON(persistMetadata, p, filepath.Join(ht.host.persistDir, settingsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host, err = New(ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRevenue(obligationSucceeded)
+
+	// Restarting again should not replay the transition a second time.
+	err = ht.host.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host, err = New(ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRevenue(obligationSucceeded)
+}
@@ -1,9 +1,16 @@
 package host
 
 import (
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
 )
 
 // TestStorageObligationID checks that the return function of the storage
@@ -80,3 +87,161 @@ func TestStorageObligationID(t *testing.T) {
 		t.Error("id function of storage obligation incorrect for file contracts with dependencies")
 	}
 }
+
+// TestStorageObligationFailureReason checks that failed obligations are given
+// the failure reason that matches how far the storage proof got.
+func TestStorageObligationFailureReason(t *testing.T) {
+	t.Parallel()
+	roots := []crypto.Hash{{1}}
+	tests := []struct {
+		so     storageObligation
+		reason modules.StorageProofFailureReason
+	}{
+		{storageObligation{}, modules.ProofFailureEmptyContract},
+		{storageObligation{SectorRoots: roots}, modules.ProofFailureHostOffline},
+		{storageObligation{SectorRoots: roots, ProofFailure: modules.ProofFailureSectorUnreadable}, modules.ProofFailureSectorUnreadable},
+		{storageObligation{SectorRoots: roots, ProofFailure: modules.ProofFailureWalletLocked, ProofConstructed: true}, modules.ProofFailureUnconfirmed},
+		{storageObligation{SectorRoots: roots, ProofConstructed: true, ProofReverted: true}, modules.ProofFailureReorged},
+		{storageObligation{SectorRoots: roots, ProofConstructed: true, ProofReverted: true, ProofConfirmed: true}, modules.ProofFailureUnconfirmed},
+		{storageObligation{ExpirationReason: "dmca"}, modules.ProofFailureExpiredByHost},
+		{storageObligation{SectorRoots: roots, ProofConstructed: true, ExpirationReason: "dmca"}, modules.ProofFailureExpiredByHost},
+	}
+	for i, test := range tests {
+		if reason := test.so.failureReason(); reason != test.reason {
+			t.Errorf("test %v: expected %q, got %q", i, test.reason, reason)
+		}
+	}
+}
+
+// TestStorageProofDiagnostics checks that failed obligations are reported with
+// their failure reasons in the storage obligation listing and the storage
+// proof diagnostics.
+func TestStorageProofDiagnostics(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := blankHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Put a succeeded obligation, a failed obligation from before failure
+	// reasons were recorded, and several failed obligations into the
+	// database.
+	obligations := []storageObligation{
+		{ObligationStatus: obligationSucceeded},
+		{ObligationStatus: obligationFailed},
+		{ObligationStatus: obligationFailed, ProofFailure: modules.ProofFailureWalletLocked, ProofError: "wallet must be unlocked"},
+		{ObligationStatus: obligationFailed, ProofFailure: modules.ProofFailureWalletLocked},
+		{ObligationStatus: obligationFailed, ProofFailure: modules.ProofFailureReorged},
+	}
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		for i, so := range obligations {
+			so.OriginTransactionSet = []types.Transaction{{
+				FileContracts: []types.FileContract{{FileSize: uint64(i)}},
+			}}
+			if err := putStorageObligation(tx, so); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diagnostics := ht.host.StorageProofDiagnostics()
+	if diagnostics.FailedObligations != 4 {
+		t.Error("expected 4 failed obligations, got", diagnostics.FailedObligations)
+	}
+	if len(diagnostics.FailureReasons) != 2 || diagnostics.FailureReasons[modules.ProofFailureWalletLocked] != 2 || diagnostics.FailureReasons[modules.ProofFailureReorged] != 1 {
+		t.Error("wrong failure reasons:", diagnostics.FailureReasons)
+	}
+
+	var withError int
+	for _, so := range ht.host.StorageObligations() {
+		if so.ObligationStatus != uint64(obligationFailed) && so.FailureReason != "" {
+			t.Error("obligation that did not fail has a failure reason")
+		}
+		if so.FailureError == "wallet must be unlocked" {
+			withError++
+		}
+	}
+	if withError != 1 {
+		t.Error("failure error was not listed")
+	}
+}
+
+// TestExpireContract checks that the host operator can terminate a storage
+// obligation early, and that the sectors of the obligation are removed.
+func TestExpireContract(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Add a storage obligation with a sector.
+	so, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedLockStorageObligation(so.id())
+	err = ht.host.managedAddStorageObligation(so)
+	ht.host.managedUnlockStorageObligation(so.id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sectorRoot, sectorData := randSector()
+	so.SectorRoots = []crypto.Hash{sectorRoot}
+	ht.host.managedLockStorageObligation(so.id())
+	err = ht.host.modifyStorageObligation(so, nil, []crypto.Hash{sectorRoot}, [][]byte{sectorData})
+	ht.host.managedUnlockStorageObligation(so.id())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ht.host.ExpireContract(so.id(), ""); err != errNoExpirationReason {
+		t.Fatal("expected errNoExpirationReason, got", err)
+	}
+	if err := ht.host.ExpireContract(types.FileContractID{1}, "dmca"); err != errNoStorageObligation {
+		t.Fatal("expected errNoStorageObligation, got", err)
+	}
+	if err := ht.host.ExpireContract(so.id(), "dmca"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.ExpireContract(so.id(), "dmca"); err != errObligationResolved {
+		t.Fatal("expected errObligationResolved, got", err)
+	}
+
+	// The obligation should be failed with the reason of the operator, and
+	// no alerts should be raised.
+	sos := ht.host.StorageObligations()
+	if len(sos) != 1 {
+		t.Fatal("expected one storage obligation, got", len(sos))
+	}
+	if sos[0].ObligationStatus != uint64(obligationFailed) || sos[0].FailureReason != modules.ProofFailureExpiredByHost || sos[0].ExpirationReason != "dmca" {
+		t.Error("expired obligation reported incorrectly:", sos[0])
+	}
+	if alerts := ht.host.Alerts(); len(alerts) != 0 {
+		t.Error("expiring an obligation raised alerts:", alerts)
+	}
+	if fm := ht.host.FinancialMetrics(); fm.ContractCount != 0 || !fm.LockedStorageCollateral.IsZero() {
+		t.Error("financial metrics were not updated:", fm.ContractCount, fm.LockedStorageCollateral)
+	}
+	err = build.Retry(100, 50*time.Millisecond, func() error {
+		if used := usedStorage(ht.host); used != 0 {
+			return errors.New("sector of the expired obligation was not removed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,216 @@
+package host
+
+// tls.go implements the optional TLS support of the host. If TLSCertFile and
+// TLSKeyFile are set, connections that start with a TLS handshake are upgraded
+// to TLS before the RPC is read. Renters authenticate the host by pinning its
+// certificate rather than through a certificate authority, so a self-signed
+// certificate is generated if the configured files do not exist. Connections
+// that do not start with a TLS handshake are served as before, so that renters
+// that have not pinned the certificate of the host can still connect.
+//
+// Renters that have not pinned the certificate upgrade a versioned session to
+// TLS with RPCUpgradeTLS instead, which the host advertises while TLS is
+// enabled. The host signs the hash of its certificate with its secret key
+// once the handshake is complete, which authenticates the host to renters
+// that only know its public key. If TLSRequired is set, the host refuses
+// every RPC other than the settings RPC on connections that are not
+// encrypted.
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+const (
+	// tlsCertValidity is how long a generated TLS certificate is valid for.
+	// Renters pin the certificate itself, so the validity only needs to
+	// outlast the use of the certificate.
+	tlsCertValidity = 10 * 365 * 24 * time.Hour
+
+	// tlsHandshakeRecordType is the first byte of a TLS handshake. The first
+	// byte of an unencrypted RPC is the low byte of the length prefix of the
+	// RPC specifier, which is never this value.
+	tlsHandshakeRecordType = 0x16
+)
+
+var (
+	// errTLSFileMissing is returned if only one of the TLS certificate and
+	// key files exists, in which case a new certificate is not generated to
+	// avoid overwriting the existing file.
+	errTLSFileMissing = errors.New("only one of the TLS certificate and key files exists")
+
+	// errTLSDisabled is returned if a renter calls RPCUpgradeTLS after TLS
+	// has been disabled.
+	errTLSDisabled = errors.New("TLS is disabled")
+)
+
+// tlsHandshakeTimeout is the amount of time that a renter has to start and
+// complete the TLS handshake of a connection.
+var tlsHandshakeTimeout = build.Select(build.Var{
+	Dev:      30 * time.Second,
+	Standard: 30 * time.Second,
+	Testing:  5 * time.Second,
+}).(time.Duration)
+
+// peekedConn is a net.Conn whose first bytes have been peeked by a buffered
+// reader. Reads are served from the reader, so the peeked bytes are not lost.
+type peekedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// Read implements io.Reader.
+func (c *peekedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// generateTLSCert generates a self-signed ECDSA certificate and its key,
+// encoded as PEM.
+func generateTLSCert() (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "Sia host"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(tlsCertValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// loadTLSConfig returns the TLS configuration for the certificate and key at
+// certFile and keyFile, generating a self-signed certificate if neither file
+// exists. If both paths are empty, TLS is disabled and a nil configuration is
+// returned.
+func (h *Host) loadTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	certPEM, certErr := h.dependencies.readFile(certFile)
+	keyPEM, keyErr := h.dependencies.readFile(keyFile)
+	if os.IsNotExist(certErr) && os.IsNotExist(keyErr) {
+		var err error
+		certPEM, keyPEM, err = generateTLSCert()
+		if err != nil {
+			return nil, build.ExtendErr("unable to generate TLS certificate", err)
+		}
+		if err := h.dependencies.writeFile(keyFile, keyPEM, 0600); err != nil {
+			return nil, build.ExtendErr("unable to write TLS key", err)
+		}
+		if err := h.dependencies.writeFile(certFile, certPEM, 0644); err != nil {
+			return nil, build.ExtendErr("unable to write TLS certificate", err)
+		}
+		h.log.Println("Generated a self-signed TLS certificate at", certFile)
+	} else if os.IsNotExist(certErr) || os.IsNotExist(keyErr) {
+		return nil, errTLSFileMissing
+	} else if err := build.ComposeErrors(certErr, keyErr); err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, build.ExtendErr("invalid TLS certificate or key", err)
+	}
+	return &tls.Config{
+		Certificates:             []tls.Certificate{cert},
+		MinVersion:               tls.VersionTLS12,
+		CipherSuites:             modules.TLSCipherSuites,
+		PreferServerCipherSuites: true,
+	}, nil
+}
+
+// managedUpgradeConn upgrades conn to TLS if TLS is enabled and the renter
+// starts the connection with a TLS handshake. Otherwise conn is returned
+// unchanged, apart from the peeked byte being buffered.
+func (h *Host) managedUpgradeConn(conn net.Conn) (net.Conn, error) {
+	h.mu.RLock()
+	tlsConfig := h.tlsConfig
+	h.mu.RUnlock()
+	if tlsConfig == nil {
+		return conn, nil
+	}
+
+	err := conn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	if err != nil {
+		return nil, err
+	}
+	pc := &peekedConn{Conn: conn, r: bufio.NewReader(conn)}
+	first, err := pc.r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if first[0] != tlsHandshakeRecordType {
+		return pc, nil
+	}
+	tlsConn := tls.Server(pc, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, build.ExtendErr("TLS handshake failed", err)
+	}
+	return tlsConn, nil
+}
+
+// upgradeSessionTLS performs the host's side of RPCUpgradeTLS on conn, using
+// config for the TLS handshake and sk to sign the certificate of config.
+func upgradeSessionTLS(conn net.Conn, config *tls.Config, sk crypto.SecretKey) (net.Conn, error) {
+	tlsConn := tls.Server(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, build.ExtendErr("TLS handshake failed", err)
+	}
+	certHash := modules.HostTLSCertHash(config.Certificates[0].Certificate[0])
+	err := encoding.WriteObject(tlsConn, crypto.SignHash(certHash, sk))
+	if err != nil {
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// managedRPCUpgradeTLS upgrades a versioned session on conn to TLS.
+func (h *Host) managedRPCUpgradeTLS(conn net.Conn) (net.Conn, error) {
+	h.mu.RLock()
+	tlsConfig := h.tlsConfig
+	sk := h.secretKey
+	h.mu.RUnlock()
+	if tlsConfig == nil {
+		return nil, errTLSDisabled
+	}
+
+	err := conn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	if err != nil {
+		return nil, err
+	}
+	return upgradeSessionTLS(conn, tlsConfig, sk)
+}
@@ -0,0 +1,321 @@
+package host
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestHostTLS checks that the host generates a self-signed certificate when
+// TLS is enabled, serves RPCs over TLS to renters that pin the certificate,
+// and still serves RPCs to renters that connect unencrypted.
+func TestHostTLS(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Setting only one of the files should be rejected.
+	certFile := filepath.Join(ht.persistDir, "tls.crt")
+	keyFile := filepath.Join(ht.persistDir, "tls.key")
+	settings := ht.host.InternalSettings()
+	settings.TLSCertFile = certFile
+	if err := ht.host.SetInternalSettings(settings); err == nil {
+		t.Fatal("expected an error when only the certificate file is set")
+	}
+	settings.TLSKeyFile = keyFile
+	if err := ht.host.SetInternalSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		t.Fatal("certificate was not generated:", err)
+	}
+	if _, err := os.Stat(keyFile); err != nil {
+		t.Fatal("key was not generated:", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		t.Fatal("generated certificate is not PEM encoded")
+	}
+
+	// call calls the settings RPC on conn and returns the response.
+	call := func(conn net.Conn) ([]byte, error) {
+		defer conn.Close()
+		if err := encoding.WriteObject(conn, modules.RPCSettings); err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(conn)
+	}
+	addr := string(ht.host.ExternalSettings().NetAddress)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := call(conn); err != nil || len(resp) == 0 {
+		t.Fatal("host did not respond to an unencrypted settings RPC:", err)
+	}
+
+	// dialTLS connects to the host over TLS, accepting only the certificate
+	// pinned.
+	dialTLS := func(pinned []byte) (net.Conn, error) {
+		return tls.Dial("tcp", addr, &tls.Config{
+			InsecureSkipVerify: true,
+			VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				if len(rawCerts) == 0 || !bytes.Equal(rawCerts[0], pinned) {
+					return errors.New("certificate does not match the pin")
+				}
+				return nil
+			},
+		})
+	}
+	tlsConn, err := dialTLS(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := call(tlsConn); err != nil || len(resp) == 0 {
+		t.Fatal("host did not respond to a settings RPC over TLS:", err)
+	}
+	if _, err := dialTLS([]byte("wrong certificate")); err == nil {
+		t.Fatal("expected the handshake to fail for the wrong pinned certificate")
+	}
+
+	// The certificate should be reused when the host is restarted.
+	if err := ht.host.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ht.host, err = New(ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newPEM, err := ioutil.ReadFile(certFile); err != nil || !bytes.Equal(newPEM, certPEM) {
+		t.Fatal("certificate was regenerated on restart:", err)
+	}
+	if ht.host.tlsConfig == nil {
+		t.Fatal("TLS was not enabled after the restart")
+	}
+}
+
+// TestHostSessionTLS checks that renters can upgrade a versioned session to
+// TLS without pinning the certificate of the host, and that the host refuses
+// unencrypted RPCs other than the settings RPC in secure-only mode.
+func TestHostSessionTLS(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+	addr := string(ht.host.ExternalSettings().NetAddress)
+	dial := func() net.Conn {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	hasUpgrade := func(hv modules.RPCVersions) bool {
+		for _, rpc := range hv.RPCs {
+			if rpc == modules.RPCUpgradeTLS {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Without TLS, the upgrade is not advertised, and secure-only mode
+	// cannot be enabled.
+	conn := dial()
+	if _, hv, err := modules.NegotiateRPCVersion(conn, modules.SupportedRPCVersions); err != nil || hasUpgrade(hv) {
+		t.Fatal("host without TLS advertised RPCUpgradeTLS:", err)
+	}
+	conn.Close()
+	settings := ht.host.InternalSettings()
+	settings.TLSRequired = true
+	if err := ht.host.SetInternalSettings(settings); err == nil {
+		t.Fatal("expected an error when TLSRequired is set without TLS files")
+	}
+	settings.TLSCertFile = filepath.Join(ht.persistDir, "tls.crt")
+	settings.TLSKeyFile = filepath.Join(ht.persistDir, "tls.key")
+	if err := ht.host.SetInternalSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	// Upgrade a versioned session and call the settings RPC over TLS.
+	conn = dial()
+	_, hv, err := modules.NegotiateRPCVersion(conn, modules.SupportedRPCVersions)
+	if err != nil {
+		t.Fatal(err)
+	} else if !hasUpgrade(hv) {
+		t.Fatal("host with TLS did not advertise RPCUpgradeTLS")
+	}
+	tlsConn, err := modules.UpgradeSessionTLS(conn, ht.host.publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := encoding.WriteObject(tlsConn, modules.RPCSettings); err != nil {
+		t.Fatal(err)
+	}
+	var hes modules.HostExternalSettings
+	var pk crypto.PublicKey
+	copy(pk[:], ht.host.publicKey.Key)
+	if err := crypto.ReadSignedObject(tlsConn, &hes, modules.NegotiateMaxHostExternalSettingsLen, pk); err != nil {
+		t.Fatal("settings RPC over TLS failed:", err)
+	}
+	tlsConn.Close()
+
+	// The upgrade must fail if the certificate is not signed by the expected
+	// key.
+	conn = dial()
+	if _, _, err := modules.NegotiateRPCVersion(conn, modules.SupportedRPCVersions); err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey := crypto.GenerateKeyPair()
+	wrongKey := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: otherKey[:]}
+	if _, err := modules.UpgradeSessionTLS(conn, wrongKey); err != modules.ErrHostCertSignature {
+		t.Fatal("expected ErrHostCertSignature, got", err)
+	}
+	conn.Close()
+
+	// In secure-only mode, the settings RPC is still served unencrypted, but
+	// other RPCs are refused.
+	conn = dial()
+	if resp, err := func() ([]byte, error) {
+		defer conn.Close()
+		if err := encoding.WriteObject(conn, modules.RPCSettings); err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(conn)
+	}(); err != nil || len(resp) == 0 {
+		t.Fatal("host did not serve the settings RPC unencrypted:", err)
+	}
+	conn = dial()
+	if _, _, err := modules.NegotiateRPCVersion(conn, modules.SupportedRPCVersions); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoding.WriteObject(conn, modules.RPCDownload); err != nil {
+		t.Fatal(err)
+	}
+	if err := modules.ReadNegotiationAcceptance(conn); err == nil || err.Error() != errTLSRequired.Error() {
+		t.Fatal("expected errTLSRequired, got", err)
+	}
+	conn.Close()
+}
+
+// BenchmarkSessionThroughput compares the throughput of a connection with the
+// throughput of the same connection after an RPCUpgradeTLS. Each iteration
+// transfers one sector.
+func BenchmarkSessionThroughput(b *testing.B) {
+	certPEM, keyPEM, err := generateTLSCert()
+	if err != nil {
+		b.Fatal(err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		b.Fatal(err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	sk, pk := crypto.GenerateKeyPair()
+	hostKey := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: pk[:]}
+	sector := make([]byte, modules.SectorSize)
+
+	for _, upgrade := range []bool{false, true} {
+		name := "plain"
+		if upgrade {
+			name = "tls"
+		}
+		b.Run(name, func(b *testing.B) {
+			l, err := net.Listen("tcp", "localhost:0")
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer l.Close()
+			go func() {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				if upgrade {
+					var id types.Specifier
+					if encoding.ReadObject(conn, &id, 16) != nil {
+						return
+					}
+					if conn, err = upgradeSessionTLS(conn, config, sk); err != nil {
+						return
+					}
+				}
+				io.Copy(ioutil.Discard, conn)
+			}()
+
+			conn, err := net.Dial("tcp", l.Addr().String())
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer conn.Close()
+			if upgrade {
+				if conn, err = modules.UpgradeSessionTLS(conn, hostKey); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.SetBytes(int64(len(sector)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := conn.Write(sector); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestLoadTLSConfigMissingFile checks that a certificate is not generated if
+// only one of the certificate and key files exists.
+func TestLoadTLSConfigMissingFile(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	certFile := filepath.Join(ht.persistDir, "tls.crt")
+	keyFile := filepath.Join(ht.persistDir, "tls.key")
+	if err := ioutil.WriteFile(keyFile, []byte("key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ht.host.loadTLSConfig(certFile, keyFile); err != errTLSFileMissing {
+		t.Fatal("expected errTLSFileMissing, got", err)
+	}
+	if key, err := ioutil.ReadFile(keyFile); err != nil || string(key) != "key" {
+		t.Fatal("existing key file was overwritten:", err)
+	}
+	if config, err := ht.host.loadTLSConfig("", ""); config != nil || err != nil {
+		t.Fatal("expected TLS to be disabled without files:", err)
+	}
+}
@@ -0,0 +1,140 @@
+package host
+
+// uniquecallers.go counts the distinct renters that have contracts with the
+// host, and the distinct IP addresses that have called the settings RPC
+// recently, so that the host operator can tell broad demand apart from a
+// single aggressive renter.
+//
+// The renters are counted from the storage obligations, so the count survives
+// restarts. Renters use a new key in every contract, so a renter is counted by
+// the identity key that it identified itself with, and the contracts of
+// renters that did not identify themselves are counted by their contract key.
+// The IP addresses of settings callers are only kept in memory, and are
+// forgotten once they have not called the settings RPC within
+// settingsCallerWindow.
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+const (
+	// settingsCallerWindow is the window over which the distinct callers of
+	// the settings RPC are counted.
+	settingsCallerWindow = 24 * time.Hour
+
+	// settingsCallerPruneInterval is how often the callers of the settings
+	// RPC that fell out of the window are forgotten.
+	settingsCallerPruneInterval = time.Hour
+
+	// maxSettingsCallers is the maximum number of IP addresses of settings
+	// callers that are remembered. Addresses that are new while the limit is
+	// reached are not recorded until pruning makes room for them, so the
+	// count saturates at the limit.
+	maxSettingsCallers = 100e3
+)
+
+// settingsCallers records the time of the most recent settings call from each
+// IP address. It has its own lock, so that settings calls do not contend for
+// the host lock.
+type settingsCallers struct {
+	callers map[string]time.Time
+	mu      sync.Mutex
+}
+
+// newSettingsCallers returns an empty set of settings callers.
+func newSettingsCallers() *settingsCallers {
+	return &settingsCallers{callers: make(map[string]time.Time)}
+}
+
+// record records a call to the settings RPC from addr at time now.
+func (sc *settingsCallers) record(addr net.Addr, now time.Time) {
+	ip := addr.String()
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if _, exists := sc.callers[ip]; exists || len(sc.callers) < maxSettingsCallers {
+		sc.callers[ip] = now
+	}
+}
+
+// prune forgets the callers of the settings RPC that have not called it
+// within the window ending at now.
+func (sc *settingsCallers) prune(now time.Time) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for ip, lastCall := range sc.callers {
+		if now.Sub(lastCall) > settingsCallerWindow {
+			delete(sc.callers, ip)
+		}
+	}
+}
+
+// count returns the number of distinct IP addresses that have called the
+// settings RPC within the window ending at now.
+func (sc *settingsCallers) count(now time.Time) (callers uint64) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for _, lastCall := range sc.callers {
+		if now.Sub(lastCall) <= settingsCallerWindow {
+			callers++
+		}
+	}
+	return callers
+}
+
+// threadedPruneSettingsCallers periodically forgets the callers of the
+// settings RPC that fell out of the window.
+func (h *Host) threadedPruneSettingsCallers() {
+	for {
+		select {
+		case <-h.tg.StopChan():
+			return
+		case <-time.After(settingsCallerPruneInterval):
+		}
+		h.settingsCallers.prune(time.Now())
+	}
+}
+
+// uniqueRenters returns the number of distinct renters across the unresolved
+// storage obligations of the host. The caller must hold the lock.
+func (h *Host) uniqueRenters() (uint64, error) {
+	renters := make(map[string]struct{})
+	err := h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+			var so storageObligation
+			err := json.Unmarshal(soBytes, &so)
+			if err != nil {
+				return build.ExtendErr("unable to unmarshal storage obligation:", err)
+			}
+			if so.ObligationStatus != obligationUnresolved || len(so.RevisionTransactionSet) == 0 {
+				return nil
+			}
+			if len(so.RenterIdentity.Key) > 0 {
+				renters[so.RenterIdentity.String()] = struct{}{}
+				return nil
+			}
+			// The renter's key is the first key of the unlock conditions of
+			// the file contract, which only appear in its revisions.
+			revisionTxn := so.RevisionTransactionSet[len(so.RevisionTransactionSet)-1]
+			if len(revisionTxn.FileContractRevisions) == 0 {
+				return nil
+			}
+			uc := revisionTxn.FileContractRevisions[0].UnlockConditions
+			if len(uc.PublicKeys) == 0 {
+				return nil
+			}
+			renters[uc.PublicKeys[0].String()] = struct{}{}
+			return nil
+		})
+	})
+	return uint64(len(renters)), err
+}
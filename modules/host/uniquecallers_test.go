@@ -0,0 +1,124 @@
+package host
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestUniqueSettingsCallers checks that the settings callers are counted by IP
+// address, are forgotten once they fall out of the window, and that the number
+// of remembered callers is limited.
+func TestUniqueSettingsCallers(t *testing.T) {
+	sc := newSettingsCallers()
+	now := time.Now()
+	old := now.Add(-settingsCallerWindow - time.Minute)
+	sc.record(&net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1000}, old)
+	sc.record(&net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 1000}, now)
+	sc.record(&net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 2000}, now)
+	sc.record(&net.TCPAddr{IP: net.IPv4(10, 0, 0, 3), Port: 1000}, now)
+	if callers := sc.count(now); callers != 2 {
+		t.Fatal("expected 2 settings callers, got", callers)
+	}
+	sc.prune(now)
+	if _, exists := sc.callers["10.0.0.1"]; exists {
+		t.Fatal("caller outside of the window was not forgotten")
+	}
+
+	// Callers that have not called again are counted out of the window even
+	// before they are pruned, and are forgotten once they are pruned.
+	later := now.Add(settingsCallerWindow + time.Minute)
+	if callers := sc.count(later); callers != 0 {
+		t.Fatal("expected 0 settings callers, got", callers)
+	}
+	sc.prune(later)
+	if len(sc.callers) != 0 {
+		t.Fatal("callers outside of the window were not forgotten:", sc.callers)
+	}
+
+	// New callers are not recorded once the limit is reached, while the
+	// recorded callers are still updated.
+	for i := 0; i < maxSettingsCallers; i++ {
+		sc.record(&net.TCPAddr{IP: net.IPv4(10, byte(i>>16), byte(i>>8), byte(i)), Port: 1000}, now)
+	}
+	sc.record(&net.TCPAddr{IP: net.IPv4(11, 0, 0, 1), Port: 1000}, now)
+	sc.record(&net.TCPAddr{IP: net.IPv4(10, 0, 0, 0), Port: 1000}, later)
+	if len(sc.callers) != maxSettingsCallers {
+		t.Fatal("expected the callers to be limited, got", len(sc.callers))
+	} else if sc.callers["10.0.0.0"] != later {
+		t.Fatal("recorded caller was not updated")
+	}
+}
+
+// TestUniqueRenters checks that the renters of the host are counted by their
+// identity across the unresolved storage obligations, falling back to the
+// contract key of renters without an identity, and that the count survives a
+// restart.
+func TestUniqueRenters(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Add two obligations of one renter, which uses a new contract key for
+	// every contract, and one of a renter without an identity.
+	identity := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{9}}
+	for _, key := range []byte{1, 2, 3} {
+		so, err := ht.newTesterStorageObligation()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != 3 {
+			so.RenterIdentity = identity
+		}
+		validPayouts, missedPayouts := so.payouts()
+		so.RevisionTransactionSet = []types.Transaction{{
+			FileContractRevisions: []types.FileContractRevision{{
+				ParentID: so.id(),
+				UnlockConditions: types.UnlockConditions{
+					PublicKeys: []types.SiaPublicKey{
+						{Algorithm: types.SignatureEd25519, Key: []byte{key}},
+						ht.host.PublicKey(),
+					},
+					SignaturesRequired: 2,
+				},
+				NewRevisionNumber:     1,
+				NewWindowStart:        so.expiration(),
+				NewWindowEnd:          so.proofDeadline(),
+				NewValidProofOutputs:  validPayouts,
+				NewMissedProofOutputs: missedPayouts,
+			}},
+		}}
+		ht.host.managedLockStorageObligation(so.id())
+		err = ht.host.managedAddStorageObligation(so)
+		ht.host.managedUnlockStorageObligation(so.id())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if renters := ht.host.NetworkMetrics().UniqueRenters; renters != 2 {
+		t.Fatal("expected 2 renters, got", renters)
+	}
+
+	// The count is derived from the obligations, so it survives a restart.
+	err = ht.host.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host, err = New(ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renters := ht.host.NetworkMetrics().UniqueRenters; renters != 2 {
+		t.Fatal("expected 2 renters after a restart, got", renters)
+	}
+}
@@ -129,7 +129,18 @@ func (h *Host) ProcessConsensusChange(cc modules.ConsensusChange) {
 	// Wrap the whole parsing into a single large database tx to keep things
 	// efficient.
 	var actionItems []types.FileContractID
+	var reopened []storageObligation
 	err := h.db.Update(func(tx *bolt.Tx) error {
+		// reopen reopens a resolved storage obligation whose storage proof
+		// was changed by the reorg.
+		reopen := func(so storageObligation) error {
+			ok, err := h.reopenStorageObligation(tx, &so, h.revenueSequence+uint64(len(reopened))+1)
+			if ok && err == nil {
+				reopened = append(reopened, so)
+			}
+			return err
+		}
+
 		for _, block := range cc.RevertedBlocks {
 			// Look for transactions relevant to open storage obligations.
 			for _, txn := range block.Transactions {
@@ -146,7 +157,7 @@ func (h *Host) ProcessConsensusChange(cc modules.ConsensusChange) {
 							continue
 						}
 						so.OriginConfirmed = false
-						err = putStorageObligation(tx, so)
+						err = putStorageObligationWithID(tx, fcid, so)
 						if err != nil {
 							continue
 						}
@@ -165,7 +176,7 @@ func (h *Host) ProcessConsensusChange(cc modules.ConsensusChange) {
 							continue
 						}
 						so.RevisionConfirmed = false
-						err = putStorageObligation(tx, so)
+						err = putStorageObligationWithID(tx, fcr.ParentID, so)
 						if err != nil {
 							continue
 						}
@@ -185,10 +196,17 @@ func (h *Host) ProcessConsensusChange(cc modules.ConsensusChange) {
 							continue
 						}
 						so.ProofConfirmed = false
-						err = putStorageObligation(tx, so)
+						so.ProofReverted = true
+						err = putStorageObligationWithID(tx, sp.ParentID, so)
 						if err != nil {
 							continue
 						}
+						if so.ObligationStatus == obligationSucceeded {
+							err = reopen(so)
+							if err != nil {
+								return err
+							}
+						}
 					}
 				}
 			}
@@ -217,7 +235,7 @@ func (h *Host) ProcessConsensusChange(cc modules.ConsensusChange) {
 							continue
 						}
 						so.OriginConfirmed = true
-						err = putStorageObligation(tx, so)
+						err = putStorageObligationWithID(tx, fcid, so)
 						if err != nil {
 							continue
 						}
@@ -236,7 +254,7 @@ func (h *Host) ProcessConsensusChange(cc modules.ConsensusChange) {
 							continue
 						}
 						so.RevisionConfirmed = true
-						err = putStorageObligation(tx, so)
+						err = putStorageObligationWithID(tx, fcr.ParentID, so)
 						if err != nil {
 							continue
 						}
@@ -255,10 +273,16 @@ func (h *Host) ProcessConsensusChange(cc modules.ConsensusChange) {
 							continue
 						}
 						so.ProofConfirmed = true
-						err = putStorageObligation(tx, so)
+						err = putStorageObligationWithID(tx, sp.ParentID, so)
 						if err != nil {
 							continue
 						}
+						if so.ObligationStatus == obligationFailed {
+							err = reopen(so)
+							if err != nil {
+								return err
+							}
+						}
 					}
 				}
 			}
@@ -295,6 +319,15 @@ func (h *Host) ProcessConsensusChange(cc modules.ConsensusChange) {
 	})
 	if err != nil {
 		h.log.Println(err)
+	} else {
+		// Return the revenue of the reopened obligations to the potential
+		// revenue, and check on them again once the change has been
+		// processed.
+		for _, so := range reopened {
+			h.revenueSequence = so.RevenueSequence
+			revertResolution(&h.financialMetrics, so)
+			actionItems = append(actionItems, so.id())
+		}
 	}
 	for i := range actionItems {
 		go h.threadedHandleActionItem(actionItems[i])
@@ -304,6 +337,16 @@ func (h *Host) ProcessConsensusChange(cc modules.ConsensusChange) {
 	// change.
 	h.recentChange = cc.ID
 
+	// Check the storage folders for any new failures.
+	h.updateStorageFolderAlerts()
+
+	// Check for obligations that have grown too large to prove in time. The
+	// check reads every obligation, so it is skipped while the host is
+	// catching up with the blockchain.
+	if cc.Synced {
+		h.updateProofConstructionAlert()
+	}
+
 	// Save the host.
 	err = h.saveSync()
 	if err != nil {
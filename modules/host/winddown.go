@@ -0,0 +1,275 @@
+package host
+
+// winddown.go implements the wind down of a host that is leaving the network.
+// While the host is winding down it does not accept new file contracts, and
+// it refuses to renew existing contracts with modules.ErrHostWindingDown, so
+// that renters migrate their data to other hosts before the contracts expire.
+// The host keeps serving and proving the storage of its remaining
+// obligations. The wind down is persistent, and AcceptingContracts cannot be
+// enabled until it is stopped.
+//
+// A storage folder can be removed without moving data once the obligations
+// of all of its sectors have resolved, which StorageFolderReleases reports for
+// every folder. WindDownCompact frees folders earlier by moving their sectors
+// into the remaining capacity of the other folders. Moving the sectors can take
+// hours, so the folders are removed in the background.
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// errNotWindingDown is returned when the storage of the host is compacted
+	// while the host is not winding down.
+	errNotWindingDown = errors.New("host is not winding down")
+
+	// errCompactionInProgress is returned when the storage of the host is
+	// compacted while an earlier compaction is still running.
+	errCompactionInProgress = errors.New("the storage of the host is already being compacted")
+
+	// errWindDownAcceptingContracts is returned when AcceptingContracts is
+	// enabled while the host is winding down.
+	errWindDownAcceptingContracts = errors.New("host cannot accept contracts while it is winding down")
+)
+
+// managedUnresolvedObligations calls fn for every unresolved storage
+// obligation of the host.
+func (h *Host) managedUnresolvedObligations(fn func(so storageObligation)) error {
+	return h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+			var so storageObligation
+			err := json.Unmarshal(soBytes, &so)
+			if err != nil {
+				return build.ExtendErr("unable to unmarshal storage obligation:", err)
+			}
+			if so.ObligationStatus == obligationUnresolved {
+				fn(so)
+			}
+			return nil
+		})
+	})
+}
+
+// SetWindDown starts or stops winding down the host. Starting the wind down
+// disables AcceptingContracts, stopping it leaves AcceptingContracts disabled.
+func (h *Host) SetWindDown(windDown bool) error {
+	err := h.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer h.tg.Done()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.windDown == windDown {
+		return nil
+	}
+	h.windDown = windDown
+	if windDown && h.settings.AcceptingContracts {
+		h.settings.AcceptingContracts = false
+		h.revisionNumber++
+	}
+	if windDown {
+		h.log.Println("Winding down the host, new contracts and renewals are refused.")
+	} else {
+		h.log.Println("Stopped winding down the host.")
+	}
+	return h.saveSync()
+}
+
+// WindDownStatus returns whether the host is winding down, and the collateral
+// and the proof deadline of its remaining obligations. Quarantined obligations
+// are counted, but do not have a proof deadline, as they are resolved by the
+// operator.
+func (h *Host) WindDownStatus() modules.HostWindDownStatus {
+	err := h.tg.Add()
+	if err != nil {
+		return modules.HostWindDownStatus{}
+	}
+	defer h.tg.Done()
+
+	h.mu.RLock()
+	status := modules.HostWindDownStatus{Active: h.windDown}
+	h.mu.RUnlock()
+	err = h.managedUnresolvedObligations(func(so storageObligation) {
+		status.RemainingObligations++
+		status.LockedCollateral = status.LockedCollateral.Add(so.LockedCollateral)
+		if so.quarantined() {
+			return
+		}
+		if deadline := so.proofDeadline(); deadline > status.FinalHeight {
+			status.FinalHeight = deadline
+		}
+	})
+	if err != nil {
+		h.log.Println("Unable to read the storage obligations for the wind down status:", err)
+	}
+	return status
+}
+
+// StorageFolderReleases returns the height at which each storage folder no
+// longer holds sectors of unresolved storage obligations, which is the latest
+// proof deadline of the obligations of its sectors. The folders are sorted by
+// that height.
+func (h *Host) StorageFolderReleases() ([]modules.HostStorageFolderRelease, error) {
+	err := h.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer h.tg.Done()
+
+	deadlines := make(map[crypto.Hash]types.BlockHeight)
+	err = h.managedUnresolvedObligations(func(so storageObligation) {
+		if so.quarantined() {
+			return
+		}
+		deadline := so.proofDeadline()
+		for _, root := range so.SectorRoots {
+			if deadline > deadlines[root] {
+				deadlines[root] = deadline
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	roots := make([]crypto.Hash, 0, len(deadlines))
+	for root := range deadlines {
+		roots = append(roots, root)
+	}
+	locations, err := h.StorageManager.HasSectors(roots)
+	if err != nil {
+		return nil, build.ExtendErr("unable to locate the sectors of the storage obligations:", err)
+	}
+
+	folders := h.StorageFolders()
+	releases := make([]modules.HostStorageFolderRelease, len(folders))
+	indices := make(map[uint16]int)
+	for i, sf := range folders {
+		releases[i] = modules.HostStorageFolderRelease{Index: sf.Index, Path: sf.Path}
+		indices[sf.Index] = i
+	}
+	for root, sl := range locations {
+		i, exists := indices[sl.StorageFolder]
+		if !exists {
+			continue
+		}
+		releases[i].Sectors++
+		if deadlines[root] > releases[i].FreeHeight {
+			releases[i].FreeHeight = deadlines[root]
+		}
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		if releases[i].FreeHeight != releases[j].FreeHeight {
+			return releases[i].FreeHeight < releases[j].FreeHeight
+		}
+		return releases[i].Index < releases[j].Index
+	})
+	return releases, nil
+}
+
+// WindDownCompact starts to remove storage folders in the background,
+// starting with the folder that uses the least storage, for as long as the
+// sectors of the next folder fit into the remaining capacity of the other
+// folders. The storage manager moves the sectors of a removed folder into the
+// other folders. WindDownCompaction reports the progress of the compaction.
+func (h *Host) WindDownCompact() error {
+	err := h.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer h.tg.Done()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.windDown {
+		return errNotWindingDown
+	}
+	if h.compaction.Active {
+		return errCompactionInProgress
+	}
+	h.compaction = modules.HostWindDownCompaction{Active: true}
+	go h.threadedWindDownCompact()
+	return nil
+}
+
+// WindDownCompaction returns the progress of the last compaction that was
+// started by WindDownCompact.
+func (h *Host) WindDownCompaction() modules.HostWindDownCompaction {
+	h.mu.RLock()
+	c := h.compaction
+	c.Removed = append([]modules.StorageFolderMetadata(nil), c.Removed...)
+	h.mu.RUnlock()
+	if c.Current == nil {
+		return c
+	}
+	// Report the current progress of the removal of the folder.
+	for _, sf := range h.StorageFolders() {
+		if sf.Index == c.Current.Index {
+			c.Current = &sf
+			break
+		}
+	}
+	return c
+}
+
+// threadedWindDownCompact removes the storage folders of a compaction that
+// was started by WindDownCompact.
+func (h *Host) threadedWindDownCompact() {
+	err := h.managedWindDownCompact()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.compaction.Active = false
+	h.compaction.Current = nil
+	if err != nil {
+		h.log.Println("Compaction of the storage of the host stopped:", err)
+		h.compaction.Error = err.Error()
+	}
+}
+
+// managedWindDownCompact removes the storage folders of a compaction, and
+// records the progress of the compaction in h.compaction.
+func (h *Host) managedWindDownCompact() error {
+	err := h.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer h.tg.Done()
+
+	for {
+		folders := h.StorageFolders()
+		if len(folders) < 2 {
+			return nil
+		}
+		sort.Slice(folders, func(i, j int) bool {
+			return folders[i].Capacity-folders[i].CapacityRemaining < folders[j].Capacity-folders[j].CapacityRemaining
+		})
+		var remaining uint64
+		for _, sf := range folders[1:] {
+			remaining += sf.CapacityRemaining
+		}
+		sf := folders[0]
+		if sf.Capacity-sf.CapacityRemaining > remaining {
+			return nil
+		}
+		h.mu.Lock()
+		h.compaction.Current = &sf
+		h.mu.Unlock()
+		err := h.RemoveStorageFolder(sf.Index, false)
+		if err != nil {
+			return build.ExtendErr("unable to remove storage folder "+sf.Path+":", err)
+		}
+		h.log.Println("Removed storage folder", sf.Path, "to compact the storage of the host.")
+		h.mu.Lock()
+		h.compaction.Removed = append(h.compaction.Removed, sf)
+		h.mu.Unlock()
+	}
+}
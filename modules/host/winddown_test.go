@@ -0,0 +1,158 @@
+package host
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestWindDown checks that a host that is winding down stops accepting
+// contracts, reports its remaining obligations and the height at which each
+// storage folder becomes free, and compacts its storage folders in the
+// background.
+func TestWindDown(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	settings := ht.host.InternalSettings()
+	settings.AcceptingContracts = true
+	if err := ht.host.SetInternalSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.WindDownCompact(); err != errNotWindingDown {
+		t.Fatal("expected errNotWindingDown, got", err)
+	}
+
+	// Add two obligations with different proof deadlines. The first
+	// obligation holds the first sector, and the second obligation holds all
+	// of the sectors.
+	var roots []crypto.Hash
+	for i := 0; i < 3; i++ {
+		data := fastrand.Bytes(int(modules.SectorSize))
+		root := crypto.MerkleRoot(data)
+		if err := ht.host.AddSector(root, data); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+	var deadlines []types.BlockHeight
+	for _, sectorRoots := range [][]crypto.Hash{roots[:1], roots} {
+		so, err := ht.newTesterStorageObligation()
+		if err != nil {
+			t.Fatal(err)
+		}
+		so.SectorRoots = sectorRoots
+		so.LockedCollateral = types.SiacoinPrecision
+		ht.host.managedLockStorageObligation(so.id())
+		err = ht.host.managedAddStorageObligation(so)
+		ht.host.managedUnlockStorageObligation(so.id())
+		if err != nil {
+			t.Fatal(err)
+		}
+		deadlines = append(deadlines, so.proofDeadline())
+		if _, err := ht.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ht.host.SetWindDown(true); err != nil {
+		t.Fatal(err)
+	}
+	if ht.host.InternalSettings().AcceptingContracts {
+		t.Fatal("host is accepting contracts while winding down")
+	}
+	if err := ht.host.SetInternalSettings(settings); err != errWindDownAcceptingContracts {
+		t.Fatal("expected errWindDownAcceptingContracts, got", err)
+	}
+	status := ht.host.WindDownStatus()
+	if !status.Active || status.RemainingObligations != 2 || status.FinalHeight != deadlines[1] {
+		t.Fatalf("wrong wind down status: %+v", status)
+	}
+	if !status.LockedCollateral.Equals(types.SiacoinPrecision.Mul64(2)) {
+		t.Fatal("wrong locked collateral:", status.LockedCollateral)
+	}
+
+	// Every folder that holds a sector is freed by the second obligation.
+	locations, err := ht.host.HasSectors(roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sectors := make(map[uint16]uint64)
+	for _, sl := range locations {
+		sectors[sl.StorageFolder]++
+	}
+	releases, err := ht.host.StorageFolderReleases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(releases) != 2 {
+		t.Fatal("expected 2 releases, got", len(releases))
+	}
+	for _, r := range releases {
+		wantHeight := deadlines[1]
+		if sectors[r.Index] == 0 {
+			wantHeight = 0
+		}
+		if r.Sectors != sectors[r.Index] || r.FreeHeight != wantHeight {
+			t.Fatalf("wrong release for folder %v: %+v", r.Index, r)
+		}
+	}
+
+	// Compacting the storage moves every sector into one folder. The folder
+	// is removed in the background.
+	if err := ht.host.WindDownCompact(); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if ht.host.WindDownCompaction().Active {
+			return errors.New("compaction is still running")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := ht.host.WindDownCompaction()
+	if len(c.Removed) != 1 || c.Current != nil || c.Error != "" || len(ht.host.StorageFolders()) != 1 {
+		t.Fatalf("expected one folder to be removed, got %+v", c)
+	}
+	releases, err = ht.host.StorageFolderReleases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(releases) != 1 || releases[0].Sectors != 3 || releases[0].FreeHeight != deadlines[1] {
+		t.Fatalf("wrong releases after compacting: %+v", releases)
+	}
+
+	// The wind down is persistent.
+	if err := ht.host.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ht.host, err = New(ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ht.host.WindDownStatus().Active {
+		t.Fatal("wind down was not persisted")
+	}
+	if err := ht.host.SetWindDown(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.SetInternalSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+}
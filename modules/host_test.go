@@ -1,7 +1,12 @@
 package modules
 
 import (
+	"math"
+	"math/big"
+	"strings"
 	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
 )
 
 // TestUnitMaxFileContractSetLenSanity checks that a sensible value for
@@ -20,3 +25,153 @@ func TestUnitMaxFileContractSetLenSanity(t *testing.T) {
 	}
 
 }
+
+// TestStoragePriceToConsensusFromFloat checks the conversion of fractional
+// human readable storage prices to consensus prices.
+func TestStoragePriceToConsensusFromFloat(t *testing.T) {
+	t.Parallel()
+
+	// siacoinsMonthTB computes the expected consensus price of a price given
+	// as a fraction of siacoins per terabyte per month.
+	siacoinsMonthTB := func(num, denom int64) types.Currency {
+		return types.SiacoinPrecision.Mul(types.NewCurrency64(uint64(num))).Div64(uint64(denom)).Div(BlockBytesPerMonthTerabyte)
+	}
+	twoToThe65 := new(big.Int).Lsh(big.NewInt(1), 65)
+	tests := []struct {
+		price    float64
+		expected types.Currency
+		err      error
+	}{
+		// Whole and fractional prices.
+		{0, types.ZeroCurrency, nil},
+		{math.Copysign(0, -1), types.ZeroCurrency, nil},
+		{1, siacoinsMonthTB(1, 1), nil},
+		{50, StoragePriceToConsensus(50), nil},
+		{0.5, siacoinsMonthTB(1, 2), nil},
+		{0.25, siacoinsMonthTB(1, 4), nil},
+		{2.75, siacoinsMonthTB(11, 4), nil},
+
+		// Prices below one hasting per byte per block are truncated.
+		{1e-8, types.NewCurrency64(2), nil},
+		{1e-9, types.ZeroCurrency, nil},
+		{1e-300, types.ZeroCurrency, nil},
+		{math.SmallestNonzeroFloat64, types.ZeroCurrency, nil},
+
+		// Prices that exceed math.MaxUint64. Both values are represented
+		// exactly by a float64.
+		{float64(math.MaxUint64) * 2, types.SiacoinPrecision.Mul(types.NewCurrency(twoToThe65)).Div(BlockBytesPerMonthTerabyte), nil},
+		{1e22, types.SiacoinPrecision.Mul(types.NewCurrency64(1e11)).Mul(types.NewCurrency64(1e11)).Div(BlockBytesPerMonthTerabyte), nil},
+
+		// Invalid prices.
+		{-1, types.ZeroCurrency, ErrInvalidHumanPrice},
+		{-0.5, types.ZeroCurrency, ErrInvalidHumanPrice},
+		{-math.SmallestNonzeroFloat64, types.ZeroCurrency, ErrInvalidHumanPrice},
+		{math.Inf(1), types.ZeroCurrency, ErrInvalidHumanPrice},
+		{math.Inf(-1), types.ZeroCurrency, ErrInvalidHumanPrice},
+		{math.NaN(), types.ZeroCurrency, ErrInvalidHumanPrice},
+	}
+	for _, test := range tests {
+		price, err := StoragePriceToConsensusFromFloat(test.price)
+		if err != test.err {
+			t.Errorf("%v: expected error %v, got %v", test.price, test.err, err)
+		} else if err == nil && !price.Equals(test.expected) {
+			t.Errorf("%v: expected %v, got %v", test.price, test.expected, price)
+		}
+	}
+
+	// Integer prices should convert to the same price as with
+	// StoragePriceToConsensus, including integers at the limit of a float64's
+	// precision.
+	for _, price := range []uint64{0, 1, 2, 3, 7, 50, 99, 1000, 12345, 1e9, 1 << 52, 1 << 53, 1 << 63} {
+		converted, err := StoragePriceToConsensusFromFloat(float64(price))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !converted.Equals(StoragePriceToConsensus(price)) {
+			t.Errorf("%v: float conversion %v does not match integer conversion %v", price, converted, StoragePriceToConsensus(price))
+		}
+	}
+}
+
+// TestHostFinancialMetricsTotalRevenue checks that TotalRevenue and
+// PotentialTotalRevenue sum the matching revenue fields and nothing else.
+func TestHostFinancialMetricsTotalRevenue(t *testing.T) {
+	t.Parallel()
+
+	fm := HostFinancialMetrics{
+		ContractCompensation:              types.NewCurrency64(1),
+		StorageRevenue:                    types.NewCurrency64(2),
+		DownloadBandwidthRevenue:          types.NewCurrency64(4),
+		UploadBandwidthRevenue:            types.NewCurrency64(8),
+		PotentialContractCompensation:     types.NewCurrency64(16),
+		PotentialStorageRevenue:           types.NewCurrency64(32),
+		PotentialDownloadBandwidthRevenue: types.NewCurrency64(64),
+		PotentialUploadBandwidthRevenue:   types.NewCurrency64(128),
+
+		LockedStorageCollateral: types.NewCurrency64(256),
+		LostRevenue:             types.NewCurrency64(512),
+		TransactionFeeExpenses:  types.NewCurrency64(1024),
+	}
+	if !fm.TotalRevenue().Equals64(15) {
+		t.Error("wrong total revenue:", fm.TotalRevenue())
+	}
+	if !fm.PotentialTotalRevenue().Equals64(240) {
+		t.Error("wrong potential total revenue:", fm.PotentialTotalRevenue())
+	}
+	if !(HostFinancialMetrics{}).TotalRevenue().Equals64(0) || !(HostFinancialMetrics{}).PotentialTotalRevenue().Equals64(0) {
+		t.Error("empty metrics should have no revenue")
+	}
+}
+
+// TestHostInternalSettingsValidate checks that Validate accepts consistent
+// settings and names every invalid setting.
+func TestHostInternalSettingsValidate(t *testing.T) {
+	t.Parallel()
+
+	valid := HostInternalSettings{
+		MaxDuration:           100,
+		NetAddress:            "foo.com:1234",
+		WindowSize:            10,
+		MaxCollateralFraction: 1.5,
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatal("valid settings were rejected:", err)
+	}
+	valid.NetAddress = ""
+	if err := valid.Validate(); err != nil {
+		t.Fatal("an empty NetAddress was rejected:", err)
+	}
+	valid.ObligationRetentionBlocks = types.MaturityDelay
+	if err := valid.Validate(); err != nil {
+		t.Fatal("an ObligationRetentionBlocks of MaturityDelay was rejected:", err)
+	}
+
+	tests := []struct {
+		modify func(*HostInternalSettings)
+		want   string
+	}{
+		{func(s *HostInternalSettings) { s.NetAddress = "foo" }, "NetAddress"},
+		{func(s *HostInternalSettings) { s.WindowSize = 0 }, "WindowSize cannot be zero"},
+		{func(s *HostInternalSettings) { s.MaxDuration = s.WindowSize }, "MaxDuration"},
+		{func(s *HostInternalSettings) { s.MaxCollateralFraction = -1 }, "MaxCollateralFraction"},
+		{func(s *HostInternalSettings) { s.MaxCollateralFraction = math.NaN() }, "MaxCollateralFraction"},
+		{func(s *HostInternalSettings) { s.MaxCollateralFraction = math.Inf(1) }, "MaxCollateralFraction"},
+		{func(s *HostInternalSettings) { s.ObligationRetentionBlocks = types.MaturityDelay - 1 }, "ObligationRetentionBlocks"},
+	}
+	for _, test := range tests {
+		settings := valid
+		test.modify(&settings)
+		if err := settings.Validate(); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("expected an error containing %q, got %v", test.want, err)
+		}
+	}
+
+	// Every invalid setting should be reported.
+	invalid := valid
+	invalid.NetAddress = "foo"
+	invalid.MaxCollateralFraction = -1
+	err := invalid.Validate()
+	if err == nil || !strings.Contains(err.Error(), "NetAddress") || !strings.Contains(err.Error(), "MaxCollateralFraction") {
+		t.Fatal("expected both invalid settings to be reported, got", err)
+	}
+}
@@ -104,7 +104,7 @@ func TestIntegrationMiner(t *testing.T) {
 	}
 
 	// Check that the wallet has money.
-	siacoins, _, _ := mt.wallet.ConfirmedBalance()
+	siacoins, _, _, _ := mt.wallet.ConfirmedBalance()
 	if siacoins.IsZero() {
 		t.Error("expecting mining full balance to not be zero")
 	}
@@ -117,7 +117,7 @@ func TestIntegrationMiner(t *testing.T) {
 			t.Fatal(err)
 		}
 	}
-	morecoins, _, _ := mt.wallet.ConfirmedBalance()
+	morecoins, _, _, _ := mt.wallet.ConfirmedBalance()
 	if siacoins.Cmp(morecoins) >= 0 {
 		t.Error("wallet is not gaining balance while mining")
 	}
@@ -2,8 +2,10 @@ package modules
 
 import (
 	"bytes"
+	"crypto/tls"
 	"errors"
 	"io"
+	"net"
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
@@ -69,6 +71,14 @@ const (
 	// encoded HostExternalSettings.
 	NegotiateMaxHostExternalSettingsLen = 16000
 
+	// NegotiateMaxRPCVersionsSize is the maximum allowed size of an encoded
+	// RPCVersions.
+	NegotiateMaxRPCVersionsSize = 1e3
+
+	// NegotiateMaxRenterIdentitySize is the maximum allowed size of an
+	// encoded RenterIdentity.
+	NegotiateMaxRenterIdentitySize = 1e3
+
 	// NegotiateMaxSiaPubkeySize defines the maximum size that a SiaPubkey is
 	// allowed to be when being sent over the wire during negotiation.
 	NegotiateMaxSiaPubkeySize = 1e3
@@ -126,6 +136,20 @@ var (
 	// announcement is not a type of signature that is recognized.
 	ErrAnnUnrecognizedSignature = errors.New("the signature provided in the host announcement is not recognized")
 
+	// ErrHostWindingDown is the rejection that a host sends to a renter that
+	// attempts to renew a contract while the host is winding down. The renter
+	// should migrate the data of the contract to other hosts before the
+	// contract expires.
+	ErrHostWindingDown = errors.New("host is winding down and does not renew contracts")
+
+	// ErrHostCertSignature is returned by UpgradeSessionTLS if the TLS
+	// certificate of the host is not signed by the public key of the host.
+	ErrHostCertSignature = errors.New("TLS certificate of the host is not signed by the host's public key")
+
+	// ErrNoCommonRPCVersion is returned by NegotiateRPCVersion if the host
+	// does not support any of the RPC versions that the caller supports.
+	ErrNoCommonRPCVersion = errors.New("host does not support any of the requested RPC versions")
+
 	// ErrRevisionCoveredFields is returned if there is a covered fields object
 	// in a transaction signature which has the 'WholeTransaction' field set to
 	// true, meaning that miner fees cannot be added to the transaction without
@@ -154,6 +178,15 @@ var (
 	// RPCRenewContract is the specifier to renewing an existing contract.
 	RPCRenewContract = types.Specifier{'R', 'e', 'n', 'e', 'w', 'C', 'o', 'n', 't', 'r', 'a', 'c', 't', 2}
 
+	// RPCRenterIdentity is the specifier that identifies the renter in a
+	// versioned session, before the specifier of the RPC is sent. The keys of
+	// the renter in its file contracts are new for every contract, so hosts
+	// that recognize renters across contracts use the identity key of the
+	// renter instead. The host sends a random challenge, the renter responds
+	// with a RenterIdentity that signs the RenterIdentityHash of the
+	// challenge, and the host accepts or rejects the identity.
+	RPCRenterIdentity = types.Specifier{'R', 'e', 'n', 't', 'e', 'r', 'I', 'd', 'e', 'n', 't', 'i', 't', 'y'}
+
 	// RPCReviseContract is the specifier for revising an existing file
 	// contract.
 	RPCReviseContract = types.Specifier{'R', 'e', 'v', 'i', 's', 'e', 'C', 'o', 'n', 't', 'r', 'a', 'c', 't', 2}
@@ -161,6 +194,19 @@ var (
 	// RPCSettings is the specifier for requesting settings from the host.
 	RPCSettings = types.Specifier{'S', 'e', 't', 't', 'i', 'n', 'g', 's', 2}
 
+	// RPCUpgradeTLS is the specifier that upgrades a versioned session to
+	// TLS. Hosts that accept TLS list it among the RPCs of their RPCVersions.
+	// After the TLS handshake, the host sends the signature of
+	// HostTLSCertHash of its certificate, and the session continues over
+	// TLS with the specifier of the RPC.
+	RPCUpgradeTLS = types.Specifier{'U', 'p', 'g', 'r', 'a', 'd', 'e', 'T', 'L', 'S'}
+
+	// RPCVersion is the specifier that starts a versioned session. The host
+	// responds with its RPCVersions, the renter selects one of the versions,
+	// and then calls an RPC the same way as in a legacy session, where the
+	// specifier of the RPC is sent without negotiating a version first.
+	RPCVersion = types.Specifier{'V', 'e', 'r', 's', 'i', 'o', 'n'}
+
 	// SectorSize defines how large a sector should be in bytes. The sector
 	// size needs to be a power of two to be compatible with package
 	// merkletree. 4MB has been chosen for the live network because large
@@ -171,6 +217,25 @@ var (
 		Standard: uint64(1 << 22), // 4 MiB
 		Testing:  uint64(1 << 12), // 4 KiB
 	}).(uint64)
+
+	// SupportedRPCVersions are the versions of the host RPC protocol that are
+	// supported by this release, from oldest to newest. Version 1 consists of
+	// the RPCs of legacy sessions.
+	SupportedRPCVersions = []uint64{1}
+
+	// TLSCipherSuites are the TLS 1.2 cipher suites of the TLS connections
+	// between renters and hosts, most preferred first. AES-GCM is hardware
+	// accelerated on most CPUs, and the CBC suites that TLS offers by default
+	// are left out, because they transfer sectors several times slower. The
+	// cipher suites of TLS 1.3 are all AEAD suites already.
+	TLSCipherSuites = []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	}
 )
 
 type (
@@ -256,6 +321,21 @@ type (
 		Version        string `json:"version"`
 	}
 
+	// RenterIdentity is sent by the renter in RPCRenterIdentity. The
+	// signature is made with the identity key of the renter.
+	RenterIdentity struct {
+		PublicKey types.SiaPublicKey
+		Signature crypto.Signature
+	}
+
+	// RPCVersions is sent by the host at the start of a versioned session. It
+	// lists the RPC versions that the host supports, and the specifiers of
+	// the RPCs that the host serves.
+	RPCVersions struct {
+		Versions []uint64
+		RPCs     []types.Specifier
+	}
+
 	// A RevisionAction is a description of an edit to be performed on a file
 	// contract. Three types are allowed, 'ActionDelete', 'ActionInsert', and
 	// 'ActionModify'. ActionDelete just takes a sector index, indicating which
@@ -279,7 +359,8 @@ type (
 // ReadNegotiationAcceptance reads an accept/reject response from r (usually a
 // net.Conn). If the response is not AcceptResponse, ReadNegotiationAcceptance
 // returns the response as an error. If the response is StopResponse,
-// ErrStopResponse is returned, allowing for direct error comparison.
+// ErrStopResponse is returned, allowing for direct error comparison. The same
+// is true of a rejection with ErrHostWindingDown.
 //
 // Note that since errors returned by ReadNegotiationAcceptance are newly
 // allocated, they cannot be compared to other errors in the traditional
@@ -295,6 +376,8 @@ func ReadNegotiationAcceptance(r io.Reader) error {
 		return nil
 	case StopResponse:
 		return ErrStopResponse
+	case ErrHostWindingDown.Error():
+		return ErrHostWindingDown
 	default:
 		return errors.New(resp)
 	}
@@ -323,6 +406,113 @@ func WriteNegotiationStop(w io.Writer) error {
 	return encoding.WriteObject(w, StopResponse)
 }
 
+// NegotiateRPCVersion starts a versioned session on rw (usually a net.Conn),
+// selecting the newest RPC version that is in supported and is also supported
+// by the host. The selected version is returned along with the versions and
+// RPCs advertised by the host. If the host does not support any of the
+// versions, ErrNoCommonRPCVersion is returned.
+func NegotiateRPCVersion(rw io.ReadWriter, supported []uint64) (uint64, RPCVersions, error) {
+	var hv RPCVersions
+	err := encoding.WriteObject(rw, RPCVersion)
+	if err != nil {
+		return 0, hv, err
+	}
+	err = encoding.ReadObject(rw, &hv, NegotiateMaxRPCVersionsSize)
+	if err != nil {
+		return 0, hv, err
+	}
+	var version uint64
+	for _, hostVersion := range hv.Versions {
+		for _, v := range supported {
+			if v == hostVersion && v > version {
+				version = v
+			}
+		}
+	}
+	// A version of 0 tells the host that the session is over.
+	err = encoding.WriteObject(rw, version)
+	if err != nil {
+		return 0, hv, err
+	}
+	if version == 0 {
+		return 0, hv, ErrNoCommonRPCVersion
+	}
+	return version, hv, ReadNegotiationAcceptance(rw)
+}
+
+// RenterIdentityHash returns the hash that a renter signs in
+// RPCRenterIdentity. The hash covers the key of the host, so that a host
+// cannot pass the signature of a renter on to another host.
+func RenterIdentityHash(challenge [32]byte, hostKey types.SiaPublicKey) crypto.Hash {
+	return crypto.HashAll(RPCRenterIdentity, challenge, hostKey)
+}
+
+// NegotiateRenterIdentity identifies the renter to the host of hostKey in a
+// versioned session on rw by calling RPCRenterIdentity with the identity key
+// sk.
+func NegotiateRenterIdentity(rw io.ReadWriter, sk crypto.SecretKey, hostKey types.SiaPublicKey) error {
+	err := encoding.WriteObject(rw, RPCRenterIdentity)
+	if err != nil {
+		return err
+	}
+	var challenge [32]byte
+	err = encoding.ReadObject(rw, &challenge, uint64(len(challenge)))
+	if err != nil {
+		return err
+	}
+	err = encoding.WriteObject(rw, RenterIdentity{
+		PublicKey: types.Ed25519PublicKey(sk.PublicKey()),
+		Signature: crypto.SignHash(RenterIdentityHash(challenge, hostKey), sk),
+	})
+	if err != nil {
+		return err
+	}
+	return ReadNegotiationAcceptance(rw)
+}
+
+// HostTLSCertHash returns the hash of the DER encoded TLS certificate of a
+// host that the host signs in RPCUpgradeTLS.
+func HostTLSCertHash(certDER []byte) crypto.Hash {
+	return crypto.HashAll(RPCUpgradeTLS, certDER)
+}
+
+// UpgradeSessionTLS upgrades a versioned session on conn to TLS by calling
+// RPCUpgradeTLS, and returns the connection that the session continues on.
+// The certificate of the host is not checked against a certificate
+// authority. Instead, the host signs the hash of its certificate with the
+// key of hostKey, which authenticates the host without the renter pinning
+// its certificate.
+func UpgradeSessionTLS(conn net.Conn, hostKey types.SiaPublicKey) (net.Conn, error) {
+	err := encoding.WriteObject(conn, RPCUpgradeTLS)
+	if err != nil {
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, &tls.Config{
+		// The certificate is verified by the signature of the host instead.
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS12,
+		CipherSuites:       TLSCipherSuites,
+	})
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, build.ExtendErr("TLS handshake failed", err)
+	}
+	var sig crypto.Signature
+	err = encoding.ReadObject(tlsConn, &sig, uint64(len(sig)))
+	if err != nil {
+		return nil, err
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 || hostKey.Algorithm != types.SignatureEd25519 {
+		return nil, ErrHostCertSignature
+	}
+	var pk crypto.PublicKey
+	copy(pk[:], hostKey.Key)
+	if crypto.VerifyHash(HostTLSCertHash(certs[0].Raw), pk, sig) != nil {
+		return nil, ErrHostCertSignature
+	}
+	return tlsConn, nil
+}
+
 // CreateAnnouncement will take a host announcement and encode it, returning
 // the exact []byte that should be added to the arbitrary data of a
 // transaction.
@@ -1,12 +1,14 @@
 package modules
 
 import (
+	"crypto/tls"
 	"errors"
 	"net"
 	"strconv"
 	"strings"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/persist"
 )
 
 // MaxEncodedNetAddressLength is the maximum length of a NetAddress encoded
@@ -15,9 +17,77 @@ import (
 // string length prefix.
 const MaxEncodedNetAddressLength = 266
 
-// A NetAddress contains the information needed to contact a peer.
+var (
+	// ErrNetAddressHost is returned by ParseNetAddress if the address does not
+	// contain a host.
+	ErrNetAddressHost = errors.New("host is missing")
+
+	// ErrNetAddressMalformed is returned by ParseNetAddress if the address
+	// cannot be split into a host and a port.
+	ErrNetAddressMalformed = errors.New("address is not of the form host:port")
+
+	// ErrNetAddressNoPort is returned by ParseNetAddress if the address is a
+	// bare host or IP without a port.
+	ErrNetAddressNoPort = errors.New("port is missing")
+
+	// ErrNetAddressPort is returned by ParseNetAddress if the port is not an
+	// integer in the range [1,65535].
+	ErrNetAddressPort = errors.New("port must be an integer between 1 and 65535")
+)
+
+// A NetAddress contains the information needed to contact a peer. A
+// NetAddress that is built from user or network input should be created with
+// ParseNetAddress instead of a direct string conversion.
 type NetAddress string
 
+// NetAddressError is returned by ParseNetAddress if an address could not be
+// parsed. Err is one of the ErrNetAddress errors, and indicates which
+// validation failed.
+type NetAddressError struct {
+	Address string
+	Err     error
+}
+
+// Error implements the error interface.
+func (e NetAddressError) Error() string {
+	return "invalid net address " + strconv.Quote(e.Address) + ": " + e.Err.Error()
+}
+
+// ParseNetAddress parses a "host:port" string into a NetAddress. The port must
+// be in the range [1,65535], and a host or IP without a port is rejected. IPv6
+// addresses may be given with or without their zero compression, the returned
+// NetAddress always holds the canonical form of the IP in brackets, e.g.
+// "[2001:db8::1]:9981". ParseNetAddress only checks the syntax of the address,
+// IsValid and IsStdValid should be used to check whether the host can be
+// contacted.
+func ParseNetAddress(s string) (NetAddress, error) {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		// A bare IPv6 address contains colons, so it has to be checked for
+		// separately from a host without a colon.
+		bare := strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+		if !strings.Contains(s, ":") || net.ParseIP(bare) != nil {
+			return "", NetAddressError{Address: s, Err: ErrNetAddressNoPort}
+		}
+		return "", NetAddressError{Address: s, Err: ErrNetAddressMalformed}
+	}
+	if host == "" {
+		return "", NetAddressError{Address: s, Err: ErrNetAddressHost}
+	}
+	if port == "" {
+		return "", NetAddressError{Address: s, Err: ErrNetAddressNoPort}
+	}
+	// Atoi accepts a leading sign, which is not valid in a port.
+	portInt, err := strconv.Atoi(port)
+	if err != nil || strings.TrimLeft(port, "0123456789") != "" || portInt < 1 || portInt > 65535 {
+		return "", NetAddressError{Address: s, Err: ErrNetAddressPort}
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	}
+	return NetAddress(net.JoinHostPort(host, strconv.Itoa(portInt))), nil
+}
+
 // Host removes the port from a NetAddress, returning just the host. If the
 // address is not of the form "host:port" the empty string is returned. The
 // port will still be returned for invalid NetAddresses (e.g. "unqualified:0"
@@ -83,7 +153,32 @@ func (na NetAddress) IsLocal() bool {
 		"192.168.0.0/16",
 		"fd00::/8",
 	}
-	for _, cidr := range localCIDRs {
+	return ipInCIDRs(ip, localCIDRs)
+}
+
+// IsPrivate returns true if the host of the NetAddress is an IP address in a
+// private range: the IPv4 ranges of RFC 1918, the IPv6 unique local range
+// fc00::/7, or the IPv4 and IPv6 link-local ranges. Loopback addresses are
+// not private, they are reported by IsLoopback.
+func (na NetAddress) IsPrivate() bool {
+	ip := net.ParseIP(na.Host())
+	if ip == nil {
+		return false
+	}
+	privateCIDRs := []string{
+		"10.0.0.0/8",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"169.254.0.0/16",
+		"fc00::/7",
+		"fe80::/10",
+	}
+	return ipInCIDRs(ip, privateCIDRs)
+}
+
+// ipInCIDRs returns true if ip is contained in one of the CIDR ranges.
+func ipInCIDRs(ip net.IP, cidrs []string) bool {
+	for _, cidr := range cidrs {
 		_, ipnet, _ := net.ParseCIDR(cidr)
 		if ipnet.Contains(ip) {
 			return true
@@ -168,3 +263,32 @@ func (na NetAddress) IsStdValid() error {
 
 	return nil
 }
+
+// TLSConfig returns a tls.Config that can be used to dial the NetAddress. If
+// the host is a hostname, the config verifies that the certificate of the peer
+// is valid for the hostname, which is also sent as the SNI server name. The
+// certificate of an IP address cannot be verified against the system roots,
+// so for IP addresses certificate verification is skipped and a warning is
+// written to log, which may be nil; such connections are encrypted but not
+// authenticated.
+func (na NetAddress) TLSConfig(log *persist.Logger) (*tls.Config, error) {
+	if err := na.IsStdValid(); err != nil {
+		return nil, err
+	}
+	host := na.Host()
+	if net.ParseIP(host) != nil {
+		if log != nil {
+			log.Printf("WARN: skipping certificate verification for TLS connection to IP address %v", na)
+		}
+		return &tls.Config{
+			InsecureSkipVerify: true,
+			MinVersion:         tls.VersionTLS12,
+		}, nil
+	}
+	return &tls.Config{
+		// The trailing dot of a fully qualified hostname is not part of the
+		// server name, see RFC 6066.
+		ServerName: strings.TrimSuffix(host, "."),
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
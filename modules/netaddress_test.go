@@ -1,9 +1,12 @@
 package modules
 
 import (
+	"bytes"
 	"net"
 	"strings"
 	"testing"
+
+	"github.com/NebulousLabs/Sia/persist"
 )
 
 var (
@@ -148,6 +151,12 @@ func TestIsLoopback(t *testing.T) {
 		{"127.0.0.1:6723", true},
 		{"::1", false},
 		{"[::1]:7124", true},
+		{"126.255.255.255:1234", false},
+		{"127.255.255.255:1234", true},
+		{"128.0.0.0:1234", false},
+		{"[::0:1]:1234", true},
+		{"[::2]:1234", false},
+		{"[::1:1]:1234", false},
 
 		// Local network tests.
 		{"10.0.0.0", false},
@@ -317,3 +326,157 @@ func TestIsLocal(t *testing.T) {
 		}
 	}
 }
+
+// TestIsPrivate checks that IsPrivate reports the private and link-local
+// ranges, and excludes the addresses just outside of them.
+func TestIsPrivate(t *testing.T) {
+	t.Parallel()
+
+	testSet := []struct {
+		query           NetAddress
+		desiredResponse bool
+	}{
+		// RFC 1918 ranges.
+		{"9.255.255.255:1234", false},
+		{"10.0.0.0:1234", true},
+		{"10.255.255.255:1234", true},
+		{"11.0.0.0:1234", false},
+		{"172.15.255.255:1234", false},
+		{"172.16.0.0:1234", true},
+		{"172.31.255.255:1234", true},
+		{"172.32.0.0:1234", false},
+		{"192.167.255.255:1234", false},
+		{"192.168.0.0:1234", true},
+		{"192.168.255.255:1234", true},
+		{"192.169.0.0:1234", false},
+
+		// IPv4 link-local range.
+		{"169.253.255.255:1234", false},
+		{"169.254.0.0:1234", true},
+		{"169.254.255.255:1234", true},
+		{"169.255.0.0:1234", false},
+
+		// IPv6 unique local range.
+		{"[fbff:ffff:ffff:ffff:ffff:ffff:ffff:ffff]:1234", false},
+		{"[fc00::]:1234", true},
+		{"[fdff:ffff:ffff:ffff:ffff:ffff:ffff:ffff]:1234", true},
+		{"[fe00::]:1234", false},
+
+		// IPv6 link-local range.
+		{"[fe7f:ffff:ffff:ffff:ffff:ffff:ffff:ffff]:1234", false},
+		{"[fe80::]:1234", true},
+		{"[febf:ffff:ffff:ffff:ffff:ffff:ffff:ffff]:1234", true},
+		{"[fec0::]:1234", false},
+
+		// Loopback addresses are not private.
+		{"localhost:1234", false},
+		{"127.0.0.1:1234", false},
+		{"[::1]:1234", false},
+
+		// Public, unspecified, and garbage addresses.
+		{"2.34.45.64:7777", false},
+		{"[2001:db8::1]:1234", false},
+		{"hn.com:8811", false},
+		{"0.0.0.0:1234", false},
+		{"[::]:1234", false},
+		{"10.0.0.1", false},
+		{"", false},
+		{"garbage:6432", false},
+	}
+	for _, test := range testSet {
+		if test.query.IsPrivate() != test.desiredResponse {
+			t.Error("test failed:", test, test.query.IsPrivate())
+		}
+	}
+}
+
+// TestParseNetAddress checks that ParseNetAddress normalises valid addresses
+// and reports which validation failed for invalid addresses.
+func TestParseNetAddress(t *testing.T) {
+	t.Parallel()
+
+	// Every valid address should parse.
+	for _, addr := range validAddrs {
+		if _, err := ParseNetAddress(addr); err != nil {
+			t.Errorf("ParseNetAddress rejected valid address %q: %v", addr, err)
+		}
+	}
+
+	tests := []struct {
+		addr     string
+		expected NetAddress
+		err      error
+	}{
+		{"foo.com:9981", "foo.com:9981", nil},
+		{"1.2.3.4:09981", "1.2.3.4:9981", nil},
+		{"[::1]:9981", "[::1]:9981", nil},
+		{"[2001:0db8:0000:0000:0000:0000:0000:0001]:9981", "[2001:db8::1]:9981", nil},
+		{"[::ffff:1.2.3.4]:9981", "1.2.3.4:9981", nil},
+		{"1.2.3.4", "", ErrNetAddressNoPort},
+		{"::2", "", ErrNetAddressNoPort},
+		{"[::2]", "", ErrNetAddressNoPort},
+		{"foo.com", "", ErrNetAddressNoPort},
+		{"foo.com:", "", ErrNetAddressNoPort},
+		{":9981", "", ErrNetAddressHost},
+		{"foo:bar:baz", "", ErrNetAddressMalformed},
+		{"foo.com:0", "", ErrNetAddressPort},
+		{"foo.com:65536", "", ErrNetAddressPort},
+		{"foo.com:+80", "", ErrNetAddressPort},
+		{"foo.com:http", "", ErrNetAddressPort},
+	}
+	for _, test := range tests {
+		na, err := ParseNetAddress(test.addr)
+		if test.err == nil {
+			if err != nil || na != test.expected {
+				t.Errorf("%q: expected %q, got %q (%v)", test.addr, test.expected, na, err)
+			}
+			continue
+		}
+		naErr, ok := err.(NetAddressError)
+		if !ok || naErr.Err != test.err || naErr.Address != test.addr {
+			t.Errorf("%q: expected error %q, got %v", test.addr, test.err, err)
+		}
+	}
+}
+
+// TestTLSConfig checks that TLSConfig sets the server name for hostnames and
+// skips certificate verification for IP addresses, logging a warning.
+func TestTLSConfig(t *testing.T) {
+	var buf bytes.Buffer
+	log := persist.NewLogger(&buf)
+	tests := []struct {
+		addr       NetAddress
+		serverName string
+		insecure   bool
+	}{
+		{"foo.com:9981", "foo.com", false},
+		{"foo.com.:9981", "foo.com", false},
+		{"localhost:9981", "localhost", false},
+		{"1.2.3.4:9981", "", true},
+		{"[2001:db8::1]:9981", "", true},
+	}
+	for _, test := range tests {
+		conf, err := test.addr.TLSConfig(log)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.addr, err)
+			continue
+		}
+		if conf.ServerName != test.serverName || conf.InsecureSkipVerify != test.insecure {
+			t.Errorf("%q: expected server name %q and insecure %v, got %q and %v", test.addr, test.serverName, test.insecure, conf.ServerName, conf.InsecureSkipVerify)
+		}
+	}
+
+	for _, addr := range []NetAddress{"", "foo.com", "foo.com:0", "foo:9981", "0.0.0.0:9981"} {
+		if _, err := addr.TLSConfig(log); err == nil {
+			t.Errorf("%q: expected an error", addr)
+		}
+	}
+
+	// Only the IP addresses are logged.
+	if n := strings.Count(buf.String(), "WARN"); n != 2 {
+		t.Errorf("expected 2 warnings, got %v:\n%v", n, buf.String())
+	}
+	if _, err := NetAddress("1.2.3.4:9981").TLSConfig(nil); err != nil {
+		t.Error("config without a logger failed:", err)
+	}
+}
@@ -1,7 +1,9 @@
 package modules
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"time"
 
@@ -14,6 +16,52 @@ const (
 	// RenterDir is the name of the directory that is used to store the
 	// renter's persistent data.
 	RenterDir = "renter"
+
+	// DefaultPortfolio is the name of the allowance portfolio that holds the
+	// renter's original allowance, contracts, and files.
+	DefaultPortfolio = "default"
+
+	// FileListFormatBinary is the binary format of an exported file list. It
+	// is the format of the '.sia' files that are used to share files.
+	FileListFormatBinary = "binary"
+
+	// FileListFormatJSON is the JSON format of an exported file list.
+	FileListFormatJSON = "json"
+
+	// ChecksumAlgorithmBlake2b selects the 256 bit BLAKE2b hash for the
+	// checksums of uploaded files. It is the default algorithm.
+	ChecksumAlgorithmBlake2b = "blake2b"
+
+	// ChecksumAlgorithmSHA256 selects the SHA-256 hash for the checksums of
+	// uploaded files.
+	ChecksumAlgorithmSHA256 = "sha256"
+
+	// DownloadChecksumVerified is the checksum status of a download whose
+	// data matched the checksum of the file.
+	DownloadChecksumVerified = "verified"
+
+	// DownloadChecksumMismatch is the checksum status of a download whose
+	// data did not match the checksum of the file. The download fails.
+	DownloadChecksumMismatch = "mismatch"
+
+	// DownloadChecksumUnavailable is the checksum status of a download of a
+	// file without a checksum that covers the downloaded data, which is the
+	// case for files that were uploaded before checksums were computed.
+	DownloadChecksumUnavailable = "unavailable"
+
+	// RenterAlertCategoryStaleContracts is used for the alert that is raised
+	// while contracts could not be resynchronized with hosts that have a
+	// more recent revision than the renter.
+	RenterAlertCategoryStaleContracts = RenterAlertCategory("stale contracts")
+
+	// RenterAlertCategoryWalletLocked is used for the alert that is raised
+	// while contract formation and renewal are suspended because the wallet
+	// is locked.
+	RenterAlertCategoryWalletLocked = RenterAlertCategory("wallet locked")
+
+	// RenterAlertSeverityWarning is used for alerts which indicate that the
+	// renter is unable to maintain its contracts until the operator acts.
+	RenterAlertSeverityWarning = RenterAlertSeverity("warning")
 )
 
 // An ErasureCoder is an error-correcting encoder and decoder.
@@ -44,17 +92,52 @@ type Allowance struct {
 	Hosts       uint64            `json:"hosts"`
 	Period      types.BlockHeight `json:"period"`
 	RenewWindow types.BlockHeight `json:"renewwindow"`
+
+	// AutoPriceBoostFactor is the factor by which the maximum storage price
+	// is multiplied when the renter cannot form contracts with enough hosts
+	// because the hosts are too expensive. The price is boosted at most 5
+	// times, is reset when the allowance changes, and falls back once the
+	// hosts' prices drop. 0 disables the boost.
+	AutoPriceBoostFactor float64 `json:"autopriceboostfactor"`
+
+	// MaxVersionsPerFile is the number of previous versions of a file that
+	// are kept when a file is uploaded to a siapath that is already in use.
+	// 0 disables versioning, such that uploads to a siapath that is in use
+	// fail. Deleted files are not kept as previous versions, since this
+	// retention has no age limit. The version retention of a directory takes
+	// precedence.
+	MaxVersionsPerFile int `json:"maxversionsperfile"`
+}
+
+// VersionRetention limits the previous versions that are kept of the files in
+// a directory and its subdirectories. MaxVersions is the number of previous
+// versions that are kept of every file. Previous versions that were replaced
+// more than MaxAge ago are removed, and their pieces are deleted from the
+// hosts. A MaxAge of 0 keeps previous versions until they exceed MaxVersions,
+// and a MaxVersions of 0 with a MaxAge keeps any number of previous versions
+// until they are too old. If both are 0, versioning is disabled. Deleted files
+// are only kept as previous versions if MaxAge is set.
+type VersionRetention struct {
+	MaxVersions int           `json:"maxversions"`
+	MaxAge      time.Duration `json:"maxage"`
 }
 
 // DownloadInfo provides information about a file that has been requested for
-// download.
+// download. Filesize is the number of bytes that were requested, starting at
+// Offset. Section is set if the download does not cover the whole file.
 type DownloadInfo struct {
 	SiaPath     string         `json:"siapath"`
 	Destination DownloadWriter `json:"destination"`
 	Filesize    uint64         `json:"filesize"`
+	Offset      uint64         `json:"offset"`
+	Section     bool           `json:"section"`
 	Received    uint64         `json:"received"`
 	StartTime   time.Time      `json:"starttime"`
 	Error       string         `json:"error"`
+
+	// ChecksumStatus is the result of verifying the downloaded data against
+	// the checksum of the file. It is empty until the download completes.
+	ChecksumStatus string `json:"checksumstatus"`
 }
 
 // DownloadWriter provides an interface which all output writers have to implement.
@@ -64,12 +147,87 @@ type DownloadWriter interface {
 	Close() error
 }
 
+// A DownloadStreamError is returned by DownloadWriter and DownloadSection if a
+// download fails after it has started streaming. BytesWritten is the number of
+// bytes that were written to the writer before the failure.
+type DownloadStreamError struct {
+	BytesWritten uint64
+	Err          error
+}
+
+// Error implements the error interface.
+func (e DownloadStreamError) Error() string {
+	return fmt.Sprintf("download failed after writing %v bytes: %v", e.BytesWritten, e.Err)
+}
+
 // FileUploadParams contains the information used by the Renter to upload a
 // file.
 type FileUploadParams struct {
 	Source      string
 	SiaPath     string
 	ErasureCode ErasureCoder
+
+	// DataPieces and ParityPieces select a Reed-Solomon erasure code for the
+	// file if ErasureCode is nil. If both are 0, the default erasure code is
+	// used.
+	DataPieces   int
+	ParityPieces int
+}
+
+// DirectoryUploadParams contains the information used by the Renter to upload
+// a directory tree. Each file in the tree is uploaded to the SiaPath formed by
+// joining SiaPath with the file's path relative to LocalPath.
+type DirectoryUploadParams struct {
+	LocalPath   string
+	SiaPath     string
+	ErasureCode ErasureCoder
+
+	// Recursive indicates whether subdirectories of LocalPath should be
+	// uploaded. FollowSymlinks indicates whether symbolic links should be
+	// followed; if it is false, symbolic links are skipped.
+	Recursive      bool
+	FollowSymlinks bool
+
+	// Workers is the number of files that are submitted to the renter
+	// concurrently. If Workers is 0, a default is used.
+	Workers int
+}
+
+// DirectoryUploadResult reports the outcome of a directory upload for each
+// file that was found in the directory tree.
+type DirectoryUploadResult struct {
+	Files     []FileUploadStatus `json:"files"`
+	Succeeded int                `json:"succeeded"`
+	Failed    int                `json:"failed"`
+}
+
+// FileUploadStatus reports whether a single file of a directory upload was
+// successfully submitted to the renter.
+type FileUploadStatus struct {
+	Source  string `json:"source"`
+	SiaPath string `json:"siapath"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BackupManifest lists the files of a local directory that were backed up to
+// SiaPath by BackupToSia, as of the backup at Time. Every backup uploads the
+// manifest to Sia as well, so that the files can be restored on another
+// machine.
+type BackupManifest struct {
+	LocalPath string       `json:"localpath"`
+	SiaPath   string       `json:"siapath"`
+	Time      time.Time    `json:"time"`
+	Files     []BackupFile `json:"files"`
+}
+
+// BackupFile is a file of a BackupManifest. Path is the path of the file
+// relative to the backed up directory, using '/' as the separator. ModTime and
+// Size are those of the local file when it was uploaded to SiaPath.
+type BackupFile struct {
+	Path    string    `json:"path"`
+	SiaPath string    `json:"siapath"`
+	ModTime time.Time `json:"modtime"`
+	Size    int64     `json:"size"`
 }
 
 // FileInfo provides information about a file.
@@ -81,6 +239,102 @@ type FileInfo struct {
 	Redundancy     float64           `json:"redundancy"`
 	UploadProgress float64           `json:"uploadprogress"`
 	Expiration     types.BlockHeight `json:"expiration"`
+	Portfolio      string            `json:"portfolio"`
+	UploadTime     time.Time         `json:"uploadtime"`
+
+	// LocalPath is the path of the local file that the renter repairs the
+	// file from. It is empty for files that were uploaded from a stream,
+	// which are repaired by downloading their data from the hosts.
+	LocalPath string `json:"localpath"`
+
+	// TargetRedundancy is the redundancy that the renter repairs the file
+	// to. It is at most the redundancy of the file's erasure code.
+	TargetRedundancy float64 `json:"targetredundancy"`
+
+	// DataPieces and ParityPieces are the erasure coding parameters of the
+	// file. MaxRedundancy is the redundancy of the erasure code, which the
+	// file reaches once every piece is uploaded.
+	DataPieces    int     `json:"datapieces"`
+	ParityPieces  int     `json:"paritypieces"`
+	MaxRedundancy float64 `json:"maxredundancy"`
+
+	// EstimatedRepairTime is an estimate of the time until the file reaches
+	// full redundancy, based on the recent upload throughput of the renter.
+	// The estimate is only valid if EstimatedRepairTimeKnown is set.
+	EstimatedRepairTime      time.Duration `json:"estimatedrepairtime"`
+	EstimatedRepairTimeKnown bool          `json:"estimatedrepairtimeknown"`
+
+	// AuditRequired is set once a download of the file did not match the
+	// checksum that was computed when the file was uploaded.
+	AuditRequired bool `json:"auditrequired"`
+
+	// Versions is the number of previous versions of the file that are
+	// kept, and VersionBytes is the size of their pieces on the hosts.
+	Versions     int    `json:"versions"`
+	VersionBytes uint64 `json:"versionbytes"`
+}
+
+// A FileChecksum is the checksum of the plaintext of a file, computed when
+// the file was uploaded. Digest covers the whole file, and ChunkDigests
+// cover the consecutive sections of ChunkSize bytes of the file, so that a
+// section of the file can be verified without the rest of it.
+type FileChecksum struct {
+	Algorithm    string        `json:"algorithm"`
+	Digest       crypto.Hash   `json:"digest"`
+	ChunkSize    uint64        `json:"chunksize"`
+	ChunkDigests []crypto.Hash `json:"chunkdigests"`
+}
+
+// DirectoryInfo provides information about a directory of the renter. The
+// values are aggregated over all of the files in the directory and its
+// subdirectories. MinRedundancy is 0 for a directory without files.
+type DirectoryInfo struct {
+	SiaPath       string  `json:"siapath"`
+	NumFiles      uint64  `json:"numfiles"`
+	Filesize      uint64  `json:"filesize"`
+	MinRedundancy float64 `json:"minredundancy"`
+}
+
+// SearchOptions are the filters of a file search. Zero values do not filter.
+// Files without a known upload time never match the time filters.
+type SearchOptions struct {
+	UploadedAfter  time.Time
+	UploadedBefore time.Time
+	MinRedundancy  float64
+}
+
+// RenterFile provides information about a single file. The piece history is
+// only included in verbose queries.
+type RenterFile struct {
+	FileInfo
+	PieceHistory []RenterPieceHistory `json:"piecehistory,omitempty"`
+}
+
+// FileVersion provides information about one version of a file. Versions are
+// numbered from 1, and the version with the highest number is the current
+// version of the file.
+type FileVersion struct {
+	FileInfo
+	Version int  `json:"version"`
+	Current bool `json:"current"`
+}
+
+// RenterPieceHistory lists the hosts that a piece of a file has been uploaded
+// to, oldest first.
+type RenterPieceHistory struct {
+	Chunk  uint64             `json:"chunk"`
+	Piece  uint64             `json:"piece"`
+	Events []RenterPieceEvent `json:"events"`
+}
+
+// RenterPieceEvent records the upload of a piece to a host. Reason is empty
+// for the first upload of a piece, and explains why the piece was replaced
+// for uploads that repaired the piece.
+type RenterPieceEvent struct {
+	HostPublicKey types.SiaPublicKey   `json:"hostpublickey"`
+	ContractID    types.FileContractID `json:"contractid"`
+	Timestamp     time.Time            `json:"timestamp"`
+	Reason        string               `json:"reason,omitempty"`
 }
 
 // A HostDBEntry represents one host entry in the Renter's host DB. It
@@ -137,6 +391,23 @@ type HostScoreBreakdown struct {
 	VersionAdjustment          float64 `json:"versionadjustment"`
 }
 
+// HostScore breaks the score of a host down into the factors that the renter
+// uses to select hosts. Every factor is a multiplier on the score of the host;
+// a factor below 1 is a penalty and a factor above 1 is a reward.
+// CompositeScore is the product of all factors, so hosts with a higher
+// CompositeScore are more likely to be selected.
+type HostScore struct {
+	PublicKey             types.SiaPublicKey `json:"publickey"`
+	PriceAdjustedScore    float64            `json:"priceadjustedscore"`
+	CollateralScore       float64            `json:"collateralscore"`
+	StorageRemainingScore float64            `json:"storageremainingscore"`
+	UptimeScore           float64            `json:"uptimescore"`
+	AgeScore              float64            `json:"agescore"`
+	VersionScore          float64            `json:"versionscore"`
+	InteractionScore      float64            `json:"interactionscore"`
+	CompositeScore        float64            `json:"compositescore"`
+}
+
 // RenterPriceEstimation contains a bunch of files estimating the costs of
 // various operations on the network.
 type RenterPriceEstimation struct {
@@ -157,6 +428,52 @@ type RenterPriceEstimation struct {
 // RenterSettings control the behavior of the Renter.
 type RenterSettings struct {
 	Allowance Allowance `json:"allowance"`
+
+	// AutoRenewEnabled indicates whether contracts are automatically renewed
+	// with the same host as they approach expiry. RenewalThresholdBlocks is
+	// the number of blocks before a contract's end height at which renewal
+	// begins. A threshold of zero means the allowance's RenewWindow is used.
+	AutoRenewEnabled       bool              `json:"autorenewenabled"`
+	RenewalThresholdBlocks types.BlockHeight `json:"renewalthresholdblocks"`
+
+	// UploadRetryPolicy controls how failed piece uploads are retried.
+	UploadRetryPolicy UploadRetryPolicy `json:"uploadretrypolicy"`
+
+	// MaxDownloadSpeed is the combined download bandwidth from all hosts, in
+	// bytes per second. Zero means unlimited.
+	MaxDownloadSpeed int64 `json:"maxdownloadspeed"`
+
+	// MaxUploadSpeed is the combined upload bandwidth to all hosts, including
+	// repairs, in bytes per second. Zero means unlimited.
+	MaxUploadSpeed int64 `json:"maxuploadspeed"`
+
+	// ChecksumAlgorithm is the hash algorithm of the checksums of newly
+	// uploaded files. An empty algorithm selects ChecksumAlgorithmBlake2b.
+	ChecksumAlgorithm string `json:"checksumalgorithm"`
+}
+
+// RenterSettingsUpdate is a change of the RenterSettings. Only the settings
+// whose fields are not nil are changed, the others keep their current values.
+type RenterSettingsUpdate struct {
+	Allowance              *Allowance
+	AutoRenewEnabled       *bool
+	RenewalThresholdBlocks *types.BlockHeight
+	UploadRetryPolicy      *UploadRetryPolicy
+	MaxDownloadSpeed       *int64
+	MaxUploadSpeed         *int64
+	ChecksumAlgorithm      *string
+}
+
+// UploadRetryPolicy controls how the renter retries a piece upload that failed
+// on a host. The first retry happens immediately, to recover from transient
+// errors. Later retries back off, starting at InitialBackoff and growing
+// towards MaxBackoff. Once MaxAttempts uploads to the host have failed, the
+// piece is given to a different host. A zero MaxAttempts means that the
+// renter's default policy is used.
+type UploadRetryPolicy struct {
+	MaxAttempts    int           `json:"maxattempts"`
+	InitialBackoff time.Duration `json:"initialbackoff"`
+	MaxBackoff     time.Duration `json:"maxbackoff"`
 }
 
 // HostDBScans represents a sortable slice of scans.
@@ -217,10 +534,21 @@ type RenterContract struct {
 	SecretKey       crypto.SecretKey           `json:"secretkey"`
 	StartHeight     types.BlockHeight          `json:"startheight"`
 
+	// PinnedHostCert is the DER encoded TLS certificate that the renter
+	// pinned for the host of the contract. If it is set, the renter connects
+	// to the host over TLS and refuses hosts that present a different
+	// certificate.
+	PinnedHostCert []byte `json:"pinnedhostcert,omitempty"`
+
 	DownloadSpending types.Currency `json:"downloadspending"`
 	StorageSpending  types.Currency `json:"storagespending"`
 	UploadSpending   types.Currency `json:"uploadspending"`
 
+	// RepairDownloadSpending is the part of DownloadSpending that was spent
+	// on downloading the data of files that were repaired without a local
+	// copy.
+	RepairDownloadSpending types.Currency `json:"repairdownloadspending"`
+
 	// TotalCost indicates the amount of money that the renter spent and/or
 	// locked up while forming a contract. This includes fees, and includes
 	// funds which were allocated (but not necessarily committed) to spend on
@@ -248,6 +576,18 @@ type RenterContract struct {
 	GoodForRenew  bool
 	GoodForUpload bool
 
+	// Stale indicates that the host has a more recent revision of the
+	// contract than the renter, which the renter could not adopt. Stale
+	// contracts are not used for uploads. HostRevisionNumber is the revision
+	// number that the host reported.
+	Stale              bool   `json:"stale"`
+	HostRevisionNumber uint64 `json:"hostrevisionnumber"`
+
+	// Cancelled indicates that the user has cancelled the contract. Cancelled
+	// contracts are neither uploaded to nor renewed, and the funds that
+	// remain in them are lost until they expire.
+	Cancelled bool `json:"cancelled"`
+
 	// PreviousContracts contains the list of contracts which were previously
 	// rewned **for the same billing cylce**. This is not a full history of the
 	// contract line, but only a history within the billing cycle. The primary
@@ -256,14 +596,232 @@ type RenterContract struct {
 	PreviousContracts []RenterContract
 }
 
+// AllowanceRecord describes a change of the allowance of one of the renter's
+// allowance portfolios.
+type AllowanceRecord struct {
+	ChangedAt    time.Time         `json:"changedat"`
+	Height       types.BlockHeight `json:"height"`
+	Portfolio    string            `json:"portfolio"`
+	OldAllowance Allowance         `json:"oldallowance"`
+	NewAllowance Allowance         `json:"newallowance"`
+}
+
+// RenterPortfolio describes one of the renter's allowance portfolios. Each
+// portfolio has its own allowance, renewal policy, and contracts, and owns the
+// files whose siapaths begin with its SiaPathPrefix. The default portfolio
+// has no prefix and owns all other files.
+type RenterPortfolio struct {
+	Name          string `json:"name"`
+	SiaPathPrefix string `json:"siapathprefix"`
+
+	Allowance              Allowance         `json:"allowance"`
+	AutoRenewEnabled       bool              `json:"autorenewenabled"`
+	RenewalThresholdBlocks types.BlockHeight `json:"renewalthresholdblocks"`
+
+	// The fields below are reported by the renter and are ignored by
+	// SetPortfolio.
+	Contracts       int                    `json:"contracts"`
+	CurrentPeriod   types.BlockHeight      `json:"currentperiod"`
+	PendingRenewals []types.FileContractID `json:"pendingrenewals"`
+	Spending        ContractorSpending     `json:"spending"`
+	Identity        types.SiaPublicKey     `json:"identity"`
+}
+
+// RedundancyChange describes the re-encoding of a file to new erasure coding
+// parameters. The current version of the file remains in place and can be
+// downloaded until the new version has reached full redundancy.
+type RedundancyChange struct {
+	SiaPath         string    `json:"siapath"`
+	OldDataPieces   int       `json:"olddatapieces"`
+	OldParityPieces int       `json:"oldparitypieces"`
+	DataPieces      int       `json:"datapieces"`
+	ParityPieces    int       `json:"paritypieces"`
+	StartTime       time.Time `json:"starttime"`
+
+	// ChunksComplete is the number of chunks of the new version that have
+	// reached full redundancy. UploadProgress is the upload progress of the
+	// new version, as reported for files.
+	ChunksComplete uint64  `json:"chunkscomplete"`
+	ChunksTotal    uint64  `json:"chunkstotal"`
+	UploadProgress float64 `json:"uploadprogress"`
+
+	// Finished indicates that the new version has replaced the old version.
+	Finished   bool      `json:"finished"`
+	FinishTime time.Time `json:"finishtime"`
+}
+
 // ContractorSpending contains the metrics about how much the Contractor has
-// spent during the current billing period.
+// spent during the current billing period. RepairDownloadSpending is the part
+// of DownloadSpending that was spent on repairing files from the data stored
+// on hosts.
+//
+// ContractSpending is the total cost of the contracts of the period, which
+// consists of the ContractFees and the funds allocated to the contracts.
+// UnspentAllocated is the part of the allocated funds that has not been spent
+// and remains available in the active contracts. ExpectedReturn is the
+// allocated funds that remain in the contracts that have not ended yet, which
+// return to the wallet when the contracts end if they are not spent. Contracts
+// contains the same breakdown for every contract of the period, including the
+// contracts that were renewed or expired during the period.
 type ContractorSpending struct {
-	ContractSpending types.Currency `json:"contractspending"`
-	DownloadSpending types.Currency `json:"downloadspending"`
-	StorageSpending  types.Currency `json:"storagespending"`
-	UploadSpending   types.Currency `json:"uploadspending"`
-	Unspent          types.Currency `json:"unspent"`
+	ContractSpending       types.Currency `json:"contractspending"`
+	ContractFees           types.Currency `json:"contractfees"`
+	DownloadSpending       types.Currency `json:"downloadspending"`
+	RepairDownloadSpending types.Currency `json:"repairdownloadspending"`
+	StorageSpending        types.Currency `json:"storagespending"`
+	UploadSpending         types.Currency `json:"uploadspending"`
+	UnspentAllocated       types.Currency `json:"unspentallocated"`
+	ExpectedReturn         types.Currency `json:"expectedreturn"`
+	Unspent                types.Currency `json:"unspent"`
+
+	Contracts []ContractPeriodSpending `json:"contracts"`
+}
+
+// ContractPeriodSpending is the spending of a single contract during the
+// current billing period. Active is false for contracts that were renewed,
+// expired, or cancelled.
+type ContractPeriodSpending struct {
+	ID            types.FileContractID `json:"id"`
+	HostPublicKey types.SiaPublicKey   `json:"hostpublickey"`
+	NetAddress    NetAddress           `json:"netaddress"`
+	StartHeight   types.BlockHeight    `json:"startheight"`
+	EndHeight     types.BlockHeight    `json:"endheight"`
+	Active        bool                 `json:"active"`
+
+	ContractSpending       types.Currency `json:"contractspending"`
+	ContractFees           types.Currency `json:"contractfees"`
+	DownloadSpending       types.Currency `json:"downloadspending"`
+	RepairDownloadSpending types.Currency `json:"repairdownloadspending"`
+	StorageSpending        types.Currency `json:"storagespending"`
+	UploadSpending         types.Currency `json:"uploadspending"`
+	UnspentAllocated       types.Currency `json:"unspentallocated"`
+	ExpectedReturn         types.Currency `json:"expectedreturn"`
+}
+
+type (
+	// RenterAlert describes a condition on the renter that requires the
+	// attention of the user. At most one alert of each category is active at
+	// a time.
+	RenterAlert struct {
+		Severity RenterAlertSeverity `json:"severity"`
+		Category RenterAlertCategory `json:"category"`
+		Message  string              `json:"message"`
+
+		// Height is the block height at which the alert was raised.
+		Height types.BlockHeight `json:"height"`
+	}
+
+	// RenterAlertCategory identifies the part of the renter that an alert
+	// relates to.
+	RenterAlertCategory string
+
+	// RenterAlertSeverity indicates how urgently an alert should be acted
+	// upon.
+	RenterAlertSeverity string
+)
+
+// CacheStats describes the renter's disk cache of downloaded chunks. Hits,
+// Misses, and Evictions are counted since the renter started.
+type CacheStats struct {
+	MaxSize   uint64 `json:"maxsize"`
+	Size      uint64 `json:"size"`
+	Entries   uint64 `json:"entries"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+// The states of the circuit breaker of a host. The renter does not route new
+// work to a host whose circuit breaker is open. Once the cooldown of an open
+// breaker has passed, it is half-open while a single request probes the host,
+// and it closes again if the probe succeeds.
+const (
+	CircuitBreakerClosed   = "closed"
+	CircuitBreakerOpen     = "open"
+	CircuitBreakerHalfOpen = "halfopen"
+)
+
+// HostCircuitBreaker is the state of the circuit breaker of a host.
+// ConsecutiveFailures is the number of requests to the host that failed in a
+// row. OpenedAt is the time at which the breaker was last opened, and
+// ProbeTime is the time after which an open breaker probes the host.
+type HostCircuitBreaker struct {
+	HostPublicKey       types.SiaPublicKey `json:"hostpublickey"`
+	State               string             `json:"state"`
+	ConsecutiveFailures int                `json:"consecutivefailures"`
+	OpenedAt            time.Time          `json:"openedat"`
+	ProbeTime           time.Time          `json:"probetime"`
+}
+
+// RepairEstimate is an estimate of how long the renter needs to upload the
+// pieces that are missing from the files that it can repair, assuming that its
+// recent upload throughput stays the same. UploadThroughput is in bytes per
+// second, and is 0 if too few pieces were uploaded recently to measure it. The
+// estimate is only valid if EstimatedTimeKnown is set.
+type RepairEstimate struct {
+	UploadThroughput   float64       `json:"uploadthroughput"`
+	RemainingBytes     uint64        `json:"remainingbytes"`
+	EstimatedTime      time.Duration `json:"estimatedtime"`
+	EstimatedTimeKnown bool          `json:"estimatedtimeknown"`
+}
+
+// RenterBandwidth is the bandwidth that the renter currently transfers to and
+// from hosts, next to the limits of the bandwidth. The speeds are in bytes per
+// second, measured over the last second, and a limit of zero means unlimited.
+type RenterBandwidth struct {
+	UploadSpeed      uint64 `json:"uploadspeed"`
+	MaxUploadSpeed   int64  `json:"maxuploadspeed"`
+	DownloadSpeed    uint64 `json:"downloadspeed"`
+	MaxDownloadSpeed int64  `json:"maxdownloadspeed"`
+}
+
+// RenterPrefixUsage is the storage and bandwidth used by the files whose
+// siapaths are in the directory Prefix, or in one of its subdirectories.
+// LogicalBytes is the size of the files, and PhysicalBytes is the size of
+// their pieces that are stored on hosts. PhysicalBytes includes VersionBytes,
+// the size of the pieces of previous versions of the files that are still
+// retained. UploadBytes and DownloadBytes are the
+// piece data transferred to and from hosts since the renter started tracking
+// usage, including the transfers of files that have since been deleted. The
+// Month counters only count the transfers of the current calendar month.
+type RenterPrefixUsage struct {
+	Prefix             string `json:"prefix"`
+	Files              uint64 `json:"files"`
+	LogicalBytes       uint64 `json:"logicalbytes"`
+	PhysicalBytes      uint64 `json:"physicalbytes"`
+	VersionBytes       uint64 `json:"versionbytes"`
+	UploadBytes        uint64 `json:"uploadbytes"`
+	DownloadBytes      uint64 `json:"downloadbytes"`
+	MonthUploadBytes   uint64 `json:"monthuploadbytes"`
+	MonthDownloadBytes uint64 `json:"monthdownloadbytes"`
+}
+
+// RenterUsage breaks down the storage and bandwidth used by the renter by
+// siapath prefix. The prefixes are the directories of the files, truncated to
+// Depth levels; files that are not in a directory have the prefix "". The
+// counters of the prefixes sum to Total. MonthStart is the start of the
+// current calendar month in UTC, when the Month counters were reset.
+type RenterUsage struct {
+	Depth      int                 `json:"depth"`
+	MonthStart time.Time           `json:"monthstart"`
+	Prefixes   []RenterPrefixUsage `json:"prefixes"`
+	Total      RenterPrefixUsage   `json:"total"`
+}
+
+// ContractUtilization describes how much a contract of the renter is used.
+// DataStored is the size of the sectors that are stored in the contract.
+// DataUploaded and DataDownloaded count the piece data that the renter has
+// transferred through the contract since it was formed or renewed. FundsSpent
+// is what the renter has spent on the contract, including its fees, and
+// FundsRemaining is what is left of the renter's funds in the contract.
+type ContractUtilization struct {
+	ContractID     types.FileContractID `json:"contractid"`
+	HostAddress    NetAddress           `json:"hostaddress"`
+	DataStored     uint64               `json:"datastored"`
+	DataUploaded   uint64               `json:"datauploaded"`
+	DataDownloaded uint64               `json:"datadownloaded"`
+	FundsSpent     types.Currency       `json:"fundsspent"`
+	FundsRemaining types.Currency       `json:"fundsremaining"`
 }
 
 // EndHeight returns the height at which the host is no longer obligated to
@@ -289,26 +847,87 @@ type Renter interface {
 	// sorted by preference.
 	ActiveHosts() []HostDBEntry
 
+	// Alerts returns the alerts that are currently active on the renter.
+	Alerts() []RenterAlert
+
+	// AllowanceHistory returns the most recent changes of the allowance of
+	// the renter's portfolios, from oldest to newest. Settings that leave an
+	// allowance unchanged are not recorded.
+	AllowanceHistory() []AllowanceRecord
+
 	// AllHosts returns the full list of hosts known to the renter.
+	//
+	// Deprecated: on large networks the result can be very large, use
+	// AllHostsPaged instead.
 	AllHosts() []HostDBEntry
 
+	// AllHostsPaged returns the hosts on the provided page of the hosts known
+	// to the renter, sorted by public key, along with the total number of
+	// hosts. Pages are numbered from 0 and hold pageSize hosts each.
+	AllHostsPaged(page, pageSize int) ([]HostDBEntry, int, error)
+
+	// BackupToSia backs up the files of the directory tree at localPath to
+	// siaBackupPath. Only the files that are new or have changed since the
+	// last backup to siaBackupPath are uploaded. BackupToSia returns the
+	// updated manifest of the backup once the uploads have completed.
+	BackupToSia(localPath string, siaBackupPath string) (BackupManifest, error)
+
+	// CacheStats returns statistics about the disk cache of downloaded
+	// chunks.
+	CacheStats() CacheStats
+
+	// ChangeRedundancy re-encodes the file at siaPath, or every file in the
+	// directory at siaPath, to the provided erasure coding parameters. The
+	// re-encoding happens in the background, and the old version of a file is
+	// only replaced once the new version has reached full redundancy.
+	ChangeRedundancy(siaPath string, dataPieces, parityPieces int) error
+
 	// Close closes the Renter.
 	Close() error
 
 	// Contracts returns the contracts formed by the renter.
 	Contracts() []RenterContract
 
+	// ContractUtilization returns the utilization of the contracts of every
+	// portfolio, keyed by contract ID.
+	ContractUtilization() map[string]ContractUtilization
+
+	// CancelContract cancels the contract with the provided ID. The contract
+	// is no longer uploaded to or renewed, the files that have pieces in it
+	// are repaired, and the funds that remain in it are lost.
+	CancelContract(id types.FileContractID) error
+
 	// CurrentPeriod returns the height at which the current allowance period
 	// began.
 	CurrentPeriod() types.BlockHeight
 
+	// Identity returns the public key that the renter identifies itself with
+	// to hosts, which hosts with a renter whitelist check. Every portfolio
+	// has its own identity.
+	Identity() types.SiaPublicKey
+
 	// PeriodSpending returns the amount spent on contracts in the current
 	// billing period.
 	PeriodSpending() ContractorSpending
 
-	// DeleteFile deletes a file entry from the renter.
+	// CreateDir creates a directory and the directories that contain it.
+	// A '/' in a siapath separates directories.
+	CreateDir(siaPath string) error
+
+	// DeleteDir deletes a directory. A directory that is not empty is only
+	// deleted if recursive is set, which deletes all of its files.
+	DeleteDir(siaPath string, recursive bool) error
+
+	// DeleteFile deletes a file entry from the renter. If the version
+	// retention of the file keeps previous versions, the file is kept as
+	// its newest previous version.
 	DeleteFile(path string) error
 
+	// DirList returns the directories and files directly inside of a
+	// directory, with the size and redundancy of the files in each
+	// subdirectory rolled up. The empty siapath is the root directory.
+	DirList(siaPath string) ([]DirectoryInfo, []FileInfo, error)
+
 	// Download performs a download according to the parameters passed, including
 	// downloads of `offset` and `length` type.
 	Download(params RenterDownloadParameters) error
@@ -316,12 +935,52 @@ type Renter interface {
 	// DownloadQueue lists all the files that have been scheduled for download.
 	DownloadQueue() []DownloadInfo
 
+	// DownloadVersion performs a download of the provided version of the
+	// file at params.Siapath.
+	DownloadVersion(params RenterDownloadParameters, version int) error
+
+	// DownloadSection streams length bytes of the file at siaPath,
+	// starting at offset, to w like DownloadWriter does. Only the chunks
+	// that overlap the section are downloaded, and a length of 0 is
+	// rejected. The download is added to the download queue.
+	DownloadSection(siaPath string, offset, length uint64, w io.Writer) error
+
+	// DownloadWriter streams the file at siaPath to w. Chunks are written in
+	// order as they are recovered, and only a few chunks are downloaded ahead
+	// of the writer, so a slow writer slows down the download instead of
+	// filling up memory.
+	DownloadWriter(siaPath string, w io.Writer) error
+
+	// File returns information on the file at siaPath. The upload history of
+	// the file's pieces is included if verbose is set.
+	File(siaPath string, verbose bool) (RenterFile, error)
+
+	// FileChecksum returns the checksum of the file at siaPath that was
+	// computed when the file was uploaded.
+	FileChecksum(siaPath string) (FileChecksum, error)
+
 	// FileList returns information on all of the files stored by the renter.
 	FileList() []FileInfo
 
+	// FileSearch returns information on the files whose siapath matches the
+	// glob pattern query and that satisfy the search options.
+	FileSearch(query string, opts SearchOptions) []FileInfo
+
 	// Host provides the DB entry and score breakdown for the requested host.
 	Host(pk types.SiaPublicKey) (HostDBEntry, bool)
 
+	// HostCircuitBreaker returns the state of the circuit breaker of a host.
+	HostCircuitBreaker(pk types.SiaPublicKey) HostCircuitBreaker
+
+	// HostCircuitBreakers returns the state of the circuit breakers of the
+	// hosts that requests have recently failed on.
+	HostCircuitBreakers() []HostCircuitBreaker
+
+	// ListVersions returns the versions of the file at siaPath, oldest
+	// first. nil is returned if there is no file or previous version at
+	// siaPath.
+	ListVersions(siaPath string) []FileVersion
+
 	// LoadSharedFiles loads a '.sia' file into the renter. A .sia file may
 	// contain multiple files. The paths of the added files are returned.
 	LoadSharedFiles(source string) ([]string, error)
@@ -330,13 +989,58 @@ type Renter interface {
 	// renter.
 	LoadSharedFilesAscii(asciiSia string) ([]string, error)
 
+	// PendingRenewals returns the IDs of the contracts that are due to be
+	// renewed automatically.
+	PendingRenewals() []types.FileContractID
+
+	// Portfolio returns the allowance portfolio with the provided name.
+	Portfolio(name string) (RenterPortfolio, error)
+
+	// PortfolioContracts returns the contracts owned by the allowance
+	// portfolio with the provided name.
+	PortfolioContracts(name string) ([]RenterContract, error)
+
+	// PinFile immediately repairs the file at siaPath and blocks until the
+	// file has reached minRedundancy. An error reporting the achieved
+	// redundancy is returned if the repair stops making progress first.
+	PinFile(siaPath string, minRedundancy float64) error
+
+	// PurgeFile deletes the file at siaPath together with all of its
+	// previous versions, whose pieces are deleted from the hosts.
+	PurgeFile(siaPath string) error
+
+	// Portfolios returns all of the renter's allowance portfolios, starting
+	// with the default portfolio.
+	Portfolios() []RenterPortfolio
+
 	// PriceEstimation estimates the cost in siacoins of performing various
 	// storage and data operations.
 	PriceEstimation() RenterPriceEstimation
 
+	// RedundancyChanges returns the progress of the most recent redundancy
+	// change of each file. Finished redundancy changes are only reported until
+	// the renter restarts.
+	RedundancyChanges() []RedundancyChange
+
+	// RenameDir changes the path of a directory and of everything in it.
+	RenameDir(path, newPath string) error
+
 	// RenameFile changes the path of a file.
 	RenameFile(path, newPath string) error
 
+	// RepairEstimate returns an estimate of how long the renter needs to
+	// upload the pieces that are missing from the files that it can repair.
+	RepairEstimate() RepairEstimate
+
+	// RestoreFromSia downloads the files of a backup manifest into the
+	// directory at localPath, restoring their modification times.
+	RestoreFromSia(manifest BackupManifest, localPath string) error
+
+	// UsageByPrefix returns the storage and bandwidth used by the renter,
+	// broken down by the directories of the files, truncated to depth
+	// levels.
+	UsageByPrefix(depth int) (RenterUsage, error)
+
 	// EstimateHostScore will return the score for a host with the provided
 	// settings, assuming perfect age and uptime adjustments
 	EstimateHostScore(entry HostDBEntry) HostScoreBreakdown
@@ -345,11 +1049,65 @@ type Renter interface {
 	// hostdb's weighting algorithm.
 	ScoreBreakdown(entry HostDBEntry) HostScoreBreakdown
 
+	// HostScores returns the scoring factors of every host in the hostdb,
+	// sorted by increasing weight.
+	HostScores() []HostScore
+
 	// Settings returns the Renter's current settings.
 	Settings() RenterSettings
 
-	// SetSettings sets the Renter's settings.
-	SetSettings(RenterSettings) error
+	// GarbageContractCount returns the number of contracts whose data is no
+	// longer used by any file, and which are therefore not renewed.
+	GarbageContractCount() int
+
+	// TransferSpeeds returns the bandwidth that the renter currently
+	// transfers to and from hosts, and the limits of the bandwidth.
+	TransferSpeeds() RenterBandwidth
+
+	// SetCacheSize sets the maximum size in bytes of the disk cache of
+	// downloaded chunks. A size of 0 disables the cache.
+	SetCacheSize(bytes uint64) error
+
+	// SetFileRedundancy sets the redundancy that the renter repairs the
+	// file at siaPath to. Pieces beyond the redundancy are deleted from the
+	// hosts, and a redundancy beyond that of the file's erasure code
+	// re-encodes the file.
+	SetFileRedundancy(siaPath string, redundancy float64) error
+
+	// SetVersionRetention sets the version retention of the files in the
+	// directory dir, which takes precedence over the retention of its
+	// parent directories and the MaxVersionsPerFile of the allowance.
+	SetVersionRetention(dir string, vr VersionRetention) error
+
+	// ClearVersionRetention removes the version retention of the
+	// directory dir, so that its files use the retention of its parents.
+	ClearVersionRetention(dir string) error
+
+	// VersionRetention returns the version retention of the file or
+	// directory at siaPath.
+	VersionRetention(siaPath string) VersionRetention
+
+	// SetSettings changes the settings of the update that are set. If any of
+	// them is invalid, or the allowance cannot be set, none of the settings
+	// are changed.
+	SetSettings(RenterSettingsUpdate) error
+
+	// SetPinnedHostCert pins the TLS certificate that the host with the
+	// given public key must present when the renter connects to it. The
+	// certificate may be PEM or DER encoded. A nil certificate unpins it.
+	SetPinnedHostCert(hostKey types.SiaPublicKey, cert []byte) error
+
+	// SetPortfolio sets the allowance and renewal policy of an allowance
+	// portfolio, creating the portfolio if it does not exist yet.
+	SetPortfolio(RenterPortfolio) error
+
+	// ExportFileList writes the metadata of all files tracked by the renter
+	// to w, using the FileListFormatBinary or FileListFormatJSON format.
+	ExportFileList(w io.Writer, format string) error
+
+	// ImportFileList reads file metadata written by ExportFileList from r
+	// and merges it with the files tracked by the renter.
+	ImportFileList(r io.Reader, format string) error
 
 	// ShareFiles creates a '.sia' file that can be shared with others.
 	ShareFiles(paths []string, shareDest string) error
@@ -359,6 +1117,22 @@ type Renter interface {
 
 	// Upload uploads a file using the input parameters.
 	Upload(FileUploadParams) error
+
+	// UploadDirectory uploads every file in a directory tree using the input
+	// parameters. Uploading stops early if the context is cancelled.
+	UploadDirectory(context.Context, DirectoryUploadParams) (DirectoryUploadResult, error)
+
+	// UploadFromURL uploads the body of an HTTP(S) URL to siaPath without
+	// storing it on disk, using the erasure code of the upload parameters.
+	// It returns once the file has been created and uploads the body in the
+	// background. The upload stops if the context is cancelled.
+	UploadFromURL(ctx context.Context, sourceURL, siaPath string, params FileUploadParams) error
+
+	// UploadReader uploads the data of an io.Reader to siaPath without
+	// storing it on disk. It returns once the reader has returned io.EOF and
+	// the data has been uploaded. The file has no local path, so it is
+	// repaired from the data stored on the hosts.
+	UploadReader(siaPath string, r io.Reader, ec ErasureCoder) error
 }
 
 // RenterDownloadParameters defines the parameters passed to the Renter's
@@ -0,0 +1,416 @@
+package renter
+
+// backup.go implements incremental backups of a local directory tree to Sia.
+// The files of a backup are uploaded into the files directory below the
+// siapath of the backup, and the manifest of the backup is uploaded next to
+// that directory, so that a backup can be restored from Sia alone. The manifest
+// of the latest backup to each siapath is also kept in the renter's persist
+// directory, and the next backup only uploads the files whose size or
+// modification time differ from that manifest.
+//
+// A file that replaces the backup of a previous version is uploaded to a
+// temporary siapath first, and only renamed over the previous version once
+// its upload has completed, so that a failed upload never loses the previous
+// backup. The manifest is written once all uploads have completed.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+)
+
+const (
+	// backupDir is the directory within the renter's persist directory that
+	// holds the manifests of the latest backups.
+	backupDir = "backups"
+
+	// backupFilesDir is the directory below the siapath of a backup that the
+	// backed up files are uploaded to.
+	backupFilesDir = "files"
+
+	// backupManifestName is the name of the manifest that is uploaded below
+	// the siapath of a backup.
+	backupManifestName = "manifest.json"
+
+	// backupUploadSuffix is appended to the siapath of a backed up file to
+	// form the temporary siapath that a replacement of the file is uploaded
+	// to.
+	backupUploadSuffix = ".backup-upload"
+)
+
+var (
+	// errBackupInvalidPath is returned if a file of a backup manifest would
+	// be restored outside of the directory that the backup is restored to.
+	errBackupInvalidPath = errors.New("path of the backed up file is outside of the backup")
+
+	// errBackupUploadIncomplete is returned if the upload of a backed up file
+	// stopped before the file could be downloaded.
+	errBackupUploadIncomplete = errors.New("upload of the backed up file did not complete")
+)
+
+// backupUpload is the upload of a file of a backup that has been started by
+// managedStartBackupUpload.
+type backupUpload struct {
+	file       *file
+	siaPath    string
+	uploadPath string
+}
+
+// backupManifestPath returns the path of the local copy of the manifest of the
+// latest backup to siaBackupPath.
+func (r *Renter) backupManifestPath(siaBackupPath string) string {
+	return filepath.Join(r.persistDir, backupDir, crypto.HashBytes([]byte(siaBackupPath)).String()+".json")
+}
+
+// loadBackupManifest loads the manifest at filename. An empty manifest is
+// returned if the file does not exist.
+func loadBackupManifest(filename string) (modules.BackupManifest, error) {
+	var manifest modules.BackupManifest
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	} else if err != nil {
+		return manifest, err
+	}
+	err = json.Unmarshal(data, &manifest)
+	return manifest, err
+}
+
+// saveBackupManifest writes the manifest to filename atomically. The manifest
+// is stored as plain JSON, so that its uploaded copy can be read without the
+// renter.
+func saveBackupManifest(manifest modules.BackupManifest, filename string) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+	file, err := persist.NewSafeFile(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.CommitSync()
+}
+
+// managedStartBackupUpload starts uploading the file at source to siaPath. If
+// siaPath holds the previous backup of the file and versioning is disabled,
+// the file is uploaded to a temporary siapath instead, and the previous backup
+// is only replaced by managedFinishBackupUpload.
+func (r *Renter) managedStartBackupUpload(source, siaPath string) (backupUpload, error) {
+	id := r.mu.RLock()
+	_, exists := r.files[siaPath]
+	keepVersions := keepsVersions(r.retentionFor(siaPath))
+	r.mu.RUnlock(id)
+	bu := backupUpload{
+		siaPath:    siaPath,
+		uploadPath: siaPath,
+	}
+	if exists && !keepVersions {
+		bu.uploadPath = siaPath + backupUploadSuffix
+		// Remove the leftovers of a backup that did not complete.
+		if err := r.PurgeFile(bu.uploadPath); err != nil && err != ErrUnknownPath {
+			return backupUpload{}, err
+		}
+	}
+	err := r.Upload(modules.FileUploadParams{
+		Source:  source,
+		SiaPath: bu.uploadPath,
+	})
+	if err != nil {
+		return backupUpload{}, err
+	}
+	id = r.mu.RLock()
+	bu.file = r.files[bu.uploadPath]
+	r.mu.RUnlock(id)
+	return bu, nil
+}
+
+// managedFinishBackupUpload waits for the upload bu to complete, and then
+// replaces the previous backup at its siapath if the file was uploaded to a
+// temporary siapath. If the upload does not complete, the previous backup is
+// kept and the temporary file is removed.
+func (r *Renter) managedFinishBackupUpload(bu backupUpload) error {
+	err := r.managedWaitForUpload(bu.file)
+	if err != nil {
+		if bu.uploadPath != bu.siaPath {
+			r.PurgeFile(bu.uploadPath)
+		}
+		return err
+	}
+	if bu.uploadPath == bu.siaPath {
+		return nil
+	}
+	return r.managedReplaceFile(bu.uploadPath, bu.siaPath)
+}
+
+// managedWaitForUpload blocks until none of the chunks of f are being
+// uploaded. errBackupUploadIncomplete is returned if f cannot be downloaded
+// afterwards.
+func (r *Renter) managedWaitForUpload(f *file) error {
+	for {
+		f.mu.RLock()
+		inRepair := f.chunksInRepair
+		f.mu.RUnlock()
+		if inRepair == 0 {
+			break
+		}
+		select {
+		case <-r.tg.StopChan():
+			return errors.New("renter shut down before the upload completed")
+		case <-time.After(pinFileCheckInterval):
+		}
+	}
+	if r.managedFileRedundancy(f) < 1 {
+		return errBackupUploadIncomplete
+	}
+	return nil
+}
+
+// managedReplaceFile deletes the file at siaPath and renames the file at
+// uploadPath to siaPath. The pieces of the deleted file are deleted from the
+// hosts.
+func (r *Renter) managedReplaceFile(uploadPath, siaPath string) error {
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	if _, exists := r.files[uploadPath]; !exists {
+		return ErrUnknownPath
+	}
+	if old, exists := r.files[siaPath]; exists {
+		r.deleteFile(siaPath, old)
+		go r.threadedDeletePieces(old, r.fileContractor(old))
+	}
+	if err := r.checkRename(uploadPath, siaPath); err != nil {
+		return err
+	}
+	if err := r.renameFile(uploadPath, siaPath); err != nil {
+		return err
+	}
+	if err := r.saveSync(); err != nil {
+		return err
+	}
+	return r.removeRenamedFile(uploadPath)
+}
+
+// managedFileExists reports whether the renter has a file at siaPath.
+func (r *Renter) managedFileExists(siaPath string) bool {
+	id := r.mu.RLock()
+	_, exists := r.files[siaPath]
+	r.mu.RUnlock(id)
+	return exists
+}
+
+// BackupToSia backs up the files of the directory tree at localPath to
+// siaBackupPath. A file is uploaded if it was not part of the previous backup
+// to siaBackupPath, or if its size or modification time have changed since.
+// Files that were removed from localPath are deleted from the backup.
+// BackupToSia blocks until the uploads have completed, and only then saves
+// and uploads the manifest of the backup.
+//
+// Files that cannot be read or uploaded keep their entry of the previous
+// backup, and are reported in the returned error. The manifest is returned
+// even if some files failed.
+func (r *Renter) BackupToSia(localPath string, siaBackupPath string) (modules.BackupManifest, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.BackupManifest{}, err
+	}
+	defer r.tg.Done()
+
+	if err := validateSiapath(siaBackupPath); err != nil {
+		return modules.BackupManifest{}, err
+	}
+	localPath, err := filepath.Abs(localPath)
+	if err != nil {
+		return modules.BackupManifest{}, err
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return modules.BackupManifest{}, err
+	}
+	if !info.IsDir() {
+		return modules.BackupManifest{}, errUploadNotDirectory
+	}
+	manifestPath := r.backupManifestPath(siaBackupPath)
+	previous, err := loadBackupManifest(manifestPath)
+	if err != nil {
+		return modules.BackupManifest{}, build.ExtendErr("unable to load the manifest of the previous backup", err)
+	}
+	previousFiles := make(map[string]modules.BackupFile)
+	for _, bf := range previous.Files {
+		previousFiles[bf.Path] = bf
+	}
+
+	// Collect the files of the directory tree.
+	filesDir := path.Join(siaBackupPath, backupFilesDir)
+	dw := &directoryWalker{
+		ctx:     context.Background(),
+		params:  modules.DirectoryUploadParams{Recursive: true},
+		visited: make(map[string]struct{}),
+	}
+	if err := dw.walk(localPath, filesDir); err != nil {
+		return modules.BackupManifest{}, err
+	}
+
+	// keepPrevious keeps the entries of the previous backup at relPath, and
+	// below relPath if it is a directory that could not be read.
+	var errs []error
+	files := make(map[string]modules.BackupFile)
+	type pendingUpload struct {
+		backupUpload
+		source string
+		file   modules.BackupFile
+	}
+	var pending []pendingUpload
+	keepPrevious := func(relPath string) {
+		for p, bf := range previousFiles {
+			if relPath == "" || p == relPath || strings.HasPrefix(p, relPath+"/") {
+				files[p] = bf
+			}
+		}
+	}
+	for _, status := range dw.files {
+		relPath := strings.TrimPrefix(strings.TrimPrefix(status.SiaPath, filesDir), "/")
+		if status.Error != "" {
+			errs = append(errs, fmt.Errorf("%v: %v", status.Source, status.Error))
+			keepPrevious(relPath)
+			continue
+		}
+		info, err := os.Stat(status.Source)
+		if err != nil {
+			errs = append(errs, err)
+			keepPrevious(relPath)
+			continue
+		}
+		bf := modules.BackupFile{
+			Path:    relPath,
+			SiaPath: status.SiaPath,
+			ModTime: info.ModTime(),
+			Size:    info.Size(),
+		}
+		prev, backedUp := previousFiles[relPath]
+		if backedUp && prev.ModTime.Equal(bf.ModTime) && prev.Size == bf.Size && r.managedFileExists(prev.SiaPath) {
+			files[relPath] = prev
+			continue
+		}
+		bu, err := r.managedStartBackupUpload(status.Source, bf.SiaPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v", status.Source, err))
+			keepPrevious(relPath)
+			continue
+		}
+		pending = append(pending, pendingUpload{backupUpload: bu, source: status.Source, file: bf})
+	}
+
+	// Wait for the uploads to complete before the previous backups of the
+	// files are replaced.
+	for _, pu := range pending {
+		if err := r.managedFinishBackupUpload(pu.backupUpload); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v", pu.source, err))
+			keepPrevious(pu.file.Path)
+			continue
+		}
+		files[pu.file.Path] = pu.file
+	}
+
+	// Delete the files that were removed from the directory tree.
+	for p, bf := range previousFiles {
+		if _, exists := files[p]; exists {
+			continue
+		}
+		if err := r.DeleteFile(bf.SiaPath); err != nil && err != ErrUnknownPath {
+			errs = append(errs, fmt.Errorf("%v: %v", bf.SiaPath, err))
+		}
+	}
+
+	// Save the manifest and upload it next to the backed up files.
+	manifest := modules.BackupManifest{
+		LocalPath: localPath,
+		SiaPath:   siaBackupPath,
+		Time:      time.Now(),
+		Files:     make([]modules.BackupFile, 0, len(files)),
+	}
+	for _, bf := range files {
+		manifest.Files = append(manifest.Files, bf)
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Path < manifest.Files[j].Path
+	})
+	if err := saveBackupManifest(manifest, manifestPath); err != nil {
+		return modules.BackupManifest{}, build.ExtendErr("unable to save the backup manifest", err)
+	}
+	bu, err := r.managedStartBackupUpload(manifestPath, path.Join(siaBackupPath, backupManifestName))
+	if err == nil {
+		err = r.managedFinishBackupUpload(bu)
+	}
+	if err != nil {
+		errs = append(errs, build.ExtendErr("unable to upload the backup manifest", err))
+	}
+	return manifest, build.ComposeErrors(errs...)
+}
+
+// managedRestoreBackupFile downloads a file of a backup manifest into the
+// directory at localPath.
+func (r *Renter) managedRestoreBackupFile(bf modules.BackupFile, localPath string) error {
+	relPath := path.Clean(bf.Path)
+	if bf.Path == "" || path.IsAbs(relPath) || relPath == ".." || strings.HasPrefix(relPath, "../") {
+		return errBackupInvalidPath
+	}
+	dst := filepath.Join(localPath, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	// Empty files cannot be downloaded, they are created instead.
+	var err error
+	if bf.Size == 0 {
+		err = ioutil.WriteFile(dst, nil, 0600)
+	} else {
+		err = r.Download(modules.RenterDownloadParameters{
+			Siapath:     bf.SiaPath,
+			Destination: dst,
+		})
+	}
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(dst, bf.ModTime, bf.ModTime)
+}
+
+// RestoreFromSia downloads the files of a backup manifest into the directory
+// at localPath, restoring their modification times. Files that fail to
+// download do not stop the restore, they are reported in the returned error.
+func (r *Renter) RestoreFromSia(manifest modules.BackupManifest, localPath string) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	localPath, err := filepath.Abs(localPath)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, bf := range manifest.Files {
+		if err := r.managedRestoreBackupFile(bf, localPath); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v", bf.Path, err))
+		}
+	}
+	return build.ComposeErrors(errs...)
+}
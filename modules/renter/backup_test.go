@@ -0,0 +1,237 @@
+package renter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestBackupToSia checks that a backup uploads every file of the directory
+// tree, and that the next backup only uploads the files that are new or have
+// changed, and deletes the files that were removed.
+func TestBackupToSia(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	tc := &throttledContractor{uploadTime: time.Millisecond}
+	for i := 0; i < 3; i++ {
+		tc.contracts = append(tc.contracts, modules.RenterContract{
+			ID:            types.FileContractID{byte(i + 1)},
+			HostPublicKey: types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{byte(i + 1)}},
+			GoodForUpload: true,
+			GoodForRenew:  true,
+		})
+	}
+	rt, err := newContractorTester(t.Name(), nil, tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	dir := build.TempDir("renter", t.Name(), "source")
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", filepath.Join("sub", "c")} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), fastrand.Bytes(100), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := rt.renter.BackupToSia(filepath.Join(dir, "a"), "backup"); err != errUploadNotDirectory {
+		t.Fatal("expected errUploadNotDirectory, got", err)
+	}
+
+	manifest, err := rt.renter.BackupToSia(dir, "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Files) != 3 || manifest.Files[2].Path != "sub/c" || manifest.Files[2].SiaPath != "backup/files/sub/c" {
+		t.Fatalf("wrong manifest: %+v", manifest)
+	}
+	if !rt.renter.managedFileExists("backup/" + backupManifestName) {
+		t.Fatal("manifest was not uploaded")
+	}
+	uploadTime := func(siaPath string) time.Time {
+		rf, err := rt.renter.File(siaPath, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rf.UploadTime
+	}
+	firstUploads := make(map[string]time.Time)
+	for _, bf := range manifest.Files {
+		firstUploads[bf.Path] = uploadTime(bf.SiaPath)
+	}
+
+	// Change a, remove b, and add d.
+	changed := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "a"), changed, changed); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "d"), fastrand.Bytes(100), 0600); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err = rt.renter.BackupToSia(dir, "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, bf := range manifest.Files {
+		paths = append(paths, bf.Path)
+	}
+	if len(paths) != 3 || paths[0] != "a" || paths[1] != "d" || paths[2] != "sub/c" {
+		t.Fatal("wrong files in the second manifest:", paths)
+	}
+	if !manifest.Files[0].ModTime.Equal(changed) || uploadTime("backup/files/a") == firstUploads["a"] {
+		t.Fatal("changed file was not uploaded again")
+	}
+	if uploadTime("backup/files/sub/c") != firstUploads["sub/c"] {
+		t.Fatal("unchanged file was uploaded again")
+	}
+	if rt.renter.managedFileExists("backup/files/b") {
+		t.Fatal("removed file was not deleted from the backup")
+	}
+	if rt.renter.managedFileExists("backup/files/a" + backupUploadSuffix) {
+		t.Fatal("temporary upload of the changed file was not renamed")
+	}
+}
+
+// TestBackupUploadReplace checks that the previous backup of a file is only
+// replaced once the upload of the new version has completed, and that it is
+// kept if the upload does not complete.
+func TestBackupUploadReplace(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	tc := &throttledContractor{uploadTime: 50 * time.Millisecond}
+	for i := 0; i < 3; i++ {
+		tc.contracts = append(tc.contracts, modules.RenterContract{
+			ID:            types.FileContractID{byte(i + 1)},
+			HostPublicKey: types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{byte(i + 1)}},
+			GoodForUpload: true,
+			GoodForRenew:  true,
+		})
+	}
+	rt, err := newContractorTester(t.Name(), nil, tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	source := filepath.Join(build.TempDir("renter", t.Name()), "source")
+	if err := os.MkdirAll(filepath.Dir(source), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(source, fastrand.Bytes(100), 0600); err != nil {
+		t.Fatal(err)
+	}
+	bu, err := rt.renter.managedStartBackupUpload(source, "backup/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bu.uploadPath != "backup/file" {
+		t.Fatal("a new file should be uploaded to its own siapath, got", bu.uploadPath)
+	}
+	if err := rt.renter.managedFinishBackupUpload(bu); err != nil {
+		t.Fatal(err)
+	}
+	first, err := rt.renter.File("backup/file", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The replacement is uploaded next to the previous backup, which is only
+	// replaced once the upload has completed.
+	bu, err = rt.renter.managedStartBackupUpload(source, "backup/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bu.uploadPath != "backup/file"+backupUploadSuffix {
+		t.Fatal("the replacement should be uploaded to a temporary siapath, got", bu.uploadPath)
+	}
+	if rf, err := rt.renter.File("backup/file", false); err != nil || rf.UploadTime != first.UploadTime {
+		t.Fatal("previous backup was replaced before the upload completed:", err)
+	}
+	if err := rt.renter.managedFinishBackupUpload(bu); err != nil {
+		t.Fatal(err)
+	}
+	second, err := rt.renter.File("backup/file", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.UploadTime == first.UploadTime {
+		t.Fatal("previous backup was not replaced")
+	}
+	if rt.renter.managedFileExists(bu.uploadPath) {
+		t.Fatal("temporary upload was not renamed")
+	}
+
+	// An upload that did not complete leaves the previous backup in place.
+	rsc, _ := NewRSCode(defaultDataPieces, defaultParityPieces)
+	f := newFile(bu.uploadPath, rsc, pieceSize, 100)
+	id := rt.renter.mu.Lock()
+	rt.renter.files[bu.uploadPath] = f
+	rt.renter.mu.Unlock(id)
+	bu.file = f
+	if err := rt.renter.managedFinishBackupUpload(bu); err != errBackupUploadIncomplete {
+		t.Fatal("expected errBackupUploadIncomplete, got", err)
+	}
+	if rt.renter.managedFileExists(bu.uploadPath) {
+		t.Fatal("incomplete upload was not removed")
+	}
+	if rf, err := rt.renter.File("backup/file", false); err != nil || rf.UploadTime != second.UploadTime {
+		t.Fatal("previous backup was not kept:", err)
+	}
+}
+
+// TestRestoreFromSiaInvalidPath checks that files of a manifest are not
+// restored outside of the destination directory.
+func TestRestoreFromSiaInvalidPath(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	dir := build.TempDir("renter", t.Name(), "restore")
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	manifest := modules.BackupManifest{
+		Files: []modules.BackupFile{
+			{Path: "../escape", SiaPath: "backup/files/escape", ModTime: modTime},
+			{Path: "sub/empty", SiaPath: "backup/files/sub/empty", ModTime: modTime},
+		},
+	}
+	if err := rt.renter.RestoreFromSia(manifest, dir); err == nil {
+		t.Fatal("expected the restore of a path outside of the backup to fail")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape")); !os.IsNotExist(err) {
+		t.Fatal("file was restored outside of the destination:", err)
+	}
+
+	// The other files of the manifest are still restored.
+	info, err := os.Stat(filepath.Join(dir, "sub", "empty"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 || !info.ModTime().Equal(modTime) {
+		t.Fatalf("wrong restored empty file: size %v, modified %v", info.Size(), info.ModTime())
+	}
+}
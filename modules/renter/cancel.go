@@ -0,0 +1,79 @@
+package renter
+
+// cancel.go cancels individual contracts. A cancelled contract is no longer
+// uploaded to or renewed, and the pieces that are stored in it no longer count
+// for the redundancy of their files, so the files that have pieces in the
+// contract are handed to the repair loop to upload those pieces to other
+// hosts. The funds that remain in the contract are lost; the host keeps them
+// once the contract expires.
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var errUnknownContract = errors.New("no record of that contract")
+
+// CancelContract cancels the contract with the provided ID, and repairs the
+// files that have pieces stored in it.
+func (r *Renter) CancelContract(id types.FileContractID) error {
+	var name string
+	var hc hostContractor
+	for n, c := range r.managedPortfolioContractors() {
+		if _, exists := c.ResolveContract(id); exists {
+			name, hc = n, c
+			break
+		}
+	}
+	if hc == nil {
+		return errUnknownContract
+	}
+	if err := hc.CancelContract(id); err != nil {
+		return err
+	}
+	id = hc.ResolveID(id)
+
+	// Collect the contracts of the tracked files of the portfolio. The IDs
+	// are resolved to the most recent renewal once the renter lock has been
+	// released.
+	fileContracts := make(map[*file][]types.FileContractID)
+	lockID := r.mu.RLock()
+	for siaPath, f := range r.files {
+		if _, tracked := r.tracking[siaPath]; !tracked || r.portfolioForSiaPath(siaPath) != name {
+			continue
+		}
+		f.mu.RLock()
+		for fcid, fc := range f.contracts {
+			if len(fc.Pieces) > 0 {
+				fileContracts[f] = append(fileContracts[f], fcid)
+			}
+		}
+		f.mu.RUnlock()
+	}
+	r.mu.RUnlock(lockID)
+
+	var repairs []*file
+	for f, ids := range fileContracts {
+		for _, fcid := range ids {
+			if hc.ResolveID(fcid) == id {
+				repairs = append(repairs, f)
+				break
+			}
+		}
+	}
+	r.log.Printf("Cancelled contract %v, repairing %v files\n", id, len(repairs))
+	go r.threadedQueueRepairs(repairs)
+	return nil
+}
+
+// threadedQueueRepairs hands the files to the repair loop one after another.
+func (r *Renter) threadedQueueRepairs(files []*file) {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+	for _, f := range files {
+		r.managedQueueRepair(f)
+	}
+}
@@ -0,0 +1,145 @@
+package renter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// cancellingContractor is a throttledContractor whose contracts can be
+// cancelled.
+type cancellingContractor struct {
+	throttledContractor
+	mu sync.Mutex
+}
+
+func (cc *cancellingContractor) Contracts() []modules.RenterContract {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return append([]modules.RenterContract(nil), cc.contracts...)
+}
+func (cc *cancellingContractor) ContractByID(id types.FileContractID) (modules.RenterContract, bool) {
+	for _, c := range cc.Contracts() {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return modules.RenterContract{}, false
+}
+func (cc *cancellingContractor) ResolveContract(id types.FileContractID) (modules.RenterContract, bool) {
+	return cc.ContractByID(id)
+}
+func (cc *cancellingContractor) CancelContract(id types.FileContractID) error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for i := range cc.contracts {
+		if cc.contracts[i].ID == id {
+			cc.contracts[i].Cancelled = true
+			cc.contracts[i].GoodForUpload = false
+			cc.contracts[i].GoodForRenew = false
+			return nil
+		}
+	}
+	return errUnknownContract
+}
+
+// TestCancelContract checks that the pieces of a file that are stored in a
+// cancelled contract are uploaded to other hosts.
+func TestCancelContract(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	cc := &cancellingContractor{throttledContractor: throttledContractor{uploadTime: time.Millisecond}}
+	for i := 0; i < 3; i++ {
+		cc.contracts = append(cc.contracts, modules.RenterContract{
+			ID:            types.FileContractID{byte(i + 1)},
+			HostPublicKey: types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{byte(i + 1)}},
+			GoodForUpload: true,
+			GoodForRenew:  true,
+		})
+	}
+	rt, err := newContractorTester(t.Name(), nil, cc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Upload a file of 2 chunks with a 1-of-2 erasure code, which leaves one
+	// of the hosts unused by every chunk.
+	ec, err := NewRSCode(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := build.TempDir("renter", t.Name(), "source")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(source, fastrand.Bytes(int(2*pieceSize)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	err = rt.renter.Upload(modules.FileUploadParams{
+		Source:      source,
+		SiaPath:     "foo",
+		ErasureCode: ec,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForRedundancy := func() {
+		err := build.Retry(100, 50*time.Millisecond, func() error {
+			if fi := rt.renter.FileList()[0]; fi.Redundancy != 2 {
+				return fmt.Errorf("file has a redundancy of %v, expected 2", fi.Redundancy)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitForRedundancy()
+
+	if err := rt.renter.CancelContract(types.FileContractID{4}); err != errUnknownContract {
+		t.Fatal("expected errUnknownContract, got", err)
+	}
+	id := rt.renter.mu.RLock()
+	f := rt.renter.files["foo"]
+	rt.renter.mu.RUnlock(id)
+	f.mu.RLock()
+	var cancelled types.FileContractID
+	for fcid, fc := range f.contracts {
+		if len(fc.Pieces) > 0 {
+			cancelled = fcid
+			break
+		}
+	}
+	f.mu.RUnlock()
+	if err := rt.renter.CancelContract(cancelled); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := cc.ContractByID(cancelled); !c.Cancelled {
+		t.Fatal("contract was not cancelled")
+	}
+
+	// The pieces in the cancelled contract no longer count, and are replaced
+	// by pieces on the unused hosts.
+	waitForRedundancy()
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for fcid, fc := range f.contracts {
+		if fcid != cancelled && len(fc.Pieces) != 2 {
+			t.Fatalf("contract %v stores %v pieces, expected 2", fcid, len(fc.Pieces))
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package renter
+
+// checksum.go computes and verifies the checksums of the plaintext of
+// uploaded files. Files that are uploaded from disk are hashed before their
+// upload starts, and streamed files are hashed as they are read. Downloads
+// verify every chunk against the digest of its section of the file before the
+// chunk is written, and downloads of a whole file to disk verify the digest
+// of the whole file once it has been written. A download that does not match
+// the checksum fails, and its file is marked for an audit.
+
+import (
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+var (
+	errChecksumAlgorithm = errors.New("unknown checksum algorithm")
+	errChecksumMismatch  = errors.New("downloaded data does not match the checksum of the file")
+	errNoChecksum        = errors.New("file was uploaded without a checksum")
+)
+
+// A checksumBuilder computes the checksum of a file from the data of its
+// chunks, which are added in order.
+type checksumBuilder struct {
+	checksum modules.FileChecksum
+	file     hash.Hash
+}
+
+// newChecksumHash returns a hash of the checksum algorithm alg.
+func newChecksumHash(alg string) (hash.Hash, error) {
+	switch alg {
+	case modules.ChecksumAlgorithmBlake2b:
+		return crypto.NewHash(), nil
+	case modules.ChecksumAlgorithmSHA256:
+		return sha256.New(), nil
+	}
+	return nil, errChecksumAlgorithm
+}
+
+// checksumDigest returns the digest of data using the checksum algorithm alg.
+func checksumDigest(alg string, data []byte) (digest crypto.Hash, err error) {
+	h, err := newChecksumHash(alg)
+	if err != nil {
+		return digest, err
+	}
+	h.Write(data)
+	copy(digest[:], h.Sum(nil))
+	return digest, nil
+}
+
+// newChecksumBuilder returns a checksumBuilder for a file with chunks of
+// chunkSize bytes.
+func newChecksumBuilder(alg string, chunkSize uint64) (*checksumBuilder, error) {
+	h, err := newChecksumHash(alg)
+	if err != nil {
+		return nil, err
+	}
+	return &checksumBuilder{
+		checksum: modules.FileChecksum{
+			Algorithm: alg,
+			ChunkSize: chunkSize,
+		},
+		file: h,
+	}, nil
+}
+
+// addChunk adds the data of the next chunk of the file to the checksum.
+func (cb *checksumBuilder) addChunk(data []byte) {
+	cb.file.Write(data)
+	digest, _ := checksumDigest(cb.checksum.Algorithm, data)
+	cb.checksum.ChunkDigests = append(cb.checksum.ChunkDigests, digest)
+}
+
+// sum returns the checksum of the chunks that have been added.
+func (cb *checksumBuilder) sum() modules.FileChecksum {
+	cs := cb.checksum
+	copy(cs.Digest[:], cb.file.Sum(nil))
+	cs.ChunkDigests = append([]crypto.Hash(nil), cs.ChunkDigests...)
+	return cs
+}
+
+// computeFileChecksum computes the checksum of the first size bytes of the
+// file at path, split into chunks of chunkSize bytes.
+func computeFileChecksum(path, alg string, chunkSize, size uint64) (modules.FileChecksum, error) {
+	cb, err := newChecksumBuilder(alg, chunkSize)
+	if err != nil {
+		return modules.FileChecksum{}, err
+	}
+	osFile, err := os.Open(path)
+	if err != nil {
+		return modules.FileChecksum{}, err
+	}
+	defer osFile.Close()
+
+	// Empty files still have one chunk, as in numChunks.
+	r := io.LimitReader(osFile, int64(size))
+	buf := make([]byte, chunkSize)
+	for index := 0; ; index++ {
+		n, err := io.ReadFull(r, buf)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return modules.FileChecksum{}, err
+		}
+		if n == 0 && index > 0 {
+			break
+		}
+		cb.addChunk(buf[:n])
+		if last {
+			break
+		}
+	}
+	return cb.sum(), nil
+}
+
+// verifyFileChecksum checks the first size bytes of the file at path against
+// the digest of cs.
+func verifyFileChecksum(path string, cs modules.FileChecksum, size uint64) error {
+	h, err := newChecksumHash(cs.Algorithm)
+	if err != nil {
+		return err
+	}
+	osFile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer osFile.Close()
+	if _, err := io.Copy(h, io.LimitReader(osFile, int64(size))); err != nil {
+		return err
+	}
+	var digest crypto.Hash
+	copy(digest[:], h.Sum(nil))
+	if digest != cs.Digest {
+		return errChecksumMismatch
+	}
+	return nil
+}
+
+// verifyChunk checks the recovered data of the chunk at index against the
+// checksum of the file. A chunk can only be verified if the checksum has a
+// digest for it, which is not the case for files that were uploaded without a
+// checksum, or whose erasure code was changed to one with a different chunk
+// size since. Chunks that cannot be verified are recorded, so that the
+// download reports an unavailable checksum. verifyChunk must only be called
+// from the download loop thread.
+func (d *download) verifyChunk(index uint64, data []byte) error {
+	cs := d.checksum
+	if cs.ChunkSize != d.chunkSize || index >= uint64(len(cs.ChunkDigests)) {
+		d.chunksUnverified = true
+		return nil
+	}
+	digest, err := checksumDigest(cs.Algorithm, data)
+	if err != nil {
+		d.chunksUnverified = true
+		return nil
+	}
+	if digest != cs.ChunkDigests[index] {
+		return errChecksumMismatch
+	}
+	return nil
+}
+
+// verifyDownload verifies the data of a completed download against the
+// checksum of the file, and returns the checksum status of the download.
+// Downloads of the whole file to disk are verified against the digest of the
+// whole file, to also cover the writes to disk. The chunks of other downloads
+// have already been verified when they were recovered.
+func (d *download) verifyDownload() (string, error) {
+	if d.checksum.Algorithm == "" {
+		return modules.DownloadChecksumUnavailable, nil
+	}
+	dfw, ok := d.destination.(*DownloadFileWriter)
+	if ok && d.offset == 0 && d.length == d.fileSize {
+		err := verifyFileChecksum(dfw.location, d.checksum, d.fileSize)
+		if err == errChecksumAlgorithm {
+			return modules.DownloadChecksumUnavailable, nil
+		} else if err == errChecksumMismatch {
+			return modules.DownloadChecksumMismatch, err
+		} else if err != nil {
+			return "", build.ExtendErr("unable to verify the checksum of the download", err)
+		}
+		return modules.DownloadChecksumVerified, nil
+	}
+	if d.chunksUnverified {
+		return modules.DownloadChecksumUnavailable, nil
+	}
+	return modules.DownloadChecksumVerified, nil
+}
+
+// managedMarkForAudit records that the data of the download d did not match
+// the checksum of its file, and marks the file for an audit.
+func (r *Renter) managedMarkForAudit(d *download) {
+	d.mu.Lock()
+	d.checksumStatus = modules.DownloadChecksumMismatch
+	d.mu.Unlock()
+	r.log.Printf("ERROR: the download of %v to %v does not match the checksum of the file, the file is marked for an audit", d.siapath, d.destination.Destination())
+
+	// The file is only saved if it is still part of the renter, so that a
+	// file that was deleted during the download is not restored.
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	d.file.mu.Lock()
+	defer d.file.mu.Unlock()
+	d.file.auditRequired = true
+	if r.files[d.file.name] != d.file {
+		return
+	}
+	if err := r.saveFile(d.file); err != nil {
+		r.log.Println("WARN: could not save the audit mark of", d.siapath+":", err)
+	}
+}
+
+// FileChecksum returns the checksum of the file at siaPath that was computed
+// when the file was uploaded.
+func (r *Renter) FileChecksum(siaPath string) (modules.FileChecksum, error) {
+	id := r.mu.RLock()
+	f, exists := r.files[siaPath]
+	r.mu.RUnlock(id)
+	if !exists {
+		return modules.FileChecksum{}, ErrUnknownPath
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.checksum.Algorithm == "" {
+		return modules.FileChecksum{}, errNoChecksum
+	}
+	cs := f.checksum
+	cs.ChunkDigests = append([]crypto.Hash(nil), cs.ChunkDigests...)
+	return cs, nil
+}
+
+// checksumAlgorithm returns the algorithm of the checksums of new uploads. The
+// caller must hold the renter lock.
+func (r *Renter) checksumAlgorithm() string {
+	if r.uploadChecksumAlgorithm == "" {
+		return modules.ChecksumAlgorithmBlake2b
+	}
+	return r.uploadChecksumAlgorithm
+}
@@ -0,0 +1,234 @@
+package renter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestComputeFileChecksum checks the checksums of files that are computed
+// with both checksum algorithms.
+func TestComputeFileChecksum(t *testing.T) {
+	dir := build.TempDir("renter", t.Name())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	const chunkSize = 64
+	data := fastrand.Bytes(chunkSize*2 + chunkSize/2)
+	path := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	digests := map[string]func([]byte) crypto.Hash{
+		modules.ChecksumAlgorithmBlake2b: crypto.HashBytes,
+		modules.ChecksumAlgorithmSHA256: func(b []byte) crypto.Hash {
+			return crypto.Hash(sha256.Sum256(b))
+		},
+	}
+	for alg, digest := range digests {
+		cs, err := computeFileChecksum(path, alg, chunkSize, uint64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cs.Algorithm != alg || cs.ChunkSize != chunkSize || cs.Digest != digest(data) {
+			t.Fatal("wrong checksum:", cs)
+		}
+		if len(cs.ChunkDigests) != 3 {
+			t.Fatal("expected 3 chunk digests, got", len(cs.ChunkDigests))
+		}
+		for i, d := range cs.ChunkDigests {
+			end := (i + 1) * chunkSize
+			if end > len(data) {
+				end = len(data)
+			}
+			if d != digest(data[i*chunkSize:end]) {
+				t.Fatalf("wrong digest of chunk %v (%v)", i, alg)
+			}
+		}
+	}
+
+	// The checksum only covers the size of the file, and an empty file has
+	// a single chunk.
+	cs, err := computeFileChecksum(path, modules.ChecksumAlgorithmBlake2b, chunkSize, chunkSize)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(cs.ChunkDigests) != 1 || cs.Digest != crypto.HashBytes(data[:chunkSize]) {
+		t.Fatal("wrong checksum of a section of the file:", cs)
+	}
+	cs, err = computeFileChecksum(path, modules.ChecksumAlgorithmBlake2b, chunkSize, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(cs.ChunkDigests) != 1 || cs.ChunkDigests[0] != crypto.HashBytes(nil) {
+		t.Fatal("wrong checksum of an empty file:", cs)
+	}
+	if _, err := computeFileChecksum(path, "md5", chunkSize, 0); err != errChecksumAlgorithm {
+		t.Fatal("expected errChecksumAlgorithm, got", err)
+	}
+}
+
+// TestDownloadChecksumVerification checks that downloads verify their chunks
+// and the whole file against the checksum of the file.
+func TestDownloadChecksumVerification(t *testing.T) {
+	dir := build.TempDir("renter", t.Name())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	rsc, _ := NewRSCode(1, 1)
+	const chunkSize = 64
+	data := fastrand.Bytes(chunkSize*2 + 10)
+	source := filepath.Join(dir, "source")
+	if err := ioutil.WriteFile(source, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	f := newFile("foo", rsc, chunkSize, uint64(len(data)))
+	cs, err := computeFileChecksum(source, modules.ChecksumAlgorithmSHA256, f.chunkSize(), f.size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.checksum = cs
+
+	// Chunks that do not match their digest are rejected.
+	dest := filepath.Join(dir, "dest")
+	dfw, err := NewDownloadFileWriter(dest, 0, f.size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := newDownload(f, dfw)
+	d.length = f.size
+	if err := d.verifyChunk(1, data[chunkSize:2*chunkSize]); err != nil {
+		t.Fatal(err)
+	}
+	corrupted := append([]byte(nil), data[chunkSize:2*chunkSize]...)
+	corrupted[0]++
+	if err := d.verifyChunk(1, corrupted); err != errChecksumMismatch {
+		t.Fatal("expected errChecksumMismatch, got", err)
+	}
+
+	// A download of the whole file verifies the file on disk.
+	if _, err := dfw.WriteAt(data, 0); err != nil {
+		t.Fatal(err)
+	}
+	dfw.Close()
+	if status, err := d.verifyDownload(); err != nil || status != modules.DownloadChecksumVerified {
+		t.Fatal("download was not verified:", status, err)
+	}
+	corrupted = append([]byte(nil), data...)
+	corrupted[len(corrupted)-1]++
+	if err := ioutil.WriteFile(dest, corrupted, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if status, err := d.verifyDownload(); err != errChecksumMismatch || status != modules.DownloadChecksumMismatch {
+		t.Fatal("expected a checksum mismatch, got", status, err)
+	}
+
+	// Downloads of files without a checksum are not verified.
+	f.checksum = modules.FileChecksum{}
+	d = newDownload(f, NewDownloadBufferWriter(f.size, 0))
+	if err := d.verifyChunk(0, corrupted[:chunkSize]); err != nil {
+		t.Fatal(err)
+	}
+	if status, err := d.verifyDownload(); err != nil || status != modules.DownloadChecksumUnavailable {
+		t.Fatal("expected an unavailable checksum, got", status, err)
+	}
+}
+
+// TestFileChecksumPersistence checks that the checksums and audit marks of
+// files are shared and saved, that .sia data without them can still be read,
+// and that the checksum algorithm can be set.
+func TestFileChecksumPersistence(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Share a file with a checksum and read it back.
+	f := newTestingFile()
+	f.checksum = modules.FileChecksum{
+		Algorithm:    modules.ChecksumAlgorithmBlake2b,
+		Digest:       crypto.HashBytes(fastrand.Bytes(16)),
+		ChunkSize:    f.chunkSize(),
+		ChunkDigests: []crypto.Hash{crypto.HashBytes(fastrand.Bytes(16))},
+	}
+	var buf bytes.Buffer
+	if err := shareFiles([]*file{f}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	files, err := readSharedFiles(&buf)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(files) != 1 || files[0].checksum.Digest != f.checksum.Digest || len(files[0].checksum.ChunkDigests) != 1 {
+		t.Fatal("checksum was not shared:", files)
+	}
+
+	// .sia data of older versions ends after the files.
+	buf.Reset()
+	encoding.NewEncoder(&buf).EncodeAll(shareHeader, shareVersion, uint64(1))
+	zip := gzip.NewWriter(&buf)
+	encoding.NewEncoder(zip).Encode(f)
+	zip.Close()
+	files, err = readSharedFiles(&buf)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(files) != 1 || files[0].checksum.Algorithm != "" {
+		t.Fatal("old .sia data was not read:", files)
+	}
+
+	// The checksum is returned by FileChecksum, and an audit mark is shown
+	// in the file list.
+	id := rt.renter.mu.Lock()
+	rt.renter.files[f.name] = f
+	rt.renter.mu.Unlock(id)
+	cs, err := rt.renter.FileChecksum(f.name)
+	if err != nil || cs.Digest != f.checksum.Digest {
+		t.Fatal("wrong checksum:", cs, err)
+	}
+	if _, err := rt.renter.FileChecksum("bar"); err != ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+	rt.renter.managedMarkForAudit(newDownload(f, NewDownloadBufferWriter(1, 0)))
+	if fl := rt.renter.FileList(); len(fl) != 1 || !fl[0].AuditRequired {
+		t.Fatal("file was not marked for an audit:", fl)
+	}
+
+	// The checksum algorithm is a persisted setting.
+	if alg := rt.renter.Settings().ChecksumAlgorithm; alg != modules.ChecksumAlgorithmBlake2b {
+		t.Fatal("wrong default checksum algorithm:", alg)
+	}
+	alg := "md5"
+	if err := rt.renter.SetSettings(modules.RenterSettingsUpdate{ChecksumAlgorithm: &alg}); err != errChecksumAlgorithm {
+		t.Fatal("expected errChecksumAlgorithm, got", err)
+	}
+	alg = modules.ChecksumAlgorithmSHA256
+	if err := rt.renter.SetSettings(modules.RenterSettingsUpdate{ChecksumAlgorithm: &alg}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, rt.renter.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alg := rt.renter.Settings().ChecksumAlgorithm; alg != modules.ChecksumAlgorithmSHA256 {
+		t.Fatal("checksum algorithm was not persisted:", alg)
+	}
+	loaded := rt.renter.files[f.name]
+	if loaded == nil || !loaded.auditRequired || loaded.checksum.Digest != cs.Digest {
+		t.Fatal("checksum and audit mark were not persisted:", loaded)
+	}
+}
@@ -0,0 +1,191 @@
+package renter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+type (
+	// hostBreaker is the circuit breaker of a single host. firstFailure is
+	// the time of the first of the consecutive failures, firstOpenedAt is
+	// the time at which the breaker opened after being closed, and probing
+	// is set while a probe of a half-open breaker is in progress.
+	hostBreaker struct {
+		hostPubKey          types.SiaPublicKey
+		state               string
+		consecutiveFailures int
+		firstFailure        time.Time
+		firstOpenedAt       time.Time
+		openedAt            time.Time
+		probing             bool
+	}
+
+	// circuitBreakers tracks the circuit breakers of the hosts that the
+	// workers talk to, keyed by the String() of the host's public key. The
+	// breakers are per host rather than per worker, because a host can have
+	// contracts in several portfolios. Hosts that have not failed recently do
+	// not have a breaker, which is the same as a closed breaker.
+	circuitBreakers struct {
+		hosts map[string]*hostBreaker
+		mu    sync.Mutex
+	}
+)
+
+// newCircuitBreakers returns an empty set of circuit breakers.
+func newCircuitBreakers() *circuitBreakers {
+	return &circuitBreakers{
+		hosts: make(map[string]*hostBreaker),
+	}
+}
+
+// info returns the state of the breaker.
+func (hb *hostBreaker) info() modules.HostCircuitBreaker {
+	info := modules.HostCircuitBreaker{
+		HostPublicKey:       hb.hostPubKey,
+		State:               hb.state,
+		ConsecutiveFailures: hb.consecutiveFailures,
+		OpenedAt:            hb.openedAt,
+	}
+	if hb.state != modules.CircuitBreakerClosed {
+		info.ProbeTime = hb.openedAt.Add(circuitBreakerCooldown)
+	}
+	return info
+}
+
+// managedCheck reports whether new work may be routed to the host. Once the
+// cooldown of an open breaker has passed, the breaker becomes half-open and
+// the first caller is told to probe the host instead. No work is routed to
+// the host until the probe has succeeded.
+func (cb *circuitBreakers) managedCheck(hpk types.SiaPublicKey) (allow, probe bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	hb, exists := cb.hosts[hpk.String()]
+	if !exists || hb.state == modules.CircuitBreakerClosed {
+		return true, false
+	}
+	if hb.state == modules.CircuitBreakerOpen && time.Since(hb.openedAt) >= circuitBreakerCooldown {
+		hb.state = modules.CircuitBreakerHalfOpen
+		hb.probing = true
+		return false, true
+	}
+	return false, false
+}
+
+// managedRecordFailure records a failed request to the host, and returns
+// whether the breaker of the host is open afterwards. A failure while the
+// breaker is half-open opens it again for another cooldown.
+func (cb *circuitBreakers) managedRecordFailure(hpk types.SiaPublicKey) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	now := time.Now()
+	hb, exists := cb.hosts[hpk.String()]
+	if !exists {
+		hb = &hostBreaker{
+			hostPubKey: hpk,
+			state:      modules.CircuitBreakerClosed,
+		}
+		cb.hosts[hpk.String()] = hb
+	}
+	switch hb.state {
+	case modules.CircuitBreakerOpen:
+		// Requests that were started before the breaker opened are not
+		// counted again.
+	case modules.CircuitBreakerHalfOpen:
+		hb.consecutiveFailures++
+		hb.state = modules.CircuitBreakerOpen
+		hb.openedAt = now
+		hb.probing = false
+	default:
+		if hb.consecutiveFailures == 0 || now.Sub(hb.firstFailure) > circuitBreakerWindow {
+			hb.consecutiveFailures = 0
+			hb.firstFailure = now
+		}
+		hb.consecutiveFailures++
+		if hb.consecutiveFailures >= circuitBreakerThreshold {
+			hb.state = modules.CircuitBreakerOpen
+			hb.firstOpenedAt = now
+			hb.openedAt = now
+		}
+	}
+	return hb.state != modules.CircuitBreakerClosed
+}
+
+// managedRecordSuccess records a successful request to the host, which
+// closes its breaker.
+func (cb *circuitBreakers) managedRecordSuccess(hpk types.SiaPublicKey) {
+	cb.mu.Lock()
+	delete(cb.hosts, hpk.String())
+	cb.mu.Unlock()
+}
+
+// managedUnavailable reports whether the breaker of the host has not closed
+// for circuitBreakerUnavailableTime since it opened, in which case the host
+// does not count towards the redundancy of the renter's files. A breaker that
+// opened more recently only keeps new work from being routed to the host, so
+// that a brief outage does not cause its pieces to be repaired.
+func (cb *circuitBreakers) managedUnavailable(hpk types.SiaPublicKey) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	hb, exists := cb.hosts[hpk.String()]
+	return exists && hb.state != modules.CircuitBreakerClosed && time.Since(hb.firstOpenedAt) >= circuitBreakerUnavailableTime
+}
+
+// managedInfo returns the state of the breaker of the host.
+func (cb *circuitBreakers) managedInfo(hpk types.SiaPublicKey) modules.HostCircuitBreaker {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	hb, exists := cb.hosts[hpk.String()]
+	if !exists {
+		return modules.HostCircuitBreaker{
+			HostPublicKey: hpk,
+			State:         modules.CircuitBreakerClosed,
+		}
+	}
+	return hb.info()
+}
+
+// managedInfos returns the state of the breakers of every host that requests
+// have failed on since its last successful request.
+func (cb *circuitBreakers) managedInfos() []modules.HostCircuitBreaker {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	infos := make([]modules.HostCircuitBreaker, 0, len(cb.hosts))
+	for _, hb := range cb.hosts {
+		infos = append(infos, hb.info())
+	}
+	return infos
+}
+
+// threadedProbeHost probes the host of the worker with a single lightweight
+// request, the handshake of a download without fetching a sector. The
+// breaker of the host closes if the probe succeeds, and opens for another
+// cooldown if it fails.
+func (w *worker) threadedProbeHost() {
+	if err := w.renter.tg.Add(); err != nil {
+		return
+	}
+	defer w.renter.tg.Done()
+
+	d, err := w.contractor.Downloader(w.contract.ID, w.renter.tg.StopChan())
+	if err != nil {
+		w.renter.log.Debugln("Circuit breaker probe of host failed:", err)
+		w.renter.hostBreakers.managedRecordFailure(w.hostPubKey)
+		return
+	}
+	d.Close()
+	w.renter.hostBreakers.managedRecordSuccess(w.hostPubKey)
+}
+
+// HostCircuitBreaker returns the state of the circuit breaker of a host.
+func (r *Renter) HostCircuitBreaker(pk types.SiaPublicKey) modules.HostCircuitBreaker {
+	return r.hostBreakers.managedInfo(pk)
+}
+
+// HostCircuitBreakers returns the state of the circuit breakers of the hosts
+// that requests have failed on since their last successful request.
+func (r *Renter) HostCircuitBreakers() []modules.HostCircuitBreaker {
+	return r.hostBreakers.managedInfos()
+}
@@ -0,0 +1,199 @@
+package renter
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/renter/contractor"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// failingContractor is a throttledContractor whose editors and downloaders
+// fail for the contract failingID.
+type failingContractor struct {
+	*throttledContractor
+	failingID types.FileContractID
+}
+
+func (fc *failingContractor) Editor(id types.FileContractID, cancel <-chan struct{}) (contractor.Editor, error) {
+	if id == fc.failingID {
+		return nil, errors.New("host is failing")
+	}
+	return fc.throttledContractor.Editor(id, cancel)
+}
+func (fc *failingContractor) Downloader(types.FileContractID, <-chan struct{}) (contractor.Downloader, error) {
+	return nil, errors.New("host is failing")
+}
+
+// TestCircuitBreaker checks the transitions of the circuit breaker of a host.
+func TestCircuitBreaker(t *testing.T) {
+	cb := newCircuitBreakers()
+	hpk := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{1}}
+
+	// The breaker should only open after circuitBreakerThreshold failures.
+	for i := 0; i < circuitBreakerThreshold-1; i++ {
+		if cb.managedRecordFailure(hpk) {
+			t.Fatal("breaker opened after", i+1, "failures")
+		}
+	}
+	if allow, _ := cb.managedCheck(hpk); !allow {
+		t.Fatal("work was not allowed before the breaker opened")
+	}
+
+	// Failures outside of the window start a new count.
+	cb.hosts[hpk.String()].firstFailure = time.Now().Add(-circuitBreakerWindow - time.Second)
+	if cb.managedRecordFailure(hpk) {
+		t.Fatal("breaker counted failures outside of the window")
+	}
+	for i := 1; i < circuitBreakerThreshold; i++ {
+		cb.managedRecordFailure(hpk)
+	}
+	if allow, probe := cb.managedCheck(hpk); allow || probe {
+		t.Fatal("work was allowed while the breaker was open")
+	}
+	if cb.managedUnavailable(hpk) {
+		t.Fatal("host is unavailable right after the breaker opened")
+	}
+	info := cb.managedInfo(hpk)
+	if info.State != modules.CircuitBreakerOpen || info.ConsecutiveFailures != circuitBreakerThreshold || !info.ProbeTime.Equal(info.OpenedAt.Add(circuitBreakerCooldown)) {
+		t.Fatalf("wrong breaker state: %+v", info)
+	}
+
+	// Once the cooldown has passed, exactly one caller should probe the host.
+	cb.hosts[hpk.String()].openedAt = time.Now().Add(-circuitBreakerCooldown)
+	if allow, probe := cb.managedCheck(hpk); allow || !probe {
+		t.Fatal("expected a probe after the cooldown, got", allow, probe)
+	}
+	if allow, probe := cb.managedCheck(hpk); allow || probe {
+		t.Fatal("host was probed twice")
+	}
+	if state := cb.managedInfo(hpk).State; state != modules.CircuitBreakerHalfOpen {
+		t.Fatal("expected a half-open breaker, got", state)
+	}
+
+	// A failed probe opens the breaker for another cooldown, a successful
+	// probe closes it.
+	if !cb.managedRecordFailure(hpk) {
+		t.Fatal("breaker did not open after a failed probe")
+	}
+	// The host becomes unavailable once the breaker has not closed for
+	// circuitBreakerUnavailableTime, regardless of the failed probes.
+	cb.hosts[hpk.String()].firstOpenedAt = time.Now().Add(-circuitBreakerUnavailableTime)
+	if !cb.managedUnavailable(hpk) {
+		t.Fatal("host is available after the breaker stayed open")
+	}
+	if allow, probe := cb.managedCheck(hpk); allow || probe {
+		t.Fatal("host was probed before the next cooldown passed")
+	}
+	cb.hosts[hpk.String()].openedAt = time.Now().Add(-circuitBreakerCooldown)
+	if _, probe := cb.managedCheck(hpk); !probe {
+		t.Fatal("expected a probe after the second cooldown")
+	}
+	cb.managedRecordSuccess(hpk)
+	if allow, _ := cb.managedCheck(hpk); !allow || cb.managedUnavailable(hpk) {
+		t.Fatal("breaker did not close after a successful probe")
+	}
+	if infos := cb.managedInfos(); len(infos) != 0 {
+		t.Fatal("closed breaker is still reported:", infos)
+	}
+}
+
+// TestCircuitBreakerUpload uploads a file to three hosts, one of which fails
+// every request, and checks that the breaker of the failing host opens, and
+// that a host only stops counting towards the redundancy of the file once its
+// breaker has been open for circuitBreakerUnavailableTime.
+func TestCircuitBreakerUpload(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	tc := &throttledContractor{uploadTime: time.Millisecond}
+	for i := 0; i < 3; i++ {
+		tc.contracts = append(tc.contracts, modules.RenterContract{
+			ID:            types.FileContractID{byte(i + 1)},
+			HostPublicKey: types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{byte(i + 1)}},
+			GoodForUpload: true,
+			GoodForRenew:  true,
+		})
+	}
+	fc := &failingContractor{throttledContractor: tc, failingID: tc.contracts[0].ID}
+	failingHost := tc.contracts[0].HostPublicKey
+	rt, err := newContractorTester(t.Name(), nil, fc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	dir := build.TempDir("renter", t.Name(), "source")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(source, fastrand.Bytes(int(8*pieceSize)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	err = rt.renter.Upload(modules.FileUploadParams{
+		Source:       source,
+		SiaPath:      "file",
+		DataPieces:   1,
+		ParityPieces: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = build.Retry(100, 50*time.Millisecond, func() error {
+		if state := rt.renter.HostCircuitBreaker(failingHost).State; state == modules.CircuitBreakerClosed {
+			return errors.New("breaker of the failing host did not open")
+		}
+		files := rt.renter.FileList()
+		if len(files) != 1 || files[0].Redundancy != 2 {
+			return fmt.Errorf("expected a redundancy of 2, got %+v", files)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	breakers := rt.renter.HostCircuitBreakers()
+	if len(breakers) != 1 || breakers[0].HostPublicKey.String() != failingHost.String() {
+		t.Fatalf("expected only the failing host to have a breaker, got %+v", breakers)
+	}
+	if allow, _ := rt.renter.hostBreakers.managedCheck(failingHost); allow {
+		t.Fatal("work is routed to the failing host")
+	}
+
+	// The pieces on a host keep counting towards the redundancy of the file
+	// right after its breaker opens.
+	secondHost := tc.contracts[1].HostPublicKey
+	for i := 0; i < circuitBreakerThreshold; i++ {
+		rt.renter.hostBreakers.managedRecordFailure(secondHost)
+	}
+	if files := rt.renter.FileList(); files[0].Redundancy != 2 {
+		t.Fatal("expected a redundancy of 2 right after a second breaker opened, got", files[0].Redundancy)
+	}
+	if _, exists := rt.renter.managedRefreshHostsAndWorkers()[secondHost.String()]; !exists {
+		t.Fatal("repair planner treats a recently opened host as unavailable")
+	}
+
+	// Once the breaker has been open for long enough, the pieces stop
+	// counting.
+	rt.renter.hostBreakers.mu.Lock()
+	rt.renter.hostBreakers.hosts[secondHost.String()].firstOpenedAt = time.Now().Add(-circuitBreakerUnavailableTime)
+	rt.renter.hostBreakers.mu.Unlock()
+	if files := rt.renter.FileList(); files[0].Redundancy != 1 {
+		t.Fatal("expected a redundancy of 1 after the breaker stayed open, got", files[0].Redundancy)
+	}
+	if _, exists := rt.renter.managedRefreshHostsAndWorkers()[secondHost.String()]; exists {
+		t.Fatal("repair planner treats the unavailable host as available")
+	}
+}
@@ -4,9 +4,18 @@ import (
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
 )
 
 var (
+	// cacheEntryLifetime is the time after which chunks in the disk cache are
+	// stale.
+	cacheEntryLifetime = build.Select(build.Var{
+		Dev:      24 * time.Hour,
+		Standard: 7 * 24 * time.Hour,
+		Testing:  time.Minute,
+	}).(time.Duration)
+
 	// chunkDownloadTimeout defines the maximum amount of time to wait for a
 	// chunk download to finish before returning in the download-to-upload repair
 	// loop
@@ -16,6 +25,82 @@ var (
 		Testing:  1 * time.Minute,
 	}).(time.Duration)
 
+	// circuitBreakerCooldown is how long the circuit breaker of a host stays
+	// open before the host is probed with a single request.
+	circuitBreakerCooldown = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: 10 * time.Minute,
+		Testing:  3 * time.Second,
+	}).(time.Duration)
+
+	// circuitBreakerUnavailableTime is how long the circuit breaker of a host
+	// must stay open or half-open before the pieces on the host stop counting
+	// towards the redundancy of files and are repaired on other hosts.
+	circuitBreakerUnavailableTime = build.Select(build.Var{
+		Dev:      30 * time.Minute,
+		Standard: 6 * time.Hour,
+		Testing:  time.Minute,
+	}).(time.Duration)
+
+	// circuitBreakerThreshold is the number of consecutive failed requests to
+	// a host that open its circuit breaker.
+	circuitBreakerThreshold = build.Select(build.Var{
+		Dev:      5,
+		Standard: 5,
+		Testing:  4,
+	}).(int)
+
+	// circuitBreakerWindow is the time within which the consecutive failures
+	// of a host must occur to open its circuit breaker. A failure after the
+	// window has passed starts a new count.
+	circuitBreakerWindow = build.Select(build.Var{
+		Dev:      5 * time.Minute,
+		Standard: 10 * time.Minute,
+		Testing:  time.Minute,
+	}).(time.Duration)
+
+	// garbageCollectionInterval is how often the renter looks for contracts
+	// whose data is no longer used by any file.
+	garbageCollectionInterval = build.Select(build.Var{
+		Dev:      5 * time.Minute,
+		Standard: time.Hour,
+		Testing:  10 * time.Second,
+	}).(time.Duration)
+
+	// versionPruneInterval is how often the renter removes the previous
+	// versions of files that are older than their version retention allows.
+	versionPruneInterval = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: time.Hour,
+		Testing:  time.Second,
+	}).(time.Duration)
+
+	// maxRemoteRepairs is the number of chunks that the repair loop downloads
+	// from hosts at the same time, because their data is not available
+	// locally.
+	maxRemoteRepairs = build.Select(build.Var{
+		Dev:      2,
+		Standard: 4,
+		Testing:  1,
+	}).(int)
+
+	// streamPrefetchChunks is the number of chunks that a streaming download
+	// downloads ahead of the writer that it streams to.
+	streamPrefetchChunks = build.Select(build.Var{
+		Dev:      4,
+		Standard: 4,
+		Testing:  2,
+	}).(int)
+
+	// defaultCacheSize is the maximum size of the disk cache of downloaded
+	// chunks if the user has not configured one. The cache is disabled in
+	// testing, so that downloads in tests reach the hosts.
+	defaultCacheSize = build.Select(build.Var{
+		Dev:      uint64(1 << 28), // 256 MiB
+		Standard: uint64(1 << 30), // 1 GiB
+		Testing:  uint64(0),
+	}).(uint64)
+
 	// defaultMemory establishes the default amount of memory that the renter
 	// will use when performing uploads and downloads. Const should be a factor
 	// of 4 MiB, since most operations will be on data pieces that are 4 MiB
@@ -26,6 +111,36 @@ var (
 		Testing:  uint64(1 << 17),     // 128 KiB - 4 KiB sector size, need to test memory exhaustion
 	}).(uint64)
 
+	// defaultUploadDirectoryWorkers is the number of files that UploadDirectory
+	// submits concurrently if the caller does not specify a number of workers.
+	defaultUploadDirectoryWorkers = build.Select(build.Var{
+		Dev:      4,
+		Standard: 8,
+		Testing:  4,
+	}).(int)
+
+	// defaultUploadRetryPolicy is the retry policy used for failed piece
+	// uploads if the user has not configured one. Retries happen immediately,
+	// then after roughly 1s, 5s, 30s, and 5min before the piece is moved to
+	// another host.
+	defaultUploadRetryPolicy = build.Select(build.Var{
+		Dev: modules.UploadRetryPolicy{
+			MaxAttempts:    4,
+			InitialBackoff: time.Second,
+			MaxBackoff:     time.Minute,
+		},
+		Standard: modules.UploadRetryPolicy{
+			MaxAttempts:    6,
+			InitialBackoff: time.Second,
+			MaxBackoff:     5 * time.Minute,
+		},
+		Testing: modules.UploadRetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: 10 * time.Millisecond,
+			MaxBackoff:     100 * time.Millisecond,
+		},
+	}).(modules.UploadRetryPolicy)
+
 	// Limit the number of doublings to prevent overflows.
 	maxConsecutivePenalty = build.Select(build.Var{
 		Dev:      4,
@@ -33,6 +148,30 @@ var (
 		Testing:  3,
 	}).(int)
 
+	// maxPieceHistory is the number of uploads that are remembered for every
+	// piece of a file. Older uploads are forgotten first.
+	maxPieceHistory = build.Select(build.Var{
+		Dev:      10,
+		Standard: 10,
+		Testing:  3,
+	}).(int)
+
+	// maxAllowanceHistory is the number of allowance changes that are kept in
+	// the allowance history. Older changes are forgotten first.
+	maxAllowanceHistory = build.Select(build.Var{
+		Dev:      100,
+		Standard: 1000,
+		Testing:  4,
+	}).(int)
+
+	// maxRedundancyChangeChunks is the maximum number of chunks of
+	// redundancy changes that are in the repair loop at any time.
+	maxRedundancyChangeChunks = build.Select(build.Var{
+		Dev:      10,
+		Standard: 20,
+		Testing:  3,
+	}).(int)
+
 	// maxScheduledDownloads specifies the number of chunks that can be downloaded
 	// for auto repair at once. If the limit is reached new ones will only be scheduled
 	// once old ones are scheduled for upload
@@ -42,6 +181,31 @@ var (
 		Testing:  5,
 	}).(int)
 
+	// minThroughputSamples is the number of piece uploads that must have
+	// completed within the throughputWindow before the renter estimates how
+	// long uploads and repairs will take.
+	minThroughputSamples = build.Select(build.Var{
+		Dev:      5,
+		Standard: 10,
+		Testing:  5,
+	}).(int)
+
+	// pinFileCheckInterval defines how often PinFile checks the redundancy of
+	// the file that is being pinned.
+	pinFileCheckInterval = build.Select(build.Var{
+		Dev:      time.Second,
+		Standard: 5 * time.Second,
+		Testing:  100 * time.Millisecond,
+	}).(time.Duration)
+
+	// pinFileStallTimeout defines how long PinFile waits for the redundancy of
+	// a file to improve before giving up.
+	pinFileStallTimeout = build.Select(build.Var{
+		Dev:      2 * time.Minute,
+		Standard: 20 * time.Minute,
+		Testing:  3 * time.Second,
+	}).(time.Duration)
+
 	// rebuildChunkHeapInterval defines how long the renter sleeps between
 	// checking on the filesystem health.
 	rebuildChunkHeapInterval = build.Select(build.Var{
@@ -50,10 +214,39 @@ var (
 		Testing:  3 * time.Second,
 	}).(time.Duration)
 
+	// throughputWindow is the length of the sliding window of recent piece
+	// uploads that the upload throughput of the renter is measured over.
+	throughputWindow = build.Select(build.Var{
+		Dev:      2 * time.Minute,
+		Standard: 10 * time.Minute,
+		Testing:  10 * time.Second,
+	}).(time.Duration)
+
 	// Prime to avoid intersecting with regular events.
 	uploadFailureCooldown = build.Select(build.Var{
 		Dev:      time.Second * 7,
 		Standard: time.Second * 61,
 		Testing:  time.Second,
 	}).(time.Duration)
+
+	// uploadRetryMemoryWait is how long a retry of a piece upload is
+	// postponed if there is not enough memory to read the piece again.
+	uploadRetryMemoryWait = build.Select(build.Var{
+		Dev:      time.Second,
+		Standard: 5 * time.Second,
+		Testing:  50 * time.Millisecond,
+	}).(time.Duration)
+
+	// uploadRetryBackoffSteps are the multiples of the InitialBackoff of an
+	// UploadRetryPolicy that are waited between successive retries. Retries
+	// beyond the last step wait for the MaxBackoff.
+	uploadRetryBackoffSteps = []time.Duration{1, 5, 30, 300}
+
+	// usageSaveInterval is how often the transfer counters of the renter's
+	// usage by siapath prefix are saved while pieces are being transferred.
+	usageSaveInterval = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: 5 * time.Minute,
+		Testing:  time.Second,
+	}).(time.Duration)
 )
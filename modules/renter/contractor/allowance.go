@@ -9,10 +9,12 @@ import (
 )
 
 var (
-	errAllowanceNoHosts    = errors.New("hosts must be non-zero")
-	errAllowanceNotSynced  = errors.New("you must be synced to set an allowance")
-	errAllowanceWindowSize = errors.New("renew window must be less than period")
-	errAllowanceZeroPeriod = errors.New("period must be non-zero")
+	errAllowanceBoostFactor = errors.New("auto price boost factor must be 0 or greater than 1")
+	errAllowanceMaxVersions = errors.New("max versions per file must not be negative")
+	errAllowanceNoHosts     = errors.New("hosts must be non-zero")
+	errAllowanceNotSynced   = errors.New("you must be synced to set an allowance")
+	errAllowanceWindowSize  = errors.New("renew window must be less than period")
+	errAllowanceZeroPeriod  = errors.New("period must be non-zero")
 
 	// ErrAllowanceZeroWindow is returned when the caller requests a
 	// zero-length renewal window. This will happen if the caller sets the
@@ -54,6 +56,10 @@ func (c *Contractor) SetAllowance(a modules.Allowance) error {
 		return ErrAllowanceZeroWindow
 	} else if a.RenewWindow >= a.Period {
 		return errAllowanceWindowSize
+	} else if a.AutoPriceBoostFactor != 0 && !(a.AutoPriceBoostFactor > 1) {
+		return errAllowanceBoostFactor
+	} else if a.MaxVersionsPerFile < 0 {
+		return errAllowanceMaxVersions
 	} else if !c.cs.Synced() {
 		return errAllowanceNotSynced
 	}
@@ -78,6 +84,11 @@ func (c *Contractor) SetAllowance(a modules.Allowance) error {
 	if reflect.DeepEqual(c.allowance, modules.Allowance{}) {
 		c.currentPeriod = c.blockHeight
 	}
+	// A boosted storage price only applies to the allowance it was boosted
+	// under.
+	if !reflect.DeepEqual(c.allowance, a) {
+		c.storagePriceCap = types.ZeroCurrency
+	}
 	c.allowance = a
 	err = c.saveSync()
 	c.mu.Unlock()
@@ -90,6 +101,27 @@ func (c *Contractor) SetAllowance(a modules.Allowance) error {
 	return nil
 }
 
+// RenewalPolicy returns whether contracts are renewed automatically, and the
+// number of blocks before expiry at which they are renewed. A threshold of
+// zero means that the allowance's RenewWindow is used.
+func (c *Contractor) RenewalPolicy() (bool, types.BlockHeight) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return !c.autoRenewDisabled, c.renewThreshold
+}
+
+// SetRenewalPolicy sets whether the Contractor renews contracts automatically
+// as they approach expiry, and how many blocks before expiry the renewal
+// happens. The new policy takes effect during the next round of contract
+// maintenance.
+func (c *Contractor) SetRenewalPolicy(enabled bool, threshold types.BlockHeight) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.autoRenewDisabled = !enabled
+	c.renewThreshold = threshold
+	return c.saveSync()
+}
+
 // managedCancelAllowance handles the special case where the allowance is empty.
 func (c *Contractor) managedCancelAllowance(a modules.Allowance) error {
 	c.log.Println("INFO: canceling allowance")
@@ -0,0 +1,32 @@
+package contractor
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var errUnknownContract = errors.New("no record of that contract")
+
+// CancelContract stops using the contract with the given ID. The contract is
+// no longer uploaded to or renewed, and its host is not chosen for new
+// contracts while the contract exists, but the data that it stores can still
+// be downloaded. The funds that remain in the contract are lost until it
+// expires. Cancelling a contract that was cancelled already is a no-op.
+func (c *Contractor) CancelContract(id types.FileContractID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	contract, exists := c.contracts[c.resolveID(id)]
+	if !exists {
+		return errUnknownContract
+	}
+	if contract.Cancelled {
+		return nil
+	}
+	contract.Cancelled = true
+	contract.GoodForUpload = false
+	contract.GoodForRenew = false
+	c.contracts[contract.ID] = contract
+	c.log.Printf("Cancelled contract %v with host %v\n", contract.ID, contract.NetAddress)
+	return c.saveSync()
+}
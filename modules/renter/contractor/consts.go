@@ -25,11 +25,25 @@ var (
 		Testing:  10 * time.Millisecond,
 	}).(time.Duration)
 
+	// walletLockCheckInterval is the interval at which the contractor checks
+	// whether the wallet has been locked or unlocked.
+	walletLockCheckInterval = build.Select(build.Var{
+		Dev:      5 * time.Second,
+		Standard: 30 * time.Second,
+		Testing:  50 * time.Millisecond,
+	}).(time.Duration)
+
 	// minContractFundRenewalThreshold defines the ratio of remaining funds to
 	// total contract cost below which the contractor will prematurely renew a
 	// contract.
 	minContractFundRenewalThreshold = float64(0.03) // 3%
 
+	// maxStoragePriceBoosts is the number of times the maximum storage price
+	// can be boosted by the allowance's AutoPriceBoostFactor. The boosted
+	// price never exceeds maxStoragePrice multiplied by the factor this many
+	// times, no matter how many rounds of contract maintenance boost it.
+	maxStoragePriceBoosts = 5
+
 	// minHostsForEstimations describes the minimum number of hosts that
 	// are needed to make broad estimations such as the number of sectors
 	// that you can store on the network for a given allowance.
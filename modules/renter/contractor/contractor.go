@@ -10,11 +10,17 @@ package contractor
 import (
 	"errors"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/renter/proto"
 	"github.com/NebulousLabs/Sia/persist"
 	siasync "github.com/NebulousLabs/Sia/sync"
 	"github.com/NebulousLabs/Sia/types"
@@ -56,11 +62,61 @@ type Contractor struct {
 	// Only one thread should be performing contract maintenance at a time.
 	maintenanceLock siasync.TryMutex
 
+	// alerts contains the active alerts of the contractor, keyed by their
+	// category.
+	alerts map[modules.RenterAlertCategory]modules.RenterAlert
+
 	allowance     modules.Allowance
 	blockHeight   types.BlockHeight
 	currentPeriod types.BlockHeight
 	lastChange    modules.ConsensusChangeID
 
+	// The renewal policy. Automatic renewal is tracked as a 'disabled' flag so
+	// that the zero value, and persist files that predate the policy, keep
+	// renewing contracts.
+	autoRenewDisabled bool
+	renewThreshold    types.BlockHeight
+
+	// storagePriceCap is the boosted maximum storage price, set when the
+	// allowance's AutoPriceBoostFactor is used to form enough contracts. A
+	// zero value means that maxStoragePrice is used. The cap is reset when
+	// the allowance changes, and is lowered again once the hosts of the
+	// current contracts no longer need it.
+	storagePriceCap types.Currency
+
+	// windingDown holds the time at which each host that is winding down
+	// refused to renew a contract, keyed by public key. The contracts with
+	// those hosts are not used, so that their data is migrated to other
+	// hosts. A host is removed once a later scan finds it accepting
+	// contracts again. The map is not persistent.
+	windingDown map[string]time.Time
+
+	// garbageContracts holds the contracts that the renter has found to be
+	// abandoned, which are not renewed. The map is not persistent.
+	garbageContracts map[types.FileContractID]struct{}
+
+	// pinnedHostCerts holds the DER encoded TLS certificate that the renter
+	// pinned for each host, keyed by the host's public key. The renter
+	// connects to these hosts over TLS.
+	pinnedHostCerts map[string][]byte
+
+	// identityKey is the key that the contractor identifies itself with to
+	// the hosts that it forms and renews contracts with, so that hosts can
+	// recognize it across contracts.
+	identityKey crypto.SecretKey
+
+	// downloadLimit limits the bandwidth of the downloads from hosts. A nil
+	// limit does not limit them.
+	downloadLimit *proto.RateLimit
+
+	// uploadLimit limits the bandwidth of the uploads to hosts. A nil limit
+	// does not limit them.
+	uploadLimit *proto.RateLimit
+
+	// bandwidthLimiter limits the connections to hosts by the node's
+	// bandwidth budget. A nil limiter does not limit them.
+	bandwidthLimiter *modules.BandwidthLimiter
+
 	downloaders map[types.FileContractID]*hostDownloader
 	editors     map[types.FileContractID]*hostEditor
 	renewing    map[types.FileContractID]bool // prevent revising during renewal
@@ -82,6 +138,25 @@ func (c *Contractor) resolveID(id types.FileContractID) types.FileContractID {
 	return id
 }
 
+// storagePriceLimit returns the maximum storage price that the Contractor
+// accepts from hosts.
+func (c *Contractor) storagePriceLimit() types.Currency {
+	if c.storagePriceCap.IsZero() {
+		return maxStoragePrice
+	}
+	return c.storagePriceCap
+}
+
+// boostedStoragePriceBound returns the highest storage price that the
+// allowance's AutoPriceBoostFactor can raise the maximum storage price to.
+func (c *Contractor) boostedStoragePriceBound() types.Currency {
+	factor := c.allowance.AutoPriceBoostFactor
+	if factor <= 1 {
+		return maxStoragePrice
+	}
+	return maxStoragePrice.MulFloat(math.Pow(factor, float64(maxStoragePriceBoosts)))
+}
+
 // Allowance returns the current allowance.
 func (c *Contractor) Allowance() modules.Allowance {
 	c.mu.RLock()
@@ -101,27 +176,95 @@ func (c *Contractor) Contract(hostAddr modules.NetAddress) (modules.RenterContra
 	return modules.RenterContract{}, false
 }
 
+// contractPeriodSpending returns the spending of contract. active indicates
+// whether the contract is still in use. The caller must hold the lock.
+func (c *Contractor) contractPeriodSpending(contract modules.RenterContract, active bool) modules.ContractPeriodSpending {
+	cs := modules.ContractPeriodSpending{
+		ID:            contract.ID,
+		HostPublicKey: contract.HostPublicKey,
+		NetAddress:    contract.NetAddress,
+		StartHeight:   contract.StartHeight,
+		EndHeight:     contract.EndHeight(),
+		Active:        active && !contract.Cancelled,
+
+		ContractSpending:       contract.TotalCost,
+		ContractFees:           contract.ContractFee.Add(contract.TxnFee).Add(contract.SiafundFee),
+		DownloadSpending:       contract.DownloadSpending,
+		RepairDownloadSpending: contract.RepairDownloadSpending,
+		StorageSpending:        contract.StorageSpending,
+		UploadSpending:         contract.UploadSpending,
+	}
+
+	// The funds of cancelled contracts are kept by the host, and the funds of
+	// contracts that have ended were returned already.
+	if len(contract.LastRevision.NewValidProofOutputs) < 2 || contract.Cancelled || cs.EndHeight <= c.blockHeight {
+		return cs
+	}
+	cs.ExpectedReturn = contract.RenterFunds()
+	if cs.Active {
+		cs.UnspentAllocated = cs.ExpectedReturn
+	}
+	return cs
+}
+
 // PeriodSpending returns the amount spent on contracts during the current
-// billing period.
+// billing period. The contracts that were renewed or expired during the
+// period are archived, but their spending still counts, so that the totals do
+// not reset when contracts roll over.
 func (c *Contractor) PeriodSpending() modules.ContractorSpending {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	spending := modules.ContractorSpending{}
+	counted := make(map[types.FileContractID]struct{})
+	addContract := func(contract modules.RenterContract, active bool) {
+		// Contracts that were renewed in the middle of the period are both
+		// archived and in the contract line of their renewal.
+		if _, exists := counted[contract.ID]; exists {
+			return
+		}
+		counted[contract.ID] = struct{}{}
+		cs := c.contractPeriodSpending(contract, active)
+		spending.ContractSpending = spending.ContractSpending.Add(cs.ContractSpending)
+		spending.ContractFees = spending.ContractFees.Add(cs.ContractFees)
+		spending.DownloadSpending = spending.DownloadSpending.Add(cs.DownloadSpending)
+		spending.RepairDownloadSpending = spending.RepairDownloadSpending.Add(cs.RepairDownloadSpending)
+		spending.UploadSpending = spending.UploadSpending.Add(cs.UploadSpending)
+		spending.StorageSpending = spending.StorageSpending.Add(cs.StorageSpending)
+		spending.UnspentAllocated = spending.UnspentAllocated.Add(cs.UnspentAllocated)
+		spending.ExpectedReturn = spending.ExpectedReturn.Add(cs.ExpectedReturn)
+		spending.Contracts = append(spending.Contracts, cs)
+	}
 	for _, contract := range c.contracts {
-		spending.ContractSpending = spending.ContractSpending.Add(contract.TotalCost)
-		spending.DownloadSpending = spending.DownloadSpending.Add(contract.DownloadSpending)
-		spending.UploadSpending = spending.UploadSpending.Add(contract.UploadSpending)
-		spending.StorageSpending = spending.StorageSpending.Add(contract.StorageSpending)
+		addContract(contract, true)
 		for _, pre := range contract.PreviousContracts {
-			spending.ContractSpending = spending.ContractSpending.Add(pre.TotalCost)
-			spending.DownloadSpending = spending.DownloadSpending.Add(pre.DownloadSpending)
-			spending.UploadSpending = spending.UploadSpending.Add(pre.UploadSpending)
-			spending.StorageSpending = spending.StorageSpending.Add(pre.StorageSpending)
+			addContract(pre, false)
+		}
+	}
+	// Without an allowance there is no current period, and all of the
+	// contracts have been archived.
+	if !reflect.DeepEqual(c.allowance, modules.Allowance{}) {
+		for _, contract := range c.oldContracts {
+			if contract.StartHeight < c.currentPeriod || contract.ID == metricsContractID {
+				continue
+			}
+			addContract(contract, false)
+			for _, pre := range contract.PreviousContracts {
+				addContract(pre, false)
+			}
 		}
 	}
+	sort.Slice(spending.Contracts, func(i, j int) bool {
+		if spending.Contracts[i].StartHeight != spending.Contracts[j].StartHeight {
+			return spending.Contracts[i].StartHeight < spending.Contracts[j].StartHeight
+		}
+		return spending.Contracts[i].ID.String() < spending.Contracts[j].ID.String()
+	})
+
 	allSpending := spending.ContractSpending.Add(spending.DownloadSpending).Add(spending.UploadSpending).Add(spending.StorageSpending)
-	spending.Unspent = c.allowance.Funds.Sub(allSpending)
+	if c.allowance.Funds.Cmp(allSpending) > 0 {
+		spending.Unspent = c.allowance.Funds.Sub(allSpending)
+	}
 	return spending
 }
 
@@ -239,14 +382,17 @@ func newContractor(cs consensusSet, w wallet, tp transactionPool, hdb hostDB, p
 		tpool:   tp,
 		wallet:  w,
 
+		alerts:          make(map[modules.RenterAlertCategory]modules.RenterAlert),
 		cachedRevisions: make(map[types.FileContractID]cachedRevision),
 		contracts:       make(map[types.FileContractID]modules.RenterContract),
 		downloaders:     make(map[types.FileContractID]*hostDownloader),
 		editors:         make(map[types.FileContractID]*hostEditor),
 		oldContracts:    make(map[types.FileContractID]modules.RenterContract),
+		pinnedHostCerts: make(map[string][]byte),
 		renewedIDs:      make(map[types.FileContractID]types.FileContractID),
 		renewing:        make(map[types.FileContractID]bool),
 		revising:        make(map[types.FileContractID]bool),
+		windingDown:     make(map[string]time.Time),
 	}
 
 	// Close the logger (provided as a dependency) upon shutdown.
@@ -261,6 +407,9 @@ func newContractor(cs consensusSet, w wallet, tp transactionPool, hdb hostDB, p
 	if err != nil && !os.IsNotExist(err) {
 		return nil, err
 	}
+	if c.identityKey == (crypto.SecretKey{}) {
+		c.identityKey, _ = crypto.GenerateKeyPair()
+	}
 	// Close the persist (provided as a dependency) upon shutdown.
 	c.tg.AfterStop(func() {
 		if err := c.persist.Close(); err != nil {
@@ -291,5 +440,17 @@ func newContractor(cs consensusSet, w wallet, tp transactionPool, hdb hostDB, p
 		return nil, err
 	}
 
+	// Suspend contract maintenance while the wallet is locked.
+	go c.threadedMonitorWallet()
+
 	return c, nil
 }
+
+// Identity returns the public key that the Contractor identifies itself with
+// to hosts. Hosts with a renter whitelist only form and renew contracts with
+// the renters whose identity is on the whitelist.
+func (c *Contractor) Identity() types.SiaPublicKey {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return types.Ed25519PublicKey(c.identityKey.PublicKey())
+}
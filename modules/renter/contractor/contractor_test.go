@@ -2,6 +2,7 @@ package contractor
 
 import (
 	"errors"
+	"io/ioutil"
 	"os"
 	"reflect"
 	"testing"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -24,8 +26,10 @@ func (newStub) Synced() bool                               { return true }
 func (newStub) Unsubscribe(modules.ConsensusSetSubscriber) { return }
 
 // wallet stubs
-func (newStub) NextAddress() (uc types.UnlockConditions, err error) { return }
-func (newStub) StartTransaction() modules.TransactionBuilder        { return nil }
+func (newStub) NextAddress() (uc types.UnlockConditions, err error)              { return }
+func (newStub) RecordSpending(modules.SpendingRecord, []types.Transaction) error { return nil }
+func (newStub) StartTransaction() modules.TransactionBuilder                     { return nil }
+func (newStub) Unlocked() bool                                                   { return true }
 
 // transaction pool stubs
 func (newStub) AcceptTransactionSet([]types.Transaction) error      { return nil }
@@ -184,6 +188,205 @@ func TestAllowance(t *testing.T) {
 	}
 }
 
+// TestPendingRenewals tests that PendingRenewals respects the renewal policy.
+func TestPendingRenewals(t *testing.T) {
+	c := &Contractor{
+		persist:     new(memPersist),
+		allowance:   modules.Allowance{Period: 100, RenewWindow: 10},
+		blockHeight: 50,
+		contracts: map[types.FileContractID]modules.RenterContract{
+			{1}: {ID: types.FileContractID{1}, GoodForRenew: true, LastRevision: types.FileContractRevision{NewWindowStart: 55}},
+			{2}: {ID: types.FileContractID{2}, GoodForRenew: true, LastRevision: types.FileContractRevision{NewWindowStart: 70}},
+			{3}: {ID: types.FileContractID{3}, GoodForRenew: false, LastRevision: types.FileContractRevision{NewWindowStart: 55}},
+		},
+	}
+
+	// Only the contract inside the allowance's renew window should be pending.
+	ids := c.PendingRenewals()
+	if len(ids) != 1 || ids[0] != (types.FileContractID{1}) {
+		t.Fatal("wrong pending renewals:", ids)
+	}
+
+	// A larger threshold should include the second contract.
+	if err := c.SetRenewalPolicy(true, 30); err != nil {
+		t.Fatal(err)
+	}
+	if ids := c.PendingRenewals(); len(ids) != 2 {
+		t.Fatal("wrong pending renewals:", ids)
+	}
+
+	// No renewals are pending if automatic renewal is disabled.
+	if err := c.SetRenewalPolicy(false, 30); err != nil {
+		t.Fatal(err)
+	}
+	if ids := c.PendingRenewals(); len(ids) != 0 {
+		t.Fatal("renewals pending while automatic renewal is disabled:", ids)
+	}
+	if enabled, threshold := c.RenewalPolicy(); enabled || threshold != 30 {
+		t.Fatal("wrong renewal policy:", enabled, threshold)
+	}
+}
+
+// TestSetGarbageContracts checks that garbage contracts are not renewed.
+func TestSetGarbageContracts(t *testing.T) {
+	c := &Contractor{
+		persist:     new(memPersist),
+		allowance:   modules.Allowance{Period: 100, RenewWindow: 10},
+		blockHeight: 50,
+		contracts: map[types.FileContractID]modules.RenterContract{
+			{1}: {ID: types.FileContractID{1}, GoodForRenew: true, GoodForUpload: true, LastRevision: types.FileContractRevision{NewWindowStart: 55}},
+			{2}: {ID: types.FileContractID{2}, GoodForRenew: true, GoodForUpload: true, LastRevision: types.FileContractRevision{NewWindowStart: 55}},
+		},
+	}
+	c.SetGarbageContracts([]types.FileContractID{{1}})
+	if ids := c.PendingRenewals(); len(ids) != 1 || ids[0] != (types.FileContractID{2}) {
+		t.Fatal("wrong pending renewals:", ids)
+	}
+	if contract := c.contracts[types.FileContractID{1}]; contract.GoodForRenew || !contract.GoodForUpload {
+		t.Fatal("garbage contract has the wrong utility:", contract.GoodForRenew, contract.GoodForUpload)
+	}
+	if _, garbage := c.garbageContracts[types.FileContractID{1}]; !garbage {
+		t.Fatal("garbage contract was not recorded")
+	}
+	c.SetGarbageContracts(nil)
+	if len(c.garbageContracts) != 0 {
+		t.Fatal("garbage contracts were not cleared")
+	}
+}
+
+// TestCancelContract tests that cancelled contracts are neither uploaded to
+// nor renewed, and that contracts can be cancelled by the ID of a contract
+// that they were renewed from.
+func TestCancelContract(t *testing.T) {
+	c := &Contractor{
+		persist:     new(memPersist),
+		log:         persist.NewLogger(ioutil.Discard),
+		allowance:   modules.Allowance{Period: 100, RenewWindow: 10},
+		blockHeight: 50,
+		contracts: map[types.FileContractID]modules.RenterContract{
+			{2}: {ID: types.FileContractID{2}, GoodForRenew: true, GoodForUpload: true, LastRevision: types.FileContractRevision{NewWindowStart: 55}},
+		},
+		renewedIDs: map[types.FileContractID]types.FileContractID{
+			{1}: {2},
+		},
+	}
+	if err := c.CancelContract(types.FileContractID{3}); err != errUnknownContract {
+		t.Fatal("expected errUnknownContract, got", err)
+	}
+	if err := c.CancelContract(types.FileContractID{1}); err != nil {
+		t.Fatal(err)
+	}
+	contract, _ := c.ContractByID(types.FileContractID{2})
+	if !contract.Cancelled || contract.GoodForUpload || contract.GoodForRenew {
+		t.Fatal("cancelled contract has the wrong state:", contract.Cancelled, contract.GoodForUpload, contract.GoodForRenew)
+	}
+	if ids := c.PendingRenewals(); len(ids) != 0 {
+		t.Fatal("cancelled contract is due to be renewed:", ids)
+	}
+	if err := c.CancelContract(types.FileContractID{2}); err != nil {
+		t.Fatal(err)
+	}
+	if contracts := c.Contracts(); len(contracts) != 1 || !contracts[0].Cancelled {
+		t.Fatal("cancelled contract is not listed:", contracts)
+	}
+}
+
+// TestPeriodSpending checks that the spending of the current period includes
+// the contracts that were renewed during the period, but not those of earlier
+// periods, and that it is the same after the contractor is reloaded.
+func TestPeriodSpending(t *testing.T) {
+	contract := func(id byte, start, end types.BlockHeight, totalCost, remaining uint64) modules.RenterContract {
+		return modules.RenterContract{
+			ID:            types.FileContractID{id},
+			HostPublicKey: types.SiaPublicKey{Key: []byte{id}},
+			StartHeight:   start,
+			LastRevision: types.FileContractRevision{
+				NewWindowStart:       end,
+				NewValidProofOutputs: []types.SiacoinOutput{{Value: types.NewCurrency64(remaining)}, {}},
+			},
+			TotalCost:        types.NewCurrency64(totalCost),
+			ContractFee:      types.NewCurrency64(5),
+			TxnFee:           types.NewCurrency64(3),
+			SiafundFee:       types.NewCurrency64(2),
+			StorageSpending:  types.NewCurrency64(20),
+			UploadSpending:   types.NewCurrency64(10),
+			DownloadSpending: types.NewCurrency64(4),
+		}
+	}
+	// Contract 3 was refreshed from contract 2 and is archived as well,
+	// contract 1 was renewed when it expired at the end of the previous
+	// period, and contract 4 was cancelled.
+	refreshed := contract(2, 120, 220, 50, 5)
+	active := contract(3, 140, 240, 100, 60)
+	active.PreviousContracts = []modules.RenterContract{refreshed}
+	cancelled := contract(4, 140, 240, 100, 70)
+	cancelled.Cancelled = true
+	c := &Contractor{
+		persist:       new(memPersist),
+		allowance:     modules.Allowance{Funds: types.NewCurrency64(1000), Period: 100, RenewWindow: 10},
+		blockHeight:   150,
+		currentPeriod: 100,
+		contracts: map[types.FileContractID]modules.RenterContract{
+			active.ID:    active,
+			cancelled.ID: cancelled,
+		},
+		oldContracts: map[types.FileContractID]modules.RenterContract{
+			{1}:          contract(1, 100, 160, 80, 30),
+			refreshed.ID: refreshed,
+			{9}:          contract(9, 10, 110, 80, 30),
+		},
+	}
+
+	spending := c.PeriodSpending()
+	if len(spending.Contracts) != 4 {
+		t.Fatal("expected 4 contracts, got", spending.Contracts)
+	}
+	for i, id := range []byte{1, 2, 3, 4} {
+		if cs := spending.Contracts[i]; cs.ID != (types.FileContractID{id}) || cs.Active != (id == 3) {
+			t.Errorf("wrong contract %v: %+v", i, cs)
+		}
+	}
+	expected := map[string][2]types.Currency{
+		"contract spending": {spending.ContractSpending, types.NewCurrency64(330)},
+		"contract fees":     {spending.ContractFees, types.NewCurrency64(40)},
+		"storage spending":  {spending.StorageSpending, types.NewCurrency64(80)},
+		"unspent allocated": {spending.UnspentAllocated, types.NewCurrency64(60)},
+		"expected return":   {spending.ExpectedReturn, types.NewCurrency64(95)},
+		"unspent allowance": {spending.Unspent, types.NewCurrency64(1000 - 330 - 80 - 40 - 16)},
+	}
+	for name, values := range expected {
+		if values[0].Cmp(values[1]) != 0 {
+			t.Errorf("wrong %v: expected %v, got %v", name, values[1], values[0])
+		}
+	}
+
+	// The spending is derived from the saved contracts.
+	if err := c.save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded := &Contractor{
+		persist:         c.persist,
+		contracts:       make(map[types.FileContractID]modules.RenterContract),
+		oldContracts:    make(map[types.FileContractID]modules.RenterContract),
+		renewedIDs:      make(map[types.FileContractID]types.FileContractID),
+		cachedRevisions: make(map[types.FileContractID]cachedRevision),
+		pinnedHostCerts: make(map[string][]byte),
+	}
+	if err := loaded.load(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.PeriodSpending(), spending) {
+		t.Fatal("spending changed after reloading the contractor")
+	}
+
+	// Entering the next period leaves the expired contract behind, while the
+	// active contracts still carry the contracts they were refreshed from.
+	c.currentPeriod = 190
+	if spending := c.PeriodSpending(); len(spending.Contracts) != 3 || spending.Contracts[0].ID != refreshed.ID {
+		t.Fatal("expected only the active contracts in the next period, got", spending.Contracts)
+	}
+}
+
 // stubHostDB mocks the hostDB dependency using zero-valued implementations of
 // its methods.
 type stubHostDB struct{}
@@ -362,7 +565,7 @@ func TestAllowanceSpending(t *testing.T) {
 			}
 		}
 	}
-	balance, _, _ := w.ConfirmedBalance()
+	balance, _, _, _ := w.ConfirmedBalance()
 	spent := minerRewards.Sub(balance)
 	if spent.Cmp(testAllowance.Funds) > 0 {
 		t.Fatal("contractor spent too much money: spent", spent.HumanString(), "allowance funds:", testAllowance.Funds.HumanString())
@@ -573,8 +776,10 @@ func TestIntegrationSetAllowance(t *testing.T) {
 
 // testWalletShim is used to test the walletBridge type.
 type testWalletShim struct {
-	nextAddressCalled bool
-	startTxnCalled    bool
+	nextAddressCalled    bool
+	recordSpendingCalled bool
+	startTxnCalled       bool
+	unlockedCalled       bool
 }
 
 // These stub implementations for the walletShim interface set their respective
@@ -583,10 +788,18 @@ func (ws *testWalletShim) NextAddress() (types.UnlockConditions, error) {
 	ws.nextAddressCalled = true
 	return types.UnlockConditions{}, nil
 }
+func (ws *testWalletShim) RecordSpending(modules.SpendingRecord, []types.Transaction) error {
+	ws.recordSpendingCalled = true
+	return nil
+}
 func (ws *testWalletShim) StartTransaction() modules.TransactionBuilder {
 	ws.startTxnCalled = true
 	return nil
 }
+func (ws *testWalletShim) Unlocked() bool {
+	ws.unlockedCalled = true
+	return true
+}
 
 // TestWalletBridge tests the walletBridge type.
 func TestWalletBridge(t *testing.T) {
@@ -596,8 +809,172 @@ func TestWalletBridge(t *testing.T) {
 	if !shim.nextAddressCalled {
 		t.Error("NextAddress was not called on the shim")
 	}
+	bridge.RecordSpending(modules.SpendingRecord{}, nil)
+	if !shim.recordSpendingCalled {
+		t.Error("RecordSpending was not called on the shim")
+	}
 	bridge.StartTransaction()
 	if !shim.startTxnCalled {
 		t.Error("StartTransaction was not called on the shim")
 	}
+	bridge.Unlocked()
+	if !shim.unlockedCalled {
+		t.Error("Unlocked was not called on the shim")
+	}
+}
+
+// rejectingTPool is a transaction pool that rejects every transaction set.
+type rejectingTPool struct{ newStub }
+
+func (rejectingTPool) AcceptTransactionSet([]types.Transaction) error {
+	return errors.New("rejected")
+}
+
+// TestSpendingPool checks that the transaction pool of contract formations
+// and renewals only records the transaction sets that were accepted.
+func TestSpendingPool(t *testing.T) {
+	shim := new(testWalletShim)
+	c := &Contractor{
+		log:    persist.NewLogger(ioutil.Discard),
+		tpool:  rejectingTPool{},
+		wallet: &walletBridge{w: shim},
+	}
+	tp := c.spendingPool(modules.SpendingPurposeContractFormation, types.SiaPublicKey{})
+	if err := tp.AcceptTransactionSet([]types.Transaction{{}}); err == nil {
+		t.Fatal("expected the transaction set to be rejected")
+	}
+	if shim.recordSpendingCalled {
+		t.Fatal("spending of a rejected transaction set was recorded")
+	}
+
+	c.tpool = newStub{}
+	if err := tp.AcceptTransactionSet([]types.Transaction{{}}); err != nil {
+		t.Fatal(err)
+	}
+	if !shim.recordSpendingCalled {
+		t.Fatal("spending of an accepted transaction set was not recorded")
+	}
+}
+
+// TestIntegrationAutoPriceBoost checks that the contractor boosts the maximum
+// storage price when it cannot form enough contracts because the hosts are
+// too expensive.
+func TestIntegrationAutoPriceBoost(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	_, c, m, err := newTestingTrio(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a second host that is more expensive than the contractor allows.
+	h, err := newTestingHost(build.TempDir("contractor", t.Name(), "expensive"), c.cs.(modules.ConsensusSet), c.tpool.(modules.TransactionPool))
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := h.InternalSettings()
+	settings.MinStoragePrice = maxStoragePrice.MulFloat(1.5)
+	err = h.SetInternalSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = h.Announce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100 && len(c.hdb.ActiveHosts()) < 2; i++ {
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	a := modules.Allowance{
+		Funds:                types.SiacoinPrecision.Mul64(100),
+		Hosts:                2,
+		Period:               20,
+		RenewWindow:          10,
+		AutoPriceBoostFactor: 0.5,
+	}
+	if err := c.SetAllowance(a); err != errAllowanceBoostFactor {
+		t.Fatal("expected errAllowanceBoostFactor, got", err)
+	}
+	a.AutoPriceBoostFactor = 0
+	a.MaxVersionsPerFile = -1
+	if err := c.SetAllowance(a); err != errAllowanceMaxVersions {
+		t.Fatal("expected errAllowanceMaxVersions, got", err)
+	}
+	a.MaxVersionsPerFile = 0
+
+	// With a factor of 2, a single boost should be enough to form a contract
+	// with the expensive host.
+	a.AutoPriceBoostFactor = 2
+	if err := c.SetAllowance(a); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		if len(c.Contracts()) != 2 {
+			return errors.New("contracts were not formed with both hosts")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.mu.RLock()
+	limit := c.storagePriceLimit()
+	c.mu.RUnlock()
+	if limit.Cmp(maxStoragePrice.Mul64(2)) != 0 {
+		t.Fatal("expected the maximum storage price to be boosted once, got", limit)
+	}
+
+	// Once the expensive host drops its price, the boost should fall back to
+	// the default maximum storage price.
+	settings.MinStoragePrice = maxStoragePrice.Div64(2)
+	err = h.SetInternalSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = h.Announce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		if _, err := m.AddBlock(); err != nil {
+			return err
+		}
+		c.mu.RLock()
+		limit = c.storagePriceLimit()
+		c.mu.RUnlock()
+		if limit.Cmp(maxStoragePrice) != 0 {
+			return errors.New("the boosted storage price was not lowered")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The boost is bounded by the factor and maxStoragePriceBoosts.
+	c.mu.Lock()
+	c.storagePriceCap = c.boostedStoragePriceBound()
+	c.mu.Unlock()
+	if c.managedBoostStoragePrice(1) {
+		t.Fatal("the storage price should not be boosted past its bound")
+	}
+
+	// Disabling the boost should restore the default maximum storage price.
+	a.AutoPriceBoostFactor = 0
+	if err := c.SetAllowance(a); err != nil {
+		t.Fatal(err)
+	}
+	c.mu.RLock()
+	limit = c.storagePriceLimit()
+	c.mu.RUnlock()
+	if limit.Cmp(maxStoragePrice) != 0 {
+		t.Fatal("expected the default maximum storage price, got", limit)
+	}
 }
@@ -142,6 +142,21 @@ func (c *Contractor) managedMarkContractsUtility() {
 			contracts[i].GoodForRenew = false
 			continue
 		}
+		// Contract has no utility if the host is winding down, so that its
+		// data is migrated before the contract expires.
+		hostKey := contracts[i].HostPublicKey.String()
+		c.mu.Lock()
+		refused, windingDown := c.windingDown[hostKey]
+		if n := len(host.ScanHistory); windingDown && host.AcceptingContracts && n > 0 && host.ScanHistory[n-1].Timestamp.After(refused) {
+			delete(c.windingDown, hostKey)
+			windingDown = false
+		}
+		c.mu.Unlock()
+		if windingDown {
+			contracts[i].GoodForUpload = false
+			contracts[i].GoodForRenew = false
+			continue
+		}
 		// Contract has no utility if the host is offline.
 		c.mu.Lock()
 		offline := c.isOffline(contracts[i].ID)
@@ -151,11 +166,16 @@ func (c *Contractor) managedMarkContractsUtility() {
 			contracts[i].GoodForRenew = false
 			continue
 		}
+		// Contract should not be used for uploads while the host has a more
+		// recent revision that the renter could not adopt.
+		if contracts[i].Stale {
+			contracts[i].GoodForUpload = false
+		}
 		// Contract has no utility if renew has already completed. (grab some
 		// extra values while we have the mutex)
 		c.mu.RLock()
 		blockHeight := c.blockHeight
-		renewWindow := c.allowance.RenewWindow
+		renewWindow := c.renewWindow()
 		_, renewedPreviously := c.renewedIDs[contracts[i].ID]
 		c.mu.RUnlock()
 		if renewedPreviously {
@@ -163,6 +183,14 @@ func (c *Contractor) managedMarkContractsUtility() {
 			contracts[i].GoodForRenew = false
 			continue
 		}
+		// Contract should not be renewed if the renter has abandoned its
+		// data, but can still be used for uploads.
+		c.mu.RLock()
+		_, garbage := c.garbageContracts[contracts[i].ID]
+		c.mu.RUnlock()
+		if garbage {
+			contracts[i].GoodForRenew = false
+		}
 
 		// Contract should not be used for upload if the number of Merkle roots
 		// exceeds 25e3 - this is in place because the current hosts do not
@@ -190,18 +218,63 @@ func (c *Contractor) managedMarkContractsUtility() {
 		if !exists {
 			continue
 		}
-		contract.GoodForUpload = contracts[i].GoodForUpload
-		contract.GoodForRenew = contracts[i].GoodForRenew
+		contract.GoodForUpload = contracts[i].GoodForUpload && !contract.Stale && !contract.Cancelled
+		contract.GoodForRenew = contracts[i].GoodForRenew && !contract.Cancelled
 		c.contracts[contracts[i].ID] = contract
 	}
+	c.updateStaleContractsAlert()
 	c.mu.Unlock()
 }
 
+// revisedContract returns contract, which was revised by a Downloader or an
+// Editor, with the fields that only the contractor tracks carried over from
+// the contractor's copy of the contract. The caller must hold the lock.
+func (c *Contractor) revisedContract(contract modules.RenterContract) modules.RenterContract {
+	old := c.contracts[contract.ID]
+	contract.RepairDownloadSpending = old.RepairDownloadSpending
+	if old.Cancelled {
+		contract.Cancelled = true
+		contract.GoodForUpload = false
+		contract.GoodForRenew = false
+	}
+	return contract
+}
+
+// renewWindow returns the number of blocks before a contract's end height at
+// which the contract should be renewed.
+func (c *Contractor) renewWindow() types.BlockHeight {
+	if c.renewThreshold != 0 {
+		return c.renewThreshold
+	}
+	return c.allowance.RenewWindow
+}
+
+// PendingRenewals returns the IDs of the contracts that will be renewed during
+// the next round of contract maintenance because they are about to expire. No
+// contracts are pending if automatic renewal is disabled.
+func (c *Contractor) PendingRenewals() []types.FileContractID {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.autoRenewDisabled {
+		return nil
+	}
+	var ids []types.FileContractID
+	for _, contract := range c.contracts {
+		if contract.GoodForRenew && c.blockHeight+c.renewWindow() >= contract.EndHeight() {
+			ids = append(ids, contract.ID)
+		}
+	}
+	return ids
+}
+
 // managedNewContract negotiates an initial file contract with the specified
 // host, saves it, and returns it.
 func (c *Contractor) managedNewContract(host modules.HostDBEntry, contractFunding types.Currency, endHeight types.BlockHeight) (modules.RenterContract, error) {
 	// reject hosts that are too expensive
-	if host.StoragePrice.Cmp(maxStoragePrice) > 0 {
+	c.mu.RLock()
+	storagePriceLimit := c.storagePriceLimit()
+	c.mu.RUnlock()
+	if host.StoragePrice.Cmp(storagePriceLimit) > 0 {
 		return modules.RenterContract{}, errTooExpensive
 	}
 	// cap host.MaxCollateral
@@ -223,13 +296,17 @@ func (c *Contractor) managedNewContract(host modules.HostDBEntry, contractFundin
 		StartHeight:   c.blockHeight,
 		EndHeight:     endHeight,
 		RefundAddress: uc.UnlockHash(),
+
+		PinnedHostCert: c.pinnedHostCerts[host.PublicKey.String()],
+		IdentityKey:    c.identityKey,
 	}
 	c.mu.RUnlock()
 
 	// create transaction builder
 	txnBuilder := c.wallet.StartTransaction()
 
-	contract, err := proto.FormContract(params, txnBuilder, c.tpool, c.hdb, c.tg.StopChan())
+	tpool := c.spendingPool(modules.SpendingPurposeContractFormation, host.PublicKey)
+	contract, err := proto.FormContract(params, txnBuilder, tpool, c.hdb, c.tg.StopChan())
 	if err != nil {
 		txnBuilder.Drop()
 		return modules.RenterContract{}, err
@@ -251,9 +328,12 @@ func (c *Contractor) managedRenew(contract modules.RenterContract, contractFundi
 
 	// Fetch the host associated with this contract.
 	host, ok := c.hdb.Host(contract.HostPublicKey)
+	c.mu.RLock()
+	storagePriceLimit := c.storagePriceLimit()
+	c.mu.RUnlock()
 	if !ok {
 		return modules.RenterContract{}, errors.New("no record of that host")
-	} else if host.StoragePrice.Cmp(maxStoragePrice) > 0 {
+	} else if host.StoragePrice.Cmp(storagePriceLimit) > 0 {
 		return modules.RenterContract{}, errTooExpensive
 	}
 	// cap host.MaxCollateral
@@ -278,12 +358,16 @@ func (c *Contractor) managedRenew(contract modules.RenterContract, contractFundi
 		StartHeight:   c.blockHeight,
 		EndHeight:     newEndHeight,
 		RefundAddress: uc.UnlockHash(),
+
+		PinnedHostCert: c.pinnedHostCerts[host.PublicKey.String()],
+		IdentityKey:    c.identityKey,
 	}
 	c.mu.RUnlock()
 
 	// execute negotiation protocol
+	tpool := c.spendingPool(modules.SpendingPurposeContractRenewal, host.PublicKey)
 	txnBuilder := c.wallet.StartTransaction()
-	newContract, err := proto.Renew(contract, params, txnBuilder, c.tpool, c.hdb, c.tg.StopChan())
+	newContract, err := proto.Renew(contract, params, txnBuilder, tpool, c.hdb, c.tg.StopChan())
 	if proto.IsRevisionMismatch(err) {
 		// return unused outputs to wallet
 		txnBuilder.Drop()
@@ -292,15 +376,21 @@ func (c *Contractor) managedRenew(contract modules.RenterContract, contractFundi
 		cached, ok := c.cachedRevisions[contract.ID]
 		c.mu.RUnlock()
 		if !ok {
-			// nothing we can do; return original error
 			c.log.Printf("wanted to recover contract %v with host %v, but no revision was cached", contract.ID, contract.NetAddress)
-			return modules.RenterContract{}, err
+		} else {
+			c.log.Printf("host %v has different revision for %v; retrying with cached revision", contract.NetAddress, contract.ID)
+			contract.LastRevision = cached.Revision
+			// need to start a new transaction
+			txnBuilder = c.wallet.StartTransaction()
+			newContract, err = proto.Renew(contract, params, txnBuilder, tpool, c.hdb, c.tg.StopChan())
+		}
+		// if the host is ahead of both revisions, try to adopt its revision
+		if resynced, ok := c.managedResyncContract(contract, err); ok {
+			contract = resynced
+			txnBuilder.Drop()
+			txnBuilder = c.wallet.StartTransaction()
+			newContract, err = proto.Renew(contract, params, txnBuilder, tpool, c.hdb, c.tg.StopChan())
 		}
-		c.log.Printf("host %v has different revision for %v; retrying with cached revision", contract.NetAddress, contract.ID)
-		contract.LastRevision = cached.Revision
-		// need to start a new transaction
-		txnBuilder = c.wallet.StartTransaction()
-		newContract, err = proto.Renew(contract, params, txnBuilder, c.tpool, c.hdb, c.tg.StopChan())
 	}
 	if err != nil {
 		txnBuilder.Drop() // return unused outputs to wallet
@@ -342,6 +432,12 @@ func (c *Contractor) threadedContractMaintenance() {
 	}
 	defer c.maintenanceLock.Unlock()
 
+	// Contracts cannot be formed or renewed while the wallet is locked.
+	// threadedMonitorWallet restarts maintenance once the wallet is unlocked.
+	if unlocked, _ := c.managedCheckWallet(); !unlocked {
+		return
+	}
+
 	// Update the utility fields for this contract based on the most recent
 	// hostdb.
 	c.managedMarkContractsUtility()
@@ -389,7 +485,7 @@ func (c *Contractor) threadedContractMaintenance() {
 
 			// Check if the contract is expiring. The funds in the contract are
 			// handled differently based on this information.
-			if c.blockHeight+c.allowance.RenewWindow >= contract.EndHeight() {
+			if c.blockHeight+c.renewWindow() >= contract.EndHeight() {
 				// The contract is expiring. Some of the funds are locked down
 				// to renew the contract, and then the remaining funds can be
 				// allocated to 'availableFunds'.
@@ -427,7 +523,13 @@ func (c *Contractor) threadedContractMaintenance() {
 			if !contract.GoodForRenew {
 				continue
 			}
-			if c.blockHeight+c.allowance.RenewWindow >= contract.EndHeight() {
+			if c.blockHeight+c.renewWindow() >= contract.EndHeight() {
+				// Expiring contracts are left to expire if the renter has
+				// disabled automatic renewal.
+				if c.autoRenewDisabled {
+					continue
+				}
+
 				// This contract needs to be renewed because it is going to
 				// expire soon. First step is to calculate how much money should
 				// be used in the renewal, based on how much of the contract
@@ -477,7 +579,7 @@ func (c *Contractor) threadedContractMaintenance() {
 
 				// skip this host if its prices are too high. managedMarkContractsUtility
 				// should make this redundant, but this is here for extra safety.
-				if host.StoragePrice.Cmp(maxStoragePrice) > 0 || host.UploadBandwidthPrice.Cmp(maxUploadPrice) > 0 {
+				if host.StoragePrice.Cmp(c.storagePriceLimit()) > 0 || host.UploadBandwidthPrice.Cmp(maxUploadPrice) > 0 {
 					continue
 				}
 
@@ -510,6 +612,12 @@ func (c *Contractor) threadedContractMaintenance() {
 
 	// Loop through the contracts and renew them one-by-one.
 	for _, renewal := range renewSet {
+		// Stop if the wallet was locked during maintenance. The remaining
+		// contracts are renewed once the wallet is unlocked.
+		if unlocked, _ := c.managedCheckWallet(); !unlocked {
+			return
+		}
+
 		// Pull the variables out of the renewal.
 		id := renewal.id
 		amount := renewal.amount
@@ -550,7 +658,18 @@ func (c *Contractor) threadedContractMaintenance() {
 
 			// Create the new contract.
 			newContract, err := c.managedRenew(oldContract, amount, endHeight)
-			if err != nil {
+			if err == modules.ErrHostWindingDown {
+				c.log.Printf("Host %v is winding down, migrating the data of contract %v\n", oldContract.NetAddress, id)
+				c.mu.Lock()
+				c.windingDown[oldContract.HostPublicKey.String()] = time.Now()
+				if contract, exists := c.contracts[id]; exists {
+					contract.GoodForUpload = false
+					contract.GoodForRenew = false
+					c.contracts[id] = contract
+				}
+				c.mu.Unlock()
+				return
+			} else if err != nil {
 				c.log.Printf("WARN: failed to renew contract %v with %v: %v\n", id, oldContract.NetAddress, err)
 				return
 			}
@@ -619,13 +738,14 @@ func (c *Contractor) threadedContractMaintenance() {
 	c.mu.RLock()
 	uploadContracts := 0
 	for _, contract := range c.contracts {
-		if contract.GoodForUpload || (contract.GoodForRenew && c.blockHeight+c.allowance.RenewWindow >= contract.EndHeight()) {
+		if contract.GoodForUpload || (contract.GoodForRenew && c.blockHeight+c.renewWindow() >= contract.EndHeight()) {
 			uploadContracts++
 		}
 	}
 	neededContracts := int(c.allowance.Hosts) - uploadContracts
 	c.mu.RUnlock()
 	if neededContracts <= 0 {
+		c.managedLowerStoragePrice()
 		return
 	}
 
@@ -639,46 +759,143 @@ func (c *Contractor) threadedContractMaintenance() {
 	}
 	initialContractFunds := c.allowance.Funds.Div64(c.allowance.Hosts).Div64(3)
 	c.mu.RUnlock()
-	hosts := c.hdb.RandomHosts(neededContracts*2+10, exclude)
 
 	// Form contracts with the hosts one at a time, until we have enough
-	// contracts.
-	for _, host := range hosts {
-		// Determine if we have enough money to form a new contract.
-		if fundsAvailable.Cmp(initialContractFunds) < 0 {
-			c.log.Println("WARN: need to form new contracts, but unable to because of a low allowance")
-			break
-		}
+	// contracts. If every host has been tried and some of them were rejected
+	// for being too expensive, the maximum storage price is boosted and the
+	// hosts are tried again.
+	for boosts := 0; ; boosts++ {
+		hosts := c.hdb.RandomHosts(neededContracts*2+10, exclude)
+		lowFunds := false
+		tooExpensive := 0
+		for _, host := range hosts {
+			// Determine if we have enough money to form a new contract.
+			if fundsAvailable.Cmp(initialContractFunds) < 0 {
+				c.log.Println("WARN: need to form new contracts, but unable to because of a low allowance")
+				lowFunds = true
+				break
+			}
+			if unlocked, _ := c.managedCheckWallet(); !unlocked {
+				return
+			}
 
-		// Attempt forming a contract with this host.
-		newContract, err := c.managedNewContract(host, initialContractFunds, endHeight)
-		if err != nil {
-			c.log.Printf("Attempted to form a contract with %v, but negotiation failed: %v\n", host.NetAddress, err)
-			continue
+			// Attempt forming a contract with this host.
+			newContract, err := c.managedNewContract(host, initialContractFunds, endHeight)
+			if err == errTooExpensive {
+				tooExpensive++
+			}
+			if err != nil {
+				c.log.Printf("Attempted to form a contract with %v, but negotiation failed: %v\n", host.NetAddress, err)
+				continue
+			}
+			newContract.GoodForUpload = true
+			newContract.GoodForRenew = true
+			exclude = append(exclude, newContract.HostPublicKey)
+
+			// Add this contract to the contractor and save.
+			c.mu.Lock()
+			c.contracts[newContract.ID] = newContract
+			err = c.saveSync()
+			c.mu.Unlock()
+			if err != nil {
+				c.log.Println("Unable to save the contractor:", err)
+			}
+
+			// Quit the loop if we've replaced all needed contracts.
+			neededContracts--
+			if neededContracts <= 0 {
+				break
+			}
+
+			// Soft sleep before making the next contract.
+			select {
+			case <-c.tg.StopChan():
+				return
+			case <-time.After(contractFormationInterval):
+			}
 		}
-		newContract.GoodForUpload = true
-		newContract.GoodForRenew = true
 
-		// Add this contract to the contractor and save.
-		c.mu.Lock()
-		c.contracts[newContract.ID] = newContract
-		err = c.saveSync()
-		c.mu.Unlock()
-		if err != nil {
-			c.log.Println("Unable to save the contractor:", err)
+		if neededContracts <= 0 || lowFunds || tooExpensive == 0 || boosts >= maxStoragePriceBoosts {
+			return
+		}
+		if !c.managedBoostStoragePrice(neededContracts) {
+			return
 		}
+	}
+}
 
-		// Quit the loop if we've replaced all needed contracts.
-		neededContracts--
-		if neededContracts <= 0 {
-			break
+// managedBoostStoragePrice multiplies the maximum storage price by the
+// allowance's AutoPriceBoostFactor, up to boostedStoragePriceBound. false is
+// returned if the boost is disabled or the bound has been reached.
+func (c *Contractor) managedBoostStoragePrice(neededContracts int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	factor := c.allowance.AutoPriceBoostFactor
+	if factor <= 1 {
+		return false
+	}
+	oldLimit := c.storagePriceLimit()
+	bound := c.boostedStoragePriceBound()
+	if oldLimit.Cmp(bound) >= 0 {
+		return false
+	}
+	c.storagePriceCap = oldLimit.MulFloat(factor)
+	if c.storagePriceCap.Cmp(bound) > 0 {
+		c.storagePriceCap = bound
+	}
+	c.log.Printf("INFO: still need %v contracts, boosting the maximum storage price from %v to %v per TB per month\n",
+		neededContracts, oldLimit.Mul(modules.BlockBytesPerMonthTerabyte).HumanString(), c.storagePriceCap.Mul(modules.BlockBytesPerMonthTerabyte).HumanString())
+	err := c.saveSync()
+	if err != nil {
+		c.log.Println("Unable to save the contractor after boosting the maximum storage price:", err)
+	}
+	return true
+}
+
+// managedLowerStoragePrice lowers a boosted maximum storage price to the
+// highest storage price charged by the hosts of the current contracts, so that
+// the boost falls back as host prices drop. The cap is never raised here, and
+// it is cleared once maxStoragePrice is enough for every host.
+func (c *Contractor) managedLowerStoragePrice() {
+	c.mu.RLock()
+	oldCap := c.storagePriceCap
+	var hostKeys []types.SiaPublicKey
+	for _, contract := range c.contracts {
+		if contract.GoodForUpload || contract.GoodForRenew {
+			hostKeys = append(hostKeys, contract.HostPublicKey)
 		}
+	}
+	c.mu.RUnlock()
+	if oldCap.IsZero() {
+		return
+	}
 
-		// Soft sleep before making the next contract.
-		select {
-		case <-c.tg.StopChan():
-			return
-		case <-time.After(contractFormationInterval):
+	highest := maxStoragePrice
+	for _, pk := range hostKeys {
+		host, ok := c.hdb.Host(pk)
+		if ok && host.StoragePrice.Cmp(highest) > 0 {
+			highest = host.StoragePrice
 		}
 	}
+	if highest.Cmp(oldCap) >= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.storagePriceCap.Equals(oldCap) {
+		// The cap was changed while the hosts were being looked up.
+		return
+	}
+	if highest.Cmp(maxStoragePrice) <= 0 {
+		c.storagePriceCap = types.ZeroCurrency
+	} else {
+		c.storagePriceCap = highest
+	}
+	c.log.Printf("INFO: lowering the maximum storage price from %v to %v per TB per month\n",
+		oldCap.Mul(modules.BlockBytesPerMonthTerabyte).HumanString(), c.storagePriceLimit().Mul(modules.BlockBytesPerMonthTerabyte).HumanString())
+	err := c.saveSync()
+	if err != nil {
+		c.log.Println("Unable to save the contractor after lowering the maximum storage price:", err)
+	}
 }
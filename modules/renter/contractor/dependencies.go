@@ -20,11 +20,15 @@ type (
 	// transactionBuilder.
 	walletShim interface {
 		NextAddress() (types.UnlockConditions, error)
+		RecordSpending(modules.SpendingRecord, []types.Transaction) error
 		StartTransaction() modules.TransactionBuilder
+		Unlocked() bool
 	}
 	wallet interface {
 		NextAddress() (types.UnlockConditions, error)
+		RecordSpending(modules.SpendingRecord, []types.Transaction) error
 		StartTransaction() transactionBuilder
+		Unlocked() bool
 	}
 	transactionBuilder interface {
 		AddArbitraryData([]byte) uint64
@@ -70,7 +74,58 @@ type walletBridge struct {
 }
 
 func (ws *walletBridge) NextAddress() (types.UnlockConditions, error) { return ws.w.NextAddress() }
-func (ws *walletBridge) StartTransaction() transactionBuilder         { return ws.w.StartTransaction() }
+func (ws *walletBridge) RecordSpending(record modules.SpendingRecord, txnSet []types.Transaction) error {
+	return ws.w.RecordSpending(record, txnSet)
+}
+func (ws *walletBridge) StartTransaction() transactionBuilder { return ws.w.StartTransaction() }
+func (ws *walletBridge) Unlocked() bool                       { return ws.w.Unlocked() }
+
+// A spendingTransactionPool forwards the transaction sets of a contract
+// formation or renewal to the transaction pool, and records the sets that the
+// pool accepts in the spending log of the wallet.
+type spendingTransactionPool struct {
+	c       *Contractor
+	purpose modules.SpendingPurpose
+	host    types.SiaPublicKey
+}
+
+// spendingPool returns a transaction pool that records the transaction sets
+// it accepts as spending for purpose with host.
+func (c *Contractor) spendingPool(purpose modules.SpendingPurpose, host types.SiaPublicKey) transactionPool {
+	return &spendingTransactionPool{
+		c:       c,
+		purpose: purpose,
+		host:    host,
+	}
+}
+
+func (tp *spendingTransactionPool) AcceptTransactionSet(txnSet []types.Transaction) error {
+	if err := tp.c.tpool.AcceptTransactionSet(txnSet); err != nil {
+		return err
+	}
+	record := modules.SpendingRecord{
+		Module:       modules.RenterDir,
+		Purpose:      tp.purpose,
+		Counterparty: tp.host.String(),
+	}
+	if len(txnSet) > 0 {
+		txn := txnSet[len(txnSet)-1]
+		if len(txn.FileContracts) > 0 {
+			record.RelatedID = txn.FileContractID(0)
+		}
+		for _, fee := range txn.MinerFees {
+			record.Fee = record.Fee.Add(fee)
+		}
+	}
+	if err := tp.c.wallet.RecordSpending(record, txnSet); err != nil {
+		tp.c.log.Println("WARN: failed to record spending:", err)
+	}
+	return nil
+}
+
+func (tp *spendingTransactionPool) FeeEstimation() (min, max types.Currency) {
+	return tp.c.tpool.FeeEstimation()
+}
 
 // stdPersist implements the persister interface via the journal type. The
 // filename required by these functions is internal to stdPersist.
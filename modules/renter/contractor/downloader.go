@@ -22,6 +22,11 @@ type Downloader interface {
 	// retrieve.
 	Sector(root crypto.Hash) ([]byte, error)
 
+	// RepairSector retrieves a sector like Sector, for the repair of a file
+	// whose data is not available locally. The payment for the sector is also
+	// counted as repair download spending of the contract.
+	RepairSector(root crypto.Hash) ([]byte, error)
+
 	// Close terminates the connection to the host.
 	Close() error
 }
@@ -35,8 +40,9 @@ type hostDownloader struct {
 	contractor   *Contractor
 	downloader   *proto.Downloader
 	hostSettings modules.HostExternalSettings
-	invalid      bool   // true if invalidate has been called
-	speed        uint64 // Bytes per second.
+	invalid      bool           // true if invalidate has been called
+	spending     types.Currency // DownloadSpending after the last sector
+	speed        uint64         // Bytes per second.
 	mu           sync.Mutex
 }
 
@@ -69,6 +75,19 @@ func (hd *hostDownloader) HostSettings() modules.HostExternalSettings {
 // the underlying contract to pay the host proportionally to the data
 // retrieve.
 func (hd *hostDownloader) Sector(root crypto.Hash) ([]byte, error) {
+	return hd.sector(root, false)
+}
+
+// RepairSector retrieves a sector like Sector, and counts the payment for the
+// sector as repair download spending.
+func (hd *hostDownloader) RepairSector(root crypto.Hash) ([]byte, error) {
+	return hd.sector(root, true)
+}
+
+// sector retrieves the sector with the specified Merkle root. If repair is
+// set, the payment for the sector is added to the repair download spending of
+// the contract.
+func (hd *hostDownloader) sector(root crypto.Hash, repair bool) ([]byte, error) {
 	hd.mu.Lock()
 	defer hd.mu.Unlock()
 	if hd.invalid {
@@ -80,10 +99,16 @@ func (hd *hostDownloader) Sector(root crypto.Hash) ([]byte, error) {
 	}
 
 	hd.contractor.mu.Lock()
+	contract = hd.contractor.revisedContract(contract)
+	if repair && contract.DownloadSpending.Cmp(hd.spending) > 0 {
+		contract.RepairDownloadSpending = contract.RepairDownloadSpending.Add(contract.DownloadSpending.Sub(hd.spending))
+	}
+	hd.spending = contract.DownloadSpending
 	hd.contractor.contracts[contract.ID] = contract
 	hd.contractor.persist.update(updateDownloadRevision{
-		NewRevisionTxn:      contract.LastRevisionTxn,
-		NewDownloadSpending: contract.DownloadSpending,
+		NewRevisionTxn:            contract.LastRevisionTxn,
+		NewDownloadSpending:       contract.DownloadSpending,
+		NewRepairDownloadSpending: contract.RepairDownloadSpending,
 	})
 	hd.contractor.mu.Unlock()
 
@@ -109,6 +134,25 @@ func (hd *hostDownloader) Close() error {
 	return hd.downloader.Close()
 }
 
+// SetDownloadRateLimit sets the RateLimit that limits the bandwidth of the
+// Contractor's downloads. The RateLimit can be shared with other Contractors,
+// to limit their combined bandwidth. Downloaders that were created before the
+// call are not limited.
+func (c *Contractor) SetDownloadRateLimit(rl *proto.RateLimit) {
+	c.mu.Lock()
+	c.downloadLimit = rl
+	c.mu.Unlock()
+}
+
+// SetBandwidthLimiter sets the BandwidthLimiter that limits the connections of
+// the Contractor's downloaders and editors by the node's bandwidth budget.
+// Connections that were opened before the call are not limited.
+func (c *Contractor) SetBandwidthLimiter(bl *modules.BandwidthLimiter) {
+	c.mu.Lock()
+	c.bandwidthLimiter = bl
+	c.mu.Unlock()
+}
+
 // Downloader returns a Downloader object that can be used to download sectors
 // from a host.
 func (c *Contractor) Downloader(id types.FileContractID, cancel <-chan struct{}) (_ Downloader, err error) {
@@ -117,7 +161,10 @@ func (c *Contractor) Downloader(id types.FileContractID, cancel <-chan struct{})
 	cachedDownloader, haveDownloader := c.downloaders[id]
 	height := c.blockHeight
 	contract, haveContract := c.contracts[id]
+	pinnedCert := c.pinnedHostCerts[contract.HostPublicKey.String()]
 	renewing := c.renewing[id]
+	downloadLimit := c.downloadLimit
+	bandwidthLimiter := c.bandwidthLimiter
 	c.mu.RUnlock()
 
 	if renewing {
@@ -144,6 +191,7 @@ func (c *Contractor) Downloader(id types.FileContractID, cancel <-chan struct{})
 	}
 	// Update the contract to the most recent net address for the host.
 	contract.NetAddress = host.NetAddress
+	contract.PinnedHostCert = pinnedCert
 
 	// acquire revising lock
 	c.mu.Lock()
@@ -176,20 +224,24 @@ func (c *Contractor) Downloader(id types.FileContractID, cancel <-chan struct{})
 	}
 
 	// create downloader
-	d, err := proto.NewDownloader(host, contract, c.hdb, cancel)
+	d, err := proto.NewDownloader(host, contract, c.hdb, bandwidthLimiter, downloadLimit, cancel)
 	if proto.IsRevisionMismatch(err) {
 		// try again with the cached revision
 		c.mu.RLock()
 		cached, ok := c.cachedRevisions[contract.ID]
 		c.mu.RUnlock()
 		if !ok {
-			// nothing we can do; return original error
 			c.log.Printf("wanted to recover contract %v with host %v, but no revision was cached", contract.ID, contract.NetAddress)
-			return nil, err
+		} else {
+			c.log.Printf("host %v has different revision for %v; retrying with cached revision", contract.NetAddress, contract.ID)
+			contract.LastRevision = cached.Revision
+			d, err = proto.NewDownloader(host, contract, c.hdb, bandwidthLimiter, downloadLimit, cancel)
+		}
+		// if the host is ahead of both revisions, try to adopt its revision
+		if resynced, ok := c.managedResyncContract(contract, err); ok {
+			contract = resynced
+			d, err = proto.NewDownloader(host, contract, c.hdb, bandwidthLimiter, downloadLimit, cancel)
 		}
-		c.log.Printf("host %v has different revision for %v; retrying with cached revision", contract.NetAddress, contract.ID)
-		contract.LastRevision = cached.Revision
-		d, err = proto.NewDownloader(host, contract, c.hdb, cancel)
 		// needs to be handled separately since a revision mismatch is not automatically a failed interaction
 		if proto.IsRevisionMismatch(err) {
 			c.hdb.IncrementFailedInteractions(host.PublicKey)
@@ -209,6 +261,7 @@ func (c *Contractor) Downloader(id types.FileContractID, cancel <-chan struct{})
 		contractor:   c,
 		downloader:   d,
 		hostSettings: host.HostExternalSettings,
+		spending:     contract.DownloadSpending,
 	}
 	c.mu.Lock()
 	c.downloaders[contract.ID] = hd
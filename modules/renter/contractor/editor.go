@@ -115,6 +115,7 @@ func (he *hostEditor) Upload(data []byte) (_ crypto.Hash, err error) {
 		return crypto.Hash{}, err
 	}
 	he.contractor.mu.Lock()
+	contract = he.contractor.revisedContract(contract)
 	he.contractor.contracts[contract.ID] = contract
 	he.contractor.persist.update(updateUploadRevision{
 		NewRevisionTxn:     contract.LastRevisionTxn,
@@ -136,14 +137,24 @@ func (he *hostEditor) Delete(root crypto.Hash) (err error) {
 	if he.invalid {
 		return errInvalidEditor
 	}
+	index := -1
+	for i, h := range he.contract.MerkleRoots {
+		if h == root {
+			index = i
+		}
+	}
 	contract, err := he.editor.Delete(root)
 	if err != nil {
 		return err
 	}
 
 	he.contractor.mu.Lock()
+	contract = he.contractor.revisedContract(contract)
 	he.contractor.contracts[contract.ID] = contract
-	he.contractor.saveSync()
+	he.contractor.persist.update(updateDeleteRevision{
+		NewRevisionTxn: contract.LastRevisionTxn,
+		SectorIndex:    index,
+	})
 	he.contractor.mu.Unlock()
 	he.contract = contract
 
@@ -162,6 +173,7 @@ func (he *hostEditor) Modify(oldRoot, newRoot crypto.Hash, offset uint64, newDat
 		return err
 	}
 	he.contractor.mu.Lock()
+	contract = he.contractor.revisedContract(contract)
 	he.contractor.contracts[contract.ID] = contract
 	he.contractor.saveSync()
 	he.contractor.mu.Unlock()
@@ -170,6 +182,16 @@ func (he *hostEditor) Modify(oldRoot, newRoot crypto.Hash, offset uint64, newDat
 	return nil
 }
 
+// SetUploadRateLimit sets the RateLimit that limits the bandwidth of the
+// Contractor's uploads. The RateLimit can be shared with other Contractors, to
+// limit their combined bandwidth. Editors that were created before the call
+// are not limited.
+func (c *Contractor) SetUploadRateLimit(rl *proto.RateLimit) {
+	c.mu.Lock()
+	c.uploadLimit = rl
+	c.mu.Unlock()
+}
+
 // Editor returns a Editor object that can be used to upload, modify, and
 // delete sectors on a host.
 func (c *Contractor) Editor(id types.FileContractID, cancel <-chan struct{}) (_ Editor, err error) {
@@ -178,7 +200,11 @@ func (c *Contractor) Editor(id types.FileContractID, cancel <-chan struct{}) (_
 	cachedEditor, haveEditor := c.editors[id]
 	height := c.blockHeight
 	contract, haveContract := c.contracts[id]
+	pinnedCert := c.pinnedHostCerts[contract.HostPublicKey.String()]
 	renewing := c.renewing[id]
+	storagePriceLimit := c.storagePriceLimit()
+	bandwidthLimiter := c.bandwidthLimiter
+	uploadLimit := c.uploadLimit
 	c.mu.RUnlock()
 
 	if renewing {
@@ -200,7 +226,7 @@ func (c *Contractor) Editor(id types.FileContractID, cancel <-chan struct{}) (_
 		return nil, errors.New("contract has already ended")
 	} else if !haveHost {
 		return nil, errors.New("no record of that host")
-	} else if host.StoragePrice.Cmp(maxStoragePrice) > 0 {
+	} else if host.StoragePrice.Cmp(storagePriceLimit) > 0 {
 		return nil, errTooExpensive
 	} else if host.UploadBandwidthPrice.Cmp(maxUploadPrice) > 0 {
 		return nil, errTooExpensive
@@ -211,6 +237,7 @@ func (c *Contractor) Editor(id types.FileContractID, cancel <-chan struct{}) (_
 		}
 	}
 	contract.NetAddress = host.NetAddress
+	contract.PinnedHostCert = pinnedCert
 
 	// acquire revising lock
 	c.mu.Lock()
@@ -243,21 +270,25 @@ func (c *Contractor) Editor(id types.FileContractID, cancel <-chan struct{}) (_
 	}
 
 	// create editor
-	e, err := proto.NewEditor(host, contract, height, c.hdb, cancel)
+	e, err := proto.NewEditor(host, contract, height, c.hdb, bandwidthLimiter, uploadLimit, cancel)
 	if proto.IsRevisionMismatch(err) {
 		// try again with the cached revision
 		c.mu.RLock()
 		cached, ok := c.cachedRevisions[contract.ID]
 		c.mu.RUnlock()
 		if !ok {
-			// nothing we can do; return original error
 			c.log.Printf("wanted to recover contract %v with host %v, but no revision was cached", contract.ID, contract.NetAddress)
-			return nil, err
+		} else {
+			c.log.Printf("host %v has different revision for %v; retrying with cached revision", contract.NetAddress, contract.ID)
+			contract.LastRevision = cached.Revision
+			contract.MerkleRoots = cached.MerkleRoots
+			e, err = proto.NewEditor(host, contract, height, c.hdb, bandwidthLimiter, uploadLimit, cancel)
+		}
+		// if the host is ahead of both revisions, try to adopt its revision
+		if resynced, ok := c.managedResyncContract(contract, err); ok {
+			contract = resynced
+			e, err = proto.NewEditor(host, contract, height, c.hdb, bandwidthLimiter, uploadLimit, cancel)
 		}
-		c.log.Printf("host %v has different revision for %v; retrying with cached revision", contract.NetAddress, contract.ID)
-		contract.LastRevision = cached.Revision
-		contract.MerkleRoots = cached.MerkleRoots
-		e, err = proto.NewEditor(host, contract, height, c.hdb, cancel)
 		// needs to be handled separately since a revision mismatch is not automatically a failed interaction
 		if proto.IsRevisionMismatch(err) {
 			c.hdb.IncrementFailedInteractions(host.PublicKey)
@@ -0,0 +1,23 @@
+package contractor
+
+import (
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// SetGarbageContracts sets the contracts that the renter has found to be
+// abandoned, because none of their sectors are used by its files. Abandoned
+// contracts are not renewed, but can still be uploaded to. Contracts that are
+// not in ids are renewed again once their utility is next updated. The set is
+// not persistent.
+func (c *Contractor) SetGarbageContracts(ids []types.FileContractID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.garbageContracts = make(map[types.FileContractID]struct{}, len(ids))
+	for _, id := range ids {
+		c.garbageContracts[id] = struct{}{}
+		if contract, exists := c.contracts[id]; exists && contract.GoodForRenew {
+			contract.GoodForRenew = false
+			c.contracts[id] = contract
+		}
+	}
+}
@@ -750,6 +750,129 @@ func TestIntegrationResync(t *testing.T) {
 	editor.Close()
 }
 
+// TestIntegrationStaleContract tests that the contractor adopts the more
+// recent revision of a host if the host is ahead of the renter, and marks the
+// contract as stale if that revision covers data the renter does not know.
+func TestIntegrationStaleContract(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// create testing trio
+	h, c, _, err := newTestingTrio(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	defer c.Close()
+
+	// get the host's entry from the db
+	hostEntry, ok := c.hdb.Host(h.PublicKey())
+	if !ok {
+		t.Fatal("no entry for host in db")
+	}
+
+	// form a contract with the host and upload a sector
+	contract, err := c.managedNewContract(hostEntry, types.SiacoinPrecision.Mul64(50), c.blockHeight+100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.mu.Lock()
+	c.contracts[contract.ID] = contract
+	c.mu.Unlock()
+	editor, err := c.Editor(contract.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := fastrand.Bytes(int(modules.SectorSize))
+	root, err := editor.Upload(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	editor.Close()
+
+	// backup returns a function that reverts the contract to its current
+	// state, as if the renter was restored from a backup.
+	backup := func() func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		contract, cached := c.contracts[contract.ID], c.cachedRevisions[contract.ID]
+		return func() {
+			c.mu.Lock()
+			c.contracts[contract.ID] = contract
+			c.cachedRevisions[contract.ID] = cached
+			c.mu.Unlock()
+		}
+	}
+
+	// download the sector after taking a backup, so that the host is ahead
+	// of the backup without any new data
+	restoreBackup := backup()
+	for i := 0; i < 2; i++ {
+		downloader, err := c.Downloader(contract.ID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := downloader.Sector(root); err != nil {
+			t.Fatal(err)
+		}
+		downloader.Close()
+	}
+	c.mu.RLock()
+	hostRevision := c.contracts[contract.ID].LastRevision
+	c.mu.RUnlock()
+	restoreBackup()
+
+	// the downloader should adopt the host's revision
+	downloader, err := c.Downloader(contract.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := downloader.Sector(root); err != nil {
+		t.Fatal(err)
+	}
+	downloader.Close()
+	c.mu.RLock()
+	resynced := c.contracts[contract.ID]
+	c.mu.RUnlock()
+	if resynced.Stale || resynced.LastRevision.NewRevisionNumber != hostRevision.NewRevisionNumber+1 {
+		t.Fatal("contract was not resynchronized:", resynced.Stale, resynced.LastRevision.NewRevisionNumber, hostRevision.NewRevisionNumber)
+	}
+	if len(c.Alerts()) != 0 {
+		t.Fatal("expected no alerts, got", c.Alerts())
+	}
+
+	// upload a sector after taking a backup, so that the host's revision
+	// covers data the renter does not know about
+	restoreBackup = backup()
+	editor, err = c.Editor(contract.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := editor.Upload(data); err != nil {
+		t.Fatal(err)
+	}
+	editor.Close()
+	c.mu.RLock()
+	hostRevision = c.contracts[contract.ID].LastRevision
+	c.mu.RUnlock()
+	restoreBackup()
+
+	// the editor should fail and mark the contract as stale
+	if _, err := c.Editor(contract.ID, nil); !proto.IsRevisionMismatch(err) {
+		t.Fatal("expected revision mismatch, got", err)
+	}
+	c.mu.RLock()
+	stale := c.contracts[contract.ID]
+	c.mu.RUnlock()
+	if !stale.Stale || stale.GoodForUpload || stale.HostRevisionNumber != hostRevision.NewRevisionNumber {
+		t.Fatal("contract was not marked as stale:", stale.Stale, stale.GoodForUpload, stale.HostRevisionNumber, hostRevision.NewRevisionNumber)
+	}
+	if alerts := c.Alerts(); len(alerts) != 1 || alerts[0].Category != modules.RenterAlertCategoryStaleContracts {
+		t.Fatal("expected a stale contracts alert, got", alerts)
+	}
+}
+
 // TestIntegrationDownloaderCaching tests that downloaders are properly cached
 // by the contractor. When two downloaders are requested for the same
 // contract, only one underlying downloader should be created.
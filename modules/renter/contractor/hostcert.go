@@ -0,0 +1,35 @@
+package contractor
+
+import (
+	"github.com/NebulousLabs/Sia/modules/renter/proto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// SetPinnedHostCert pins the TLS certificate of a host, which may be PEM or
+// DER encoded. The contractor connects to the host over TLS and refuses the
+// host if it presents a different certificate. A nil cert removes the pin.
+// The pin applies to the existing contracts with the host when their next
+// editor or downloader is created.
+func (c *Contractor) SetPinnedHostCert(hostKey types.SiaPublicKey, cert []byte) error {
+	if len(cert) > 0 {
+		var err error
+		cert, err = proto.ParsePinnedCert(cert)
+		if err != nil {
+			return err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(cert) == 0 {
+		delete(c.pinnedHostCerts, hostKey.String())
+	} else {
+		c.pinnedHostCerts[hostKey.String()] = cert
+	}
+	for id, contract := range c.contracts {
+		if contract.HostPublicKey.String() == hostKey.String() {
+			contract.PinnedHostCert = cert
+			c.contracts[id] = contract
+		}
+	}
+	return c.saveSync()
+}
@@ -232,6 +232,10 @@ func (set updateSet) MarshalJSON() ([]byte, error) {
 			marshaledSet[i].Type = "cachedUploadRevision"
 		case updateCachedDownloadRevision:
 			marshaledSet[i].Type = "cachedDownloadRevision"
+		case updateDeleteRevision:
+			marshaledSet[i].Type = "deleteRevision"
+		case updateCachedDeleteRevision:
+			marshaledSet[i].Type = "cachedDeleteRevision"
 		}
 	}
 	return json.Marshal(marshaledSet)
@@ -266,6 +270,14 @@ func (set *updateSet) UnmarshalJSON(b []byte) error {
 			var cdr updateCachedDownloadRevision
 			err = json.Unmarshal(u.Data, &cdr)
 			*set = append(*set, cdr)
+		case "deleteRevision":
+			var dr updateDeleteRevision
+			err = json.Unmarshal(u.Data, &dr)
+			*set = append(*set, dr)
+		case "cachedDeleteRevision":
+			var cdr updateCachedDeleteRevision
+			err = json.Unmarshal(u.Data, &cdr)
+			*set = append(*set, cdr)
 		}
 		if err != nil {
 			return err
@@ -314,12 +326,13 @@ func (u updateUploadRevision) apply(data *contractorPersist) {
 // updateUploadRevision is a journalUpdate that records the new data
 // associated with downloading a sector from a host.
 type updateDownloadRevision struct {
-	NewRevisionTxn      types.Transaction `json:"newrevisiontxn"`
-	NewDownloadSpending types.Currency    `json:"newdownloadspending"`
+	NewRevisionTxn            types.Transaction `json:"newrevisiontxn"`
+	NewDownloadSpending       types.Currency    `json:"newdownloadspending"`
+	NewRepairDownloadSpending types.Currency    `json:"newrepairdownloadspending"`
 }
 
-// apply sets the LastRevision, LastRevisionTxn, and DownloadSpending fields
-// of the contract being revised.
+// apply sets the LastRevision, LastRevisionTxn, DownloadSpending, and
+// RepairDownloadSpending fields of the contract being revised.
 func (u updateDownloadRevision) apply(data *contractorPersist) {
 	if len(u.NewRevisionTxn.FileContractRevisions) == 0 {
 		build.Critical("updateDownloadRevision is missing its FileContractRevision")
@@ -330,9 +343,44 @@ func (u updateDownloadRevision) apply(data *contractorPersist) {
 	c.LastRevisionTxn = u.NewRevisionTxn
 	c.LastRevision = rev
 	c.DownloadSpending = u.NewDownloadSpending
+	c.RepairDownloadSpending = u.NewRepairDownloadSpending
 	data.Contracts[rev.ParentID.String()] = c
 }
 
+// updateDeleteRevision is a journalUpdate that records the new data
+// associated with deleting a sector from a host.
+type updateDeleteRevision struct {
+	NewRevisionTxn types.Transaction `json:"newrevisiontxn"`
+	SectorIndex    int               `json:"sectorindex"`
+}
+
+// apply sets the LastRevision and LastRevisionTxn fields of the contract being
+// revised. It also removes the deleted Merkle root from the contract's Merkle
+// root set.
+func (u updateDeleteRevision) apply(data *contractorPersist) {
+	if len(u.NewRevisionTxn.FileContractRevisions) == 0 {
+		build.Critical("updateDeleteRevision is missing its FileContractRevision")
+		return
+	}
+	rev := u.NewRevisionTxn.FileContractRevisions[0]
+	c := data.Contracts[rev.ParentID.String()]
+	c.LastRevisionTxn = u.NewRevisionTxn
+	c.LastRevision = rev
+	c.MerkleRoots = removeRoot(c.MerkleRoots, u.SectorIndex)
+	data.Contracts[rev.ParentID.String()] = c
+}
+
+// removeRoot returns the set of Merkle roots without the root at index. The
+// set is returned unchanged if index is out of bounds.
+func removeRoot(roots []crypto.Hash, index int) []crypto.Hash {
+	if index < 0 || index >= len(roots) {
+		return roots
+	}
+	newRoots := make([]crypto.Hash, 0, len(roots)-1)
+	newRoots = append(newRoots, roots[:index]...)
+	return append(newRoots, roots[index+1:]...)
+}
+
 // updateCachedUploadRevision is a journalUpdate that records the unsigned
 // revision sent to the host during a sector upload, along with the Merkle
 // root of the new sector.
@@ -357,6 +405,23 @@ func (u updateCachedUploadRevision) apply(data *contractorPersist) {
 	data.CachedRevisions[u.Revision.ParentID.String()] = c
 }
 
+// updateCachedDeleteRevision is a journalUpdate that records the unsigned
+// revision sent to the host during a sector deletion, along with the index of
+// the deleted sector.
+type updateCachedDeleteRevision struct {
+	Revision    types.FileContractRevision `json:"revision"`
+	SectorIndex int                        `json:"sectorindex"`
+}
+
+// apply sets the Revision field of the cachedRevision associated with the
+// contract being revised, and removes the Merkle root of the deleted sector.
+func (u updateCachedDeleteRevision) apply(data *contractorPersist) {
+	c := data.CachedRevisions[u.Revision.ParentID.String()]
+	c.Revision = u.Revision
+	c.MerkleRoots = removeRoot(c.MerkleRoots, u.SectorIndex)
+	data.CachedRevisions[u.Revision.ParentID.String()] = c
+}
+
 // updateCachedDownloadRevision is a journalUpdate that records the unsigned
 // revision sent to the host during a sector download.
 type updateCachedDownloadRevision struct {
@@ -11,6 +11,7 @@ import (
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -67,6 +68,49 @@ func TestJournal(t *testing.T) {
 	}
 }
 
+// TestJournalDeleteRevision checks that the delete updates remove the deleted
+// sector from the Merkle roots of the contract and its cached revision.
+func TestJournalDeleteRevision(t *testing.T) {
+	j, cleanup := tempJournal(t)
+	defer cleanup()
+
+	roots := []crypto.Hash{{1}, {2}, {3}}
+	rev := types.FileContractRevision{ParentID: types.FileContractID{1}}
+	txn := types.Transaction{FileContractRevisions: []types.FileContractRevision{rev}}
+	var us updateSet
+	for i, root := range roots {
+		us = append(us,
+			updateCachedUploadRevision{Revision: rev, SectorRoot: root, SectorIndex: i},
+			updateUploadRevision{NewRevisionTxn: txn, NewSectorRoot: root, NewSectorIndex: i},
+		)
+	}
+	us = append(us,
+		updateCachedDeleteRevision{Revision: rev, SectorIndex: 1},
+		updateDeleteRevision{NewRevisionTxn: txn, SectorIndex: 0},
+	)
+	if err := j.update(us); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var data contractorPersist
+	j2, err := openJournal(j.filename, &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j2.Close()
+	cached := data.CachedRevisions[rev.ParentID.String()].MerkleRoots
+	if !reflect.DeepEqual(cached, modules.MerkleRootSet{roots[0], roots[2]}) {
+		t.Fatal("cached delete revision applied incorrectly:", cached)
+	}
+	contractRoots := data.Contracts[rev.ParentID.String()].MerkleRoots
+	if !reflect.DeepEqual(contractRoots, modules.MerkleRootSet{roots[1], roots[2]}) {
+		t.Fatal("delete revision applied incorrectly:", contractRoots)
+	}
+}
+
 func TestJournalCheckpoint(t *testing.T) {
 	j, cleanup := tempJournal(t)
 	defer cleanup()
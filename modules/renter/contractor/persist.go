@@ -11,26 +11,36 @@ import (
 
 // contractorPersist defines what Contractor data persists across sessions.
 type contractorPersist struct {
-	Allowance       modules.Allowance                 `json:"allowance"`
-	BlockHeight     types.BlockHeight                 `json:"blockheight"`
-	CachedRevisions map[string]cachedRevision         `json:"cachedrevisions"`
-	Contracts       map[string]modules.RenterContract `json:"contracts"`
-	CurrentPeriod   types.BlockHeight                 `json:"currentperiod"`
-	LastChange      modules.ConsensusChangeID         `json:"lastchange"`
-	OldContracts    []modules.RenterContract          `json:"oldcontracts"`
-	RenewedIDs      map[string]string                 `json:"renewedids"`
+	Allowance         modules.Allowance                 `json:"allowance"`
+	AutoRenewDisabled bool                              `json:"autorenewdisabled"`
+	BlockHeight       types.BlockHeight                 `json:"blockheight"`
+	CachedRevisions   map[string]cachedRevision         `json:"cachedrevisions"`
+	Contracts         map[string]modules.RenterContract `json:"contracts"`
+	CurrentPeriod     types.BlockHeight                 `json:"currentperiod"`
+	IdentityKey       crypto.SecretKey                  `json:"identitykey"`
+	LastChange        modules.ConsensusChangeID         `json:"lastchange"`
+	OldContracts      []modules.RenterContract          `json:"oldcontracts"`
+	PinnedHostCerts   map[string][]byte                 `json:"pinnedhostcerts"`
+	RenewThreshold    types.BlockHeight                 `json:"renewthreshold"`
+	RenewedIDs        map[string]string                 `json:"renewedids"`
+	StoragePriceCap   types.Currency                    `json:"storagepricecap"`
 }
 
 // persistData returns the data in the Contractor that will be saved to disk.
 func (c *Contractor) persistData() contractorPersist {
 	data := contractorPersist{
-		Allowance:       c.allowance,
-		BlockHeight:     c.blockHeight,
-		CachedRevisions: make(map[string]cachedRevision),
-		Contracts:       make(map[string]modules.RenterContract),
-		CurrentPeriod:   c.currentPeriod,
-		LastChange:      c.lastChange,
-		RenewedIDs:      make(map[string]string),
+		Allowance:         c.allowance,
+		AutoRenewDisabled: c.autoRenewDisabled,
+		BlockHeight:       c.blockHeight,
+		CachedRevisions:   make(map[string]cachedRevision),
+		Contracts:         make(map[string]modules.RenterContract),
+		CurrentPeriod:     c.currentPeriod,
+		IdentityKey:       c.identityKey,
+		LastChange:        c.lastChange,
+		PinnedHostCerts:   c.pinnedHostCerts,
+		RenewThreshold:    c.renewThreshold,
+		RenewedIDs:        make(map[string]string),
+		StoragePriceCap:   c.storagePriceCap,
 	}
 	for _, rev := range c.cachedRevisions {
 		data.CachedRevisions[rev.Revision.ParentID.String()] = rev
@@ -56,7 +66,10 @@ func (c *Contractor) load() error {
 		return err
 	}
 	c.allowance = data.Allowance
+	c.autoRenewDisabled = data.AutoRenewDisabled
 	c.blockHeight = data.BlockHeight
+	c.identityKey = data.IdentityKey
+	c.storagePriceCap = data.StoragePriceCap
 	for _, rev := range data.CachedRevisions {
 		c.cachedRevisions[rev.Revision.ParentID] = rev
 	}
@@ -106,6 +119,10 @@ func (c *Contractor) load() error {
 	}
 
 	c.lastChange = data.LastChange
+	for hostKey, cert := range data.PinnedHostCerts {
+		c.pinnedHostCerts[hostKey] = cert
+	}
+	c.renewThreshold = data.RenewThreshold
 	for _, contract := range data.OldContracts {
 		c.oldContracts[contract.ID] = contract
 	}
@@ -129,13 +146,30 @@ func (c *Contractor) saveSync() error {
 	return c.persist.save(c.persistData())
 }
 
-// saveUploadRevision returns a function that saves an upload revision. It is
-// used by the Editor type to prevent desynchronizing with the host.
+// saveUploadRevision returns a function that saves an upload or delete
+// revision. It is used by the Editor type to prevent desynchronizing with the
+// host.
 func (c *Contractor) saveUploadRevision(id types.FileContractID) func(types.FileContractRevision, []crypto.Hash) error {
 	return func(rev types.FileContractRevision, newRoots []crypto.Hash) error {
 		c.mu.Lock()
 		defer c.mu.Unlock()
+		oldRoots := c.cachedRevisions[id].MerkleRoots
 		c.cachedRevisions[id] = cachedRevision{rev, newRoots}
+		if len(newRoots) == len(oldRoots)-1 {
+			// A sector was deleted; the deleted root is the first root that
+			// differs.
+			index := len(newRoots)
+			for i := range newRoots {
+				if newRoots[i] != oldRoots[i] {
+					index = i
+					break
+				}
+			}
+			return c.persist.update(updateCachedDeleteRevision{
+				Revision:    rev,
+				SectorIndex: index,
+			})
+		}
 		return c.persist.update(updateCachedUploadRevision{
 			Revision: rev,
 			// only the last root is new
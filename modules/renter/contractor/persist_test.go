@@ -48,6 +48,8 @@ func TestSaveLoad(t *testing.T) {
 		{1}: {ID: types.FileContractID{1}, HostPublicKey: types.SiaPublicKey{Key: []byte("bar")}},
 		{2}: {ID: types.FileContractID{2}, HostPublicKey: types.SiaPublicKey{Key: []byte("baz")}},
 	}
+	c.autoRenewDisabled = true
+	c.renewThreshold = 7
 
 	// save, clear, and reload
 	err := c.save()
@@ -55,6 +57,8 @@ func TestSaveLoad(t *testing.T) {
 		t.Fatal(err)
 	}
 	c.hdb = stubHostDB{}
+	c.autoRenewDisabled = false
+	c.renewThreshold = 0
 	c.contracts = make(map[types.FileContractID]modules.RenterContract)
 	c.renewedIDs = make(map[types.FileContractID]types.FileContractID)
 	c.cachedRevisions = make(map[types.FileContractID]cachedRevision)
@@ -88,6 +92,9 @@ func TestSaveLoad(t *testing.T) {
 	if !ok0 || !ok1 || !ok2 {
 		t.Fatal("oldContracts were not restored properly:", c.oldContracts)
 	}
+	if !c.autoRenewDisabled || c.renewThreshold != 7 {
+		t.Fatal("renewal policy was not restored properly:", c.autoRenewDisabled, c.renewThreshold)
+	}
 
 	// use stdPersist instead of mock
 	c.persist = newPersist(build.TempDir("contractor", t.Name()))
@@ -0,0 +1,101 @@
+package contractor
+
+// stale.go resynchronizes contracts with hosts that have a more recent
+// revision than the renter, which happens if the renter was restored from an
+// old backup. The host sends its most recent revision, together with the
+// signatures of both parties, whenever a revision, download, or renewal is
+// started, so the renter adopts that revision if it is valid. If it cannot,
+// the contract is marked as stale and is no longer used for uploads, and an
+// alert is raised until no active contract is stale.
+
+import (
+	"fmt"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/renter/proto"
+)
+
+// managedResyncContract is called if starting a session with the host of
+// contract failed with err. If the host is ahead of the renter, the host's
+// revision is adopted and the resynchronized contract is returned, so that the
+// session can be retried. If the revision cannot be adopted, the contract is
+// marked as stale instead.
+func (c *Contractor) managedResyncContract(contract modules.RenterContract, err error) (modules.RenterContract, bool) {
+	hostRevisionNumber, ahead := proto.HostAhead(err)
+	if !ahead {
+		return contract, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current, exists := c.contracts[contract.ID]
+	if !exists {
+		return contract, false
+	}
+	resynced, resyncErr := proto.ResyncContract(current, err)
+	if resyncErr != nil {
+		if !current.Stale || current.HostRevisionNumber != hostRevisionNumber {
+			c.log.Printf("WARN: host %v is at revision %v of contract %v, but the renter is at revision %v and could not resynchronize: %v\n",
+				current.NetAddress, hostRevisionNumber, current.ID, current.LastRevision.NewRevisionNumber, resyncErr)
+		}
+		current.Stale = true
+		current.HostRevisionNumber = hostRevisionNumber
+		current.GoodForUpload = false
+		c.contracts[current.ID] = current
+		c.updateStaleContractsAlert()
+		return contract, false
+	}
+
+	// The data of the contract has not changed, so the adopted revision is
+	// saved like a download revision.
+	c.log.Printf("Resynchronized contract %v with host %v: adopted revision %v, the renter was at revision %v\n",
+		current.ID, current.NetAddress, hostRevisionNumber, current.LastRevision.NewRevisionNumber)
+	resynced.Stale = false
+	resynced.HostRevisionNumber = 0
+	c.contracts[current.ID] = resynced
+	cr := c.cachedRevisions[current.ID]
+	cr.Revision = resynced.LastRevision
+	c.cachedRevisions[current.ID] = cr
+	c.updateStaleContractsAlert()
+	err = c.persist.update(updateDownloadRevision{
+		NewRevisionTxn:            resynced.LastRevisionTxn,
+		NewDownloadSpending:       resynced.DownloadSpending,
+		NewRepairDownloadSpending: resynced.RepairDownloadSpending,
+	}, updateCachedDownloadRevision{
+		Revision: resynced.LastRevision,
+	})
+	if err != nil {
+		c.log.Println("Unable to save the contractor after resynchronizing a contract:", err)
+	}
+	return resynced, true
+}
+
+// updateStaleContractsAlert raises the stale contracts alert while any of the
+// active contracts is stale, and clears it otherwise.
+func (c *Contractor) updateStaleContractsAlert() {
+	var stale int
+	for _, contract := range c.contracts {
+		if contract.Stale {
+			stale++
+		}
+	}
+	old, raised := c.alerts[modules.RenterAlertCategoryStaleContracts]
+	if stale == 0 {
+		delete(c.alerts, modules.RenterAlertCategoryStaleContracts)
+		return
+	}
+	alert := modules.RenterAlert{
+		Severity: modules.RenterAlertSeverityWarning,
+		Category: modules.RenterAlertCategoryStaleContracts,
+		Message:  fmt.Sprintf("%v contracts are behind their hosts and could not be resynchronized, they are not used for uploads", stale),
+		Height:   c.blockHeight,
+	}
+	if raised {
+		if old.Message == alert.Message {
+			return
+		}
+		alert.Height = old.Height
+	}
+	c.alerts[alert.Category] = alert
+	c.log.Printf("ALERT: %v %v: %v\n", alert.Severity, alert.Category, alert.Message)
+}
@@ -0,0 +1,83 @@
+package contractor
+
+// walletlock.go suspends contract formation and renewal while the wallet is
+// locked. Both need the wallet to fund and sign transactions, so instead of
+// failing, and logging, once for every contract, contract maintenance is
+// skipped and a single alert is raised. Revisions and downloads do not need
+// the wallet and keep working. Once the wallet is unlocked the alert is
+// cleared and contract maintenance runs immediately, which renews any
+// contracts whose renewal was missed while the wallet was locked, as long as
+// they have not expired.
+
+import (
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// Alerts returns the alerts that are currently active on the contractor,
+// sorted by category.
+func (c *Contractor) Alerts() []modules.RenterAlert {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	alerts := make([]modules.RenterAlert, 0, len(c.alerts))
+	for _, alert := range c.alerts {
+		alerts = append(alerts, alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].Category < alerts[j].Category
+	})
+	return alerts
+}
+
+// managedCheckWallet reports whether the wallet is unlocked, raising the
+// wallet locked alert if it is not. The alert is only raised while the
+// contractor has an allowance, since there is nothing to suspend otherwise.
+// resumed is true if the wallet was unlocked since the alert was raised.
+func (c *Contractor) managedCheckWallet() (unlocked bool, resumed bool) {
+	unlocked = c.wallet.Unlocked()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, suspended := c.alerts[modules.RenterAlertCategoryWalletLocked]
+	if unlocked || c.allowance.Hosts == 0 {
+		if suspended {
+			delete(c.alerts, modules.RenterAlertCategoryWalletLocked)
+		}
+		if suspended && unlocked {
+			c.log.Println("INFO: wallet has been unlocked, resuming contract formation and renewal")
+		}
+		return unlocked, suspended && unlocked
+	}
+	if !suspended {
+		alert := modules.RenterAlert{
+			Severity: modules.RenterAlertSeverityWarning,
+			Category: modules.RenterAlertCategoryWalletLocked,
+			Message:  "wallet is locked, contracts will not be formed or renewed until it is unlocked",
+			Height:   c.blockHeight,
+		}
+		c.alerts[alert.Category] = alert
+		c.log.Printf("ALERT: %v %v: %v\n", alert.Severity, alert.Category, alert.Message)
+	}
+	return false, false
+}
+
+// threadedMonitorWallet periodically checks whether the wallet has been
+// locked or unlocked, and starts contract maintenance as soon as a locked
+// wallet is unlocked again.
+func (c *Contractor) threadedMonitorWallet() {
+	if err := c.tg.Add(); err != nil {
+		return
+	}
+	defer c.tg.Done()
+	for {
+		select {
+		case <-c.tg.StopChan():
+			return
+		case <-time.After(walletLockCheckInterval):
+		}
+		if _, resumed := c.managedCheckWallet(); resumed && c.cs.Synced() {
+			go c.threadedContractMaintenance()
+		}
+	}
+}
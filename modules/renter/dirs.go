@@ -0,0 +1,367 @@
+package renter
+
+// dirs.go implements the directories of the renter. A '/' in a siapath
+// separates directories, and a directory exists while it contains a file or
+// was created with CreateDir. Created directories are persisted, so empty
+// directories are kept until they are deleted.
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+var (
+	// errDirExists is returned if a directory is created or renamed to a
+	// path that already is a directory or a file.
+	errDirExists = errors.New("a directory or file already exists at that location")
+
+	// errDirNotEmpty is returned if a directory that contains files or
+	// directories is deleted without the recursive flag.
+	errDirNotEmpty = errors.New("directory is not empty")
+
+	// errRenameDirIntoItself is returned if a directory is renamed to a path
+	// inside of itself.
+	errRenameDirIntoItself = errors.New("cannot move a directory into itself")
+
+	// errRootDir is returned if the root directory is deleted or renamed.
+	errRootDir = errors.New("cannot delete or rename the root directory")
+
+	// errUnknownDir is returned if a directory does not exist.
+	errUnknownDir = errors.New("no directory known with that path")
+)
+
+// validateDirPath checks that siaPath is a valid directory path. The empty
+// path is the root directory.
+func validateDirPath(siaPath string) error {
+	if siaPath == "" {
+		return nil
+	}
+	if strings.HasSuffix(siaPath, "/") || strings.Contains(siaPath, "//") {
+		return errors.New("directory paths cannot end with / or contain empty directory names")
+	}
+	return validateSiapath(siaPath)
+}
+
+// dirPrefix returns the prefix of the siapaths in the directory siaPath.
+func dirPrefix(siaPath string) string {
+	if siaPath == "" {
+		return ""
+	}
+	return siaPath + "/"
+}
+
+// parentDirs returns the directories that contain siaPath, excluding the
+// root directory.
+func parentDirs(siaPath string) []string {
+	var dirs []string
+	for i := 1; i < len(siaPath); i++ {
+		if siaPath[i] == '/' && siaPath[i-1] != '/' {
+			dirs = append(dirs, siaPath[:i])
+		}
+	}
+	return dirs
+}
+
+// dirExists reports whether siaPath is a directory. The caller must hold the
+// renter lock.
+func (r *Renter) dirExists(siaPath string) bool {
+	if _, exists := r.dirs[siaPath]; exists || siaPath == "" {
+		return true
+	}
+	prefix := dirPrefix(siaPath)
+	for name := range r.files {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	for dir := range r.dirs {
+		if strings.HasPrefix(dir, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFilePath checks that a file can be added at siaPath without colliding
+// with a directory. siaPath must not be a directory, whether it was created
+// or is implied by the files in it, and none of the directories that would
+// contain the file may be a file. The caller must hold the renter lock.
+func (r *Renter) checkFilePath(siaPath string) error {
+	if r.dirExists(siaPath) {
+		return errDirExists
+	}
+	for _, dir := range parentDirs(siaPath) {
+		if _, exists := r.files[dir]; exists {
+			return ErrPathOverload
+		}
+	}
+	return nil
+}
+
+// deletedFilesInDir returns the siapaths of the deleted files in the directory
+// siaPath and its subdirectories that still have previous versions, sorted.
+// The caller must hold the renter lock.
+func (r *Renter) deletedFilesInDir(siaPath string) []string {
+	prefix := dirPrefix(siaPath)
+	var names []string
+	for name := range r.versions {
+		if _, exists := r.files[name]; !exists && strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// filesInDir returns the siapaths of the files in the directory siaPath and
+// its subdirectories, sorted. The caller must hold the renter lock.
+func (r *Renter) filesInDir(siaPath string) []string {
+	prefix := dirPrefix(siaPath)
+	var names []string
+	for name := range r.files {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CreateDir creates the directory siaPath and the directories that contain
+// it.
+func (r *Renter) CreateDir(siaPath string) error {
+	if siaPath == "" {
+		return errDirExists
+	}
+	if err := validateDirPath(siaPath); err != nil {
+		return err
+	}
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	if r.dirExists(siaPath) {
+		return errDirExists
+	}
+	dirs := append(parentDirs(siaPath), siaPath)
+	for _, dir := range dirs {
+		if _, exists := r.files[dir]; exists {
+			return ErrPathOverload
+		}
+	}
+	for _, dir := range dirs {
+		r.dirs[dir] = struct{}{}
+	}
+	return r.saveSync()
+}
+
+// DeleteDir deletes the directory siaPath. A directory that contains files or
+// directories is only deleted if recursive is set, in which case all of its
+// files and the previous versions of its deleted files are deleted as well.
+func (r *Renter) DeleteDir(siaPath string, recursive bool) error {
+	if siaPath == "" {
+		return errRootDir
+	}
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	if !r.dirExists(siaPath) {
+		return errUnknownDir
+	}
+	prefix := dirPrefix(siaPath)
+	names := r.filesInDir(siaPath)
+	var subdirs []string
+	for dir := range r.dirs {
+		if strings.HasPrefix(dir, prefix) {
+			subdirs = append(subdirs, dir)
+		}
+	}
+	if !recursive && (len(names) > 0 || len(subdirs) > 0) {
+		return errDirNotEmpty
+	}
+
+	for _, name := range names {
+		r.deleteFile(name, r.files[name])
+	}
+	for _, name := range r.deletedFilesInDir(siaPath) {
+		r.purgeVersions(name)
+	}
+	for _, dir := range subdirs {
+		delete(r.dirs, dir)
+		delete(r.versionRetention, dir)
+	}
+	delete(r.dirs, siaPath)
+	delete(r.versionRetention, siaPath)
+	return r.saveSync()
+}
+
+// RenameDir moves the directory currentPath, its files, and its directories
+// to newPath. The files are renamed while the renter is locked, so the
+// directory is never seen partially renamed. If a file cannot be renamed, the
+// files that were renamed already are moved back.
+func (r *Renter) RenameDir(currentPath, newPath string) error {
+	if currentPath == "" || newPath == "" {
+		return errRootDir
+	}
+	if err := validateDirPath(newPath); err != nil {
+		return err
+	}
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	if !r.dirExists(currentPath) {
+		return errUnknownDir
+	}
+	if _, exists := r.files[newPath]; exists || r.dirExists(newPath) {
+		return errDirExists
+	}
+	for _, dir := range parentDirs(newPath) {
+		if _, exists := r.files[dir]; exists {
+			return ErrPathOverload
+		}
+	}
+	if strings.HasPrefix(newPath, dirPrefix(currentPath)) {
+		return errRenameDirIntoItself
+	}
+
+	// Check all of the files before renaming any of them.
+	names := r.filesInDir(currentPath)
+	newName := func(name string) string {
+		return newPath + strings.TrimPrefix(name, currentPath)
+	}
+	for _, name := range names {
+		if err := r.checkRename(name, newName(name)); err != nil {
+			return err
+		}
+	}
+	deleted := r.deletedFilesInDir(currentPath)
+	for _, name := range deleted {
+		if _, exists := r.versions[newName(name)]; exists {
+			return ErrPathOverload
+		}
+	}
+	for i, name := range names {
+		if err := r.renameFile(name, newName(name)); err != nil {
+			for _, renamed := range names[:i] {
+				if err := r.renameFile(newName(renamed), renamed); err != nil {
+					r.log.Println("WARN: could not move a file back after a failed directory rename:", err)
+					continue
+				}
+				r.removeRenamedFile(newName(renamed))
+			}
+			return err
+		}
+	}
+
+	// The previous versions of deleted files are moved after the files, and
+	// versions that cannot be moved are left behind.
+	for _, name := range deleted {
+		if err := r.renameVersions(name, newName(name)); err != nil {
+			r.log.Println("WARN: could not move the previous versions of a deleted file:", err)
+		}
+	}
+
+	prefix := dirPrefix(currentPath)
+	for dir := range r.dirs {
+		if dir == currentPath || strings.HasPrefix(dir, prefix) {
+			delete(r.dirs, dir)
+			r.dirs[newPath+strings.TrimPrefix(dir, currentPath)] = struct{}{}
+		}
+	}
+	r.dirs[newPath] = struct{}{}
+	for dir, vr := range r.versionRetention {
+		if dir == currentPath || strings.HasPrefix(dir, prefix) {
+			delete(r.versionRetention, dir)
+			r.versionRetention[newPath+strings.TrimPrefix(dir, currentPath)] = vr
+		}
+	}
+	if err := r.saveSync(); err != nil {
+		return err
+	}
+	for _, name := range names {
+		r.removeRenamedFile(name)
+	}
+	return nil
+}
+
+// DirList returns the directories and files that are directly inside of the
+// directory siaPath, sorted by their siapaths. The size, file count, and
+// minimum redundancy of each directory include all of the files below it.
+func (r *Renter) DirList(siaPath string) ([]modules.DirectoryInfo, []modules.FileInfo, error) {
+	if err := validateDirPath(siaPath); err != nil {
+		return nil, nil, err
+	}
+	prefix := dirPrefix(siaPath)
+	lockID := r.mu.RLock()
+	if !r.dirExists(siaPath) {
+		r.mu.RUnlock(lockID)
+		return nil, nil, errUnknownDir
+	}
+	dirs := make(map[string]*modules.DirectoryInfo)
+	for dir := range r.dirs {
+		if !strings.HasPrefix(dir, prefix) {
+			continue
+		}
+		rel := strings.TrimPrefix(dir, prefix)
+		if i := strings.Index(rel, "/"); i >= 0 {
+			rel = rel[:i]
+		}
+		dirs[prefix+rel] = &modules.DirectoryInfo{SiaPath: prefix + rel}
+	}
+	r.mu.RUnlock(lockID)
+
+	var files []modules.FileInfo
+	for _, fi := range r.managedFileList(prefix) {
+		rel := strings.TrimPrefix(fi.SiaPath, prefix)
+		i := strings.Index(rel, "/")
+		if i < 0 {
+			files = append(files, fi)
+			continue
+		}
+		dir := prefix + rel[:i]
+		di, exists := dirs[dir]
+		if !exists {
+			di = &modules.DirectoryInfo{SiaPath: dir}
+			dirs[dir] = di
+		}
+		if di.NumFiles == 0 || fi.Redundancy < di.MinRedundancy {
+			di.MinRedundancy = fi.Redundancy
+		}
+		di.NumFiles++
+		di.Filesize += fi.Filesize
+	}
+
+	dirInfos := make([]modules.DirectoryInfo, 0, len(dirs))
+	for _, di := range dirs {
+		dirInfos = append(dirInfos, *di)
+	}
+	sort.Slice(dirInfos, func(i, j int) bool {
+		return dirInfos[i].SiaPath < dirInfos[j].SiaPath
+	})
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].SiaPath < files[j].SiaPath
+	})
+	return dirInfos, files, nil
+}
+
+// dirNames returns the directories created with CreateDir, sorted. The caller
+// must hold the renter lock.
+func (r *Renter) dirNames() []string {
+	names := make([]string, 0, len(r.dirs))
+	for dir := range r.dirs {
+		names = append(names, dir)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// migrateDirs creates a directory for every directory that contains a file.
+// It is used to carry the directories of a renter that was saved with a flat
+// file list over to the directory hierarchy, so that they are kept when their
+// files are deleted. The caller must hold the renter lock.
+func (r *Renter) migrateDirs() {
+	for name := range r.files {
+		for _, dir := range parentDirs(name) {
+			r.dirs[dir] = struct{}{}
+		}
+	}
+}
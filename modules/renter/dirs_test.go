@@ -0,0 +1,292 @@
+package renter
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+)
+
+// addTestingFiles adds a testing file to the renter for each siapath.
+func addTestingFiles(r *Renter, siaPaths ...string) {
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	for _, siaPath := range siaPaths {
+		f := newTestingFile()
+		f.name = siaPath
+		r.files[siaPath] = f
+	}
+}
+
+// TestRenterDirs probes CreateDir, DeleteDir, and DirList.
+func TestRenterDirs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	addTestingFiles(rt.renter, "a", "foo/b", "foo/bar/c", "foo/bar/d")
+	if err := rt.renter.CreateDir("empty/inner"); err != nil {
+		t.Fatal(err)
+	}
+	for _, siaPath := range []string{"foo", "foo/bar", "empty", "empty/inner"} {
+		if err := rt.renter.CreateDir(siaPath); err != errDirExists {
+			t.Errorf("expected errDirExists creating %v, got %v", siaPath, err)
+		}
+	}
+	if err := rt.renter.CreateDir("a/x"); err != ErrPathOverload {
+		t.Error("expected ErrPathOverload creating a directory inside of a file, got", err)
+	}
+	if err := rt.renter.CreateDir("x/"); err == nil {
+		t.Error("expected a directory path with a trailing slash to be rejected")
+	}
+
+	// The root directory lists its files and rolls up its subdirectories.
+	dirs, files, err := rt.renter.DirList("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].SiaPath != "a" {
+		t.Fatal("wrong files in the root directory:", files)
+	}
+	if len(dirs) != 2 || dirs[0].SiaPath != "empty" || dirs[1].SiaPath != "foo" {
+		t.Fatal("wrong directories in the root directory:", dirs)
+	}
+	if dirs[0].NumFiles != 0 || dirs[0].Filesize != 0 {
+		t.Fatalf("wrong rollup of an empty directory: %+v", dirs[0])
+	}
+	var size uint64
+	minRedundancy := -1.0
+	for _, fi := range rt.renter.FileList() {
+		if fi.SiaPath != "a" {
+			size += fi.Filesize
+			if minRedundancy < 0 || fi.Redundancy < minRedundancy {
+				minRedundancy = fi.Redundancy
+			}
+		}
+	}
+	if foo := dirs[1]; foo.NumFiles != 3 || foo.Filesize != size || foo.MinRedundancy != minRedundancy {
+		t.Fatalf("wrong rollup of foo: %+v", foo)
+	}
+	dirs, files, err = rt.renter.DirList("foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 0 || len(files) != 2 || files[0].SiaPath != "foo/bar/c" || files[1].SiaPath != "foo/bar/d" {
+		t.Fatal("wrong listing of foo/bar:", dirs, files)
+	}
+	if _, _, err := rt.renter.DirList("nope"); err != errUnknownDir {
+		t.Fatal("expected errUnknownDir, got", err)
+	}
+
+	// Only empty directories are deleted without the recursive flag.
+	if err := rt.renter.DeleteDir("foo", false); err != errDirNotEmpty {
+		t.Fatal("expected errDirNotEmpty, got", err)
+	}
+	if err := rt.renter.DeleteDir("empty", false); err != errDirNotEmpty {
+		t.Fatal("expected errDirNotEmpty for a directory with a subdirectory, got", err)
+	}
+	if err := rt.renter.DeleteDir("empty/inner", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.DeleteDir("foo", true); err != nil {
+		t.Fatal(err)
+	}
+	if files := rt.renter.FileList(); len(files) != 1 || files[0].SiaPath != "a" {
+		t.Fatal("recursive delete left the wrong files:", files)
+	}
+	dirs, _, err = rt.renter.DirList("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 1 || dirs[0].SiaPath != "empty" {
+		t.Fatal("wrong directories after deleting:", dirs)
+	}
+	if err := rt.renter.DeleteDir("", true); err != errRootDir {
+		t.Fatal("expected errRootDir, got", err)
+	}
+}
+
+// TestRenterFileDirCollisions checks that files cannot be uploaded or renamed
+// to the path of a directory, or to a path inside of a file.
+func TestRenterFileDirCollisions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	addTestingFiles(rt.renter, "a", "foo/b")
+	if err := rt.renter.CreateDir("empty"); err != nil {
+		t.Fatal(err)
+	}
+	dir := build.TempDir("renter", t.Name(), "source")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(source, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ec, _ := NewRSCode(1, 1)
+
+	// "foo" is implied by the file in it, "empty" was created, and "a/x"
+	// would be inside of a file.
+	collisions := map[string]error{
+		"foo":   errDirExists,
+		"empty": errDirExists,
+		"a/x":   ErrPathOverload,
+	}
+	for siaPath, expected := range collisions {
+		err := rt.renter.Upload(modules.FileUploadParams{Source: source, SiaPath: siaPath, ErasureCode: ec})
+		if err != expected {
+			t.Errorf("expected %v uploading to %v, got %v", expected, siaPath, err)
+		}
+		err = rt.renter.UploadReader(siaPath, bytes.NewReader([]byte("data")), ec)
+		if err != expected {
+			t.Errorf("expected %v streaming to %v, got %v", expected, siaPath, err)
+		}
+		err = rt.renter.UploadFromURL(context.Background(), "http://127.0.0.1:1/data", siaPath, modules.FileUploadParams{ErasureCode: ec})
+		if err != expected {
+			t.Errorf("expected %v uploading a URL to %v, got %v", expected, siaPath, err)
+		}
+		if siaPath != "a/x" {
+			if err := rt.renter.RenameFile("a", siaPath); err != expected {
+				t.Errorf("expected %v renaming to %v, got %v", expected, siaPath, err)
+			}
+		}
+	}
+	if err := rt.renter.RenameFile("foo/b", "a/b"); err != ErrPathOverload {
+		t.Error("expected ErrPathOverload renaming a file into a file, got", err)
+	}
+
+	// None of the attempts should have added a file.
+	if files := rt.renter.FileList(); len(files) != 2 {
+		t.Fatal("expected two files, got", len(files))
+	}
+}
+
+// TestRenterRenameDir checks that renaming a directory renames all of its
+// files and directories.
+func TestRenterRenameDir(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	addTestingFiles(rt.renter, "foo/a", "foo/bar/b", "foobar/c", "other/d")
+	if err := rt.renter.CreateDir("foo/empty"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.RenameDir("foo", "other"); err != errDirExists {
+		t.Fatal("expected errDirExists, got", err)
+	}
+	if err := rt.renter.RenameDir("foo", "foo/bar/baz"); err != errRenameDirIntoItself {
+		t.Fatal("expected errRenameDirIntoItself, got", err)
+	}
+	if err := rt.renter.RenameDir("foo", "new/foo"); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, fi := range rt.renter.FileList() {
+		names = append(names, fi.SiaPath)
+	}
+	expected := map[string]bool{"new/foo/a": true, "new/foo/bar/b": true, "foobar/c": true, "other/d": true}
+	if len(names) != len(expected) {
+		t.Fatal("wrong files after renaming a directory:", names)
+	}
+	for _, name := range names {
+		if !expected[name] {
+			t.Fatal("wrong files after renaming a directory:", names)
+		}
+		if !strings.HasPrefix(name, "new/") {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(rt.renter.persistDir, name+ShareExtension)); err != nil {
+			t.Fatal("renamed file was not saved:", err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(rt.renter.persistDir, "foo", "a"+ShareExtension)); !os.IsNotExist(err) {
+		t.Fatal("file was not removed from its previous location:", err)
+	}
+	dirs, _, err := rt.renter.DirList("new/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 2 || dirs[0].SiaPath != "new/foo/bar" || dirs[1].SiaPath != "new/foo/empty" {
+		t.Fatal("wrong directories after renaming a directory:", dirs)
+	}
+	if _, _, err := rt.renter.DirList("foo"); err != errUnknownDir {
+		t.Fatal("expected the previous directory to be gone, got", err)
+	}
+}
+
+// TestRenterDirsMigration checks that a renter that was saved with a flat
+// file list keeps the directories of its files.
+func TestRenterDirsMigration(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	addTestingFiles(rt.renter, "foo/bar/a", "b")
+	id := rt.renter.mu.Lock()
+	for _, f := range rt.renter.files {
+		if err := rt.renter.saveFile(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rt.renter.files = make(map[string]*file)
+	rt.renter.mu.Unlock(id)
+	flat := struct {
+		Tracking          map[string]trackedFile
+		UploadRetryPolicy modules.UploadRetryPolicy
+	}{}
+	if err := persist.SaveJSON(saveMetadata, flat, filepath.Join(rt.renter.persistDir, PersistFilename)); err != nil {
+		t.Fatal(err)
+	}
+
+	id = rt.renter.mu.Lock()
+	err = rt.renter.load()
+	rt.renter.mu.Unlock(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.DeleteFile("foo/bar/a"); err != nil {
+		t.Fatal(err)
+	}
+	dirs, _, err := rt.renter.DirList("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 1 || dirs[0].SiaPath != "foo/bar" {
+		t.Fatal("directories of a flat file list were not migrated:", dirs)
+	}
+}
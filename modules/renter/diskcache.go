@@ -0,0 +1,272 @@
+package renter
+
+// diskcache.go implements a disk cache of recently downloaded chunks. After a
+// chunk has been recovered, it is encrypted and written to the cache
+// directory, so that later downloads of the chunk are served from disk
+// instead of fetching pieces from hosts. When the cache exceeds its maximum
+// size, the least recently used chunks are evicted. Chunks that were cached
+// longer than cacheEntryLifetime ago are stale, and are not served.
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+const (
+	// cacheDir is the name of the directory of the disk cache within the
+	// renter directory.
+	cacheDir = "cache"
+)
+
+type (
+	// A cacheEntry is a chunk that is stored in the disk cache.
+	cacheEntry struct {
+		size       uint64
+		created    time.Time
+		lastAccess time.Time
+	}
+
+	// A DiskCache stores recently downloaded chunks on disk. Chunks are
+	// encrypted with a key derived from the master key of their file, and are
+	// named by the hash of that key, so the cache does not reveal which files
+	// were downloaded.
+	DiskCache struct {
+		entries map[string]*cacheEntry
+		size    uint64
+		maxSize uint64
+
+		// Statistics since the renter started.
+		hits      uint64
+		misses    uint64
+		evictions uint64
+
+		dir string
+		mu  sync.Mutex
+	}
+)
+
+// newDiskCache returns a disk cache that stores chunks in dir. The chunks
+// that are already in dir are only added to the cache by load.
+func newDiskCache(dir string, maxSize uint64) *DiskCache {
+	return &DiskCache{
+		entries: make(map[string]*cacheEntry),
+		maxSize: maxSize,
+		dir:     dir,
+	}
+}
+
+// cacheChunkKey returns the key that the chunk at chunkIndex of the file with
+// the provided master key is encrypted with in the cache.
+func cacheChunkKey(masterKey crypto.TwofishKey, chunkIndex uint64) crypto.TwofishKey {
+	return crypto.TwofishKey(crypto.HashAll(masterKey, chunkIndex, cacheDir))
+}
+
+// cacheEntryName returns the name of the cache entry of a chunk that is
+// encrypted with key.
+func cacheEntryName(key crypto.TwofishKey) string {
+	return crypto.HashObject(key).String()
+}
+
+// load creates the cache directory, and adds the chunks that are stored in it
+// to the cache. Stale chunks are removed.
+func (dc *DiskCache) load() error {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	err := os.MkdirAll(dc.dir, 0700)
+	if err != nil {
+		return err
+	}
+	infos, err := ioutil.ReadDir(dc.dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		if time.Since(info.ModTime()) > cacheEntryLifetime {
+			os.Remove(filepath.Join(dc.dir, info.Name()))
+			continue
+		}
+		dc.entries[info.Name()] = &cacheEntry{
+			size:       uint64(info.Size()),
+			created:    info.ModTime(),
+			lastAccess: info.ModTime(),
+		}
+		dc.size += uint64(info.Size())
+	}
+	dc.evict(dc.maxSize)
+	return nil
+}
+
+// remove deletes the entry with the provided name from the cache. The caller
+// must hold the lock.
+func (dc *DiskCache) remove(name string) {
+	entry, exists := dc.entries[name]
+	if !exists {
+		return
+	}
+	os.Remove(filepath.Join(dc.dir, name))
+	delete(dc.entries, name)
+	dc.size -= entry.size
+}
+
+// evict removes the least recently used entries from the cache until the
+// cache is no larger than size. The caller must hold the lock.
+func (dc *DiskCache) evict(size uint64) {
+	for dc.size > size {
+		var oldest string
+		for name, entry := range dc.entries {
+			if oldest == "" || entry.lastAccess.Before(dc.entries[oldest].lastAccess) {
+				oldest = name
+			}
+		}
+		dc.remove(oldest)
+		dc.evictions++
+	}
+}
+
+// managedAdd adds the chunk at chunkIndex of the file with the provided master
+// key to the cache. Chunks that are larger than the cache are not added.
+func (dc *DiskCache) managedAdd(masterKey crypto.TwofishKey, chunkIndex uint64, data []byte) error {
+	key := cacheChunkKey(masterKey, chunkIndex)
+	ciphertext := key.EncryptBytes(data)
+	size := uint64(len(ciphertext))
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if size > dc.maxSize {
+		return nil
+	}
+	name := cacheEntryName(key)
+	dc.remove(name)
+	dc.evict(dc.maxSize - size)
+	err := ioutil.WriteFile(filepath.Join(dc.dir, name), ciphertext, 0600)
+	if err != nil {
+		os.Remove(filepath.Join(dc.dir, name))
+		return err
+	}
+	now := time.Now()
+	dc.entries[name] = &cacheEntry{
+		size:       size,
+		created:    now,
+		lastAccess: now,
+	}
+	dc.size += size
+	return nil
+}
+
+// managedGet returns the chunk at chunkIndex of the file with the provided
+// master key, if the cache contains a fresh copy of it.
+func (dc *DiskCache) managedGet(masterKey crypto.TwofishKey, chunkIndex uint64) ([]byte, bool) {
+	key := cacheChunkKey(masterKey, chunkIndex)
+	name := cacheEntryName(key)
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if dc.maxSize == 0 {
+		return nil, false
+	}
+	entry, exists := dc.entries[name]
+	if exists && time.Since(entry.created) > cacheEntryLifetime {
+		dc.remove(name)
+		exists = false
+	}
+	if !exists {
+		dc.misses++
+		return nil, false
+	}
+
+	// Entries that cannot be read or decrypted are removed, so that the chunk
+	// is downloaded from the hosts again.
+	ciphertext, err := ioutil.ReadFile(filepath.Join(dc.dir, name))
+	if err != nil {
+		dc.remove(name)
+		dc.misses++
+		return nil, false
+	}
+	data, err := key.DecryptBytes(ciphertext)
+	if err != nil {
+		dc.remove(name)
+		dc.misses++
+		return nil, false
+	}
+	entry.lastAccess = time.Now()
+	dc.hits++
+	return data, true
+}
+
+// managedRemove removes the chunk at chunkIndex of the file with the provided
+// master key from the cache.
+func (dc *DiskCache) managedRemove(masterKey crypto.TwofishKey, chunkIndex uint64) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.remove(cacheEntryName(cacheChunkKey(masterKey, chunkIndex)))
+}
+
+// managedRemoveFile removes the chunks of the file with the provided master
+// key from the cache.
+func (dc *DiskCache) managedRemoveFile(masterKey crypto.TwofishKey, numChunks uint64) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	for i := uint64(0); i < numChunks && len(dc.entries) > 0; i++ {
+		dc.remove(cacheEntryName(cacheChunkKey(masterKey, i)))
+	}
+}
+
+// managedMaxSize returns the maximum size of the cache.
+func (dc *DiskCache) managedMaxSize() uint64 {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return dc.maxSize
+}
+
+// managedSetMaxSize sets the maximum size of the cache, evicting entries if
+// the cache is larger than the new size.
+func (dc *DiskCache) managedSetMaxSize(size uint64) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.maxSize = size
+	dc.evict(size)
+}
+
+// managedStats returns the statistics of the cache.
+func (dc *DiskCache) managedStats() modules.CacheStats {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return modules.CacheStats{
+		MaxSize:   dc.maxSize,
+		Size:      dc.size,
+		Entries:   uint64(len(dc.entries)),
+		Hits:      dc.hits,
+		Misses:    dc.misses,
+		Evictions: dc.evictions,
+	}
+}
+
+// CacheStats returns statistics about the disk cache of downloaded chunks.
+func (r *Renter) CacheStats() modules.CacheStats {
+	return r.cache.managedStats()
+}
+
+// SetCacheSize sets the maximum size of the disk cache of downloaded chunks,
+// evicting chunks if the cache is larger than the new size. A size of 0
+// disables the cache.
+func (r *Renter) SetCacheSize(size uint64) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	r.cache.managedSetMaxSize(size)
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	return r.saveSync()
+}
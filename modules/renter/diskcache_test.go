@@ -0,0 +1,200 @@
+package renter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestDiskCache checks that the disk cache serves the chunks that were added
+// to it, evicts the least recently used chunks, and discards chunks that are
+// stale or corrupted.
+func TestDiskCache(t *testing.T) {
+	dir := build.TempDir(modules.RenterDir, t.Name())
+	key := crypto.GenerateTwofishKey()
+	chunks := [][]byte{fastrand.Bytes(100), fastrand.Bytes(100), fastrand.Bytes(100)}
+
+	// Size the cache for two chunks.
+	entrySize := uint64(len(cacheChunkKey(key, 0).EncryptBytes(chunks[0])))
+	dc := newDiskCache(dir, 2*entrySize)
+	if err := dc.load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dc.managedGet(key, 0); ok {
+		t.Fatal("empty cache returned a chunk")
+	}
+	for i := range chunks[:2] {
+		if err := dc.managedAdd(key, uint64(i), chunks[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data, ok := dc.managedGet(key, 0); !ok || !bytes.Equal(data, chunks[0]) {
+		t.Fatal("cache did not return the added chunk")
+	}
+	if _, ok := dc.managedGet(crypto.GenerateTwofishKey(), 0); ok {
+		t.Fatal("cache returned a chunk of another file")
+	}
+
+	// Adding a third chunk should evict chunk 1, which was used least
+	// recently.
+	if err := dc.managedAdd(key, 2, chunks[2]); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dc.managedGet(key, 1); ok {
+		t.Fatal("least recently used chunk was not evicted")
+	}
+	if _, ok := dc.managedGet(key, 0); !ok {
+		t.Fatal("recently used chunk was evicted")
+	}
+	stats := dc.managedStats()
+	if stats.Entries != 2 || stats.Size != 2*entrySize || stats.Evictions != 1 || stats.Hits != 2 || stats.Misses != 3 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// The chunks should still be cached after a restart.
+	dc = newDiskCache(dir, 2*entrySize)
+	if err := dc.load(); err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := dc.managedGet(key, 2); !ok || !bytes.Equal(data, chunks[2]) {
+		t.Fatal("chunk was not cached after a restart")
+	}
+
+	// Corrupted and stale chunks should not be served, and should be removed.
+	name := cacheEntryName(cacheChunkKey(key, 2))
+	if err := ioutil.WriteFile(filepath.Join(dir, name), fastrand.Bytes(int(entrySize)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dc.managedGet(key, 2); ok {
+		t.Fatal("corrupted chunk was served")
+	}
+	if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+		t.Fatal("corrupted chunk was not removed")
+	}
+	dc.entries[cacheEntryName(cacheChunkKey(key, 0))].created = time.Now().Add(-cacheEntryLifetime - time.Second)
+	if _, ok := dc.managedGet(key, 0); ok {
+		t.Fatal("stale chunk was served")
+	}
+	if stats := dc.managedStats(); stats.Entries != 0 || stats.Size != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// Shrinking the cache should evict chunks, and a cache of size 0 should
+	// not store chunks.
+	for i := range chunks {
+		if err := dc.managedAdd(key, uint64(i), chunks[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dc.managedSetMaxSize(entrySize)
+	if stats := dc.managedStats(); stats.Entries != 1 || stats.Size != entrySize {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	dc.managedSetMaxSize(0)
+	if err := dc.managedAdd(key, 0, chunks[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dc.managedGet(key, 0); ok {
+		t.Fatal("disabled cache returned a chunk")
+	}
+}
+
+// TestRenterCacheSize checks that the size of the renter's disk cache
+// survives a restart.
+func TestRenterCacheSize(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	if size := rt.renter.CacheStats().MaxSize; size != defaultCacheSize {
+		t.Fatal("expected the default cache size, got", size)
+	}
+	if err := rt.renter.SetCacheSize(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, rt.renter.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := rt.renter.CacheStats().MaxSize; size != 1<<20 {
+		t.Fatal("cache size was not restored, got", size)
+	}
+}
+
+// TestDownloadCorruptedCacheEntry checks that a cached chunk that does not
+// match the checksum of its file is evicted instead of failing the download,
+// and that the download falls back to the hosts.
+func TestDownloadCorruptedCacheEntry(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Create a file of a single chunk, without any hosts.
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, pieceSize, pieceSize)
+	data := fastrand.Bytes(int(f.chunkSize()))
+	f.size = uint64(len(data))
+	source := filepath.Join(rt.renter.persistDir, "source")
+	if err := ioutil.WriteFile(source, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	f.checksum, err = computeFileChecksum(source, modules.ChecksumAlgorithmBlake2b, f.chunkSize(), f.size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.files[f.name] = f
+	rt.renter.mu.Unlock(id)
+
+	// A valid cached chunk is served from the cache.
+	if err := rt.renter.SetCacheSize(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.cache.managedAdd(f.masterKey, 0, data); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := rt.renter.DownloadSection(f.name, 0, f.size, &buf); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("cached chunk was not served")
+	}
+
+	// A corrupted chunk is evicted. As the file has no hosts, the download
+	// from the hosts fails.
+	if err := rt.renter.cache.managedAdd(f.masterKey, 0, fastrand.Bytes(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	err = rt.renter.DownloadSection(f.name, 0, f.size, &buf)
+	if err == nil || strings.Contains(err.Error(), errChecksumMismatch.Error()) {
+		t.Fatal("expected the download from the hosts to fail, got", err)
+	}
+	if _, ok := rt.renter.cache.managedGet(f.masterKey, 0); ok {
+		t.Fatal("corrupted chunk was not evicted")
+	}
+}
@@ -7,7 +7,6 @@ package renter
 import (
 	"bytes"
 	"errors"
-	"io"
 	"os"
 	"sync"
 	"sync/atomic"
@@ -25,9 +24,10 @@ const (
 )
 
 var (
-	errInsufficientHosts  = errors.New("insufficient hosts to recover file")
-	errInsufficientPieces = errors.New("couldn't fetch enough pieces to recover data")
-	errPrevErr            = errors.New("download could not be completed due to a previous error")
+	errDownloadInterrupted = errors.New("download interrupted by shutdown")
+	errInsufficientHosts   = errors.New("insufficient hosts to recover file")
+	errInsufficientPieces  = errors.New("couldn't fetch enough pieces to recover data")
+	errPrevErr             = errors.New("download could not be completed due to a previous error")
 
 	// maxActiveDownloadPieces determines the maximum number of pieces that are
 	// allowed to be concurrently downloading. More pieces means more
@@ -62,6 +62,7 @@ type (
 	download struct {
 		// Progress variables.
 		atomicDataReceived uint64
+		checksumStatus     string
 		downloadComplete   bool
 		downloadErr        error
 		finishedChunks     map[uint64]bool
@@ -73,13 +74,24 @@ type (
 		startTime    time.Time
 
 		// Static information about the file - can be read without a lock.
+		checksum    modules.FileChecksum
 		chunkSize   uint64
 		destination modules.DownloadWriter
 		erasureCode modules.ErasureCoder
+		file        *file
 		fileSize    uint64
 		masterKey   crypto.TwofishKey
 		numChunks   uint64
 
+		// repair is set for the downloads of the repair loop, whose pieces
+		// are paid for as repair download spending.
+		repair bool
+
+		// chunksUnverified is set once a chunk of the download could not be
+		// verified against the checksum of the file. It is only accessed by
+		// the download loop thread.
+		chunksUnverified bool
+
 		// pieceSet contains a sparse map of the chunk indices to be downloaded to
 		// their piece data.
 		pieceSet          map[uint64]map[types.FileContractID]pieceData
@@ -151,11 +163,16 @@ func (r *Renter) newSectionDownload(f *file, destination modules.DownloadWriter,
 
 // newDownload creates a newly initialized download.
 func newDownload(f *file, destination modules.DownloadWriter) *download {
+	f.mu.RLock()
+	checksum := f.checksum
+	f.mu.RUnlock()
 	return &download{
 		startTime:        time.Now(),
+		checksum:         checksum,
 		chunkSize:        f.chunkSize(),
 		destination:      destination,
 		erasureCode:      f.erasureCode,
+		file:             f,
 		fileSize:         f.size,
 		masterKey:        f.masterKey,
 		numChunks:        f.numChunks(),
@@ -185,9 +202,10 @@ func (d *download) initPieceSet(f *file, r *Renter) {
 		d.pieceSet[i] = make(map[types.FileContractID]pieceData)
 	}
 
+	hc := r.managedFileContractor(f)
 	f.mu.RLock()
 	for _, contract := range f.contracts {
-		id := r.hostContractor.ResolveID(contract.ID)
+		id := hc.ResolveID(contract.ID)
 		for i := range contract.Pieces {
 			// Only add pieceSet entries for chunks that are going to be downloaded.
 			m, exists := d.pieceSet[contract.Pieces[i].Chunk]
@@ -222,8 +240,9 @@ func (d *download) fail(err error) {
 }
 
 // recoverChunk takes a chunk that has had a sufficient number of pieces
-// downloaded and verifies, decrypts and decodes them into the file.
-func (cd *chunkDownload) recoverChunk() error {
+// downloaded and verifies, decrypts and decodes them into the file. The
+// recovered chunk is returned so that it can be cached.
+func (cd *chunkDownload) recoverChunk() ([]byte, error) {
 	// Assemble the chunk from the download.
 	cd.download.mu.Lock()
 	chunk := make([][]byte, cd.download.erasureCode.NumPieces())
@@ -236,7 +255,7 @@ func (cd *chunkDownload) recoverChunk() error {
 
 	// Return early if the download has previously suffered an error.
 	if complete {
-		return build.ComposeErrors(errPrevErr, prevErr)
+		return nil, build.ComposeErrors(errPrevErr, prevErr)
 	}
 
 	// Decrypt the chunk pieces.
@@ -250,7 +269,7 @@ func (cd *chunkDownload) recoverChunk() error {
 		key := deriveKey(cd.download.masterKey, cd.index, uint64(i))
 		decryptedPiece, err := key.DecryptBytes(chunk[i])
 		if err != nil {
-			return build.ExtendErr("unable to decrypt piece", err)
+			return nil, build.ExtendErr("unable to decrypt piece", err)
 		}
 		chunk[i] = decryptedPiece
 	}
@@ -263,11 +282,18 @@ func (cd *chunkDownload) recoverChunk() error {
 	}
 	err := cd.download.erasureCode.Recover(chunk, recoverSize, recoverWriter)
 	if err != nil {
-		return build.ExtendErr("unable to recover chunk", err)
+		return nil, build.ExtendErr("unable to recover chunk", err)
 	}
+	data := recoverWriter.Bytes()
+	if err := cd.download.verifyChunk(cd.index, data); err != nil {
+		return nil, err
+	}
+	return data, cd.writeChunk(data)
+}
 
-	result := recoverWriter.Bytes()
-
+// writeChunk writes the requested part of the recovered chunk data to the
+// destination of the download, and marks the chunk as finished.
+func (cd *chunkDownload) writeChunk(result []byte) error {
 	// Calculate the offset. If the offset is within the chunk, the
 	// requested offset is passed, otherwise the offset of the chunk
 	// within the overall file is passed.
@@ -284,7 +310,7 @@ func (cd *chunkDownload) recoverChunk() error {
 	// Truncate b if writing the whole buffer at the specified offset would
 	// exceed the maximum file size.
 	upperBound := cd.download.chunkSize
-	if chunkTopAddress > cd.download.length+cd.download.offset {
+	if chunkTopAddress >= cd.download.length+cd.download.offset {
 		diff := chunkTopAddress - (cd.download.length + cd.download.offset)
 		upperBound -= diff + 1
 	}
@@ -295,13 +321,12 @@ func (cd *chunkDownload) recoverChunk() error {
 	result = result[lowerBound:upperBound]
 
 	// Write the bytes to the requested output.
-	_, err = cd.download.destination.WriteAt(result, int64(off))
+	_, err := cd.download.destination.WriteAt(result, int64(off))
 	if err != nil {
 		return build.ExtendErr("unable to write to download destination", err)
 	}
 
 	cd.download.mu.Lock()
-	defer cd.download.mu.Unlock()
 
 	// Update the download to signal that this chunk has completed. Only update
 	// after the sync, so that durability is maintained.
@@ -318,15 +343,28 @@ func (cd *chunkDownload) recoverChunk() error {
 			break
 		}
 	}
-	if nowComplete {
-		// Signal that the download is complete.
-		cd.download.downloadComplete = true
-		close(cd.download.downloadFinished)
-		err = cd.download.destination.Close()
-		if err != nil {
-			return err
-		}
+	cd.download.mu.Unlock()
+	if !nowComplete {
+		return nil
+	}
+
+	// Verify the downloaded data before signaling that the download is
+	// complete, so that a download which does not match the checksum of the
+	// file fails. The lock is not held while the data is verified, as the
+	// whole file may have to be read from disk.
+	err = cd.download.destination.Close()
+	if err != nil {
+		return err
 	}
+	status, err := cd.download.verifyDownload()
+	if err != nil {
+		return err
+	}
+	cd.download.mu.Lock()
+	defer cd.download.mu.Unlock()
+	cd.download.checksumStatus = status
+	cd.download.downloadComplete = true
+	close(cd.download.downloadFinished)
 	return nil
 }
 
@@ -401,6 +439,15 @@ func (r *Renter) managedDownloadIteration(ds *downloadState) {
 			continue
 		}
 
+		// Ignore workers whose host has an open circuit breaker.
+		allow, probe := r.hostBreakers.managedCheck(worker.hostPubKey)
+		if probe {
+			go worker.threadedProbeHost()
+		}
+		if !allow {
+			continue
+		}
+
 		// TODO: Prune workers that do not provide value. If bandwidth can be
 		// saturated with fewer workers, then the more expensive ones should be
 		// eliminated.
@@ -545,6 +592,25 @@ func (r *Renter) managedScheduleNewChunks(ds *downloadState) {
 			continue
 		}
 
+		// Serve the chunk from the disk cache if it contains the chunk. A
+		// cached chunk that does not match the checksum of the file is
+		// corrupted, so it is evicted and the chunk is fetched from the hosts.
+		if data, ok := r.cache.managedGet(nextChunk.download.masterKey, nextChunk.index); ok {
+			if err := nextChunk.download.verifyChunk(nextChunk.index, data); err != nil {
+				r.log.Println("Evicting a cached chunk that does not match its checksum:", err)
+				r.cache.managedRemove(nextChunk.download.masterKey, nextChunk.index)
+			} else {
+				atomic.AddUint64(&nextChunk.download.atomicDataReceived, nextChunk.download.reportedPieceSize*uint64(nextChunk.download.erasureCode.MinPieces()))
+				if err := nextChunk.writeChunk(data); err != nil {
+					r.log.Println("Download failed - could not write a cached chunk:", err)
+					nextChunk.download.mu.Lock()
+					nextChunk.download.fail(err)
+					nextChunk.download.mu.Unlock()
+				}
+				continue
+			}
+		}
+
 		// Add an incomplete chunk entry for every piece of the download.
 		for i := 0; i < nextChunk.download.erasureCode.MinPieces(); i++ {
 			ds.incompleteChunks = append(ds.incompleteChunks, nextChunk)
@@ -591,9 +657,22 @@ func (r *Renter) managedWaitOnDownloadWork(ds *downloadState) {
 	if finishedDownload.err != nil {
 		r.log.Debugln("Error when downloading a piece:", finishedDownload.err)
 		worker.downloadRecentFailure = time.Now()
+		r.hostBreakers.managedRecordFailure(worker.hostPubKey)
 		ds.incompleteChunks = append(ds.incompleteChunks, cd)
 		return
 	}
+	r.hostBreakers.managedRecordSuccess(worker.hostPubKey)
+
+	// Drop the piece if the download failed while the piece was in flight.
+	// The other pieces of the chunk were cleared when the download failed, so
+	// only the burden of this piece remains.
+	cd.download.mu.Lock()
+	downloadComplete := cd.download.downloadComplete
+	cd.download.mu.Unlock()
+	if downloadComplete {
+		ds.activePieces--
+		return
+	}
 
 	// Add this returned piece to the appropriate chunk.
 	if _, ok := cd.completedPieces[finishedDownload.pieceIndex]; ok {
@@ -603,18 +682,27 @@ func (r *Renter) managedWaitOnDownloadWork(ds *downloadState) {
 	}
 	cd.completedPieces[finishedDownload.pieceIndex] = finishedDownload.data
 	atomic.AddUint64(&cd.download.atomicDataReceived, cd.download.reportedPieceSize)
+	r.managedRecordTransfer(cd.download.siapath, workerID, 0, uint64(len(finishedDownload.data)))
 
 	// If the chunk has completed, perform chunk recovery.
 	if len(cd.completedPieces) == cd.download.erasureCode.MinPieces() {
-		err := cd.recoverChunk()
+		data, err := cd.recoverChunk()
 		ds.activePieces -= len(cd.completedPieces)
 		cd.completedPieces = make(map[uint64][]byte)
 		if err != nil {
 			r.log.Println("Download failed - could not recover a chunk:", err)
+			if err == errChecksumMismatch {
+				r.managedMarkForAudit(cd.download)
+			}
 			cd.download.mu.Lock()
 			cd.download.fail(err)
 			cd.download.mu.Unlock()
 		}
+		if data != nil {
+			if err := r.cache.managedAdd(cd.download.masterKey, cd.index, data); err != nil {
+				r.log.Println("WARN: could not add a chunk to the disk cache:", err)
+			}
+		}
 	}
 }
 
@@ -736,69 +824,3 @@ func (dw *DownloadFileWriter) WriteAt(b []byte, off int64) (int, error) {
 func (dw *DownloadFileWriter) Close() error {
 	return dw.f.Close()
 }
-
-// DownloadHttpWriter is a http response writer-backed implementation of
-// DownloadWriter.  The writer writes all content that is written to the
-// current `offset` directly to the ResponseWriter, and buffers all content
-// that is written at other offsets.  After every write to the ResponseWriter
-// the `offset` and `length` fields are updated, and buffer content written
-// until
-type DownloadHttpWriter struct {
-	w              io.Writer
-	offset         int            // The index in the original file of the last byte written to the response writer.
-	firstByteIndex int            // The index of the first byte in the original file.
-	length         int            // The total size of the slice to be written.
-	buffer         map[int][]byte // Buffer used for storing the chunks until download finished.
-}
-
-// NewDownloadHttpWriter creates a new instance of http.ResponseWriter backed DownloadWriter.
-func NewDownloadHttpWriter(w io.Writer, offset, length uint64) *DownloadHttpWriter {
-	return &DownloadHttpWriter{
-		w:              w,
-		offset:         0,           // Current offset in the output file.
-		firstByteIndex: int(offset), // Index of first byte in original file.
-		length:         int(length),
-		buffer:         make(map[int][]byte),
-	}
-}
-
-// Destination implements the Destination method of the DownloadWriter
-// interface and informs callers where this download writer is
-// being written to.
-func (dw *DownloadHttpWriter) Destination() string {
-	return "httpresp"
-}
-
-// Cloes implements DownloadWriter's Close method.
-func (dw *DownloadHttpWriter) Close() error {
-	return nil
-}
-
-// WriteAt buffers parts of the file until the entire file can be
-// flushed to the client. Returns the number of bytes written or an error.
-func (dw *DownloadHttpWriter) WriteAt(b []byte, off int64) (int, error) {
-	// Write bytes to buffer.
-	offsetInBuffer := int(off) - dw.firstByteIndex
-	dw.buffer[offsetInBuffer] = b
-
-	// Send all chunks to the client that can be sent.
-	totalDataSent := 0
-	for {
-		data, exists := dw.buffer[dw.offset]
-		if exists {
-			// Send data to client.
-			dw.w.Write(data)
-
-			// Remove chunk from map.
-			delete(dw.buffer, dw.offset)
-
-			// Increment offset to point to the beginning of the next chunk.
-			dw.offset += len(data)
-			totalDataSent += len(data)
-		} else {
-			break
-		}
-	}
-
-	return totalDataSent, nil
-}
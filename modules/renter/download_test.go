@@ -1,11 +1,14 @@
 package renter
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/NebulousLabs/fastrand"
 )
 
 // TestRenterDownloadFileWriter verifies that the renter's DownloadFileWriter
@@ -50,3 +53,37 @@ func TestRenterDownloadFileWriter(t *testing.T) {
 		t.Fatal("expected read to return file already closed, got", err, "instead.")
 	}
 }
+
+// TestWriteChunkSectionEnd checks that writeChunk only writes the requested
+// section of a chunk when the section ends one byte before the end of the
+// chunk.
+func TestWriteChunkSectionEnd(t *testing.T) {
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, 64, 128)
+	data := fastrand.Bytes(128)
+	sections := []struct {
+		offset, length uint64
+	}{
+		{0, 63},
+		{70, 57},
+		{10, 100},
+	}
+	for _, s := range sections {
+		dbw := NewDownloadBufferWriter(s.length, int64(s.offset))
+		d := newDownload(f, dbw)
+		d.offset, d.length = s.offset, s.length
+		minChunk, maxChunk := s.offset/64, (s.offset+s.length-1)/64
+		for i := minChunk; i <= maxChunk; i++ {
+			d.finishedChunks[i] = false
+		}
+		for i := minChunk; i <= maxChunk; i++ {
+			cd := &chunkDownload{download: d, index: i}
+			if err := cd.writeChunk(data[i*64 : (i+1)*64]); err != nil {
+				t.Fatalf("section %v: %v", s, err)
+			}
+		}
+		if !bytes.Equal(dbw.Bytes(), data[s.offset:s.offset+s.length]) {
+			t.Fatalf("section %v: wrong data", s)
+		}
+	}
+}
@@ -18,7 +18,30 @@ func (r *Renter) Download(p modules.RenterDownloadParameters) error {
 	if !exists {
 		return errors.New(fmt.Sprintf("no file with that path: %s", p.Siapath))
 	}
+	return r.managedDownloadFile(file, p)
+}
+
+// downloadSectionLength checks that the section of the file starting at offset
+// can be downloaded, and returns the length of the section. A length of 0
+// selects the remainder of the file.
+func downloadSectionLength(file *file, offset, length uint64) (uint64, error) {
+	if offset == file.size {
+		return 0, errors.New("offset equals filesize")
+	}
+	// sentinel: if length == 0, download the entire file
+	if length == 0 {
+		length = file.size - offset
+	}
+	// Check whether offset and length is valid.
+	if offset < 0 || offset+length > file.size {
+		return 0, fmt.Errorf("offset and length combination invalid, max byte is at index %d", file.size-1)
+	}
+	return length, nil
+}
 
+// managedDownloadFile downloads the file according to the parameters passed,
+// and blocks until the download has completed.
+func (r *Renter) managedDownloadFile(file *file, p modules.RenterDownloadParameters) error {
 	isHttpResp := p.Httpwriter != nil
 
 	// validate download parameters
@@ -34,48 +57,51 @@ func (r *Renter) Download(p modules.RenterDownloadParameters) error {
 	if p.Destination != "" && !filepath.IsAbs(p.Destination) {
 		return errors.New("destination must be an absolute path")
 	}
-	if p.Offset == file.size {
-		return errors.New("offset equals filesize")
-	}
-	// sentinel: if length == 0, download the entire file
-	if p.Length == 0 {
-		p.Length = file.size - p.Offset
-	}
-	// Check whether offset and length is valid.
-	if p.Offset < 0 || p.Offset+p.Length > file.size {
-		return fmt.Errorf("offset and length combination invalid, max byte is at index %d", file.size-1)
+	length, err := downloadSectionLength(file, p.Offset, p.Length)
+	if err != nil {
+		return err
 	}
+	p.Length = length
 
-	// Instantiate the correct DownloadWriter implementation
-	// (e.g. content written to file or response body).
-	var dw modules.DownloadWriter
+	// Downloads to a response body are streamed in order, so that only a few
+	// chunks are held in memory at a time.
 	if isHttpResp {
-		dw = NewDownloadHttpWriter(p.Httpwriter, p.Offset, p.Length)
-	} else {
-		dfw, err := NewDownloadFileWriter(p.Destination, p.Offset, p.Length)
-		if err != nil {
-			return err
-		}
-		dw = dfw
+		return r.managedQueueStream(file, p.Httpwriter, "httpresp", p.Offset, p.Length)
+	}
+	dfw, err := NewDownloadFileWriter(p.Destination, p.Offset, p.Length)
+	if err != nil {
+		return err
 	}
 
 	// Create the download object and add it to the queue.
-	d := r.newSectionDownload(file, dw, p.Offset, p.Length)
+	return r.managedQueueDownload(r.newSectionDownload(file, dfw, p.Offset, p.Length))
+}
 
-	lockID = r.mu.Lock()
+// managedQueueDownload adds a download to the download queue, and blocks until
+// the download has completed.
+func (r *Renter) managedQueueDownload(d *download) error {
+	lockID := r.mu.Lock()
 	r.downloadQueue = append(r.downloadQueue, d)
 	r.mu.Unlock(lockID)
-	r.newDownloads <- d
+	return r.managedWaitOnDownload(d)
+}
+
+// managedWaitOnDownload hands a download to the download loop and blocks until
+// the download has completed.
+func (r *Renter) managedWaitOnDownload(d *download) error {
+	select {
+	case r.newDownloads <- d:
+	case <-r.tg.StopChan():
+		return errDownloadInterrupted
+	}
 
-	// Block until the download has completed.
-	//
 	// TODO: Eventually just return the channel to the error instead of the
 	// error itself.
 	select {
 	case <-d.downloadFinished:
 		return d.Err()
 	case <-r.tg.StopChan():
-		return errors.New("download interrupted by shutdown")
+		return errDownloadInterrupted
 	}
 }
 
@@ -93,10 +119,15 @@ func (r *Renter) DownloadQueue() []modules.DownloadInfo {
 			SiaPath:     d.siapath,
 			Destination: d.destination,
 			Filesize:    d.length,
+			Offset:      d.offset,
+			Section:     d.offset != 0 || d.length != d.fileSize,
 			StartTime:   d.startTime,
 		}
 		downloads[i].Received = atomic.LoadUint64(&d.atomicDataReceived)
 
+		d.mu.Lock()
+		downloads[i].ChecksumStatus = d.checksumStatus
+		d.mu.Unlock()
 		if err := d.Err(); err != nil {
 			downloads[i].Error = err.Error()
 		}
@@ -0,0 +1,43 @@
+package renter
+
+import (
+	"errors"
+	"io"
+)
+
+var (
+	// ErrSectionOutOfRange is returned if a section download does not lie
+	// within the file.
+	ErrSectionOutOfRange = errors.New("section extends beyond the end of the file")
+
+	// ErrZeroLengthSection is returned if a section download has a length of
+	// zero.
+	ErrZeroLengthSection = errors.New("section length must be greater than zero")
+)
+
+// DownloadSection streams length bytes of the file at siaPath, starting at
+// offset, to w. Unlike the other downloads, a length of 0 is rejected instead
+// of selecting the remainder of the file. The download is added to the
+// download queue. If the download fails once streaming has started, a
+// modules.DownloadStreamError with the number of bytes that were written to w
+// is returned.
+func (r *Renter) DownloadSection(siaPath string, offset, length uint64, w io.Writer) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	if length == 0 {
+		return ErrZeroLengthSection
+	}
+
+	id := r.mu.RLock()
+	f, exists := r.files[siaPath]
+	r.mu.RUnlock(id)
+	if !exists {
+		return ErrUnknownPath
+	}
+	if offset >= f.size || length > f.size-offset {
+		return ErrSectionOutOfRange
+	}
+	return r.managedQueueStream(f, w, "writer", offset, length)
+}
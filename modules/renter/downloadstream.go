@@ -0,0 +1,153 @@
+package renter
+
+// downloadstream.go streams downloads to an io.Writer. The section of the file
+// is split at its chunk boundaries, and every chunk is downloaded by the
+// download loop into a buffer of its own. Chunks are written to the writer in
+// order, and at most streamPrefetchChunks chunks are downloaded ahead of the
+// writer, which bounds the memory of a stream and slows the download down to
+// the pace of a slow writer.
+//
+// Streams of DownloadWriter are not added to the download queue. The streams of
+// DownloadSection and of downloads to an http response are.
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// streamedChunk is the result of downloading a chunk of a stream.
+type streamedChunk struct {
+	data []byte
+	err  error
+}
+
+// managedStreamChunk downloads the section of the file at offset into a
+// buffer.
+func (r *Renter) managedStreamChunk(f *file, offset, length uint64) streamedChunk {
+	if err := r.tg.Add(); err != nil {
+		return streamedChunk{err: err}
+	}
+	defer r.tg.Done()
+	buf := NewDownloadBufferWriter(length, int64(offset))
+	err := r.managedWaitOnDownload(r.newSectionDownload(f, buf, offset, length))
+	return streamedChunk{data: buf.Bytes(), err: err}
+}
+
+// streamDestination is the destination of a stream in the download queue. The
+// chunks of a stream are written to its io.Writer by managedStreamSection, so
+// streamDestination is never written to.
+type streamDestination string
+
+// Destination implements the Destination method of the DownloadWriter
+// interface.
+func (sd streamDestination) Destination() string {
+	return string(sd)
+}
+
+// Close implements the Close method of the DownloadWriter interface.
+func (sd streamDestination) Close() error {
+	return nil
+}
+
+// WriteAt implements the WriteAt method of the DownloadWriter interface.
+func (sd streamDestination) WriteAt(b []byte, off int64) (int, error) {
+	build.Critical("stream destination should not be written to")
+	return 0, errors.New("cannot write to a stream destination")
+}
+
+// DownloadWriter streams the file at siaPath to w. If the download fails once
+// streaming has started, a modules.DownloadStreamError with the number of
+// bytes that were written to w is returned.
+func (r *Renter) DownloadWriter(siaPath string, w io.Writer) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	id := r.mu.RLock()
+	f, exists := r.files[siaPath]
+	r.mu.RUnlock(id)
+	if !exists {
+		return ErrUnknownPath
+	}
+	length, err := downloadSectionLength(f, 0, 0)
+	if err != nil {
+		return err
+	}
+	return r.managedStreamSection(f, w, 0, length, nil)
+}
+
+// managedQueueStream adds a stream of the section of the file to the download
+// queue as a download to dest, and streams the section to w. The progress of
+// the stream is reported by the queue as the bytes written to w.
+func (r *Renter) managedQueueStream(f *file, w io.Writer, dest string, offset, length uint64) error {
+	d := newDownload(f, streamDestination(dest))
+	d.offset = offset
+	d.length = length
+	lockID := r.mu.Lock()
+	r.downloadQueue = append(r.downloadQueue, d)
+	r.mu.Unlock(lockID)
+
+	err := r.managedStreamSection(f, w, offset, length, d)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err != nil {
+		d.fail(err)
+		return err
+	}
+	d.downloadComplete = true
+	close(d.downloadFinished)
+	return nil
+}
+
+// managedStreamSection streams length bytes of the file, starting at offset,
+// to w. If d is not nil, the bytes written to w are added to its progress.
+func (r *Renter) managedStreamSection(f *file, w io.Writer, offset, length uint64, d *download) error {
+	// Start the chunk downloads in the background. The result of each chunk
+	// is queued on pending before its download starts, which blocks once
+	// streamPrefetchChunks chunks are waiting for the writer.
+	pending := make(chan chan streamedChunk, streamPrefetchChunks)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(pending)
+		chunkSize := f.chunkSize()
+		for pos, end := offset, offset+length; pos < end; {
+			n := (pos/chunkSize+1)*chunkSize - pos
+			if pos+n > end {
+				n = end - pos
+			}
+			result := make(chan streamedChunk, 1)
+			select {
+			case pending <- result:
+			case <-stop:
+				return
+			}
+			go func(pos, n uint64) {
+				result <- r.managedStreamChunk(f, pos, n)
+			}(pos, n)
+			pos += n
+		}
+	}()
+
+	var written uint64
+	for result := range pending {
+		chunk := <-result
+		if chunk.err == nil {
+			var n int
+			n, chunk.err = w.Write(chunk.data)
+			written += uint64(n)
+			if d != nil {
+				atomic.AddUint64(&d.atomicDataReceived, uint64(n))
+			}
+		}
+		if chunk.err != nil {
+			return modules.DownloadStreamError{BytesWritten: written, Err: chunk.err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package renter
+
+// filelist.go implements the export and import of the metadata of all files
+// tracked by the renter. The metadata contains everything that is needed to
+// recover the files from the hosts: the erasure code, the master key, and the
+// pieces that each contract is storing. Renters that lose their persist
+// directory can restore their files from an exported file list, as long as
+// the contracts are still being stored by the hosts.
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// fileListVersion is the version of the JSON file list format.
+const fileListVersion = "1.0"
+
+var (
+	errBadFileListKey     = errors.New("file list contains a master key of the wrong length")
+	errUnknownFileListFmt = errors.New("unknown file list format, must be 'binary' or 'json'")
+	errFileListVersion    = errors.New("file list version is not supported")
+)
+
+type (
+	// fileList is the JSON encoding of the renter's file metadata.
+	fileList struct {
+		Version string          `json:"version"`
+		Files   []fileListEntry `json:"files"`
+	}
+
+	// fileListEntry is the JSON encoding of the metadata of a single file.
+	fileListEntry struct {
+		Name         string         `json:"name"`
+		Size         uint64         `json:"size"`
+		MasterKey    []byte         `json:"masterkey"`
+		PieceSize    uint64         `json:"piecesize"`
+		Mode         uint32         `json:"mode"`
+		DataPieces   int            `json:"datapieces"`
+		ParityPieces int            `json:"paritypieces"`
+		Contracts    []fileContract `json:"contracts"`
+	}
+)
+
+// fileListEntry returns the JSON encoding of the file's metadata.
+func (f *file) fileListEntry() fileListEntry {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	entry := fileListEntry{
+		Name:         f.name,
+		Size:         f.size,
+		MasterKey:    append([]byte(nil), f.masterKey[:]...),
+		PieceSize:    f.pieceSize,
+		Mode:         f.mode,
+		DataPieces:   f.erasureCode.MinPieces(),
+		ParityPieces: f.erasureCode.NumPieces() - f.erasureCode.MinPieces(),
+		Contracts:    make([]fileContract, 0, len(f.contracts)),
+	}
+	for _, fc := range f.contracts {
+		entry.Contracts = append(entry.Contracts, fc)
+	}
+	sort.Slice(entry.Contracts, func(i, j int) bool {
+		return entry.Contracts[i].ID.String() < entry.Contracts[j].ID.String()
+	})
+	return entry
+}
+
+// file returns the file that is described by the entry.
+func (entry fileListEntry) file() (*file, error) {
+	f := &file{
+		name:      entry.Name,
+		size:      entry.Size,
+		pieceSize: entry.PieceSize,
+		mode:      entry.Mode,
+		contracts: make(map[types.FileContractID]fileContract),
+	}
+	if len(entry.MasterKey) != len(f.masterKey) {
+		return nil, errBadFileListKey
+	}
+	copy(f.masterKey[:], entry.MasterKey)
+	rsc, err := NewRSCode(entry.DataPieces, entry.ParityPieces)
+	if err != nil {
+		return nil, err
+	}
+	f.erasureCode = rsc
+	for _, fc := range entry.Contracts {
+		f.contracts[fc.ID] = fc
+	}
+	return f, nil
+}
+
+// mergeContracts adds the contracts and pieces of src that f does not know
+// about to f.
+func (f *file) mergeContracts(src *file) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, srcContract := range src.contracts {
+		fc, exists := f.contracts[id]
+		if !exists {
+			f.contracts[id] = srcContract
+			continue
+		}
+		known := make(map[pieceData]struct{}, len(fc.Pieces))
+		for _, p := range fc.Pieces {
+			known[p] = struct{}{}
+		}
+		for _, p := range srcContract.Pieces {
+			if _, ok := known[p]; !ok {
+				fc.Pieces = append(fc.Pieces, p)
+			}
+		}
+		f.contracts[id] = fc
+	}
+}
+
+// mergeFiles merges the provided files with the files of the renter. A file
+// with the same name and master key as a file of the renter is the same file,
+// and the pieces that the renter does not know about are added to it. Other
+// files are added to the renter, renamed if their name is already in use.
+func (r *Renter) mergeFiles(files []*file) error {
+	var merged []*file
+	for _, f := range files {
+		if existing, exists := r.files[f.name]; exists && existing.masterKey == f.masterKey {
+			existing.mergeContracts(f)
+			merged = append(merged, existing)
+			continue
+		}
+		dupCount := 0
+		origName := f.name
+		for {
+			_, exists := r.files[f.name]
+			if !exists {
+				break
+			}
+			dupCount++
+			f.name = origName + "_" + strconv.Itoa(dupCount)
+		}
+		r.files[f.name] = f
+		merged = append(merged, f)
+	}
+	for _, f := range merged {
+		if err := r.saveFile(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportFileList writes the metadata of all files tracked by the renter to w,
+// using either the binary or the JSON file list format.
+func (r *Renter) ExportFileList(w io.Writer, format string) error {
+	lockID := r.mu.RLock()
+	defer r.mu.RUnlock(lockID)
+	files := make([]*file, 0, len(r.files))
+	for _, f := range r.files {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].name < files[j].name
+	})
+
+	switch format {
+	case modules.FileListFormatBinary:
+		return shareFiles(files, w)
+	case modules.FileListFormatJSON:
+		fl := fileList{
+			Version: fileListVersion,
+			Files:   make([]fileListEntry, len(files)),
+		}
+		for i, f := range files {
+			fl.Files[i] = f.fileListEntry()
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(fl)
+	default:
+		return errUnknownFileListFmt
+	}
+}
+
+// ImportFileList reads file metadata that was written by ExportFileList from
+// reader and merges it with the files tracked by the renter. Files that the renter
+// already tracks keep their metadata and gain the pieces that were only known
+// to the file list.
+func (r *Renter) ImportFileList(reader io.Reader, format string) error {
+	var files []*file
+	switch format {
+	case modules.FileListFormatBinary:
+		var err error
+		files, err = readSharedFiles(reader)
+		if err != nil {
+			return err
+		}
+	case modules.FileListFormatJSON:
+		var fl fileList
+		if err := json.NewDecoder(reader).Decode(&fl); err != nil {
+			return err
+		} else if fl.Version != fileListVersion {
+			return errFileListVersion
+		}
+		for _, entry := range fl.Files {
+			f, err := entry.file()
+			if err != nil {
+				return err
+			}
+			files = append(files, f)
+		}
+	default:
+		return errUnknownFileListFmt
+	}
+
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	return r.mergeFiles(files)
+}
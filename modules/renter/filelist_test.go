@@ -0,0 +1,84 @@
+package renter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestExportImportFileList checks that file metadata can be exported and
+// merged back into the renter in both file list formats.
+func TestExportImportFileList(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	for _, format := range []string{modules.FileListFormatBinary, modules.FileListFormatJSON} {
+		// Add two files with a contract each to the renter.
+		id := types.FileContractID{1}
+		pieces := []pieceData{{Chunk: 0, Piece: 0, MerkleRoot: crypto.Hash{1}}, {Chunk: 0, Piece: 1, MerkleRoot: crypto.Hash{2}}}
+		f1, f2 := newTestingFile(), newTestingFile()
+		f1.name, f2.name = "a", "b"
+		for _, f := range []*file{f1, f2} {
+			f.contracts = map[types.FileContractID]fileContract{
+				id: {ID: id, IP: "foo:1234", Pieces: append([]pieceData(nil), pieces...), WindowStart: 100},
+			}
+		}
+		lockID := rt.renter.mu.Lock()
+		rt.renter.files = map[string]*file{f1.name: f1, f2.name: f2}
+		rt.renter.mu.Unlock(lockID)
+
+		var buf bytes.Buffer
+		if err := rt.renter.ExportFileList(&buf, format); err != nil {
+			t.Fatal(err)
+		}
+
+		// Forget one file and a piece of the other, and replace a third file
+		// with a different file of the same name.
+		f1.contracts[id] = fileContract{ID: id, IP: "foo:1234", Pieces: pieces[:1], WindowStart: 100}
+		f3 := newTestingFile()
+		f3.name = "b"
+		lockID = rt.renter.mu.Lock()
+		rt.renter.files = map[string]*file{f1.name: f1, f3.name: f3}
+		rt.renter.mu.Unlock(lockID)
+
+		if err := rt.renter.ImportFileList(&buf, format); err != nil {
+			t.Fatal(err)
+		}
+		lockID = rt.renter.mu.RLock()
+		files := rt.renter.files
+		rt.renter.mu.RUnlock(lockID)
+		if len(files) != 3 || files["a"] != f1 || files["b"] != f3 {
+			t.Fatal("files were not merged:", len(files))
+		}
+		if got := f1.contracts[id].Pieces; len(got) != 2 || got[1] != pieces[1] {
+			t.Error("missing piece was not merged:", got)
+		}
+		imported := files["b_1"]
+		if err := equalFiles(imported, &file{name: "b_1", size: f2.size, masterKey: f2.masterKey, pieceSize: f2.pieceSize}); err != nil {
+			t.Error(err)
+		}
+		if imported.erasureCode.NumPieces() != f2.erasureCode.NumPieces() || imported.erasureCode.MinPieces() != f2.erasureCode.MinPieces() {
+			t.Error("erasure code was not imported")
+		}
+		if fc := imported.contracts[id]; fc.IP != "foo:1234" || fc.WindowStart != 100 || len(fc.Pieces) != 2 || fc.Pieces[1] != pieces[1] {
+			t.Error("contract was not imported:", fc)
+		}
+	}
+
+	if err := rt.renter.ExportFileList(new(bytes.Buffer), "xml"); err != errUnknownFileListFmt {
+		t.Fatal("expected errUnknownFileListFmt, got", err)
+	}
+	if err := rt.renter.ImportFileList(bytes.NewBufferString(`{"version":"0.1"}`), modules.FileListFormatJSON); err != errFileListVersion {
+		t.Fatal("expected errFileListVersion, got", err)
+	}
+}
@@ -3,8 +3,11 @@ package renter
 import (
 	"errors"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -33,17 +36,47 @@ type file struct {
 	pieceSize   uint64               // Static - can be accessed without lock.
 	mode        uint32               // actually an os.FileMode
 
+	// chunksInRepair is the number of chunks of the file that are in the
+	// repair heap or held by workers, plus one for every time the file is
+	// being sent to the repair loop. It is not persisted.
+	chunksInRepair int
+
+	// newVersion is set on the new version of a file whose redundancy is
+	// being changed. The new version is saved next to the current version of
+	// the file, and is not part of the renter's files. It is not persisted.
+	newVersion bool
+
+	// version is set on the previous versions of a file to their version
+	// number. Previous versions are saved next to the current version of the
+	// file, and are not part of the renter's files. It is not persisted.
+	version int
+
+	// pieceHistory records the uploads of the file's pieces. It is saved next
+	// to the file, and only loaded from disk once it is needed.
+	// pieceHistoryDirty is set while the history has uploads that have not
+	// been saved yet.
+	pieceHistory       map[pieceKey][]modules.RenterPieceEvent
+	pieceHistoryDirty  bool
+	pieceHistoryLoaded bool
+
+	// checksum is the checksum of the file's plaintext that was computed when
+	// the file was uploaded. It is empty for files that were uploaded without
+	// a checksum. auditRequired is set once a download of the file did not
+	// match the checksum.
+	checksum      modules.FileChecksum
+	auditRequired bool
+
 	mu sync.RWMutex
 }
 
 // A fileContract is a contract covering an arbitrary number of file pieces.
 // Chunk/Piece metadata is used to split the raw contract data appropriately.
 type fileContract struct {
-	ID     types.FileContractID
-	IP     modules.NetAddress
-	Pieces []pieceData
+	ID     types.FileContractID `json:"id"`
+	IP     modules.NetAddress   `json:"netaddress"`
+	Pieces []pieceData          `json:"pieces"`
 
-	WindowStart types.BlockHeight
+	WindowStart types.BlockHeight `json:"windowstart"`
 }
 
 // pieceData contains the metadata necessary to request a piece from a
@@ -52,9 +85,9 @@ type fileContract struct {
 // TODO: Add an 'Unavailable' flag that can be set if the host loses the piece.
 // Some TODOs exist in 'repair.go' related to this field.
 type pieceData struct {
-	Chunk      uint64      // which chunk the piece belongs to
-	Piece      uint64      // the index of the piece in the chunk
-	MerkleRoot crypto.Hash // the Merkle root of the piece
+	Chunk      uint64      `json:"chunk"`      // which chunk the piece belongs to
+	Piece      uint64      `json:"piece"`      // the index of the piece in the chunk
+	MerkleRoot crypto.Hash `json:"merkleroot"` // the Merkle root of the piece
 }
 
 // deriveKey derives the key used to encrypt and decrypt a specific file piece.
@@ -175,7 +208,10 @@ func newFile(name string, code modules.ErasureCoder, pieceSize, fileSize uint64)
 }
 
 // DeleteFile removes a file entry from the renter and deletes its data from
-// the hosts it is stored on.
+// the hosts it is stored on. If the version retention of the file keeps
+// previous versions for a limited time, the file becomes the newest previous
+// version of its siapath instead, and its data stays on the hosts until the
+// version exceeds the retention.
 //
 // TODO: The data is not cleared from any contracts where the host is not
 // immediately online.
@@ -186,14 +222,23 @@ func (r *Renter) DeleteFile(nickname string) error {
 		r.mu.Unlock(lockID)
 		return ErrUnknownPath
 	}
-	delete(r.files, nickname)
-	delete(r.tracking, nickname)
-
-	err := persist.RemoveFile(filepath.Join(r.persistDir, f.name+ShareExtension))
-	if err != nil {
-		r.log.Println("WARN: couldn't remove file :", err)
+	if retention := r.retentionFor(nickname); keepsDeletedFiles(retention) {
+		err := r.addVersion(f, retention)
+		if err != nil {
+			r.mu.Unlock(lockID)
+			return build.ExtendErr("unable to keep the file as a previous version", err)
+		}
+		delete(r.files, nickname)
+		delete(r.tracking, nickname)
+		err = persist.RemoveFile(filepath.Join(r.persistDir, nickname+ShareExtension))
+		if err != nil {
+			r.log.Println("WARN: couldn't remove file :", err)
+		}
+		r.saveSync()
+		r.mu.Unlock(lockID)
+		return nil
 	}
-
+	r.deleteFile(nickname, f)
 	r.saveSync()
 	r.mu.Unlock(lockID)
 
@@ -206,73 +251,299 @@ func (r *Renter) DeleteFile(nickname string) error {
 	return nil
 }
 
+// PurgeFile removes the file at siaPath from the renter together with all of
+// its previous versions, whose pieces are deleted from the hosts. The
+// previous versions of a deleted file are purged as well.
+func (r *Renter) PurgeFile(siaPath string) error {
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	f, exists := r.files[siaPath]
+	if _, hasVersions := r.versions[siaPath]; !exists && !hasVersions {
+		return ErrUnknownPath
+	}
+	r.purgeVersions(siaPath)
+	if exists {
+		r.deleteFile(siaPath, f)
+	}
+	return r.saveSync()
+}
+
+// deleteFile removes the file f at nickname from the renter together with its
+// .sia file, piece history, and previous versions. The renter persistence is
+// not saved. The caller must hold the renter lock.
+func (r *Renter) deleteFile(nickname string, f *file) {
+	delete(r.files, nickname)
+	delete(r.tracking, nickname)
+	r.cancelRedundancyChange(nickname)
+	if fv, exists := r.versions[nickname]; exists {
+		r.removeVersions(nickname, fv)
+		delete(r.versions, nickname)
+	}
+
+	err := persist.RemoveFile(filepath.Join(r.persistDir, f.name+ShareExtension))
+	if err != nil {
+		r.log.Println("WARN: couldn't remove file :", err)
+	}
+	err = os.Remove(r.pieceHistoryPath(f.name))
+	if err != nil && !os.IsNotExist(err) {
+		r.log.Println("WARN: couldn't remove piece history :", err)
+	}
+	r.cache.managedRemoveFile(f.masterKey, f.numChunks())
+}
+
+// contractOfflineFunc returns a function that reports whether the pieces in a
+// contract of the provided contractor should be left out when computing the
+// availability and redundancy of a file. The pieces on hosts whose circuit
+// breaker has been open for a long time are left out as well.
+func (r *Renter) contractOfflineFunc(hc hostContractor) func(types.FileContractID) bool {
+	return func(id types.FileContractID) bool {
+		id = hc.ResolveID(id)
+		offline := hc.IsOffline(id)
+		contract, exists := hc.ContractByID(id)
+		if !exists {
+			return true
+		}
+		return offline || !contract.GoodForRenew || r.hostBreakers.managedUnavailable(contract.HostPublicKey)
+	}
+}
+
 // FileList returns all of the files that the renter has.
 func (r *Renter) FileList() []modules.FileInfo {
+	return r.managedFileList("")
+}
+
+// managedFileList returns the files of the renter whose siapath starts with
+// prefix.
+func (r *Renter) managedFileList(prefix string) []modules.FileInfo {
 	var files []*file
+	var portfolios []string
+	var uploadTimes []time.Time
+	var localPaths []string
+	var tracked []bool
+	var targets []float64
+	var versionCounts []int
+	var versionBytes []uint64
 	lockID := r.mu.RLock()
 	for _, f := range r.files {
+		if !strings.HasPrefix(f.name, prefix) {
+			continue
+		}
+		tf, isTracked := r.tracking[f.name]
 		files = append(files, f)
+		portfolios = append(portfolios, r.portfolioForSiaPath(f.name))
+		uploadTimes = append(uploadTimes, tf.UploadTime)
+		localPaths = append(localPaths, tf.RepairPath)
+		tracked = append(tracked, isTracked)
+		targets = append(targets, tf.TargetRedundancy)
+		var n int
+		var physical uint64
+		if fv, exists := r.versions[f.name]; exists {
+			n, physical = retainedVersions(fv)
+		}
+		versionCounts = append(versionCounts, n)
+		versionBytes = append(versionBytes, physical)
 	}
+	contractors := r.portfolioContractors()
 	r.mu.RUnlock(lockID)
 
-	isOffline := func(id types.FileContractID) bool {
-		id = r.hostContractor.ResolveID(id)
-		offline := r.hostContractor.IsOffline(id)
-		contract, exists := r.hostContractor.ContractByID(id)
+	throughput := r.uploadThroughput.managedThroughput()
+	uploadContracts := make(map[string]int)
+	var fileList []modules.FileInfo
+	for i, f := range files {
+		// The contracts of a file are always owned by the file's portfolio.
+		hc := contractors[portfolios[i]]
+		isOffline := r.contractOfflineFunc(hc)
+		n, exists := uploadContracts[portfolios[i]]
 		if !exists {
-			return true
+			n = goodForUploadContracts(hc)
+			uploadContracts[portfolios[i]] = n
 		}
-		return offline || !contract.GoodForRenew
-	}
 
-	var fileList []modules.FileInfo
-	for _, f := range files {
 		f.mu.RLock()
-		renewing := true
-		fileList = append(fileList, modules.FileInfo{
-			SiaPath:        f.name,
-			Filesize:       f.size,
-			Renewing:       renewing,
-			Available:      f.available(isOffline),
-			Redundancy:     f.redundancy(isOffline),
-			UploadProgress: f.uploadProgress(),
-			Expiration:     f.expiration(),
-		})
+		fi := f.fileInfo(portfolios[i], uploadTimes[i], isOffline)
+		fi.LocalPath = localPaths[i]
+		fi.TargetRedundancy = f.targetRedundancy(targets[i])
+		fi.EstimatedRepairTime, fi.EstimatedRepairTimeKnown = f.repairEstimate(hc, tracked[i], n, throughput)
 		f.mu.RUnlock()
+		fi.Versions = versionCounts[i]
+		fi.VersionBytes = versionBytes[i]
+		fileList = append(fileList, fi)
 	}
 	return fileList
 }
 
+// FileSearch returns the files whose siapath matches the glob pattern query
+// and that satisfy opts. The pattern syntax is that of path.Match, so '*' does
+// not match a '/'. An empty query matches every file, and a malformed query
+// matches none.
+func (r *Renter) FileSearch(query string, opts modules.SearchOptions) []modules.FileInfo {
+	var matches []modules.FileInfo
+	for _, fi := range r.FileList() {
+		if query != "" {
+			if ok, err := path.Match(query, fi.SiaPath); err != nil || !ok {
+				continue
+			}
+		}
+		if !opts.UploadedAfter.IsZero() && !fi.UploadTime.After(opts.UploadedAfter) {
+			continue
+		}
+		if !opts.UploadedBefore.IsZero() && (fi.UploadTime.IsZero() || !fi.UploadTime.Before(opts.UploadedBefore)) {
+			continue
+		}
+		if opts.MinRedundancy > 0 && fi.Redundancy < opts.MinRedundancy {
+			continue
+		}
+		matches = append(matches, fi)
+	}
+	return matches
+}
+
+// fileInfo returns information about f. The caller must hold the lock of f.
+func (f *file) fileInfo(portfolio string, uploadTime time.Time, isOffline func(types.FileContractID) bool) modules.FileInfo {
+	renewing := true
+	return modules.FileInfo{
+		SiaPath:        f.name,
+		Filesize:       f.size,
+		Renewing:       renewing,
+		Available:      f.available(isOffline),
+		Redundancy:     f.redundancy(isOffline),
+		UploadProgress: f.uploadProgress(),
+		Expiration:     f.expiration(),
+		Portfolio:      portfolio,
+		UploadTime:     uploadTime,
+		DataPieces:     f.erasureCode.MinPieces(),
+		ParityPieces:   f.erasureCode.NumPieces() - f.erasureCode.MinPieces(),
+		MaxRedundancy:  float64(f.erasureCode.NumPieces()) / float64(f.erasureCode.MinPieces()),
+		AuditRequired:  f.auditRequired,
+	}
+}
+
+// File returns information about the file at siaPath. If verbose is set, the
+// piece history of the file is loaded and included.
+func (r *Renter) File(siaPath string, verbose bool) (modules.RenterFile, error) {
+	lockID := r.mu.RLock()
+	f, exists := r.files[siaPath]
+	if !exists {
+		r.mu.RUnlock(lockID)
+		return modules.RenterFile{}, ErrUnknownPath
+	}
+	portfolio := r.portfolioForSiaPath(siaPath)
+	tf, tracked := r.tracking[siaPath]
+	hc := r.fileContractor(f)
+	var versions int
+	var versionBytes uint64
+	if fv, exists := r.versions[siaPath]; exists {
+		versions, versionBytes = retainedVersions(fv)
+	}
+	r.mu.RUnlock(lockID)
+	throughput := r.uploadThroughput.managedThroughput()
+	uploadContracts := goodForUploadContracts(hc)
+
+	// The file lock is held for writing, as loading the piece history
+	// modifies the file.
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rf := modules.RenterFile{
+		FileInfo: f.fileInfo(portfolio, tf.UploadTime, r.contractOfflineFunc(hc)),
+	}
+	rf.LocalPath = tf.RepairPath
+	rf.TargetRedundancy = f.targetRedundancy(tf.TargetRedundancy)
+	rf.EstimatedRepairTime, rf.EstimatedRepairTimeKnown = f.repairEstimate(hc, tracked, uploadContracts, throughput)
+	rf.Versions = versions
+	rf.VersionBytes = versionBytes
+	if verbose {
+		if err := r.loadPieceHistory(f); err != nil {
+			return modules.RenterFile{}, err
+		}
+		rf.PieceHistory = f.filePieceHistory()
+	}
+	return rf, nil
+}
+
 // RenameFile takes an existing file and changes the nickname. The original
 // file must exist, and there must not be any file that already has the
-// replacement nickname.
+// replacement nickname. The contracts and sectors of the file are not
+// changed. Downloads that were started before the rename complete under the
+// old nickname, and streamed uploads continue under the new nickname.
 func (r *Renter) RenameFile(currentName, newName string) error {
 	lockID := r.mu.Lock()
 	defer r.mu.Unlock(lockID)
 
-	// Check that newName is nonempty.
-	if newName == "" {
-		return ErrEmptyFilename
+	// Check that newName is a valid siapath.
+	if err := validateSiapath(newName); err != nil {
+		return err
+	}
+	if err := r.checkRename(currentName, newName); err != nil {
+		return err
 	}
+	if err := r.renameFile(currentName, newName); err != nil {
+		return err
+	}
+	err := r.saveSync()
+	if err != nil {
+		return err
+	}
+	return r.removeRenamedFile(currentName)
+}
 
+// checkRename checks that the file at currentName can be renamed to newName.
+// The caller must hold the renter lock.
+func (r *Renter) checkRename(currentName, newName string) error {
 	// Check that currentName exists and newName doesn't.
-	file, exists := r.files[currentName]
-	if !exists {
+	if _, exists := r.files[currentName]; !exists {
 		return ErrUnknownPath
 	}
-	_, exists = r.files[newName]
-	if exists {
+	if _, exists := r.files[newName]; exists {
+		return ErrPathOverload
+	}
+	if _, exists := r.versions[newName]; exists {
 		return ErrPathOverload
 	}
+	if err := r.checkFilePath(newName); err != nil {
+		return err
+	}
+	if r.portfolioForSiaPath(currentName) != r.portfolioForSiaPath(newName) {
+		return errRenameAcrossPortfolios
+	}
+	if rc, exists := r.redundancyChanges[currentName]; exists && rc.newVersion != nil {
+		return errRedundancyChangeInProgress
+	}
+	return nil
+}
 
-	// Modify the file and save it to disk.
+// renameFile moves the file at currentName to newName, which must have been
+// checked with checkRename. The file is saved under newName, but the renter
+// persistence is not saved and the .sia file and piece history at currentName
+// are not removed. The caller must hold the renter lock.
+func (r *Renter) renameFile(currentName, newName string) error {
+	file := r.files[currentName]
+
+	// Modify the file and save it to disk. The piece history is loaded first
+	// so that it is saved under the new name.
 	file.mu.Lock()
+	if err := r.loadPieceHistory(file); err != nil {
+		file.mu.Unlock()
+		return err
+	}
 	file.name = newName
 	err := r.saveFile(file)
+	if err != nil {
+		file.name = currentName
+	}
 	file.mu.Unlock()
 	if err != nil {
 		return err
 	}
+	if err := r.renameVersions(currentName, newName); err != nil {
+		// Keep the file under its current name, the versions that were
+		// renamed already are restored by renameVersions.
+		file.mu.Lock()
+		file.name = currentName
+		file.mu.Unlock()
+		os.Remove(r.pieceHistoryPath(newName))
+		os.RemoveAll(filepath.Join(r.persistDir, newName+ShareExtension))
+		return err
+	}
 
 	// Update the entries in the renter.
 	delete(r.files, currentName)
@@ -281,12 +552,12 @@ func (r *Renter) RenameFile(currentName, newName string) error {
 		delete(r.tracking, currentName)
 		r.tracking[newName] = t
 	}
-	err = r.saveSync()
-	if err != nil {
-		return err
-	}
+	return nil
+}
 
-	// Delete the old .sia file.
-	oldPath := filepath.Join(r.persistDir, currentName+ShareExtension)
-	return os.RemoveAll(oldPath)
+// removeRenamedFile deletes the .sia file and piece history that a renamed
+// file left at its previous name.
+func (r *Renter) removeRenamedFile(name string) error {
+	os.Remove(r.pieceHistoryPath(name))
+	return os.RemoveAll(filepath.Join(r.persistDir, name+ShareExtension))
 }
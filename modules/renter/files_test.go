@@ -3,8 +3,12 @@ package renter
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"testing"
+	"time"
 
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -326,6 +330,58 @@ func TestRenterFileList(t *testing.T) {
 	}
 }
 
+// TestRenterFileSearch probes the FileSearch method of the renter.
+func TestRenterFileSearch(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Add files that were uploaded a day apart, and one without an upload
+	// time.
+	now := time.Now()
+	for i, name := range []string{"foo/a", "foo/b", "foo/bar/c", "d"} {
+		f := newTestingFile()
+		f.name = name
+		rt.renter.files[name] = f
+		if name != "d" {
+			rt.renter.tracking[name] = trackedFile{UploadTime: now.Add(time.Duration(i-3) * 24 * time.Hour)}
+		}
+	}
+
+	search := func(query string, opts modules.SearchOptions) []string {
+		var names []string
+		for _, fi := range rt.renter.FileSearch(query, opts) {
+			names = append(names, fi.SiaPath)
+		}
+		sort.Strings(names)
+		return names
+	}
+	tests := []struct {
+		query string
+		opts  modules.SearchOptions
+		want  []string
+	}{
+		{"", modules.SearchOptions{}, []string{"d", "foo/a", "foo/b", "foo/bar/c"}},
+		{"foo/*", modules.SearchOptions{}, []string{"foo/a", "foo/b"}},
+		{"foo/ba[rz]/?", modules.SearchOptions{}, []string{"foo/bar/c"}},
+		{"[", modules.SearchOptions{}, nil},
+		{"", modules.SearchOptions{UploadedAfter: now.Add(-36 * time.Hour)}, []string{"foo/bar/c"}},
+		{"", modules.SearchOptions{UploadedBefore: now.Add(-36 * time.Hour)}, []string{"foo/a", "foo/b"}},
+		{"foo/*", modules.SearchOptions{UploadedAfter: now.Add(-60 * time.Hour), UploadedBefore: now}, []string{"foo/b"}},
+		{"", modules.SearchOptions{MinRedundancy: 0.5}, nil},
+	}
+	for _, test := range tests {
+		if got := search(test.query, test.opts); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("search for %q with %+v: expected %v, got %v", test.query, test.opts, test.want, got)
+		}
+	}
+}
+
 // TestRenterRenameFile probes the rename method of the renter.
 func TestRenterRenameFile(t *testing.T) {
 	if testing.Short() {
@@ -374,8 +430,18 @@ func TestRenterRenameFile(t *testing.T) {
 		t.Error("Expecting ErrPathOverload, got", err)
 	}
 
+	// Rename a file to invalid names.
+	for _, name := range []string{"", "/1c", "../1c", "a/../../1c"} {
+		if err := rt.renter.RenameFile("1", name); err == nil {
+			t.Errorf("renaming to %q should have failed", name)
+		}
+	}
+	if _, exists := rt.renter.files["1"]; !exists || f2.name != "1" {
+		t.Error("failed rename changed the file")
+	}
+
 	// Renaming should also update the tracking set
-	rt.renter.tracking["1"] = trackedFile{"foo"}
+	rt.renter.tracking["1"] = trackedFile{RepairPath: "foo"}
 	err = rt.renter.RenameFile("1", "1b")
 	if err != nil {
 		t.Fatal(err)
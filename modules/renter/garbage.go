@@ -0,0 +1,113 @@
+package renter
+
+// garbage.go finds the renter's abandoned contracts. A contract is abandoned
+// if it stores sectors, but none of them are referenced by the renter's files,
+// their previous versions, or their redundancy changes, which happens once
+// every file that was uploaded to the contract has been deleted. Renewing an
+// abandoned contract only pays for data that is no longer used, so the
+// contractor of the portfolio is told not to renew it. Contracts without
+// sectors are kept for future uploads. The abandoned contracts are found again
+// every garbageCollectionInterval, so a contract that is uploaded to is
+// renewed again.
+
+import (
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// threadedContractGarbageCollector periodically marks the abandoned contracts
+// of every portfolio for non-renewal.
+func (r *Renter) threadedContractGarbageCollector() {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	for {
+		r.managedCollectGarbageContracts()
+		select {
+		case <-r.tg.StopChan():
+			return
+		case <-time.After(garbageCollectionInterval):
+		}
+	}
+}
+
+// managedCollectGarbageContracts finds the abandoned contracts of every
+// portfolio, and passes them to the contractor of the portfolio.
+func (r *Renter) managedCollectGarbageContracts() {
+	// The contracts are listed before the files are, so that a contract whose
+	// first piece is uploaded in between is not abandoned.
+	contractors := r.managedPortfolioContractors()
+	contracts := make(map[string][]types.FileContractID, len(contractors))
+	for name, hc := range contractors {
+		for _, c := range hc.Contracts() {
+			if len(c.MerkleRoots) > 0 {
+				contracts[name] = append(contracts[name], c.ID)
+			}
+		}
+	}
+
+	// Collect the contracts that the pieces of the files are stored in, by
+	// portfolio. The IDs are resolved to the most recent renewal once the
+	// renter lock has been released.
+	fileContracts := make(map[string][]types.FileContractID, len(contractors))
+	addFile := func(f *file) {
+		name := r.portfolioForSiaPath(f.name)
+		f.mu.RLock()
+		for id, fc := range f.contracts {
+			if len(fc.Pieces) > 0 {
+				fileContracts[name] = append(fileContracts[name], id)
+			}
+		}
+		f.mu.RUnlock()
+	}
+	lockID := r.mu.RLock()
+	for _, f := range r.files {
+		addFile(f)
+	}
+	for _, fv := range r.versions {
+		for _, v := range fv.Previous {
+			addFile(v.file)
+		}
+	}
+	for _, rc := range r.redundancyChanges {
+		if rc.newVersion != nil {
+			addFile(rc.newVersion)
+		}
+	}
+	r.mu.RUnlock(lockID)
+
+	var garbage int
+	for name, hc := range contractors {
+		referenced := make(map[types.FileContractID]struct{}, len(fileContracts[name]))
+		for _, id := range fileContracts[name] {
+			referenced[hc.ResolveID(id)] = struct{}{}
+		}
+		var ids []types.FileContractID
+		for _, id := range contracts[name] {
+			if _, exists := referenced[id]; !exists {
+				ids = append(ids, id)
+			}
+		}
+		hc.SetGarbageContracts(ids)
+		garbage += len(ids)
+	}
+
+	lockID = r.mu.Lock()
+	if garbage != r.garbageContracts {
+		r.log.Printf("%v abandoned contracts will not be renewed\n", garbage)
+	}
+	r.garbageContracts = garbage
+	r.mu.Unlock(lockID)
+}
+
+// GarbageContractCount returns the number of contracts that store sectors,
+// none of which are used by the renter's files, and which are therefore not
+// renewed.
+func (r *Renter) GarbageContractCount() int {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	return r.garbageContracts
+}
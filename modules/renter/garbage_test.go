@@ -0,0 +1,95 @@
+package renter
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// garbageContractor is a throttledContractor that records the garbage
+// contracts that it is given.
+type garbageContractor struct {
+	throttledContractor
+	garbage []types.FileContractID
+	mu      sync.Mutex
+}
+
+func (gc *garbageContractor) SetGarbageContracts(ids []types.FileContractID) {
+	gc.mu.Lock()
+	gc.garbage = ids
+	gc.mu.Unlock()
+}
+
+// TestContractGarbageCollection checks that only contracts that store
+// sectors, none of which are used by a file or a previous version of a file,
+// are found to be garbage.
+func TestContractGarbageCollection(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	roots := modules.MerkleRootSet{crypto.Hash{1}}
+	gc := &garbageContractor{throttledContractor: throttledContractor{uploadTime: time.Millisecond}}
+	for i, contractRoots := range []modules.MerkleRootSet{roots, roots, roots, nil} {
+		gc.contracts = append(gc.contracts, modules.RenterContract{
+			ID:          types.FileContractID{byte(i + 1)},
+			MerkleRoots: contractRoots,
+		})
+	}
+	rt, err := newContractorTester(t.Name(), nil, gc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// The first contract is used by a file, and the second by a previous
+	// version of a file. The third contract is abandoned, and the fourth is
+	// empty.
+	rsc, _ := NewRSCode(1, 1)
+	piece := []pieceData{{MerkleRoot: roots[0]}}
+	current := newFile("foo", rsc, 64, 64)
+	current.contracts[types.FileContractID{1}] = fileContract{ID: types.FileContractID{1}, Pieces: piece}
+	previous := newFile("foo", rsc, 64, 64)
+	previous.contracts[types.FileContractID{2}] = fileContract{ID: types.FileContractID{2}, Pieces: piece}
+	id := rt.renter.mu.Lock()
+	rt.renter.files[current.name] = current
+	rt.renter.versions[current.name] = &fileVersions{Current: 1, Previous: []*fileVersion{{file: previous}}}
+	rt.renter.mu.Unlock(id)
+
+	// The garbage collector of the renter may still be running its first
+	// pass, which started before the files were added.
+	err = build.Retry(10, 100*time.Millisecond, func() error {
+		rt.renter.managedCollectGarbageContracts()
+		gc.mu.Lock()
+		garbage := gc.garbage
+		gc.mu.Unlock()
+		if len(garbage) != 1 || garbage[0] != (types.FileContractID{3}) {
+			return fmt.Errorf("wrong garbage contracts: %v", garbage)
+		}
+		if n := rt.renter.GarbageContractCount(); n != 1 {
+			return fmt.Errorf("wrong garbage contract count: %v", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Once the file and its versions are gone, the first two contracts are
+	// abandoned as well.
+	id = rt.renter.mu.Lock()
+	delete(rt.renter.files, current.name)
+	delete(rt.renter.versions, current.name)
+	rt.renter.mu.Unlock(id)
+	rt.renter.managedCollectGarbageContracts()
+	if n := rt.renter.GarbageContractCount(); n != 3 {
+		t.Fatal("wrong garbage contract count after deleting the file:", n)
+	}
+}
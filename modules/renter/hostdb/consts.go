@@ -22,6 +22,11 @@ const (
 	// scan.
 	hostScanDeadline = 4 * time.Minute
 
+	// maxHostsPageSize is the largest number of hosts that can be requested
+	// on a page of AllHostsPaged. It keeps the arithmetic of the pages from
+	// overflowing.
+	maxHostsPageSize = 1e6
+
 	// maxHostDowntime specifies the maximum amount of time that a host is
 	// allowed to be offline while still being in the hostdb.
 	maxHostDowntime = 10 * 24 * time.Hour
@@ -5,10 +5,12 @@
 package hostdb
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 
 	"github.com/NebulousLabs/Sia/build"
@@ -22,6 +24,10 @@ import (
 var (
 	errNilCS      = errors.New("cannot create hostdb with nil consensus set")
 	errNilGateway = errors.New("cannot create hostdb with nil gateway")
+
+	// errInvalidPage is returned by AllHostsPaged if a negative page or a
+	// page size outside of [1, maxHostsPageSize] is requested.
+	errInvalidPage = fmt.Errorf("page must not be negative and page size must be between 1 and %v", maxHostsPageSize)
 )
 
 // The HostDB is a database of potential hosts. It assigns a weight to each
@@ -200,10 +206,38 @@ func (hdb *HostDB) ActiveHosts() (activeHosts []modules.HostDBEntry) {
 
 // AllHosts returns all of the hosts known to the hostdb, including the
 // inactive ones.
+//
+// Deprecated: on large networks the result can be very large, use
+// AllHostsPaged instead.
 func (hdb *HostDB) AllHosts() (allHosts []modules.HostDBEntry) {
 	return hdb.hostTree.All()
 }
 
+// AllHostsPaged returns the hosts on the provided page of the hosts known to
+// the hostdb, along with the total number of hosts. The hosts are sorted by
+// their public key, so that the pages do not change when the weights of the
+// hosts change between two calls. Pages are numbered from 0. A page past the
+// last host is empty.
+func (hdb *HostDB) AllHostsPaged(page, pageSize int) ([]modules.HostDBEntry, int, error) {
+	if page < 0 || pageSize < 1 || pageSize > maxHostsPageSize {
+		return nil, 0, errInvalidPage
+	}
+	hosts := hdb.hostTree.All()
+	sort.Slice(hosts, func(i, j int) bool {
+		return bytes.Compare(hosts[i].PublicKey.Key, hosts[j].PublicKey.Key) < 0
+	})
+	total := len(hosts)
+	if page >= (total+pageSize-1)/pageSize {
+		return nil, total, nil
+	}
+	start := page * pageSize
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+	return hosts[start:end], total, nil
+}
+
 // AverageContractPrice returns the average price of a host.
 func (hdb *HostDB) AverageContractPrice() (totalPrice types.Currency) {
 	sampleSize := 32
@@ -1,6 +1,7 @@
 package hostdb
 
 import (
+	"bytes"
 	"io/ioutil"
 	"math"
 	"os"
@@ -159,6 +160,58 @@ func TestAverageContractPrice(t *testing.T) {
 	}
 }
 
+// TestAllHostsPaged checks that paging through the hosts of the hostdb returns
+// every host once, sorted by public key.
+func TestAllHostsPaged(t *testing.T) {
+	hdb := bareHostDB()
+	for i := 0; i < 7; i++ {
+		hdb.hostTree.Insert(makeHostDBEntry())
+	}
+	all := hdb.AllHosts()
+
+	var paged []modules.HostDBEntry
+	for page := 0; ; page++ {
+		hosts, total, err := hdb.AllHostsPaged(page, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 7 {
+			t.Fatal("expected a total of 7 hosts, got", total)
+		}
+		if len(hosts) == 0 {
+			if page != 3 {
+				t.Fatal("expected 3 pages, got", page)
+			}
+			break
+		}
+		paged = append(paged, hosts...)
+	}
+	if len(paged) != len(all) {
+		t.Fatalf("expected %v hosts, got %v", len(all), len(paged))
+	}
+	seen := make(map[string]bool)
+	for i := range paged {
+		if i > 0 && bytes.Compare(paged[i-1].PublicKey.Key, paged[i].PublicKey.Key) >= 0 {
+			t.Fatal("paged hosts are not sorted by public key")
+		}
+		seen[paged[i].PublicKey.String()] = true
+	}
+	for _, host := range all {
+		if !seen[host.PublicKey.String()] {
+			t.Fatal("host is missing from the pages:", host.PublicKey)
+		}
+	}
+
+	for _, params := range [][2]int{{-1, 3}, {0, 0}, {0, maxHostsPageSize + 1}} {
+		if _, _, err := hdb.AllHostsPaged(params[0], params[1]); err != errInvalidPage {
+			t.Fatalf("expected errInvalidPage for %v, got %v", params, err)
+		}
+	}
+	if hosts, total, err := hdb.AllHostsPaged(math.MaxInt32, maxHostsPageSize); err != nil || len(hosts) != 0 || total != 7 {
+		t.Fatal("expected an empty page past the last host, got", len(hosts), total, err)
+	}
+}
+
 // TestNew tests the New function.
 func TestNew(t *testing.T) {
 	if testing.Short() {
@@ -1,7 +1,18 @@
 package hosttree
 
+import (
+	"bytes"
+)
+
+// byWeight sorts hosts by their weight. Hosts with the same weight are sorted
+// by their public key, so that the order is the same in every call.
 type byWeight []hostEntry
 
-func (he byWeight) Len() int           { return len(he) }
-func (he byWeight) Less(i, j int) bool { return he[i].weight.Cmp(he[j].weight) < 0 }
-func (he byWeight) Swap(i, j int)      { he[i], he[j] = he[j], he[i] }
+func (he byWeight) Len() int { return len(he) }
+func (he byWeight) Less(i, j int) bool {
+	if c := he[i].weight.Cmp(he[j].weight); c != 0 {
+		return c < 0
+	}
+	return bytes.Compare(he[i].PublicKey.Key, he[j].PublicKey.Key) < 0
+}
+func (he byWeight) Swap(i, j int) { he[i], he[j] = he[j], he[i] }
@@ -382,23 +382,30 @@ func (hdb *HostDB) uptimeAdjustments(entry modules.HostDBEntry) float64 {
 	return math.Pow(uptimeRatio, exp)
 }
 
-// calculateHostWeight returns the weight of a host according to the settings of
-// the host database entry.
-func (hdb *HostDB) calculateHostWeight(entry modules.HostDBEntry) types.Currency {
-	collateralReward := hdb.collateralAdjustments(entry)
-	interactionPenalty := hdb.interactionAdjustments(entry)
-	lifetimePenalty := hdb.lifetimeAdjustments(entry)
-	pricePenalty := hdb.priceAdjustments(entry)
-	storageRemainingPenalty := storageRemainingAdjustments(entry)
-	uptimePenalty := hdb.uptimeAdjustments(entry)
-	versionPenalty := versionAdjustments(entry)
+// hostScore returns the adjustments of a host according to the settings of the
+// host database entry.
+func (hdb *HostDB) hostScore(entry modules.HostDBEntry) modules.HostScore {
+	hs := modules.HostScore{
+		CollateralScore:       hdb.collateralAdjustments(entry),
+		InteractionScore:      hdb.interactionAdjustments(entry),
+		AgeScore:              hdb.lifetimeAdjustments(entry),
+		PriceAdjustedScore:    hdb.priceAdjustments(entry),
+		StorageRemainingScore: storageRemainingAdjustments(entry),
+		UptimeScore:           hdb.uptimeAdjustments(entry),
+		VersionScore:          versionAdjustments(entry),
+	}
 
 	// Combine the adjustments.
-	fullPenalty := collateralReward * interactionPenalty * lifetimePenalty *
-		pricePenalty * storageRemainingPenalty * uptimePenalty * versionPenalty
+	hs.CompositeScore = hs.CollateralScore * hs.InteractionScore * hs.AgeScore *
+		hs.PriceAdjustedScore * hs.StorageRemainingScore * hs.UptimeScore * hs.VersionScore
+	return hs
+}
 
+// calculateHostWeight returns the weight of a host according to the settings of
+// the host database entry.
+func (hdb *HostDB) calculateHostWeight(entry modules.HostDBEntry) types.Currency {
 	// Return a types.Currency.
-	weight := baseWeight.MulFloat(fullPenalty)
+	weight := baseWeight.MulFloat(hdb.hostScore(entry).CompositeScore)
 	if weight.IsZero() {
 		// A weight of zero is problematic for for the host tree.
 		return types.NewCurrency64(1)
@@ -478,3 +485,18 @@ func (hdb *HostDB) ScoreBreakdown(entry modules.HostDBEntry) modules.HostScoreBr
 		VersionAdjustment:          versionAdjustments(entry),
 	}
 }
+
+// HostScores returns the adjustments of every host in the hostdb, sorted by
+// increasing weight.
+func (hdb *HostDB) HostScores() []modules.HostScore {
+	hdb.mu.Lock()
+	defer hdb.mu.Unlock()
+
+	var scores []modules.HostScore
+	for _, entry := range hdb.hostTree.All() {
+		hs := hdb.hostScore(entry)
+		hs.PublicKey = entry.PublicKey
+		scores = append(scores, hs)
+	}
+	return scores
+}
@@ -284,3 +284,47 @@ func TestHostWeightUptimeDifferences4(t *testing.T) {
 		t.Error("Been around longer should have more weight")
 	}
 }
+
+// TestHostScores checks that the scores of the hosts in the hostdb match their
+// score breakdowns, and that the composite score determines the weight.
+func TestHostScores(t *testing.T) {
+	hdb := bareHostDB()
+	if scores := hdb.HostScores(); len(scores) != 0 {
+		t.Fatal("expected no scores for an empty hostdb, got", scores)
+	}
+
+	h1 := makeHostDBEntry()
+	h1.RemainingStorage = 250e3
+	h1.StoragePrice = types.NewCurrency64(300).Mul(types.SiacoinPrecision).Div64(4032).Div64(1e9)
+	h2 := makeHostDBEntry()
+	h2.RemainingStorage = 250e3
+	h2.StoragePrice = h1.StoragePrice.Mul64(2)
+	hdb.hostTree.Insert(h1)
+	hdb.hostTree.Insert(h2)
+
+	scores := hdb.HostScores()
+	if len(scores) != 2 {
+		t.Fatal("expected the scores of 2 hosts, got", len(scores))
+	}
+	// The scores are sorted by increasing weight, and the expensive host has
+	// the lower weight.
+	for i, entry := range []modules.HostDBEntry{h2, h1} {
+		hs := scores[i]
+		if hs.PublicKey.String() != entry.PublicKey.String() {
+			t.Fatalf("expected the score of %v at index %v, got %v", entry.PublicKey, i, hs.PublicKey)
+		}
+		sb := hdb.ScoreBreakdown(entry)
+		if hs.PriceAdjustedScore != sb.PriceAdjustment || hs.AgeScore != sb.AgeAdjustment ||
+			hs.UptimeScore != sb.UptimeAdjustment || hs.VersionScore != sb.VersionAdjustment ||
+			hs.InteractionScore != sb.InteractionAdjustment || hs.CollateralScore != sb.CollateralAdjustment ||
+			hs.StorageRemainingScore != sb.StorageRemainingAdjustment {
+			t.Errorf("host score %+v does not match score breakdown %+v", hs, sb)
+		}
+		if baseWeight.MulFloat(hs.CompositeScore).Cmp(sb.Score) != 0 {
+			t.Error("composite score does not determine the weight of the host")
+		}
+	}
+	if s1, s2 := scores[1], scores[0]; s1.CompositeScore <= s2.CompositeScore {
+		t.Error("expensive host should have a lower composite score")
+	}
+}
@@ -1,6 +1,7 @@
 package renter
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"encoding/base64"
@@ -38,6 +39,14 @@ var (
 	shareVersion = "0.4"
 )
 
+// sharedFileMetadata is the metadata of a shared file that is not part of the
+// encoding of the file. The metadata of every file is written after the
+// files, where older versions, which stop reading after the files, ignore it.
+type sharedFileMetadata struct {
+	Checksum      modules.FileChecksum
+	AuditRequired bool
+}
+
 // MarshalSia implements the encoding.SiaMarshaller interface, writing the
 // file data to w.
 func (f *file) MarshalSia(w io.Writer) error {
@@ -152,17 +161,31 @@ func (f *file) UnmarshalSia(r io.Reader) error {
 	return nil
 }
 
-// saveFile saves a file to the renter directory.
+// saveFile saves a file and its piece history to the renter directory.
 func (r *Renter) saveFile(f *file) error {
+	if err := r.saveSiaFile(f); err != nil {
+		return err
+	}
+	return r.savePieceHistory(f)
+}
+
+// saveSiaFile saves the .sia file of a file to the renter directory, without
+// its piece history.
+func (r *Renter) saveSiaFile(f *file) error {
 	// Create directory structure specified in nickname.
 	fullPath := filepath.Join(r.persistDir, f.name+ShareExtension)
+	if f.newVersion {
+		fullPath = r.newVersionPath(f.name)
+	} else if f.version != 0 {
+		fullPath = r.versionPath(f.name, f.version)
+	}
 	err := os.MkdirAll(filepath.Dir(fullPath), 0700)
 	if err != nil {
 		return err
 	}
 
 	// Open SafeFile handle.
-	handle, err := persist.NewSafeFile(filepath.Join(r.persistDir, f.name+ShareExtension))
+	handle, err := persist.NewSafeFile(fullPath)
 	if err != nil {
 		return err
 	}
@@ -180,9 +203,21 @@ func (r *Renter) saveFile(f *file) error {
 
 // saveSync stores the current renter data to disk and then syncs to disk.
 func (r *Renter) saveSync() error {
+	cacheSize := r.cache.managedMaxSize()
 	data := struct {
-		Tracking map[string]trackedFile
-	}{r.tracking}
+		Tracking          map[string]trackedFile
+		UploadRetryPolicy modules.UploadRetryPolicy
+		MaxDownloadSpeed  int64
+		Portfolios        []string
+		AllowanceHistory  []modules.AllowanceRecord
+		RedundancyChanges []*redundancyChange
+		CacheSize         *uint64
+		Versions          map[string]*fileVersions
+		Directories       []string
+		ChecksumAlgorithm string
+		MaxUploadSpeed    int64
+		VersionRetention  map[string]modules.VersionRetention
+	}{r.tracking, r.uploadRetryPolicy, r.downloadLimit.Limit(), r.portfolioNames(), r.allowanceHistory, r.unfinishedRedundancyChanges(), &cacheSize, r.versions, r.dirNames(), r.uploadChecksumAlgorithm, r.uploadLimit.Limit(), r.versionRetention}
 
 	return persist.SaveJSON(saveMetadata, data, filepath.Join(r.persistDir, PersistFilename))
 }
@@ -226,8 +261,19 @@ func (r *Renter) load() error {
 
 	// Load contracts, repair set, and entropy.
 	data := struct {
-		Tracking  map[string]trackedFile
-		Repairing map[string]string // COMPATv0.4.8
+		Tracking          map[string]trackedFile
+		Repairing         map[string]string // COMPATv0.4.8
+		UploadRetryPolicy modules.UploadRetryPolicy
+		MaxDownloadSpeed  int64
+		Portfolios        []string
+		AllowanceHistory  []modules.AllowanceRecord
+		RedundancyChanges []*redundancyChange
+		CacheSize         *uint64 // nil if the renter was saved without a disk cache
+		Versions          map[string]*fileVersions
+		Directories       []string // nil if the renter was saved with a flat file list
+		ChecksumAlgorithm string
+		MaxUploadSpeed    int64
+		VersionRetention  map[string]modules.VersionRetention
 	}{}
 	err = persist.LoadJSON(saveMetadata, &data, filepath.Join(r.persistDir, PersistFilename))
 	if err != nil {
@@ -236,12 +282,34 @@ func (r *Renter) load() error {
 	if data.Tracking != nil {
 		r.tracking = data.Tracking
 	}
+	r.uploadRetryPolicy = data.UploadRetryPolicy
+	r.downloadLimit.SetLimit(data.MaxDownloadSpeed)
+	r.uploadLimit.SetLimit(data.MaxUploadSpeed)
+	r.uploadChecksumAlgorithm = data.ChecksumAlgorithm
+	r.allowanceHistory = data.AllowanceHistory
+	r.loadRedundancyChanges(data.RedundancyChanges)
+	r.loadVersions(data.Versions)
+	if data.VersionRetention != nil {
+		r.versionRetention = data.VersionRetention
+	}
+	if data.CacheSize != nil {
+		r.cache.managedSetMaxSize(*data.CacheSize)
+	}
+	if data.Directories == nil {
+		// COMPATv1.3.1 - create the directories of the files of a flat file
+		// list.
+		r.migrateDirs()
+	}
+	for _, dir := range data.Directories {
+		r.dirs[dir] = struct{}{}
+	}
 
-	return nil
+	return r.loadPortfolios(data.Portfolios)
 }
 
 // shareFiles writes the specified files to w. First a header is written,
-// followed by the gzipped concatenation of each file.
+// followed by the gzipped concatenation of each file and the metadata of the
+// files.
 func shareFiles(files []*file, w io.Writer) error {
 	// Write header.
 	err := encoding.NewEncoder(w).EncodeAll(
@@ -265,6 +333,18 @@ func shareFiles(files []*file, w io.Writer) error {
 		}
 	}
 
+	// Encode the metadata of the files.
+	metadata := make([]sharedFileMetadata, len(files))
+	for i, f := range files {
+		metadata[i] = sharedFileMetadata{
+			Checksum:      f.checksum,
+			AuditRequired: f.auditRequired,
+		}
+	}
+	if err := enc.Encode(metadata); err != nil {
+		return err
+	}
+
 	return zip.Close()
 }
 
@@ -327,9 +407,8 @@ func (r *Renter) ShareFilesAscii(nicknames []string) (string, error) {
 	return buf.String(), nil
 }
 
-// loadSharedFiles reads .sia data from reader and registers the contained
-// files in the renter. It returns the nicknames of the loaded files.
-func (r *Renter) loadSharedFiles(reader io.Reader) ([]string, error) {
+// readSharedFiles reads the files contained in the .sia data from reader.
+func readSharedFiles(reader io.Reader) ([]*file, error) {
 	// read header
 	var header [15]byte
 	var version string
@@ -352,7 +431,8 @@ func (r *Renter) loadSharedFiles(reader io.Reader) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	dec := encoding.NewDecoder(unzip)
+	buf := bufio.NewReader(unzip)
+	dec := encoding.NewDecoder(buf)
 
 	// Read each file.
 	files := make([]*file, numFiles)
@@ -362,7 +442,34 @@ func (r *Renter) loadSharedFiles(reader io.Reader) ([]string, error) {
 		if err != nil {
 			return nil, err
 		}
+	}
+
+	// Read the metadata of the files. Files that were shared by older
+	// versions do not have metadata.
+	if _, err := buf.Peek(1); err == io.EOF {
+		return files, nil
+	}
+	var metadata []sharedFileMetadata
+	if err := dec.Decode(&metadata); err != nil {
+		return nil, err
+	} else if len(metadata) != len(files) {
+		return nil, ErrBadFile
+	}
+	for i, f := range files {
+		f.checksum = metadata[i].Checksum
+		f.auditRequired = metadata[i].AuditRequired
+	}
+	return files, nil
+}
 
+// loadSharedFiles reads .sia data from reader and registers the contained
+// files in the renter. It returns the nicknames of the loaded files.
+func (r *Renter) loadSharedFiles(reader io.Reader) ([]string, error) {
+	files, err := readSharedFiles(reader)
+	if err != nil {
+		return nil, err
+	}
+	for i := range files {
 		// Make sure the file's name does not conflict with existing files.
 		dupCount := 0
 		origName := files[i].name
@@ -377,7 +484,7 @@ func (r *Renter) loadSharedFiles(reader io.Reader) ([]string, error) {
 	}
 
 	// Add files to renter.
-	names := make([]string, numFiles)
+	names := make([]string, len(files))
 	for i, f := range files {
 		r.files[f.name] = f
 		names[i] = f.name
@@ -405,12 +512,19 @@ func (r *Renter) initPersist() error {
 		return err
 	}
 
-	// Load the prior persistence structures.
+	// Load the prior persistence structures, including the size of the disk
+	// cache.
+	r.cache = newDiskCache(filepath.Join(r.persistDir, cacheDir), defaultCacheSize)
 	err = r.load()
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
-	return nil
+
+	// Load the disk cache, which uses the persisted cache size.
+	if err := r.cache.load(); err != nil {
+		return err
+	}
+	return r.initUsage()
 }
 
 // LoadSharedFiles loads a .sia file into the renter. It returns the nicknames
@@ -11,6 +11,8 @@ import (
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 	"github.com/NebulousLabs/fastrand"
 )
 
@@ -229,6 +231,241 @@ func TestRenterSaveLoad(t *testing.T) {
 	}
 }
 
+// TestAllowanceHistory checks that changes of the allowance are recorded in
+// the allowance history, that the history is capped, and that the history
+// survives a restart.
+func TestAllowanceHistory(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	if len(rt.renter.AllowanceHistory()) != 0 {
+		t.Fatal("fresh renter has an allowance history")
+	}
+
+	// Setting the empty allowance of the default portfolio, and creating a
+	// portfolio with an empty allowance, do not change any allowance.
+	err = rt.renter.SetSettings(modules.RenterSettingsUpdate{Allowance: &modules.Allowance{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rt.renter.SetPortfolio(modules.RenterPortfolio{Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A failed change should not be recorded either.
+	allowance := modules.Allowance{
+		Funds:       types.SiacoinPrecision.Mul64(100),
+		Hosts:       1,
+		Period:      100,
+		RenewWindow: 10,
+	}
+	err = rt.renter.SetSettings(modules.RenterSettingsUpdate{Allowance: &allowance})
+	if err == nil {
+		t.Fatal("expected the allowance to be rejected without hosts")
+	}
+	if len(rt.renter.AllowanceHistory()) != 0 {
+		t.Fatal("unchanged allowances were recorded:", rt.renter.AllowanceHistory())
+	}
+
+	// The renter has no hosts, so record the successful changes directly.
+	// Only the most recent maxAllowanceHistory changes should be kept.
+	for i := 0; i < maxAllowanceHistory+2; i++ {
+		old := allowance
+		allowance.Hosts++
+		err = rt.renter.managedRecordAllowanceChange("alice", old, allowance)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkHistory := func(history []modules.AllowanceRecord) {
+		if len(history) != maxAllowanceHistory {
+			t.Fatalf("expected %v allowance records, got %v", maxAllowanceHistory, len(history))
+		}
+		for i, record := range history {
+			if record.Portfolio != "alice" || record.NewAllowance.Hosts != uint64(i+4) || record.OldAllowance.Hosts != uint64(i+3) {
+				t.Error("record is wrong:", record)
+			}
+			if record.Height != rt.cs.Height() || record.ChangedAt.IsZero() {
+				t.Error("record has the wrong time:", record.Height, record.ChangedAt)
+			}
+		}
+	}
+	checkHistory(rt.renter.AllowanceHistory())
+
+	// Restart the renter, the history should have been persisted.
+	err = rt.renter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, rt.renter.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHistory(rt.renter.AllowanceHistory())
+}
+
+// TestMaxDownloadSpeed checks that the download speed limit of the renter is
+// validated, applied to the shared download limit, and persisted.
+func TestMaxDownloadSpeed(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	speed := int64(-1)
+	if err := rt.renter.SetSettings(modules.RenterSettingsUpdate{MaxDownloadSpeed: &speed}); err != errDownloadSpeed {
+		t.Fatal("expected errDownloadSpeed, got", err)
+	}
+	speed = 1e6
+	if err := rt.renter.SetSettings(modules.RenterSettingsUpdate{MaxDownloadSpeed: &speed}); err != nil {
+		t.Fatal(err)
+	}
+	if speed := rt.renter.Settings().MaxDownloadSpeed; speed != 1e6 {
+		t.Fatal("wrong max download speed:", speed)
+	}
+	if limit := rt.renter.downloadLimit.Limit(); limit != 1e6 {
+		t.Fatal("download limit was not changed:", limit)
+	}
+
+	// Restart the renter, the speed should have been persisted.
+	err = rt.renter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, rt.renter.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if speed := rt.renter.Settings().MaxDownloadSpeed; speed != 1e6 {
+		t.Fatal("max download speed was not persisted:", speed)
+	}
+}
+
+// TestMaxUploadSpeed checks that the upload speed limit of the renter is
+// validated, applied to the shared upload limit, reported, and persisted.
+func TestMaxUploadSpeed(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	speed := int64(-1)
+	if err := rt.renter.SetSettings(modules.RenterSettingsUpdate{MaxUploadSpeed: &speed}); err != errUploadSpeed {
+		t.Fatal("expected errUploadSpeed, got", err)
+	}
+	speed = 1e6
+	if err := rt.renter.SetSettings(modules.RenterSettingsUpdate{MaxUploadSpeed: &speed}); err != nil {
+		t.Fatal(err)
+	}
+	if speed := rt.renter.Settings().MaxUploadSpeed; speed != 1e6 {
+		t.Fatal("wrong max upload speed:", speed)
+	}
+	if limit := rt.renter.uploadLimit.Limit(); limit != 1e6 {
+		t.Fatal("upload limit was not changed:", limit)
+	}
+	if ts := rt.renter.TransferSpeeds(); ts.MaxUploadSpeed != 1e6 || ts.UploadSpeed != 0 {
+		t.Fatal("wrong transfer speeds:", ts)
+	}
+
+	// Restart the renter, the speed should have been persisted.
+	err = rt.renter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, rt.renter.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if speed := rt.renter.Settings().MaxUploadSpeed; speed != 1e6 {
+		t.Fatal("max upload speed was not persisted:", speed)
+	}
+}
+
+// TestSetSettingsPartial checks that SetSettings only changes the settings
+// that are set, and that an invalid update changes none of them.
+func TestSetSettingsPartial(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	autoRenew, threshold := true, types.BlockHeight(5)
+	err = rt.renter.SetSettings(modules.RenterSettingsUpdate{
+		AutoRenewEnabled:       &autoRenew,
+		RenewalThresholdBlocks: &threshold,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing only the download speed should keep the renewal policy.
+	speed := int64(1e6)
+	if err := rt.renter.SetSettings(modules.RenterSettingsUpdate{MaxDownloadSpeed: &speed}); err != nil {
+		t.Fatal(err)
+	}
+	s := rt.renter.Settings()
+	if !s.AutoRenewEnabled || s.RenewalThresholdBlocks != threshold {
+		t.Fatal("renewal policy was changed by a partial update:", s.AutoRenewEnabled, s.RenewalThresholdBlocks)
+	}
+	if s.MaxDownloadSpeed != 1e6 {
+		t.Fatal("wrong max download speed:", s.MaxDownloadSpeed)
+	}
+
+	// An update with an invalid setting should change none of the others.
+	autoRenew, speed = false, -1
+	err = rt.renter.SetSettings(modules.RenterSettingsUpdate{
+		AutoRenewEnabled: &autoRenew,
+		MaxUploadSpeed:   &speed,
+	})
+	if err != errUploadSpeed {
+		t.Fatal("expected errUploadSpeed, got", err)
+	}
+	if s := rt.renter.Settings(); !s.AutoRenewEnabled || s.MaxUploadSpeed != 0 {
+		t.Fatal("invalid update changed the settings:", s.AutoRenewEnabled, s.MaxUploadSpeed)
+	}
+
+	// An allowance the contractor rejects should not change the renewal
+	// policy either.
+	allowance := modules.Allowance{
+		Funds:       types.SiacoinPrecision.Mul64(100),
+		Hosts:       1,
+		Period:      100,
+		RenewWindow: 10,
+	}
+	err = rt.renter.SetSettings(modules.RenterSettingsUpdate{
+		Allowance:        &allowance,
+		AutoRenewEnabled: &autoRenew,
+	})
+	if err == nil {
+		t.Fatal("expected the allowance to be rejected without hosts")
+	}
+	if !rt.renter.Settings().AutoRenewEnabled {
+		t.Fatal("rejected allowance changed the renewal policy")
+	}
+}
+
 // TestRenterPaths checks that the renter properly handles nicknames
 // containing the path separator ("/").
 func TestRenterPaths(t *testing.T) {
@@ -0,0 +1,176 @@
+package renter
+
+// piecehistory.go records which hosts the pieces of a file were uploaded to,
+// and why pieces were uploaded again during repair. The history is meant for
+// debugging lost or slow files, so it is kept out of the .sia file, which is
+// also used to share files with other renters. Instead it is saved next to
+// the .sia file and only loaded when a piece is uploaded or the history is
+// requested for a single file. The uploads of a chunk's pieces are saved
+// together once the chunk leaves the repair loop, so that the history is not
+// synced to disk for every piece.
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// pieceHistoryExtension is appended to the path of a .sia file to get the
+	// path of the file's piece history.
+	pieceHistoryExtension = ".history"
+
+	// The reasons for replacing a piece that are recorded in the piece
+	// history.
+	pieceLostCircuitBreakerOpen = "circuit breaker of the previous host is open"
+	pieceLostContractCancelled  = "contract with the previous host was cancelled"
+	pieceLostContractGone       = "contract with the previous host no longer exists"
+	pieceLostNotGoodForUpload   = "contract with the previous host is no longer good for upload"
+	pieceLostUnknown            = "piece was missing from the hosts of the file"
+)
+
+var pieceHistoryMetadata = persist.Metadata{
+	Header:  "Renter Piece History",
+	Version: "1.0",
+}
+
+// pieceKey identifies a piece of a file.
+type pieceKey struct {
+	chunk uint64
+	piece uint64
+}
+
+// markLostPieces records the reason why the pieces stored in fc no longer
+// count towards the redundancy of their chunks.
+func markLostPieces(chunks []*unfinishedChunk, fc fileContract, reason string) {
+	for _, piece := range fc.Pieces {
+		if _, exists := chunks[piece.Chunk].lostPieces[piece.Piece]; !exists {
+			chunks[piece.Chunk].lostPieces[piece.Piece] = reason
+		}
+	}
+}
+
+// pieceHistoryPath returns the path of the piece history of the file at
+// siaPath.
+func (r *Renter) pieceHistoryPath(siaPath string) string {
+	return filepath.Join(r.persistDir, siaPath+ShareExtension+pieceHistoryExtension)
+}
+
+// loadPieceHistory loads the piece history of f from disk, unless it has been
+// loaded already. The new version of a file starts with an empty history, as
+// its pieces have nothing to do with the pieces of the current version, and
+// the history of previous versions is not kept. The caller must hold the lock
+// of f.
+func (r *Renter) loadPieceHistory(f *file) error {
+	if f.pieceHistoryLoaded {
+		return nil
+	}
+	f.pieceHistory = make(map[pieceKey][]modules.RenterPieceEvent)
+	f.pieceHistoryLoaded = true
+	if f.newVersion || f.version != 0 {
+		return nil
+	}
+
+	var history []modules.RenterPieceHistory
+	err := persist.LoadJSON(pieceHistoryMetadata, &history, r.pieceHistoryPath(f.name))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, ph := range history {
+		f.pieceHistory[pieceKey{ph.Chunk, ph.Piece}] = ph.Events
+	}
+	return nil
+}
+
+// savePieceHistory saves the piece history of f next to its .sia file. Files
+// whose history has not been loaded are left alone, and the new version of a
+// file only saves its history once it replaces the current version. Previous
+// versions of a file have no history. The caller must hold the lock of f.
+func (r *Renter) savePieceHistory(f *file) error {
+	if !f.pieceHistoryLoaded || f.newVersion || f.version != 0 {
+		return nil
+	}
+	path := r.pieceHistoryPath(f.name)
+	var err error
+	if len(f.pieceHistory) == 0 {
+		err = os.Remove(path)
+		if os.IsNotExist(err) {
+			err = nil
+		}
+	} else {
+		err = persist.SaveJSON(pieceHistoryMetadata, f.filePieceHistory(), path)
+	}
+	if err == nil {
+		f.pieceHistoryDirty = false
+	}
+	return err
+}
+
+// managedSavePieceHistory saves the piece history of f if it has uploads that
+// have not been saved yet.
+func (r *Renter) managedSavePieceHistory(f *file) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.pieceHistoryDirty {
+		return
+	}
+	if err := r.savePieceHistory(f); err != nil {
+		r.log.Println("WARN: unable to save the piece history of", f.name+":", err)
+	}
+}
+
+// filePieceHistory returns the piece history of f, sorted by chunk and piece.
+// The caller must hold the lock of f and have loaded the history.
+func (f *file) filePieceHistory() []modules.RenterPieceHistory {
+	history := make([]modules.RenterPieceHistory, 0, len(f.pieceHistory))
+	for pi, events := range f.pieceHistory {
+		history = append(history, modules.RenterPieceHistory{
+			Chunk:  pi.chunk,
+			Piece:  pi.piece,
+			Events: events,
+		})
+	}
+	sort.Slice(history, func(i, j int) bool {
+		if history[i].Chunk != history[j].Chunk {
+			return history[i].Chunk < history[j].Chunk
+		}
+		return history[i].Piece < history[j].Piece
+	})
+	return history
+}
+
+// recordPieceUpload adds the upload of a piece of uc to the host of the
+// provided contract to the piece history of the chunk's file. Only the most
+// recent maxPieceHistory uploads of a piece are kept. The history is saved by
+// managedSavePieceHistory once the chunk leaves the repair loop. The caller
+// must hold the lock of the file.
+func (r *Renter) recordPieceUpload(uc *unfinishedChunk, piece uint64, hostKey types.SiaPublicKey, id types.FileContractID) {
+	f := uc.renterFile
+	if err := r.loadPieceHistory(f); err != nil {
+		r.log.Println("WARN: unable to load the piece history of", f.name+":", err)
+	}
+	pi := pieceKey{uc.index, piece}
+	events := f.pieceHistory[pi]
+	reason := uc.lostPieces[piece]
+	if reason == "" && len(events) > 0 {
+		reason = pieceLostUnknown
+	}
+	events = append(events, modules.RenterPieceEvent{
+		HostPublicKey: hostKey,
+		ContractID:    id,
+		Timestamp:     time.Now(),
+		Reason:        reason,
+	})
+	if len(events) > maxPieceHistory {
+		events = append([]modules.RenterPieceEvent(nil), events[len(events)-maxPieceHistory:]...)
+	}
+	f.pieceHistory[pi] = events
+	f.pieceHistoryDirty = true
+}
@@ -0,0 +1,128 @@
+package renter
+
+import (
+	"os"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestPieceHistory checks that piece uploads are recorded with the reason for
+// replacing a piece, that the history is capped and saved next to the file
+// once per chunk, and that it follows the file when it is renamed or deleted.
+func TestPieceHistory(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+	r := rt.renter
+
+	f := newTestingFile()
+	f.name = "foo"
+	lockID := r.mu.Lock()
+	r.files[f.name] = f
+	r.mu.Unlock(lockID)
+
+	// Upload piece 1 of chunk 0, then replace it because its contract is gone
+	// and then for an unknown reason.
+	uc := &unfinishedChunk{renterFile: f, lostPieces: make(map[uint64]string)}
+	record := func(id types.FileContractID) {
+		f.mu.Lock()
+		r.recordPieceUpload(uc, 1, types.SiaPublicKey{Key: id[:]}, id)
+		err := r.saveSiaFile(f)
+		f.mu.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.managedSavePieceHistory(f)
+	}
+
+	// The uploads of a piece are only saved once its chunk is done.
+	f.mu.Lock()
+	r.recordPieceUpload(uc, 0, types.SiaPublicKey{}, types.FileContractID{})
+	err = r.saveSiaFile(f)
+	f.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(r.pieceHistoryPath(f.name)); !os.IsNotExist(err) {
+		t.Fatal("history was saved before the chunk was done:", err)
+	}
+	f.mu.Lock()
+	delete(f.pieceHistory, pieceKey{0, 0})
+	f.mu.Unlock()
+
+	record(types.FileContractID{1})
+	if _, err := os.Stat(r.pieceHistoryPath(f.name)); err != nil {
+		t.Fatal("history was not saved:", err)
+	}
+	uc.lostPieces[1] = pieceLostContractGone
+	record(types.FileContractID{2})
+	delete(uc.lostPieces, 1)
+	record(types.FileContractID{3})
+
+	rf, err := r.File(f.name, false)
+	if err != nil {
+		t.Fatal(err)
+	} else if rf.SiaPath != f.name || rf.PieceHistory != nil {
+		t.Fatal("unexpected non-verbose file:", rf)
+	}
+
+	// Forget the history in memory, it should be loaded from disk.
+	f.mu.Lock()
+	f.pieceHistory, f.pieceHistoryLoaded = nil, false
+	f.mu.Unlock()
+	rf, err = r.File(f.name, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rf.PieceHistory) != 1 || rf.PieceHistory[0].Chunk != 0 || rf.PieceHistory[0].Piece != 1 {
+		t.Fatal("unexpected piece history:", rf.PieceHistory)
+	}
+	events := rf.PieceHistory[0].Events
+	if len(events) != 3 {
+		t.Fatal("expected 3 events, got", len(events))
+	}
+	for i, reason := range []string{"", pieceLostContractGone, pieceLostUnknown} {
+		if events[i].Reason != reason || events[i].ContractID != (types.FileContractID{byte(i + 1)}) {
+			t.Errorf("unexpected event %v: %v", i, events[i])
+		}
+	}
+
+	// Only the most recent uploads are kept.
+	record(types.FileContractID{4})
+	rf, err = r.File(f.name, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events = rf.PieceHistory[0].Events
+	if len(events) != maxPieceHistory || events[len(events)-1].ContractID != (types.FileContractID{4}) {
+		t.Fatal("history was not capped:", events)
+	}
+
+	// The history moves with the file when it is renamed, and is removed when
+	// the file is deleted.
+	if err := r.RenameFile("foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(r.pieceHistoryPath("foo")); !os.IsNotExist(err) {
+		t.Fatal("history was not removed from the old path:", err)
+	}
+	if _, err := os.Stat(r.pieceHistoryPath("bar")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.DeleteFile("bar"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(r.pieceHistoryPath("bar")); !os.IsNotExist(err) {
+		t.Fatal("history was not removed:", err)
+	}
+	if _, err := r.File("bar", true); err != ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+}
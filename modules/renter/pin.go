@@ -0,0 +1,102 @@
+package renter
+
+// pin.go implements PinFile, which repairs a single file immediately instead
+// of waiting for the repair loop to rebuild its chunk heap. The file is handed
+// to the repair loop in the same way as a new upload, so the missing pieces of
+// the file are uploaded to the hosts of the file's portfolio that the
+// contractor has selected.
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// errPinRedundancy is returned if PinFile is called with a redundancy that
+	// the file can never reach.
+	errPinRedundancy = errors.New("minimum redundancy must be positive and cannot exceed the redundancy of the file's erasure code")
+)
+
+// managedFileRedundancy returns the current redundancy of a file.
+func (r *Renter) managedFileRedundancy(f *file) float64 {
+	isOffline := r.contractOfflineFunc(r.managedFileContractor(f))
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.redundancy(isOffline)
+}
+
+// PinFile immediately repairs the file at siaPath and blocks until the file
+// has reached minRedundancy. If the redundancy of the file does not improve
+// for pinFileStallTimeout, an error reporting the achieved redundancy is
+// returned.
+func (r *Renter) PinFile(siaPath string, minRedundancy float64) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	id := r.mu.RLock()
+	f, exists := r.files[siaPath]
+	r.mu.RUnlock(id)
+	if !exists {
+		return ErrUnknownPath
+	}
+	f.mu.RLock()
+	maxRedundancy := float64(f.erasureCode.NumPieces()) / float64(f.erasureCode.MinPieces())
+	empty := f.size == 0
+	f.mu.RUnlock()
+	if minRedundancy <= 0 || minRedundancy > maxRedundancy {
+		return errPinRedundancy
+	} else if empty {
+		// Empty files have no pieces that could be repaired.
+		return nil
+	}
+
+	// Hand the file to the repair loop once none of its chunks are being
+	// repaired. The repair loop does not know which pieces are in flight, so
+	// handing the file over during an upload or repair would upload the same
+	// pieces twice. If the redundancy stops improving halfway to the stall
+	// timeout, the repair may have finished without uploading every missing
+	// piece, so the file is handed over once more.
+	best := r.managedFileRedundancy(f)
+	lastProgress := time.Now()
+	var lastQueued time.Time
+	for best < minRedundancy {
+		if time.Since(lastProgress) > pinFileStallTimeout {
+			return fmt.Errorf("file only reached a redundancy of %.2f, wanted %.2f", best, minRedundancy)
+		}
+
+		f.mu.Lock()
+		var queue chan *file
+		if f.chunksInRepair == 0 && (lastQueued.IsZero() || (lastQueued.Before(lastProgress) && time.Since(lastProgress) > pinFileStallTimeout/2)) {
+			queue = r.newUploads
+			f.chunksInRepair++
+		}
+		f.mu.Unlock()
+		select {
+		case queue <- f:
+			lastQueued = time.Now()
+			queue = nil
+		case <-time.After(pinFileCheckInterval):
+		case <-r.tg.StopChan():
+		}
+		if queue != nil {
+			// The file was not sent to the repair loop.
+			f.mu.Lock()
+			f.chunksInRepair--
+			f.mu.Unlock()
+		}
+		select {
+		case <-r.tg.StopChan():
+			return errors.New("renter shut down before the file reached the requested redundancy")
+		default:
+		}
+
+		if redundancy := r.managedFileRedundancy(f); redundancy > best {
+			best = redundancy
+			lastProgress = time.Now()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package renter
+
+// portfolio.go implements allowance portfolios. A portfolio is an allowance
+// with its own contractor, which means that each portfolio has its own budget,
+// contract set, spending metrics, and renewals. All portfolios share the
+// renter's hostdb, but every contract is owned by exactly one portfolio.
+//
+// Files are assigned to a portfolio by their siapath: a file whose siapath
+// begins with "<name>/" belongs to the portfolio called <name>, and all other
+// files belong to the default portfolio. The default portfolio uses the
+// renter's original contractor, so renters that never create a portfolio
+// behave exactly as they did before portfolios existed.
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+const (
+	// portfoliosDir is the directory within the renter's persist directory
+	// that holds the persist directories of the named portfolios.
+	portfoliosDir = "portfolios"
+)
+
+var (
+	// errPortfolioName is returned if a portfolio is given an invalid name.
+	errPortfolioName = errors.New("portfolio names must not be empty, contain '/', or begin with '.'")
+
+	// errPortfolioNotFound is returned if a portfolio is requested that does
+	// not exist.
+	errPortfolioNotFound = errors.New("no portfolio exists with that name")
+
+	// errPortfolioPrefixInUse is returned when creating a portfolio whose
+	// siapath prefix is already used by files of the default portfolio.
+	errPortfolioPrefixInUse = errors.New("siapath prefix of the portfolio is already in use by other files")
+
+	// errPortfoliosUnsupported is returned when creating a portfolio on a
+	// renter that was not given a way to create new contractors.
+	errPortfoliosUnsupported = errors.New("renter does not support creating portfolios")
+
+	// errRenameAcrossPortfolios is returned if a file is renamed into the
+	// namespace of a different portfolio. The file's contracts belong to its
+	// current portfolio, so it cannot be moved.
+	errRenameAcrossPortfolios = errors.New("files cannot be moved between portfolios")
+)
+
+// portfolioPrefix returns the siapath prefix of the files that belong to the
+// named portfolio.
+func portfolioPrefix(name string) string {
+	if name == modules.DefaultPortfolio {
+		return ""
+	}
+	return name + "/"
+}
+
+// validatePortfolioName checks that a portfolio name can be used as a siapath
+// prefix and as a directory name.
+func validatePortfolioName(name string) error {
+	if name == "" || strings.Contains(name, "/") || strings.HasPrefix(name, ".") {
+		return errPortfolioName
+	}
+	return nil
+}
+
+// portfolioForSiaPath returns the name of the portfolio that owns the file at
+// the provided siapath. The caller must hold the renter lock.
+func (r *Renter) portfolioForSiaPath(siapath string) string {
+	if i := strings.Index(siapath, "/"); i > 0 {
+		if _, exists := r.portfolios[siapath[:i]]; exists {
+			return siapath[:i]
+		}
+	}
+	return modules.DefaultPortfolio
+}
+
+// portfolioContractor returns the contractor of the named portfolio. The
+// caller must hold the renter lock.
+func (r *Renter) portfolioContractor(name string) (hostContractor, bool) {
+	if name == modules.DefaultPortfolio {
+		return r.hostContractor, true
+	}
+	hc, exists := r.portfolios[name]
+	return hc, exists
+}
+
+// fileContractor returns the contractor of the portfolio that owns the file.
+// The caller must hold the renter lock.
+func (r *Renter) fileContractor(f *file) hostContractor {
+	hc, _ := r.portfolioContractor(r.portfolioForSiaPath(f.name))
+	return hc
+}
+
+// managedFileContractor calls fileContractor while holding the renter lock.
+func (r *Renter) managedFileContractor(f *file) hostContractor {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	return r.fileContractor(f)
+}
+
+// portfolioContractors returns the contractors of every portfolio, keyed by
+// the name of the portfolio. The caller must hold the renter lock.
+func (r *Renter) portfolioContractors() map[string]hostContractor {
+	contractors := make(map[string]hostContractor, len(r.portfolios)+1)
+	contractors[modules.DefaultPortfolio] = r.hostContractor
+	for name, hc := range r.portfolios {
+		contractors[name] = hc
+	}
+	return contractors
+}
+
+// managedPortfolioContractors calls portfolioContractors while holding the
+// renter lock.
+func (r *Renter) managedPortfolioContractors() map[string]hostContractor {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	return r.portfolioContractors()
+}
+
+// loadPortfolios creates the contractors of the named portfolios after the
+// renter has been loaded from disk.
+func (r *Renter) loadPortfolios(names []string) error {
+	for _, name := range names {
+		if _, exists := r.portfolios[name]; exists {
+			continue
+		}
+		if r.newPortfolioContractor == nil {
+			return errPortfoliosUnsupported
+		}
+		hc, err := r.newPortfolioContractor(filepath.Join(r.persistDir, portfoliosDir, name))
+		if err != nil {
+			return err
+		}
+		r.portfolios[name] = hc
+	}
+	return nil
+}
+
+// portfolioNames returns the sorted names of the renter's named portfolios.
+// The caller must hold the renter lock.
+func (r *Renter) portfolioNames() []string {
+	names := make([]string, 0, len(r.portfolios))
+	for name := range r.portfolios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// managedCreatePortfolio creates a new named portfolio and returns its
+// contractor. If the portfolio already exists, the existing contractor is
+// returned.
+func (r *Renter) managedCreatePortfolio(name string) (hostContractor, error) {
+	id := r.mu.RLock()
+	hc, exists := r.portfolios[name]
+	prefix := portfolioPrefix(name)
+	var prefixInUse bool
+	for siapath := range r.files {
+		if strings.HasPrefix(siapath, prefix) {
+			prefixInUse = true
+			break
+		}
+	}
+	r.mu.RUnlock(id)
+	if exists {
+		return hc, nil
+	} else if prefixInUse {
+		return nil, errPortfolioPrefixInUse
+	} else if r.newPortfolioContractor == nil {
+		return nil, errPortfoliosUnsupported
+	}
+
+	// Creating the contractor may take a while, because it needs to subscribe
+	// to the consensus set, so it is done without holding the lock.
+	hc, err := r.newPortfolioContractor(filepath.Join(r.persistDir, portfoliosDir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	id = r.mu.Lock()
+	defer r.mu.Unlock(id)
+	if existing, exists := r.portfolios[name]; exists {
+		// The portfolio was created concurrently.
+		hc.Close()
+		return existing, nil
+	}
+	r.portfolios[name] = hc
+	err = r.saveSync()
+	if err != nil {
+		delete(r.portfolios, name)
+		hc.Close()
+		return nil, err
+	}
+	return hc, nil
+}
+
+// portfolioInfo returns the settings and metrics of a portfolio.
+func portfolioInfo(name string, hc hostContractor) modules.RenterPortfolio {
+	autoRenew, threshold := hc.RenewalPolicy()
+	return modules.RenterPortfolio{
+		Name:          name,
+		SiaPathPrefix: portfolioPrefix(name),
+
+		Allowance:              hc.Allowance(),
+		AutoRenewEnabled:       autoRenew,
+		RenewalThresholdBlocks: threshold,
+
+		Contracts:       len(hc.Contracts()),
+		CurrentPeriod:   hc.CurrentPeriod(),
+		PendingRenewals: hc.PendingRenewals(),
+		Spending:        hc.PeriodSpending(),
+		Identity:        hc.Identity(),
+	}
+}
+
+// Portfolio returns the allowance portfolio with the provided name.
+func (r *Renter) Portfolio(name string) (modules.RenterPortfolio, error) {
+	id := r.mu.RLock()
+	hc, exists := r.portfolioContractor(name)
+	r.mu.RUnlock(id)
+	if !exists {
+		return modules.RenterPortfolio{}, errPortfolioNotFound
+	}
+	return portfolioInfo(name, hc), nil
+}
+
+// PortfolioContracts returns the contracts owned by the allowance portfolio
+// with the provided name.
+func (r *Renter) PortfolioContracts(name string) ([]modules.RenterContract, error) {
+	id := r.mu.RLock()
+	hc, exists := r.portfolioContractor(name)
+	r.mu.RUnlock(id)
+	if !exists {
+		return nil, errPortfolioNotFound
+	}
+	return hc.Contracts(), nil
+}
+
+// Portfolios returns all of the renter's allowance portfolios, starting with
+// the default portfolio.
+func (r *Renter) Portfolios() []modules.RenterPortfolio {
+	id := r.mu.RLock()
+	names := r.portfolioNames()
+	contractors := r.portfolioContractors()
+	r.mu.RUnlock(id)
+
+	portfolios := []modules.RenterPortfolio{portfolioInfo(modules.DefaultPortfolio, r.hostContractor)}
+	for _, name := range names {
+		portfolios = append(portfolios, portfolioInfo(name, contractors[name]))
+	}
+	return portfolios
+}
+
+// SetPortfolio sets the allowance and renewal policy of an allowance
+// portfolio, creating the portfolio if it does not exist yet. Setting the
+// default portfolio is equivalent to changing the allowance and renewal
+// policy through SetSettings.
+func (r *Renter) SetPortfolio(p modules.RenterPortfolio) error {
+	if err := validatePortfolioName(p.Name); err != nil {
+		return err
+	}
+	if p.Allowance.Period != 0 && p.RenewalThresholdBlocks >= p.Allowance.Period {
+		return errRenewalThresholdSize
+	}
+
+	hc := r.hostContractor
+	if p.Name != modules.DefaultPortfolio {
+		var err error
+		hc, err = r.managedCreatePortfolio(p.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The renewal policy is set first so that the maintenance triggered by
+	// SetAllowance uses it.
+	err := hc.SetRenewalPolicy(p.AutoRenewEnabled, p.RenewalThresholdBlocks)
+	if err != nil {
+		return err
+	}
+	oldAllowance := hc.Allowance()
+	err = hc.SetAllowance(p.Allowance)
+	if err != nil {
+		return err
+	}
+	err = r.managedRecordAllowanceChange(p.Name, oldAllowance, p.Allowance)
+	if err != nil {
+		return err
+	}
+
+	r.managedUpdateWorkerPool()
+	return nil
+}
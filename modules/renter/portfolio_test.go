@@ -0,0 +1,115 @@
+package renter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestRenterPortfolios checks that portfolios can be created, own the files in
+// their siapath namespace, and are restored when the renter restarts.
+func TestRenterPortfolios(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// A fresh renter only has the default portfolio.
+	portfolios := rt.renter.Portfolios()
+	if len(portfolios) != 1 || portfolios[0].Name != modules.DefaultPortfolio || portfolios[0].SiaPathPrefix != "" {
+		t.Fatal("expected only the default portfolio:", portfolios)
+	}
+	if _, err := rt.renter.Portfolio("alice"); err != errPortfolioNotFound {
+		t.Fatal("expected errPortfolioNotFound, got", err)
+	}
+
+	// Invalid names should be rejected.
+	for _, name := range []string{"", "a/b", ".hidden"} {
+		if err := rt.renter.SetPortfolio(modules.RenterPortfolio{Name: name}); err != errPortfolioName {
+			t.Errorf("expected errPortfolioName for %q, got %v", name, err)
+		}
+	}
+
+	// Create a portfolio without an allowance.
+	err = rt.renter.SetPortfolio(modules.RenterPortfolio{Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := rt.renter.Portfolio("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.SiaPathPrefix != "alice/" || p.Contracts != 0 {
+		t.Fatal("portfolio was not created correctly:", p)
+	}
+
+	// Upload files into the namespaces of both portfolios.
+	source := filepath.Join(build.TempDir("renter", t.Name()), "source")
+	if err := os.MkdirAll(filepath.Dir(source), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(source, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, siapath := range []string{"alice/foo", "foo", "carol/foo"} {
+		err = rt.renter.Upload(modules.FileUploadParams{Source: source, SiaPath: siapath})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, fi := range rt.renter.FileList() {
+		expected := modules.DefaultPortfolio
+		if fi.SiaPath == "alice/foo" {
+			expected = "alice"
+		}
+		if fi.Portfolio != expected {
+			t.Errorf("file %v reported in portfolio %v, expected %v", fi.SiaPath, fi.Portfolio, expected)
+		}
+	}
+
+	// Files may not be moved between portfolios.
+	if err := rt.renter.RenameFile("alice/foo", "bar"); err != errRenameAcrossPortfolios {
+		t.Fatal("expected errRenameAcrossPortfolios, got", err)
+	}
+	if err := rt.renter.RenameFile("foo", "alice/bar"); err != errRenameAcrossPortfolios {
+		t.Fatal("expected errRenameAcrossPortfolios, got", err)
+	}
+	if err := rt.renter.RenameFile("alice/foo", "alice/bar"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A portfolio cannot claim a prefix that is used by files of the default
+	// portfolio.
+	if err := rt.renter.SetPortfolio(modules.RenterPortfolio{Name: "carol"}); err != errPortfolioPrefixInUse {
+		t.Fatal("expected errPortfolioPrefixInUse, got", err)
+	}
+
+	// Restart the renter, the portfolio should still exist and own its files.
+	err = rt.renter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, rt.renter.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	portfolios = rt.renter.Portfolios()
+	if len(portfolios) != 2 || portfolios[1].Name != "alice" {
+		t.Fatal("portfolio was not restored:", portfolios)
+	}
+	id := rt.renter.mu.RLock()
+	portfolio := rt.renter.portfolioForSiaPath("alice/bar")
+	rt.renter.mu.RUnlock(id)
+	if portfolio != "alice" {
+		t.Fatal("file was not restored into its portfolio")
+	}
+}
@@ -137,8 +137,11 @@ func (hd *Downloader) Close() error {
 }
 
 // NewDownloader initiates the download request loop with a host, and returns a
-// Downloader.
-func NewDownloader(host modules.HostDBEntry, contract modules.RenterContract, hdb hostDB, cancel <-chan struct{}) (_ *Downloader, err error) {
+// Downloader. The connection to the host draws from the node's bandwidth budget
+// through bl, and the bandwidth that the Downloader reads from the host is
+// further limited by rl. Both may be nil. The time spent waiting for rl does
+// not count against the deadline of a sector download.
+func NewDownloader(host modules.HostDBEntry, contract modules.RenterContract, hdb hostDB, bl *modules.BandwidthLimiter, rl *RateLimit, cancel <-chan struct{}) (_ *Downloader, err error) {
 	// check that contract has enough value to support a download
 	if len(contract.LastRevision.NewValidProofOutputs) != 2 {
 		return nil, errors.New("invalid contract")
@@ -159,13 +162,14 @@ func NewDownloader(host modules.HostDBEntry, contract modules.RenterContract, hd
 	}()
 
 	// initiate download loop
-	conn, err := (&net.Dialer{
+	conn, err := dialHost(&net.Dialer{
 		Cancel:  cancel,
 		Timeout: 15 * time.Second,
-	}).Dial("tcp", string(contract.NetAddress))
+	}, contract.NetAddress, contract.HostPublicKey, contract.PinnedHostCert, crypto.SecretKey{})
 	if err != nil {
 		return nil, err
 	}
+	conn = rl.wrap(bl.LimitConn(conn), cancel)
 
 	closeChan := make(chan struct{})
 	go func() {
@@ -258,8 +258,11 @@ func (he *Editor) Modify(oldRoot, newRoot crypto.Hash, offset uint64, newData []
 }
 
 // NewEditor initiates the contract revision process with a host, and returns
-// an Editor.
-func NewEditor(host modules.HostDBEntry, contract modules.RenterContract, currentHeight types.BlockHeight, hdb hostDB, cancel <-chan struct{}) (_ *Editor, err error) {
+// an Editor. The connection to the host draws from the node's bandwidth budget
+// through bl, and the bandwidth that the Editor writes to the host is limited
+// by rl. Both may be nil. The time spent waiting for rl does not count against
+// the deadline of a sector upload.
+func NewEditor(host modules.HostDBEntry, contract modules.RenterContract, currentHeight types.BlockHeight, hdb hostDB, bl *modules.BandwidthLimiter, rl *RateLimit, cancel <-chan struct{}) (_ *Editor, err error) {
 	// check that contract has enough value to support an upload
 	if len(contract.LastRevision.NewValidProofOutputs) != 2 {
 		return nil, errors.New("invalid contract")
@@ -276,13 +279,14 @@ func NewEditor(host modules.HostDBEntry, contract modules.RenterContract, curren
 	}()
 
 	// initiate revision loop
-	conn, err := (&net.Dialer{
+	conn, err := dialHost(&net.Dialer{
 		Cancel:  cancel,
 		Timeout: 15 * time.Second,
-	}).Dial("tcp", string(contract.NetAddress))
+	}, contract.NetAddress, contract.HostPublicKey, contract.PinnedHostCert, crypto.SecretKey{})
 	if err != nil {
 		return nil, err
 	}
+	conn = rl.wrapWrites(bl.LimitConn(conn), cancel)
 
 	closeChan := make(chan struct{})
 	go func() {
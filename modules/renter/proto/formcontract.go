@@ -113,7 +113,7 @@ func FormContract(params ContractParams, txnBuilder transactionBuilder, tpool tr
 		Cancel:  cancel,
 		Timeout: connTimeout,
 	}
-	conn, err := dialer.Dial("tcp", string(host.NetAddress))
+	conn, err := dialHost(dialer, host.NetAddress, host.PublicKey, params.PinnedHostCert, params.IdentityKey)
 	if err != nil {
 		return modules.RenterContract{}, err
 	}
@@ -272,6 +272,7 @@ func FormContract(params ContractParams, txnBuilder transactionBuilder, tpool tr
 		NetAddress:      host.NetAddress,
 		SecretKey:       ourSK,
 		StartHeight:     startHeight,
+		PinnedHostCert:  params.PinnedHostCert,
 
 		TotalCost:   funding,
 		ContractFee: host.ContractPrice,
@@ -12,6 +12,16 @@ import (
 	"github.com/NebulousLabs/Sia/types"
 )
 
+var (
+	// errHostNotAhead is returned by ResyncContract if the error it is passed
+	// was not caused by the host being ahead of the renter.
+	errHostNotAhead = errors.New("host does not have a more recent revision")
+
+	// errResyncDataMismatch is returned by ResyncContract if the host's more
+	// recent revision covers data that the renter does not know about.
+	errResyncDataMismatch = errors.New("host's revision does not match the renter's Merkle roots")
+)
+
 // extendDeadline is a helper function for extending the connection timeout.
 func extendDeadline(conn net.Conn, d time.Duration) { _ = conn.SetDeadline(time.Now().Add(d)) }
 
@@ -87,7 +97,10 @@ func verifyRecentRevision(conn net.Conn, contract modules.RenterContract, hostVe
 		return errors.New("couldn't send challenge response: " + err.Error())
 	}
 	// read acceptance
-	if err := modules.ReadNegotiationAcceptance(conn); err != nil {
+	if err := modules.ReadNegotiationAcceptance(conn); err == modules.ErrHostWindingDown {
+		// Preserve the error so that renewals can detect it.
+		return err
+	} else if err != nil {
 		return errors.New("host did not accept revision request: " + err.Error())
 	}
 	// read last revision and signatures
@@ -104,7 +117,12 @@ func verifyRecentRevision(conn net.Conn, contract modules.RenterContract, hostVe
 	if lastRevision.UnlockConditions.UnlockHash() != contract.LastRevision.UnlockConditions.UnlockHash() {
 		return errors.New("unlock conditions do not match")
 	} else if lastRevision.NewRevisionNumber != contract.LastRevision.NewRevisionNumber {
-		return &recentRevisionError{contract.LastRevision.NewRevisionNumber, lastRevision.NewRevisionNumber}
+		return &recentRevisionError{
+			ours:           contract.LastRevision.NewRevisionNumber,
+			theirs:         lastRevision.NewRevisionNumber,
+			hostRevision:   lastRevision,
+			hostSignatures: hostSignatures,
+		}
 	}
 	// NOTE: we can fake the blockheight here because it doesn't affect
 	// verification; it just needs to be above the fork height and below the
@@ -112,6 +130,43 @@ func verifyRecentRevision(conn net.Conn, contract modules.RenterContract, hostVe
 	return modules.VerifyFileContractRevisionTransactionSignatures(lastRevision, hostSignatures, contract.FileContract.WindowStart-1)
 }
 
+// ResyncContract adopts the revision that the host reported in err, which
+// must be a revision mismatch where the host is ahead of the renter. The
+// revision is only adopted if it is signed by both the renter and the host,
+// and if it covers the same data as the Merkle roots of the contract, since
+// the renter could not revise a contract whose data it does not know. Funds
+// that the host gained in the missing revisions are counted as download
+// spending, as revisions that do not change the data pay for downloads.
+func ResyncContract(contract modules.RenterContract, err error) (modules.RenterContract, error) {
+	rre, ok := err.(*recentRevisionError)
+	if !ok || rre.theirs <= rre.ours {
+		return modules.RenterContract{}, errHostNotAhead
+	}
+	rev := rre.hostRevision
+	if rev.ParentID != contract.ID || len(rev.NewValidProofOutputs) != 2 {
+		return modules.RenterContract{}, errors.New("host reported a revision of a different contract")
+	}
+	// NOTE: as in verifyRecentRevision, the blockheight only needs to be
+	// above the fork height and below the contract expiration.
+	if err := modules.VerifyFileContractRevisionTransactionSignatures(rev, rre.hostSignatures, contract.FileContract.WindowStart-1); err != nil {
+		return modules.RenterContract{}, build.ExtendErr("host's revision has invalid signatures:", err)
+	}
+	if rev.NewFileSize != uint64(len(contract.MerkleRoots))*modules.SectorSize || rev.NewFileMerkleRoot != cachedMerkleRoot(contract.MerkleRoots) {
+		return modules.RenterContract{}, errResyncDataMismatch
+	}
+
+	ourFunds := contract.LastRevision.NewValidProofOutputs[0].Value
+	if hostFunds := rev.NewValidProofOutputs[0].Value; hostFunds.Cmp(ourFunds) < 0 {
+		contract.DownloadSpending = contract.DownloadSpending.Add(ourFunds.Sub(hostFunds))
+	}
+	contract.LastRevision = rev
+	contract.LastRevisionTxn = types.Transaction{
+		FileContractRevisions: []types.FileContractRevision{rev},
+		TransactionSignatures: rre.hostSignatures,
+	}
+	return contract, nil
+}
+
 // negotiateRevision sends a revision and actions to the host for approval,
 // completing one iteration of the revision loop.
 func negotiateRevision(conn net.Conn, rev types.FileContractRevision, secretKey crypto.SecretKey) (types.Transaction, error) {
@@ -41,6 +41,13 @@ type ContractParams struct {
 	StartHeight   types.BlockHeight
 	EndHeight     types.BlockHeight
 	RefundAddress types.UnlockHash
+	// PinnedHostCert is the TLS certificate that the host must present, see
+	// modules.RenterContract.
+	PinnedHostCert []byte
+	// IdentityKey is the key that the renter identifies itself with to the
+	// host, see modules.RPCRenterIdentity. Unlike the renter's key in the
+	// contract, it is the same for every contract.
+	IdentityKey crypto.SecretKey
 	// TODO: add optional keypair
 }
 
@@ -49,9 +56,13 @@ type ContractParams struct {
 type revisionSaver func(types.FileContractRevision, []crypto.Hash) error
 
 // A recentRevisionError occurs if the host reports a different revision
-// number than expected.
+// number than expected. The revision and signatures that the host reported
+// are kept so that the contract can be resynchronized with the host.
 type recentRevisionError struct {
 	ours, theirs uint64
+
+	hostRevision   types.FileContractRevision
+	hostSignatures []types.TransactionSignature
 }
 
 func (e *recentRevisionError) Error() string {
@@ -64,3 +75,13 @@ func IsRevisionMismatch(err error) bool {
 	_, ok := err.(*recentRevisionError)
 	return ok
 }
+
+// HostAhead returns the host's revision number if err was caused by the host
+// reporting a more recent revision than the renter's.
+func HostAhead(err error) (hostRevisionNumber uint64, ahead bool) {
+	rre, ok := err.(*recentRevisionError)
+	if !ok || rre.theirs <= rre.ours {
+		return 0, false
+	}
+	return rre.theirs, true
+}
@@ -0,0 +1,297 @@
+package proto
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// rateLimitChunkSize is the largest read or write that a rate limited
+	// connection makes at once. Smaller reads and writes spread the bandwidth
+	// of a limit more evenly between the connections that share it.
+	rateLimitChunkSize = 16 << 10
+
+	// rateWindow is the period over which the rate of a RateLimit is
+	// measured.
+	rateWindow = time.Second
+)
+
+var (
+	// errRateLimitCanceled is returned by a read or write of a rate limited
+	// connection that was canceled while waiting for bandwidth.
+	errRateLimitCanceled = errors.New("transfer was canceled while waiting for the rate limit")
+)
+
+type (
+	// A RateLimit limits the combined read or write rate of every connection
+	// that shares it, and measures the rate of the connections. The limit can
+	// be changed while connections are using it. A nil RateLimit, or a limit
+	// of zero, does not limit the connections.
+	RateLimit struct {
+		bps int64
+
+		// next is the time at which the bandwidth that has been transferred
+		// so far has been paid for. Transfers wait until then.
+		next time.Time
+
+		// windowStart is the start of the current rateWindow, windowBytes
+		// are the bytes that were transferred since, and lastRate is the
+		// rate of the previous rateWindow.
+		windowStart time.Time
+		windowBytes uint64
+		lastRate    uint64
+
+		mu sync.Mutex
+	}
+
+	// rateLimitDeadlines are the deadlines of a rate limited connection.
+	// Every wait for the limit pushes the deadlines back by the length of
+	// the wait, so that a transfer only times out if the connection itself
+	// is slow, no matter how low the limit is or how many connections share
+	// it.
+	rateLimitDeadlines struct {
+		conn          net.Conn
+		readDeadline  time.Time
+		writeDeadline time.Time
+		mu            sync.Mutex
+	}
+
+	// rateLimitedConn is a connection whose reads are limited by a
+	// RateLimit.
+	rateLimitedConn struct {
+		net.Conn
+		deadlines *rateLimitDeadlines
+		rl        *RateLimit
+		cancel    <-chan struct{}
+	}
+
+	// rateLimitedWriteConn is a connection whose writes are limited by a
+	// RateLimit.
+	rateLimitedWriteConn struct {
+		net.Conn
+		deadlines *rateLimitDeadlines
+		rl        *RateLimit
+		cancel    <-chan struct{}
+	}
+)
+
+// NewRateLimit returns a RateLimit of bps bytes per second.
+func NewRateLimit(bps int64) *RateLimit {
+	return &RateLimit{bps: bps}
+}
+
+// Limit returns the limit in bytes per second.
+func (rl *RateLimit) Limit() int64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.bps
+}
+
+// SetLimit sets the limit to bps bytes per second. The new limit applies to
+// the next read or write of every connection.
+func (rl *RateLimit) SetLimit(bps int64) {
+	rl.mu.Lock()
+	rl.bps = bps
+	rl.mu.Unlock()
+}
+
+// Rate returns the rate of the connections in bytes per second, measured over
+// the last complete second.
+func (rl *RateLimit) Rate() uint64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.updateWindow(time.Now())
+	return rl.lastRate
+}
+
+// updateWindow starts a new rateWindow if the current one has ended. The
+// caller must hold the lock.
+func (rl *RateLimit) updateWindow(now time.Time) {
+	elapsed := now.Sub(rl.windowStart)
+	if elapsed < rateWindow {
+		return
+	}
+	if elapsed < 2*rateWindow {
+		rl.lastRate = rl.windowBytes
+	} else {
+		// Nothing was transferred in the previous window.
+		rl.lastRate = 0
+	}
+	rl.windowStart = now
+	rl.windowBytes = 0
+}
+
+// managedReserve pays for n bytes that are transferred, and returns how long
+// the transfer must wait until the limit allows them. Bandwidth that was not
+// used in the past is not saved up, so the connections cannot burst after
+// being idle.
+func (rl *RateLimit) managedReserve(n int) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := time.Now()
+	rl.updateWindow(now)
+	rl.windowBytes += uint64(n)
+	if rl.bps <= 0 {
+		return 0
+	}
+	if rl.next.Before(now) {
+		rl.next = now
+	}
+	rl.next = rl.next.Add(time.Duration(n) * time.Second / time.Duration(rl.bps))
+	return rl.next.Sub(now)
+}
+
+// waitForLimit sleeps for wait, or until cancel is closed.
+func waitForLimit(wait time.Duration, cancel <-chan struct{}) error {
+	if wait <= 0 {
+		return nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-cancel:
+		return errRateLimitCanceled
+	}
+}
+
+// setDeadlines sets the read deadline of the connection to t if setRead is
+// true, and the write deadline if setWrite is true.
+func (d *rateLimitDeadlines) setDeadlines(t time.Time, setRead, setWrite bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if setRead {
+		d.readDeadline = t
+	}
+	if setWrite {
+		d.writeDeadline = t
+	}
+	return d.apply()
+}
+
+// extend pushes the deadlines that are set back by wait.
+func (d *rateLimitDeadlines) extend(wait time.Duration) {
+	if wait <= 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.readDeadline.IsZero() {
+		d.readDeadline = d.readDeadline.Add(wait)
+	}
+	if !d.writeDeadline.IsZero() {
+		d.writeDeadline = d.writeDeadline.Add(wait)
+	}
+	_ = d.apply()
+}
+
+// apply sets the deadlines of the underlying connection. The caller must hold
+// the lock.
+func (d *rateLimitDeadlines) apply() error {
+	if err := d.conn.SetReadDeadline(d.readDeadline); err != nil {
+		return err
+	}
+	return d.conn.SetWriteDeadline(d.writeDeadline)
+}
+
+// wrap returns conn with its reads limited by the RateLimit. A wait for the
+// limit is abandoned once cancel is closed.
+func (rl *RateLimit) wrap(conn net.Conn, cancel <-chan struct{}) net.Conn {
+	if rl == nil {
+		return conn
+	}
+	return &rateLimitedConn{
+		Conn:      conn,
+		deadlines: &rateLimitDeadlines{conn: conn},
+		rl:        rl,
+		cancel:    cancel,
+	}
+}
+
+// wrapWrites returns conn with its writes limited by the RateLimit. A wait for
+// the limit is abandoned once cancel is closed.
+func (rl *RateLimit) wrapWrites(conn net.Conn, cancel <-chan struct{}) net.Conn {
+	if rl == nil {
+		return conn
+	}
+	return &rateLimitedWriteConn{
+		Conn:      conn,
+		deadlines: &rateLimitDeadlines{conn: conn},
+		rl:        rl,
+		cancel:    cancel,
+	}
+}
+
+// Read reads from the connection, and then waits until the limit allows the
+// bytes that were read.
+func (c *rateLimitedConn) Read(b []byte) (int, error) {
+	if len(b) > rateLimitChunkSize {
+		b = b[:rateLimitChunkSize]
+	}
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		wait := c.rl.managedReserve(n)
+		c.deadlines.extend(wait)
+		if waitErr := waitForLimit(wait, c.cancel); waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return n, err
+}
+
+// SetDeadline sets the read and write deadlines of the connection.
+func (c *rateLimitedConn) SetDeadline(t time.Time) error {
+	return c.deadlines.setDeadlines(t, true, true)
+}
+
+// SetReadDeadline sets the read deadline of the connection.
+func (c *rateLimitedConn) SetReadDeadline(t time.Time) error {
+	return c.deadlines.setDeadlines(t, true, false)
+}
+
+// SetWriteDeadline sets the write deadline of the connection.
+func (c *rateLimitedConn) SetWriteDeadline(t time.Time) error {
+	return c.deadlines.setDeadlines(t, false, true)
+}
+
+// Write waits until the limit allows each chunk of b, and writes it to the
+// connection.
+func (c *rateLimitedWriteConn) Write(b []byte) (int, error) {
+	var written int
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > rateLimitChunkSize {
+			chunk = chunk[:rateLimitChunkSize]
+		}
+		wait := c.rl.managedReserve(len(chunk))
+		c.deadlines.extend(wait)
+		if err := waitForLimit(wait, c.cancel); err != nil {
+			return written, err
+		}
+		n, err := c.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// SetDeadline sets the read and write deadlines of the connection.
+func (c *rateLimitedWriteConn) SetDeadline(t time.Time) error {
+	return c.deadlines.setDeadlines(t, true, true)
+}
+
+// SetReadDeadline sets the read deadline of the connection.
+func (c *rateLimitedWriteConn) SetReadDeadline(t time.Time) error {
+	return c.deadlines.setDeadlines(t, true, false)
+}
+
+// SetWriteDeadline sets the write deadline of the connection.
+func (c *rateLimitedWriteConn) SetWriteDeadline(t time.Time) error {
+	return c.deadlines.setDeadlines(t, false, true)
+}
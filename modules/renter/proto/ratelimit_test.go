@@ -0,0 +1,190 @@
+package proto
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// readLimited writes size bytes through a pipe whose reading end is limited by
+// rl, and reads them.
+func readLimited(rl *RateLimit, size int, cancel <-chan struct{}) error {
+	server, client := net.Pipe()
+	defer server.Close()
+	go func() {
+		server.Write(fastrand.Bytes(size))
+	}()
+	conn := rl.wrap(client, cancel)
+	defer conn.Close()
+	_, err := io.CopyN(ioutil.Discard, conn, int64(size))
+	return err
+}
+
+// TestRateLimit checks that the combined rate of several connections that
+// share a RateLimit stays within the limit, and that the limit can be changed
+// while the connections are in use.
+func TestRateLimit(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	const (
+		workers = 4
+		size    = 128 << 10
+		limit   = 512 << 10
+	)
+	rl := NewRateLimit(limit)
+	readAll := func() time.Duration {
+		start := time.Now()
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := readLimited(rl, size, nil); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+		return time.Since(start)
+	}
+
+	// The combined rate should be close to the limit, and must not exceed
+	// it by more than the tolerance.
+	elapsed := readAll()
+	rate := float64(workers*size) / elapsed.Seconds()
+	if rate > limit*1.1 || rate < limit*0.7 {
+		t.Fatalf("combined rate of %.0f B/s is not within the tolerance of the %v B/s limit", rate, limit)
+	}
+
+	// Removing the limit should take effect immediately.
+	rl.SetLimit(0)
+	if elapsed := readAll(); elapsed > time.Second/4 {
+		t.Fatal("connections were still limited after the limit was removed:", elapsed)
+	}
+
+	// A wait for the limit should end once it is canceled.
+	rl.SetLimit(1)
+	cancel := make(chan struct{})
+	errChan := make(chan error)
+	go func() {
+		errChan <- readLimited(rl, size, cancel)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	close(cancel)
+	select {
+	case err := <-errChan:
+		if err != errRateLimitCanceled {
+			t.Fatal("expected errRateLimitCanceled, got", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("canceled read did not return")
+	}
+}
+
+// TestRateLimitDeadline checks that the time a connection spends waiting for
+// the limit does not count against its deadlines.
+func TestRateLimitDeadline(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Reading size bytes at the limit takes about a second, four times as
+	// long as the deadline.
+	const (
+		size  = 64 << 10
+		limit = 64 << 10
+	)
+	rl := NewRateLimit(limit)
+	server, client := net.Pipe()
+	defer server.Close()
+	go server.Write(fastrand.Bytes(size))
+	conn := rl.wrap(client, nil)
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(time.Second / 4)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.CopyN(ioutil.Discard, conn, size); err != nil {
+		t.Fatal("limited read timed out:", err)
+	}
+
+	// Without any data from the other end, the deadline still applies.
+	if err := conn.SetDeadline(time.Now().Add(time.Second / 4)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1)
+	if _, err := conn.Read(buf); err == nil {
+		t.Fatal("read of an idle connection did not time out")
+	}
+
+	// The same applies to the writes of a connection.
+	wserver, wclient := net.Pipe()
+	defer wserver.Close()
+	go io.Copy(ioutil.Discard, wserver)
+	wconn := rl.wrapWrites(wclient, nil)
+	defer wconn.Close()
+	if err := wconn.SetDeadline(time.Now().Add(time.Second / 4)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wconn.Write(fastrand.Bytes(size)); err != nil {
+		t.Fatal("limited write timed out:", err)
+	}
+}
+
+// TestRateLimitWrites checks that the writes of connections that share a
+// RateLimit stay within the limit, and that their rate is measured.
+func TestRateLimitWrites(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	const (
+		size  = 256 << 10
+		limit = 256 << 10
+	)
+	rl := NewRateLimit(limit)
+	server, client := net.Pipe()
+	defer server.Close()
+	go io.Copy(ioutil.Discard, server)
+	conn := rl.wrapWrites(client, nil)
+	defer conn.Close()
+
+	start := time.Now()
+	if n, err := conn.Write(fastrand.Bytes(size)); err != nil || n != size {
+		t.Fatal("write failed:", n, err)
+	}
+	rate := float64(size) / time.Since(start).Seconds()
+	if rate > limit*1.1 || rate < limit*0.7 {
+		t.Fatalf("rate of %.0f B/s is not within the tolerance of the %v B/s limit", rate, limit)
+	}
+
+	// The rate of the previous second should be close to the limit, and
+	// drop to zero once the connection is idle.
+	time.Sleep(rateWindow / 10)
+	if r := float64(rl.Rate()); r > limit*1.1 || r < limit/2 {
+		t.Fatal("wrong measured rate:", r)
+	}
+	time.Sleep(2 * rateWindow)
+	if r := rl.Rate(); r != 0 {
+		t.Fatal("idle connection has a rate of", r)
+	}
+
+	// Without a limit, writes are not delayed.
+	rl.SetLimit(0)
+	start = time.Now()
+	if _, err := conn.Write(fastrand.Bytes(size)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second/4 {
+		t.Fatal("write was still limited after the limit was removed:", elapsed)
+	}
+}
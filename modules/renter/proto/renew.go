@@ -98,8 +98,9 @@ func Renew(contract modules.RenterContract, params ContractParams, txnBuilder tr
 
 	// Increase Successful/Failed interactions accordingly
 	defer func() {
-		// A revision mismatch might not be the host's fault.
-		if err != nil && !IsRevisionMismatch(err) {
+		// A revision mismatch might not be the host's fault, and a host
+		// that is winding down is refusing the renewal on purpose.
+		if err != nil && !IsRevisionMismatch(err) && err != modules.ErrHostWindingDown {
 			hdb.IncrementFailedInteractions(contract.HostPublicKey)
 		} else if err == nil {
 			hdb.IncrementSuccessfulInteractions(contract.HostPublicKey)
@@ -111,7 +112,7 @@ func Renew(contract modules.RenterContract, params ContractParams, txnBuilder tr
 		Cancel:  cancel,
 		Timeout: connTimeout,
 	}
-	conn, err := dialer.Dial("tcp", string(host.NetAddress))
+	conn, err := dialHost(dialer, host.NetAddress, host.PublicKey, params.PinnedHostCert, params.IdentityKey)
 	if err != nil {
 		return modules.RenterContract{}, err
 	}
@@ -125,7 +126,8 @@ func Renew(contract modules.RenterContract, params ContractParams, txnBuilder tr
 	// verify that both parties are renewing the same contract
 	if err = verifyRecentRevision(conn, contract, host.Version); err != nil {
 		// don't add context; want to preserve the original error type so that
-		// callers can check using IsRevisionMismatch
+		// callers can check using IsRevisionMismatch or compare it to
+		// modules.ErrHostWindingDown
 		return modules.RenterContract{}, err
 	}
 	// verify the host's settings and confirm its identity
@@ -276,6 +278,7 @@ func Renew(contract modules.RenterContract, params ContractParams, txnBuilder tr
 		NetAddress:      host.NetAddress,
 		SecretKey:       ourSK,
 		StartHeight:     startHeight,
+		PinnedHostCert:  params.PinnedHostCert,
 
 		TotalCost:   funding,
 		ContractFee: host.ContractPrice,
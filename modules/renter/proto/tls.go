@@ -0,0 +1,207 @@
+package proto
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// errHostCertMismatch is returned if a host presents a TLS certificate
+	// other than the one that the renter pinned for it.
+	errHostCertMismatch = errors.New("host presented a TLS certificate other than the pinned certificate")
+
+	// errInvalidPinnedCert is returned if a pinned certificate is neither a
+	// PEM nor a DER encoded X.509 certificate.
+	errInvalidPinnedCert = errors.New("pinned host certificate is not a PEM or DER encoded certificate")
+)
+
+// errTLSDowngrade is returned if a host that has upgraded a session to TLS
+// before does not offer the upgrade, which is what an attacker between the
+// renter and the host would do to read the session.
+var errTLSDowngrade = errors.New("host upgraded sessions to TLS before, but did not offer TLS")
+
+// legacySessionRetry is how long the renter connects to a host that did not
+// serve a versioned session without trying one again.
+var legacySessionRetry = build.Select(build.Var{
+	Dev:      10 * time.Minute,
+	Standard: time.Hour,
+	Testing:  time.Minute,
+}).(time.Duration)
+
+// hostSessions records the sessions of each host, keyed by the public key of
+// the host. legacy holds when a host last closed the connection instead of
+// starting a versioned session, so that dialHost does not dial those hosts
+// twice for every connection. tls holds the hosts that have upgraded a
+// session to TLS, which are never connected to without TLS again, so that an
+// attacker can not strip the upgrade from the session.
+var hostSessions = struct {
+	legacy map[string]time.Time
+	tls    map[string]struct{}
+	mu     sync.Mutex
+}{
+	legacy: make(map[string]time.Time),
+	tls:    make(map[string]struct{}),
+}
+
+// ParsePinnedCert returns the DER encoding of a certificate that is pinned
+// for a host, which may be provided in PEM or DER encoding.
+func ParsePinnedCert(cert []byte) ([]byte, error) {
+	if block, _ := pem.Decode(cert); block != nil {
+		cert = block.Bytes
+	}
+	if _, err := x509.ParseCertificate(cert); err != nil {
+		return nil, errInvalidPinnedCert
+	}
+	return cert, nil
+}
+
+// dialHost connects to the host at addr. If pinnedCert is set, the connection
+// is upgraded to TLS, and the host must present pinnedCert as its
+// certificate. The certificate is not checked against a certificate
+// authority, pinning it is what authenticates the host.
+//
+// Otherwise a versioned session is started, and upgraded to TLS if the host
+// advertises RPCUpgradeTLS, in which case the host must sign its certificate
+// with hostKey. Hosts that do not serve versioned sessions are connected to
+// again without one, unless they have upgraded a session to TLS before.
+//
+// If identityKey is set, the renter identifies itself with the key to hosts
+// that advertise RPCRenterIdentity, which requires a versioned session on
+// connections with a pinned certificate as well.
+func dialHost(dialer *net.Dialer, addr modules.NetAddress, hostKey types.SiaPublicKey, pinnedCert []byte, identityKey crypto.SecretKey) (net.Conn, error) {
+	conn, err := dialer.Dial("tcp", string(addr))
+	if err != nil {
+		return nil, err
+	}
+	if len(pinnedCert) == 0 {
+		return startSession(dialer, conn, addr, hostKey, identityKey)
+	}
+	pinned, err := ParsePinnedCert(pinnedCert)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, &tls.Config{
+		// The certificate chain is not verified, the certificate of the host
+		// is compared to the pinned certificate instead.
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS12,
+		CipherSuites:       modules.TLSCipherSuites,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 || !bytes.Equal(rawCerts[0], pinned) {
+				return errHostCertMismatch
+			}
+			return nil
+		},
+	})
+	extendDeadline(tlsConn, dialer.Timeout)
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if identityKey == (crypto.SecretKey{}) {
+		return tlsConn, nil
+	}
+	_, hv, err := modules.NegotiateRPCVersion(tlsConn, modules.SupportedRPCVersions)
+	if err == nil {
+		err = identifySession(tlsConn, hv, hostKey, identityKey)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// identifySession identifies the renter with identityKey in a versioned
+// session with a host that advertises RPCRenterIdentity in hv. Sessions with
+// other hosts are not identified.
+func identifySession(conn net.Conn, hv modules.RPCVersions, hostKey types.SiaPublicKey, identityKey crypto.SecretKey) error {
+	if identityKey == (crypto.SecretKey{}) {
+		return nil
+	}
+	for _, rpc := range hv.RPCs {
+		if rpc == modules.RPCRenterIdentity {
+			return build.ExtendErr("unable to identify the renter", modules.NegotiateRenterIdentity(conn, identityKey, hostKey))
+		}
+	}
+	return nil
+}
+
+// startSession starts a versioned session with the host on conn, and upgrades
+// it to TLS if the host supports it. Hosts that do not serve versioned
+// sessions close the connection when they are asked for one, in which case a
+// new connection without a session is returned. Any other error, such as a
+// timeout, is returned without falling back to a legacy connection. Hosts
+// that have upgraded a session to TLS before must do so again.
+func startSession(dialer *net.Dialer, conn net.Conn, addr modules.NetAddress, hostKey types.SiaPublicKey, identityKey crypto.SecretKey) (net.Conn, error) {
+	key := hostKey.String()
+	hostSessions.mu.Lock()
+	failed, legacy := hostSessions.legacy[key]
+	_, upgraded := hostSessions.tls[key]
+	hostSessions.mu.Unlock()
+	if legacy && !upgraded && time.Since(failed) < legacySessionRetry {
+		return conn, nil
+	}
+
+	extendDeadline(conn, dialer.Timeout)
+	_, hv, err := modules.NegotiateRPCVersion(conn, modules.SupportedRPCVersions)
+	if err == io.EOF && upgraded {
+		conn.Close()
+		return nil, errTLSDowngrade
+	} else if err == io.EOF {
+		// Hosts that do not know RPCVersion close the connection without
+		// replying.
+		conn.Close()
+		hostSessions.mu.Lock()
+		hostSessions.legacy[key] = time.Now()
+		hostSessions.mu.Unlock()
+		return dialer.Dial("tcp", string(addr))
+	} else if err != nil {
+		conn.Close()
+		return nil, build.ExtendErr("unable to start a versioned session", err)
+	}
+	if legacy {
+		hostSessions.mu.Lock()
+		delete(hostSessions.legacy, key)
+		hostSessions.mu.Unlock()
+	}
+	offered := false
+	for _, rpc := range hv.RPCs {
+		if rpc == modules.RPCUpgradeTLS {
+			offered = true
+			break
+		}
+	}
+	if offered {
+		tlsConn, err := modules.UpgradeSessionTLS(conn, hostKey)
+		if err != nil {
+			conn.Close()
+			return nil, build.ExtendErr("unable to upgrade the connection to TLS", err)
+		}
+		conn = tlsConn
+		hostSessions.mu.Lock()
+		hostSessions.tls[key] = struct{}{}
+		hostSessions.mu.Unlock()
+	} else if upgraded {
+		conn.Close()
+		return nil, errTLSDowngrade
+	}
+	if err := identifySession(conn, hv, hostKey, identityKey); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,370 @@
+package proto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// newTestCert returns a self-signed certificate, and the DER encoding of that
+// certificate.
+func newTestCert(t *testing.T) (tls.Certificate, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{certDER}, PrivateKey: key}, certDER
+}
+
+// newTestTLSListener returns a TLS listener that presents a self-signed
+// certificate, and the DER encoding of that certificate.
+func newTestTLSListener(t *testing.T) (net.Listener, []byte) {
+	cert, certDER := newTestCert(t)
+	l, err := tls.Listen("tcp", "localhost:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+	return l, certDER
+}
+
+// TestDialHostPinnedCert checks that dialHost only completes the TLS handshake
+// if the host presents the pinned certificate.
+func TestDialHostPinnedCert(t *testing.T) {
+	l, certDER := newTestTLSListener(t)
+	defer l.Close()
+	addr := modules.NetAddress(l.Addr().String())
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	for _, pinned := range [][]byte{certDER, certPEM} {
+		conn, err := dialHost(dialer, addr, types.SiaPublicKey{}, pinned, crypto.SecretKey{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := conn.(*tls.Conn); !ok {
+			t.Error("connection with a pinned certificate does not use TLS")
+		}
+		conn.Close()
+	}
+
+	other, otherDER := newTestTLSListener(t)
+	other.Close()
+	if _, err := dialHost(dialer, addr, types.SiaPublicKey{}, otherDER, crypto.SecretKey{}); err == nil {
+		t.Fatal("expected the handshake to fail for the wrong pinned certificate")
+	}
+	if _, err := dialHost(dialer, addr, types.SiaPublicKey{}, []byte("not a certificate"), crypto.SecretKey{}); err != errInvalidPinnedCert {
+		t.Fatal("expected errInvalidPinnedCert, got", err)
+	}
+
+	// The listener does not serve versioned sessions, so dialHost has to
+	// connect again without one, and remember that it did.
+	_, pk := crypto.GenerateKeyPair()
+	hostKey := types.Ed25519PublicKey(pk)
+	conn, err := dialHost(dialer, addr, hostKey, nil, crypto.SecretKey{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := conn.(*tls.Conn); ok {
+		t.Error("connection without a pinned certificate uses TLS")
+	}
+	conn.Close()
+	hostSessions.mu.Lock()
+	_, legacy := hostSessions.legacy[hostKey.String()]
+	hostSessions.mu.Unlock()
+	if !legacy {
+		t.Error("host without versioned sessions was not recorded")
+	}
+}
+
+// newTestSessionHost returns a listener that calls serve for every connection
+// that it accepts, and closes the connection afterwards.
+func newTestSessionHost(t *testing.T, serve func(net.Conn)) net.Listener {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				serve(conn)
+				conn.Close()
+			}()
+		}
+	}()
+	return l
+}
+
+// serveTestSession serves the host's side of a versioned session that offers
+// RPCUpgradeTLS, upgrading the connection with cert and signing the
+// certificate with sk, and then echoes a byte over the upgraded connection.
+func serveTestSession(conn net.Conn, cert tls.Certificate, sk crypto.SecretKey) {
+	var id types.Specifier
+	if encoding.ReadObject(conn, &id, 16) != nil || id != modules.RPCVersion {
+		return
+	}
+	err := encoding.WriteObject(conn, modules.RPCVersions{
+		Versions: modules.SupportedRPCVersions,
+		RPCs:     []types.Specifier{modules.RPCUpgradeTLS},
+	})
+	var version uint64
+	if err != nil || encoding.ReadObject(conn, &version, 8) != nil || modules.WriteNegotiationAcceptance(conn) != nil {
+		return
+	}
+	if encoding.ReadObject(conn, &id, 16) != nil || id != modules.RPCUpgradeTLS {
+		return
+	}
+	tlsConn := tls.Server(conn, &tls.Config{
+		Certificates:             []tls.Certificate{cert},
+		CipherSuites:             modules.TLSCipherSuites,
+		PreferServerCipherSuites: true,
+	})
+	if tlsConn.Handshake() != nil {
+		return
+	}
+	if encoding.WriteObject(tlsConn, crypto.SignHash(modules.HostTLSCertHash(cert.Certificate[0]), sk)) != nil {
+		return
+	}
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(tlsConn, b); err == nil {
+		tlsConn.Write(b)
+	}
+}
+
+// TestStartSessionUpgradeTLS checks that dialHost upgrades a versioned session
+// to TLS if the host offers RPCUpgradeTLS, and that the host has to sign its
+// certificate with its key.
+func TestStartSessionUpgradeTLS(t *testing.T) {
+	cert, _ := newTestCert(t)
+	sk, pk := crypto.GenerateKeyPair()
+	hostKey := types.Ed25519PublicKey(pk)
+	l := newTestSessionHost(t, func(conn net.Conn) { serveTestSession(conn, cert, sk) })
+	defer l.Close()
+	addr := modules.NetAddress(l.Addr().String())
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+
+	conn, err := dialHost(dialer, addr, hostKey, nil, crypto.SecretKey{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		t.Fatal("session was not upgraded to TLS")
+	}
+	if name := tls.CipherSuiteName(tlsConn.ConnectionState().CipherSuite); !strings.Contains(name, "GCM") && !strings.Contains(name, "CHACHA20") {
+		t.Error("session does not use an AEAD cipher suite:", name)
+	}
+	if _, err := conn.Write([]byte{7}); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(conn, b); err != nil || b[0] != 7 {
+		t.Fatal("upgraded session does not transfer data:", err)
+	}
+
+	// A host that signs its certificate with another key is rejected.
+	_, otherPK := crypto.GenerateKeyPair()
+	if _, err := dialHost(dialer, addr, types.Ed25519PublicKey(otherPK), nil, crypto.SecretKey{}); err == nil || !strings.Contains(err.Error(), modules.ErrHostCertSignature.Error()) {
+		t.Fatal("expected ErrHostCertSignature, got", err)
+	}
+}
+
+// TestStartSessionFallback checks that dialHost only falls back to a legacy
+// connection if the host closes the connection when asked for a versioned
+// session, and not if the host does not reply in time.
+func TestStartSessionFallback(t *testing.T) {
+	legacy := newTestSessionHost(t, func(conn net.Conn) {
+		// Legacy hosts read the specifier of the RPC, and close the
+		// connection because they do not know it.
+		var id types.Specifier
+		encoding.ReadObject(conn, &id, 16)
+	})
+	defer legacy.Close()
+	addr := modules.NetAddress(legacy.Addr().String())
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	_, pk := crypto.GenerateKeyPair()
+	hostKey := types.Ed25519PublicKey(pk)
+	conn, err := dialHost(dialer, addr, hostKey, nil, crypto.SecretKey{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	hostSessions.mu.Lock()
+	_, isLegacy := hostSessions.legacy[hostKey.String()]
+	hostSessions.mu.Unlock()
+	if !isLegacy {
+		t.Fatal("host that closed the connection was not recorded as a legacy host")
+	}
+
+	stalled := make(chan struct{})
+	defer close(stalled)
+	slow := newTestSessionHost(t, func(conn net.Conn) {
+		<-stalled
+	})
+	defer slow.Close()
+	addr = modules.NetAddress(slow.Addr().String())
+	dialer = &net.Dialer{Timeout: 100 * time.Millisecond}
+	_, pk = crypto.GenerateKeyPair()
+	hostKey = types.Ed25519PublicKey(pk)
+	if _, err := dialHost(dialer, addr, hostKey, nil, crypto.SecretKey{}); err == nil {
+		t.Fatal("expected a host that does not reply to fail the session")
+	}
+	hostSessions.mu.Lock()
+	_, isLegacy = hostSessions.legacy[hostKey.String()]
+	hostSessions.mu.Unlock()
+	if isLegacy {
+		t.Fatal("host that did not reply was recorded as a legacy host")
+	}
+}
+
+// TestStartSessionDowngrade checks that dialHost refuses to connect without
+// TLS to a host that has upgraded a session to TLS before, whether the
+// upgrade is missing from the RPCs of the host or the connection is closed.
+func TestStartSessionDowngrade(t *testing.T) {
+	cert, _ := newTestCert(t)
+	sk, pk := crypto.GenerateKeyPair()
+	hostKey := types.Ed25519PublicKey(pk)
+	var mode uint32
+	l := newTestSessionHost(t, func(conn net.Conn) {
+		switch atomic.LoadUint32(&mode) {
+		case 0:
+			serveTestSession(conn, cert, sk)
+		case 1:
+			// Strip RPCUpgradeTLS from the RPCs of the host.
+			var id types.Specifier
+			if encoding.ReadObject(conn, &id, 16) != nil {
+				return
+			}
+			encoding.WriteObject(conn, modules.RPCVersions{Versions: modules.SupportedRPCVersions})
+			var version uint64
+			if encoding.ReadObject(conn, &version, 8) == nil {
+				modules.WriteNegotiationAcceptance(conn)
+			}
+		case 2:
+			// Close the connection like a legacy host.
+			var id types.Specifier
+			encoding.ReadObject(conn, &id, 16)
+		}
+	})
+	defer l.Close()
+	addr := modules.NetAddress(l.Addr().String())
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+
+	conn, err := dialHost(dialer, addr, hostKey, nil, crypto.SecretKey{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	for _, m := range []uint32{1, 2} {
+		atomic.StoreUint32(&mode, m)
+		if _, err := dialHost(dialer, addr, hostKey, nil, crypto.SecretKey{}); err != errTLSDowngrade {
+			t.Fatal("expected errTLSDowngrade, got", err)
+		}
+	}
+	hostSessions.mu.Lock()
+	_, isLegacy := hostSessions.legacy[hostKey.String()]
+	hostSessions.mu.Unlock()
+	if isLegacy {
+		t.Fatal("host that upgraded to TLS before was recorded as a legacy host")
+	}
+}
+
+// TestTLSSessionOverhead checks that uploading sectors over a TLS session
+// takes less than 10% longer than over a plain connection, when the link
+// between the renter and the host is limited to the speed of a fast network.
+func TestTLSSessionOverhead(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	cert, _ := newTestCert(t)
+	const linkRate = 32 << 20
+	const transferSize = 16 << 20
+	upload := func(useTLS bool) time.Duration {
+		done := make(chan struct{})
+		l := newTestSessionHost(t, func(conn net.Conn) {
+			defer close(done)
+			if useTLS {
+				conn = tls.Server(conn, &tls.Config{
+					Certificates:             []tls.Certificate{cert},
+					CipherSuites:             modules.TLSCipherSuites,
+					PreferServerCipherSuites: true,
+				})
+			}
+			io.Copy(ioutil.Discard, conn)
+		})
+		defer l.Close()
+		raw, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The rate limit below TLS stands in for the network, so the bytes
+		// that TLS adds are limited as well.
+		conn := NewRateLimit(linkRate).wrapWrites(raw, nil)
+		if useTLS {
+			conn = tls.Client(conn, &tls.Config{
+				InsecureSkipVerify: true,
+				MinVersion:         tls.VersionTLS12,
+				CipherSuites:       modules.TLSCipherSuites,
+			})
+		}
+		sector := make([]byte, 1<<20)
+		start := time.Now()
+		for sent := 0; sent < transferSize; sent += len(sector) {
+			if err := encoding.WriteObject(conn, sector); err != nil {
+				t.Fatal(err)
+			}
+		}
+		conn.Close()
+		<-done
+		return time.Since(start)
+	}
+
+	plain := upload(false)
+	withTLS := upload(true)
+	if overhead := float64(withTLS)/float64(plain) - 1; overhead > 0.1 {
+		t.Fatalf("TLS session is %.1f%% slower than a plain connection (%v vs %v)", overhead*100, withTLS, plain)
+	}
+}
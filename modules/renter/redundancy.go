@@ -0,0 +1,484 @@
+package renter
+
+// redundancy.go implements ChangeRedundancy, which re-encodes files to new
+// erasure coding parameters. A new version of the file is created with the new
+// parameters, and its chunks are handed to the repair loop. The repair loop
+// fetches the data of each chunk from disk or from the current version of the
+// file, encodes it with the new parameters, and uploads the new pieces. Once
+// every chunk of the new version has reached full redundancy, the new version
+// atomically replaces the current version, and the pieces of the old version
+// are deleted from the hosts. Until then the current version of the file is
+// left untouched, so a redundancy change that fails or is interrupted never
+// affects the availability of the file.
+//
+// Chunks of redundancy changes are only repaired after all other chunks, and
+// at most maxRedundancyChangeChunks of them are in the repair loop at any
+// time, so that redundancy changes do not starve the repair of other files.
+
+import (
+	"container/heap"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// redundancyChangeExtension is appended to the path of the .sia file of a
+	// file to get the path of the new version of the file during a redundancy
+	// change.
+	redundancyChangeExtension = ".reencode"
+)
+
+var (
+	// errRedundancyChangeInProgress is returned if a file is renamed, or its
+	// redundancy is changed, while its redundancy is already being changed.
+	errRedundancyChangeInProgress = errors.New("the redundancy of the file is already being changed")
+
+	// errRedundancyUnchanged is returned if the redundancy of a file is
+	// changed to the erasure coding parameters that it already uses.
+	errRedundancyUnchanged = errors.New("file already uses the requested erasure coding parameters")
+)
+
+// redundancyChange tracks the re-encoding of a file to new erasure coding
+// parameters. The exported fields are persisted for unfinished redundancy
+// changes.
+type redundancyChange struct {
+	SiaPath      string
+	DataPieces   int
+	ParityPieces int
+	StartTime    time.Time
+
+	oldDataPieces   int
+	oldParityPieces int
+
+	// newVersion is the new version of the file. It is nil once the
+	// redundancy change has finished. nextChunk is the index of the next
+	// chunk of the new version that is handed to the repair loop.
+	newVersion *file
+	nextChunk  uint64
+
+	finishTime  time.Time
+	chunksTotal uint64
+}
+
+// erasureParams returns the number of data and parity pieces of a file.
+func erasureParams(f *file) (dataPieces, parityPieces int) {
+	return f.erasureCode.MinPieces(), f.erasureCode.NumPieces() - f.erasureCode.MinPieces()
+}
+
+// chunksAtFullRedundancy returns the number of chunks of the file that have
+// every one of their pieces stored on a contract that is not offline.
+func chunksAtFullRedundancy(f *file, isOffline func(types.FileContractID) bool) uint64 {
+	pieces := make([]map[uint64]struct{}, f.numChunks())
+	for _, fc := range f.contracts {
+		if isOffline(fc.ID) {
+			continue
+		}
+		for _, p := range fc.Pieces {
+			if pieces[p.Chunk] == nil {
+				pieces[p.Chunk] = make(map[uint64]struct{})
+			}
+			pieces[p.Chunk][p.Piece] = struct{}{}
+		}
+	}
+	var complete uint64
+	for _, chunkPieces := range pieces {
+		if len(chunkPieces) == f.erasureCode.NumPieces() {
+			complete++
+		}
+	}
+	return complete
+}
+
+// newVersionPath returns the path that the new version of the file at siaPath
+// is saved to during a redundancy change.
+func (r *Renter) newVersionPath(siaPath string) string {
+	return filepath.Join(r.persistDir, siaPath+ShareExtension+redundancyChangeExtension)
+}
+
+// loadNewVersion loads the new version of the file at siaPath from disk.
+func (r *Renter) loadNewVersion(siaPath string) (*file, error) {
+	osFile, err := os.Open(r.newVersionPath(siaPath))
+	if err != nil {
+		return nil, err
+	}
+	defer osFile.Close()
+	files, err := readSharedFiles(osFile)
+	if err != nil {
+		return nil, err
+	} else if len(files) != 1 || files[0].name != siaPath {
+		return nil, ErrBadFile
+	}
+	files[0].newVersion = true
+	return files[0], nil
+}
+
+// loadRedundancyChanges resumes the unfinished redundancy changes after the
+// renter's files have been loaded from disk. If the new version of a file
+// cannot be loaded, its redundancy change starts over.
+func (r *Renter) loadRedundancyChanges(changes []*redundancyChange) {
+	for _, rc := range changes {
+		f, exists := r.files[rc.SiaPath]
+		if !exists {
+			os.Remove(r.newVersionPath(rc.SiaPath))
+			continue
+		}
+		code, err := NewRSCode(rc.DataPieces, rc.ParityPieces)
+		if err != nil {
+			r.log.Println("WARN: dropping redundancy change with bad erasure coding parameters:", err)
+			continue
+		}
+		nf, err := r.loadNewVersion(rc.SiaPath)
+		if err != nil {
+			r.log.Println("WARN: restarting redundancy change of", rc.SiaPath+":", err)
+			nf = newFile(f.name, code, f.pieceSize, f.size)
+			nf.mode = f.mode
+			nf.checksum = f.checksum
+			nf.auditRequired = f.auditRequired
+			nf.newVersion = true
+		}
+		rc.oldDataPieces, rc.oldParityPieces = erasureParams(f)
+		rc.newVersion = nf
+		r.redundancyChanges[rc.SiaPath] = rc
+	}
+}
+
+// unfinishedRedundancyChanges returns the redundancy changes that have not
+// finished yet. The caller must hold the renter lock.
+func (r *Renter) unfinishedRedundancyChanges() []*redundancyChange {
+	var changes []*redundancyChange
+	for _, rc := range r.redundancyChanges {
+		if rc.newVersion != nil {
+			changes = append(changes, rc)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if !changes[i].StartTime.Equal(changes[j].StartTime) {
+			return changes[i].StartTime.Before(changes[j].StartTime)
+		}
+		return changes[i].SiaPath < changes[j].SiaPath
+	})
+	return changes
+}
+
+// startRedundancyChange starts re-encoding the file to the provided erasure
+// code. The caller must hold the renter lock.
+func (r *Renter) startRedundancyChange(f *file, code modules.ErasureCoder) error {
+	if rc, exists := r.redundancyChanges[f.name]; exists && rc.newVersion != nil {
+		return errRedundancyChangeInProgress
+	}
+	oldData, oldParity := erasureParams(f)
+	newData, newParity := code.MinPieces(), code.NumPieces()-code.MinPieces()
+	if oldData == newData && oldParity == newParity {
+		return errRedundancyUnchanged
+	}
+	if build.Release != "testing" {
+		if err := checkUploadContracts(code, len(r.fileContractor(f).Contracts())); err != nil {
+			return err
+		}
+	}
+
+	f.mu.RLock()
+	nf := newFile(f.name, code, f.pieceSize, f.size)
+	nf.mode = f.mode
+	nf.checksum = f.checksum
+	nf.auditRequired = f.auditRequired
+	f.mu.RUnlock()
+	nf.newVersion = true
+	rc := &redundancyChange{
+		SiaPath:      f.name,
+		DataPieces:   newData,
+		ParityPieces: newParity,
+		StartTime:    time.Now(),
+
+		oldDataPieces:   oldData,
+		oldParityPieces: oldParity,
+		newVersion:      nf,
+	}
+	prev, hadPrev := r.redundancyChanges[f.name]
+	r.redundancyChanges[f.name] = rc
+	// The new version of the file is repaired to the full redundancy of its
+	// erasure code.
+	tf, tracked := r.tracking[f.name]
+	if tracked {
+		cleared := tf
+		cleared.TargetRedundancy = 0
+		r.tracking[f.name] = cleared
+	}
+	err := r.saveFile(nf)
+	if err == nil {
+		err = r.saveSync()
+	}
+	if err != nil {
+		delete(r.redundancyChanges, f.name)
+		if hadPrev {
+			r.redundancyChanges[f.name] = prev
+		}
+		if tracked {
+			r.tracking[f.name] = tf
+		}
+		os.Remove(r.newVersionPath(f.name))
+		return err
+	}
+	return nil
+}
+
+// cancelRedundancyChange stops the redundancy change of the file at siaPath,
+// if any. The pieces of the new version that were already uploaded are left on
+// the hosts. The caller must hold the renter lock.
+func (r *Renter) cancelRedundancyChange(siaPath string) {
+	rc, exists := r.redundancyChanges[siaPath]
+	if !exists {
+		return
+	}
+	delete(r.redundancyChanges, siaPath)
+	if rc.newVersion != nil {
+		os.Remove(r.newVersionPath(siaPath))
+	}
+}
+
+// managedFinishRedundancyChanges replaces every file whose new version has
+// reached full redundancy with its new version, and then deletes the pieces
+// of the old versions from the hosts.
+func (r *Renter) managedFinishRedundancyChanges() {
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+
+	for _, rc := range r.unfinishedRedundancyChanges() {
+		nf := rc.newVersion
+		old, exists := r.files[rc.SiaPath]
+		if !exists {
+			r.cancelRedundancyChange(rc.SiaPath)
+			continue
+		}
+		hc := r.fileContractor(nf)
+		nf.mu.RLock()
+		complete := chunksAtFullRedundancy(nf, r.contractOfflineFunc(hc))
+		nf.mu.RUnlock()
+		if complete < nf.numChunks() && nf.size != 0 {
+			continue
+		}
+
+		// Forget the redundancy change before replacing the file, so that an
+		// interruption leaves either the old version in place with nothing
+		// left to resume, or the new version in place.
+		rc.newVersion = nil
+		err := r.saveSync()
+		if err == nil {
+			// The audit mark of the old version may have been set during
+			// the redundancy change.
+			old.mu.RLock()
+			auditRequired := old.auditRequired
+			old.mu.RUnlock()
+			nf.mu.Lock()
+			nf.newVersion = false
+			nf.auditRequired = nf.auditRequired || auditRequired
+			err = r.saveFile(nf)
+			if err != nil {
+				nf.newVersion = true
+			}
+			nf.mu.Unlock()
+		}
+		if err != nil {
+			r.log.Println("WARN: unable to replace file with its new version:", err)
+			rc.newVersion = nf
+			r.saveSync()
+			continue
+		}
+		r.files[rc.SiaPath] = nf
+		rc.finishTime = time.Now()
+		rc.chunksTotal = nf.numChunks()
+		os.Remove(r.newVersionPath(rc.SiaPath))
+		r.log.Printf("Changed the redundancy of %v from %v-of-%v to %v-of-%v\n", rc.SiaPath, rc.oldDataPieces, rc.oldDataPieces+rc.oldParityPieces, rc.DataPieces, rc.DataPieces+rc.ParityPieces)
+		go r.threadedDeletePieces(old, hc)
+	}
+}
+
+// managedQueueRedundancyChanges adds chunks of the unfinished redundancy
+// changes to the chunk heap, such that at most maxRedundancyChangeChunks of
+// them are in the repair loop. Chunks are handed out in order, starting over
+// from the first incomplete chunk if 'rebuilt' is set. The returned bool
+// indicates whether any chunks were added.
+func (r *Renter) managedQueueRedundancyChanges(ch *chunkHeap, hosts map[string]struct{}, rebuilt bool) bool {
+	r.managedFinishRedundancyChanges()
+
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	changes := r.unfinishedRedundancyChanges()
+	inRepair := 0
+	for _, rc := range changes {
+		if rebuilt {
+			rc.nextChunk = 0
+		}
+		rc.newVersion.mu.RLock()
+		inRepair += rc.newVersion.chunksInRepair
+		rc.newVersion.mu.RUnlock()
+	}
+
+	added := false
+	for _, rc := range changes {
+		if inRepair >= maxRedundancyChangeChunks {
+			break
+		}
+		var dropped int
+		for _, uc := range r.buildUnfinishedChunks(rc.newVersion, hosts) {
+			if uc.index < rc.nextChunk || inRepair >= maxRedundancyChangeChunks {
+				dropped++
+				continue
+			}
+			heap.Push(ch, uc)
+			rc.nextChunk = uc.index + 1
+			inRepair++
+			added = true
+		}
+		rc.newVersion.mu.Lock()
+		rc.newVersion.chunksInRepair -= dropped
+		rc.newVersion.mu.Unlock()
+	}
+	return added
+}
+
+// threadedDeletePieces deletes the pieces of the replaced version of a file
+// from the hosts that store them. Pieces that cannot be deleted are left on
+// the hosts until their contracts expire.
+func (r *Renter) threadedDeletePieces(f *file, hc hostContractor) {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	f.mu.RLock()
+	contracts := make([]fileContract, 0, len(f.contracts))
+	for _, fc := range f.contracts {
+		contracts = append(contracts, fc)
+	}
+	f.mu.RUnlock()
+	r.deleteContractPieces(contracts, hc)
+}
+
+// deleteContractPieces deletes the pieces of the provided contracts from the
+// hosts that store them. The caller must have called tg.Add.
+func (r *Renter) deleteContractPieces(contracts []fileContract, hc hostContractor) {
+	for _, fc := range contracts {
+		editor, err := hc.Editor(hc.ResolveID(fc.ID), r.tg.StopChan())
+		if err != nil {
+			r.log.Debugln("Unable to delete pieces from", fc.IP+":", err)
+			continue
+		}
+		for _, piece := range fc.Pieces {
+			if err := editor.Delete(piece.MerkleRoot); err != nil {
+				r.log.Debugln("Unable to delete a piece from", fc.IP+":", err)
+				break
+			}
+		}
+		editor.Close()
+	}
+}
+
+// ChangeRedundancy re-encodes the file at siaPath to the provided erasure
+// coding parameters. If siaPath is a directory, every file in the directory
+// and its subdirectories is re-encoded, skipping the files that already use
+// the parameters or whose redundancy is already being changed.
+func (r *Renter) ChangeRedundancy(siaPath string, dataPieces, parityPieces int) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	code, err := NewRSCode(dataPieces, parityPieces)
+	if err != nil {
+		return err
+	}
+
+	id := r.mu.Lock()
+	if f, exists := r.files[siaPath]; exists {
+		err = r.startRedundancyChange(f, code)
+	} else {
+		err = r.changeDirectoryRedundancy(siaPath, code)
+	}
+	r.mu.Unlock(id)
+	if err != nil {
+		return err
+	}
+
+	// Wake up the repair loop.
+	select {
+	case r.redundancyChangeReady <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// changeDirectoryRedundancy starts re-encoding every file in the directory at
+// siaPath to the provided erasure code. The caller must hold the renter lock.
+func (r *Renter) changeDirectoryRedundancy(siaPath string, code modules.ErasureCoder) error {
+	prefix := strings.TrimSuffix(siaPath, "/") + "/"
+	var names []string
+	for name := range r.files {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ErrUnknownPath
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		err := r.startRedundancyChange(r.files[name], code)
+		if err != nil && err != errRedundancyChangeInProgress && err != errRedundancyUnchanged {
+			return err
+		}
+	}
+	return nil
+}
+
+// RedundancyChanges returns the progress of the most recent redundancy change
+// of each file, ordered by their start time. Finished redundancy changes are
+// only reported until the renter restarts.
+func (r *Renter) RedundancyChanges() []modules.RedundancyChange {
+	id := r.mu.RLock()
+	var changes []*redundancyChange
+	for _, rc := range r.redundancyChanges {
+		changes = append(changes, rc)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if !changes[i].StartTime.Equal(changes[j].StartTime) {
+			return changes[i].StartTime.Before(changes[j].StartTime)
+		}
+		return changes[i].SiaPath < changes[j].SiaPath
+	})
+	infos := make([]modules.RedundancyChange, 0, len(changes))
+	for _, rc := range changes {
+		info := modules.RedundancyChange{
+			SiaPath:         rc.SiaPath,
+			OldDataPieces:   rc.oldDataPieces,
+			OldParityPieces: rc.oldParityPieces,
+			DataPieces:      rc.DataPieces,
+			ParityPieces:    rc.ParityPieces,
+			StartTime:       rc.StartTime,
+		}
+		if nf := rc.newVersion; nf != nil {
+			isOffline := r.contractOfflineFunc(r.fileContractor(nf))
+			nf.mu.RLock()
+			info.ChunksComplete = chunksAtFullRedundancy(nf, isOffline)
+			info.ChunksTotal = nf.numChunks()
+			info.UploadProgress = nf.uploadProgress()
+			nf.mu.RUnlock()
+		} else {
+			info.ChunksComplete = rc.chunksTotal
+			info.ChunksTotal = rc.chunksTotal
+			info.UploadProgress = 100
+			info.Finished = true
+			info.FinishTime = rc.finishTime
+		}
+		infos = append(infos, info)
+	}
+	r.mu.RUnlock(id)
+	return infos
+}
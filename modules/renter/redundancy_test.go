@@ -0,0 +1,119 @@
+package renter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestChangeRedundancy checks that redundancy changes can be started on files
+// and directories, leave the current version of the file untouched, and are
+// resumed when the renter restarts.
+func TestChangeRedundancy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Upload a few files. The renter has no contracts, so none of the pieces
+	// are uploaded and the redundancy changes cannot finish.
+	source := filepath.Join(build.TempDir("renter", t.Name()), "source")
+	if err := os.MkdirAll(filepath.Dir(source), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(source, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, siapath := range []string{"dir/a", "dir/sub/b", "c"} {
+		err = rt.renter.Upload(modules.FileUploadParams{Source: source, SiaPath: siapath})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := rt.renter.ChangeRedundancy("missing", 1, 1); err != ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+	if err := rt.renter.ChangeRedundancy("c", 0, 1); err == nil {
+		t.Fatal("expected an error for invalid erasure coding parameters")
+	}
+	if err := rt.renter.ChangeRedundancy("c", defaultDataPieces, defaultParityPieces); err != errRedundancyUnchanged {
+		t.Fatal("expected errRedundancyUnchanged, got", err)
+	}
+
+	// Change the redundancy of a directory and of a single file.
+	if err := rt.renter.ChangeRedundancy("dir/", 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.ChangeRedundancy("c", 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.ChangeRedundancy("dir/a", 1, 2); err != errRedundancyChangeInProgress {
+		t.Fatal("expected errRedundancyChangeInProgress, got", err)
+	}
+	if err := rt.renter.RenameFile("dir/a", "d"); err != errRedundancyChangeInProgress {
+		t.Fatal("expected errRedundancyChangeInProgress, got", err)
+	}
+	changes := rt.renter.RedundancyChanges()
+	if len(changes) != 3 {
+		t.Fatal("expected 3 redundancy changes, got", len(changes))
+	}
+	for _, rc := range changes {
+		if rc.Finished || rc.ChunksComplete != 0 || rc.ChunksTotal != 1 || rc.OldDataPieces != defaultDataPieces || rc.OldParityPieces != defaultParityPieces {
+			t.Error("redundancy change reported incorrectly:", rc)
+		}
+		if _, err := os.Stat(rt.renter.newVersionPath(rc.SiaPath)); err != nil {
+			t.Error("new version of the file was not saved:", err)
+		}
+	}
+
+	// Restart the renter. The redundancy changes should be resumed with the
+	// same new versions, and the files should keep their erasure code.
+	id := rt.renter.mu.RLock()
+	masterKey := rt.renter.redundancyChanges["c"].newVersion.masterKey
+	rt.renter.mu.RUnlock(id)
+	err = rt.renter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, rt.renter.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id = rt.renter.mu.RLock()
+	rc, exists := rt.renter.redundancyChanges["c"]
+	if !exists || rc.DataPieces != 2 || rc.ParityPieces != 3 || rc.newVersion == nil {
+		t.Fatal("redundancy change was not resumed:", rc)
+	}
+	if !bytes.Equal(rc.newVersion.masterKey[:], masterKey[:]) || !rc.newVersion.newVersion {
+		t.Error("new version of the file was not restored")
+	}
+	if rt.renter.files["c"].erasureCode.MinPieces() != defaultDataPieces {
+		t.Error("file was replaced before its new version was uploaded")
+	}
+	rt.renter.mu.RUnlock(id)
+	if changes := rt.renter.RedundancyChanges(); len(changes) != 3 {
+		t.Fatal("expected 3 redundancy changes after restart, got", len(changes))
+	}
+
+	// Deleting a file stops its redundancy change.
+	if err := rt.renter.DeleteFile("c"); err != nil {
+		t.Fatal(err)
+	}
+	if changes := rt.renter.RedundancyChanges(); len(changes) != 2 {
+		t.Fatal("expected 2 redundancy changes after deleting a file, got", len(changes))
+	}
+	if _, err := os.Stat(rt.renter.newVersionPath("c")); !os.IsNotExist(err) {
+		t.Error("new version of deleted file was not removed:", err)
+	}
+}
@@ -26,12 +26,16 @@ package renter
 
 import (
 	"errors"
+	"reflect"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/renter/contractor"
 	"github.com/NebulousLabs/Sia/modules/renter/hostdb"
+	"github.com/NebulousLabs/Sia/modules/renter/proto"
 	"github.com/NebulousLabs/Sia/persist"
 	siasync "github.com/NebulousLabs/Sia/sync"
 	"github.com/NebulousLabs/Sia/types"
@@ -44,6 +48,12 @@ var (
 	errNilCS         = errors.New("cannot create renter with nil consensus set")
 	errNilHdb        = errors.New("cannot create renter with nil hostdb")
 	errNilTpool      = errors.New("cannot create renter with nil transaction pool")
+
+	errRenewalThresholdSize = errors.New("renewal threshold must be less than period")
+	errRetryAttempts        = errors.New("upload retry attempts must not be negative")
+	errRetryBackoff         = errors.New("initial upload retry backoff must not exceed the maximum backoff")
+	errDownloadSpeed        = errors.New("max download speed must not be negative")
+	errUploadSpeed          = errors.New("max upload speed must not be negative")
 )
 
 var (
@@ -69,6 +79,10 @@ type hostDB interface {
 	// order of preference.
 	AllHosts() []modules.HostDBEntry
 
+	// AllHostsPaged returns a page of the hosts returned by AllHosts, sorted
+	// by public key, along with the total number of hosts.
+	AllHostsPaged(page, pageSize int) ([]modules.HostDBEntry, int, error)
+
 	// AverageContractPrice returns the average contract price of a host.
 	AverageContractPrice() types.Currency
 
@@ -87,6 +101,9 @@ type hostDB interface {
 	// of the host.
 	ScoreBreakdown(modules.HostDBEntry) modules.HostScoreBreakdown
 
+	// HostScores returns the scoring factors of every host in the hostdb.
+	HostScores() []modules.HostScore
+
 	// EstimateHostScore returns the estimated score breakdown of a host with the
 	// provided settings.
 	EstimateHostScore(modules.HostDBEntry) modules.HostScoreBreakdown
@@ -95,6 +112,10 @@ type hostDB interface {
 // A hostContractor negotiates, revises, renews, and provides access to file
 // contracts.
 type hostContractor interface {
+	// Alerts returns the alerts that are currently active on the
+	// hostContractor.
+	Alerts() []modules.RenterAlert
+
 	// SetAllowance sets the amount of money the contractor is allowed to
 	// spend on contracts over a given time period, divided among the number
 	// of hosts specified. Note that contractor can start forming contracts as
@@ -104,6 +125,45 @@ type hostContractor interface {
 	// Allowance returns the current allowance
 	Allowance() modules.Allowance
 
+	// SetRenewalPolicy sets whether contracts are renewed automatically, and
+	// how many blocks before expiry they are renewed.
+	SetRenewalPolicy(enabled bool, threshold types.BlockHeight) error
+
+	// RenewalPolicy returns the current renewal policy.
+	RenewalPolicy() (bool, types.BlockHeight)
+
+	// PendingRenewals returns the IDs of the contracts that are due to be
+	// renewed.
+	PendingRenewals() []types.FileContractID
+
+	// Identity returns the public key that the hostContractor identifies
+	// itself with to hosts.
+	Identity() types.SiaPublicKey
+
+	// SetPinnedHostCert pins the TLS certificate of a host. A nil certificate
+	// unpins it.
+	SetPinnedHostCert(types.SiaPublicKey, []byte) error
+
+	// SetDownloadRateLimit sets the RateLimit that limits the bandwidth of
+	// the downloads from hosts.
+	SetDownloadRateLimit(*proto.RateLimit)
+
+	// SetUploadRateLimit sets the RateLimit that limits the bandwidth of the
+	// uploads to hosts.
+	SetUploadRateLimit(*proto.RateLimit)
+
+	// SetBandwidthLimiter sets the BandwidthLimiter that limits the
+	// connections to hosts by the node's bandwidth budget.
+	SetBandwidthLimiter(*modules.BandwidthLimiter)
+
+	// SetGarbageContracts sets the contracts whose data is no longer used by
+	// the renter's files, which are not renewed.
+	SetGarbageContracts([]types.FileContractID)
+
+	// CancelContract marks a contract as cancelled. A cancelled contract is
+	// neither uploaded to nor renewed.
+	CancelContract(types.FileContractID) error
+
 	// Close closes the hostContractor.
 	Close() error
 
@@ -151,6 +211,14 @@ type hostContractor interface {
 type trackedFile struct {
 	// location of original file on disk
 	RepairPath string
+
+	// time at which the file was uploaded. Zero for files that were tracked
+	// before upload times were recorded.
+	UploadTime time.Time
+
+	// redundancy that the file is repaired to. Zero for the full redundancy
+	// of the file's erasure code.
+	TargetRedundancy float64
 }
 
 // A Renter is responsible for tracking all of the files that a user has
@@ -161,6 +229,7 @@ type Renter struct {
 	// tracking contains a list of files that the user intends to maintain. By
 	// default, files loaded through sharing are not maintained by the user.
 	files    map[string]*file
+	dirs     map[string]struct{}    // directories created with CreateDir
 	tracking map[string]trackedFile // map from nickname to metadata
 
 	// Work management.
@@ -175,6 +244,7 @@ type Renter struct {
 	downloadQueue []*download
 	newDownloads  chan *download
 	newUploads    chan *file
+	newChunks     chan *unfinishedChunk // chunks of streamed uploads
 	workerPool    map[types.FileContractID]*worker
 
 	// Memory management - baseMemory tracks how much memory the renter is
@@ -186,6 +256,76 @@ type Renter struct {
 	memoryAvailable uint64
 	newMemory       chan struct{}
 
+	// remoteRepairs holds a token for every chunk whose data the repair loop
+	// is downloading from hosts, limiting them to maxRemoteRepairs.
+	remoteRepairs chan struct{}
+
+	// cache stores recently downloaded chunks on disk, so that repeated
+	// downloads of a chunk do not fetch it from hosts again.
+	cache *DiskCache
+
+	// usage counts the piece data that is transferred for the files of every
+	// directory. It has its own lock.
+	usage *usageTracker
+
+	// uploadThroughput measures the rate at which the workers upload pieces,
+	// which the estimates of the time to full redundancy are based on.
+	uploadThroughput *throughputMeter
+
+	// hostBreakers are the circuit breakers of the hosts that the workers
+	// talk to. They have their own lock.
+	hostBreakers *circuitBreakers
+
+	// downloadLimit limits the combined bandwidth of the downloads of every
+	// portfolio's contractor. It has its own lock.
+	downloadLimit *proto.RateLimit
+
+	// uploadLimit limits the combined bandwidth of the uploads of every
+	// portfolio's contractor, including repairs. It has its own lock.
+	uploadLimit *proto.RateLimit
+
+	// bandwidthLimiter limits the connections of every portfolio's
+	// contractor by the node's bandwidth budget. It has its own lock.
+	bandwidthLimiter *modules.BandwidthLimiter
+
+	// uploadRetryPolicy determines how workers retry failed piece uploads. The
+	// zero value means that defaultUploadRetryPolicy is used.
+	uploadRetryPolicy modules.UploadRetryPolicy
+
+	// uploadChecksumAlgorithm is the algorithm of the checksums of new
+	// uploads. The empty algorithm means that the default algorithm is used.
+	uploadChecksumAlgorithm string
+
+	// allowanceHistory records the most recent successful changes of the
+	// allowance of the renter's portfolios, from oldest to newest.
+	allowanceHistory []modules.AllowanceRecord
+
+	// redundancyChanges contains the redundancy changes of the renter's
+	// files, keyed by siapath. redundancyChangeReady is signaled whenever a
+	// chunk of a redundancy change leaves the repair loop, or a new
+	// redundancy change is started.
+	redundancyChanges     map[string]*redundancyChange
+	redundancyChangeReady chan struct{}
+
+	// versions contains the version of the current file and the previous
+	// versions of every siapath that has been uploaded to more than once
+	// while versioning was enabled. A siapath whose file was deleted may
+	// still have previous versions. versionRetention contains the version
+	// retention of directories, keyed by the path of the directory.
+	versions         map[string]*fileVersions
+	versionRetention map[string]modules.VersionRetention
+
+	// garbageContracts is the number of abandoned contracts that were found
+	// by the last run of the contract garbage collector, see garbage.go.
+	garbageContracts int
+
+	// portfolios contains the contractors of the renter's named allowance
+	// portfolios. The default portfolio uses hostContractor. Contractors for
+	// new portfolios are created by newPortfolioContractor, which is called
+	// with the persist directory of the portfolio.
+	portfolios             map[string]hostContractor
+	newPortfolioContractor func(persistDir string) (hostContractor, error)
+
 	// Utilities.
 	cs             modules.ConsensusSet
 	hostContractor hostContractor
@@ -208,12 +348,16 @@ func New(g modules.Gateway, cs modules.ConsensusSet, wallet modules.Wallet, tpoo
 	if err != nil {
 		return nil, err
 	}
+	newPortfolioContractor := func(dir string) (hostContractor, error) {
+		return contractor.New(cs, wallet, tpool, hdb, dir)
+	}
 
-	return newRenter(cs, tpool, hdb, hc, persistDir)
+	return newRenter(cs, tpool, hdb, hc, newPortfolioContractor, persistDir)
 }
 
-// newRenter initializes a renter and returns it.
-func newRenter(cs modules.ConsensusSet, tpool modules.TransactionPool, hdb hostDB, hc hostContractor, persistDir string) (*Renter, error) {
+// newRenter initializes a renter and returns it. If newPortfolioContractor is
+// nil, the renter will only support the default portfolio.
+func newRenter(cs modules.ConsensusSet, tpool modules.TransactionPool, hdb hostDB, hc hostContractor, newPortfolioContractor func(string) (hostContractor, error), persistDir string) (*Renter, error) {
 	if cs == nil {
 		return nil, errNilCS
 	}
@@ -230,15 +374,33 @@ func newRenter(cs modules.ConsensusSet, tpool modules.TransactionPool, hdb hostD
 
 	r := &Renter{
 		files:    make(map[string]*file),
+		dirs:     make(map[string]struct{}),
 		tracking: make(map[string]trackedFile),
 
 		newDownloads: make(chan *download),
 		newUploads:   make(chan *file),
+		newChunks:    make(chan *unfinishedChunk),
 		workerPool:   make(map[types.FileContractID]*worker),
 
+		portfolios:             make(map[string]hostContractor),
+		newPortfolioContractor: newPortfolioContractor,
+
+		redundancyChanges:     make(map[string]*redundancyChange),
+		redundancyChangeReady: make(chan struct{}, 1),
+
+		versions:         make(map[string]*fileVersions),
+		versionRetention: make(map[string]modules.VersionRetention),
+
 		baseMemory:      defaultMemory,
 		memoryAvailable: defaultMemory,
 		newMemory:       make(chan struct{}, 1),
+		remoteRepairs:   make(chan struct{}, maxRemoteRepairs),
+
+		uploadThroughput: new(throughputMeter),
+		hostBreakers:     newCircuitBreakers(),
+		downloadLimit:    proto.NewRateLimit(0),
+		uploadLimit:      proto.NewRateLimit(0),
+		bandwidthLimiter: modules.NewBandwidthLimiter(modules.BandwidthClassRenter),
 
 		cs:             cs,
 		hostDB:         hdb,
@@ -247,6 +409,23 @@ func newRenter(cs modules.ConsensusSet, tpool modules.TransactionPool, hdb hostD
 		mu:             siasync.New(modules.SafeMutexDelay, 1),
 		tpool:          tpool,
 	}
+	// Every contractor shares the renter's download and upload limits and
+	// bandwidth limiter.
+	hc.SetDownloadRateLimit(r.downloadLimit)
+	hc.SetUploadRateLimit(r.uploadLimit)
+	hc.SetBandwidthLimiter(r.bandwidthLimiter)
+	if newPortfolioContractor != nil {
+		r.newPortfolioContractor = func(dir string) (hostContractor, error) {
+			hc, err := newPortfolioContractor(dir)
+			if err != nil {
+				return nil, err
+			}
+			hc.SetDownloadRateLimit(r.downloadLimit)
+			hc.SetUploadRateLimit(r.uploadLimit)
+			hc.SetBandwidthLimiter(r.bandwidthLimiter)
+			return hc, nil
+		}
+	}
 	if err := r.initPersist(); err != nil {
 		return nil, err
 	}
@@ -255,6 +434,8 @@ func newRenter(cs modules.ConsensusSet, tpool modules.TransactionPool, hdb hostD
 	r.managedUpdateWorkerPool()
 	go r.threadedRepairScan()
 	go r.threadedDownloadLoop()
+	go r.threadedContractGarbageCollector()
+	go r.threadedPruneVersions()
 
 	// Kill workers on shutdown.
 	r.tg.OnStop(func() error {
@@ -310,13 +491,51 @@ func (r *Renter) managedMemoryAvailableSub(amt uint64) {
 	r.mu.Unlock(id)
 }
 
+// managedMemoryAvailableTrySub subtracts the amount provided from the renter's
+// total memory available if that much memory is available, and returns whether
+// it did.
+func (r *Renter) managedMemoryAvailableTrySub(amt uint64) bool {
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	if r.memoryAvailable < amt {
+		return false
+	}
+	r.memoryAvailable -= amt
+	return true
+}
+
 // Close closes the Renter and its dependencies
 func (r *Renter) Close() error {
 	r.tg.Stop()
 	r.hostDB.Close()
+	id := r.mu.RLock()
+	for _, hc := range r.portfolios {
+		hc.Close()
+	}
+	r.mu.RUnlock(id)
 	return r.hostContractor.Close()
 }
 
+// Alerts returns the alerts of the contractors of all portfolios. Alerts that
+// are raised by several portfolios, such as the wallet being locked, are only
+// returned once.
+func (r *Renter) Alerts() []modules.RenterAlert {
+	var alerts []modules.RenterAlert
+	seen := make(map[modules.RenterAlertCategory]struct{})
+	for _, hc := range r.managedPortfolioContractors() {
+		for _, alert := range hc.Alerts() {
+			if _, exists := seen[alert.Category]; !exists {
+				seen[alert.Category] = struct{}{}
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].Category < alerts[j].Category
+	})
+	return alerts
+}
+
 // PriceEstimation estimates the cost in siacoins of performing various storage
 // and data operations.
 //
@@ -374,20 +593,147 @@ func (r *Renter) PriceEstimation() modules.RenterPriceEstimation {
 	}
 }
 
-// SetSettings will update the settings for the renter.
-func (r *Renter) SetSettings(s modules.RenterSettings) error {
-	err := r.hostContractor.SetAllowance(s.Allowance)
-	if err != nil {
-		return err
+// SetSettings changes the settings of the renter that are set in u. All of
+// the settings are validated before any of them are changed, and the
+// allowance is set first, since the contractor may reject it.
+func (r *Renter) SetSettings(u modules.RenterSettingsUpdate) error {
+	s := r.Settings()
+	if u.Allowance != nil {
+		s.Allowance = *u.Allowance
+	}
+	if u.AutoRenewEnabled != nil {
+		s.AutoRenewEnabled = *u.AutoRenewEnabled
+	}
+	if u.RenewalThresholdBlocks != nil {
+		s.RenewalThresholdBlocks = *u.RenewalThresholdBlocks
+	}
+	if u.UploadRetryPolicy != nil {
+		s.UploadRetryPolicy = *u.UploadRetryPolicy
+	}
+	if u.MaxDownloadSpeed != nil {
+		s.MaxDownloadSpeed = *u.MaxDownloadSpeed
+	}
+	if u.MaxUploadSpeed != nil {
+		s.MaxUploadSpeed = *u.MaxUploadSpeed
+	}
+	if u.ChecksumAlgorithm != nil {
+		s.ChecksumAlgorithm = *u.ChecksumAlgorithm
 	}
 
-	r.managedUpdateWorkerPool()
-	return nil
+	if s.Allowance.Period != 0 && s.RenewalThresholdBlocks >= s.Allowance.Period {
+		return errRenewalThresholdSize
+	}
+	if s.UploadRetryPolicy.MaxAttempts < 0 {
+		return errRetryAttempts
+	}
+	if s.UploadRetryPolicy.MaxBackoff != 0 && s.UploadRetryPolicy.InitialBackoff > s.UploadRetryPolicy.MaxBackoff {
+		return errRetryBackoff
+	}
+	if s.MaxDownloadSpeed < 0 {
+		return errDownloadSpeed
+	}
+	if s.MaxUploadSpeed < 0 {
+		return errUploadSpeed
+	}
+	if s.ChecksumAlgorithm != "" {
+		if _, err := newChecksumHash(s.ChecksumAlgorithm); err != nil {
+			return err
+		}
+	}
+
+	// The renewal policy is set first so that the maintenance triggered by
+	// SetAllowance uses it, and restored if the allowance can not be set, so
+	// that a failed call changes neither.
+	oldAutoRenew, oldThreshold := r.hostContractor.RenewalPolicy()
+	policyChanged := u.AutoRenewEnabled != nil || u.RenewalThresholdBlocks != nil
+	if policyChanged {
+		err := r.hostContractor.SetRenewalPolicy(s.AutoRenewEnabled, s.RenewalThresholdBlocks)
+		if err != nil {
+			return err
+		}
+	}
+	if u.Allowance != nil {
+		oldAllowance := r.hostContractor.Allowance()
+		err := r.hostContractor.SetAllowance(s.Allowance)
+		if err != nil {
+			if policyChanged {
+				r.hostContractor.SetRenewalPolicy(oldAutoRenew, oldThreshold)
+			}
+			return err
+		}
+		err = r.managedRecordAllowanceChange(modules.DefaultPortfolio, oldAllowance, s.Allowance)
+		if err != nil {
+			return err
+		}
+		r.managedUpdateWorkerPool()
+	}
+	if u.UploadRetryPolicy == nil && u.MaxDownloadSpeed == nil && u.MaxUploadSpeed == nil && u.ChecksumAlgorithm == nil {
+		return nil
+	}
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	r.uploadRetryPolicy = s.UploadRetryPolicy
+	r.downloadLimit.SetLimit(s.MaxDownloadSpeed)
+	r.uploadLimit.SetLimit(s.MaxUploadSpeed)
+	if u.ChecksumAlgorithm != nil {
+		r.uploadChecksumAlgorithm = s.ChecksumAlgorithm
+	}
+	return r.saveSync()
+}
+
+// managedRecordAllowanceChange adds a change of the allowance of a portfolio
+// to the allowance history and saves the history to disk. Settings that leave
+// the allowance unchanged are not recorded, and only the most recent
+// maxAllowanceHistory changes are kept.
+func (r *Renter) managedRecordAllowanceChange(portfolio string, oldAllowance, newAllowance modules.Allowance) error {
+	if reflect.DeepEqual(oldAllowance, newAllowance) {
+		return nil
+	}
+	record := modules.AllowanceRecord{
+		ChangedAt:    time.Now(),
+		Height:       r.cs.Height(),
+		Portfolio:    portfolio,
+		OldAllowance: oldAllowance,
+		NewAllowance: newAllowance,
+	}
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	r.allowanceHistory = append(r.allowanceHistory, record)
+	if len(r.allowanceHistory) > maxAllowanceHistory {
+		r.allowanceHistory = append([]modules.AllowanceRecord(nil), r.allowanceHistory[len(r.allowanceHistory)-maxAllowanceHistory:]...)
+	}
+	return r.saveSync()
+}
+
+// AllowanceHistory returns the most recent changes of the allowance of the
+// renter's portfolios, from oldest to newest.
+func (r *Renter) AllowanceHistory() []modules.AllowanceRecord {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	return append([]modules.AllowanceRecord(nil), r.allowanceHistory...)
+}
+
+// managedUploadRetryPolicy returns the policy that workers should use when
+// retrying failed piece uploads.
+func (r *Renter) managedUploadRetryPolicy() modules.UploadRetryPolicy {
+	id := r.mu.RLock()
+	policy := r.uploadRetryPolicy
+	r.mu.RUnlock(id)
+	if policy.MaxAttempts == 0 {
+		return defaultUploadRetryPolicy
+	}
+	if policy.MaxBackoff == 0 {
+		policy.MaxBackoff = defaultUploadRetryPolicy.MaxBackoff
+	}
+	return policy
 }
 
 // hostdb passthroughs
-func (r *Renter) ActiveHosts() []modules.HostDBEntry                      { return r.hostDB.ActiveHosts() }
-func (r *Renter) AllHosts() []modules.HostDBEntry                         { return r.hostDB.AllHosts() }
+func (r *Renter) ActiveHosts() []modules.HostDBEntry { return r.hostDB.ActiveHosts() }
+func (r *Renter) AllHosts() []modules.HostDBEntry    { return r.hostDB.AllHosts() }
+func (r *Renter) AllHostsPaged(page, pageSize int) ([]modules.HostDBEntry, int, error) {
+	return r.hostDB.AllHostsPaged(page, pageSize)
+}
 func (r *Renter) Host(spk types.SiaPublicKey) (modules.HostDBEntry, bool) { return r.hostDB.Host(spk) }
 func (r *Renter) ScoreBreakdown(e modules.HostDBEntry) modules.HostScoreBreakdown {
 	return r.hostDB.ScoreBreakdown(e)
@@ -395,16 +741,53 @@ func (r *Renter) ScoreBreakdown(e modules.HostDBEntry) modules.HostScoreBreakdow
 func (r *Renter) EstimateHostScore(e modules.HostDBEntry) modules.HostScoreBreakdown {
 	return r.hostDB.EstimateHostScore(e)
 }
+func (r *Renter) HostScores() []modules.HostScore { return r.hostDB.HostScores() }
+
+// SetBandwidthManager sets the manager of the node's bandwidth budget that the
+// connections to hosts draw from. A nil manager stops limiting them.
+func (r *Renter) SetBandwidthManager(bm modules.BandwidthManager) {
+	r.bandwidthLimiter.SetManager(bm)
+}
 
 // contractor passthroughs
-func (r *Renter) Contracts() []modules.RenterContract        { return r.hostContractor.Contracts() }
-func (r *Renter) CurrentPeriod() types.BlockHeight           { return r.hostContractor.CurrentPeriod() }
-func (r *Renter) PeriodSpending() modules.ContractorSpending { return r.hostContractor.PeriodSpending() }
+func (r *Renter) Contracts() []modules.RenterContract { return r.hostContractor.Contracts() }
+func (r *Renter) CurrentPeriod() types.BlockHeight    { return r.hostContractor.CurrentPeriod() }
+func (r *Renter) PeriodSpending() modules.ContractorSpending {
+	return r.hostContractor.PeriodSpending()
+}
+func (r *Renter) PendingRenewals() []types.FileContractID { return r.hostContractor.PendingRenewals() }
+func (r *Renter) Identity() types.SiaPublicKey            { return r.hostContractor.Identity() }
+func (r *Renter) SetPinnedHostCert(hostKey types.SiaPublicKey, cert []byte) error {
+	return r.hostContractor.SetPinnedHostCert(hostKey, cert)
+}
 func (r *Renter) Settings() modules.RenterSettings {
+	autoRenew, threshold := r.hostContractor.RenewalPolicy()
+	id := r.mu.RLock()
+	retryPolicy := r.uploadRetryPolicy
+	checksumAlgorithm := r.checksumAlgorithm()
+	r.mu.RUnlock(id)
 	return modules.RenterSettings{
-		Allowance: r.hostContractor.Allowance(),
+		Allowance:              r.hostContractor.Allowance(),
+		AutoRenewEnabled:       autoRenew,
+		RenewalThresholdBlocks: threshold,
+		UploadRetryPolicy:      retryPolicy,
+		MaxDownloadSpeed:       r.downloadLimit.Limit(),
+		MaxUploadSpeed:         r.uploadLimit.Limit(),
+		ChecksumAlgorithm:      checksumAlgorithm,
 	}
 }
+
+// TransferSpeeds returns the bandwidth that the renter currently transfers to
+// and from hosts, and the limits of the bandwidth.
+func (r *Renter) TransferSpeeds() modules.RenterBandwidth {
+	return modules.RenterBandwidth{
+		UploadSpeed:      r.uploadLimit.Rate(),
+		MaxUploadSpeed:   r.uploadLimit.Limit(),
+		DownloadSpeed:    r.downloadLimit.Rate(),
+		MaxDownloadSpeed: r.downloadLimit.Limit(),
+	}
+}
+
 func (r *Renter) AllContracts() []modules.RenterContract {
 	return r.hostContractor.(interface {
 		AllContracts() []modules.RenterContract
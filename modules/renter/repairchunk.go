@@ -12,18 +12,23 @@ import (
 // managedDistributeChunkToWorkers will take a chunk with fully prepared
 // physical data and distribute it to the worker pool.
 func (r *Renter) managedDistributeChunkToWorkers(uc *unfinishedChunk) {
-	// Give the chunk to each worker, marking the number of workers that have
-	// received the chunk. The workers cannot be interacted with while the
-	// renter is holding a lock, so we need to build a list of workers while
-	// under lock and then launch work jobs after that.
+	// Give the chunk to each worker of the chunk's portfolio, marking the
+	// number of workers that have received the chunk. The workers cannot be
+	// interacted with while the renter is holding a lock, so we need to build
+	// a list of workers while under lock and then launch work jobs after that.
 	id := r.mu.RLock()
-	uc.workersRemaining += len(r.workerPool)
-	r.heapWG.Add(len(r.workerPool))
 	workers := make([]*worker, 0, len(r.workerPool))
 	for _, worker := range r.workerPool {
-		workers = append(workers, worker)
+		if worker.portfolio == uc.portfolio {
+			workers = append(workers, worker)
+		}
 	}
+	uc.workersRemaining += len(workers)
+	r.heapWG.Add(len(workers))
 	r.mu.RUnlock(id)
+	if len(workers) == 0 {
+		uc.managedChunkRepairDone()
+	}
 	for _, worker := range workers {
 		worker.managedQueueChunkRepair(uc)
 	}
@@ -45,10 +50,23 @@ func (r *Renter) managedDownloadLogicalChunkData(chunk *unfinishedChunk) error {
 	// more than download memory, and if we need to allocate two times in a row
 	// from the same memory pool while other processes are asynchronously doing
 	// the same, we risk deadlock.
-	buf := NewDownloadBufferWriter(chunk.length, chunk.offset)
+	//
+	// The data of a chunk of a redundancy change is downloaded from the
+	// current version of the file. Its chunks have a different size, so the
+	// download is trimmed to the chunks that exist in the current version.
+	source := chunk.renterFile
+	length := chunk.length
+	if chunk.sourceFile != nil {
+		source = chunk.sourceFile
+		if padded := source.numChunks() * source.chunkSize(); uint64(chunk.offset)+length > padded {
+			length = padded - uint64(chunk.offset)
+		}
+	}
+	buf := NewDownloadBufferWriter(length, chunk.offset)
 	// TODO: Should convert the inputs of newSectionDownload to use an int64 for
 	// the offset.
-	d := r.newSectionDownload(chunk.renterFile, buf, uint64(chunk.offset), chunk.length)
+	d := r.newSectionDownload(source, buf, uint64(chunk.offset), length)
+	d.repair = true
 	select {
 	case r.newDownloads <- d:
 	case <-r.tg.StopChan():
@@ -63,7 +81,13 @@ func (r *Renter) managedDownloadLogicalChunkData(chunk *unfinishedChunk) error {
 		buf.data = nil
 		return d.Err()
 	} else {
-		chunk.logicalChunkData = buf.Bytes()
+		// Pad a trimmed chunk back to the size of the chunk, so that its
+		// pieces have the piece size of the file.
+		data := buf.Bytes()
+		if uint64(len(data)) < chunk.length {
+			data = append(data, make([]byte, chunk.length-uint64(len(data)))...)
+		}
+		chunk.logicalChunkData = data
 		return nil
 	}
 }
@@ -72,12 +96,26 @@ func (r *Renter) managedDownloadLogicalChunkData(chunk *unfinishedChunk) error {
 // the physical pieces for the chunk, and then distribute them. The returned
 // bool indicates whether the chunk was successfully distributed to workers.
 func (r *Renter) managedFetchAndRepairChunk(chunk *unfinishedChunk) bool {
-	// Only download this file if more than 25% of the redundancy is missing.
-	minMissingPiecesToDownload := (chunk.piecesNeeded - chunk.minimumPieces) / 4
-	download := chunk.piecesCompleted+minMissingPiecesToDownload < chunk.piecesNeeded
+	// Chunks whose data has to be downloaded from hosts wait for a remote
+	// repair token, which throttles the downloads of the repair loop. The
+	// token is taken here rather than in the repair loop, so that the loop
+	// keeps handing out chunks whose data is on disk while the downloads are
+	// busy.
+	remoteRepair := chunk.needsRemoteRepair()
+	if remoteRepair {
+		select {
+		case r.remoteRepairs <- struct{}{}:
+		case <-r.tg.StopChan():
+			return false
+		}
+	}
 
-	// Fetch the logical data for the chunk.
-	err := r.managedFetchLogicalChunkData(chunk, download)
+	// Fetch the logical data for the chunk. The token of a remote repair is
+	// returned once the data has been downloaded.
+	err := r.managedFetchLogicalChunkData(chunk, chunk.downloadAllowed())
+	if remoteRepair {
+		<-r.remoteRepairs
+	}
 	if err != nil {
 		// Logical data is not available, nothing to do.
 		r.log.Debugln("Fetching logical data of a chunk failed:", err)
@@ -125,12 +163,39 @@ func (r *Renter) managedFetchAndRepairChunk(chunk *unfinishedChunk) bool {
 	return true
 }
 
+// downloadAllowed returns whether the logical data of the chunk may be
+// downloaded from hosts if it is not available locally. Downloads are only
+// worth their cost if more than 25% of the redundancy is missing.
+func (uc *unfinishedChunk) downloadAllowed() bool {
+	minMissingPiecesToDownload := (uc.piecesNeeded - uc.minimumPieces) / 4
+	return uc.piecesCompleted+minMissingPiecesToDownload < uc.piecesNeeded
+}
+
+// needsRemoteRepair returns whether the logical data of the chunk will be
+// downloaded from hosts, because the local file of the chunk is gone.
+func (uc *unfinishedChunk) needsRemoteRepair() bool {
+	if uc.logicalChunkData != nil || !uc.downloadAllowed() {
+		return false
+	}
+	if uc.localPath == "" {
+		return true
+	}
+	_, err := os.Stat(uc.localPath)
+	return err != nil
+}
+
 // managedFetchLogicalChunkData will get the raw data for a chunk, pulling it from disk if
 // possible but otherwise queueing a download.
 //
 // chunk.data should be passed as 'nil' to the download, to keep memory usage as
 // light as possible.
 func (r *Renter) managedFetchLogicalChunkData(chunk *unfinishedChunk, download bool) error {
+	// The data of the chunks of streamed uploads is read before the chunks
+	// are added to the repair heap.
+	if chunk.logicalChunkData != nil {
+		return nil
+	}
+
 	// Download the chunk if it's not on disk.
 	if chunk.localPath == "" && download {
 		return r.managedDownloadLogicalChunkData(chunk)
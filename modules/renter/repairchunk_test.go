@@ -0,0 +1,105 @@
+package renter
+
+import (
+	"container/heap"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+)
+
+// TestNeedsRemoteRepair checks that chunks are only repaired from the data on
+// hosts if their local file is gone, and if enough of their redundancy is
+// missing.
+func TestNeedsRemoteRepair(t *testing.T) {
+	dir := build.TempDir("renter", t.Name())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(path, []byte("foo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A 10-of-30 chunk can be downloaded once more than 5 pieces are missing.
+	rsc, _ := NewRSCode(10, 20)
+	uc := newUnfinishedChunk(newFile("foo", rsc, 64, 640), 0, "", rsc.NumPieces(), nil)
+	uc.localPath = path
+	uc.piecesCompleted = 20
+	if uc.needsRemoteRepair() {
+		t.Fatal("chunk with a local file needs a remote repair")
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if !uc.needsRemoteRepair() {
+		t.Fatal("chunk without a local file does not need a remote repair")
+	}
+	uc.localPath = ""
+	if !uc.needsRemoteRepair() {
+		t.Fatal("chunk of a streamed file does not need a remote repair")
+	}
+
+	// Chunks that are missing little of their redundancy are not downloaded,
+	// and chunks whose data was read already do not need to be.
+	uc.piecesCompleted = 25
+	if uc.needsRemoteRepair() {
+		t.Fatal("chunk with most of its redundancy needs a remote repair")
+	}
+	uc.piecesCompleted = 0
+	uc.logicalChunkData = make([]byte, uc.length)
+	if uc.needsRemoteRepair() {
+		t.Fatal("chunk with logical data needs a remote repair")
+	}
+}
+
+// TestRemoteRepairDoesNotBlock checks that a chunk waiting for a remote
+// repair token does not keep the repair loop from preparing other chunks.
+func TestRemoteRepairDoesNotBlock(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Take every remote repair token.
+	for i := 0; i < maxRemoteRepairs; i++ {
+		rt.renter.remoteRepairs <- struct{}{}
+	}
+
+	// Both chunks have no local file, so they need a remote repair.
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, 64, 128)
+	ch := new(chunkHeap)
+	heap.Push(ch, newUnfinishedChunk(f, 0, "", rsc.NumPieces(), nil))
+	heap.Push(ch, newUnfinishedChunk(f, 1, "", rsc.NumPieces(), nil))
+	done := make(chan struct{})
+	go func() {
+		rt.renter.managedPrepareNextChunk(ch, nil)
+		rt.renter.managedPrepareNextChunk(ch, nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("repair loop was blocked by a chunk waiting for a remote repair token")
+	}
+
+	// Once the tokens are returned, the chunks are fetched and give their
+	// memory back, as the download fails without hosts.
+	memory := rt.renter.managedMemoryAvailableGet()
+	for i := 0; i < maxRemoteRepairs; i++ {
+		<-rt.renter.remoteRepairs
+	}
+	rt.renter.heapWG.Wait()
+	if rt.renter.managedMemoryAvailableGet() <= memory {
+		t.Fatal("memory of the chunks was not returned")
+	}
+}
@@ -39,6 +39,22 @@ type unfinishedChunk struct {
 	renterFile *file
 	localPath  string
 
+	// portfolio is the name of the portfolio that owns the file. Only the
+	// workers of that portfolio may upload pieces of the chunk.
+	portfolio string
+
+	// sourceFile is set if the chunk belongs to the new version of a file
+	// whose redundancy is being changed. It is the current version of the
+	// file, which the logical data is downloaded from. repairDone is signaled
+	// when such a chunk leaves the repair loop.
+	sourceFile *file
+	repairDone chan struct{}
+
+	// lostPieces explains why pieces that were uploaded to a host no longer
+	// count towards the redundancy of the chunk. It is only written while the
+	// chunk is built, and is used to record why the pieces were replaced.
+	lostPieces map[uint64]string
+
 	// Information about the chunk, namely where it exists within the file.
 	//
 	// TODO / NOTE: As we change the file mapper, we're probably going to have
@@ -71,6 +87,10 @@ type unfinishedChunk struct {
 // Implementation of heap.Interface for chunkHeap.
 func (ch chunkHeap) Len() int { return len(ch) }
 func (ch chunkHeap) Less(i, j int) bool {
+	// Chunks of redundancy changes are repaired after all other chunks.
+	if (ch[i].sourceFile == nil) != (ch[j].sourceFile == nil) {
+		return ch[i].sourceFile == nil
+	}
 	return float64(ch[i].piecesCompleted)/float64(ch[i].piecesNeeded) < float64(ch[j].piecesCompleted)/float64(ch[j].piecesNeeded)
 }
 func (ch chunkHeap) Swap(i, j int)       { ch[i], ch[j] = ch[j], ch[i] }
@@ -83,6 +103,36 @@ func (ch *chunkHeap) Pop() interface{} {
 	return x
 }
 
+// newUnfinishedChunk returns the chunk of f at index, which has none of its
+// pieces uploaded yet.
+func newUnfinishedChunk(f *file, index uint64, portfolio string, piecesNeeded int, hosts map[string]struct{}) *unfinishedChunk {
+	uc := &unfinishedChunk{
+		renterFile: f,
+		portfolio:  portfolio,
+
+		index:  index,
+		length: f.chunkSize(),
+		offset: int64(index * f.chunkSize()),
+
+		// memoryNeeded has to also include the logical data, and also
+		// include the overhead for encryption.
+		//
+		// TODO / NOTE: If we adjust the file to have a flexible encryption
+		// scheme, we'll need to adjust the overhead stuff too.
+		memoryNeeded:  f.pieceSize*uint64(f.erasureCode.NumPieces()+f.erasureCode.MinPieces()) + uint64(f.erasureCode.NumPieces()*crypto.TwofishOverhead),
+		minimumPieces: f.erasureCode.MinPieces(),
+		piecesNeeded:  piecesNeeded,
+		pieceUsage:    make([]bool, f.erasureCode.NumPieces()),
+		unusedHosts:   make(map[string]struct{}),
+		lostPieces:    make(map[uint64]string),
+	}
+	// Every chunk can have a different set of unused hosts.
+	for host := range hosts {
+		uc.unusedHosts[host] = struct{}{}
+	}
+	return uc
+}
+
 // buildUnfinishedChunks will pull all of the unfinished chunks out of a file.
 //
 // TODO / NOTE: This code can be substantially simplified once the files store
@@ -93,9 +143,19 @@ func (r *Renter) buildUnfinishedChunks(f *file, hosts map[string]struct{}) []*un
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	// If the file is not being tracked, don't repair it.
+	// If the file is not being tracked, don't repair it. The new version of a
+	// file whose redundancy is being changed is repaired regardless, as its
+	// data can be downloaded from the current version of the file.
 	trackedFile, exists := r.tracking[f.name]
-	if !exists {
+	var sourceFile *file
+	var repairDone chan struct{}
+	if f.newVersion {
+		sourceFile = r.files[f.name]
+		repairDone = r.redundancyChangeReady
+		if sourceFile == nil {
+			return nil
+		}
+	} else if !exists {
 		return nil
 	}
 
@@ -105,52 +165,58 @@ func (r *Renter) buildUnfinishedChunks(f *file, hosts map[string]struct{}) []*un
 	// number of chunks. Changes will be made due to things like sparse files,
 	// and the fact that chunks are going to be different sizes.
 	chunkCount := f.numChunks()
+	portfolio := r.portfolioForSiaPath(f.name)
+	piecesNeeded := f.erasureCode.NumPieces()
+	var counted [][]countedPiece
+	if !f.newVersion {
+		piecesNeeded = f.targetPieces(trackedFile.TargetRedundancy)
+	}
+	if piecesNeeded < f.erasureCode.NumPieces() {
+		counted = make([][]countedPiece, chunkCount)
+	}
 	newUnfinishedChunks := make([]*unfinishedChunk, chunkCount)
 	for i := uint64(0); i < chunkCount; i++ {
-		newUnfinishedChunks[i] = &unfinishedChunk{
-			renterFile: f,
-			localPath:  trackedFile.RepairPath,
-
-			index:  i,
-			length: f.chunkSize(),
-			offset: int64(i * f.chunkSize()),
-
-			// memoryNeeded has to also include the logical data, and also
-			// include the overhead for encryption.
-			//
-			// TODO / NOTE: If we adjust the file to have a flexible encryption
-			// scheme, we'll need to adjust the overhead stuff too.
-			memoryNeeded:  f.pieceSize*uint64(f.erasureCode.NumPieces()+f.erasureCode.MinPieces()) + uint64(f.erasureCode.NumPieces()*crypto.TwofishOverhead),
-			minimumPieces: f.erasureCode.MinPieces(),
-			piecesNeeded:  f.erasureCode.NumPieces(),
-			pieceUsage:    make([]bool, f.erasureCode.NumPieces()),
-			unusedHosts:   make(map[string]struct{}),
-		}
-		// Every chunk can have a different set of unused hosts.
-		for host := range hosts {
-			newUnfinishedChunks[i].unusedHosts[host] = struct{}{}
-		}
+		newUnfinishedChunks[i] = newUnfinishedChunk(f, i, portfolio, piecesNeeded, hosts)
+		newUnfinishedChunks[i].localPath = trackedFile.RepairPath
+		newUnfinishedChunks[i].sourceFile = sourceFile
+		newUnfinishedChunks[i].repairDone = repairDone
 	}
 
 	// Iterate through the contracts of the file and mark which hosts are
 	// already in use for the chunk. As you delete hosts from the 'unusedHosts'
 	// map, also increment the 'piecesCompleted' value.
 	saveFile := false
+	hc := r.fileContractor(f)
 	for fcid, fileContract := range f.contracts {
-		recentContract, exists := r.hostContractor.ResolveContract(fcid)
+		recentContract, exists := hc.ResolveContract(fcid)
 		if !exists {
 			// File contract does not seem to be part of the host anymore.
 			// Delete this contract and mark the file to be saved.
+			markLostPieces(newUnfinishedChunks, fileContract, pieceLostContractGone)
 			delete(f.contracts, fcid)
 			saveFile = true
 			continue
 		}
+		if recentContract.Cancelled {
+			// The contract was cancelled by the user, so its pieces are
+			// uploaded to other hosts.
+			markLostPieces(newUnfinishedChunks, fileContract, pieceLostContractCancelled)
+			continue
+		}
 		if !recentContract.GoodForUpload {
 			// We are no longer renewing with this contract, so it does not
 			// count for redundancy.
+			markLostPieces(newUnfinishedChunks, fileContract, pieceLostNotGoodForUpload)
 			continue
 		}
 		hpk := recentContract.HostPublicKey
+		if r.hostBreakers.managedUnavailable(hpk) {
+			// The host has been failing every request for a long time, so
+			// its pieces do not count for redundancy until its circuit
+			// breaker closes.
+			markLostPieces(newUnfinishedChunks, fileContract, pieceLostCircuitBreakerOpen)
+			continue
+		}
 
 		// Mark the chunk set based on the pieces in this contract.
 		for _, piece := range fileContract.Pieces {
@@ -160,6 +226,9 @@ func (r *Renter) buildUnfinishedChunks(f *file, hosts map[string]struct{}) []*un
 				newUnfinishedChunks[piece.Chunk].pieceUsage[piece.Piece] = true
 				newUnfinishedChunks[piece.Chunk].piecesCompleted++
 				delete(newUnfinishedChunks[piece.Chunk].unusedHosts, hpk.String())
+				if counted != nil {
+					counted[piece.Chunk] = append(counted[piece.Chunk], countedPiece{contract: fcid, piece: piece})
+				}
 			} else if exists {
 				// TODO / NOTE: This host has a piece, but it's the same piece
 				// that another host has. We may want to take action (such as
@@ -168,6 +237,15 @@ func (r *Renter) buildUnfinishedChunks(f *file, hosts map[string]struct{}) []*un
 			}
 		}
 	}
+	// Remove the pieces beyond the target redundancy of the file, and delete
+	// them from their hosts.
+	if counted != nil {
+		if removed := f.removeExcessPieces(newUnfinishedChunks, counted); len(removed) > 0 {
+			saveFile = true
+			go r.threadedDeleteExcessPieces(removed, hc)
+		}
+	}
+
 	// If 'saveFile' is marked, it means we deleted some dead contracts and
 	// cleaned up the file a bit. Save the file to clean up some space on disk
 	// and prevent the same work from being repeated after the next restart.
@@ -189,9 +267,24 @@ func (r *Renter) buildUnfinishedChunks(f *file, hosts map[string]struct{}) []*un
 			incompleteChunks = append(incompleteChunks, newUnfinishedChunks[i])
 		}
 	}
+	f.chunksInRepair += len(incompleteChunks)
 	return incompleteChunks
 }
 
+// managedChunkRepairDone marks that a chunk is no longer in the repair heap or
+// held by any worker.
+func (uc *unfinishedChunk) managedChunkRepairDone() {
+	uc.renterFile.mu.Lock()
+	uc.renterFile.chunksInRepair--
+	uc.renterFile.mu.Unlock()
+	if uc.repairDone != nil {
+		select {
+		case uc.repairDone <- struct{}{}:
+		default:
+		}
+	}
+}
+
 // managedBuildChunkHeap will iterate through all of the files in the renter and
 // construct a chunk heap.
 func (r *Renter) managedBuildChunkHeap(hosts map[string]struct{}) *chunkHeap {
@@ -215,11 +308,26 @@ func (r *Renter) managedBuildChunkHeap(hosts map[string]struct{}) *chunkHeap {
 // chunk heap.
 func (r *Renter) managedInsertFileIntoChunkHeap(f *file, ch *chunkHeap, hosts map[string]struct{}) {
 	id := r.mu.Lock()
-	unfinishedChunks := r.buildUnfinishedChunks(f, hosts)
-	for i := 0; i < len(unfinishedChunks); i++ {
-		heap.Push(ch, unfinishedChunks[i])
+	// If the heap was rebuilt after the file was sent to the repair loop, the
+	// chunks of the file are in the repair loop already, on top of the one
+	// that the file is counted with while it is sent. Inserting them again
+	// would upload every piece twice.
+	f.mu.RLock()
+	queued := f.chunksInRepair > 1
+	f.mu.RUnlock()
+	if !queued {
+		unfinishedChunks := r.buildUnfinishedChunks(f, hosts)
+		for i := 0; i < len(unfinishedChunks); i++ {
+			heap.Push(ch, unfinishedChunks[i])
+		}
 	}
 	r.mu.Unlock(id)
+
+	// The file was counted as being repaired while it was sent to the repair
+	// loop, its chunks are counted from now on.
+	f.mu.Lock()
+	f.chunksInRepair--
+	f.mu.Unlock()
 }
 
 // managedPrepareNextChunk takes the next chunk from the chunk heap and prepares
@@ -243,12 +351,15 @@ func (r *Renter) managedPrepareNextChunk(ch *chunkHeap, hosts map[string]struct{
 		select {
 		case newFile := <-r.newUploads:
 			r.managedInsertFileIntoChunkHeap(newFile, ch, hosts)
+		case uc := <-r.newChunks:
+			heap.Push(ch, uc)
 		case <-r.newMemory:
 			memoryAvailable = r.managedMemoryAvailableGet()
 		case <-r.tg.StopChan():
 		}
 	}
 	r.managedMemoryAvailableSub(nextChunk.memoryNeeded)
+
 	// Add this thread to the waitgroup. This Add will be released once the
 	// worker threads have been added to the wg.
 	r.heapWG.Add(1)
@@ -258,6 +369,7 @@ func (r *Renter) managedPrepareNextChunk(ch *chunkHeap, hosts map[string]struct{
 		if !workDistributed {
 			// Release any data that did not get distributed to workers.
 			r.managedMemoryAvailableAdd(nextChunk.memoryNeeded - nextChunk.memoryReleased)
+			nextChunk.managedChunkRepairDone()
 		} else {
 			nextChunk.mu.Lock()
 			nextChunk.mu.Unlock()
@@ -280,10 +392,18 @@ func (r *Renter) managedRefreshHostsAndWorkers() map[string]struct{} {
 	//
 	// TODO / NOTE: This code can be removed once files store the HostPubKey
 	// of the hosts they are using, instead of just the FileContractID.
-	currentContracts := r.hostContractor.Contracts()
+	//
+	// Hosts whose circuit breaker has been open for a long time are left
+	// out. The workers of hosts whose breaker opened more recently refuse new
+	// chunks by themselves.
 	hosts := make(map[string]struct{})
-	for _, contract := range currentContracts {
-		hosts[contract.HostPublicKey.String()] = struct{}{}
+	for _, hc := range r.managedPortfolioContractors() {
+		for _, contract := range hc.Contracts() {
+			if r.hostBreakers.managedUnavailable(contract.HostPublicKey) {
+				continue
+			}
+			hosts[contract.HostPublicKey.String()] = struct{}{}
+		}
 	}
 
 	// Refresh the worker pool as well.
@@ -318,8 +438,10 @@ func (r *Renter) threadedRepairScan() {
 		// useful for uploading.
 		hosts := r.managedRefreshHostsAndWorkers()
 
-		// Build a min-heap of chunks organized by upload progress.
+		// Build a min-heap of chunks organized by upload progress. The chunks
+		// of redundancy changes are added after the chunks of all files.
 		chunkHeap := r.managedBuildChunkHeap(hosts)
+		r.managedQueueRedundancyChanges(chunkHeap, hosts, true)
 		r.log.Println("Repairing", chunkHeap.Len(), "chunks")
 
 		// Work through the heap. Chunks will be processed one at a time until
@@ -339,6 +461,9 @@ func (r *Renter) threadedRepairScan() {
 
 			if chunkHeap.Len() > 0 {
 				r.managedPrepareNextChunk(chunkHeap, hosts)
+			} else if r.managedQueueRedundancyChanges(chunkHeap, hosts, false) {
+				// Chunks of redundancy changes were added to the heap.
+				continue
 			} else {
 				// Block until the rebuild signal is received.
 				select {
@@ -350,6 +475,16 @@ func (r *Renter) threadedRepairScan() {
 					hosts = r.managedRefreshHostsAndWorkers()
 					r.managedInsertFileIntoChunkHeap(newFile, chunkHeap, hosts)
 					continue
+				case uc := <-r.newChunks:
+					// A chunk of a streamed upload was read, add it to the
+					// repair heap.
+					heap.Push(chunkHeap, uc)
+					continue
+				case <-r.redundancyChangeReady:
+					// A chunk of a redundancy change has left the repair
+					// loop, or a redundancy change was started. Loop to
+					// queue the next chunks of the redundancy changes.
+					continue
 				case <-rebuildHeapSignal:
 					// If the rebuild heap signal is received, break out to the
 					// outer loop which will check the health of all filess
@@ -0,0 +1,206 @@
+package renter
+
+// targetredundancy.go implements SetFileRedundancy, which changes the
+// redundancy that the repair loop maintains for a file without re-encoding it.
+// The target redundancy is stored with the tracking metadata of the file, and
+// limits the number of pieces of every chunk that the repair loop uploads. A
+// target of 0 means the full redundancy of the file's erasure code.
+//
+// If the target is raised, the file is handed to the repair loop immediately
+// so that the missing pieces are uploaded. If the target is lowered, the pieces
+// beyond the target are removed from the file and deleted from their hosts the
+// next time the repair loop builds the chunks of the file. Pieces with the
+// lowest indices are kept, so that lowering the target to 1 keeps the data
+// pieces of every chunk. A target beyond the redundancy of the erasure code
+// starts a redundancy change to an erasure code with more parity pieces.
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// errInvalidTargetRedundancy is returned if the target redundancy of a
+	// file is set to a value below 1, at which the file is not available.
+	errInvalidTargetRedundancy = errors.New("target redundancy must be at least 1")
+
+	// errFileNotTracked is returned if the target redundancy of a file that
+	// is not repaired by the renter is set.
+	errFileNotTracked = errors.New("file is not repaired by the renter")
+)
+
+// piecesForRedundancy returns the number of pieces of each chunk that a file
+// with minPieces data pieces needs to reach redundancy.
+func piecesForRedundancy(redundancy float64, minPieces int) int {
+	// Allow for floating point error in redundancies such as 1.1.
+	return int(math.Ceil(redundancy*float64(minPieces) - 1e-9))
+}
+
+// targetPieces returns the number of pieces of each chunk of the file that the
+// repair loop maintains for the provided target redundancy.
+func (f *file) targetPieces(target float64) int {
+	numPieces := f.erasureCode.NumPieces()
+	if target == 0 {
+		return numPieces
+	}
+	if n := piecesForRedundancy(target, f.erasureCode.MinPieces()); n < numPieces {
+		return n
+	}
+	return numPieces
+}
+
+// targetRedundancy returns the redundancy that the repair loop maintains for
+// the file for the provided target redundancy.
+func (f *file) targetRedundancy(target float64) float64 {
+	return float64(f.targetPieces(target)) / float64(f.erasureCode.MinPieces())
+}
+
+// countedPiece is a piece that counts towards the redundancy of its chunk.
+type countedPiece struct {
+	contract types.FileContractID
+	piece    pieceData
+}
+
+// removeExcessPieces removes the pieces of the chunks that have more counted
+// pieces than they need from the file, keeping the pieces with the lowest
+// indices. The removed pieces are returned grouped by contract. The caller
+// must hold the lock of the file.
+func (f *file) removeExcessPieces(chunks []*unfinishedChunk, counted [][]countedPiece) []fileContract {
+	excess := make(map[types.FileContractID]map[pieceKey]struct{})
+	for i, uc := range chunks {
+		if uc.piecesCompleted <= uc.piecesNeeded {
+			continue
+		}
+		pieces := counted[i]
+		sort.Slice(pieces, func(a, b int) bool {
+			return pieces[a].piece.Piece < pieces[b].piece.Piece
+		})
+		for _, cp := range pieces[uc.piecesNeeded:] {
+			if excess[cp.contract] == nil {
+				excess[cp.contract] = make(map[pieceKey]struct{})
+			}
+			excess[cp.contract][pieceKey{chunk: cp.piece.Chunk, piece: cp.piece.Piece}] = struct{}{}
+			uc.pieceUsage[cp.piece.Piece] = false
+		}
+		uc.piecesCompleted = uc.piecesNeeded
+	}
+
+	var removed []fileContract
+	for fcid, keys := range excess {
+		fc := f.contracts[fcid]
+		deleted := fileContract{ID: fc.ID, IP: fc.IP, WindowStart: fc.WindowStart}
+		var kept []pieceData
+		for _, p := range fc.Pieces {
+			if _, exists := keys[pieceKey{chunk: p.Chunk, piece: p.Piece}]; exists {
+				deleted.Pieces = append(deleted.Pieces, p)
+			} else {
+				kept = append(kept, p)
+			}
+		}
+		fc.Pieces = kept
+		f.contracts[fcid] = fc
+		removed = append(removed, deleted)
+	}
+	return removed
+}
+
+// threadedDeleteExcessPieces deletes the pieces that were removed from a file
+// because they exceeded its target redundancy from their hosts.
+func (r *Renter) threadedDeleteExcessPieces(contracts []fileContract, hc hostContractor) {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+	r.deleteContractPieces(contracts, hc)
+}
+
+// SetFileRedundancy sets the redundancy that the renter maintains for the file
+// at siaPath. Pieces are uploaded immediately if the redundancy is raised, and
+// deleted during the next repair of the file if it is lowered. A redundancy
+// beyond that of the file's erasure code re-encodes the file with more parity
+// pieces.
+func (r *Renter) SetFileRedundancy(siaPath string, redundancy float64) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	// NaN fails the comparison as well.
+	if !(redundancy >= 1) {
+		return errInvalidTargetRedundancy
+	}
+
+	id := r.mu.Lock()
+	f, exists := r.files[siaPath]
+	if !exists {
+		r.mu.Unlock(id)
+		return ErrUnknownPath
+	}
+	tf, tracked := r.tracking[siaPath]
+	if !tracked {
+		r.mu.Unlock(id)
+		return errFileNotTracked
+	}
+	oldPieces := f.targetPieces(tf.TargetRedundancy)
+	dataPieces := f.erasureCode.MinPieces()
+	reencode := piecesForRedundancy(redundancy, dataPieces) > f.erasureCode.NumPieces()
+	if reencode {
+		code, err := NewRSCode(dataPieces, piecesForRedundancy(redundancy, dataPieces)-dataPieces)
+		if err == nil {
+			err = r.startRedundancyChange(f, code)
+		}
+		if err != nil {
+			r.mu.Unlock(id)
+			return err
+		}
+		tf = r.tracking[siaPath]
+	}
+	tf.TargetRedundancy = redundancy
+	r.tracking[siaPath] = tf
+	err := r.saveSync()
+	r.mu.Unlock(id)
+	if err != nil {
+		return err
+	}
+
+	if reencode {
+		// Wake up the repair loop.
+		select {
+		case r.redundancyChangeReady <- struct{}{}:
+		default:
+		}
+		return nil
+	}
+	if f.targetPieces(redundancy) <= oldPieces {
+		return nil
+	}
+
+	// Hand the file to the repair loop to upload the missing pieces.
+	r.managedQueueRepair(f)
+	return nil
+}
+
+// managedQueueRepair hands the file f to the repair loop to upload its
+// missing pieces. If any chunks of the file are being repaired, the missing
+// pieces are uploaded once the repair loop rebuilds its chunk heap instead, so
+// that the same pieces are not uploaded twice.
+func (r *Renter) managedQueueRepair(f *file) {
+	f.mu.Lock()
+	queue := f.chunksInRepair == 0
+	if queue {
+		f.chunksInRepair++
+	}
+	f.mu.Unlock()
+	if !queue {
+		return
+	}
+	select {
+	case r.newUploads <- f:
+	case <-r.tg.StopChan():
+		f.mu.Lock()
+		f.chunksInRepair--
+		f.mu.Unlock()
+	}
+}
@@ -0,0 +1,146 @@
+package renter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestSetFileRedundancy checks that raising the target redundancy of a file
+// uploads the missing pieces, that lowering it removes the pieces beyond the
+// target during the next repair, and that a target beyond the erasure code
+// starts a redundancy change.
+func TestSetFileRedundancy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	tc := &throttledContractor{uploadTime: time.Millisecond}
+	hosts := make(map[string]struct{})
+	for i := 0; i < 3; i++ {
+		c := modules.RenterContract{
+			ID:            types.FileContractID{byte(i + 1)},
+			HostPublicKey: types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{byte(i + 1)}},
+			GoodForUpload: true,
+			GoodForRenew:  true,
+		}
+		tc.contracts = append(tc.contracts, c)
+		hosts[c.HostPublicKey.String()] = struct{}{}
+	}
+	rt, err := newContractorTester(t.Name(), nil, tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Upload a file of 4 chunks with a 1-of-3 erasure code.
+	ec, err := NewRSCode(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := build.TempDir("renter", t.Name(), "source")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(source, fastrand.Bytes(int(4*pieceSize)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	err = rt.renter.Upload(modules.FileUploadParams{
+		Source:      source,
+		SiaPath:     "foo",
+		ErasureCode: ec,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForRedundancy := func(redundancy float64) {
+		err := build.Retry(100, 50*time.Millisecond, func() error {
+			if fi := rt.renter.FileList()[0]; fi.Redundancy != redundancy {
+				return fmt.Errorf("file has a redundancy of %v, expected %v", fi.Redundancy, redundancy)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitForRedundancy(3)
+	if fi := rt.renter.FileList()[0]; fi.TargetRedundancy != 3 {
+		t.Fatal("expected a target redundancy of 3, got", fi.TargetRedundancy)
+	}
+
+	for _, redundancy := range []float64{0, 0.5, math.NaN()} {
+		if err := rt.renter.SetFileRedundancy("foo", redundancy); err != errInvalidTargetRedundancy {
+			t.Fatalf("expected errInvalidTargetRedundancy for %v, got %v", redundancy, err)
+		}
+	}
+	if err := rt.renter.SetFileRedundancy("missing", 2); err != ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+
+	// Lowering the target keeps the pieces until the file is repaired.
+	if err := rt.renter.SetFileRedundancy("foo", 2); err != nil {
+		t.Fatal(err)
+	}
+	if fi := rt.renter.FileList()[0]; fi.TargetRedundancy != 2 || fi.Redundancy != 3 {
+		t.Fatalf("wrong redundancy after lowering the target: %+v", fi)
+	}
+	id := rt.renter.mu.Lock()
+	f := rt.renter.files["foo"]
+	chunks := rt.renter.buildUnfinishedChunks(f, hosts)
+	rt.renter.mu.Unlock(id)
+	if len(chunks) != 0 {
+		t.Fatal("expected no chunks to repair, got", len(chunks))
+	}
+	f.mu.RLock()
+	pieces := make(map[pieceKey]int)
+	for _, fc := range f.contracts {
+		for _, p := range fc.Pieces {
+			pieces[pieceKey{chunk: p.Chunk, piece: p.Piece}]++
+		}
+	}
+	f.mu.RUnlock()
+	if len(pieces) != 8 {
+		t.Fatal("expected 8 pieces after lowering the target, got", len(pieces))
+	}
+	for pk := range pieces {
+		if pk.piece > 1 {
+			t.Fatal("piece beyond the target was kept:", pk)
+		}
+	}
+	if fi := rt.renter.FileList()[0]; fi.Redundancy != 2 {
+		t.Fatal("expected a redundancy of 2 after the repair, got", fi.Redundancy)
+	}
+
+	// Raising the target uploads the missing pieces immediately.
+	if err := rt.renter.SetFileRedundancy("foo", 3); err != nil {
+		t.Fatal(err)
+	}
+	waitForRedundancy(3)
+
+	// A target beyond the erasure code re-encodes the file.
+	if err := rt.renter.SetFileRedundancy("foo", 4.5); err != nil {
+		t.Fatal(err)
+	}
+	changes := rt.renter.RedundancyChanges()
+	if len(changes) != 1 || changes[0].DataPieces != 1 || changes[0].ParityPieces != 4 {
+		t.Fatalf("expected a redundancy change to 1-of-5, got %+v", changes)
+	}
+	id = rt.renter.mu.RLock()
+	target := rt.renter.tracking["foo"].TargetRedundancy
+	rt.renter.mu.RUnlock(id)
+	if target != 4.5 {
+		t.Fatal("target redundancy was not kept for the new version, got", target)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -83,60 +84,124 @@ func validateSource(sourcePath string) error {
 	return nil
 }
 
-// Upload instructs the renter to start tracking a file. The renter will
-// automatically upload and repair tracked files using a background loop.
-func (r *Renter) Upload(up modules.FileUploadParams) error {
-	// Enforce nickname rules.
-	if err := validateSiapath(up.SiaPath); err != nil {
-		return err
-	}
-	// Enforce source rules.
-	if err := validateSource(up.Source); err != nil {
-		return err
+// checkUploadContracts returns an error if nContracts contracts are too few to
+// upload a file that is encoded with ec. We need at least (data + parity/2)
+// contracts; since NumPieces = data + parity, we arrive at the expression
+// below.
+func checkUploadContracts(ec modules.ErasureCoder, nContracts int) error {
+	if needed := (ec.NumPieces() + ec.MinPieces()) / 2; nContracts < needed {
+		return fmt.Errorf("not enough contracts to upload file: got %v, needed %v", nContracts, needed)
 	}
+	return nil
+}
 
-	// Check for a nickname conflict.
+// managedAddUploadFile creates the file of the upload up and adds it to the
+// renter, turning the current file at the siapath into a previous version. If
+// tf is nil, the file is not tracked, and it is not repaired by the repair
+// loop. If tf has a repair path, the checksum of the file is computed from the
+// local file.
+func (r *Renter) managedAddUploadFile(up modules.FileUploadParams, size uint64, mode uint32, tf *trackedFile) (*file, error) {
+	// Check for a nickname conflict. Uploads to a siapath that is in use
+	// create a new version of the file if the version retention of the file
+	// keeps previous versions.
 	lockID := r.mu.RLock()
 	_, exists := r.files[up.SiaPath]
+	pathErr := r.checkFilePath(up.SiaPath)
+	hc, _ := r.portfolioContractor(r.portfolioForSiaPath(up.SiaPath))
+	checksumAlgorithm := r.checksumAlgorithm()
+	retention := r.retentionFor(up.SiaPath)
 	r.mu.RUnlock(lockID)
-	if exists {
-		return ErrPathOverload
+	if exists && !keepsVersions(retention) {
+		return nil, ErrPathOverload
+	}
+	if pathErr != nil {
+		return nil, pathErr
 	}
 
 	// Fill in any missing upload params with sensible defaults.
-	fileInfo, err := os.Stat(up.Source)
-	if err != nil {
-		return err
+	if up.ErasureCode == nil && (up.DataPieces != 0 || up.ParityPieces != 0) {
+		ec, err := NewRSCode(up.DataPieces, up.ParityPieces)
+		if err != nil {
+			return nil, build.ExtendErr("invalid erasure coding parameters", err)
+		}
+		up.ErasureCode = ec
 	}
 	if up.ErasureCode == nil {
 		up.ErasureCode, _ = NewRSCode(defaultDataPieces, defaultParityPieces)
 	}
 
-	// Check that we have contracts to upload to. We need at least (data +
-	// parity/2) contracts; since NumPieces = data + parity, we arrive at the
-	// expression below.
-	if nContracts := len(r.hostContractor.Contracts()); nContracts < (up.ErasureCode.NumPieces()+up.ErasureCode.MinPieces())/2 && build.Release != "testing" {
-		return fmt.Errorf("not enough contracts to upload file: got %v, needed %v", nContracts, (up.ErasureCode.NumPieces()+up.ErasureCode.MinPieces())/2)
+	// Check that we have contracts to upload to.
+	if build.Release != "testing" {
+		if err := checkUploadContracts(up.ErasureCode, len(hc.Contracts())); err != nil {
+			return nil, err
+		}
 	}
 
 	// Create file object.
-	f := newFile(up.SiaPath, up.ErasureCode, pieceSize, uint64(fileInfo.Size()))
-	f.mode = uint32(fileInfo.Mode())
+	f := newFile(up.SiaPath, up.ErasureCode, pieceSize, size)
+	f.mode = mode
+	if tf != nil && tf.RepairPath != "" {
+		checksum, err := computeFileChecksum(tf.RepairPath, checksumAlgorithm, f.chunkSize(), size)
+		if err != nil {
+			return nil, build.ExtendErr("unable to compute the checksum of the file", err)
+		}
+		f.checksum = checksum
+	}
 
-	// Add file to renter.
+	// Add file to renter, turning the current file into a previous version.
 	lockID = r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	if err := r.checkFilePath(up.SiaPath); err != nil {
+		return nil, err
+	}
+	if current, exists := r.files[up.SiaPath]; exists {
+		retention := r.retentionFor(up.SiaPath)
+		if !keepsVersions(retention) {
+			return nil, ErrPathOverload
+		}
+		if err := r.addVersion(current, retention); err != nil {
+			return nil, err
+		}
+	}
 	r.files[up.SiaPath] = f
-	r.tracking[up.SiaPath] = trackedFile{
-		RepairPath: up.Source,
+	if tf != nil {
+		r.tracking[up.SiaPath] = *tf
 	}
 	r.saveSync()
-	err = r.saveFile(f)
-	r.mu.Unlock(lockID)
+	if err := r.saveFile(f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Upload instructs the renter to start tracking a file. The renter will
+// automatically upload and repair tracked files using a background loop.
+func (r *Renter) Upload(up modules.FileUploadParams) error {
+	// Enforce nickname rules.
+	if err := validateSiapath(up.SiaPath); err != nil {
+		return err
+	}
+	// Enforce source rules.
+	if err := validateSource(up.Source); err != nil {
+		return err
+	}
+	fileInfo, err := os.Stat(up.Source)
+	if err != nil {
+		return err
+	}
+	f, err := r.managedAddUploadFile(up, uint64(fileInfo.Size()), uint32(fileInfo.Mode()), &trackedFile{
+		RepairPath: up.Source,
+		UploadTime: time.Now(),
+	})
 	if err != nil {
 		return err
 	}
 
-	// Send the upload to the repair loop.
+	// Send the upload to the repair loop. The file counts as being repaired
+	// until the repair loop has added its chunks to the heap.
+	f.mu.Lock()
+	f.chunksInRepair++
+	f.mu.Unlock()
 	r.newUploads <- f
 	return nil
 }
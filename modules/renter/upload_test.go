@@ -1,11 +1,18 @@
 package renter
 
 import (
+	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
 )
 
 // TestRenterSiapathValidate verifies that the validateSiapath function correctly validates SiaPaths.
@@ -72,3 +79,273 @@ func TestRenterUploadInode(t *testing.T) {
 		t.Fatal("expected errUploadDirectory, got", err)
 	}
 }
+
+// TestUploadRetryDelay checks that upload retries follow the backoff schedule
+// of the retry policy.
+func TestUploadRetryDelay(t *testing.T) {
+	policy := modules.UploadRetryPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+	}
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{1, 0},
+		{2, time.Second},
+		{3, 5 * time.Second},
+		{4, 30 * time.Second},
+		{5, time.Minute},
+		{9, time.Minute},
+	}
+	for _, test := range tests {
+		delay := uploadRetryDelay(policy, test.failures)
+		min := test.expected - test.expected/4
+		max := test.expected + test.expected/4
+		if delay < min || delay > max {
+			t.Errorf("retry after %v failures: expected roughly %v, got %v", test.failures, test.expected, delay)
+		}
+	}
+}
+
+// TestUploadErasureCodeParams checks that files are uploaded and repaired with
+// the erasure coding parameters of their upload, and that the file list
+// reports the parameters of each file.
+func TestUploadErasureCodeParams(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	tc := &throttledContractor{uploadTime: time.Millisecond}
+	for i := 0; i < 3; i++ {
+		tc.contracts = append(tc.contracts, modules.RenterContract{
+			ID:            types.FileContractID{byte(i + 1)},
+			HostPublicKey: types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{byte(i + 1)}},
+			GoodForUpload: true,
+			GoodForRenew:  true,
+		})
+	}
+	rt, err := newContractorTester(t.Name(), nil, tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	dir := build.TempDir("renter", t.Name(), "source")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(source, fastrand.Bytes(int(2*pieceSize)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Upload a critical file with a 1-of-3 erasure code and a scratch file
+	// with a 2-of-3 erasure code.
+	uploads := []struct {
+		siaPath            string
+		data, parity       int
+		expectedRedundancy float64
+	}{
+		{"critical", 1, 2, 3},
+		{"scratch", 2, 1, 1.5},
+	}
+	for _, up := range uploads {
+		err := rt.renter.Upload(modules.FileUploadParams{
+			Source:       source,
+			SiaPath:      up.siaPath,
+			DataPieces:   up.data,
+			ParityPieces: up.parity,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = build.Retry(100, 50*time.Millisecond, func() error {
+		files := rt.renter.FileList()
+		if len(files) != len(uploads) {
+			return fmt.Errorf("expected %v files, got %v", len(uploads), len(files))
+		}
+		for _, fi := range files {
+			up := uploads[0]
+			if fi.SiaPath == uploads[1].siaPath {
+				up = uploads[1]
+			}
+			if fi.DataPieces != up.data || fi.ParityPieces != up.parity || fi.MaxRedundancy != up.expectedRedundancy {
+				return fmt.Errorf("wrong erasure code of %v: %+v", fi.SiaPath, fi)
+			}
+			if fi.Redundancy != up.expectedRedundancy {
+				return fmt.Errorf("%v has a redundancy of %v, expected %v", fi.SiaPath, fi.Redundancy, up.expectedRedundancy)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = rt.renter.Upload(modules.FileUploadParams{
+		Source:       source,
+		SiaPath:      "invalid",
+		DataPieces:   0,
+		ParityPieces: 3,
+	})
+	if err == nil {
+		t.Fatal("expected an upload without data pieces to fail")
+	}
+
+	// A 1-of-10 erasure code needs at least 5 contracts.
+	ec, err := NewRSCode(1, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkUploadContracts(ec, 4); err == nil {
+		t.Fatal("expected 4 contracts to be too few for a 1-of-10 erasure code")
+	}
+	if err := checkUploadContracts(ec, 5); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newRetryTestChunk returns a worker of the renter that is uploading the first
+// piece of a chunk of a file on disk, along with the chunk and the data of the
+// piece. The memory of the chunk is taken from the renter like the repair loop
+// does.
+func newRetryTestChunk(t *testing.T, r *Renter) (*worker, *unfinishedChunk, []byte) {
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("retry", rsc, 64, 64)
+	path := filepath.Join(r.persistDir, "retry.dat")
+	data := fastrand.Bytes(64)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	uc := newUnfinishedChunk(f, 0, modules.DefaultPortfolio, rsc.NumPieces(), nil)
+	uc.localPath = path
+	r.managedMemoryAvailableSub(uc.memoryNeeded)
+	pieces, err := rsc.Encode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uc.physicalChunkData = make([][]byte, len(pieces))
+	for i := range pieces {
+		uc.physicalChunkData[i] = deriveKey(f.masterKey, 0, uint64(i)).EncryptBytes(pieces[i])
+	}
+	uc.memoryReleased = uc.memoryNeeded - uint64(len(uc.physicalChunkData[0])+len(uc.physicalChunkData[1]))
+	uc.pieceUsage[0] = true
+	uc.piecesRegistered = 1
+	uc.workersRemaining = 1
+	r.heapWG.Add(1)
+
+	w := &worker{
+		contract: modules.RenterContract{GoodForUpload: true},
+		renter:   r,
+	}
+	return w, uc, append([]byte(nil), uc.physicalChunkData[0]...)
+}
+
+// TestUploadRetryReleasesMemory checks that a piece upload that waits for a
+// backoff releases the memory of the piece, and that the piece is read from
+// disk again for the retry.
+func TestUploadRetryReleasesMemory(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+	r := rt.renter
+
+	w, uc, piece := newRetryTestChunk(t, r)
+	before := r.managedMemoryAvailableGet()
+
+	// The second failure waits for a backoff.
+	w.managedUploadFailed(uc, 0, 2)
+	if len(w.pendingRetries) != 1 || !w.pendingRetries[0].released || uc.physicalChunkData[0] != nil {
+		t.Fatal("the piece of the retry was not released:", w.pendingRetries)
+	}
+	if after := r.managedMemoryAvailableGet(); after != before+uint64(len(piece)) {
+		t.Fatalf("expected %v bytes of memory to be returned, got %v", len(piece), after-before)
+	}
+
+	// Without memory, the piece cannot be read again.
+	memory := r.managedMemoryAvailableGet()
+	r.managedMemoryAvailableSub(memory)
+	if err := r.managedLoadPieceData(uc, 0); err != errRetryMemory {
+		t.Fatal("expected errRetryMemory, got", err)
+	}
+	r.managedMemoryAvailableAdd(memory)
+
+	// Once the retry is due, the piece is read again with the memory of the
+	// retry.
+	if err := r.managedLoadPieceData(uc, 0); err != nil {
+		t.Fatal(err)
+	}
+	// The pieces are encrypted with a random nonce, so compare the
+	// plaintexts.
+	key := deriveKey(uc.renterFile.masterKey, 0, 0)
+	reloaded, err := key.DecryptBytes(uc.physicalChunkData[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := key.DecryptBytes(piece)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uc.physicalChunkData[0]) != len(piece) || !bytes.Equal(reloaded, original) {
+		t.Fatal("the piece that was read for the retry does not match the original")
+	}
+	if after := r.managedMemoryAvailableGet(); after != before {
+		t.Fatalf("expected the memory of the piece to be taken again, got a difference of %v", int64(after)-int64(before))
+	}
+
+	// The immediate first retry keeps the data of the piece.
+	w, uc, _ = newRetryTestChunk(t, r)
+	w.managedUploadFailed(uc, 0, 1)
+	if len(w.pendingRetries) != 1 || w.pendingRetries[0].released || uc.physicalChunkData[0] == nil {
+		t.Fatal("the piece of the immediate retry was released")
+	}
+}
+
+// TestUploadRetryCooldown checks that a worker on cooldown does not retry
+// piece uploads.
+func TestUploadRetryCooldown(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// A piece that fails while the worker is on cooldown is released.
+	w, uc, _ := newRetryTestChunk(t, rt.renter)
+	w.uploadRecentFailure = time.Now()
+	w.managedUploadFailed(uc, 0, 2)
+	if len(w.pendingRetries) != 0 || uc.piecesRegistered != 0 || uc.pieceUsage[0] || uc.workersRemaining != 0 {
+		t.Fatal("the piece was retried while the worker was on cooldown:", w.pendingRetries)
+	}
+
+	// A retry that becomes due while the worker is on cooldown is dropped.
+	w, uc, _ = newRetryTestChunk(t, rt.renter)
+	w.managedUploadFailed(uc, 0, 2)
+	w.pendingRetries[0].retryTime = time.Now()
+	w.uploadRecentFailure = time.Now()
+	if chunk, _, _ := w.managedNextChunk(); chunk != nil {
+		t.Fatal("a retry was returned while the worker was on cooldown")
+	}
+	if len(w.pendingRetries) != 0 || uc.piecesRegistered != 0 || uc.workersRemaining != 0 {
+		t.Fatal("the retry was not dropped:", w.pendingRetries)
+	}
+	// The data of the released piece is gone, so it must not be handed to
+	// another worker.
+	if !uc.pieceUsage[0] {
+		t.Fatal("the released piece was made available to other workers")
+	}
+}
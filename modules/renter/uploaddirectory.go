@@ -0,0 +1,195 @@
+package renter
+
+// uploaddirectory.go implements UploadDirectory, which walks a local directory
+// tree and submits every file in the tree to Upload. Files are submitted by a
+// pool of workers, and the outcome of each file is reported individually so
+// that a single bad file does not abort the whole directory.
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/threadgroup"
+)
+
+var (
+	// errUploadNotDirectory is returned if the local path of a directory
+	// upload is not a directory.
+	errUploadNotDirectory = errors.New("local path is not a directory")
+
+	// errSymlinkCycle is reported for a symbolic link that points to a
+	// directory which is already being uploaded.
+	errSymlinkCycle = errors.New("symbolic link creates a directory cycle")
+)
+
+// directoryWalker collects the files of a directory tree that should be
+// uploaded by UploadDirectory.
+type directoryWalker struct {
+	ctx    context.Context
+	params modules.DirectoryUploadParams
+
+	// visited contains the resolved paths of the directories that have been
+	// walked, to prevent infinite loops when following symbolic links.
+	visited map[string]struct{}
+	files   []modules.FileUploadStatus
+}
+
+// walk adds the files in 'dir' to the walker, uploading them into 'siaDir'.
+// Errors that only affect a single entry are recorded in the upload status of
+// that entry, only cancellation of the context stops the walk.
+func (dw *directoryWalker) walk(dir, siaDir string) error {
+	if err := dw.ctx.Err(); err != nil {
+		return err
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		dw.addError(dir, siaDir, err)
+		return nil
+	}
+	if _, exists := dw.visited[realDir]; exists {
+		dw.addError(dir, siaDir, errSymlinkCycle)
+		return nil
+	}
+	dw.visited[realDir] = struct{}{}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		dw.addError(dir, siaDir, err)
+		return nil
+	}
+	for _, info := range infos {
+		source := filepath.Join(dir, info.Name())
+		siaPath := path.Join(siaDir, info.Name())
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			if !dw.params.FollowSymlinks {
+				continue
+			}
+			info, err = os.Stat(source)
+			if err != nil {
+				dw.addError(source, siaPath, err)
+				continue
+			}
+		}
+
+		switch {
+		case info.IsDir():
+			if !dw.params.Recursive {
+				continue
+			}
+			if err := dw.walk(source, siaPath); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			dw.files = append(dw.files, modules.FileUploadStatus{
+				Source:  source,
+				SiaPath: siaPath,
+			})
+		}
+	}
+	return nil
+}
+
+// addError records an entry of the directory tree that could not be read.
+func (dw *directoryWalker) addError(source, siaPath string, err error) {
+	dw.files = append(dw.files, modules.FileUploadStatus{
+		Source:  source,
+		SiaPath: siaPath,
+		Error:   err.Error(),
+	})
+}
+
+// UploadDirectory uploads every file in the directory tree at
+// params.LocalPath. The returned result contains the upload status of each
+// file that was found. If the context is cancelled, files that have not been
+// submitted yet are marked as failed and the context's error is returned.
+func (r *Renter) UploadDirectory(ctx context.Context, params modules.DirectoryUploadParams) (modules.DirectoryUploadResult, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.DirectoryUploadResult{}, err
+	}
+	defer r.tg.Done()
+
+	// Enforce the nickname and source rules for the directory.
+	if err := validateSiapath(params.SiaPath); err != nil {
+		return modules.DirectoryUploadResult{}, err
+	}
+	info, err := os.Stat(params.LocalPath)
+	if err != nil {
+		return modules.DirectoryUploadResult{}, err
+	}
+	if !info.IsDir() {
+		return modules.DirectoryUploadResult{}, errUploadNotDirectory
+	}
+
+	// Collect the files that need to be uploaded.
+	dw := &directoryWalker{
+		ctx:     ctx,
+		params:  params,
+		visited: make(map[string]struct{}),
+	}
+	if err := dw.walk(params.LocalPath, params.SiaPath); err != nil {
+		return modules.DirectoryUploadResult{}, err
+	}
+	files := dw.files
+
+	// Submit the files using a pool of workers. Each worker writes only to
+	// the status of the files that it was handed, so no lock is needed.
+	workers := params.Workers
+	if workers <= 0 {
+		workers = defaultUploadDirectoryWorkers
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				err := r.Upload(modules.FileUploadParams{
+					Source:      files[i].Source,
+					SiaPath:     files[i].SiaPath,
+					ErasureCode: params.ErasureCode,
+				})
+				if err != nil {
+					files[i].Error = err.Error()
+				}
+			}
+		}()
+	}
+	var cancelled error
+	for i := range files {
+		if files[i].Error != "" {
+			// The file could not be read during the walk.
+			continue
+		}
+		if cancelled == nil {
+			select {
+			case jobs <- i:
+				continue
+			case <-ctx.Done():
+				cancelled = ctx.Err()
+			case <-r.tg.StopChan():
+				cancelled = threadgroup.ErrStopped
+			}
+		}
+		files[i].Error = cancelled.Error()
+	}
+	close(jobs)
+	wg.Wait()
+
+	result := modules.DirectoryUploadResult{Files: files}
+	for _, f := range files {
+		if f.Error == "" {
+			result.Succeeded++
+		} else {
+			result.Failed++
+		}
+	}
+	return result, cancelled
+}
@@ -0,0 +1,125 @@
+package renter
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestRenterUploadDirectory checks that UploadDirectory uploads the files of a
+// directory tree to the matching siapaths, honoring the recursive and
+// symbolic link options.
+func TestRenterUploadDirectory(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Create a directory tree containing a file, a subdirectory, a symbolic
+	// link to a file, and a symbolic link to the root that would create a
+	// cycle if followed naively.
+	root, err := ioutil.TempDir("", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err := os.MkdirAll(filepath.Join(root, "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", filepath.Join("sub", "b")} {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte("data"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "a"), filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(root, filepath.Join(root, "sub", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	// uploadedPaths returns the set of siapaths that were uploaded
+	// successfully.
+	uploadedPaths := func(result modules.DirectoryUploadResult) map[string]bool {
+		paths := make(map[string]bool)
+		for _, f := range result.Files {
+			if f.Error == "" {
+				paths[f.SiaPath] = true
+			}
+		}
+		return paths
+	}
+
+	// A non-recursive upload should only upload the top level file.
+	result, err := rt.renter.UploadDirectory(context.Background(), modules.DirectoryUploadParams{
+		LocalPath: root,
+		SiaPath:   "flat",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paths := uploadedPaths(result); len(paths) != 1 || !paths["flat/a"] || result.Succeeded != 1 {
+		t.Fatal("wrong files uploaded:", result)
+	}
+
+	// A recursive upload that follows symbolic links should upload the linked
+	// file, and report the cycle instead of following it.
+	result, err = rt.renter.UploadDirectory(context.Background(), modules.DirectoryUploadParams{
+		LocalPath:      root,
+		SiaPath:        "tree",
+		Recursive:      true,
+		FollowSymlinks: true,
+		Workers:        2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := uploadedPaths(result)
+	if len(paths) != 3 || !paths["tree/a"] || !paths["tree/link"] || !paths["tree/sub/b"] {
+		t.Fatal("wrong files uploaded:", result)
+	}
+	if result.Failed != 1 || result.Files[len(result.Files)-1].Error != errSymlinkCycle.Error() {
+		t.Fatal("symbolic link cycle was not reported:", result)
+	}
+	if len(rt.renter.FileList()) != 4 {
+		t.Fatal("expected the renter to track 4 files, got", len(rt.renter.FileList()))
+	}
+
+	// Uploading the same tree again should report every file as failed,
+	// because the siapaths are already in use.
+	result, err = rt.renter.UploadDirectory(context.Background(), modules.DirectoryUploadParams{
+		LocalPath: root,
+		SiaPath:   "tree",
+		Recursive: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Succeeded != 0 || result.Failed != 2 {
+		t.Fatal("expected all files to fail:", result)
+	}
+
+	// A cancelled upload should not upload any files.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = rt.renter.UploadDirectory(ctx, modules.DirectoryUploadParams{
+		LocalPath: root,
+		SiaPath:   "cancelled",
+		Recursive: true,
+	})
+	if err != context.Canceled {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+	if len(rt.renter.FileList()) != 4 {
+		t.Fatal("cancelled upload added files to the renter")
+	}
+}
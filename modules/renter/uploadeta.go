@@ -0,0 +1,177 @@
+package renter
+
+// uploadeta.go estimates how long it will take for files to reach full
+// redundancy. The workers record every piece that they upload, and the upload
+// throughput of the renter is measured over the pieces that were uploaded
+// within the last throughputWindow. The estimate for a file is the size of the
+// pieces that the file is missing divided by the throughput. The estimate
+// assumes that the throughput stays the same and that the file gets all of it,
+// so a file that is repaired alongside other files will take longer than
+// estimated. The estimate of the whole repair backlog does not have that
+// problem.
+
+import (
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+type (
+	// throughputSample is a piece upload of the provided size that completed
+	// at the provided time.
+	throughputSample struct {
+		size uint64
+		time time.Time
+	}
+
+	// A throughputMeter measures the upload throughput of the renter over a
+	// sliding window of recent piece uploads.
+	throughputMeter struct {
+		samples []throughputSample
+		mu      sync.Mutex
+	}
+)
+
+// prune removes the samples that are older than the throughputWindow. The
+// caller must hold the lock.
+func (tm *throughputMeter) prune(now time.Time) {
+	i := 0
+	for i < len(tm.samples) && now.Sub(tm.samples[i].time) > throughputWindow {
+		i++
+	}
+	tm.samples = tm.samples[i:]
+}
+
+// managedRecordUpload records the completed upload of a piece of the provided
+// size.
+func (tm *throughputMeter) managedRecordUpload(size uint64) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	now := time.Now()
+	tm.prune(now)
+	tm.samples = append(tm.samples, throughputSample{size: size, time: now})
+}
+
+// managedThroughput returns the upload throughput in bytes per second, or 0 if
+// fewer than minThroughputSamples pieces were uploaded within the
+// throughputWindow. The throughput is measured from the completion of the
+// first upload in the window, so the size of that upload is not counted.
+func (tm *throughputMeter) managedThroughput() float64 {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.prune(time.Now())
+	if len(tm.samples) < minThroughputSamples {
+		return 0
+	}
+	elapsed := tm.samples[len(tm.samples)-1].time.Sub(tm.samples[0].time)
+	if elapsed <= 0 {
+		return 0
+	}
+	var size uint64
+	for _, s := range tm.samples[1:] {
+		size += s.size
+	}
+	return float64(size) / elapsed.Seconds()
+}
+
+// estimateRepairTime returns how long it takes to upload remaining bytes at
+// the provided throughput. The time is unknown if the bytes cannot be
+// uploaded, or if the throughput is unknown.
+func estimateRepairTime(remaining uint64, repairable bool, throughput float64) (time.Duration, bool) {
+	if remaining == 0 {
+		return 0, true
+	}
+	if !repairable || throughput <= 0 {
+		return 0, false
+	}
+	return time.Duration(float64(remaining) / throughput * float64(time.Second)), true
+}
+
+// goodForUploadContracts returns the number of contracts of hc that are good
+// for upload.
+func goodForUploadContracts(hc hostContractor) int {
+	var n int
+	for _, c := range hc.Contracts() {
+		if c.GoodForUpload {
+			n++
+		}
+	}
+	return n
+}
+
+// missingPieceBytes returns the size of the pieces that are missing for the
+// file to reach full redundancy on the contracts of hc that are good for
+// upload. The caller must hold the lock of f.
+func (f *file) missingPieceBytes(hc hostContractor) uint64 {
+	chunkPieces := make([]map[uint64]struct{}, f.numChunks())
+	for fcid, fc := range f.contracts {
+		contract, exists := hc.ResolveContract(fcid)
+		if !exists || !contract.GoodForUpload {
+			continue
+		}
+		for _, p := range fc.Pieces {
+			if chunkPieces[p.Chunk] == nil {
+				chunkPieces[p.Chunk] = make(map[uint64]struct{})
+			}
+			chunkPieces[p.Chunk][p.Piece] = struct{}{}
+		}
+	}
+	var missing uint64
+	for _, pieces := range chunkPieces {
+		if n := f.erasureCode.NumPieces() - len(pieces); n > 0 {
+			missing += uint64(n)
+		}
+	}
+	// The uploaded pieces are encrypted.
+	return missing * (f.pieceSize + crypto.TwofishOverhead)
+}
+
+// repairEstimate returns the estimated time until the file reaches full
+// redundancy at the provided throughput. The file can only be repaired if it
+// is tracked, and if there is a contract that is good for upload for every
+// piece of a chunk. The caller must hold the lock of f.
+func (f *file) repairEstimate(hc hostContractor, tracked bool, uploadContracts int, throughput float64) (time.Duration, bool) {
+	repairable := tracked && uploadContracts >= f.erasureCode.NumPieces()
+	return estimateRepairTime(f.missingPieceBytes(hc), repairable, throughput)
+}
+
+// RepairEstimate returns an estimate of how long the renter needs to upload
+// the pieces that are missing from the files that it can repair.
+func (r *Renter) RepairEstimate() modules.RepairEstimate {
+	var files []*file
+	var portfolios []string
+	lockID := r.mu.RLock()
+	for _, f := range r.files {
+		if _, tracked := r.tracking[f.name]; tracked {
+			files = append(files, f)
+			portfolios = append(portfolios, r.portfolioForSiaPath(f.name))
+		}
+	}
+	contractors := r.portfolioContractors()
+	r.mu.RUnlock(lockID)
+
+	uploadContracts := make(map[string]int)
+	var remaining uint64
+	for i, f := range files {
+		hc := contractors[portfolios[i]]
+		n, exists := uploadContracts[portfolios[i]]
+		if !exists {
+			n = goodForUploadContracts(hc)
+			uploadContracts[portfolios[i]] = n
+		}
+		f.mu.RLock()
+		if n >= f.erasureCode.NumPieces() {
+			remaining += f.missingPieceBytes(hc)
+		}
+		f.mu.RUnlock()
+	}
+
+	est := modules.RepairEstimate{
+		UploadThroughput: r.uploadThroughput.managedThroughput(),
+		RemainingBytes:   remaining,
+	}
+	est.EstimatedTime, est.EstimatedTimeKnown = estimateRepairTime(remaining, true, est.UploadThroughput)
+	return est
+}
@@ -0,0 +1,209 @@
+package renter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/renter/contractor"
+	"github.com/NebulousLabs/Sia/modules/renter/proto"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// throttledContractor is a hostContractor whose editors upload to simulated
+// hosts that take uploadTime to store every piece. Methods that are not used
+// by uploads are not implemented.
+type throttledContractor struct {
+	hostContractor
+	contracts  []modules.RenterContract
+	uploadTime time.Duration
+}
+
+func (tc *throttledContractor) Allowance() modules.Allowance                  { return modules.Allowance{} }
+func (tc *throttledContractor) Close() error                                  { return nil }
+func (tc *throttledContractor) Contracts() []modules.RenterContract           { return tc.contracts }
+func (tc *throttledContractor) IsOffline(types.FileContractID) bool           { return false }
+func (tc *throttledContractor) SetDownloadRateLimit(*proto.RateLimit)         {}
+func (tc *throttledContractor) SetUploadRateLimit(*proto.RateLimit)           {}
+func (tc *throttledContractor) SetBandwidthLimiter(*modules.BandwidthLimiter) {}
+func (tc *throttledContractor) SetGarbageContracts([]types.FileContractID)    {}
+func (tc *throttledContractor) ResolveID(id types.FileContractID) types.FileContractID {
+	return id
+}
+func (tc *throttledContractor) ContractByID(id types.FileContractID) (modules.RenterContract, bool) {
+	for _, c := range tc.contracts {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return modules.RenterContract{}, false
+}
+func (tc *throttledContractor) ResolveContract(id types.FileContractID) (modules.RenterContract, bool) {
+	return tc.ContractByID(id)
+}
+func (tc *throttledContractor) Editor(id types.FileContractID, _ <-chan struct{}) (contractor.Editor, error) {
+	return &throttledEditor{id: id, uploadTime: tc.uploadTime}, nil
+}
+
+// throttledEditor is the contractor.Editor of a throttledContractor.
+type throttledEditor struct {
+	id         types.FileContractID
+	uploadTime time.Duration
+}
+
+func (te *throttledEditor) Upload(data []byte) (crypto.Hash, error) {
+	time.Sleep(te.uploadTime)
+	return crypto.MerkleRoot(data), nil
+}
+func (te *throttledEditor) Delete(crypto.Hash) error                              { return nil }
+func (te *throttledEditor) Modify(crypto.Hash, crypto.Hash, uint64, []byte) error { return nil }
+func (te *throttledEditor) Address() modules.NetAddress                           { return "foo:1234" }
+func (te *throttledEditor) ContractID() types.FileContractID                      { return te.id }
+func (te *throttledEditor) EndHeight() types.BlockHeight                          { return 100 }
+func (te *throttledEditor) Close() error                                          { return nil }
+
+// TestThroughputMeter checks that the throughput is only known once enough
+// pieces have been uploaded within the throughput window.
+func TestThroughputMeter(t *testing.T) {
+	var tm throughputMeter
+	for i := 0; i < minThroughputSamples-1; i++ {
+		tm.managedRecordUpload(1000)
+	}
+	if tp := tm.managedThroughput(); tp != 0 {
+		t.Fatal("throughput should be unknown with too few samples, got", tp)
+	}
+
+	// Spread the samples out over 4 seconds, one every second.
+	now := time.Now()
+	tm.samples = nil
+	for i := 0; i < minThroughputSamples; i++ {
+		tm.samples = append(tm.samples, throughputSample{size: 1000, time: now.Add(time.Duration(i-minThroughputSamples+1) * time.Second)})
+	}
+	if tp := tm.managedThroughput(); tp < 999 || tp > 1001 {
+		t.Fatal("expected a throughput of 1000 bytes per second, got", tp)
+	}
+
+	// Samples that are older than the window should be forgotten.
+	tm.samples[0].time = now.Add(-throughputWindow - time.Second)
+	if tp := tm.managedThroughput(); tp != 0 {
+		t.Fatal("throughput should be unknown after old samples are pruned, got", tp)
+	}
+
+	for _, test := range []struct {
+		remaining  uint64
+		repairable bool
+		throughput float64
+		est        time.Duration
+		known      bool
+	}{
+		{0, false, 0, 0, true},
+		{1000, true, 0, 0, false},
+		{1000, false, 500, 0, false},
+		{1000, true, 500, 2 * time.Second, true},
+	} {
+		est, known := estimateRepairTime(test.remaining, test.repairable, test.throughput)
+		if est != test.est || known != test.known {
+			t.Errorf("estimateRepairTime(%v, %v, %v) = %v, %v, expected %v, %v", test.remaining, test.repairable, test.throughput, est, known, test.est, test.known)
+		}
+	}
+}
+
+// TestEstimatedRepairTime uploads a file to throttled hosts, and checks that
+// the estimated time to full redundancy is close to the time that the upload
+// actually takes.
+func TestEstimatedRepairTime(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Three hosts that each take 30ms to store a piece.
+	tc := &throttledContractor{uploadTime: 30 * time.Millisecond}
+	for i := 0; i < 3; i++ {
+		tc.contracts = append(tc.contracts, modules.RenterContract{
+			ID:            types.FileContractID{byte(i + 1)},
+			HostPublicKey: types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{byte(i + 1)}},
+			GoodForUpload: true,
+			GoodForRenew:  true,
+		})
+	}
+	rt, err := newContractorTester(t.Name(), nil, tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+	if est := rt.renter.RepairEstimate(); est.RemainingBytes != 0 || !est.EstimatedTimeKnown {
+		t.Fatalf("expected an empty repair backlog, got %+v", est)
+	}
+
+	// Upload a file of 40 chunks, which needs 120 pieces to reach full
+	// redundancy.
+	ec, err := NewRSCode(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := build.TempDir("renter", t.Name(), "source")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(source, fastrand.Bytes(int(40*pieceSize)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	err = rt.renter.Upload(modules.FileUploadParams{
+		Source:      source,
+		SiaPath:     "foo",
+		ErasureCode: ec,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for the first estimate.
+	var est time.Duration
+	var estTime time.Time
+	err = build.Retry(100, 20*time.Millisecond, func() error {
+		fi := rt.renter.FileList()[0]
+		if !fi.EstimatedRepairTimeKnown || fi.EstimatedRepairTime == 0 {
+			return fmt.Errorf("no estimate yet: %+v", fi)
+		}
+		est, estTime = fi.EstimatedRepairTime, time.Now()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backlog := rt.renter.RepairEstimate(); !backlog.EstimatedTimeKnown || backlog.RemainingBytes == 0 {
+		t.Fatalf("expected an estimate of the repair backlog, got %+v", backlog)
+	}
+
+	// Wait for the file to reach full redundancy.
+	err = build.Retry(200, 50*time.Millisecond, func() error {
+		if fi := rt.renter.FileList()[0]; !fi.EstimatedRepairTimeKnown || fi.EstimatedRepairTime != 0 {
+			return fmt.Errorf("upload is at %v%%", fi.UploadProgress)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := time.Since(estTime)
+	t.Logf("estimated %v after %v, upload took another %v", est, estTime.Sub(start), actual)
+	if actual < est/3 || actual > est*3 {
+		t.Fatalf("estimated %v, but the upload took %v", est, actual)
+	}
+	if fi := rt.renter.FileList()[0]; fi.UploadProgress < 100 {
+		t.Fatalf("expected the upload to be complete, got %+v", fi)
+	}
+	if backlog := rt.renter.RepairEstimate(); backlog.RemainingBytes != 0 {
+		t.Fatalf("expected an empty repair backlog, got %+v", backlog)
+	}
+}
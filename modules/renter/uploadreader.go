@@ -0,0 +1,256 @@
+package renter
+
+// uploadreader.go implements UploadReader, which uploads the data of an
+// io.Reader without storing it on disk. The stream is read one chunk at a
+// time, and every chunk is handed to the repair loop together with its logical
+// data, so that the repair loop encodes and uploads it like any other chunk.
+// Only a few chunks of the stream are held in memory at once.
+//
+// The file is not tracked while it is being streamed, so that the repair loop
+// does not try to repair the chunks that have not been read yet. Once every
+// chunk has been uploaded, the file is tracked without a local path. As there
+// is no local copy of the data, later repairs download the data of a chunk
+// from the hosts, which requires the chunk to still be recoverable.
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/threadgroup"
+)
+
+var (
+	// streamUploadChunks is the number of chunks of a streamed upload that
+	// are read ahead of the chunks that have finished uploading.
+	streamUploadChunks = build.Select(build.Var{
+		Dev:      4,
+		Standard: 4,
+		Testing:  2,
+	}).(int)
+
+	// errStreamIncomplete is returned if some chunks of a streamed upload
+	// could not be uploaded to enough hosts to recover them.
+	errStreamIncomplete = errors.New("not all chunks of the file could be uploaded")
+)
+
+// UploadReader uploads the data of rd to siaPath using the erasure code ec,
+// or the default erasure code if ec is nil. The data is uploaded while it is
+// read, without storing it on disk, and UploadReader returns once rd has
+// returned io.EOF and all of the data has been uploaded. If the upload fails,
+// the file is deleted. As the file would be deleted together with its
+// previous versions, siaPath must not be in use.
+func (r *Renter) UploadReader(siaPath string, rd io.Reader, ec modules.ErasureCoder) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	if err := validateSiapath(siaPath); err != nil {
+		return err
+	}
+	id := r.mu.RLock()
+	_, exists := r.files[siaPath]
+	pathErr := r.checkFilePath(siaPath)
+	r.mu.RUnlock(id)
+	if exists {
+		return ErrPathOverload
+	}
+	if pathErr != nil {
+		return pathErr
+	}
+
+	ctx, cancel := r.stopContext(context.Background())
+	defer cancel()
+	params := modules.FileUploadParams{
+		SiaPath:     siaPath,
+		ErasureCode: ec,
+	}
+	return r.managedUploadStream(ctx, params, 0, rd)
+}
+
+// stopContext returns a context derived from ctx that is also cancelled when
+// the renter is stopped.
+func (r *Renter) stopContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-r.tg.StopChan():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// managedUploadStream creates the file of up with the provided size, uploads
+// the data of body to it and tracks the file once all of the data has been
+// uploaded. A size of 0 means that the size of the data is unknown, in which
+// case the file grows as body is read. If the upload fails, the file is
+// deleted.
+func (r *Renter) managedUploadStream(ctx context.Context, up modules.FileUploadParams, size uint64, body io.Reader) error {
+	f, err := r.managedAddUploadFile(up, size, 0644, nil)
+	if err != nil {
+		return err
+	}
+	return r.managedFinishUploadStream(ctx, f, body)
+}
+
+// managedFinishUploadStream uploads the data of body to the file f that was
+// created for a streamed upload, and tracks the file once all of the data has
+// been uploaded. If the upload fails, the file is deleted.
+func (r *Renter) managedFinishUploadStream(ctx context.Context, f *file, body io.Reader) error {
+	err := r.managedStreamChunks(ctx, f, body)
+	if err == nil {
+		err = r.managedTrackStreamedFile(f)
+	}
+	if err != nil {
+		r.log.Println("Streamed upload to", f.name, "failed:", err)
+		r.managedDeleteStreamedFile(f)
+		return err
+	}
+	r.log.Println("Streamed upload to", f.name, "completed")
+	return nil
+}
+
+// managedStreamChunks reads the chunks of f from body and hands them to the
+// repair loop. It returns once all of the chunks have left the repair loop.
+func (r *Renter) managedStreamChunks(ctx context.Context, f *file, body io.Reader) error {
+	id := r.mu.RLock()
+	portfolio := r.portfolioForSiaPath(f.name)
+	hc, _ := r.portfolioContractor(portfolio)
+	checksumAlgorithm := r.checksumAlgorithm()
+	r.mu.RUnlock(id)
+	cb, err := newChecksumBuilder(checksumAlgorithm, f.chunkSize())
+	if err != nil {
+		return err
+	}
+	hosts := make(map[string]struct{})
+	for _, c := range hc.Contracts() {
+		hosts[c.HostPublicKey.String()] = struct{}{}
+	}
+
+	// Every chunk in the repair loop holds a token, which the chunk returns
+	// when it leaves the repair loop. The chunks have to leave the repair loop
+	// before the file can be deleted, as the workers save the file.
+	tokens := make(chan struct{}, streamUploadChunks)
+	for i := 0; i < streamUploadChunks; i++ {
+		tokens <- struct{}{}
+	}
+	waitForChunks := func() error {
+		for i := 0; i < streamUploadChunks; i++ {
+			select {
+			case <-tokens:
+			case <-r.tg.StopChan():
+				return threadgroup.ErrStopped
+			}
+		}
+		return nil
+	}
+
+	var read uint64
+	for index := uint64(0); ; index++ {
+		select {
+		case <-tokens:
+		case <-ctx.Done():
+			waitForChunks()
+			return ctx.Err()
+		}
+		f.mu.Lock()
+		uc := newUnfinishedChunk(f, index, portfolio, f.erasureCode.NumPieces(), hosts)
+		f.mu.Unlock()
+		uc.repairDone = tokens
+		uc.logicalChunkData = make([]byte, uc.length)
+		n, err := io.ReadFull(body, uc.logicalChunkData)
+		read += uint64(n)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			tokens <- struct{}{}
+			waitForChunks()
+			return err
+		}
+		if n == 0 && index > 0 {
+			// The previous chunk ended at the end of the body.
+			tokens <- struct{}{}
+			break
+		}
+		cb.addChunk(uc.logicalChunkData[:n])
+
+		// The file grows with every chunk if the body does not have a
+		// Content-Length.
+		f.mu.Lock()
+		if read > f.size {
+			f.size = read
+		}
+		f.chunksInRepair++
+		f.mu.Unlock()
+		select {
+		case r.newChunks <- uc:
+		case <-ctx.Done():
+			uc.managedChunkRepairDone()
+			waitForChunks()
+			return ctx.Err()
+		}
+		if last {
+			break
+		}
+	}
+	if err := waitForChunks(); err != nil {
+		return err
+	}
+
+	// The checksum is set under the file lock, as downloads and the file
+	// list read it concurrently.
+	checksum := cb.sum()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if read != f.size {
+		return io.ErrUnexpectedEOF
+	}
+	if !f.available(func(types.FileContractID) bool { return false }) {
+		return errStreamIncomplete
+	}
+	f.checksum = checksum
+	return nil
+}
+
+// managedDeleteStreamedFile deletes the file of a failed streamed upload. The
+// file may have been renamed while it was uploaded, so it is deleted by its
+// current name, and only if that name still refers to f.
+func (r *Renter) managedDeleteStreamedFile(f *file) {
+	id := r.mu.Lock()
+	if r.files[f.name] == f {
+		r.deleteFile(f.name, f)
+		r.saveSync()
+	}
+	r.mu.Unlock(id)
+
+	// Wait for the operations that still hold the file.
+	f.mu.Lock()
+	f.mu.Unlock()
+}
+
+// managedTrackStreamedFile tracks a file that was streamed to the hosts, so
+// that the repair loop maintains its redundancy. The file is tracked without a
+// local path.
+func (r *Renter) managedTrackStreamedFile(f *file) error {
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	if r.files[f.name] != f {
+		return ErrUnknownPath
+	}
+	r.tracking[f.name] = trackedFile{
+		UploadTime: time.Now(),
+	}
+	if err := r.saveSync(); err != nil {
+		return err
+	}
+	// Save the checksum of the file, which is only known once the whole
+	// stream has been read.
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return r.saveFile(f)
+}
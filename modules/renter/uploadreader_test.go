@@ -0,0 +1,118 @@
+package renter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestUploadReader checks that UploadReader uploads the data of a reader as
+// it arrives, that the file is listed without a local path, and that the file
+// is deleted if the reader fails.
+func TestUploadReader(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	tc := &throttledContractor{uploadTime: time.Millisecond}
+	for i := 0; i < 3; i++ {
+		tc.contracts = append(tc.contracts, modules.RenterContract{
+			ID:            types.FileContractID{byte(i + 1)},
+			HostPublicKey: types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{byte(i + 1)}},
+			GoodForUpload: true,
+			GoodForRenew:  true,
+		})
+	}
+	rt, err := newContractorTester(t.Name(), nil, tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Write the data in small pieces, so that it arrives while the first
+	// chunks are uploaded.
+	data := fastrand.Bytes(int(3*pieceSize + 100))
+	pr, pw := io.Pipe()
+	go func() {
+		for i := 0; i < len(data); i += 1000 {
+			end := i + 1000
+			if end > len(data) {
+				end = len(data)
+			}
+			pw.Write(data[i:end])
+		}
+		pw.Close()
+	}()
+	ec, err := NewRSCode(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.UploadReader("stream", pr, ec); err != nil {
+		t.Fatal(err)
+	}
+	files := rt.renter.FileList()
+	if len(files) != 1 || files[0].SiaPath != "stream" {
+		t.Fatal("expected the streamed file to be listed, got", files)
+	}
+	if fi := files[0]; fi.Filesize != uint64(len(data)) || fi.LocalPath != "" || !fi.Available {
+		t.Fatalf("wrong file info for a streamed file: %+v", fi)
+	}
+	if cs, err := rt.renter.FileChecksum("stream"); err != nil || cs.Digest != crypto.HashBytes(data) {
+		t.Fatal("wrong checksum of a streamed file:", cs, err)
+	}
+	if err := rt.renter.UploadReader("stream", bytes.NewReader(data), ec); err != ErrPathOverload {
+		t.Fatal("expected ErrPathOverload, got", err)
+	}
+
+	// A reader that fails deletes the file.
+	errRead := errors.New("read failed")
+	pr, pw = io.Pipe()
+	go func() {
+		pw.Write(data[:pieceSize+1])
+		pw.CloseWithError(errRead)
+	}()
+	if err := rt.renter.UploadReader("failed", pr, ec); err != errRead {
+		t.Fatal("expected the error of the reader, got", err)
+	}
+	id := rt.renter.mu.RLock()
+	_, exists := rt.renter.files["failed"]
+	rt.renter.mu.RUnlock(id)
+	if exists {
+		t.Fatal("file of a failed upload was not deleted")
+	}
+
+	// A file that is renamed while it is streamed is deleted under its new
+	// name if the reader fails, and does not affect a file that is uploaded
+	// to the old name in the meantime.
+	pr, pw = io.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- rt.renter.UploadReader("renamed", pr, ec)
+	}()
+	pw.Write(data[:pieceSize+1])
+	if err := rt.renter.RenameFile("renamed", "renamed2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.UploadReader("renamed", bytes.NewReader(data[:100]), ec); err != nil {
+		t.Fatal(err)
+	}
+	pw.CloseWithError(errRead)
+	if err := <-done; err != errRead {
+		t.Fatal("expected the error of the reader, got", err)
+	}
+	id = rt.renter.mu.RLock()
+	_, renamedExists := rt.renter.files["renamed2"]
+	_, oldExists := rt.renter.files["renamed"]
+	rt.renter.mu.RUnlock(id)
+	if renamedExists || !oldExists {
+		t.Fatal("wrong file deleted after a renamed upload failed:", renamedExists, oldExists)
+	}
+}
@@ -0,0 +1,282 @@
+package renter
+
+// uploadurl.go implements UploadFromURL, which uploads the body of an HTTP(S)
+// response without storing it on disk. The body is streamed to the hosts like
+// the stream of UploadReader, see uploadreader.go, in the background. Requests
+// that fail with a transient error or stall are retried, and interrupted
+// bodies are resumed with range requests.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+const (
+	// maxURLUploadRetries is the number of times that UploadFromURL retries a
+	// request that failed with a transient error.
+	maxURLUploadRetries = 3
+)
+
+var (
+	// urlUploadRetryInterval is the time that UploadFromURL waits before the
+	// first retry of a failed request. The wait grows with every retry.
+	urlUploadRetryInterval = build.Select(build.Var{
+		Dev:      time.Second,
+		Standard: 5 * time.Second,
+		Testing:  10 * time.Millisecond,
+	}).(time.Duration)
+
+	// urlUploadDialTimeout is the time that UploadFromURL waits for a
+	// connection to the server, including the TLS handshake.
+	urlUploadDialTimeout = build.Select(build.Var{
+		Dev:      30 * time.Second,
+		Standard: 30 * time.Second,
+		Testing:  5 * time.Second,
+	}).(time.Duration)
+
+	// urlUploadResponseTimeout is the time that UploadFromURL waits for the
+	// headers of a response once the request has been sent.
+	urlUploadResponseTimeout = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: time.Minute,
+		Testing:  5 * time.Second,
+	}).(time.Duration)
+
+	// urlUploadReadTimeout is the time that a read of the body may stall
+	// before the request is retried. The body as a whole has no deadline, as
+	// uploading a large file takes a long time.
+	urlUploadReadTimeout = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: 2 * time.Minute,
+		Testing:  5 * time.Second,
+	}).(time.Duration)
+
+	// urlUploadClient is the client that UploadFromURL requests the URLs
+	// with.
+	urlUploadClient = &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   urlUploadDialTimeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   urlUploadDialTimeout,
+			ResponseHeaderTimeout: urlUploadResponseTimeout,
+			IdleConnTimeout:       90 * time.Second,
+		},
+	}
+)
+
+var (
+	// errURLScheme is returned if the URL of an upload is not an HTTP or
+	// HTTPS URL.
+	errURLScheme = errors.New("only http and https URLs can be uploaded")
+
+	// errURLRangeNotSupported is returned if a request that was interrupted
+	// cannot be resumed because the server does not support range requests.
+	errURLRangeNotSupported = errors.New("server does not support resuming the download")
+)
+
+// urlReader reads the body of a URL. If reading the body fails with a
+// transient error, the request is resumed at the current offset with a range
+// request.
+type urlReader struct {
+	ctx    context.Context
+	client *http.Client
+	url    string
+
+	body    io.ReadCloser
+	cancel  context.CancelFunc // cancels the request of body
+	offset  uint64
+	length  int64 // Content-Length of the body, -1 if unknown
+	retries int
+}
+
+// retry waits before a request that failed with err is retried, and returns
+// an error if the request should not be retried.
+func (ur *urlReader) retry(err error) error {
+	if ur.ctx.Err() != nil {
+		return ur.ctx.Err()
+	}
+	ur.retries++
+	if ur.retries > maxURLUploadRetries {
+		return build.ExtendErr(fmt.Sprintf("request failed %v times:", ur.retries), err)
+	}
+	select {
+	case <-time.After(time.Duration(ur.retries) * urlUploadRetryInterval):
+		return nil
+	case <-ur.ctx.Done():
+		return ur.ctx.Err()
+	}
+}
+
+// open requests the body of the URL starting at the current offset.
+func (ur *urlReader) open() error {
+	for {
+		req, err := http.NewRequest("GET", ur.url, nil)
+		if err != nil {
+			return err
+		}
+		if ur.offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", ur.offset))
+		}
+		// Each request has its own context, so that a request whose body
+		// stalls can be cancelled without cancelling the upload.
+		ctx, cancel := context.WithCancel(ur.ctx)
+		resp, err := ur.client.Do(req.WithContext(ctx))
+		if err == nil && (resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests) {
+			resp.Body.Close()
+			err = errors.New(resp.Status)
+		} else if err == nil && ur.offset > 0 && resp.StatusCode != http.StatusPartialContent {
+			resp.Body.Close()
+			cancel()
+			return errURLRangeNotSupported
+		} else if err == nil && ur.offset == 0 && resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			cancel()
+			return errors.New("unexpected response: " + resp.Status)
+		} else if err == nil {
+			if ur.offset == 0 {
+				ur.length = resp.ContentLength
+			}
+			ur.body = resp.Body
+			ur.cancel = cancel
+			return nil
+		}
+		cancel()
+		if err := ur.retry(err); err != nil {
+			return err
+		}
+	}
+}
+
+// Read implements the io.Reader interface.
+func (ur *urlReader) Read(p []byte) (int, error) {
+	for {
+		if ur.body == nil {
+			if err := ur.open(); err != nil {
+				return 0, err
+			}
+		}
+		// Cancel the request if the read stalls. The timer only runs while
+		// the body is read, as the upload may wait for the hosts between
+		// reads.
+		stall := time.AfterFunc(urlUploadReadTimeout, ur.cancel)
+		n, err := ur.body.Read(p)
+		if !stall.Stop() && ur.ctx.Err() == nil {
+			err = errors.New("read of the body timed out")
+		}
+		ur.offset += uint64(n)
+		if err == nil || (err == io.EOF && (ur.length < 0 || ur.offset >= uint64(ur.length))) {
+			return n, err
+		} else if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		ur.Close()
+		if err := ur.retry(err); err != nil {
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// Close closes the body of the current request.
+func (ur *urlReader) Close() error {
+	if ur.body == nil {
+		return nil
+	}
+	err := ur.body.Close()
+	ur.cancel()
+	ur.body, ur.cancel = nil, nil
+	return err
+}
+
+// UploadFromURL uploads the body of the HTTP or HTTPS URL sourceURL to
+// siaPath, using the erasure code of params. The body is uploaded while it is
+// downloaded, without storing it on disk. If the response has a Content-Length
+// the file has its final size from the start, so that its upload progress is
+// reported correctly, otherwise the file grows as the body is read.
+// UploadFromURL returns once the body has been requested and the file has
+// been created, and uploads the body in the background. If the upload fails or
+// ctx is cancelled, the file is deleted. As the file would be deleted together
+// with its previous versions, siaPath must not be in use.
+func (r *Renter) UploadFromURL(ctx context.Context, sourceURL, siaPath string, params modules.FileUploadParams) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	params.Source = sourceURL
+	params.SiaPath = siaPath
+	if err := validateSiapath(siaPath); err != nil {
+		return err
+	}
+	u, err := url.Parse(sourceURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errURLScheme
+	}
+	id := r.mu.RLock()
+	_, exists := r.files[siaPath]
+	pathErr := r.checkFilePath(siaPath)
+	r.mu.RUnlock(id)
+	if exists {
+		return ErrPathOverload
+	}
+	if pathErr != nil {
+		return pathErr
+	}
+
+	// Cancel the requests if the renter is stopped.
+	ctx, cancel := r.stopContext(ctx)
+
+	// Request the body before the file is created, so that the size of the
+	// file is known.
+	ur := &urlReader{
+		ctx:    ctx,
+		client: urlUploadClient,
+		url:    sourceURL,
+		length: -1,
+	}
+	if err := ur.open(); err != nil {
+		cancel()
+		return build.ExtendErr("unable to request "+sourceURL+":", err)
+	}
+	var size uint64
+	if ur.length > 0 {
+		size = uint64(ur.length)
+	}
+	f, err := r.managedAddUploadFile(params, size, 0644, nil)
+	if err != nil {
+		ur.Close()
+		cancel()
+		return err
+	}
+	go r.threadedUploadFromURL(ctx, cancel, f, ur)
+	return nil
+}
+
+// threadedUploadFromURL uploads the body of ur to the file f. Failures are
+// logged by managedFinishUploadStream, which also deletes the file.
+func (r *Renter) threadedUploadFromURL(ctx context.Context, cancel context.CancelFunc, f *file, ur *urlReader) {
+	defer cancel()
+	defer ur.Close()
+	if err := r.tg.Add(); err != nil {
+		r.managedDeleteStreamedFile(f)
+		return
+	}
+	defer r.tg.Done()
+	r.managedFinishUploadStream(ctx, f, ur)
+}
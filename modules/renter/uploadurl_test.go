@@ -0,0 +1,230 @@
+package renter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestUploadFromURL checks that UploadFromURL uploads the body of a URL with
+// and without a Content-Length in the background, resumes interrupted bodies,
+// retries transient errors a limited number of times, and deletes the file if
+// the upload fails or is cancelled.
+func TestUploadFromURL(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	tc := &throttledContractor{uploadTime: time.Millisecond}
+	for i := 0; i < 3; i++ {
+		tc.contracts = append(tc.contracts, modules.RenterContract{
+			ID:            types.FileContractID{byte(i + 1)},
+			HostPublicKey: types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{byte(i + 1)}},
+			GoodForUpload: true,
+			GoodForRenew:  true,
+		})
+	}
+	rt, err := newContractorTester(t.Name(), nil, tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	data := fastrand.Bytes(int(5*pieceSize + 100))
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	mux := http.NewServeMux()
+	handle := func(path string, fn func(w http.ResponseWriter, req *http.Request, n int)) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			requests[path]++
+			n := requests[path]
+			mu.Unlock()
+			fn(w, req, n)
+		})
+	}
+	numRequests := func(path string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests[path]
+	}
+	handle("/data", func(w http.ResponseWriter, req *http.Request, _ int) {
+		http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(data))
+	})
+	handle("/chunked", func(w http.ResponseWriter, req *http.Request, _ int) {
+		for i := 0; i < len(data); i += 1000 {
+			end := i + 1000
+			if end > len(data) {
+				end = len(data)
+			}
+			w.Write(data[i:end])
+			w.(http.Flusher).Flush()
+		}
+	})
+	handle("/flaky", func(w http.ResponseWriter, req *http.Request, n int) {
+		switch n {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			// Interrupt the body halfway.
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Write(data[:len(data)/2])
+		default:
+			http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(data))
+		}
+	})
+	handle("/down", func(w http.ResponseWriter, req *http.Request, _ int) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	handle("/missing", func(w http.ResponseWriter, req *http.Request, _ int) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	handle("/slow", func(w http.ResponseWriter, req *http.Request, _ int) {
+		w.Write(data[:pieceSize])
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ec, err := NewRSCode(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := modules.FileUploadParams{ErasureCode: ec}
+	for _, path := range []string{"/data", "/chunked", "/flaky"} {
+		if err := rt.renter.UploadFromURL(context.Background(), srv.URL+path, path[1:], params); err != nil {
+			t.Fatal(path, err)
+		}
+		err := build.Retry(100, 100*time.Millisecond, func() error {
+			id := rt.renter.mu.RLock()
+			f := rt.renter.files[path[1:]]
+			tf, tracked := rt.renter.tracking[path[1:]]
+			rt.renter.mu.RUnlock(id)
+			if f == nil {
+				return errors.New("file was deleted")
+			} else if !tracked || tf.RepairPath != "" {
+				return fmt.Errorf("file is not tracked without a local path: %+v", tf)
+			}
+			f.mu.RLock()
+			size, numChunks := f.size, f.numChunks()
+			redundancy := f.redundancy(func(types.FileContractID) bool { return false })
+			f.mu.RUnlock()
+			if size != uint64(len(data)) || numChunks != 6 {
+				return fmt.Errorf("wrong size %v with %v chunks", size, numChunks)
+			}
+			if redundancy != 3 {
+				return fmt.Errorf("expected a redundancy of 3, got %v", redundancy)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(path, err)
+		}
+	}
+	if n := numRequests("/flaky"); n != 3 {
+		t.Fatal("expected the flaky body to be requested 3 times, got", n)
+	}
+
+	if err := rt.renter.UploadFromURL(context.Background(), srv.URL+"/data", "data", params); err != ErrPathOverload {
+		t.Fatal("expected ErrPathOverload, got", err)
+	}
+	if err := rt.renter.UploadFromURL(context.Background(), "ftp://example.com/data", "ftp", params); err != errURLScheme {
+		t.Fatal("expected errURLScheme, got", err)
+	}
+	if err := rt.renter.UploadFromURL(context.Background(), srv.URL+"/missing", "missing", params); err == nil {
+		t.Fatal("expected the upload of a missing URL to fail")
+	} else if numRequests("/missing") != 1 {
+		t.Fatal("a missing URL was retried")
+	}
+	if err := rt.renter.UploadFromURL(context.Background(), srv.URL+"/down", "down", params); err == nil {
+		t.Fatal("expected the upload of an unavailable URL to fail")
+	} else if n := numRequests("/down"); n != maxURLUploadRetries+1 {
+		t.Fatal("wrong number of requests for an unavailable URL:", n)
+	}
+
+	// Cancelling the upload deletes the file.
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := rt.renter.UploadFromURL(ctx, srv.URL+"/slow", "slow", params); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		id := rt.renter.mu.RLock()
+		_, exists := rt.renter.files["slow"]
+		rt.renter.mu.RUnlock(id)
+		if exists {
+			return errors.New("file of a cancelled upload was not deleted")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestUploadFromURLStall checks that a request whose body stalls is retried
+// and resumed.
+func TestUploadFromURLStall(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	ur := &urlReader{
+		ctx:    context.Background(),
+		client: urlUploadClient,
+		length: -1,
+	}
+	data := fastrand.Bytes(1000)
+	var requests int
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		requests++
+		n := requests
+		mu.Unlock()
+		if n == 1 {
+			// Send half of the body, then stall.
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Write(data[:len(data)/2])
+			w.(http.Flusher).Flush()
+			<-req.Context().Done()
+			return
+		}
+		http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+	ur.url = srv.URL
+	defer ur.Close()
+
+	start := time.Now()
+	read, err := ioutil.ReadAll(ur)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(read, data) {
+		t.Fatal("body was not resumed correctly")
+	}
+	mu.Lock()
+	n := requests
+	mu.Unlock()
+	if n != 2 {
+		t.Fatal("expected 2 requests, got", n)
+	} else if time.Since(start) < urlUploadReadTimeout {
+		t.Fatal("request was retried before the read timed out")
+	}
+}
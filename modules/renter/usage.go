@@ -0,0 +1,366 @@
+package renter
+
+// usage.go breaks down the storage and bandwidth used by the renter by siapath
+// prefix, so that the users of a shared renter can be charged for the
+// directories that they use. Storage is computed from the renter's files when
+// the usage is requested. Transfers are counted by the workers as pieces are
+// uploaded to and downloaded from hosts, and are attributed to the directory of
+// the file that the piece belongs to. Every piece belongs to exactly one file,
+// so the usage of the prefixes always sums to the usage of the renter.
+//
+// The transfer counters are kept per directory rather than per prefix, so that
+// the usage can be requested at any depth. They are saved to disk every
+// usageSaveInterval and when the renter shuts down, and the counters of the
+// current month are reset at the start of every calendar month.
+//
+// The same transfers are also counted per contract, which together with the
+// sectors and spending of the contracts shows which contracts are actually
+// used. A renewed contract starts with new counters, and the counters of
+// contracts that expired or were renewed are dropped with their workers.
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// usageFile is the name of the file within the renter directory that
+	// holds the transfer counters.
+	usageFile = "usage.json"
+)
+
+var (
+	// errInvalidUsageDepth is returned if the usage is requested with a depth
+	// that is less than 1.
+	errInvalidUsageDepth = errors.New("usage depth must be at least 1")
+
+	usageMetadata = persist.Metadata{
+		Header:  "Renter Usage",
+		Version: "1.0",
+	}
+)
+
+type (
+	// transferUsage counts the bytes transferred for the files of a directory.
+	transferUsage struct {
+		Upload        uint64 `json:"upload"`
+		Download      uint64 `json:"download"`
+		MonthUpload   uint64 `json:"monthupload"`
+		MonthDownload uint64 `json:"monthdownload"`
+	}
+
+	// contractTransfer counts the bytes transferred through a contract.
+	contractTransfer struct {
+		ID       types.FileContractID `json:"id"`
+		Upload   uint64               `json:"upload"`
+		Download uint64               `json:"download"`
+	}
+
+	// usageTracker counts the bytes transferred for the files of every
+	// directory, keyed by the directory, and through every contract, keyed by
+	// the contract.
+	usageTracker struct {
+		directories map[string]*transferUsage
+		contracts   map[types.FileContractID]*contractTransfer
+		monthStart  time.Time
+		lastSave    time.Time
+
+		path string
+		mu   sync.Mutex
+	}
+
+	// usagePersist is the persisted form of a usageTracker.
+	usagePersist struct {
+		MonthStart  time.Time                `json:"monthstart"`
+		Directories map[string]transferUsage `json:"directories"`
+		Contracts   []contractTransfer       `json:"contracts"`
+	}
+)
+
+// monthStart returns the start of the calendar month of t in UTC.
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// siaPathDir returns the directory of the file at siaPath, or "" if the file
+// is not in a directory.
+func siaPathDir(siaPath string) string {
+	if i := strings.LastIndex(siaPath, "/"); i >= 0 {
+		return siaPath[:i]
+	}
+	return ""
+}
+
+// usagePrefix truncates the directory dir to its first depth levels.
+func usagePrefix(dir string, depth int) string {
+	if dir == "" {
+		return ""
+	}
+	levels := strings.SplitN(dir, "/", depth+1)
+	if len(levels) > depth {
+		levels = levels[:depth]
+	}
+	return strings.Join(levels, "/")
+}
+
+// newUsageTracker returns a usageTracker that is saved to path.
+func newUsageTracker(path string) *usageTracker {
+	return &usageTracker{
+		directories: make(map[string]*transferUsage),
+		contracts:   make(map[types.FileContractID]*contractTransfer),
+		monthStart:  monthStart(time.Now()),
+		lastSave:    time.Now(),
+		path:        path,
+	}
+}
+
+// load loads the transfer counters from disk.
+func (ut *usageTracker) load() error {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	var up usagePersist
+	if err := persist.LoadJSON(usageMetadata, &up, ut.path); err != nil {
+		return err
+	}
+	ut.monthStart = up.MonthStart
+	for dir, tu := range up.Directories {
+		tu := tu
+		ut.directories[dir] = &tu
+	}
+	for _, ct := range up.Contracts {
+		ct := ct
+		ut.contracts[ct.ID] = &ct
+	}
+	ut.rollover(time.Now())
+	return nil
+}
+
+// save saves the transfer counters to disk. The caller must hold the lock.
+func (ut *usageTracker) save() error {
+	up := usagePersist{
+		MonthStart:  ut.monthStart,
+		Directories: make(map[string]transferUsage, len(ut.directories)),
+		Contracts:   make([]contractTransfer, 0, len(ut.contracts)),
+	}
+	for dir, tu := range ut.directories {
+		up.Directories[dir] = *tu
+	}
+	for _, ct := range ut.contracts {
+		up.Contracts = append(up.Contracts, *ct)
+	}
+	ut.lastSave = time.Now()
+	return persist.SaveJSON(usageMetadata, up, ut.path)
+}
+
+// managedSave calls save while holding the lock.
+func (ut *usageTracker) managedSave() error {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	return ut.save()
+}
+
+// rollover resets the counters of the current month if a new month has
+// started since the counters were last reset. The caller must hold the lock.
+func (ut *usageTracker) rollover(now time.Time) {
+	start := monthStart(now)
+	if !start.After(ut.monthStart) {
+		return
+	}
+	for _, tu := range ut.directories {
+		tu.MonthUpload = 0
+		tu.MonthDownload = 0
+	}
+	ut.monthStart = start
+}
+
+// managedRecordTransfer adds the bytes that were uploaded and downloaded for
+// the file at siaPath through the contract id to the counters of the directory
+// of the file and of the contract. The counters are saved if they have not
+// been saved within usageSaveInterval.
+func (ut *usageTracker) managedRecordTransfer(siaPath string, id types.FileContractID, uploaded, downloaded uint64) error {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	now := time.Now()
+	ut.rollover(now)
+	dir := siaPathDir(siaPath)
+	tu, exists := ut.directories[dir]
+	if !exists {
+		tu = new(transferUsage)
+		ut.directories[dir] = tu
+	}
+	tu.Upload += uploaded
+	tu.Download += downloaded
+	tu.MonthUpload += uploaded
+	tu.MonthDownload += downloaded
+	ct, exists := ut.contracts[id]
+	if !exists {
+		ct = &contractTransfer{ID: id}
+		ut.contracts[id] = ct
+	}
+	ct.Upload += uploaded
+	ct.Download += downloaded
+	if now.Sub(ut.lastSave) < usageSaveInterval {
+		return nil
+	}
+	return ut.save()
+}
+
+// managedPruneContracts drops the counters of the contracts that are not in
+// active.
+func (ut *usageTracker) managedPruneContracts(active map[types.FileContractID]struct{}) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	for id := range ut.contracts {
+		if _, exists := active[id]; !exists {
+			delete(ut.contracts, id)
+		}
+	}
+}
+
+// managedRecordTransfer records a transfer of piece data for the file at
+// siaPath through the contract id in the renter's usage counters.
+func (r *Renter) managedRecordTransfer(siaPath string, id types.FileContractID, uploaded, downloaded uint64) {
+	if err := r.usage.managedRecordTransfer(siaPath, id, uploaded, downloaded); err != nil {
+		r.log.Println("WARN: could not save the usage counters:", err)
+	}
+}
+
+// physicalBytes returns the size of the pieces of the file that are stored on
+// hosts. The caller must hold the lock of f.
+func (f *file) physicalBytes() uint64 {
+	var pieces uint64
+	for _, fc := range f.contracts {
+		pieces += uint64(len(fc.Pieces))
+	}
+	// The uploaded pieces are encrypted.
+	return pieces * (f.pieceSize + crypto.TwofishOverhead)
+}
+
+// UsageByPrefix returns the storage and bandwidth used by the renter, broken
+// down by the directories of the files, truncated to depth levels.
+func (r *Renter) UsageByPrefix(depth int) (modules.RenterUsage, error) {
+	if depth < 1 {
+		return modules.RenterUsage{}, errInvalidUsageDepth
+	}
+	prefixes := make(map[string]*modules.RenterPrefixUsage)
+	prefixUsage := func(dir string) *modules.RenterPrefixUsage {
+		prefix := usagePrefix(dir, depth)
+		pu, exists := prefixes[prefix]
+		if !exists {
+			pu = &modules.RenterPrefixUsage{Prefix: prefix}
+			prefixes[prefix] = pu
+		}
+		return pu
+	}
+
+	// Add the storage of the files.
+	usage := modules.RenterUsage{
+		Depth:    depth,
+		Prefixes: []modules.RenterPrefixUsage{},
+	}
+	lockID := r.mu.RLock()
+	for _, f := range r.files {
+		pu := prefixUsage(siaPathDir(f.name))
+		f.mu.RLock()
+		physical := f.physicalBytes()
+		pu.Files++
+		pu.LogicalBytes += f.size
+		pu.PhysicalBytes += physical
+		usage.Total.Files++
+		usage.Total.LogicalBytes += f.size
+		usage.Total.PhysicalBytes += physical
+		f.mu.RUnlock()
+	}
+	// Previous versions of the files use storage, but are not counted as
+	// files.
+	for siaPath, fv := range r.versions {
+		pu := prefixUsage(siaPathDir(siaPath))
+		_, physical := retainedVersions(fv)
+		pu.PhysicalBytes += physical
+		pu.VersionBytes += physical
+		usage.Total.PhysicalBytes += physical
+		usage.Total.VersionBytes += physical
+	}
+	r.mu.RUnlock(lockID)
+
+	// Add the transfers of the directories.
+	r.usage.mu.Lock()
+	r.usage.rollover(time.Now())
+	usage.MonthStart = r.usage.monthStart
+	for dir, tu := range r.usage.directories {
+		pu := prefixUsage(dir)
+		pu.UploadBytes += tu.Upload
+		pu.DownloadBytes += tu.Download
+		pu.MonthUploadBytes += tu.MonthUpload
+		pu.MonthDownloadBytes += tu.MonthDownload
+		usage.Total.UploadBytes += tu.Upload
+		usage.Total.DownloadBytes += tu.Download
+		usage.Total.MonthUploadBytes += tu.MonthUpload
+		usage.Total.MonthDownloadBytes += tu.MonthDownload
+	}
+	r.usage.mu.Unlock()
+
+	for _, pu := range prefixes {
+		usage.Prefixes = append(usage.Prefixes, *pu)
+	}
+	sort.Slice(usage.Prefixes, func(i, j int) bool {
+		return usage.Prefixes[i].Prefix < usage.Prefixes[j].Prefix
+	})
+	return usage, nil
+}
+
+// ContractUtilization returns the utilization of the contracts of every
+// portfolio, keyed by contract ID.
+func (r *Renter) ContractUtilization() map[string]modules.ContractUtilization {
+	utilization := make(map[string]modules.ContractUtilization)
+	for _, hc := range r.managedPortfolioContractors() {
+		for _, c := range hc.Contracts() {
+			utilization[c.ID.String()] = modules.ContractUtilization{
+				ContractID:     c.ID,
+				HostAddress:    c.NetAddress,
+				DataStored:     uint64(len(c.MerkleRoots)) * modules.SectorSize,
+				FundsSpent:     c.UploadSpending.Add(c.DownloadSpending).Add(c.StorageSpending).Add(c.ContractFee).Add(c.TxnFee).Add(c.SiafundFee),
+				FundsRemaining: c.RenterFunds(),
+			}
+		}
+	}
+
+	// Add the transfers of the contracts.
+	r.usage.mu.Lock()
+	for _, ct := range r.usage.contracts {
+		if cu, exists := utilization[ct.ID.String()]; exists {
+			cu.DataUploaded = ct.Upload
+			cu.DataDownloaded = ct.Download
+			utilization[ct.ID.String()] = cu
+		}
+	}
+	r.usage.mu.Unlock()
+	return utilization
+}
+
+// initUsage loads the transfer counters of the renter, and saves them when the
+// renter shuts down.
+func (r *Renter) initUsage() error {
+	r.usage = newUsageTracker(filepath.Join(r.persistDir, usageFile))
+	err := r.usage.load()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	r.tg.OnStop(func() error {
+		return r.usage.managedSave()
+	})
+	return nil
+}
@@ -0,0 +1,298 @@
+package renter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestUsagePrefix checks that the directories of siapaths are truncated to
+// the requested depth.
+func TestUsagePrefix(t *testing.T) {
+	for _, test := range []struct {
+		siaPath string
+		depth   int
+		prefix  string
+	}{
+		{"foo", 1, ""},
+		{"a/foo", 1, "a"},
+		{"a/foo", 2, "a"},
+		{"a/b/c/foo", 1, "a"},
+		{"a/b/c/foo", 2, "a/b"},
+		{"a/b/c/foo", 3, "a/b/c"},
+		{"a/b/c/foo", 4, "a/b/c"},
+	} {
+		if prefix := usagePrefix(siaPathDir(test.siaPath), test.depth); prefix != test.prefix {
+			t.Errorf("prefix of %q at depth %v is %q, expected %q", test.siaPath, test.depth, prefix, test.prefix)
+		}
+	}
+}
+
+// checkUsageSums checks that the usage of the prefixes sums to the total usage.
+func checkUsageSums(usage modules.RenterUsage) error {
+	var sum modules.RenterPrefixUsage
+	for _, pu := range usage.Prefixes {
+		sum.Files += pu.Files
+		sum.LogicalBytes += pu.LogicalBytes
+		sum.PhysicalBytes += pu.PhysicalBytes
+		sum.UploadBytes += pu.UploadBytes
+		sum.DownloadBytes += pu.DownloadBytes
+		sum.MonthUploadBytes += pu.MonthUploadBytes
+		sum.MonthDownloadBytes += pu.MonthDownloadBytes
+	}
+	if sum != usage.Total {
+		return fmt.Errorf("usage of the prefixes sums to %+v, but the total is %+v", sum, usage.Total)
+	}
+	return nil
+}
+
+// TestUsageByPrefix checks that the storage and transfers of the renter are
+// attributed to the prefixes of the files.
+func TestUsageByPrefix(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+	r := rt.renter
+
+	if _, err := r.UsageByPrefix(0); err != errInvalidUsageDepth {
+		t.Fatal("expected errInvalidUsageDepth, got", err)
+	}
+
+	// Add files in several directories, with one piece on a host per 100
+	// bytes of the file.
+	rsc, _ := NewRSCode(1, 1)
+	sizes := map[string]uint64{
+		"root":          100,
+		"teamA/foo":     200,
+		"teamA/x/bar":   300,
+		"teamA/y/baz":   400,
+		"teamB/x/y/qux": 500,
+	}
+	id := r.mu.Lock()
+	for name, size := range sizes {
+		f := newFile(name, rsc, 64, size)
+		for i := uint64(0); i < size/100; i++ {
+			fc := f.contracts[types.FileContractID{byte(i)}]
+			fc.Pieces = append(fc.Pieces, pieceData{Chunk: i})
+			f.contracts[types.FileContractID{byte(i)}] = fc
+		}
+		r.files[name] = f
+	}
+	r.mu.Unlock(id)
+	for name, size := range sizes {
+		r.managedRecordTransfer(name, types.FileContractID{}, 2*size, 0)
+		r.managedRecordTransfer(name, types.FileContractID{}, 0, size)
+	}
+	// Transfers of deleted files are still counted.
+	r.managedRecordTransfer("teamB/gone", types.FileContractID{}, 1000, 1000)
+
+	pieceSize := uint64(64 + crypto.TwofishOverhead)
+	expected := map[string]modules.RenterPrefixUsage{
+		"":      {Files: 1, LogicalBytes: 100, PhysicalBytes: 1 * pieceSize, UploadBytes: 200, DownloadBytes: 100},
+		"teamA": {Files: 3, LogicalBytes: 900, PhysicalBytes: 9 * pieceSize, UploadBytes: 1800, DownloadBytes: 900},
+		"teamB": {Files: 1, LogicalBytes: 500, PhysicalBytes: 5 * pieceSize, UploadBytes: 2000, DownloadBytes: 1500},
+	}
+	usage, err := r.UsageByPrefix(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage.Prefixes) != len(expected) {
+		t.Fatalf("expected %v prefixes, got %+v", len(expected), usage.Prefixes)
+	}
+	for _, pu := range usage.Prefixes {
+		exp := expected[pu.Prefix]
+		exp.Prefix = pu.Prefix
+		exp.MonthUploadBytes = exp.UploadBytes
+		exp.MonthDownloadBytes = exp.DownloadBytes
+		if pu != exp {
+			t.Errorf("expected usage %+v, got %+v", exp, pu)
+		}
+	}
+	if err := checkUsageSums(usage); err != nil {
+		t.Fatal(err)
+	}
+	if usage.Total.Files != 5 || usage.Total.LogicalBytes != 1500 || usage.Total.UploadBytes != 4000 {
+		t.Fatalf("unexpected total usage: %+v", usage.Total)
+	}
+
+	// Deeper prefixes split the usage up further, with the same totals.
+	for depth := 2; depth <= 4; depth++ {
+		deeper, err := r.UsageByPrefix(depth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkUsageSums(deeper); err != nil {
+			t.Fatal(err)
+		}
+		if deeper.Total != usage.Total {
+			t.Fatalf("total usage at depth %v is %+v, expected %+v", depth, deeper.Total, usage.Total)
+		}
+	}
+	usage, _ = r.UsageByPrefix(2)
+	if len(usage.Prefixes) != 6 || usage.Prefixes[4].Prefix != "teamB" || usage.Prefixes[5].Prefix != "teamB/x" {
+		t.Fatalf("unexpected prefixes at depth 2: %+v", usage.Prefixes)
+	}
+
+	// The month counters are reset when a new month starts, and the counters
+	// survive a restart.
+	r.usage.mu.Lock()
+	r.usage.monthStart = r.usage.monthStart.AddDate(0, -1, 0)
+	r.usage.mu.Unlock()
+	usage, _ = r.UsageByPrefix(1)
+	if usage.Total.MonthUploadBytes != 0 || usage.Total.MonthDownloadBytes != 0 || usage.Total.UploadBytes != 4000 {
+		t.Fatalf("month counters were not reset: %+v", usage.Total)
+	}
+	if !usage.MonthStart.Equal(monthStart(time.Now())) {
+		t.Fatal("month start was not updated:", usage.MonthStart)
+	}
+	r.managedRecordTransfer("teamA/foo", types.FileContractID{}, 10, 0)
+	if err := r.usage.managedSave(); err != nil {
+		t.Fatal(err)
+	}
+	ut := newUsageTracker(r.usage.path)
+	if err := ut.load(); err != nil {
+		t.Fatal(err)
+	}
+	if tu := ut.directories["teamA"]; tu == nil || tu.Upload != 410 || tu.MonthUpload != 10 || tu.Download != 200 {
+		t.Fatalf("counters were not loaded: %+v", tu)
+	}
+}
+
+// TestUsageUploads uploads a file to throttled hosts, and checks that the
+// uploaded bytes are attributed to the directory of the file and to the
+// contracts that they were uploaded through.
+func TestUsageUploads(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	tc := &throttledContractor{uploadTime: time.Millisecond}
+	for i := 0; i < 3; i++ {
+		tc.contracts = append(tc.contracts, modules.RenterContract{
+			ID:            types.FileContractID{byte(i + 1)},
+			HostPublicKey: types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{byte(i + 1)}},
+			GoodForUpload: true,
+			GoodForRenew:  true,
+			LastRevision: types.FileContractRevision{
+				NewValidProofOutputs: []types.SiacoinOutput{{Value: types.NewCurrency64(100)}, {}},
+			},
+			MerkleRoots:    modules.MerkleRootSet{{}},
+			UploadSpending: types.NewCurrency64(10),
+			ContractFee:    types.NewCurrency64(5),
+		})
+	}
+	rt, err := newContractorTester(t.Name(), nil, tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	ec, err := NewRSCode(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := build.TempDir("renter", t.Name(), "source")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(source, fastrand.Bytes(int(4*pieceSize)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	err = rt.renter.Upload(modules.FileUploadParams{
+		Source:      source,
+		SiaPath:     "teamA/foo",
+		ErasureCode: ec,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 50*time.Millisecond, func() error {
+		if fi := rt.renter.FileList()[0]; fi.UploadProgress < 100 {
+			return fmt.Errorf("upload is at %v%%", fi.UploadProgress)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := rt.renter.UsageByPrefix(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage.Prefixes) != 1 || usage.Prefixes[0].Prefix != "teamA" {
+		t.Fatalf("expected the usage of teamA, got %+v", usage.Prefixes)
+	}
+	pu := usage.Prefixes[0]
+	if pu.PhysicalBytes == 0 || pu.UploadBytes != pu.PhysicalBytes || pu.MonthUploadBytes != pu.UploadBytes {
+		t.Fatalf("uploaded bytes do not match the stored pieces: %+v", pu)
+	}
+
+	// Every contract received a piece of every chunk.
+	utilization := rt.renter.ContractUtilization()
+	if len(utilization) != len(tc.contracts) {
+		t.Fatalf("expected the utilization of %v contracts, got %v", len(tc.contracts), len(utilization))
+	}
+	var uploaded uint64
+	for _, c := range tc.contracts {
+		cu, exists := utilization[c.ID.String()]
+		if !exists {
+			t.Fatal("missing the utilization of contract", c.ID)
+		}
+		if cu.ContractID != c.ID || cu.DataStored != modules.SectorSize || cu.DataUploaded != pu.UploadBytes/3 || cu.DataDownloaded != 0 {
+			t.Fatalf("wrong utilization of contract %v: %+v", c.ID, cu)
+		}
+		if !cu.FundsSpent.Equals64(15) || !cu.FundsRemaining.Equals64(100) {
+			t.Fatalf("wrong funds of contract %v: %v spent, %v remaining", c.ID, cu.FundsSpent, cu.FundsRemaining)
+		}
+		uploaded += cu.DataUploaded
+	}
+	if uploaded != pu.UploadBytes {
+		t.Fatalf("contracts uploaded %v bytes, the directory %v", uploaded, pu.UploadBytes)
+	}
+
+	// The counters of the contracts are saved.
+	if err := rt.renter.usage.managedSave(); err != nil {
+		t.Fatal(err)
+	}
+	ut := newUsageTracker(rt.renter.usage.path)
+	if err := ut.load(); err != nil {
+		t.Fatal(err)
+	}
+	if ct := ut.contracts[tc.contracts[0].ID]; ct == nil || ct.Upload != pu.UploadBytes/3 {
+		t.Fatalf("contract counters were not loaded: %+v", ct)
+	}
+
+	// The counters of contracts that are no longer active are dropped.
+	ut.managedPruneContracts(map[types.FileContractID]struct{}{
+		tc.contracts[1].ID: {},
+		tc.contracts[2].ID: {},
+	})
+	if _, exists := ut.contracts[tc.contracts[0].ID]; exists || len(ut.contracts) != 2 {
+		t.Fatal("counters of an inactive contract were not dropped:", ut.contracts)
+	}
+	rt.renter.managedUpdateWorkerPool()
+	rt.renter.usage.mu.Lock()
+	n := len(rt.renter.usage.contracts)
+	rt.renter.usage.mu.Unlock()
+	if n != len(tc.contracts) {
+		t.Fatal("counters of active contracts were dropped:", n)
+	}
+}
@@ -0,0 +1,429 @@
+package renter
+
+// versions.go implements file versioning. When the version retention of a
+// file keeps previous versions, uploading to a siapath that is already in use
+// turns the current file into a previous version of the new file instead of
+// failing. Deleting the file turns it into a previous version as well if the
+// retention has an age limit, so that the pieces of a deleted file are always
+// deleted from the hosts eventually.
+// Previous versions keep their pieces on the hosts and can still be
+// downloaded, but they are not repaired. Once a siapath has more previous
+// versions than the retention allows, or a previous version was replaced
+// longer ago than the retention allows, the version is removed and its pieces
+// are deleted from the hosts.
+//
+// The version retention of a file is that of its closest directory that has
+// one, and otherwise the MaxVersionsPerFile of the allowance of its
+// portfolio, without an age limit.
+//
+// Previous versions are saved next to the .sia file of the current version.
+// The version numbers, upload times, and replacement times are saved with the
+// renter.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+var (
+	// errUnknownVersion is returned if a version of a file is requested that
+	// the renter does not have.
+	errUnknownVersion = errors.New("no version of the file with that number")
+
+	// errInvalidVersionRetention is returned if a version retention has a
+	// negative limit.
+	errInvalidVersionRetention = errors.New("version retention cannot be negative")
+)
+
+type (
+	// fileVersion is a previous version of a file. The exported fields are
+	// persisted.
+	fileVersion struct {
+		Version      int
+		UploadTime   time.Time
+		ReplacedTime time.Time
+
+		file *file
+	}
+
+	// fileVersions contains the version number of the current file at a
+	// siapath, and the previous versions of the file, oldest first.
+	fileVersions struct {
+		Current  int
+		Previous []*fileVersion
+	}
+)
+
+// versionPath returns the path that the provided previous version of the file
+// at siaPath is saved to.
+func (r *Renter) versionPath(siaPath string, version int) string {
+	return filepath.Join(r.persistDir, siaPath+ShareExtension+fmt.Sprintf(".v%d", version))
+}
+
+// loadVersion loads the provided previous version of the file at siaPath from
+// disk.
+func (r *Renter) loadVersion(siaPath string, version int) (*file, error) {
+	osFile, err := os.Open(r.versionPath(siaPath, version))
+	if err != nil {
+		return nil, err
+	}
+	defer osFile.Close()
+	files, err := readSharedFiles(osFile)
+	if err != nil {
+		return nil, err
+	} else if len(files) != 1 || files[0].name != siaPath {
+		return nil, ErrBadFile
+	}
+	files[0].version = version
+	return files[0], nil
+}
+
+// loadVersions loads the previous versions of the renter's files after the
+// files have been loaded from disk. Previous versions that cannot be loaded
+// are dropped. Previous versions that were saved without the time they were
+// replaced are considered to be replaced when they are loaded.
+func (r *Renter) loadVersions(versions map[string]*fileVersions) {
+	now := time.Now()
+	for siaPath, fv := range versions {
+		var previous []*fileVersion
+		for _, v := range fv.Previous {
+			f, err := r.loadVersion(siaPath, v.Version)
+			if err != nil {
+				r.log.Printf("WARN: dropping version %v of %v: %v\n", v.Version, siaPath, err)
+				continue
+			}
+			if v.ReplacedTime.IsZero() {
+				v.ReplacedTime = now
+			}
+			v.file = f
+			previous = append(previous, v)
+		}
+		fv.Previous = previous
+		if _, exists := r.files[siaPath]; !exists && len(previous) == 0 {
+			continue
+		}
+		r.versions[siaPath] = fv
+	}
+}
+
+// removeVersions removes the previous versions in fv of the file at siaPath
+// from disk.
+func (r *Renter) removeVersions(siaPath string, fv *fileVersions) {
+	for _, v := range fv.Previous {
+		err := os.Remove(r.versionPath(siaPath, v.Version))
+		if err != nil && !os.IsNotExist(err) {
+			r.log.Println("WARN: couldn't remove file version:", err)
+		}
+	}
+}
+
+// keepsVersions reports whether the retention vr keeps previous versions. A
+// retention with a MaxAge but no MaxVersions keeps any number of previous
+// versions until they are too old.
+func keepsVersions(vr modules.VersionRetention) bool {
+	return vr.MaxVersions > 0 || vr.MaxAge > 0
+}
+
+// keepsDeletedFiles reports whether the retention vr keeps deleted files as
+// previous versions. Only a retention with an age limit does, because a
+// deleted file never gets another version that would push it out of a count
+// limit.
+func keepsDeletedFiles(vr modules.VersionRetention) bool {
+	return vr.MaxAge > 0
+}
+
+// retainedVersions returns the number of previous versions in fv and the size
+// of their pieces on the hosts. The caller must hold the renter lock.
+func retainedVersions(fv *fileVersions) (n int, physical uint64) {
+	for _, v := range fv.Previous {
+		v.file.mu.RLock()
+		physical += v.file.physicalBytes()
+		v.file.mu.RUnlock()
+	}
+	return len(fv.Previous), physical
+}
+
+// retentionFor returns the version retention of the file or directory at
+// siaPath. Files cannot have a retention of their own, so the lookup starts at
+// siaPath itself. The caller must hold the renter lock.
+func (r *Renter) retentionFor(siaPath string) modules.VersionRetention {
+	for dir := siaPath; ; dir = siaPathDir(dir) {
+		if vr, exists := r.versionRetention[dir]; exists {
+			return vr
+		}
+		if dir == "" {
+			break
+		}
+	}
+	hc, _ := r.portfolioContractor(r.portfolioForSiaPath(siaPath))
+	return modules.VersionRetention{MaxVersions: hc.Allowance().MaxVersionsPerFile}
+}
+
+// addVersion turns the current file f into the newest previous version of its
+// siapath, and removes the previous versions that exceed the retention vr.
+// The pieces of the removed versions are deleted from the hosts. The caller
+// must hold the renter lock.
+func (r *Renter) addVersion(f *file, vr modules.VersionRetention) error {
+	fv, exists := r.versions[f.name]
+	if !exists {
+		fv = &fileVersions{Current: 1}
+	}
+	f.mu.Lock()
+	f.version = fv.Current
+	err := r.saveFile(f)
+	if err != nil {
+		f.version = 0
+	}
+	f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// The piece history belongs to the current version, and previous versions
+	// do not keep one.
+	r.cancelRedundancyChange(f.name)
+	err = os.Remove(r.pieceHistoryPath(f.name))
+	if err != nil && !os.IsNotExist(err) {
+		r.log.Println("WARN: couldn't remove piece history :", err)
+	}
+
+	now := time.Now()
+	fv.Previous = append(fv.Previous, &fileVersion{
+		Version:      fv.Current,
+		UploadTime:   r.tracking[f.name].UploadTime,
+		ReplacedTime: now,
+		file:         f,
+	})
+	fv.Current++
+	r.versions[f.name] = fv
+	r.pruneVersions(f.name, vr, now)
+	return nil
+}
+
+// pruneVersions removes the previous versions of the file at siaPath that
+// exceed the retention vr, and deletes their pieces from the hosts. A MaxVersions
+// of 0 with a MaxAge does not limit the number of versions. The number of
+// removed versions is returned. The caller must hold the renter lock.
+func (r *Renter) pruneVersions(siaPath string, vr modules.VersionRetention, now time.Time) int {
+	fv, exists := r.versions[siaPath]
+	if !exists {
+		return 0
+	}
+	var excess int
+	if vr.MaxVersions > 0 || vr.MaxAge == 0 {
+		excess = len(fv.Previous) - vr.MaxVersions
+	}
+	var kept []*fileVersion
+	for i, v := range fv.Previous {
+		if i >= excess && (vr.MaxAge == 0 || now.Sub(v.ReplacedTime) <= vr.MaxAge) {
+			kept = append(kept, v)
+			continue
+		}
+		err := os.Remove(r.versionPath(siaPath, v.Version))
+		if err != nil && !os.IsNotExist(err) {
+			r.log.Println("WARN: couldn't remove file version:", err)
+		}
+		go r.threadedDeletePieces(v.file, r.fileContractor(v.file))
+	}
+	removed := len(fv.Previous) - len(kept)
+	fv.Previous = kept
+	if _, exists := r.files[siaPath]; !exists && len(kept) == 0 {
+		delete(r.versions, siaPath)
+	}
+	return removed
+}
+
+// purgeVersions removes all previous versions of the file at siaPath, and
+// deletes their pieces from the hosts. The caller must hold the renter lock.
+func (r *Renter) purgeVersions(siaPath string) {
+	fv, exists := r.versions[siaPath]
+	if !exists {
+		return
+	}
+	r.removeVersions(siaPath, fv)
+	delete(r.versions, siaPath)
+	for _, v := range fv.Previous {
+		go r.threadedDeletePieces(v.file, r.fileContractor(v.file))
+	}
+}
+
+// threadedPruneVersions periodically removes the previous versions that have
+// exceeded their retention.
+func (r *Renter) threadedPruneVersions() {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	for {
+		select {
+		case <-r.tg.StopChan():
+			return
+		case <-time.After(versionPruneInterval):
+		}
+		r.managedPruneVersions()
+	}
+}
+
+// managedPruneVersions removes the previous versions of every file that have
+// exceeded their retention.
+func (r *Renter) managedPruneVersions() {
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	now := time.Now()
+	var removed int
+	for siaPath := range r.versions {
+		removed += r.pruneVersions(siaPath, r.retentionFor(siaPath), now)
+	}
+	if removed == 0 {
+		return
+	}
+	r.log.Printf("Removed %v previous versions of files that exceeded their retention\n", removed)
+	if err := r.saveSync(); err != nil {
+		r.log.Println("WARN: could not save the renter after removing previous versions:", err)
+	}
+}
+
+// renameVersions moves the previous versions of the file at currentName to
+// newName. If a version cannot be saved, the versions keep currentName. The
+// caller must hold the renter lock.
+func (r *Renter) renameVersions(currentName, newName string) error {
+	fv, exists := r.versions[currentName]
+	if !exists {
+		return nil
+	}
+	for i, v := range fv.Previous {
+		v.file.mu.Lock()
+		v.file.name = newName
+		err := r.saveFile(v.file)
+		v.file.mu.Unlock()
+		if err != nil {
+			for _, v := range fv.Previous[:i+1] {
+				v.file.mu.Lock()
+				v.file.name = currentName
+				v.file.mu.Unlock()
+			}
+			return err
+		}
+	}
+	delete(r.versions, currentName)
+	r.versions[newName] = fv
+	r.removeVersions(currentName, fv)
+	return nil
+}
+
+// ListVersions returns the versions of the file at siaPath, oldest first. nil
+// is returned if there is no file or previous version at siaPath. If the file
+// was deleted, only its previous versions are returned.
+func (r *Renter) ListVersions(siaPath string) []modules.FileVersion {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	f, exists := r.files[siaPath]
+	if _, hasVersions := r.versions[siaPath]; !exists && !hasVersions {
+		return nil
+	}
+	portfolio := r.portfolioForSiaPath(siaPath)
+	current := 1
+	var versions []modules.FileVersion
+	if fv, exists := r.versions[siaPath]; exists {
+		current = fv.Current
+		for _, v := range fv.Previous {
+			isOffline := r.contractOfflineFunc(r.fileContractor(v.file))
+			v.file.mu.RLock()
+			versions = append(versions, modules.FileVersion{
+				FileInfo: v.file.fileInfo(portfolio, v.UploadTime, isOffline),
+				Version:  v.Version,
+			})
+			v.file.mu.RUnlock()
+		}
+	}
+	if !exists {
+		return versions
+	}
+	isOffline := r.contractOfflineFunc(r.fileContractor(f))
+	f.mu.RLock()
+	versions = append(versions, modules.FileVersion{
+		FileInfo: f.fileInfo(portfolio, r.tracking[siaPath].UploadTime, isOffline),
+		Version:  current,
+		Current:  true,
+	})
+	f.mu.RUnlock()
+	return versions
+}
+
+// DownloadVersion downloads the provided version of the file at p.Siapath
+// using the passed parameters. The previous versions of a deleted file can
+// still be downloaded.
+func (r *Renter) DownloadVersion(p modules.RenterDownloadParameters, version int) error {
+	id := r.mu.RLock()
+	f, exists := r.files[p.Siapath]
+	fv, hasVersions := r.versions[p.Siapath]
+	if !exists && !hasVersions {
+		r.mu.RUnlock(id)
+		return ErrUnknownPath
+	}
+	if !hasVersions {
+		fv = &fileVersions{Current: 1}
+	}
+	if version != fv.Current {
+		f = nil
+		for _, v := range fv.Previous {
+			if v.Version == version {
+				f = v.file
+			}
+		}
+	}
+	r.mu.RUnlock(id)
+	if f == nil {
+		return errUnknownVersion
+	}
+	return r.managedDownloadFile(f, p)
+}
+
+// SetVersionRetention sets the version retention of the files in the
+// directory dir and its subdirectories, unless a subdirectory has a retention
+// of its own. The previous versions of the files that exceed the new
+// retention are removed right away.
+func (r *Renter) SetVersionRetention(dir string, vr modules.VersionRetention) error {
+	if err := validateDirPath(dir); err != nil {
+		return err
+	}
+	if vr.MaxVersions < 0 || vr.MaxAge < 0 {
+		return errInvalidVersionRetention
+	}
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	if _, exists := r.files[dir]; exists {
+		return ErrPathOverload
+	}
+	r.versionRetention[dir] = vr
+	now := time.Now()
+	for siaPath := range r.versions {
+		r.pruneVersions(siaPath, r.retentionFor(siaPath), now)
+	}
+	return r.saveSync()
+}
+
+// ClearVersionRetention removes the version retention of the directory dir.
+func (r *Renter) ClearVersionRetention(dir string) error {
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	if _, exists := r.versionRetention[dir]; !exists {
+		return nil
+	}
+	delete(r.versionRetention, dir)
+	return r.saveSync()
+}
+
+// VersionRetention returns the version retention of the file or directory at
+// siaPath.
+func (r *Renter) VersionRetention(siaPath string) modules.VersionRetention {
+	lockID := r.mu.RLock()
+	defer r.mu.RUnlock(lockID)
+	return r.retentionFor(siaPath)
+}
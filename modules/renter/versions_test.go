@@ -0,0 +1,304 @@
+package renter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// versioningContractor is a hostContractor whose allowance keeps maxVersions
+// previous versions of every file.
+type versioningContractor struct {
+	hostContractor
+	maxVersions int
+}
+
+func (vc *versioningContractor) Allowance() modules.Allowance {
+	return modules.Allowance{MaxVersionsPerFile: vc.maxVersions}
+}
+
+// TestFileVersions checks that uploads to a siapath that is in use create new
+// versions of the file while versioning is enabled, that the oldest versions
+// are removed once there are too many, and that the versions follow the file
+// through renames, restarts, and deletion.
+func TestFileVersions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	source := filepath.Join(build.TempDir("renter", t.Name()), "source")
+	if err := os.MkdirAll(filepath.Dir(source), 0700); err != nil {
+		t.Fatal(err)
+	}
+	upload := func(data string) error {
+		if err := ioutil.WriteFile(source, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return rt.renter.Upload(modules.FileUploadParams{Source: source, SiaPath: "dir/a"})
+	}
+
+	// Without versioning, uploads to a siapath that is in use fail.
+	if err := upload("1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := upload("22"); err != ErrPathOverload {
+		t.Fatal("expected ErrPathOverload, got", err)
+	}
+	if versions := rt.renter.ListVersions("dir/a"); len(versions) != 1 || versions[0].Version != 1 || !versions[0].Current {
+		t.Fatal("expected a single current version, got", versions)
+	}
+
+	// Keep two previous versions, and upload three more versions.
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = &versioningContractor{hostContractor: rt.renter.hostContractor, maxVersions: 2}
+	rt.renter.mu.Unlock(id)
+	for _, data := range []string{"22", "333", "4444"} {
+		if err := upload(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	versions := rt.renter.ListVersions("dir/a")
+	if len(versions) != 3 {
+		t.Fatal("expected 3 versions, got", versions)
+	}
+	for i, v := range versions {
+		if v.Version != i+2 || v.Filesize != uint64(i+2) || v.Current != (i == 2) {
+			t.Errorf("version %v reported incorrectly: %+v", i, v)
+		}
+	}
+	if _, err := os.Stat(rt.renter.versionPath("dir/a", 1)); !os.IsNotExist(err) {
+		t.Error("oldest version was not removed:", err)
+	}
+	if _, err := os.Stat(rt.renter.versionPath("dir/a", 2)); err != nil {
+		t.Error("previous version was not saved:", err)
+	}
+	if len(rt.renter.FileList()) != 1 {
+		t.Error("previous versions should not be listed as files")
+	}
+
+	// Only versions that the renter has can be downloaded.
+	p := modules.RenterDownloadParameters{Siapath: "dir/a", Destination: filepath.Join(filepath.Dir(source), "dest")}
+	if err := rt.renter.DownloadVersion(p, 1); err != errUnknownVersion {
+		t.Fatal("expected errUnknownVersion, got", err)
+	}
+	p.Siapath = "missing"
+	if err := rt.renter.DownloadVersion(p, 2); err != ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+
+	// The versions are renamed with the file.
+	if err := rt.renter.RenameFile("dir/a", "dir/b"); err != nil {
+		t.Fatal(err)
+	}
+	if versions := rt.renter.ListVersions("dir/b"); len(versions) != 3 || versions[0].SiaPath != "dir/b" {
+		t.Fatal("versions were not renamed:", versions)
+	}
+	if _, err := os.Stat(rt.renter.versionPath("dir/a", 2)); !os.IsNotExist(err) {
+		t.Error("version was not moved:", err)
+	}
+
+	// Restart the renter. The previous versions should be restored.
+	id = rt.renter.mu.RLock()
+	masterKey := rt.renter.versions["dir/b"].Previous[1].file.masterKey
+	rt.renter.mu.RUnlock(id)
+	err = rt.renter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, rt.renter.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if versions := rt.renter.ListVersions("dir/b"); len(versions) != 3 || versions[2].Version != 4 || !versions[2].Current {
+		t.Fatal("versions were not restored:", versions)
+	}
+	id = rt.renter.mu.RLock()
+	v := rt.renter.versions["dir/b"].Previous[1]
+	if v.Version != 3 || v.file.version != 3 || !bytes.Equal(v.file.masterKey[:], masterKey[:]) {
+		t.Error("previous version was not restored:", v)
+	}
+	rt.renter.mu.RUnlock(id)
+
+	// Deleting the file removes its versions.
+	if err := rt.renter.DeleteFile("dir/b"); err != nil {
+		t.Fatal(err)
+	}
+	if versions := rt.renter.ListVersions("dir/b"); versions != nil {
+		t.Fatal("expected no versions of a deleted file, got", versions)
+	}
+	if _, err := os.Stat(rt.renter.versionPath("dir/b", 3)); !os.IsNotExist(err) {
+		t.Error("version of deleted file was not removed:", err)
+	}
+}
+
+// TestVersionRetention checks that the version retention of a directory
+// overrides the allowance, that deleted files are kept as previous versions,
+// that versions are pruned once they are too old, and that purging a file
+// removes all of its versions.
+func TestVersionRetention(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	source := filepath.Join(build.TempDir("renter", t.Name(), "source"), "file")
+	if err := os.MkdirAll(filepath.Dir(source), 0700); err != nil {
+		t.Fatal(err)
+	}
+	upload := func(siaPath, data string) error {
+		if err := ioutil.WriteFile(source, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return rt.renter.Upload(modules.FileUploadParams{Source: source, SiaPath: siaPath})
+	}
+
+	// The retention of the closest directory applies, and the allowance is
+	// used outside of it.
+	if err := rt.renter.SetVersionRetention("dir", modules.VersionRetention{MaxVersions: -1}); err != errInvalidVersionRetention {
+		t.Fatal("expected errInvalidVersionRetention, got", err)
+	}
+	if err := rt.renter.SetVersionRetention("dir", modules.VersionRetention{MaxVersions: 1, MaxAge: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	if vr := rt.renter.VersionRetention("dir/sub/a"); vr.MaxVersions != 1 || vr.MaxAge != time.Hour {
+		t.Fatal("wrong retention of a file in a subdirectory:", vr)
+	}
+	if vr := rt.renter.VersionRetention("b"); vr.MaxVersions != 0 {
+		t.Fatal("wrong retention of a file outside of the directory:", vr)
+	}
+	for _, data := range []string{"1", "22", "333"} {
+		if err := upload("dir/a", data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := upload("b", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := upload("b", "22"); err != ErrPathOverload {
+		t.Fatal("expected ErrPathOverload, got", err)
+	}
+	if versions := rt.renter.ListVersions("dir/a"); len(versions) != 2 || versions[0].Version != 2 {
+		t.Fatal("expected versions 2 and 3, got", versions)
+	}
+	fi, err := rt.renter.File("dir/a", false)
+	if err != nil {
+		t.Fatal(err)
+	} else if fi.Versions != 1 {
+		t.Fatal("expected 1 previous version, got", fi.Versions)
+	}
+
+	// Deleting the file keeps it as a previous version, which is removed once
+	// the retention no longer keeps it.
+	if err := rt.renter.DeleteFile("dir/a"); err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.renter.FileList()) != 1 {
+		t.Fatal("deleted file is still listed")
+	}
+	if versions := rt.renter.ListVersions("dir/a"); len(versions) != 1 || versions[0].Version != 3 || versions[0].Current {
+		t.Fatal("expected deleted file to be kept as version 3, got", versions)
+	}
+	if _, err := os.Stat(rt.renter.versionPath("dir/a", 2)); !os.IsNotExist(err) {
+		t.Error("version over the retention was not removed:", err)
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.versions["dir/a"].Previous[0].ReplacedTime = time.Now().Add(-2 * time.Hour)
+	rt.renter.mu.Unlock(id)
+	rt.renter.managedPruneVersions()
+	if versions := rt.renter.ListVersions("dir/a"); versions != nil {
+		t.Fatal("expected the old version to be pruned, got", versions)
+	}
+
+	// Purging a file removes the file and all of its versions, and the
+	// retention is persisted.
+	if err := rt.renter.SetVersionRetention("", modules.VersionRetention{MaxVersions: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := upload("b", "22"); err != nil {
+		t.Fatal(err)
+	}
+	if fl := rt.renter.FileList(); len(fl) != 1 || fl[0].Versions != 1 {
+		t.Fatal("expected a file with 1 previous version, got", fl)
+	}
+	if err := rt.renter.PurgeFile("b"); err != nil {
+		t.Fatal(err)
+	}
+	if versions := rt.renter.ListVersions("b"); versions != nil {
+		t.Fatal("expected no versions of a purged file, got", versions)
+	}
+	if err := rt.renter.PurgeFile("b"); err != ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+
+	// Without an age limit, deleting a file does not keep it as a previous
+	// version.
+	if err := upload("b", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := upload("b", "22"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.DeleteFile("b"); err != nil {
+		t.Fatal(err)
+	}
+	if versions := rt.renter.ListVersions("b"); versions != nil {
+		t.Fatal("expected no versions of a file deleted without an age limit, got", versions)
+	}
+
+	// A MaxVersions of 0 with a MaxAge keeps any number of previous versions
+	// until they are too old.
+	if err := rt.renter.SetVersionRetention("aged", modules.VersionRetention{MaxAge: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range []string{"1", "22", "333", "4444"} {
+		if err := upload("aged/c", data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if versions := rt.renter.ListVersions("aged/c"); len(versions) != 4 {
+		t.Fatal("expected 3 previous versions and the current file, got", versions)
+	}
+	id = rt.renter.mu.Lock()
+	rt.renter.versions["aged/c"].Previous[0].ReplacedTime = time.Now().Add(-2 * time.Hour)
+	rt.renter.mu.Unlock(id)
+	rt.renter.managedPruneVersions()
+	if versions := rt.renter.ListVersions("aged/c"); len(versions) != 3 || versions[0].Version != 2 {
+		t.Fatal("expected the oldest version to be pruned, got", versions)
+	}
+	if err := rt.renter.PurgeFile("aged/c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, rt.renter.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vr := rt.renter.VersionRetention("dir/a"); vr.MaxVersions != 1 || vr.MaxAge != time.Hour {
+		t.Fatal("retention was not persisted:", vr)
+	}
+	if err := rt.renter.ClearVersionRetention("dir"); err != nil {
+		t.Fatal(err)
+	}
+	if vr := rt.renter.VersionRetention("dir/a"); vr.MaxVersions != 3 {
+		t.Fatal("expected the retention of the root directory, got", vr)
+	}
+}
@@ -17,9 +17,12 @@ import (
 // interacted with exclusively by the primary worker thread, and only one of
 // those ever exists at a time.
 type worker struct {
-	// The contract and host used by this worker.
+	// The contract and host used by this worker, along with the portfolio
+	// that owns the contract and the portfolio's contractor.
 	contract   modules.RenterContract
+	contractor hostContractor
 	hostPubKey types.SiaPublicKey
+	portfolio  string
 	renter     *Renter
 
 	// Channels that inform the worker of kill signals and of new work.
@@ -43,6 +46,12 @@ type worker struct {
 	standbyChunks     []*unfinishedChunk
 	terminated        bool
 	unprocessedChunks []*unfinishedChunk
+
+	// Piece uploads that failed on this host and are waiting to be retried.
+	// The pieces remain registered to this worker until they are retried or
+	// dropped, so no other worker will pick them up in the meantime. Retries
+	// that wait for a backoff do not hold the data of their pieces.
+	pendingRetries []pendingUploadRetry
 }
 
 // threadedWorkLoop repeatedly issues work to a worker, stopping when the worker
@@ -75,9 +84,9 @@ func (w *worker) threadedWorkLoop() {
 		}
 
 		// Perform one step of processing upload work.
-		chunk, pieceIndex := w.managedNextChunk()
+		chunk, pieceIndex, failures := w.managedNextChunk()
 		if chunk != nil {
-			w.managedUpload(chunk, pieceIndex)
+			w.managedUpload(chunk, pieceIndex, failures)
 			continue
 		}
 
@@ -85,6 +94,7 @@ func (w *worker) threadedWorkLoop() {
 		var sleepDuration time.Duration
 		w.mu.Lock()
 		numStandby := len(w.standbyChunks)
+		nextRetry, retryPending := w.nextRetryTime()
 		w.mu.Unlock()
 		if numStandby > 0 {
 			// TODO: Pick a random time instead of just a constant time.
@@ -92,6 +102,9 @@ func (w *worker) threadedWorkLoop() {
 		} else {
 			sleepDuration = time.Hour // TODO: Constant
 		}
+		if untilRetry := nextRetry.Sub(time.Now()); retryPending && untilRetry < sleepDuration {
+			sleepDuration = untilRetry
+		}
 
 		// Block until new work is received via the upload or download channels,
 		// or until the standby chunks are ready to be revisited, or until a
@@ -115,23 +128,36 @@ func (w *worker) threadedWorkLoop() {
 	}
 }
 
-// updateWorkerPool will grab the set of contracts from the contractor and
-// update the worker pool to match.
+// updateWorkerPool will grab the set of contracts from the contractor of each
+// portfolio and update the worker pool to match.
 func (r *Renter) managedUpdateWorkerPool() {
-	contractSlice := r.hostContractor.Contracts()
-	contractMap := make(map[types.FileContractID]modules.RenterContract)
-	for i := 0; i < len(contractSlice); i++ {
-		contractMap[contractSlice[i].ID] = contractSlice[i]
+	type portfolioContract struct {
+		contract   modules.RenterContract
+		contractor hostContractor
+		portfolio  string
+	}
+	contractMap := make(map[types.FileContractID]portfolioContract)
+	for portfolio, hc := range r.managedPortfolioContractors() {
+		contractSlice := hc.Contracts()
+		for i := 0; i < len(contractSlice); i++ {
+			contractMap[contractSlice[i].ID] = portfolioContract{
+				contract:   contractSlice[i],
+				contractor: hc,
+				portfolio:  portfolio,
+			}
+		}
 	}
 
 	// Add a worker for any contract that does not already have a worker.
-	for id, contract := range contractMap {
+	for id, pc := range contractMap {
 		lockID := r.mu.Lock()
 		_, exists := r.workerPool[id]
 		if !exists {
 			worker := &worker{
-				contract:   contract,
-				hostPubKey: contract.HostPublicKey,
+				contract:   pc.contract,
+				contractor: pc.contractor,
+				hostPubKey: pc.contract.HostPublicKey,
+				portfolio:  pc.portfolio,
 
 				downloadChan:         make(chan downloadWork, 1),
 				killChan:             make(chan struct{}),
@@ -156,4 +182,12 @@ func (r *Renter) managedUpdateWorkerPool() {
 		}
 	}
 	r.mu.Unlock(lockID)
+
+	// The transfer counters of the contracts that are gone are no longer
+	// reported.
+	active := make(map[types.FileContractID]struct{}, len(contractMap))
+	for id := range contractMap {
+		active[id] = struct{}{}
+	}
+	r.usage.managedPruneContracts(active)
 }
@@ -33,7 +33,7 @@ type (
 
 // download will perform some download work.
 func (w *worker) download(dw downloadWork) {
-	d, err := w.renter.hostContractor.Downloader(w.contract.ID, w.renter.tg.StopChan())
+	d, err := w.contractor.Downloader(w.contract.ID, w.renter.tg.StopChan())
 	if err != nil {
 		go func() {
 			select {
@@ -45,7 +45,12 @@ func (w *worker) download(dw downloadWork) {
 	}
 	defer d.Close()
 
-	data, err := d.Sector(dw.dataRoot)
+	var data []byte
+	if dw.chunkDownload.download.repair {
+		data, err = d.RepairSector(dw.dataRoot)
+	} else {
+		data, err = d.Sector(dw.dataRoot)
+	}
 	go func() {
 		select {
 		case dw.resultChan <- finishedDownload{dw.chunkDownload, data, err, dw.pieceIndex, w.contract.ID}:
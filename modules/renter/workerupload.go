@@ -1,15 +1,64 @@
 package renter
 
 import (
+	"errors"
+	"io"
+	"os"
 	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/NebulousLabs/fastrand"
 )
 
+// errRetryMemory is returned when the data of a piece cannot be read again for
+// a retry because the renter is out of memory.
+var errRetryMemory = errors.New("not enough memory to read the piece for the retry")
+
+// pendingUploadRetry is a piece upload that failed and is waiting to be retried
+// on the same host. If released is set, the data of the piece was released
+// while waiting, and is read from disk again when the retry is due.
+type pendingUploadRetry struct {
+	chunk      *unfinishedChunk
+	pieceIndex uint64
+	failures   int
+	released   bool
+	retryTime  time.Time
+}
+
+// uploadRetryDelay returns how long a worker should wait before retrying a
+// piece upload that has failed 'failures' times. The first retry is immediate,
+// later retries are spaced out by uploadRetryBackoffSteps and randomized by up
+// to 25% so that workers do not retry in lockstep.
+func uploadRetryDelay(policy modules.UploadRetryPolicy, failures int) time.Duration {
+	if failures <= 1 {
+		return 0
+	}
+	step := failures - 2
+	delay := policy.MaxBackoff
+	if step < len(uploadRetryBackoffSteps) {
+		delay = policy.InitialBackoff * uploadRetryBackoffSteps[step]
+	}
+	if delay > policy.MaxBackoff {
+		delay = policy.MaxBackoff
+	}
+	if jitter := uint64(delay / 4); jitter > 0 {
+		delay += time.Duration(fastrand.Uint64n(2*jitter)) - delay/4
+	}
+	return delay
+}
+
 // dropChunk will remove a worker from the responsibility of tracking a chunk.
 func (w *worker) dropChunk(uc *unfinishedChunk) {
 	uc.mu.Lock()
 	uc.workersRemaining--
+	repairDone := uc.workersRemaining == 0
 	uc.mu.Unlock()
 	w.renter.managedReleaseIdleChunkPieces(uc)
+	if repairDone {
+		w.renter.managedSavePieceHistory(uc.renterFile)
+		uc.managedChunkRepairDone()
+	}
 	w.renter.heapWG.Done()
 }
 
@@ -24,6 +73,25 @@ func (w *worker) dropUploadChunks() {
 		w.dropChunk(w.standbyChunks[i])
 	}
 	w.standbyChunks = w.standbyChunks[:0]
+	for i := 0; i < len(w.pendingRetries); i++ {
+		w.abandonRetry(w.pendingRetries[i])
+	}
+	w.pendingRetries = w.pendingRetries[:0]
+}
+
+// abandonRetry gives up a pending retry. The piece of a retry that still holds
+// its data is released to the other workers. The data of a released piece is
+// gone, so the piece stays marked as used, and is uploaded by a later repair
+// of the chunk instead.
+func (w *worker) abandonRetry(retry pendingUploadRetry) {
+	if retry.released {
+		retry.chunk.mu.Lock()
+		retry.chunk.piecesRegistered--
+		retry.chunk.mu.Unlock()
+	} else {
+		w.unregisterPiece(retry.chunk, retry.pieceIndex)
+	}
+	w.dropChunk(retry.chunk)
 }
 
 // managedKillUploading will disable all uploading for the worker.
@@ -35,11 +103,36 @@ func (w *worker) managedKillUploading() {
 }
 
 // managedNextChunk will pull the next potential chunk out of the worker's work queue
-// for uploading.
-func (w *worker) managedNextChunk() (nextChunk *unfinishedChunk, pieceIndex uint64) {
+// for uploading. Retries that are due take priority over new work. The number
+// of times that the returned piece has already failed to upload is also
+// returned.
+func (w *worker) managedNextChunk() (nextChunk *unfinishedChunk, pieceIndex uint64, failures int) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	// Check for a retry that is due.
+	now := time.Now()
+	for i := 0; i < len(w.pendingRetries); i++ {
+		retry := w.pendingRetries[i]
+		if now.Before(retry.retryTime) {
+			continue
+		}
+		w.pendingRetries = append(w.pendingRetries[:i], w.pendingRetries[i+1:]...)
+		i--
+
+		// The retry is no longer needed if the chunk has been completed by
+		// other workers in the meantime, and it is not allowed while the
+		// worker is on cooldown.
+		retry.chunk.mu.Lock()
+		chunkComplete := retry.chunk.piecesNeeded <= retry.chunk.piecesCompleted
+		retry.chunk.mu.Unlock()
+		if chunkComplete || !w.contract.GoodForUpload || w.onUploadCooldown() {
+			w.abandonRetry(retry)
+			continue
+		}
+		return retry.chunk, retry.pieceIndex, retry.failures
+	}
+
 	// Loop through the unprocessed chunks and find some work to do.
 	for range w.unprocessedChunks {
 		// Pull a chunk off of the unprocessed chunks stack.
@@ -47,7 +140,7 @@ func (w *worker) managedNextChunk() (nextChunk *unfinishedChunk, pieceIndex uint
 		w.unprocessedChunks = w.unprocessedChunks[1:]
 		nextChunk, pieceIndex := w.processChunk(chunk)
 		if nextChunk != nil {
-			return nextChunk, pieceIndex
+			return nextChunk, pieceIndex, 0
 		}
 	}
 
@@ -57,12 +150,27 @@ func (w *worker) managedNextChunk() (nextChunk *unfinishedChunk, pieceIndex uint
 		w.standbyChunks = w.standbyChunks[1:]
 		nextChunk, pieceIndex := w.processChunk(chunk)
 		if nextChunk != nil {
-			return nextChunk, pieceIndex
+			return nextChunk, pieceIndex, 0
 		}
 	}
 
 	// No work found, try again later.
-	return nil, 0
+	return nil, 0, 0
+}
+
+// nextRetryTime returns the time at which the earliest pending retry is due,
+// and false if there are no pending retries.
+func (w *worker) nextRetryTime() (time.Time, bool) {
+	if len(w.pendingRetries) == 0 {
+		return time.Time{}, false
+	}
+	next := w.pendingRetries[0].retryTime
+	for _, retry := range w.pendingRetries[1:] {
+		if retry.retryTime.Before(next) {
+			next = retry.retryTime
+		}
+	}
+	return next, true
 }
 
 // processChunk will process a chunk from the worker chunk queue.
@@ -109,15 +217,14 @@ func (w *worker) processChunk(uc *unfinishedChunk) (nextChunk *unfinishedChunk,
 // managedQueueChunkRepair will take a chunk and add it to the worker's repair stack.
 func (w *worker) managedQueueChunkRepair(uc *unfinishedChunk) {
 	// Check that the worker is allowed to be uploading.
-	contract, exists := w.renter.hostContractor.ContractByID(w.contract.ID)
+	contract, exists := w.contractor.ContractByID(w.contract.ID)
 	w.mu.Lock()
-	// Figure out how long the worker would need to be on cooldown.
-	requiredCooldown := uploadFailureCooldown
-	for i := 0; i < w.uploadConsecutiveFailures && i < maxConsecutivePenalty; i++ {
-		requiredCooldown *= 2
+	onCooldown := w.onUploadCooldown()
+	allow, probe := w.renter.hostBreakers.managedCheck(w.hostPubKey)
+	if probe {
+		go w.threadedProbeHost()
 	}
-	onCooldown := time.Now().Before(w.uploadRecentFailure.Add(requiredCooldown))
-	if !exists || !contract.GoodForUpload || w.terminated || onCooldown {
+	if !exists || !contract.GoodForUpload || w.terminated || onCooldown || !allow {
 		// The worker should not be uploading, remove the chunk.
 		w.dropChunk(uc)
 		w.mu.Unlock()
@@ -133,26 +240,160 @@ func (w *worker) managedQueueChunkRepair(uc *unfinishedChunk) {
 	}
 }
 
-// uploadFailed is called if a worker failed to upload part of an unfinished
-// chunk.
-func (w *worker) uploadFailed(uc *unfinishedChunk, pieceIndex uint64) {
-	w.uploadRecentFailure = time.Now()
-	w.uploadConsecutiveFailures++
+// onUploadCooldown returns whether the worker is on cooldown after a failed
+// upload. The cooldown doubles with every consecutive failure. The caller must
+// hold the worker's lock.
+func (w *worker) onUploadCooldown() bool {
+	requiredCooldown := uploadFailureCooldown
+	for i := 0; i < w.uploadConsecutiveFailures && i < maxConsecutivePenalty; i++ {
+		requiredCooldown *= 2
+	}
+	return time.Now().Before(w.uploadRecentFailure.Add(requiredCooldown))
+}
+
+// unregisterPiece releases a piece that the worker had registered to upload,
+// allowing other workers to upload it instead.
+func (w *worker) unregisterPiece(uc *unfinishedChunk, pieceIndex uint64) {
 	uc.mu.Lock()
 	uc.piecesRegistered--
 	uc.pieceUsage[pieceIndex] = false
 	uc.mu.Unlock()
+}
+
+// uploadFailed is called if a worker failed to upload part of an unfinished
+// chunk and will not retry the upload.
+func (w *worker) uploadFailed(uc *unfinishedChunk, pieceIndex uint64) {
+	w.uploadRecentFailure = time.Now()
+	w.uploadConsecutiveFailures++
+	w.unregisterPiece(uc, pieceIndex)
 	w.dropChunk(uc)
 	w.dropUploadChunks()
 }
 
-// managedUpload will perform some upload work.
-func (w *worker) managedUpload(uc *unfinishedChunk, pieceIndex uint64) {
+// managedUploadFailed is called if a worker failed to upload a piece. The
+// piece is scheduled for a retry on the same host according to the renter's
+// UploadRetryPolicy. Once the policy's attempts are exhausted, or once the
+// circuit breaker of the host has opened, the piece is released so that a
+// different host can upload it. The piece is released without a retry as well
+// if the worker went on cooldown while the piece was uploading.
+//
+// Only the immediate first retry keeps the data of the piece. A retry that
+// waits for a backoff releases the memory of the piece, and reads the piece
+// from disk again once it is due. The pieces of chunks that are not available
+// on disk keep their data.
+func (w *worker) managedUploadFailed(uc *unfinishedChunk, pieceIndex uint64, failures int) {
+	policy := w.renter.managedUploadRetryPolicy()
+	breakerOpen := w.renter.hostBreakers.managedRecordFailure(w.hostPubKey)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if failures >= policy.MaxAttempts || w.terminated || breakerOpen {
+		w.uploadFailed(uc, pieceIndex)
+		return
+	}
+	if w.onUploadCooldown() {
+		w.unregisterPiece(uc, pieceIndex)
+		w.dropChunk(uc)
+		return
+	}
+	delay := uploadRetryDelay(policy, failures)
+	retry := pendingUploadRetry{
+		chunk:      uc,
+		pieceIndex: pieceIndex,
+		failures:   failures,
+		retryTime:  time.Now().Add(delay),
+	}
+	if delay > 0 && uc.localPath != "" {
+		w.renter.managedReleasePieceData(uc, pieceIndex)
+		retry.released = true
+	}
+	w.pendingRetries = append(w.pendingRetries, retry)
+}
+
+// managedReleasePieceData releases the data of a piece of the chunk and
+// returns its memory to the renter.
+func (r *Renter) managedReleasePieceData(uc *unfinishedChunk, pieceIndex uint64) {
+	uc.mu.Lock()
+	size := uint64(len(uc.physicalChunkData[pieceIndex]))
+	uc.physicalChunkData[pieceIndex] = nil
+	uc.memoryReleased += size
+	uc.mu.Unlock()
+	r.managedMemoryAvailableAdd(size)
+}
+
+// managedLoadPieceData reads the data of a piece whose data was released while
+// it waited for a retry from disk again. The chunk is read and encoded like
+// the repair loop does, which needs the full memory of the chunk for a moment.
+// errRetryMemory is returned if that memory is not available.
+func (r *Renter) managedLoadPieceData(uc *unfinishedChunk, pieceIndex uint64) error {
+	uc.mu.Lock()
+	loaded := uc.physicalChunkData[pieceIndex] != nil
+	uc.mu.Unlock()
+	if loaded {
+		return nil
+	}
+	if !r.managedMemoryAvailableTrySub(uc.memoryNeeded) {
+		return errRetryMemory
+	}
+
+	var piece []byte
+	defer func() {
+		r.managedMemoryAvailableAdd(uc.memoryNeeded - uint64(len(piece)))
+	}()
+	osFile, err := os.Open(uc.localPath)
+	if err != nil {
+		return err
+	}
+	defer osFile.Close()
+	logicalChunkData := make([]byte, uc.length)
+	_, err = osFile.ReadAt(logicalChunkData, uc.offset)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	pieces, err := uc.renterFile.erasureCode.Encode(logicalChunkData)
+	if err != nil {
+		return err
+	}
+	key := deriveKey(uc.renterFile.masterKey, uc.index, pieceIndex)
+	piece = key.EncryptBytes(pieces[pieceIndex])
+
+	uc.mu.Lock()
+	uc.physicalChunkData[pieceIndex] = piece
+	uc.memoryReleased -= uint64(len(piece))
+	uc.mu.Unlock()
+	return nil
+}
+
+// managedUpload will perform some upload work. 'failures' is the number of
+// previous attempts to upload the piece to this host that have failed.
+func (w *worker) managedUpload(uc *unfinishedChunk, pieceIndex uint64, failures int) {
+	// Read the data of a piece that was released while it waited for the
+	// retry. If there is not enough memory, the retry is postponed without
+	// counting it as a failure.
+	err := w.renter.managedLoadPieceData(uc, pieceIndex)
+	if err != nil {
+		retry := pendingUploadRetry{
+			chunk:      uc,
+			pieceIndex: pieceIndex,
+			failures:   failures,
+			released:   true,
+			retryTime:  time.Now().Add(uploadRetryMemoryWait),
+		}
+		w.mu.Lock()
+		if err == errRetryMemory && !w.terminated {
+			w.pendingRetries = append(w.pendingRetries, retry)
+		} else {
+			w.renter.log.Debugln("Worker failed to read a piece for a retry:", err)
+			w.abandonRetry(retry)
+		}
+		w.mu.Unlock()
+		return
+	}
+
 	// Open an editing connection to the host.
-	e, err := w.renter.hostContractor.Editor(w.contract.ID, w.renter.tg.StopChan())
+	e, err := w.contractor.Editor(w.contract.ID, w.renter.tg.StopChan())
 	if err != nil {
 		w.renter.log.Debugln("Worker failed to acquire an editor:", err)
-		w.uploadFailed(uc, pieceIndex)
+		w.managedUploadFailed(uc, pieceIndex, failures+1)
 		return
 	}
 	defer e.Close()
@@ -162,14 +403,13 @@ func (w *worker) managedUpload(uc *unfinishedChunk, pieceIndex uint64) {
 	root, err := e.Upload(uc.physicalChunkData[pieceIndex])
 	if err != nil {
 		w.renter.log.Debugln("Worker failed to upload via the editor:", err)
-		w.mu.Lock()
-		w.uploadFailed(uc, pieceIndex)
-		w.mu.Unlock()
+		w.managedUploadFailed(uc, pieceIndex, failures+1)
 		return
 	}
 	w.mu.Lock()
 	w.uploadConsecutiveFailures = 0
 	w.mu.Unlock()
+	w.renter.hostBreakers.managedRecordSuccess(w.hostPubKey)
 
 	// Update the renter metadata.
 	addr := e.Address()
@@ -190,7 +430,9 @@ func (w *worker) managedUpload(uc *unfinishedChunk, pieceIndex uint64) {
 		MerkleRoot: root,
 	})
 	uc.renterFile.contracts[w.contract.ID] = contract
-	w.renter.saveFile(uc.renterFile)
+	w.renter.recordPieceUpload(uc, pieceIndex, w.hostPubKey, w.contract.ID)
+	w.renter.saveSiaFile(uc.renterFile)
+	siaPath := uc.renterFile.name
 	uc.renterFile.mu.Unlock()
 	w.renter.mu.Unlock(id)
 
@@ -203,6 +445,8 @@ func (w *worker) managedUpload(uc *unfinishedChunk, pieceIndex uint64) {
 	uc.physicalChunkData[pieceIndex] = nil
 	uc.memoryReleased += uint64(releaseSize)
 	uc.mu.Unlock()
+	w.renter.uploadThroughput.managedRecordUpload(uint64(releaseSize))
+	w.renter.managedRecordTransfer(siaPath, w.contract.ID, uint64(releaseSize), 0)
 	w.renter.managedMemoryAvailableAdd(uint64(releaseSize))
 	w.dropChunk(uc)
 }
@@ -0,0 +1,63 @@
+package modules
+
+import (
+	"time"
+)
+
+const (
+	// SchedulerDir is the name of the directory that is used to store the
+	// scheduler's persistent data.
+	SchedulerDir = "scheduler"
+
+	// TaskResultSuccess is the result of a task run that did not return an
+	// error.
+	TaskResultSuccess = "success"
+)
+
+// ScheduledTask describes a recurring task of the scheduler.
+type ScheduledTask struct {
+	Name string `json:"name"`
+
+	// Interval is the time between runs of the task. DefaultInterval is the
+	// interval that the task was registered with.
+	Interval        time.Duration `json:"interval"`
+	DefaultInterval time.Duration `json:"defaultinterval"`
+
+	// LastRun is the time at which the last run of the task started, and
+	// LastResult is either TaskResultSuccess or the error of the last run.
+	// Both are zero if the task has never run.
+	LastRun    time.Time `json:"lastrun"`
+	LastResult string    `json:"lastresult"`
+
+	// NextRun is the time at which the task runs next, unless it is paused.
+	NextRun time.Time `json:"nextrun"`
+	Paused  bool      `json:"paused"`
+	Running bool      `json:"running"`
+}
+
+// A Scheduler runs the recurring maintenance tasks of the daemon. The
+// schedules and the results of the last runs persist across restarts.
+type Scheduler interface {
+	// RegisterTask adds a task that calls fn every interval. The interval
+	// may be replaced by one that was set with RescheduleTask before.
+	RegisterTask(name string, interval time.Duration, fn func() error) error
+
+	// RunTask starts a run of the task immediately, even if it is paused.
+	RunTask(name string) error
+
+	// PauseTask stops the task from running until it is resumed.
+	PauseTask(name string) error
+
+	// ResumeTask resumes a paused task.
+	ResumeTask(name string) error
+
+	// RescheduleTask changes the interval of the task. An interval of 0
+	// restores the task's default interval.
+	RescheduleTask(name string, interval time.Duration) error
+
+	// Tasks returns the registered tasks, sorted by name.
+	Tasks() []ScheduledTask
+
+	// Close stops the scheduler, waiting for running tasks to finish.
+	Close() error
+}
@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+)
+
+const (
+	logFile      = modules.SchedulerDir + ".log"
+	settingsFile = modules.SchedulerDir + ".json"
+)
+
+var (
+	// overdueTaskDelay is the time between the runs of tasks that were
+	// already due when they were registered.
+	overdueTaskDelay = build.Select(build.Var{
+		Dev:      10 * time.Second,
+		Standard: time.Minute,
+		Testing:  100 * time.Millisecond,
+	}).(time.Duration)
+
+	settingsMetadata = persist.Metadata{
+		Header:  "Scheduler Settings",
+		Version: "1.0",
+	}
+)
+
+type (
+	// persistence contains the persistent state of the scheduler. The state
+	// of tasks that have not been registered since the daemon started is
+	// kept, so that modules which are not loaded keep their schedule.
+	persistence struct {
+		Tasks map[string]persistedTask
+	}
+
+	// persistedTask is the persistent state of a task. An Interval of 0
+	// means that the task uses its default interval.
+	persistedTask struct {
+		Interval   time.Duration
+		LastRun    time.Time
+		LastResult string
+		Paused     bool
+	}
+)
+
+// initPersist initializes the persistence of the scheduler.
+func (s *Scheduler) initPersist() error {
+	// Create the scheduler directory.
+	err := os.MkdirAll(s.persistDir, 0700)
+	if err != nil {
+		return err
+	}
+
+	// Add a logger.
+	s.log, err = persist.NewFileLogger(filepath.Join(s.persistDir, logFile))
+	if err != nil {
+		return err
+	}
+	s.tg.AfterStop(func() {
+		s.mu.Lock()
+		err := s.saveSync()
+		s.mu.Unlock()
+		if err != nil {
+			s.log.Println("ERROR: unable to save the scheduler:", err)
+		}
+		if err := s.log.Close(); err != nil {
+			fmt.Println("Error when closing the logger:", err)
+		}
+	})
+
+	// Load the settings, creating them if they do not exist yet.
+	s.persist.Tasks = make(map[string]persistedTask)
+	filename := filepath.Join(s.persistDir, settingsFile)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return s.saveSync()
+	} else if err != nil {
+		return err
+	}
+	err = persist.LoadJSON(settingsMetadata, &s.persist, filename)
+	if err != nil {
+		return err
+	}
+	if s.persist.Tasks == nil {
+		s.persist.Tasks = make(map[string]persistedTask)
+	}
+	return nil
+}
+
+// saveSync saves the state of the tasks to disk, and then syncs to disk. The
+// caller must hold the lock.
+func (s *Scheduler) saveSync() error {
+	for name, t := range s.tasks {
+		pt := persistedTask{
+			LastRun:    t.lastRun,
+			LastResult: t.lastResult,
+			Paused:     t.paused,
+		}
+		if t.interval != t.defaultInterval {
+			pt.Interval = t.interval
+		}
+		s.persist.Tasks[name] = pt
+	}
+	return persist.SaveJSON(settingsMetadata, s.persist, filepath.Join(s.persistDir, settingsFile))
+}
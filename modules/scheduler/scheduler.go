@@ -0,0 +1,319 @@
+// Package scheduler runs the recurring maintenance tasks of the daemon, such
+// as metadata backups. Modules register their tasks with a default interval,
+// and the user can list, trigger, pause, and reschedule the tasks through the
+// API. The schedules and the results of the last runs are persisted, so that
+// tasks keep their schedule across restarts.
+package scheduler
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	siasync "github.com/NebulousLabs/Sia/sync"
+)
+
+var (
+	errInvalidInterval = errors.New("task interval must be positive")
+	errNilTaskFunc     = errors.New("task function cannot be nil")
+	errTaskExists      = errors.New("a task with that name is already registered")
+	errTaskRunning     = errors.New("task is already running")
+	errUnknownTask     = errors.New("no task registered with that name")
+)
+
+// A task is a recurring task of the scheduler.
+type task struct {
+	name            string
+	fn              func() error
+	interval        time.Duration
+	defaultInterval time.Duration
+
+	lastRun    time.Time
+	lastResult string
+	nextRun    time.Time
+	paused     bool
+	running    bool
+}
+
+// A Scheduler runs registered tasks at their interval, one run per task at a
+// time.
+type Scheduler struct {
+	tasks map[string]*task
+
+	// overdueTasks is the number of registered tasks whose next run was
+	// already due when they were registered. Their runs are spread out by
+	// overdueTaskDelay, so that a restart does not run them all at once.
+	overdueTasks int
+
+	// wake is signaled when the schedule of a task changes.
+	wake chan struct{}
+
+	log        *persist.Logger
+	mu         sync.Mutex
+	persist    persistence
+	persistDir string
+	tg         siasync.ThreadGroup
+}
+
+// New returns a scheduler that persists its state in persistDir.
+func New(persistDir string) (*Scheduler, error) {
+	s := &Scheduler{
+		tasks:      make(map[string]*task),
+		wake:       make(chan struct{}, 1),
+		persistDir: persistDir,
+	}
+	if err := s.initPersist(); err != nil {
+		return nil, errors.New("scheduler persistence startup failed: " + err.Error())
+	}
+	go s.threadedSchedule()
+	return s, nil
+}
+
+// info returns the description of t. The caller must hold the lock.
+func (t *task) info() modules.ScheduledTask {
+	return modules.ScheduledTask{
+		Name:            t.name,
+		Interval:        t.interval,
+		DefaultInterval: t.defaultInterval,
+		LastRun:         t.lastRun,
+		LastResult:      t.lastResult,
+		NextRun:         t.nextRun,
+		Paused:          t.paused,
+		Running:         t.running,
+	}
+}
+
+// rescheduleNextRun sets the next run of t to one interval after its last
+// run, or one interval from now if it has never run.
+func (t *task) rescheduleNextRun() {
+	if t.lastRun.IsZero() {
+		t.nextRun = time.Now().Add(t.interval)
+		return
+	}
+	t.nextRun = t.lastRun.Add(t.interval)
+}
+
+// notify wakes up the scheduling thread.
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// startRun starts a run of t in a new thread. The caller must hold the lock.
+func (s *Scheduler) startRun(t *task) {
+	t.running = true
+	t.lastRun = time.Now()
+	go s.threadedRunTask(t)
+}
+
+// threadedRunTask runs t and records the result of the run.
+func (s *Scheduler) threadedRunTask(t *task) {
+	if err := s.tg.Add(); err != nil {
+		s.mu.Lock()
+		t.running = false
+		s.mu.Unlock()
+		return
+	}
+	defer s.tg.Done()
+
+	err := t.fn()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t.running = false
+	t.lastResult = modules.TaskResultSuccess
+	if err != nil {
+		t.lastResult = err.Error()
+		s.log.Printf("WARN: task %q failed: %v\n", t.name, err)
+	} else {
+		s.log.Debugf("Task %q finished in %v\n", t.name, time.Since(t.lastRun))
+	}
+	t.rescheduleNextRun()
+	if err := s.saveSync(); err != nil {
+		s.log.Println("ERROR: unable to save the scheduler:", err)
+	}
+	s.notify()
+}
+
+// threadedSchedule starts the runs of the tasks that are due, and then sleeps
+// until the next run is due or the schedule of a task changes.
+func (s *Scheduler) threadedSchedule() {
+	if err := s.tg.Add(); err != nil {
+		return
+	}
+	defer s.tg.Done()
+
+	for {
+		var next time.Time
+		s.mu.Lock()
+		now := time.Now()
+		for _, t := range s.tasks {
+			if t.paused || t.running {
+				continue
+			}
+			if !t.nextRun.After(now) {
+				s.startRun(t)
+				continue
+			}
+			if next.IsZero() || t.nextRun.Before(next) {
+				next = t.nextRun
+			}
+		}
+		s.mu.Unlock()
+
+		var wait <-chan time.Time
+		if !next.IsZero() {
+			wait = time.After(time.Until(next))
+		}
+		select {
+		case <-s.tg.StopChan():
+			return
+		case <-s.wake:
+		case <-wait:
+		}
+	}
+}
+
+// RegisterTask adds a task that calls fn every interval. The task keeps the
+// schedule and the last result that were persisted for a task of the same
+// name. A task that has never run first runs one interval after it is
+// registered.
+func (s *Scheduler) RegisterTask(name string, interval time.Duration, fn func() error) error {
+	if interval <= 0 {
+		return errInvalidInterval
+	} else if fn == nil {
+		return errNilTaskFunc
+	}
+	if err := s.tg.Add(); err != nil {
+		return err
+	}
+	defer s.tg.Done()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.tasks[name]; exists {
+		return errTaskExists
+	}
+	pt := s.persist.Tasks[name]
+	t := &task{
+		name:            name,
+		fn:              fn,
+		interval:        interval,
+		defaultInterval: interval,
+		lastRun:         pt.LastRun,
+		lastResult:      pt.LastResult,
+		paused:          pt.Paused,
+	}
+	if pt.Interval > 0 {
+		t.interval = pt.Interval
+	}
+	t.rescheduleNextRun()
+	if now := time.Now(); t.nextRun.Before(now) {
+		s.overdueTasks++
+		t.nextRun = now.Add(time.Duration(s.overdueTasks) * overdueTaskDelay)
+	}
+	s.tasks[name] = t
+	s.notify()
+	return nil
+}
+
+// RunTask starts a run of the task immediately, even if it is paused. It does
+// not wait for the run to finish.
+func (s *Scheduler) RunTask(name string) error {
+	if err := s.tg.Add(); err != nil {
+		return err
+	}
+	defer s.tg.Done()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, exists := s.tasks[name]
+	if !exists {
+		return errUnknownTask
+	} else if t.running {
+		return errTaskRunning
+	}
+	s.startRun(t)
+	return nil
+}
+
+// setPaused pauses or resumes the task.
+func (s *Scheduler) setPaused(name string, paused bool) error {
+	if err := s.tg.Add(); err != nil {
+		return err
+	}
+	defer s.tg.Done()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, exists := s.tasks[name]
+	if !exists {
+		return errUnknownTask
+	}
+	t.paused = paused
+	s.notify()
+	return s.saveSync()
+}
+
+// PauseTask stops the task from running until it is resumed. A run that is
+// in progress is not interrupted.
+func (s *Scheduler) PauseTask(name string) error {
+	return s.setPaused(name, true)
+}
+
+// ResumeTask resumes a paused task. If the next run of the task became due
+// while it was paused, the task runs immediately.
+func (s *Scheduler) ResumeTask(name string) error {
+	return s.setPaused(name, false)
+}
+
+// RescheduleTask changes the interval of the task, and schedules its next run
+// one new interval after its last run. An interval of 0 restores the default
+// interval of the task.
+func (s *Scheduler) RescheduleTask(name string, interval time.Duration) error {
+	if interval < 0 {
+		return errInvalidInterval
+	}
+	if err := s.tg.Add(); err != nil {
+		return err
+	}
+	defer s.tg.Done()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, exists := s.tasks[name]
+	if !exists {
+		return errUnknownTask
+	}
+	t.interval = t.defaultInterval
+	if interval != 0 {
+		t.interval = interval
+	}
+	t.rescheduleNextRun()
+	s.notify()
+	return s.saveSync()
+}
+
+// Tasks returns the registered tasks, sorted by name.
+func (s *Scheduler) Tasks() []modules.ScheduledTask {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tasks := make([]modules.ScheduledTask, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		tasks = append(tasks, t.info())
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].Name < tasks[j].Name
+	})
+	return tasks
+}
+
+// Close stops the scheduler, waiting for running tasks to finish.
+func (s *Scheduler) Close() error {
+	return s.tg.Stop()
+}
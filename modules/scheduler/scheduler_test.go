@@ -0,0 +1,206 @@
+package scheduler
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// taskByName returns the task with the provided name from s.Tasks.
+func taskByName(s *Scheduler, name string) (modules.ScheduledTask, bool) {
+	for _, t := range s.Tasks() {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return modules.ScheduledTask{}, false
+}
+
+// TestSchedulerRunsTasks checks that registered tasks run at their interval,
+// and that they can be triggered, paused, and rescheduled.
+func TestSchedulerRunsTasks(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	s, err := New(build.TempDir(modules.SchedulerDir, t.Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var runs int32
+	errFail := errors.New("task failed")
+	err = s.RegisterTask("count", 50*time.Millisecond, func() error {
+		if atomic.AddInt32(&runs, 1) == 1 {
+			return errFail
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RegisterTask("count", time.Second, func() error { return nil }); err != errTaskExists {
+		t.Fatal("expected errTaskExists, got", err)
+	}
+	if err := s.RegisterTask("bad", 0, func() error { return nil }); err != errInvalidInterval {
+		t.Fatal("expected errInvalidInterval, got", err)
+	}
+
+	// The task should run repeatedly, and report the result of its last run.
+	err = build.Retry(50, 20*time.Millisecond, func() error {
+		if atomic.LoadInt32(&runs) < 2 {
+			return errors.New("task has not run twice")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task, _ := taskByName(s, "count"); task.LastRun.IsZero() {
+		t.Fatal("last run was not recorded")
+	}
+
+	// Paused tasks only run when triggered.
+	if err := s.PauseTask("count"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	paused := atomic.LoadInt32(&runs)
+	time.Sleep(200 * time.Millisecond)
+	if atomic.LoadInt32(&runs) != paused {
+		t.Fatal("paused task kept running")
+	}
+	if err := s.RunTask("count"); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(50, 20*time.Millisecond, func() error {
+		if task, _ := taskByName(s, "count"); atomic.LoadInt32(&runs) != paused+1 || task.Running || task.LastResult != modules.TaskResultSuccess {
+			return errors.New("triggered task did not run")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunTask("missing"); err != errUnknownTask {
+		t.Fatal("expected errUnknownTask, got", err)
+	}
+
+	// Reschedule the task to a long interval and resume it.
+	if err := s.RescheduleTask("count", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ResumeTask("count"); err != nil {
+		t.Fatal(err)
+	}
+	task, _ := taskByName(s, "count")
+	if task.Paused || task.Interval != time.Hour || task.DefaultInterval != 50*time.Millisecond || task.NextRun.Before(time.Now().Add(59*time.Minute)) {
+		t.Fatal("task was not rescheduled:", task)
+	}
+	resumed := atomic.LoadInt32(&runs)
+	time.Sleep(200 * time.Millisecond)
+	if atomic.LoadInt32(&runs) != resumed {
+		t.Fatal("rescheduled task ran before its next run")
+	}
+}
+
+// TestSchedulerPersist checks that the schedules and results of tasks survive
+// a restart, and that overdue tasks do not all run at once after a restart.
+func TestSchedulerPersist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	dir := build.TempDir(modules.SchedulerDir, t.Name())
+	s, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errFail := errors.New("task failed")
+	names := []string{"a", "b", "c"}
+	for _, name := range names {
+		if err := s.RegisterTask(name, time.Hour, func() error { return errFail }); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RunTask(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.PauseTask("c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RescheduleTask("b", 2*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(50, 20*time.Millisecond, func() error {
+		for _, task := range s.Tasks() {
+			if task.LastResult != errFail.Error() {
+				return errors.New("task has not finished")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restart the scheduler with intervals that make a and c overdue.
+	s, err = New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var runs int32
+	for _, name := range names {
+		err := s.RegisterTask(name, time.Millisecond, func() error {
+			atomic.AddInt32(&runs, 1)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, _ := taskByName(s, "a")
+	b, _ := taskByName(s, "b")
+	c, _ := taskByName(s, "c")
+	if a.LastResult != errFail.Error() || a.LastRun.IsZero() || a.Interval != time.Millisecond {
+		t.Fatal("state of a was not restored:", a)
+	}
+	if b.Interval != 2*time.Hour || b.DefaultInterval != time.Millisecond {
+		t.Fatal("interval of b was not restored:", b)
+	}
+	if !c.Paused {
+		t.Fatal("c is no longer paused")
+	}
+	if !a.NextRun.After(time.Now()) || !c.NextRun.After(a.NextRun) {
+		t.Fatal("overdue tasks were not spread out:", a.NextRun, c.NextRun)
+	}
+
+	// Only a should run, since b is not due and c is paused.
+	err = build.Retry(50, 20*time.Millisecond, func() error {
+		if atomic.LoadInt32(&runs) == 0 {
+			return errors.New("a did not run")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PauseTask("a"); err != nil {
+		t.Fatal(err)
+	}
+	if task, _ := taskByName(s, "b"); !task.LastRun.Equal(b.LastRun) {
+		t.Fatal("b ran before it was due")
+	}
+	if task, _ := taskByName(s, "c"); !task.LastRun.Equal(c.LastRun) {
+		t.Fatal("paused task c ran")
+	}
+}
@@ -2,6 +2,7 @@ package modules
 
 import (
 	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 const (
@@ -23,6 +24,11 @@ type (
 		Index             uint16 `json:"index"`
 		Path              string `json:"path"`
 
+		// SectorsUsed is the number of physical sectors stored in the folder,
+		// and BytesUsed is the amount of disk space consumed by those sectors.
+		SectorsUsed uint64 `json:"sectorsused"`
+		BytesUsed   uint64 `json:"bytesused"`
+
 		// Below are statistics about the filesystem. FailedReads and
 		// FailedWrites are only incremented if the filesystem is returning
 		// errors when operations are being performed. A large number of
@@ -34,6 +40,16 @@ type (
 		SuccessfulReads  uint64 `json:"successfulreads"`
 		SuccessfulWrites uint64 `json:"successfulwrites"`
 
+		// LastError is the most recent error returned by the filesystem for
+		// this folder. It is empty if no errors have been recorded since the
+		// folder's health statistics were last reset.
+		LastError string `json:"lasterror"`
+
+		// ReadThroughput is the average throughput of the sector reads from
+		// this folder since the host started, in bytes per second. It is zero
+		// if no sectors have been read from the folder yet.
+		ReadThroughput uint64 `json:"readthroughput"`
+
 		// Certain operations on a storage folder can take a long time (Add,
 		// Remove, and Resize). The fields below indicate the progress of any
 		// long running operations that might be under way in the storage
@@ -42,6 +58,54 @@ type (
 		ProgressDenominator uint64
 	}
 
+	// SectorLocation is the location on disk of a sector. Offset is the byte
+	// offset of the sector within the sector file of the storage folder.
+	// ReferenceCount is the number of times that the sector has been added
+	// without being removed. Obligations is the set of storage obligations
+	// whose sector roots include the sector, and is only filled in by the
+	// host.
+	SectorLocation struct {
+		StorageFolder  uint16                 `json:"storagefolder"`
+		Path           string                 `json:"path"`
+		Offset         uint64                 `json:"offset"`
+		ReferenceCount uint16                 `json:"referencecount"`
+		Obligations    []types.FileContractID `json:"obligations,omitempty"`
+	}
+
+	// SectorError is a sector of a storage folder whose data does not match
+	// the metadata of the storage manager, found by VerifyStorageFolder.
+	// Offset is the byte offset of the sector within the storage folder, and
+	// ActualRoot is the Merkle root of the data that was read, or the empty
+	// hash if the sector could not be read. The storage manager only stores
+	// the SectorID of each sector, so ExpectedRoot is only known to callers
+	// that track the roots of their sectors, such as the host, and is the
+	// empty hash otherwise.
+	SectorError struct {
+		SectorID     SectorID    `json:"sectorid"`
+		Offset       uint64      `json:"offset"`
+		ExpectedRoot crypto.Hash `json:"expectedroot"`
+		ActualRoot   crypto.Hash `json:"actualroot"`
+	}
+
+	// SectorID is the id under which the storage manager stores a sector. The
+	// id is a salted hash of the root of the sector, so the root cannot be
+	// recovered from it.
+	SectorID [12]byte
+
+	// SectorLocationEntry is the location of the sector with the given root.
+	SectorLocationEntry struct {
+		Root crypto.Hash `json:"root"`
+		SectorLocation
+	}
+
+	// SectorUpdate is a sector that is added to the storage manager as part of
+	// a batch. Data is ignored if the storage manager already holds a sector
+	// with the same root.
+	SectorUpdate struct {
+		Root crypto.Hash
+		Data []byte
+	}
+
 	// A StorageManager is responsible for managing storage folders and
 	// sectors. Sectors are the base unit of storage that gets moved between
 	// renters and hosts, and primarily is stored on the hosts.
@@ -63,6 +127,12 @@ type (
 		// successfully renewing.
 		AddSectorBatch(sectorRoots []crypto.Hash) error
 
+		// AddSectors adds a batch of sectors to the storage manager. The batch
+		// is atomic: either every sector is added, or none of them are. The
+		// whole batch is committed with a single sync, which is much faster
+		// than calling AddSector for each sector.
+		AddSectors(sectors []SectorUpdate) error
+
 		// AddStorageFolder adds a storage folder to the manager. The manager
 		// may not check that there is enough space available on-disk to
 		// support as much storage as requested, though the manager should
@@ -72,6 +142,15 @@ type (
 		// The storage manager needs to be able to shut down.
 		Close() error
 
+		// HasSector returns whether the storage manager has the sector with
+		// the provided root, and if so, where the sector is stored.
+		HasSector(sectorRoot crypto.Hash) (bool, SectorLocation, error)
+
+		// HasSectors is the bulk form of HasSector. The returned map holds the
+		// location of each of the provided roots that the storage manager
+		// has.
+		HasSectors(sectorRoots []crypto.Hash) (map[crypto.Hash]SectorLocation, error)
+
 		// DeleteSector deletes a sector, meaning that the manager will be
 		// unable to upload that sector and be unable to provide a storage
 		// proof on that sector. DeleteSector is for removing the data
@@ -94,6 +173,12 @@ type (
 		// necessary when clearing out an entire contract from the host.
 		RemoveSectorBatch(sectorRoots []crypto.Hash) error
 
+		// RemoveSectors removes a batch of sectors from the storage manager.
+		// A root that appears multiple times is removed multiple times. The
+		// batch is atomic: if any of the sectors cannot be removed, none of
+		// them are.
+		RemoveSectors(sectorRoots []crypto.Hash) error
+
 		// RemoveStorageFolder will remove a storage folder from the manager.
 		// All storage on the folder will be moved to other storage folders,
 		// meaning that no data will be lost. If the manager is unable to save
@@ -118,8 +203,24 @@ type (
 		// that data will be lost.
 		ResizeStorageFolder(index uint16, newSize uint64, force bool) error
 
+		// SectorLocation returns the storage folder and the offset within the
+		// folder at which the sector with the provided root is stored.
+		SectorLocation(sectorRoot crypto.Hash) (SectorLocation, error)
+
 		// StorageFolders will return a list of storage folders tracked by the
 		// manager.
 		StorageFolders() []StorageFolderMetadata
+
+		// StorageFolderMetrics returns the usage and health statistics of
+		// each storage folder. Unlike StorageFolders, the read and write
+		// counters of unavailable folders are reported as-is.
+		StorageFolderMetrics() []StorageFolderMetadata
+
+		// VerifyStorageFolder reads a random sample of the sectors of the
+		// storage folder at path, recomputes their Merkle roots, and returns
+		// the sectors whose data does not match their metadata. sampleRate is
+		// the fraction of the sectors that are read, and must be greater than
+		// 0 and at most 1, which reads every sector.
+		VerifyStorageFolder(path string, sampleRate float64) ([]SectorError, error)
 	}
 )
@@ -71,6 +71,16 @@ type (
 		RevertedTransactions []TransactionSetID
 	}
 
+	// FeeHistogramBucket is a range of fees per byte, along with the number of
+	// transactions in the transaction pool whose transaction sets pay a fee
+	// in that range. MinFeePerByte is inclusive, and MaxFeePerByte is
+	// exclusive.
+	FeeHistogramBucket struct {
+		MinFeePerByte types.Currency `json:"minfeeperbyte"`
+		MaxFeePerByte types.Currency `json:"maxfeeperbyte"`
+		TxCount       uint64         `json:"txcount"`
+	}
+
 	// UnconfirmedTransactionSet defines a new unconfirmed transaction that has
 	// been added to the transaction pool. ID is the ID of the set, IDs contains
 	// an ID for each transaction, eliminating the need to recompute it (because
@@ -117,6 +127,12 @@ type (
 		// within 10 blocks.
 		FeeEstimation() (minimumRecommended, maximumRecommended types.Currency)
 
+		// TransactionFeeHistogram returns the number of transactions in the
+		// transaction pool by the fee per byte of their transaction sets, in
+		// buckets ordered by fee. The last bucket also holds the transactions
+		// that pay more than its maximum fee.
+		TransactionFeeHistogram() []FeeHistogramBucket
+
 		// PurgeTransactionPool is a temporary function available to the miner. In
 		// the event that a miner mines an unacceptable block, the transaction pool
 		// will be purged to clear out the transaction pool and get rid of the
@@ -54,6 +54,11 @@ const (
 	// amount required to extend the fee pool when coming up with a min fee
 	// recommendation.
 	minExtendMultiplier = 1.2
+
+	// feeHistogramBuckets defines the number of buckets of the fee histogram.
+	// The first bucket holds the fees below minEstimation, and every other
+	// bucket covers twice the fees of the previous bucket.
+	feeHistogramBuckets = 16
 )
 
 // Variables related to the persisting structures of the transaction pool.
@@ -169,6 +169,58 @@ func (tp *TransactionPool) FeeEstimation() (min, max types.Currency) {
 	return
 }
 
+// feeHistogramBounds returns the lower bound of every bucket of the fee
+// histogram.
+func feeHistogramBounds() []types.Currency {
+	bounds := make([]types.Currency, feeHistogramBuckets)
+	bounds[1] = minEstimation
+	for i := 2; i < feeHistogramBuckets; i++ {
+		bounds[i] = bounds[i-1].Mul64(2)
+	}
+	return bounds
+}
+
+// feeHistogramIndex returns the index of the bucket of the fee histogram that
+// the fee falls into.
+func feeHistogramIndex(bounds []types.Currency, fee types.Currency) int {
+	i := len(bounds) - 1
+	for i > 0 && fee.Cmp(bounds[i]) < 0 {
+		i--
+	}
+	return i
+}
+
+// TransactionFeeHistogram returns the number of transactions in the
+// transaction pool by the fee per byte of their transaction sets. The buckets
+// are ordered by fee, and the last bucket also holds the transactions that pay
+// more than its maximum fee.
+func (tp *TransactionPool) TransactionFeeHistogram() []modules.FeeHistogramBucket {
+	err := tp.tg.Add()
+	if err != nil {
+		return nil
+	}
+	defer tp.tg.Done()
+
+	bounds := feeHistogramBounds()
+	histogram := make([]modules.FeeHistogramBucket, feeHistogramBuckets)
+	for i := range histogram {
+		histogram[i].MinFeePerByte = bounds[i]
+		if i+1 < feeHistogramBuckets {
+			histogram[i].MaxFeePerByte = bounds[i+1]
+		} else {
+			histogram[i].MaxFeePerByte = bounds[i].Mul64(2)
+		}
+	}
+
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	for _, tSet := range tp.transactionSets {
+		i := feeHistogramIndex(bounds, modules.CalculateFee(tSet))
+		histogram[i].TxCount += uint64(len(tSet))
+	}
+	return histogram
+}
+
 // TransactionList returns a list of all transactions in the transaction pool.
 // The transactions are provided in an order that can acceptably be put into a
 // block.
@@ -210,6 +210,98 @@ func TestGetTransaction(t *testing.T) {
 	}
 }
 
+// TestTransactionFeeHistogram checks that the transactions of the pool are
+// counted in the buckets of the fees per byte of their transaction sets.
+func TestTransactionFeeHistogram(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// The bounds should double from minEstimation.
+	bounds := feeHistogramBounds()
+	if len(bounds) != feeHistogramBuckets || !bounds[0].IsZero() || !bounds[1].Equals(minEstimation) || !bounds[3].Equals(minEstimation.Mul64(4)) {
+		t.Fatal("wrong fee histogram bounds:", bounds)
+	}
+	for _, test := range []struct {
+		fee   types.Currency
+		index int
+	}{
+		{types.ZeroCurrency, 0},
+		{minEstimation.Sub(types.NewCurrency64(1)), 0},
+		{minEstimation, 1},
+		{minEstimation.Mul64(3), 2},
+		{minEstimation.Mul64(1 << 20), feeHistogramBuckets - 1},
+	} {
+		if i := feeHistogramIndex(bounds, test.fee); i != test.index {
+			t.Errorf("fee %v was put in bucket %v, expected %v", test.fee, i, test.index)
+		}
+	}
+
+	// Add a transaction set without fees and one with a large fee.
+	histogram := tpt.tpool.TransactionFeeHistogram()
+	for _, b := range histogram {
+		if b.TxCount != 0 {
+			t.Fatal("empty pool has transactions in its fee histogram:", histogram)
+		}
+	}
+	// Confirm two outputs that can be spent without signatures, so that the
+	// transaction sets spending them are independent.
+	emptyUH := types.UnlockConditions{}.UnlockHash()
+	value := types.SiacoinPrecision.Mul64(10)
+	txns, err := tpt.wallet.SendSiacoinsMulti([]types.SiacoinOutput{
+		{Value: value, UnlockHash: emptyUH},
+		{Value: value, UnlockHash: emptyUH},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tpt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	var sets [][]types.Transaction
+	for i, fee := range []types.Currency{types.ZeroCurrency, types.SiacoinPrecision} {
+		txn := types.Transaction{
+			SiacoinInputs: []types.SiacoinInput{{
+				ParentID: txns[len(txns)-1].SiacoinOutputID(uint64(i)),
+			}},
+			SiacoinOutputs: []types.SiacoinOutput{{
+				Value:      value.Sub(fee),
+				UnlockHash: emptyUH,
+			}},
+		}
+		if !fee.IsZero() {
+			txn.MinerFees = []types.Currency{fee}
+		}
+		set := []types.Transaction{txn}
+		if err := tpt.tpool.AcceptTransactionSet(set); err != nil {
+			t.Fatal(err)
+		}
+		sets = append(sets, set)
+	}
+
+	expected := make([]uint64, feeHistogramBuckets)
+	for _, set := range sets {
+		expected[feeHistogramIndex(bounds, modules.CalculateFee(set))] += uint64(len(set))
+	}
+	if expected[0] != 1 {
+		t.Fatal("transaction set without fees should be in the first bucket")
+	}
+	histogram = tpt.tpool.TransactionFeeHistogram()
+	for i, b := range histogram {
+		if !b.MinFeePerByte.Equals(bounds[i]) || b.MinFeePerByte.Cmp(b.MaxFeePerByte) >= 0 {
+			t.Errorf("bucket %v has a bad range: %+v", i, b)
+		}
+		if b.TxCount != expected[i] {
+			t.Errorf("bucket %v has %v transactions, expected %v", i, b.TxCount, expected[i])
+		}
+	}
+}
+
 // TestBlockFeeEstimation checks that the fee estimation algorithm is reasonably
 // on target when the tpool is relying on blockchain based fee estimation.
 func TestFeeEstimation(t *testing.T) {
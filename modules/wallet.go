@@ -3,6 +3,7 @@ package modules
 import (
 	"bytes"
 	"errors"
+	"time"
 
 	"github.com/NebulousLabs/entropy-mnemonics"
 
@@ -23,6 +24,30 @@ const (
 	WalletDir = "wallet"
 )
 
+// The purposes of the spending transactions that are recorded in the spending
+// log of the wallet.
+const (
+	SpendingPurposeSend               SpendingPurpose = "send"
+	SpendingPurposeTimelock           SpendingPurpose = "timelock"
+	SpendingPurposeDefrag             SpendingPurpose = "defrag"
+	SpendingPurposeAnnouncement       SpendingPurpose = "announcement"
+	SpendingPurposeContractCollateral SpendingPurpose = "contractcollateral"
+	SpendingPurposeRevisionFee        SpendingPurpose = "revisionfee"
+	SpendingPurposeStorageProof       SpendingPurpose = "storageproof"
+	SpendingPurposeContractFormation  SpendingPurpose = "contractformation"
+	SpendingPurposeContractRenewal    SpendingPurpose = "contractrenewal"
+)
+
+// The statuses of a spending transaction. A transaction is pending until the
+// wallet has seen it in the transaction pool, and dropped if the transaction
+// pool removed it without it being confirmed.
+const (
+	SpendingStatusPending     SpendingStatus = "pending"
+	SpendingStatusUnconfirmed SpendingStatus = "unconfirmed"
+	SpendingStatusConfirmed   SpendingStatus = "confirmed"
+	SpendingStatusDropped     SpendingStatus = "dropped"
+)
+
 var (
 	// ErrBadEncryptionKey is returned if the incorrect encryption key to a
 	// file is provided.
@@ -51,6 +76,13 @@ type (
 	// WalletTransactionID is a unique identifier for a wallet transaction.
 	WalletTransactionID crypto.Hash
 
+	// A GeneratedAddress is an address generated from the primary seed, along
+	// with the index of the seed key that the address was derived from.
+	GeneratedAddress struct {
+		Address types.UnlockHash `json:"address"`
+		Index   uint64           `json:"index"`
+	}
+
 	// A ProcessedInput represents funding to a transaction. The input is
 	// coming from an address and going to the outputs. The fund types are
 	// 'SiacoinInput', 'SiafundInput'.
@@ -58,6 +90,7 @@ type (
 		ParentID       types.OutputID   `json:"parentid"`
 		FundType       types.Specifier  `json:"fundtype"`
 		WalletAddress  bool             `json:"walletaddress"`
+		WatchedAddress bool             `json:"watchedaddress"`
 		RelatedAddress types.UnlockHash `json:"relatedaddress"`
 		Value          types.Currency   `json:"value"`
 	}
@@ -79,6 +112,7 @@ type (
 		FundType       types.Specifier   `json:"fundtype"`
 		MaturityHeight types.BlockHeight `json:"maturityheight"`
 		WalletAddress  bool              `json:"walletaddress"`
+		WatchedAddress bool              `json:"watchedaddress"`
 		RelatedAddress types.UnlockHash  `json:"relatedaddress"`
 		Value          types.Currency    `json:"value"`
 	}
@@ -100,6 +134,45 @@ type (
 		Outputs []ProcessedOutput `json:"outputs"`
 	}
 
+	// SpendingPurpose describes why a transaction spent siacoins of the
+	// wallet.
+	SpendingPurpose string
+
+	// SpendingStatus describes whether a spending transaction has been
+	// confirmed.
+	SpendingStatus string
+
+	// A SpendingRecord is an entry of the spending log of the wallet. Module
+	// is the module that created the transaction set, and Counterparty and
+	// RelatedID identify the recipient and the file contract of the spending
+	// where they are known. Amount is the number of siacoins that left the
+	// wallet, including the Fee paid by the wallet. TransactionID is the ID
+	// of the last transaction of the set.
+	SpendingRecord struct {
+		Module             string               `json:"module"`
+		Purpose            SpendingPurpose      `json:"purpose"`
+		Counterparty       string               `json:"counterparty"`
+		RelatedID          types.FileContractID `json:"relatedid"`
+		Amount             types.Currency       `json:"amount"`
+		Fee                types.Currency       `json:"fee"`
+		TransactionID      types.TransactionID  `json:"transactionid"`
+		Timestamp          time.Time            `json:"timestamp"`
+		Status             SpendingStatus       `json:"status"`
+		ConfirmationHeight types.BlockHeight    `json:"confirmationheight"`
+	}
+
+	// A SpendingRecorder records the transaction sets that spend siacoins of
+	// the wallet in the spending log of the wallet.
+	SpendingRecorder interface {
+		// RecordSpending adds record to the spending log once txnSet has
+		// been accepted by the transaction pool, so that rejected sets are
+		// not recorded. The amount, transaction ID, timestamp, and status of
+		// the record are set by the wallet. Recording a transaction set
+		// again updates its module, purpose, counterparty, related ID, and
+		// fee.
+		RecordSpending(record SpendingRecord, txnSet []types.Transaction) error
+	}
+
 	// TransactionBuilder is used to construct custom transactions. A transaction
 	// builder is initialized via 'RegisterTransaction' and then can be modified by
 	// adding funds or other fields. The transaction is completed by calling
@@ -284,6 +357,16 @@ type (
 		// filepath. The backup will have all seeds and keys.
 		CreateBackup(string) error
 
+		// GenerateAddresses returns n new addresses generated from the
+		// primary seed. The addresses are persisted with a single sync, which
+		// is much faster than calling NextAddress n times.
+		GenerateAddresses(n uint64) ([]types.UnlockHash, error)
+
+		// GenerateAddressExport is GenerateAddresses, but also returns the
+		// seed index of each address so that the addresses can be exported
+		// to another system.
+		GenerateAddressExport(n uint64) ([]GeneratedAddress, error)
+
 		// LoadBackup will load a backup of the wallet from the provided
 		// address. The backup wallet will be added as an auxiliary seed, not
 		// as a primary seed.
@@ -327,14 +410,16 @@ type (
 	Wallet interface {
 		EncryptionManager
 		KeyManager
+		SpendingRecorder
 
 		// Close permits clean shutdown during testing and serving.
 		Close() error
 
 		// ConfirmedBalance returns the confirmed balance of the wallet, minus
 		// any outgoing transactions. ConfirmedBalance will include unconfirmed
-		// refund transactions.
-		ConfirmedBalance() (siacoinBalance types.Currency, siafundBalance types.Currency, siacoinClaimBalance types.Currency)
+		// refund transactions. Timelocked siacoins are not included in the
+		// siacoin balance, they are reported as the timelocked balance.
+		ConfirmedBalance() (siacoinBalance types.Currency, siafundBalance types.Currency, siacoinClaimBalance types.Currency, timelockedBalance types.Currency)
 
 		// UnconfirmedBalance returns the unconfirmed balance of the wallet.
 		// Outgoing funds and incoming funds are reported separately. Refund
@@ -392,6 +477,29 @@ type (
 		// are also returned to the caller.
 		SendSiafunds(amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error)
 
+		// TimelockFunds sends amount to a new address of the wallet that
+		// cannot be spent from until the blockchain reaches unlockHeight. The
+		// transaction is given to the transaction pool and is also returned.
+		TimelockFunds(amount types.Currency, unlockHeight types.BlockHeight) (types.Transaction, error)
+
+		// WatchAddress adds an address that the wallet has no keys for to
+		// the watched addresses. The outputs and transactions of watched
+		// addresses are tracked, but their outputs are never spent.
+		WatchAddress(addr types.UnlockHash) error
+
+		// WatchedAddresses returns the addresses watched by the wallet.
+		WatchedAddresses() []types.UnlockHash
+
+		// WatchedBalance returns the confirmed siacoins and siafunds of the
+		// watched addresses. They are not included in the confirmed balance.
+		WatchedBalance() (siacoinBalance types.Currency, siafundBalance types.Currency)
+
+		// SpendingLog returns the spending records of the wallet that were
+		// recorded in [start, end], oldest first. A zero start or end leaves
+		// that side of the range open, and an empty module or purpose
+		// matches every record.
+		SpendingLog(start, end time.Time, module string, purpose SpendingPurpose) ([]SpendingRecord, error)
+
 		// DustThreshold returns the quantity below which a Currency is considered to be Dust.
 		DustThreshold() types.Currency
 	}
@@ -20,18 +20,23 @@ var (
 	// stored. The key of this bucket is an autoincrementing integer.
 	bucketProcessedTransactions = []byte("bucketProcessedTransactions")
 	// bucketSiacoinOutputs maps a SiacoinOutputID to its SiacoinOutput. Only
-	// outputs that the wallet controls are stored. The wallet uses these
-	// outputs to fund transactions.
+	// outputs that the wallet controls or watches are stored. The wallet uses
+	// the outputs that it controls to fund transactions.
 	bucketSiacoinOutputs = []byte("bucketSiacoinOutputs")
 	// bucketSiacoinOutputs maps a SiafundOutputID to its SiafundOutput. Only
-	// outputs that the wallet controls are stored. The wallet uses these
-	// outputs to fund transactions.
+	// outputs that the wallet controls or watches are stored. The wallet uses
+	// the outputs that it controls to fund transactions.
 	bucketSiafundOutputs = []byte("bucketSiafundOutputs")
 	// bucketSpentOutputs maps an OutputID to the height at which it was
 	// spent. Only outputs spent by the wallet are stored. The wallet tracks
 	// these outputs so that it can reuse them if they are not confirmed on
 	// the blockchain.
 	bucketSpentOutputs = []byte("bucketSpentOutputs")
+	// bucketSpendingLog maps the ID of the last transaction of a spending
+	// transaction set to its SpendingRecord. The wallet, host, and renter
+	// record each transaction set that spends siacoins of the wallet before
+	// it is broadcast.
+	bucketSpendingLog = []byte("bucketSpendingLog")
 	// bucketWallet contains various fields needed by the wallet, such as its
 	// UID, EncryptionVerification, and PrimarySeedFile.
 	bucketWallet = []byte("bucketWallet")
@@ -41,6 +46,7 @@ var (
 		bucketSiacoinOutputs,
 		bucketSiafundOutputs,
 		bucketSpentOutputs,
+		bucketSpendingLog,
 		bucketWallet,
 	}
 
@@ -55,7 +61,9 @@ var (
 	keyPrimarySeedProgress    = []byte("keyPrimarySeedProgress")
 	keySiafundPool            = []byte("keySiafundPool")
 	keySpendableKeyFiles      = []byte("keySpendableKeyFiles")
+	keyTimelockedKeys         = []byte("keyTimelockedKeys")
 	keyUID                    = []byte("keyUID")
+	keyWatchedAddrs           = []byte("keyWatchedAddrs")
 )
 
 // threadedDBUpdate commits the active database transaction and starts a new
@@ -113,6 +121,8 @@ func dbReset(tx *bolt.Tx) error {
 	wb.Put(keyConsensusHeight, encoding.Marshal(uint64(0)))
 	wb.Put(keyAuxiliarySeedFiles, encoding.Marshal([]seedFile{}))
 	wb.Put(keySpendableKeyFiles, encoding.Marshal([]spendableKeyFile{}))
+	wb.Put(keyTimelockedKeys, encoding.Marshal([]timelockedKey{}))
+	wb.Put(keyWatchedAddrs, encoding.Marshal([]types.UnlockHash{}))
 	dbPutConsensusHeight(tx, 0)
 	dbPutConsensusChangeID(tx, modules.ConsensusChangeBeginning)
 	dbPutSiafundPool(tx, types.ZeroCurrency)
@@ -5,6 +5,7 @@ import (
 	"sort"
 
 	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -158,6 +159,7 @@ func (w *Wallet) threadedDefragWallet() {
 		w.log.Println("WARN: defrag transaction was rejected:", err)
 		return
 	}
+	w.managedRecordSpending(modules.SpendingPurposeDefrag, "", txnSet)
 	w.log.Println("Submitting a transaction set to defragment the wallet's outputs, IDs:")
 	for _, txn := range txnSet {
 		w.log.Println("\t", txn.ID())
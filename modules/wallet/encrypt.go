@@ -109,6 +109,8 @@ func (w *Wallet) managedUnlock(masterKey crypto.TwofishKey) error {
 	var primarySeedProgress uint64
 	var auxiliarySeedFiles []seedFile
 	var unseededKeyFiles []spendableKeyFile
+	var timelockedKeys []timelockedKey
+	var watchedAddrs []types.UnlockHash
 	err := func() error {
 		w.mu.Lock()
 		defer w.mu.Unlock()
@@ -145,6 +147,18 @@ func (w *Wallet) managedUnlock(masterKey crypto.TwofishKey) error {
 			return err
 		}
 
+		// timelockedKeys
+		err = encoding.Unmarshal(wb.Get(keyTimelockedKeys), &timelockedKeys)
+		if err != nil {
+			return err
+		}
+
+		// watchedAddrs
+		err = encoding.Unmarshal(wb.Get(keyWatchedAddrs), &watchedAddrs)
+		if err != nil {
+			return err
+		}
+
 		return nil
 	}()
 	if err != nil {
@@ -165,6 +179,16 @@ func (w *Wallet) managedUnlock(masterKey crypto.TwofishKey) error {
 		w.primarySeed = primarySeed
 		w.regenerateLookahead(primarySeedProgress)
 
+		// timelockedKeys
+		for _, tk := range timelockedKeys {
+			w.integrateTimelockedKey(tk)
+		}
+
+		// watchedAddrs
+		for _, uh := range watchedAddrs {
+			w.watchedAddrs[uh] = struct{}{}
+		}
+
 		// auxiliarySeedFiles
 		for _, sf := range auxiliarySeedFiles {
 			auxSeed, err := decryptSeedFile(masterKey, sf)
@@ -47,7 +47,7 @@ func postEncryptionTesting(m modules.TestMiner, w *Wallet, masterKey crypto.Twof
 			panic(err)
 		}
 	}
-	siacoinBal, _, _ := w.ConfirmedBalance()
+	siacoinBal, _, _, _ := w.ConfirmedBalance()
 	if siacoinBal.IsZero() {
 		panic("wallet balance reported as 0 after maturing some mined blocks")
 	}
@@ -83,7 +83,7 @@ func postEncryptionTesting(m modules.TestMiner, w *Wallet, masterKey crypto.Twof
 	if err != nil {
 		panic(err)
 	}
-	siacoinBal2, _, _ := w.ConfirmedBalance()
+	siacoinBal2, _, _, _ := w.ConfirmedBalance()
 	if siacoinBal2.Cmp(siacoinBal) >= 0 {
 		panic("balance did not increase")
 	}
@@ -210,13 +210,13 @@ func TestLock(t *testing.T) {
 	}
 
 	// Lock the wallet.
-	siacoinBalance, _, _ := wt.wallet.ConfirmedBalance()
+	siacoinBalance, _, _, _ := wt.wallet.ConfirmedBalance()
 	err = wt.wallet.Lock()
 	if err != nil {
 		t.Error(err)
 	}
 	// Compare to the original balance.
-	siacoinBalance2, _, _ := wt.wallet.ConfirmedBalance()
+	siacoinBalance2, _, _, _ := wt.wallet.ConfirmedBalance()
 	if !siacoinBalance2.Equals(siacoinBalance) {
 		t.Error("siacoin balance reporting changed upon closing the wallet")
 	}
@@ -243,7 +243,7 @@ func TestLock(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	siacoinBalance3, _, _ := wt.wallet.ConfirmedBalance()
+	siacoinBalance3, _, _, _ := wt.wallet.ConfirmedBalance()
 	if siacoinBalance3.Cmp(siacoinBalance2) <= 0 {
 		t.Error("balance should increase after a block was mined")
 	}
@@ -266,7 +266,7 @@ func TestInitFromSeedConcurrentUnlock(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	origBal, _, _ := wt.wallet.ConfirmedBalance()
+	origBal, _, _, _ := wt.wallet.ConfirmedBalance()
 
 	// create a blank wallet
 	dir := filepath.Join(build.TempDir(modules.WalletDir, t.Name()+"-new"), modules.WalletDir)
@@ -296,7 +296,7 @@ func TestInitFromSeedConcurrentUnlock(t *testing.T) {
 	}
 
 	// starting balance should match the original wallet
-	newBal, _, _ := w.ConfirmedBalance()
+	newBal, _, _, _ := w.ConfirmedBalance()
 	if newBal.Cmp(origBal) != 0 {
 		t.Log(w.UnconfirmedBalance())
 		t.Fatalf("wallet should have correct balance after loading seed: wanted %v, got %v", origBal, newBal)
@@ -358,7 +358,7 @@ func TestInitFromSeed(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	origBal, _, _ := wt.wallet.ConfirmedBalance()
+	origBal, _, _, _ := wt.wallet.ConfirmedBalance()
 
 	// create a blank wallet
 	dir := filepath.Join(build.TempDir(modules.WalletDir, "TestInitFromSeed1"), modules.WalletDir)
@@ -375,7 +375,7 @@ func TestInitFromSeed(t *testing.T) {
 		t.Fatal(err)
 	}
 	// starting balance should match the original wallet
-	newBal, _, _ := w.ConfirmedBalance()
+	newBal, _, _, _ := w.ConfirmedBalance()
 	if newBal.Cmp(origBal) != 0 {
 		t.Log(w.UnconfirmedBalance())
 		t.Fatalf("wallet should have correct balance after loading seed: wanted %v, got %v", origBal, newBal)
@@ -447,7 +447,7 @@ func TestChangeKey(t *testing.T) {
 
 	var newKey crypto.TwofishKey
 	fastrand.Read(newKey[:])
-	origBal, _, _ := wt.wallet.ConfirmedBalance()
+	origBal, _, _, _ := wt.wallet.ConfirmedBalance()
 
 	err = wt.wallet.ChangeKey(wt.walletMasterKey, newKey)
 	if err != nil {
@@ -468,7 +468,7 @@ func TestChangeKey(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	newBal, _, _ := wt.wallet.ConfirmedBalance()
+	newBal, _, _, _ := wt.wallet.ConfirmedBalance()
 	if newBal.Cmp(origBal) != 0 {
 		t.Fatal("wallet with changed key did not have the same balance")
 	}
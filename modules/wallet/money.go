@@ -20,8 +20,9 @@ func (w *Wallet) DustThreshold() types.Currency {
 }
 
 // ConfirmedBalance returns the balance of the wallet according to all of the
-// confirmed transactions.
-func (w *Wallet) ConfirmedBalance() (siacoinBalance types.Currency, siafundBalance types.Currency, siafundClaimBalance types.Currency) {
+// confirmed transactions. Siacoin outputs that cannot be spent yet because of
+// their timelock are reported separately as the timelocked balance.
+func (w *Wallet) ConfirmedBalance() (siacoinBalance types.Currency, siafundBalance types.Currency, siafundClaimBalance types.Currency, timelockedBalance types.Currency) {
 	// dustThreshold has to be obtained separate from the lock
 	dustThreshold := w.DustThreshold()
 
@@ -31,8 +32,17 @@ func (w *Wallet) ConfirmedBalance() (siacoinBalance types.Currency, siafundBalan
 	// ensure durability of reported balance
 	w.syncDB()
 
+	height, err := dbGetConsensusHeight(w.dbTx)
+	if err != nil {
+		return
+	}
 	dbForEachSiacoinOutput(w.dbTx, func(_ types.SiacoinOutputID, sco types.SiacoinOutput) {
-		if sco.Value.Cmp(dustThreshold) > 0 {
+		if sco.Value.Cmp(dustThreshold) <= 0 || !w.isWalletAddress(sco.UnlockHash) {
+			return
+		}
+		if w.isTimelocked(sco.UnlockHash, height) {
+			timelockedBalance = timelockedBalance.Add(sco.Value)
+		} else {
 			siacoinBalance = siacoinBalance.Add(sco.Value)
 		}
 	})
@@ -42,6 +52,9 @@ func (w *Wallet) ConfirmedBalance() (siacoinBalance types.Currency, siafundBalan
 		return
 	}
 	dbForEachSiafundOutput(w.dbTx, func(_ types.SiafundOutputID, sfo types.SiafundOutput) {
+		if !w.isWalletAddress(sfo.UnlockHash) {
+			return
+		}
 		siafundBalance = siafundBalance.Add(sfo.Value)
 		if sfo.ClaimStart.Cmp(siafundPool) > 0 {
 			// Skip claims larger than the siafund pool. This should only
@@ -56,7 +69,7 @@ func (w *Wallet) ConfirmedBalance() (siacoinBalance types.Currency, siafundBalan
 
 // UnconfirmedBalance returns the number of outgoing and incoming siacoins in
 // the unconfirmed transaction set. Refund outputs are included in this
-// reporting.
+// reporting, timelocked outputs are not.
 func (w *Wallet) UnconfirmedBalance() (outgoingSiacoins types.Currency, incomingSiacoins types.Currency) {
 	// dustThreshold has to be obtained separate from the lock
 	dustThreshold := w.DustThreshold()
@@ -64,6 +77,10 @@ func (w *Wallet) UnconfirmedBalance() (outgoingSiacoins types.Currency, incoming
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	height, err := dbGetConsensusHeight(w.dbTx)
+	if err != nil {
+		return
+	}
 	for _, upt := range w.unconfirmedProcessedTransactions {
 		for _, input := range upt.Inputs {
 			if input.FundType == types.SpecifierSiacoinInput && input.WalletAddress {
@@ -71,7 +88,7 @@ func (w *Wallet) UnconfirmedBalance() (outgoingSiacoins types.Currency, incoming
 			}
 		}
 		for _, output := range upt.Outputs {
-			if output.FundType == types.SpecifierSiacoinOutput && output.WalletAddress && output.Value.Cmp(dustThreshold) > 0 {
+			if output.FundType == types.SpecifierSiacoinOutput && output.WalletAddress && output.Value.Cmp(dustThreshold) > 0 && !w.isTimelocked(output.RelatedAddress, height) {
 				incomingSiacoins = incomingSiacoins.Add(output.Value)
 			}
 		}
@@ -116,6 +133,7 @@ func (w *Wallet) SendSiacoins(amount types.Currency, dest types.UnlockHash) ([]t
 		w.log.Println("Attempt to send coins has failed - transaction pool rejected transaction:", err)
 		return nil, build.ExtendErr("unable to get transaction accepted", err)
 	}
+	w.managedRecordSpending(modules.SpendingPurposeSend, dest.String(), txnSet)
 	w.log.Println("Submitted a siacoin transfer transaction set for value", amount.HumanString(), "with fees", tpoolFee.HumanString(), "IDs:")
 	for _, txn := range txnSet {
 		w.log.Println("\t", txn.ID())
@@ -166,11 +184,16 @@ func (w *Wallet) SendSiacoinsMulti(outputs []types.SiacoinOutput) ([]types.Trans
 		w.log.Println("Attempt to send coins has failed - failed to sign transaction:", err)
 		return nil, build.ExtendErr("unable to sign transaction", err)
 	}
+	var counterparty string
+	if len(outputs) == 1 {
+		counterparty = outputs[0].UnlockHash.String()
+	}
 	err = w.tpool.AcceptTransactionSet(txnSet)
 	if err != nil {
 		w.log.Println("Attempt to send coins has failed - transaction pool rejected transaction:", err)
 		return nil, build.ExtendErr("unable to get transaction accepted", err)
 	}
+	w.managedRecordSpending(modules.SpendingPurposeSend, counterparty, txnSet)
 	return txnSet, nil
 }
 
@@ -212,6 +235,7 @@ func (w *Wallet) SendSiafunds(amount types.Currency, dest types.UnlockHash) ([]t
 	if err != nil {
 		return nil, err
 	}
+	w.managedRecordSpending(modules.SpendingPurposeSend, dest.String(), txnSet)
 	w.log.Println("Submitted a siafund transfer transaction set for value", amount.HumanString(), "with fees", tpoolFee.HumanString(), "IDs:")
 	for _, txn := range txnSet {
 		w.log.Println("\t", txn.ID())
@@ -43,6 +43,13 @@ var numInitialKeys = func() uint64 {
 	}
 }()
 
+// maxAddressBatch is the largest number of addresses that GenerateAddresses
+// will create at once. The seed scanner stops once none of the upper half of
+// the keys it generated are used, so as long as the scanner finds the address
+// before a batch, it also finds the last address of the batch, even if none of
+// the other addresses of the batch are ever used.
+var maxAddressBatch = numInitialKeys / 2
+
 // A scannedOutput is an output found in the blockchain that was generated
 // from a given seed.
 type scannedOutput struct {
@@ -2,6 +2,7 @@ package wallet
 
 import (
 	"errors"
+	"fmt"
 	"runtime"
 	"sync"
 
@@ -15,6 +16,8 @@ import (
 
 var (
 	errKnownSeed = errors.New("seed is already known")
+
+	errAddressBatchSize = fmt.Errorf("cannot generate more than %v addresses at once", maxAddressBatch)
 )
 
 type (
@@ -139,6 +142,34 @@ func (w *Wallet) nextPrimarySeedAddress(tx *bolt.Tx) (types.UnlockConditions, er
 	return spendableKey.UnlockConditions, nil
 }
 
+// nextPrimarySeedAddresses fetches the next n addresses from the primary seed,
+// returning the keys of the addresses and the seed index of the first key.
+func (w *Wallet) nextPrimarySeedAddresses(tx *bolt.Tx, n uint64) (uint64, []spendableKey, error) {
+	// Check that the wallet has been unlocked.
+	if !w.unlocked {
+		return 0, nil, modules.ErrLockedWallet
+	}
+
+	// Fetch and advance the seed progress.
+	progress, err := dbGetPrimarySeedProgress(tx)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err = dbPutPrimarySeedProgress(tx, progress+n); err != nil {
+		return 0, nil, err
+	}
+
+	// Integrate the new keys into the wallet. The lookahead starts at the old
+	// progress, so after removing the new keys it starts at the new progress.
+	spendableKeys := generateKeys(w.primarySeed, progress, n)
+	for _, sk := range spendableKeys {
+		w.keys[sk.UnlockConditions.UnlockHash()] = sk
+		delete(w.lookahead, sk.UnlockConditions.UnlockHash())
+	}
+	w.regenerateLookahead(progress + n)
+	return progress, spendableKeys, nil
+}
+
 // AllSeeds returns a list of all seeds known to and used by the wallet.
 func (w *Wallet) AllSeeds() ([]modules.Seed, error) {
 	w.mu.Lock()
@@ -193,6 +224,57 @@ func (w *Wallet) NextAddress() (types.UnlockConditions, error) {
 	return uc, err
 }
 
+// managedGenerateAddresses generates n addresses from the primary seed and
+// syncs them to disk once.
+func (w *Wallet) managedGenerateAddresses(n uint64) ([]modules.GeneratedAddress, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+	if n > maxAddressBatch {
+		return nil, errAddressBatchSize
+	}
+
+	w.mu.Lock()
+	start, spendableKeys, err := w.nextPrimarySeedAddresses(w.dbTx, n)
+	w.syncDB() // ensure durability of reported addresses
+	w.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]modules.GeneratedAddress, len(spendableKeys))
+	for i, sk := range spendableKeys {
+		addrs[i] = modules.GeneratedAddress{
+			Address: sk.UnlockConditions.UnlockHash(),
+			Index:   start + uint64(i),
+		}
+	}
+	return addrs, nil
+}
+
+// GenerateAddresses returns n new addresses generated from the primary seed.
+// All of the addresses are persisted with a single sync. At most
+// maxAddressBatch addresses can be generated at once, so that a seed restore
+// is guaranteed to find funds sent to any address of the batch.
+func (w *Wallet) GenerateAddresses(n uint64) ([]types.UnlockHash, error) {
+	addrs, err := w.managedGenerateAddresses(n)
+	if err != nil {
+		return nil, err
+	}
+	uhs := make([]types.UnlockHash, len(addrs))
+	for i, addr := range addrs {
+		uhs[i] = addr.Address
+	}
+	return uhs, nil
+}
+
+// GenerateAddressExport generates n new addresses like GenerateAddresses, and
+// returns them along with the seed index that each address was derived from.
+func (w *Wallet) GenerateAddressExport(n uint64) ([]modules.GeneratedAddress, error) {
+	return w.managedGenerateAddresses(n)
+}
+
 // LoadSeed will track all of the addresses generated by the input seed,
 // reclaiming any funds that were lost due to a deleted file or lost encryption
 // key. An error will be returned if the seed has already been integrated with
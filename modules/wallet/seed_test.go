@@ -125,7 +125,7 @@ func TestLoadSeed(t *testing.T) {
 		t.Fatal(err)
 	}
 	// Balance of wallet should be 0.
-	siacoinBal, _, _ := w.ConfirmedBalance()
+	siacoinBal, _, _, _ := w.ConfirmedBalance()
 	if !siacoinBal.Equals64(0) {
 		t.Error("fresh wallet should not have a balance")
 	}
@@ -147,7 +147,7 @@ func TestLoadSeed(t *testing.T) {
 		t.Error("AllSeeds returned the wrong seed")
 	}
 
-	siacoinBal2, _, _ := w.ConfirmedBalance()
+	siacoinBal2, _, _, _ := w.ConfirmedBalance()
 	if siacoinBal2.Cmp64(0) <= 0 {
 		t.Error("wallet failed to load a seed with money in it")
 	}
@@ -213,7 +213,7 @@ func TestSweepSeedCoins(t *testing.T) {
 		t.Fatal(err)
 	}
 	// starting balance should be 0.
-	siacoinBal, _, _ := w.ConfirmedBalance()
+	siacoinBal, _, _, _ := w.ConfirmedBalance()
 	if !siacoinBal.IsZero() {
 		t.Error("fresh wallet should not have a balance")
 	}
@@ -250,7 +250,7 @@ func TestSweepSeedFunds(t *testing.T) {
 		t.Error(err)
 	}
 
-	_, siafundBal, _ := wt.wallet.ConfirmedBalance()
+	_, siafundBal, _, _ := wt.wallet.ConfirmedBalance()
 	if siafundBal.Cmp(types.NewCurrency64(2000)) != 0 {
 		t.Error("expecting a siafund balance of 2000 from the 1of1 key")
 	}
@@ -279,7 +279,7 @@ func TestSweepSeedFunds(t *testing.T) {
 	for i := types.BlockHeight(0); i < types.MaturityDelay; i++ {
 		wt.addBlockNoPayout()
 	}
-	oldCoinBalance, siafundBal, _ := wt.wallet.ConfirmedBalance()
+	oldCoinBalance, siafundBal, _, _ := wt.wallet.ConfirmedBalance()
 	if siafundBal.Cmp(types.NewCurrency64(1988)) != 0 {
 		t.Errorf("expecting balance of %v after sending siafunds to the seed, got %v", 1988, siafundBal)
 	}
@@ -299,7 +299,7 @@ func TestSweepSeedFunds(t *testing.T) {
 	wt.addBlockNoPayout()
 
 	// Wallet balance should have decreased to pay for the sweep transaction.
-	newCoinBalance, _, _ := wt.wallet.ConfirmedBalance()
+	newCoinBalance, _, _, _ := wt.wallet.ConfirmedBalance()
 	if newCoinBalance.Cmp(oldCoinBalance) >= 0 {
 		t.Error("expecting balance to go down; instead, increased by", newCoinBalance.Sub(oldCoinBalance))
 	}
@@ -325,7 +325,7 @@ func TestSweepSeedSentFunds(t *testing.T) {
 		t.Error(err)
 	}
 
-	_, siafundBal, _ := wt.wallet.ConfirmedBalance()
+	_, siafundBal, _, _ := wt.wallet.ConfirmedBalance()
 	if siafundBal.Cmp(types.NewCurrency64(2000)) != 0 {
 		t.Error("expecting a siafund balance of 2000 from the 1of1 key")
 	}
@@ -367,7 +367,7 @@ func TestSweepSeedSentFunds(t *testing.T) {
 	for i := types.BlockHeight(0); i < types.MaturityDelay; i++ {
 		wt.addBlockNoPayout()
 	}
-	oldCoinBalance, siafundBal, _ := wt.wallet.ConfirmedBalance()
+	oldCoinBalance, siafundBal, _, _ := wt.wallet.ConfirmedBalance()
 	if expected := 2000 - 12 - 10; siafundBal.Cmp(types.NewCurrency64(uint64(expected))) != 0 {
 		t.Errorf("expecting balance of %v after sending siafunds to the seed, got %v", expected, siafundBal)
 	}
@@ -387,7 +387,7 @@ func TestSweepSeedSentFunds(t *testing.T) {
 	wt.addBlockNoPayout()
 
 	// Wallet balance should have decreased to pay for the sweep transaction.
-	newCoinBalance, _, _ := wt.wallet.ConfirmedBalance()
+	newCoinBalance, _, _, _ := wt.wallet.ConfirmedBalance()
 	if newCoinBalance.Cmp(oldCoinBalance) >= 0 {
 		t.Error("expecting balance to go down; instead, increased by", newCoinBalance.Sub(oldCoinBalance))
 	}
@@ -412,7 +412,7 @@ func TestSweepSeedCoinsAndFunds(t *testing.T) {
 		t.Error(err)
 	}
 
-	_, siafundBal, _ := wt.wallet.ConfirmedBalance()
+	_, siafundBal, _, _ := wt.wallet.ConfirmedBalance()
 	if siafundBal.Cmp(types.NewCurrency64(2000)) != 0 {
 		t.Error("expecting a siafund balance of 2000 from the 1of1 key")
 	}
@@ -442,7 +442,7 @@ func TestSweepSeedCoinsAndFunds(t *testing.T) {
 	for i := types.BlockHeight(0); i < types.MaturityDelay; i++ {
 		wt.addBlockNoPayout()
 	}
-	oldCoinBalance, siafundBal, _ := wt.wallet.ConfirmedBalance()
+	oldCoinBalance, siafundBal, _, _ := wt.wallet.ConfirmedBalance()
 	if siafundBal.Cmp(types.NewCurrency64(1988)) != 0 {
 		t.Errorf("expecting balance of %v after sending siafunds to the seed, got %v", 1988, siafundBal)
 	}
@@ -462,7 +462,7 @@ func TestSweepSeedCoinsAndFunds(t *testing.T) {
 	wt.addBlockNoPayout()
 
 	// Wallet balance should have decreased to pay for the sweep transaction.
-	newCoinBalance, _, _ := wt.wallet.ConfirmedBalance()
+	newCoinBalance, _, _, _ := wt.wallet.ConfirmedBalance()
 	if newCoinBalance.Cmp(oldCoinBalance) <= 0 {
 		t.Error("expecting balance to go up; instead, decreased by", oldCoinBalance.Sub(newCoinBalance))
 	}
@@ -477,3 +477,137 @@ func TestGenerateKeys(t *testing.T) {
 		}
 	}
 }
+
+// TestGenerateAddresses checks that GenerateAddresses advances the primary
+// seed like repeated calls to NextAddress, and that a seed scan finds funds
+// sent to the last address of a batch.
+func TestGenerateAddresses(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	_, startRemaining, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := maxScanKeys - startRemaining
+	addrs, err := wt.wallet.GenerateAddressExport(maxAddressBatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(len(addrs)) != maxAddressBatch {
+		t.Fatal("expected", maxAddressBatch, "addresses, got", len(addrs))
+	}
+	seed, remaining, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining != startRemaining-maxAddressBatch {
+		t.Error("primary seed progress was not advanced by the size of the batch")
+	}
+	wt.wallet.mu.Lock()
+	for i, addr := range addrs {
+		if addr.Index != start+uint64(i) {
+			t.Fatal("address has the wrong index:", addr.Index, start+uint64(i))
+		}
+		if addr.Address != generateSpendableKey(seed, addr.Index).UnlockConditions.UnlockHash() {
+			t.Fatal("address does not match its index")
+		}
+		if _, exists := wt.wallet.keys[addr.Address]; !exists {
+			t.Fatal("address was not added to the wallet")
+		}
+		if _, exists := wt.wallet.lookahead[addr.Address]; exists {
+			t.Fatal("address was left in the lookahead")
+		}
+	}
+	progress := start + maxAddressBatch
+	if uint64(len(wt.wallet.lookahead)) != maxLookahead(progress) {
+		t.Error("lookahead has the wrong size:", len(wt.wallet.lookahead), maxLookahead(progress))
+	}
+	wt.wallet.mu.Unlock()
+
+	// The next address should follow the batch.
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uc.UnlockHash() != generateSpendableKey(seed, progress).UnlockConditions.UnlockHash() {
+		t.Error("NextAddress did not continue after the batch")
+	}
+
+	// Batches that are too large, and locked wallets, should be rejected.
+	if _, err := wt.wallet.GenerateAddresses(maxAddressBatch + 1); err != errAddressBatchSize {
+		t.Error("expected errAddressBatchSize, got", err)
+	}
+	if err := wt.wallet.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.wallet.GenerateAddresses(1); err != modules.ErrLockedWallet {
+		t.Error("expected ErrLockedWallet, got", err)
+	}
+	if err := wt.wallet.Unlock(wt.walletMasterKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// Send coins to the last address of the batch. A seed scan should find
+	// them.
+	last := addrs[len(addrs)-1]
+	_, err = wt.wallet.SendSiacoins(types.SiacoinPrecision, last.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	ss := newSeedScanner(seed, wt.wallet.log)
+	if err := ss.scan(wt.cs, wt.wallet.tg.StopChan()); err != nil {
+		t.Fatal(err)
+	}
+	if ss.largestIndexSeen < last.Index {
+		t.Error("seed scan did not find the last address of the batch:", ss.largestIndexSeen, last.Index)
+	}
+}
+
+// BenchmarkNextAddress benchmarks generating addresses one at a time.
+func BenchmarkNextAddress(b *testing.B) {
+	wt, err := createWalletTester(b.Name())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := wt.wallet.NextAddress(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGenerateAddresses benchmarks generating the same number of
+// addresses as BenchmarkNextAddress in a single batch.
+func BenchmarkGenerateAddresses(b *testing.B) {
+	wt, err := createWalletTester(b.Name())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	b.ResetTimer()
+	for n := uint64(b.N); n > 0; {
+		batch := n
+		if batch > maxAddressBatch {
+			batch = maxAddressBatch
+		}
+		if _, err := wt.wallet.GenerateAddresses(batch); err != nil {
+			b.Fatal(err)
+		}
+		n -= batch
+	}
+}
@@ -0,0 +1,235 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// errEmptySpendingSet is returned if the wallet is asked to record the
+	// spending of an empty transaction set.
+	errEmptySpendingSet = errors.New("cannot record the spending of an empty transaction set")
+)
+
+// dbGetSpendingRecord returns the spending record of the transaction set
+// whose last transaction is id. The records are stored as JSON, because the
+// timestamp of a record cannot be encoded by the encoding package.
+func dbGetSpendingRecord(tx *bolt.Tx, id types.TransactionID) (record modules.SpendingRecord, err error) {
+	recordBytes := tx.Bucket(bucketSpendingLog).Get(id[:])
+	if recordBytes == nil {
+		return modules.SpendingRecord{}, errNoKey
+	}
+	err = json.Unmarshal(recordBytes, &record)
+	return
+}
+
+// dbPutSpendingRecord stores a spending record under its transaction ID.
+func dbPutSpendingRecord(tx *bolt.Tx, record modules.SpendingRecord) error {
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucketSpendingLog).Put(record.TransactionID[:], recordBytes)
+}
+
+// dbUpdateSpendingStatus sets the status and confirmation height of the
+// spending record of id, if there is one. Confirmed records are only changed
+// by consensus changes, so that the transaction pool cannot undo a
+// confirmation.
+func dbUpdateSpendingStatus(tx *bolt.Tx, id types.TransactionID, status modules.SpendingStatus, height types.BlockHeight, consensus bool) error {
+	record, err := dbGetSpendingRecord(tx, id)
+	if err == errNoKey {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if record.Status == modules.SpendingStatusConfirmed && !consensus {
+		return nil
+	}
+	record.Status = status
+	record.ConfirmationHeight = height
+	return dbPutSpendingRecord(tx, record)
+}
+
+// spentBy returns the number of siacoins that txnSet spends from the wallet:
+// the value of the wallet's outputs spent by the set, minus the value of the
+// outputs that the set creates for the wallet.
+func (w *Wallet) spentBy(txnSet []types.Transaction) types.Currency {
+	created := make(map[types.SiacoinOutputID]types.SiacoinOutput)
+	for _, txn := range txnSet {
+		for i, sco := range txn.SiacoinOutputs {
+			created[txn.SiacoinOutputID(uint64(i))] = sco
+		}
+	}
+	unconfirmed := make(map[types.OutputID]types.Currency)
+	for _, pt := range w.unconfirmedProcessedTransactions {
+		for _, output := range pt.Outputs {
+			if output.FundType == types.SpecifierSiacoinOutput {
+				unconfirmed[output.ID] = output.Value
+			}
+		}
+	}
+
+	var spent, received types.Currency
+	for _, txn := range txnSet {
+		for _, sci := range txn.SiacoinInputs {
+			if !w.isWalletAddress(sci.UnlockConditions.UnlockHash()) {
+				continue
+			}
+			if sco, exists := created[sci.ParentID]; exists {
+				spent = spent.Add(sco.Value)
+			} else if sco, err := dbGetSiacoinOutput(w.dbTx, sci.ParentID); err == nil {
+				spent = spent.Add(sco.Value)
+			} else if value, exists := unconfirmed[types.OutputID(sci.ParentID)]; exists {
+				spent = spent.Add(value)
+			}
+		}
+		for _, sco := range txn.SiacoinOutputs {
+			if w.isWalletAddress(sco.UnlockHash) {
+				received = received.Add(sco.Value)
+			}
+		}
+	}
+	if spent.Cmp(received) < 0 {
+		return types.ZeroCurrency
+	}
+	return spent.Sub(received)
+}
+
+// updateSpendingLog marks the spending records of the transactions in the
+// applied blocks of cc as confirmed, and those in the reverted blocks as
+// unconfirmed. It must be called after the consensus height of the wallet
+// has been updated for cc.
+func (w *Wallet) updateSpendingLog(tx *bolt.Tx, cc modules.ConsensusChange) error {
+	for _, block := range cc.RevertedBlocks {
+		for _, txn := range block.Transactions {
+			err := dbUpdateSpendingStatus(tx, txn.ID(), modules.SpendingStatusUnconfirmed, 0, true)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	height, err := dbGetConsensusHeight(tx)
+	if err != nil {
+		return err
+	}
+	for i := len(cc.AppliedBlocks) - 1; i >= 0; i-- {
+		for _, txn := range cc.AppliedBlocks[i].Transactions {
+			err := dbUpdateSpendingStatus(tx, txn.ID(), modules.SpendingStatusConfirmed, height, true)
+			if err != nil {
+				return err
+			}
+		}
+		height--
+	}
+	return nil
+}
+
+// isUnconfirmed reports whether the transaction pool contains the transaction
+// id. The caller must hold the lock.
+func (w *Wallet) isUnconfirmed(id types.TransactionID) bool {
+	for _, txids := range w.unconfirmedSets {
+		for _, txid := range txids {
+			if txid == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// managedRecordSpending records a transaction set of the wallet in the
+// spending log once the transaction pool has accepted it. All of the miner
+// fees of the set are paid by the wallet.
+func (w *Wallet) managedRecordSpending(purpose modules.SpendingPurpose, counterparty string, txnSet []types.Transaction) {
+	var fee types.Currency
+	for _, txn := range txnSet {
+		for _, mf := range txn.MinerFees {
+			fee = fee.Add(mf)
+		}
+	}
+	err := w.RecordSpending(modules.SpendingRecord{
+		Module:       modules.WalletDir,
+		Purpose:      purpose,
+		Counterparty: counterparty,
+		Fee:          fee,
+	}, txnSet)
+	if err != nil {
+		w.log.Println("WARN: failed to record spending:", err)
+	}
+}
+
+// RecordSpending adds record to the spending log of the wallet. The amount of
+// the record is the number of siacoins that txnSet spends from the wallet.
+// Recording a transaction set that is already in the log keeps its timestamp
+// and status. Sets are recorded after the transaction pool accepted them, so
+// a new record is unconfirmed if the wallet has seen the set in the pool.
+func (w *Wallet) RecordSpending(record modules.SpendingRecord, txnSet []types.Transaction) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+	if len(txnSet) == 0 {
+		return errEmptySpendingSet
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	record.TransactionID = txnSet[len(txnSet)-1].ID()
+	record.Amount = w.spentBy(txnSet)
+	record.Timestamp = time.Now()
+	record.Status = modules.SpendingStatusPending
+	record.ConfirmationHeight = 0
+	if w.isUnconfirmed(record.TransactionID) {
+		record.Status = modules.SpendingStatusUnconfirmed
+	}
+	if existing, err := dbGetSpendingRecord(w.dbTx, record.TransactionID); err == nil {
+		record.Timestamp = existing.Timestamp
+		record.Status = existing.Status
+		record.ConfirmationHeight = existing.ConfirmationHeight
+	} else if err != errNoKey {
+		return err
+	}
+	return dbPutSpendingRecord(w.dbTx, record)
+}
+
+// SpendingLog returns the spending records of the wallet that were recorded
+// in [start, end] and match module and purpose, oldest first.
+func (w *Wallet) SpendingLog(start, end time.Time, module string, purpose modules.SpendingPurpose) ([]modules.SpendingRecord, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var records []modules.SpendingRecord
+	err := w.dbTx.Bucket(bucketSpendingLog).ForEach(func(_, recordBytes []byte) error {
+		var record modules.SpendingRecord
+		if err := json.Unmarshal(recordBytes, &record); err != nil {
+			return err
+		}
+		if (!start.IsZero() && record.Timestamp.Before(start)) || (!end.IsZero() && record.Timestamp.After(end)) {
+			return nil
+		}
+		if (module != "" && record.Module != module) || (purpose != "" && record.Purpose != purpose) {
+			return nil
+		}
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+	return records, nil
+}
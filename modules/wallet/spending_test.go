@@ -0,0 +1,113 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestSpendingLog checks that the spending log of the wallet reconciles with
+// its transaction history, and that the status of the records follows the
+// confirmation of their transactions.
+func TestSpendingLog(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	start := time.Now().Add(-time.Second)
+	sendSet, err := wt.wallet.SendSiacoins(types.SiacoinPrecision.Mul64(100), types.UnlockHash{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	height := wt.cs.Height()
+	timelockTxn, err := wt.wallet.TimelockFunds(types.SiacoinPrecision.Mul64(10), height+10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := wt.wallet.SpendingLog(time.Time{}, time.Time{}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatal("expected 2 spending records, got", len(records))
+	}
+	send, timelock := records[0], records[1]
+	if send.TransactionID != sendSet[len(sendSet)-1].ID() || send.Module != modules.WalletDir || send.Purpose != modules.SpendingPurposeSend || send.Counterparty != (types.UnlockHash{1}).String() {
+		t.Fatalf("wrong send record: %+v", send)
+	}
+	if timelock.TransactionID != timelockTxn.ID() || timelock.Purpose != modules.SpendingPurposeTimelock {
+		t.Fatalf("wrong timelock record: %+v", timelock)
+	}
+	for _, r := range records {
+		if r.Status != modules.SpendingStatusUnconfirmed || r.Fee.IsZero() {
+			t.Fatalf("wrong status or fee of an unconfirmed record: %+v", r)
+		}
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The amount of each record is the siacoins that its transaction moved
+	// out of the wallet, and the records are confirmed at the height of the
+	// block.
+	records, err = wt.wallet.SpendingLog(time.Time{}, time.Time{}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		pt, exists := wt.wallet.Transaction(r.TransactionID)
+		if !exists {
+			t.Fatal("transaction of a spending record is not in the wallet history:", r.TransactionID)
+		}
+		var in, out types.Currency
+		for _, input := range pt.Inputs {
+			if input.FundType == types.SpecifierSiacoinInput && input.WalletAddress {
+				in = in.Add(input.Value)
+			}
+		}
+		for _, output := range pt.Outputs {
+			if output.FundType == types.SpecifierSiacoinOutput && output.WalletAddress {
+				out = out.Add(output.Value)
+			}
+		}
+		if !r.Amount.Equals(in.Sub(out)) {
+			t.Fatalf("amount of a %v record is %v, but the wallet history spent %v", r.Purpose, r.Amount, in.Sub(out))
+		}
+		if r.Status != modules.SpendingStatusConfirmed || r.ConfirmationHeight != pt.ConfirmationHeight {
+			t.Fatalf("wrong status of a confirmed record: %+v", r)
+		}
+	}
+	if !records[0].Amount.Equals(types.SiacoinPrecision.Mul64(100).Add(records[0].Fee)) || !records[1].Amount.Equals(records[1].Fee) {
+		t.Fatal("wrong amounts of the records:", records[0].Amount, records[1].Amount)
+	}
+
+	// Records of other modules can be filtered by module, purpose, and time.
+	set, err := wt.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt.wallet.RecordSpending(modules.SpendingRecord{
+		Module:  modules.HostDir,
+		Purpose: modules.SpendingPurposeAnnouncement,
+	}, set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records, _ := wt.wallet.SpendingLog(time.Time{}, time.Time{}, modules.HostDir, ""); len(records) != 1 || records[0].Purpose != modules.SpendingPurposeAnnouncement {
+		t.Fatal("wrong records of the host:", records)
+	}
+	if records, _ := wt.wallet.SpendingLog(start, time.Time{}, "", modules.SpendingPurposeSend); len(records) != 1 {
+		t.Fatal("expected 1 send record, got", len(records))
+	}
+	if records, _ := wt.wallet.SpendingLog(time.Time{}, start, "", ""); len(records) != 0 {
+		t.Fatal("expected no records before the start of the test, got", len(records))
+	}
+}
@@ -0,0 +1,202 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// errTimelockNotInFuture is returned if funds are timelocked until a
+	// height that the blockchain has already reached.
+	errTimelockNotInFuture = errors.New("unlock height must be above the current block height")
+
+	// specifierTimelockedKey follows modules.PrefixNonSia in the arbitrary
+	// data that TimelockFunds adds to its transactions, so that a wallet that
+	// is restored from its seed can find the timelocked addresses in the
+	// blockchain.
+	specifierTimelockedKey = types.Specifier{'T', 'i', 'm', 'e', 'l', 'o', 'c', 'k', 'e', 'd', 'K', 'e', 'y'}
+)
+
+// timelockedKey is the persist structure of a timelocked address of the
+// wallet. The secret key is not stored, since it is derived from the primary
+// seed at Index, separately from the other addresses of the seed. The
+// Timelock of the unlock conditions is recorded in the transaction that funds
+// the address, from which the address is recovered when the wallet is
+// restored from its seed.
+type timelockedKey struct {
+	Index    uint64
+	Timelock types.BlockHeight
+}
+
+// generateTimelockedKey creates the keys and unlock conditions of the
+// timelocked address of seed at the provided index.
+func generateTimelockedKey(seed modules.Seed, tk timelockedKey) spendableKey {
+	sk, pk := crypto.GenerateKeyPairDeterministic(crypto.HashAll(seed, specifierTimelockedKey, tk.Index))
+	return spendableKey{
+		UnlockConditions: types.UnlockConditions{
+			PublicKeys:         []types.SiaPublicKey{types.Ed25519PublicKey(pk)},
+			SignaturesRequired: 1,
+			Timelock:           tk.Timelock,
+		},
+		SecretKeys: []crypto.SecretKey{sk},
+	}
+}
+
+// integrateTimelockedKey adds the timelocked address of tk to the spendable
+// keys of the wallet. The primary seed of the wallet must be loaded.
+func (w *Wallet) integrateTimelockedKey(tk timelockedKey) types.UnlockConditions {
+	sk := generateTimelockedKey(w.primarySeed, tk)
+	w.keys[sk.UnlockConditions.UnlockHash()] = sk
+	return sk.UnlockConditions
+}
+
+// dbAddTimelockedKey adds tk to the timelocked keys of the wallet in the
+// database. false is returned if the wallet already has tk.
+func dbAddTimelockedKey(tx *bolt.Tx, tk timelockedKey) (bool, error) {
+	var tks []timelockedKey
+	wb := tx.Bucket(bucketWallet)
+	if err := encoding.Unmarshal(wb.Get(keyTimelockedKeys), &tks); err != nil {
+		return false, err
+	}
+	for _, existing := range tks {
+		if existing == tk {
+			return false, nil
+		}
+	}
+	return true, wb.Put(keyTimelockedKeys, encoding.Marshal(append(tks, tk)))
+}
+
+// nextTimelockedAddress derives a new address from the primary seed which
+// cannot be spent from until the blockchain reaches unlockHeight, and adds it
+// to the spendable keys of the wallet. The arbitrary data that records the
+// address in the funding transaction is returned as well.
+func (w *Wallet) nextTimelockedAddress(unlockHeight types.BlockHeight) (types.UnlockConditions, []byte, error) {
+	var tks []timelockedKey
+	wb := w.dbTx.Bucket(bucketWallet)
+	if err := encoding.Unmarshal(wb.Get(keyTimelockedKeys), &tks); err != nil {
+		return types.UnlockConditions{}, nil, err
+	}
+	var index uint64
+	for _, tk := range tks {
+		if tk.Index >= index {
+			index = tk.Index + 1
+		}
+	}
+	tk := timelockedKey{Index: index, Timelock: unlockHeight}
+	if _, err := dbAddTimelockedKey(w.dbTx, tk); err != nil {
+		return types.UnlockConditions{}, nil, err
+	}
+	return w.integrateTimelockedKey(tk), encoding.MarshalAll(modules.PrefixNonSia, specifierTimelockedKey, tk), nil
+}
+
+// updateTimelockedKeys adds the timelocked addresses that the transactions of
+// the applied blocks of cc record for the primary seed to the wallet, so that
+// a wallet that is restored from its seed recovers them while it rescans the
+// blockchain.
+func (w *Wallet) updateTimelockedKeys(tx *bolt.Tx, cc modules.ConsensusChange) error {
+	for _, block := range cc.AppliedBlocks {
+		for _, txn := range block.Transactions {
+			for _, arb := range txn.ArbitraryData {
+				prefix := encoding.MarshalAll(modules.PrefixNonSia, specifierTimelockedKey)
+				if !bytes.HasPrefix(arb, prefix) {
+					continue
+				}
+				var tk timelockedKey
+				if err := encoding.Unmarshal(arb[len(prefix):], &tk); err != nil {
+					continue
+				}
+				uh := generateTimelockedKey(w.primarySeed, tk).UnlockConditions.UnlockHash()
+				for _, sco := range txn.SiacoinOutputs {
+					if sco.UnlockHash != uh {
+						continue
+					}
+					added, err := dbAddTimelockedKey(tx, tk)
+					if err != nil {
+						return err
+					} else if added {
+						w.integrateTimelockedKey(tk)
+					}
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isTimelocked reports whether the address uh of the wallet cannot be spent
+// from at the provided height.
+func (w *Wallet) isTimelocked(uh types.UnlockHash, height types.BlockHeight) bool {
+	return height < w.keys[uh].UnlockConditions.Timelock
+}
+
+// TimelockFunds creates a transaction that sends amount to a new address of
+// the wallet, which cannot be spent from until the blockchain reaches
+// unlockHeight. The transaction is submitted to the transaction pool and is
+// also returned. Until then, the output is reported as the timelocked balance
+// of the wallet.
+func (w *Wallet) TimelockFunds(amount types.Currency, unlockHeight types.BlockHeight) (types.Transaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return types.Transaction{}, err
+	}
+	defer w.tg.Done()
+
+	w.mu.Lock()
+	if !w.unlocked {
+		w.mu.Unlock()
+		w.log.Println("Attempt to timelock coins has failed - wallet is locked")
+		return types.Transaction{}, modules.ErrLockedWallet
+	}
+	height, err := dbGetConsensusHeight(w.dbTx)
+	if err == nil && unlockHeight <= height {
+		err = errTimelockNotInFuture
+	}
+	var uc types.UnlockConditions
+	var arb []byte
+	if err == nil {
+		uc, arb, err = w.nextTimelockedAddress(unlockHeight)
+	}
+	w.mu.Unlock()
+	if err != nil {
+		return types.Transaction{}, err
+	}
+
+	_, tpoolFee := w.tpool.FeeEstimation()
+	tpoolFee = tpoolFee.Mul64(750) // Estimated transaction size in bytes
+	output := types.SiacoinOutput{
+		Value:      amount,
+		UnlockHash: uc.UnlockHash(),
+	}
+
+	txnBuilder := w.StartTransaction()
+	err = txnBuilder.FundSiacoins(amount.Add(tpoolFee))
+	if err != nil {
+		w.log.Println("Attempt to timelock coins has failed - failed to fund transaction:", err)
+		return types.Transaction{}, build.ExtendErr("unable to fund transaction", err)
+	}
+	txnBuilder.AddMinerFee(tpoolFee)
+	txnBuilder.AddSiacoinOutput(output)
+	txnBuilder.AddArbitraryData(arb)
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		w.log.Println("Attempt to timelock coins has failed - failed to sign transaction:", err)
+		return types.Transaction{}, build.ExtendErr("unable to sign transaction", err)
+	}
+	err = w.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		w.log.Println("Attempt to timelock coins has failed - transaction pool rejected transaction:", err)
+		return types.Transaction{}, build.ExtendErr("unable to get transaction accepted", err)
+	}
+	w.managedRecordSpending(modules.SpendingPurposeTimelock, "", txnSet)
+	txn := txnSet[len(txnSet)-1]
+	w.log.Println("Submitted a transaction timelocking", amount.HumanString(), "until height", unlockHeight, "with fees", tpoolFee.HumanString(), "ID:", txn.ID())
+	return txn, nil
+}
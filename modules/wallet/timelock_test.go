@@ -0,0 +1,129 @@
+package wallet
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestTimelockFunds probes the TimelockFunds method of the wallet.
+func TestTimelockFunds(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	height := wt.cs.Height()
+	amount := types.SiacoinPrecision.Mul64(3)
+	if _, err := wt.wallet.TimelockFunds(amount, height); err != errTimelockNotInFuture {
+		t.Fatal("expected errTimelockNotInFuture, got", err)
+	}
+
+	// The timelocked output is not reported as incoming siacoins.
+	unlockHeight := height + 3
+	_, tpoolFee := wt.wallet.tpool.FeeEstimation()
+	tpoolFee = tpoolFee.Mul64(750)
+	txn, err := wt.wallet.TimelockFunds(amount, unlockHeight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uc := wt.wallet.keys[txn.SiacoinOutputs[0].UnlockHash].UnlockConditions
+	if uc.Timelock != unlockHeight {
+		t.Fatal("output is not timelocked until the unlock height:", uc.Timelock)
+	}
+	unconfirmedOut, unconfirmedIn := wt.wallet.UnconfirmedBalance()
+	if !unconfirmedOut.Equals(unconfirmedIn.Add(amount).Add(tpoolFee)) {
+		t.Error("timelocked siacoins are reported as incoming siacoins")
+	}
+
+	// Once confirmed, the output is reported as the timelocked balance until
+	// the blockchain reaches the unlock height.
+	for wt.cs.Height() < unlockHeight {
+		if _, err := wt.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+		_, _, _, timelockedBal := wt.wallet.ConfirmedBalance()
+		if wt.cs.Height() < unlockHeight && !timelockedBal.Equals(amount) {
+			t.Fatal("wrong timelocked balance:", timelockedBal)
+		} else if wt.cs.Height() >= unlockHeight && !timelockedBal.IsZero() {
+			t.Fatal("funds are still timelocked at the unlock height:", timelockedBal)
+		}
+	}
+
+	// The timelocked addresses are restored when the wallet is reloaded.
+	if _, err := wt.wallet.TimelockFunds(amount, wt.cs.Height()+100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet, err = New(wt.cs, wt.tpool, filepath.Join(wt.persistDir, modules.WalletDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.Unlock(wt.walletMasterKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, timelockedBal := wt.wallet.ConfirmedBalance(); !timelockedBal.Equals(amount) {
+		t.Fatal("wrong timelocked balance after reloading the wallet:", timelockedBal)
+	}
+}
+
+// TestTimelockFundsFromSeed checks that a wallet that is restored from its
+// seed finds the timelocked addresses of the seed.
+func TestTimelockFundsFromSeed(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	amount := types.SiacoinPrecision.Mul64(5)
+	txn, err := wt.wallet.TimelockFunds(amount, wt.cs.Height()+100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	seed, _, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(build.TempDir(modules.WalletDir, t.Name(), "restored"), modules.WalletDir)
+	w, err := New(wt.cs, wt.tpool, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.InitFromSeed(crypto.TwofishKey{}, seed); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Unlock(crypto.TwofishKey(crypto.HashObject(seed))); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, timelockedBal := w.ConfirmedBalance(); !timelockedBal.Equals(amount) {
+		t.Fatal("wrong timelocked balance of the restored wallet:", timelockedBal)
+	}
+	w.mu.RLock()
+	_, known := w.keys[txn.SiacoinOutputs[0].UnlockHash]
+	w.mu.RUnlock()
+	if !known {
+		t.Fatal("restored wallet cannot spend from the timelocked address")
+	}
+}
@@ -22,6 +22,10 @@ var (
 	// errDustOutput indicates an output is not spendable because it is dust.
 	errDustOutput = errors.New("output is too small")
 
+	// errOutputWatched indicates an output belongs to a watched address, which
+	// the wallet has no keys for.
+	errOutputWatched = errors.New("output belongs to a watched address")
+
 	// errOutputTimelock indicates an output's timelock is still active.
 	errOutputTimelock = errors.New("wallet consensus set height is lower than the output timelock")
 
@@ -104,6 +108,9 @@ func (w *Wallet) checkOutput(tx *bolt.Tx, currentHeight types.BlockHeight, id ty
 			return errSpendHeightTooHigh
 		}
 	}
+	if !w.isWalletAddress(output.UnlockHash) {
+		return errOutputWatched
+	}
 	outputUnlockConditions := w.keys[output.UnlockHash].UnlockConditions
 	if currentHeight < outputUnlockConditions.Timelock {
 		return errOutputTimelock
@@ -295,6 +302,9 @@ func (tb *transactionBuilder) FundSiafunds(amount types.Currency) error {
 			potentialFund = potentialFund.Add(sfo.Value)
 			continue
 		}
+		if !tb.wallet.isWalletAddress(sfo.UnlockHash) {
+			continue
+		}
 		outputUnlockConditions := tb.wallet.keys[sfo.UnlockHash].UnlockConditions
 		if consensusHeight < outputUnlockConditions.Timelock {
 			continue
@@ -25,7 +25,7 @@ func TestIntegrationLoad1of1Siag(t *testing.T) {
 		t.Error(err)
 	}
 
-	_, siafundBal, _ := wt.wallet.ConfirmedBalance()
+	_, siafundBal, _, _ := wt.wallet.ConfirmedBalance()
 	if !siafundBal.Equals64(2000) {
 		t.Error("expecting a siafund balance of 2000 from the 1of1 key")
 	}
@@ -39,7 +39,7 @@ func TestIntegrationLoad1of1Siag(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, siafundBal, _ = wt.wallet.ConfirmedBalance()
+	_, siafundBal, _, _ = wt.wallet.ConfirmedBalance()
 	if !siafundBal.Equals64(1988) {
 		t.Error("expecting balance of 1988 after sending siafunds to the void")
 	}
@@ -64,7 +64,7 @@ func TestIntegrationLoad2of3Siag(t *testing.T) {
 		t.Error(err)
 	}
 
-	_, siafundBal, _ := wt.wallet.ConfirmedBalance()
+	_, siafundBal, _, _ := wt.wallet.ConfirmedBalance()
 	if !siafundBal.Equals64(7000) {
 		t.Error("expecting a siafund balance of 7000 from the 2of3 key")
 	}
@@ -78,7 +78,7 @@ func TestIntegrationLoad2of3Siag(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, siafundBal, _ = wt.wallet.ConfirmedBalance()
+	_, siafundBal, _, _ = wt.wallet.ConfirmedBalance()
 	if !siafundBal.Equals64(6988) {
 		t.Error("expecting balance of 6988 after sending siafunds to the void")
 	}
@@ -72,6 +72,13 @@ func (w *Wallet) isWalletAddress(uh types.UnlockHash) bool {
 	return exists
 }
 
+// isTrackedAddress is a helper function that checks if the outputs and
+// transactions of an UnlockHash are tracked by the wallet, either because it
+// is a wallet address or because it is watched.
+func (w *Wallet) isTrackedAddress(uh types.UnlockHash) bool {
+	return w.isWalletAddress(uh) || w.isWatchedAddress(uh)
+}
+
 // updateLookahead uses a consensus change to update the seed progress if one of the outputs
 // contains an unlock hash of the lookahead set. Returns true if a blockchain rescan is required
 func (w *Wallet) updateLookahead(tx *bolt.Tx, cc modules.ConsensusChange) (bool, error) {
@@ -102,7 +109,7 @@ func (w *Wallet) updateLookahead(tx *bolt.Tx, cc modules.ConsensusChange) (bool,
 func (w *Wallet) updateConfirmedSet(tx *bolt.Tx, cc modules.ConsensusChange) error {
 	for _, diff := range cc.SiacoinOutputDiffs {
 		// Verify that the diff is relevant to the wallet.
-		if !w.isWalletAddress(diff.SiacoinOutput.UnlockHash) {
+		if !w.isTrackedAddress(diff.SiacoinOutput.UnlockHash) {
 			continue
 		}
 
@@ -120,7 +127,7 @@ func (w *Wallet) updateConfirmedSet(tx *bolt.Tx, cc modules.ConsensusChange) err
 	}
 	for _, diff := range cc.SiafundOutputDiffs {
 		// Verify that the diff is relevant to the wallet.
-		if !w.isWalletAddress(diff.SiafundOutput.UnlockHash) {
+		if !w.isTrackedAddress(diff.SiafundOutput.UnlockHash) {
 			continue
 		}
 
@@ -173,7 +180,7 @@ func (w *Wallet) revertHistory(tx *bolt.Tx, reverted []types.Block) error {
 
 		// Remove the miner payout transaction if applicable.
 		for i, mp := range block.MinerPayouts {
-			if w.isWalletAddress(mp.UnlockHash) {
+			if w.isTrackedAddress(mp.UnlockHash) {
 				w.log.Println("Miner payout has been reverted due to a reorg:", block.MinerPayoutID(uint64(i)), "::", mp.Value.HumanString())
 				if err := dbDeleteLastProcessedTransaction(tx); err != nil {
 					w.log.Severe("Could not revert transaction:", err)
@@ -232,7 +239,7 @@ func (w *Wallet) applyHistory(tx *bolt.Tx, cc modules.ConsensusChange) error {
 
 		relevant := false
 		for _, mp := range block.MinerPayouts {
-			relevant = relevant || w.isWalletAddress(mp.UnlockHash)
+			relevant = relevant || w.isTrackedAddress(mp.UnlockHash)
 		}
 		if relevant {
 			w.log.Println("Wallet has received new miner payouts:", block.ID())
@@ -250,6 +257,7 @@ func (w *Wallet) applyHistory(tx *bolt.Tx, cc modules.ConsensusChange) error {
 					FundType:       types.SpecifierMinerPayout,
 					MaturityHeight: consensusHeight + types.MaturityDelay,
 					WalletAddress:  w.isWalletAddress(mp.UnlockHash),
+					WatchedAddress: w.isWatchedAddress(mp.UnlockHash),
 					RelatedAddress: mp.UnlockHash,
 					Value:          mp.Value,
 				})
@@ -263,16 +271,16 @@ func (w *Wallet) applyHistory(tx *bolt.Tx, cc modules.ConsensusChange) error {
 			// determine if transaction is relevant
 			relevant := false
 			for _, sci := range txn.SiacoinInputs {
-				relevant = relevant || w.isWalletAddress(sci.UnlockConditions.UnlockHash())
+				relevant = relevant || w.isTrackedAddress(sci.UnlockConditions.UnlockHash())
 			}
 			for _, sco := range txn.SiacoinOutputs {
-				relevant = relevant || w.isWalletAddress(sco.UnlockHash)
+				relevant = relevant || w.isTrackedAddress(sco.UnlockHash)
 			}
 			for _, sfi := range txn.SiafundInputs {
-				relevant = relevant || w.isWalletAddress(sfi.UnlockConditions.UnlockHash())
+				relevant = relevant || w.isTrackedAddress(sfi.UnlockConditions.UnlockHash())
 			}
 			for _, sfo := range txn.SiafundOutputs {
-				relevant = relevant || w.isWalletAddress(sfo.UnlockHash)
+				relevant = relevant || w.isTrackedAddress(sfo.UnlockHash)
 			}
 
 			// only create a ProcessedTransaction if txn is relevant
@@ -293,6 +301,7 @@ func (w *Wallet) applyHistory(tx *bolt.Tx, cc modules.ConsensusChange) error {
 					ParentID:       types.OutputID(sci.ParentID),
 					FundType:       types.SpecifierSiacoinInput,
 					WalletAddress:  w.isWalletAddress(sci.UnlockConditions.UnlockHash()),
+					WatchedAddress: w.isWatchedAddress(sci.UnlockConditions.UnlockHash()),
 					RelatedAddress: sci.UnlockConditions.UnlockHash(),
 					Value:          spentSiacoinOutputs[sci.ParentID].Value,
 				}
@@ -311,6 +320,7 @@ func (w *Wallet) applyHistory(tx *bolt.Tx, cc modules.ConsensusChange) error {
 					FundType:       types.SpecifierSiacoinOutput,
 					MaturityHeight: consensusHeight,
 					WalletAddress:  w.isWalletAddress(sco.UnlockHash),
+					WatchedAddress: w.isWatchedAddress(sco.UnlockHash),
 					RelatedAddress: sco.UnlockHash,
 					Value:          sco.Value,
 				}
@@ -327,6 +337,7 @@ func (w *Wallet) applyHistory(tx *bolt.Tx, cc modules.ConsensusChange) error {
 					ParentID:       types.OutputID(sfi.ParentID),
 					FundType:       types.SpecifierSiafundInput,
 					WalletAddress:  w.isWalletAddress(sfi.UnlockConditions.UnlockHash()),
+					WatchedAddress: w.isWatchedAddress(sfi.UnlockConditions.UnlockHash()),
 					RelatedAddress: sfi.UnlockConditions.UnlockHash(),
 					Value:          spentSiafundOutputs[sfi.ParentID].Value,
 				}
@@ -347,6 +358,7 @@ func (w *Wallet) applyHistory(tx *bolt.Tx, cc modules.ConsensusChange) error {
 					FundType:       types.SpecifierClaimOutput,
 					MaturityHeight: consensusHeight + types.MaturityDelay,
 					WalletAddress:  w.isWalletAddress(sfi.UnlockConditions.UnlockHash()),
+					WatchedAddress: w.isWatchedAddress(sfi.UnlockConditions.UnlockHash()),
 					RelatedAddress: sfi.ClaimUnlockHash,
 					Value:          siafundPool.Sub(sfo.ClaimStart).Mul(sfo.Value),
 				}
@@ -363,6 +375,7 @@ func (w *Wallet) applyHistory(tx *bolt.Tx, cc modules.ConsensusChange) error {
 					FundType:       types.SpecifierSiafundOutput,
 					MaturityHeight: consensusHeight,
 					WalletAddress:  w.isWalletAddress(sfo.UnlockHash),
+					WatchedAddress: w.isWatchedAddress(sfo.UnlockHash),
 					RelatedAddress: sfo.UnlockHash,
 					Value:          sfo.Value,
 				}
@@ -406,6 +419,9 @@ func (w *Wallet) ProcessConsensusChange(cc modules.ConsensusChange) {
 	} else if needRescan {
 		go w.threadedResetSubscriptions()
 	}
+	if err := w.updateTimelockedKeys(w.dbTx, cc); err != nil {
+		w.log.Println("ERROR: failed to update timelocked keys:", err)
+	}
 	if err := w.updateConfirmedSet(w.dbTx, cc); err != nil {
 		w.log.Println("ERROR: failed to update confirmed set:", err)
 	}
@@ -415,6 +431,9 @@ func (w *Wallet) ProcessConsensusChange(cc modules.ConsensusChange) {
 	if err := w.applyHistory(w.dbTx, cc); err != nil {
 		w.log.Println("ERROR: failed to apply consensus change:", err)
 	}
+	if err := w.updateSpendingLog(w.dbTx, cc); err != nil {
+		w.log.Println("ERROR: failed to update spending log:", err)
+	}
 	if err := dbPutConsensusChangeID(w.dbTx, cc.ID); err != nil {
 		w.log.Println("ERROR: failed to update consensus change ID:", err)
 	}
@@ -442,6 +461,9 @@ func (w *Wallet) ReceiveUpdatedUnconfirmedTransactions(diff *modules.Transaction
 		txids := w.unconfirmedSets[diff.RevertedTransactions[i]]
 		for i := range txids {
 			droppedTransactions[txids[i]] = struct{}{}
+			if err := dbUpdateSpendingStatus(w.dbTx, txids[i], modules.SpendingStatusDropped, 0, false); err != nil {
+				w.log.Println("ERROR: failed to update spending log:", err)
+			}
 		}
 		delete(w.unconfirmedSets, diff.RevertedTransactions[i])
 	}
@@ -475,6 +497,11 @@ func (w *Wallet) ReceiveUpdatedUnconfirmedTransactions(diff *modules.Transaction
 		// TODO: Technically only necessary to mark the ones that are relevant
 		// to the wallet, but overhead should be low.
 		w.unconfirmedSets[unconfirmedTxnSet.ID] = unconfirmedTxnSet.IDs
+		for _, txid := range unconfirmedTxnSet.IDs {
+			if err := dbUpdateSpendingStatus(w.dbTx, txid, modules.SpendingStatusUnconfirmed, 0, false); err != nil {
+				w.log.Println("ERROR: failed to update spending log:", err)
+			}
+		}
 
 		// Get the values for the spent outputs.
 		spentSiacoinOutputs := make(map[types.SiacoinOutputID]types.SiacoinOutput)
@@ -491,10 +518,10 @@ func (w *Wallet) ReceiveUpdatedUnconfirmedTransactions(diff *modules.Transaction
 			// determine whether transaction is relevant to the wallet
 			relevant := false
 			for _, sci := range txn.SiacoinInputs {
-				relevant = relevant || w.isWalletAddress(sci.UnlockConditions.UnlockHash())
+				relevant = relevant || w.isTrackedAddress(sci.UnlockConditions.UnlockHash())
 			}
 			for _, sco := range txn.SiacoinOutputs {
-				relevant = relevant || w.isWalletAddress(sco.UnlockHash)
+				relevant = relevant || w.isTrackedAddress(sco.UnlockHash)
 			}
 
 			// only create a ProcessedTransaction if txn is relevant
@@ -513,6 +540,7 @@ func (w *Wallet) ReceiveUpdatedUnconfirmedTransactions(diff *modules.Transaction
 					ParentID:       types.OutputID(sci.ParentID),
 					FundType:       types.SpecifierSiacoinInput,
 					WalletAddress:  w.isWalletAddress(sci.UnlockConditions.UnlockHash()),
+					WatchedAddress: w.isWatchedAddress(sci.UnlockConditions.UnlockHash()),
 					RelatedAddress: sci.UnlockConditions.UnlockHash(),
 					Value:          spentSiacoinOutputs[sci.ParentID].Value,
 				})
@@ -523,6 +551,7 @@ func (w *Wallet) ReceiveUpdatedUnconfirmedTransactions(diff *modules.Transaction
 					FundType:       types.SpecifierSiacoinOutput,
 					MaturityHeight: types.BlockHeight(math.MaxUint64),
 					WalletAddress:  w.isWalletAddress(sco.UnlockHash),
+					WatchedAddress: w.isWatchedAddress(sco.UnlockHash),
 					RelatedAddress: sco.UnlockHash,
 					Value:          sco.Value,
 				})
@@ -423,7 +423,7 @@ func TestAdvanceLookaheadForceRescan(t *testing.T) {
 	if err != nil {
 		t.Fatal("Couldn't fetch primary seed from db")
 	}
-	startBal, _, _ := wt.wallet.ConfirmedBalance()
+	startBal, _, _, _ := wt.wallet.ConfirmedBalance()
 
 	// Send coins to an address with a high seed index, just outside the
 	// lookahead range. It will not be initially detected, but later the
@@ -452,7 +452,7 @@ func TestAdvanceLookaheadForceRescan(t *testing.T) {
 		t.Fatal(err)
 	}
 	wt.addBlockNoPayout()
-	newBal, _, _ := wt.wallet.ConfirmedBalance()
+	newBal, _, _, _ := wt.wallet.ConfirmedBalance()
 	if !startBal.Sub(newBal).Equals(farPayout) {
 		t.Fatal("wallet should not recognize coins sent to very high seed index")
 	}
@@ -500,7 +500,7 @@ func TestAdvanceLookaheadForceRescan(t *testing.T) {
 	time.Sleep(time.Second * 2)
 
 	// Check that high seed index txn was discovered in the rescan
-	rescanBal, _, _ := wt.wallet.ConfirmedBalance()
+	rescanBal, _, _, _ := wt.wallet.ConfirmedBalance()
 	if !rescanBal.Equals(startBal) {
 		t.Fatal("wallet did not discover txn after rescan")
 	}
@@ -557,8 +557,8 @@ func TestDistantWallets(t *testing.T) {
 	}
 
 	// The second wallet's balance should update accordingly.
-	w1bal, _, _ := wt.wallet.ConfirmedBalance()
-	w2bal, _, _ := w2.ConfirmedBalance()
+	w1bal, _, _, _ := wt.wallet.ConfirmedBalance()
+	w2bal, _, _, _ := w2.ConfirmedBalance()
 
 	if !w1bal.Equals(w2bal) {
 		t.Fatal("balances do not match:", w1bal, w2bal)
@@ -587,7 +587,7 @@ func TestDistantWallets(t *testing.T) {
 	}
 	wt.addBlockNoPayout()
 
-	if newBal, _, _ := w2.ConfirmedBalance(); !newBal.Equals(w2bal.Sub(value)) {
+	if newBal, _, _, _ := w2.ConfirmedBalance(); !newBal.Equals(w2bal.Sub(value)) {
 		t.Fatal("wallet should not recognize coins sent to very high seed index")
 	}
 }
@@ -0,0 +1,134 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// errWatchWalletAddress is returned if the wallet is asked to watch an
+	// address that it already has the keys for.
+	errWatchWalletAddress = errors.New("address is already an address of the wallet")
+)
+
+// isWatchedAddress is a helper function that checks if an UnlockHash is
+// watched by the wallet.
+func (w *Wallet) isWatchedAddress(uh types.UnlockHash) bool {
+	_, exists := w.watchedAddrs[uh]
+	return exists
+}
+
+// WatchAddress adds addr to the watched addresses of the wallet. The outputs
+// and transactions of a watched address are tracked like those of the
+// wallet's own addresses, but as the wallet has no keys for it, its outputs
+// are never spent and are reported as the watched balance. The blockchain is
+// rescanned, so that the wallet learns about the existing outputs and
+// transactions of addr. Watching an address that is already watched does
+// nothing.
+func (w *Wallet) WatchAddress(addr types.UnlockHash) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+
+	// add the address and reset the consensus change ID and height in
+	// preparation for rescan
+	rescan, err := func() (bool, error) {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if !w.unlocked {
+			return false, modules.ErrLockedWallet
+		}
+		if w.isWalletAddress(addr) {
+			return false, errWatchWalletAddress
+		}
+		if w.isWatchedAddress(addr) {
+			return false, nil
+		}
+
+		var addrs []types.UnlockHash
+		wb := w.dbTx.Bucket(bucketWallet)
+		if err := encoding.Unmarshal(wb.Get(keyWatchedAddrs), &addrs); err != nil {
+			return false, err
+		}
+		if err := wb.Put(keyWatchedAddrs, encoding.Marshal(append(addrs, addr))); err != nil {
+			return false, err
+		}
+		w.watchedAddrs[addr] = struct{}{}
+
+		if err := w.dbTx.DeleteBucket(bucketProcessedTransactions); err != nil {
+			return false, err
+		}
+		if _, err := w.dbTx.CreateBucket(bucketProcessedTransactions); err != nil {
+			return false, err
+		}
+		w.unconfirmedProcessedTransactions = nil
+		if err := dbPutConsensusChangeID(w.dbTx, modules.ConsensusChangeBeginning); err != nil {
+			return false, err
+		}
+		return true, dbPutConsensusHeight(w.dbTx, 0)
+	}()
+	if err != nil || !rescan {
+		return err
+	}
+	w.log.Println("Watching address", addr)
+
+	// rescan the blockchain
+	w.cs.Unsubscribe(w)
+	w.tpool.Unsubscribe(w)
+
+	done := make(chan struct{})
+	go w.rescanMessage(done)
+	defer close(done)
+
+	err = w.cs.ConsensusSetSubscribe(w, modules.ConsensusChangeBeginning, w.tg.StopChan())
+	if err != nil {
+		return err
+	}
+	w.tpool.TransactionPoolSubscribe(w)
+	return nil
+}
+
+// WatchedAddresses returns the addresses that are watched by the wallet,
+// sorted by their bytes.
+func (w *Wallet) WatchedAddresses() []types.UnlockHash {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	addrs := make([]types.UnlockHash, 0, len(w.watchedAddrs))
+	for uh := range w.watchedAddrs {
+		addrs = append(addrs, uh)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+	})
+	return addrs
+}
+
+// WatchedBalance returns the confirmed siacoins and siafunds of the watched
+// addresses of the wallet. Unlike the confirmed balance of the wallet, dust
+// and timelocked outputs are included, since the wallet cannot tell whether
+// a watched output is spendable.
+func (w *Wallet) WatchedBalance() (siacoinBalance types.Currency, siafundBalance types.Currency) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// ensure durability of reported balance
+	w.syncDB()
+
+	dbForEachSiacoinOutput(w.dbTx, func(_ types.SiacoinOutputID, sco types.SiacoinOutput) {
+		if w.isWatchedAddress(sco.UnlockHash) && !w.isWalletAddress(sco.UnlockHash) {
+			siacoinBalance = siacoinBalance.Add(sco.Value)
+		}
+	})
+	dbForEachSiafundOutput(w.dbTx, func(_ types.SiafundOutputID, sfo types.SiafundOutput) {
+		if w.isWatchedAddress(sfo.UnlockHash) && !w.isWalletAddress(sfo.UnlockHash) {
+			siafundBalance = siafundBalance.Add(sfo.Value)
+		}
+	})
+	return
+}
@@ -0,0 +1,110 @@
+package wallet
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestWatchAddress probes the WatchAddress method of the wallet.
+func TestWatchAddress(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	own, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.WatchAddress(own.UnlockHash()); err != errWatchWalletAddress {
+		t.Fatal("expected errWatchWalletAddress, got", err)
+	}
+
+	// Send siacoins to an address that the wallet has no keys for before it
+	// is watched, so that the rescan has to find them.
+	_, pk := crypto.GenerateKeyPair()
+	addr := types.UnlockConditions{
+		PublicKeys:         []types.SiaPublicKey{types.Ed25519PublicKey(pk)},
+		SignaturesRequired: 1,
+	}.UnlockHash()
+	amount := types.SiacoinPrecision.Mul64(10)
+	if _, err := wt.wallet.SendSiacoins(amount, addr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	siacoinBal, _, _, _ := wt.wallet.ConfirmedBalance()
+	if err := wt.wallet.WatchAddress(addr); err != nil {
+		t.Fatal(err)
+	}
+	if addrs := wt.wallet.WatchedAddresses(); len(addrs) != 1 || addrs[0] != addr {
+		t.Fatal("wrong watched addresses:", addrs)
+	}
+
+	// The siacoins of the watched address are reported as the watched
+	// balance, and not as the confirmed balance.
+	if watchedBal, _ := wt.wallet.WatchedBalance(); !watchedBal.Equals(amount) {
+		t.Fatal("wrong watched balance:", watchedBal)
+	}
+	if bal, _, _, _ := wt.wallet.ConfirmedBalance(); !bal.Equals(siacoinBal) {
+		t.Fatal("watched siacoins changed the confirmed balance:", siacoinBal, bal)
+	}
+	pts := wt.wallet.AddressTransactions(addr)
+	if len(pts) != 1 {
+		t.Fatal("expected the transaction of the watched address to be found, got", len(pts))
+	}
+	var tagged bool
+	for _, output := range pts[0].Outputs {
+		if output.RelatedAddress == addr {
+			tagged = output.WatchedAddress && !output.WalletAddress
+		}
+	}
+	if !tagged {
+		t.Fatal("output of the watched address is not tagged as watched")
+	}
+
+	// The siacoins of the watched address are never spent.
+	if _, err := wt.wallet.SendSiacoins(siacoinBal.Sub(types.SiacoinPrecision), types.UnlockHash{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if watchedBal, _ := wt.wallet.WatchedBalance(); !watchedBal.Equals(amount) {
+		t.Fatal("siacoins of the watched address were spent:", watchedBal)
+	}
+
+	// Incoming transactions are tracked after the rescan as well, and the
+	// watched addresses are restored when the wallet is reloaded.
+	if _, err := wt.wallet.SendSiacoins(amount, addr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet, err = New(wt.cs, wt.tpool, filepath.Join(wt.persistDir, modules.WalletDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.Unlock(wt.walletMasterKey); err != nil {
+		t.Fatal(err)
+	}
+	if addrs := wt.wallet.WatchedAddresses(); len(addrs) != 1 || addrs[0] != addr {
+		t.Fatal("wrong watched addresses after reloading the wallet:", addrs)
+	}
+	if watchedBal, _ := wt.wallet.WatchedBalance(); !watchedBal.Equals(amount.Mul64(2)) {
+		t.Fatal("wrong watched balance after reloading the wallet:", watchedBal)
+	}
+}
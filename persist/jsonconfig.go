@@ -0,0 +1,104 @@
+package persist
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/NebulousLabs/Sia/build"
+)
+
+var (
+	// ErrInvalidConfig is returned if a config file or the result of a
+	// migration is not valid JSON.
+	ErrInvalidConfig = errors.New("config is not valid JSON")
+)
+
+// JSONConfig is a config file that stores the version of its schema next to
+// the config, as {"version": N, "config": {...}}. The config is kept as raw
+// JSON until it is parsed, so that it can be migrated to the current schema
+// first.
+type JSONConfig struct {
+	Version uint64          `json:"version"`
+	Config  json.RawMessage `json:"config"`
+}
+
+// NewJSONConfig returns a JSONConfig that holds config at the given schema
+// version.
+func NewJSONConfig(version uint64, config interface{}) (JSONConfig, error) {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return JSONConfig{}, build.ExtendErr("unable to marshal config", err)
+	}
+	return JSONConfig{Version: version, Config: configBytes}, nil
+}
+
+// LoadJSONConfig reads the config file at filename. A file that is a plain
+// JSON object without a version is loaded as version 0, so that config files
+// written before the schema was versioned can be migrated.
+func LoadJSONConfig(filename string) (JSONConfig, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return JSONConfig{}, err
+	}
+	if !json.Valid(data) {
+		return JSONConfig{}, ErrInvalidConfig
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err == nil && len(fields) == 2 && fields["version"] != nil && fields["config"] != nil {
+		var c JSONConfig
+		if err := json.Unmarshal(data, &c); err != nil {
+			return JSONConfig{}, build.ExtendErr("unable to read config version", err)
+		}
+		return c, nil
+	}
+	return JSONConfig{Version: 0, Config: bytes.TrimSpace(data)}, nil
+}
+
+// Migrate upgrades the config to the most recent schema version. handlers maps
+// a version to the function that upgrades a config of that version to the next
+// version. Handlers are applied in order until there is no handler for the
+// version of the config. If a handler fails, the config is left unchanged.
+func (c *JSONConfig) Migrate(handlers map[uint64]func([]byte) ([]byte, error)) error {
+	version, config := c.Version, c.Config
+	for {
+		handler, exists := handlers[version]
+		if !exists {
+			break
+		}
+		migrated, err := handler(config)
+		if err != nil {
+			return build.ExtendErr(fmt.Sprintf("unable to migrate config from version %v", version), err)
+		}
+		if !json.Valid(migrated) {
+			return build.ExtendErr(fmt.Sprintf("unable to migrate config from version %v", version), ErrInvalidConfig)
+		}
+		version, config = version+1, migrated
+	}
+	c.Version, c.Config = version, config
+	return nil
+}
+
+// Unmarshal parses the config into v.
+func (c JSONConfig) Unmarshal(v interface{}) error {
+	return json.Unmarshal(c.Config, v)
+}
+
+// Save writes the config and its version to filename atomically.
+func (c JSONConfig) Save(filename string) error {
+	data, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return build.ExtendErr("unable to marshal config", err)
+	}
+	file, err := NewSafeFile(filename)
+	if err != nil {
+		return build.ExtendErr("unable to open config file", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return build.ExtendErr("unable to write config file", err)
+	}
+	return file.CommitSync()
+}
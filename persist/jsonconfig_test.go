@@ -0,0 +1,116 @@
+package persist
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+)
+
+// TestJSONConfigMigrate checks that a config file is saved with its version,
+// that an unversioned config file is loaded as version 0, and that Migrate
+// applies the upgrade of every version in order.
+func TestJSONConfigMigrate(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	dir := filepath.Join(build.TempDir(persistDir), t.Name())
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type testConfig struct {
+		APIAddr string `json:"apiaddr"`
+		Modules string `json:"modules"`
+	}
+	handlers := map[uint64]func([]byte) ([]byte, error){
+		// Version 0 only had the API address, under a different name.
+		0: func(b []byte) ([]byte, error) {
+			return bytes.Replace(b, []byte(`"addr"`), []byte(`"apiaddr"`), 1), nil
+		},
+		// Version 1 did not list the modules.
+		1: func(b []byte) ([]byte, error) {
+			return append(bytes.TrimSuffix(b, []byte("}")), []byte(`,"modules":"cgtw"}`)...), nil
+		},
+	}
+
+	// Migrate an unversioned config file.
+	filename := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(filename, []byte(`{"addr":"localhost:9980"}`+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadJSONConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != 0 {
+		t.Fatal("unversioned config was not loaded as version 0:", c.Version)
+	}
+	if err := c.Migrate(handlers); err != nil {
+		t.Fatal(err)
+	}
+	var config testConfig
+	if err := c.Unmarshal(&config); err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != 2 || config != (testConfig{"localhost:9980", "cgtw"}) {
+		t.Fatalf("wrong migrated config: version %v, %+v", c.Version, config)
+	}
+
+	// Save and load the migrated config. Migrating it again should not change
+	// it.
+	if err := c.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	c, err = LoadJSONConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Migrate(handlers); err != nil {
+		t.Fatal(err)
+	}
+	config = testConfig{}
+	if err := c.Unmarshal(&config); err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != 2 || config != (testConfig{"localhost:9980", "cgtw"}) {
+		t.Fatalf("wrong loaded config: version %v, %+v", c.Version, config)
+	}
+
+	// A failed migration should leave the config unchanged.
+	c, err = NewJSONConfig(0, map[string]string{"addr": "localhost:9980"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := string(c.Config)
+	handlers[1] = func([]byte) ([]byte, error) {
+		return nil, errors.New("migration failed")
+	}
+	if err := c.Migrate(handlers); err == nil {
+		t.Fatal("expected the migration to fail")
+	}
+	if c.Version != 0 || string(c.Config) != original {
+		t.Fatalf("failed migration changed the config: version %v, %s", c.Version, c.Config)
+	}
+	handlers[1] = func([]byte) ([]byte, error) {
+		return []byte("{"), nil
+	}
+	if err := c.Migrate(handlers); err == nil {
+		t.Fatal("expected the migration to invalid JSON to fail")
+	}
+
+	// Invalid config files should be rejected.
+	err = ioutil.WriteFile(filename, []byte(`{"version":1,`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadJSONConfig(filename); err != ErrInvalidConfig {
+		t.Fatal("expected ErrInvalidConfig, got", err)
+	}
+}
@@ -90,6 +90,16 @@ func (b Block) CalculateSubsidy(height BlockHeight) Currency {
 	return subsidy
 }
 
+// FileContractCount returns the number of file contracts that are created by
+// the transactions of the block.
+func (b Block) FileContractCount() int {
+	var n int
+	for _, txn := range b.Transactions {
+		n += len(txn.FileContracts)
+	}
+	return n
+}
+
 // Header returns the header of a block.
 func (b Block) Header() BlockHeader {
 	return BlockHeader{
@@ -152,3 +162,27 @@ func (b Block) MinerPayoutID(i uint64) SiacoinOutputID {
 		i,
 	))
 }
+
+// SiacoinOutputCount returns the number of siacoin outputs that are created by
+// the transactions of the block. The miner payouts are not included.
+func (b Block) SiacoinOutputCount() int {
+	var n int
+	for _, txn := range b.Transactions {
+		n += len(txn.SiacoinOutputs)
+	}
+	return n
+}
+
+// TotalStorageBytes returns the sum of the file sizes of the file contracts
+// that are created by the transactions of the block. The file sizes are not
+// bounded, so the sum can overflow; totals that must be exact should be summed
+// as a Currency.
+func (b Block) TotalStorageBytes() uint64 {
+	var size uint64
+	for _, txn := range b.Transactions {
+		for _, fc := range txn.FileContracts {
+			size += fc.FileSize
+		}
+	}
+	return size
+}
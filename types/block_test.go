@@ -231,3 +231,40 @@ func TestBlockMinerPayoutID(t *testing.T) {
 		knownIDs[id] = struct{}{}
 	}
 }
+
+// TestBlockStatistics probes the FileContractCount, SiacoinOutputCount, and
+// TotalStorageBytes functions of the block type.
+func TestBlockStatistics(t *testing.T) {
+	var b Block
+	if b.FileContractCount() != 0 || b.SiacoinOutputCount() != 0 || b.TotalStorageBytes() != 0 {
+		t.Error("statistics of an empty block are not zero")
+	}
+
+	// Miner payouts are not counted as siacoin outputs.
+	b.MinerPayouts = []SiacoinOutput{{Value: CalculateCoinbase(0)}}
+	b.Transactions = []Transaction{
+		{
+			SiacoinOutputs: []SiacoinOutput{{}, {}},
+			FileContracts:  []FileContract{{FileSize: 100}},
+		},
+		{
+			ArbitraryData: [][]byte{{'6'}},
+		},
+		{
+			SiacoinOutputs: []SiacoinOutput{{}},
+			FileContracts:  []FileContract{{FileSize: 20}, {FileSize: 3}},
+			FileContractRevisions: []FileContractRevision{
+				{NewFileSize: 1000},
+			},
+		},
+	}
+	if n := b.FileContractCount(); n != 3 {
+		t.Error("expected 3 file contracts, got", n)
+	}
+	if n := b.SiacoinOutputCount(); n != 3 {
+		t.Error("expected 3 siacoin outputs, got", n)
+	}
+	if size := b.TotalStorageBytes(); size != 123 {
+		t.Error("expected 123 bytes of storage, got", size)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"errors"
 	"math"
 	"math/big"
+	"strings"
 
 	"github.com/NebulousLabs/Sia/build"
 )
@@ -35,8 +36,34 @@ var (
 	// unit64 would cause an overflow.
 	ErrUint64Overflow = errors.New("cannot return the uint64 of this currency - result is an overflow")
 
+	// ErrMalformedSiacoin is the error that is returned if a siacoin amount
+	// cannot be parsed.
+	ErrMalformedSiacoin = errors.New("malformed siacoin amount")
+
+	// ErrFractionalHastings is the error that is returned if a siacoin amount
+	// is not a whole number of hastings.
+	ErrFractionalHastings = errors.New("siacoin amount is not a whole number of hastings")
+
 	// ZeroCurrency defines a currency of value zero.
 	ZeroCurrency = NewCurrency64(0)
+
+	// siacoinUnits are the suffixes of siacoin amounts, and the power of ten
+	// of hastings that they stand for, from the smallest to the largest unit.
+	// "uSC" is accepted as an alternative to "µSC" when parsing.
+	siacoinUnits = []struct {
+		suffix string
+		exp    int64
+	}{
+		{"pSC", 12},
+		{"nSC", 15},
+		{"µSC", 18},
+		{"mSC", 21},
+		{"SC", 24},
+		{"KSC", 27},
+		{"MSC", 30},
+		{"GSC", 33},
+		{"TSC", 36},
+	}
 )
 
 // NewCurrency creates a Currency value from a big.Int. Undefined behavior
@@ -194,3 +221,87 @@ func (c Currency) Uint64() (u uint64, err error) {
 	}
 	return c.Big().Uint64(), nil
 }
+
+// ToFloat64 returns the value of c in hastings as a float64. Values that do not
+// fit in a float64 lose precision, so the result should only be used for
+// display and estimates.
+func (c Currency) ToFloat64() float64 {
+	f, _ := new(big.Float).SetInt(&c.i).Float64()
+	return f
+}
+
+// ParseSiacoin parses a siacoin amount such as "1.5 SC" or "250mSC". The
+// amount is an integer or a decimal number with at most 24 decimal places, and
+// is followed by an optional unit, which is one of "H" (hastings), "pSC",
+// "nSC", "µSC" (or "uSC"), "mSC", "SC", "KSC", "MSC", "GSC", and "TSC".
+// Amounts without a unit are in siacoins.
+func ParseSiacoin(s string) (Currency, error) {
+	s = strings.TrimSpace(s)
+	exp := int64(24)
+	if strings.HasSuffix(s, "H") {
+		s, exp = strings.TrimSuffix(s, "H"), 0
+	} else if strings.HasSuffix(s, "uSC") {
+		s, exp = strings.TrimSuffix(s, "uSC"), 18
+	} else {
+		// The prefixed units also end in "SC", so the unit is the longest
+		// matching suffix.
+		var suffix string
+		for _, u := range siacoinUnits {
+			if strings.HasSuffix(s, u.suffix) && len(u.suffix) > len(suffix) {
+				suffix, exp = u.suffix, u.exp
+			}
+		}
+		s = strings.TrimSuffix(s, suffix)
+	}
+	s = strings.TrimSpace(s)
+
+	// Only plain decimal numbers are accepted; big.Rat would also accept
+	// signs, fractions, and exponents.
+	parts := strings.Split(s, ".")
+	if len(parts) > 2 || s == "." || len(s) == 0 || (len(parts) == 2 && len(parts[1]) > 24) {
+		return Currency{}, ErrMalformedSiacoin
+	}
+	for _, part := range parts {
+		for _, r := range part {
+			if r < '0' || r > '9' {
+				return Currency{}, ErrMalformedSiacoin
+			}
+		}
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Currency{}, ErrMalformedSiacoin
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(exp), nil)))
+	if !r.IsInt() {
+		return Currency{}, ErrFractionalHastings
+	}
+	return NewCurrency(r.Num()), nil
+}
+
+// StringSC returns c as a siacoin amount in the largest unit that results in a
+// value of at least 1, such as "1.5 SC" or "250 mSC". Values below 1 pSC are
+// returned in hastings. Unlike HumanString, the value is not rounded, so
+// ParseSiacoin(c.StringSC()) equals c.
+func (c Currency) StringSC() string {
+	ten := big.NewInt(10)
+	unit := siacoinUnits[0]
+	if c.i.Cmp(new(big.Int).Exp(ten, big.NewInt(unit.exp), nil)) < 0 {
+		return c.String() + " H"
+	}
+	for _, u := range siacoinUnits[1:] {
+		if c.i.Cmp(new(big.Int).Exp(ten, big.NewInt(u.exp), nil)) < 0 {
+			break
+		}
+		unit = u
+	}
+
+	whole, frac := new(big.Int).QuoRem(&c.i, new(big.Int).Exp(ten, big.NewInt(unit.exp), nil), new(big.Int))
+	str := whole.String()
+	if frac.Sign() != 0 {
+		digits := frac.String()
+		digits = strings.Repeat("0", int(unit.exp)-len(digits)) + digits
+		str += "." + strings.TrimRight(digits, "0")
+	}
+	return str + " " + unit.suffix
+}
@@ -318,3 +318,92 @@ func TestCurrencyUint64(t *testing.T) {
 		t.Error("result is not being zeroed in the event of an error")
 	}
 }
+
+// TestCurrencyToFloat64 checks that currencies are converted to floats.
+func TestCurrencyToFloat64(t *testing.T) {
+	if f := ZeroCurrency.ToFloat64(); f != 0 {
+		t.Error("expected 0, got", f)
+	}
+	if f := NewCurrency64(25e3).ToFloat64(); f != 25e3 {
+		t.Error("expected 25e3, got", f)
+	}
+	if f := SiacoinPrecision.ToFloat64(); f != 1e24 {
+		t.Error("expected 1e24, got", f)
+	}
+}
+
+// TestParseSiacoin checks that siacoin amounts are parsed, and that malformed
+// amounts are rejected.
+func TestParseSiacoin(t *testing.T) {
+	tests := []struct {
+		s   string
+		c   Currency
+		err error
+	}{
+		{"1", SiacoinPrecision, nil},
+		{"1 SC", SiacoinPrecision, nil},
+		{"1.5SC", SiacoinPrecision.Mul64(3).Div64(2), nil},
+		{" 0.000000000000000000000001 SC ", NewCurrency64(1), nil},
+		{"250 mSC", SiacoinPrecision.Div64(4), nil},
+		{"250 MSC", SiacoinPrecision.Mul64(250e6), nil},
+		{"3 µSC", NewCurrency64(3e18), nil},
+		{"3 uSC", NewCurrency64(3e18), nil},
+		{"2 pSC", NewCurrency64(2e12), nil},
+		{"7 KSC", SiacoinPrecision.Mul64(7e3), nil},
+		{"12 H", NewCurrency64(12), nil},
+		{".5 SC", SiacoinPrecision.Div64(2), nil},
+		{"5. SC", SiacoinPrecision.Mul64(5), nil},
+		{"0", ZeroCurrency, nil},
+
+		{"", ZeroCurrency, ErrMalformedSiacoin},
+		{"SC", ZeroCurrency, ErrMalformedSiacoin},
+		{". SC", ZeroCurrency, ErrMalformedSiacoin},
+		{"-1 SC", ZeroCurrency, ErrMalformedSiacoin},
+		{"1e3 SC", ZeroCurrency, ErrMalformedSiacoin},
+		{"1/2 SC", ZeroCurrency, ErrMalformedSiacoin},
+		{"1.2.3 SC", ZeroCurrency, ErrMalformedSiacoin},
+		{"1 XSC", ZeroCurrency, ErrMalformedSiacoin},
+		{"0.0000000000000000000000001 SC", ZeroCurrency, ErrMalformedSiacoin},
+		{"1.5 H", ZeroCurrency, ErrFractionalHastings},
+		{"0.0000000000001 pSC", ZeroCurrency, ErrFractionalHastings},
+	}
+	for _, test := range tests {
+		c, err := ParseSiacoin(test.s)
+		if err != test.err {
+			t.Errorf("ParseSiacoin(%q): expected error %v, got %v", test.s, test.err, err)
+		} else if err == nil && !c.Equals(test.c) {
+			t.Errorf("ParseSiacoin(%q): expected %v, got %v", test.s, test.c, c)
+		}
+	}
+}
+
+// TestCurrencyStringSC checks that StringSC picks the largest unit that
+// results in a value of at least 1, and that the result can be parsed back.
+func TestCurrencyStringSC(t *testing.T) {
+	tests := []struct {
+		c Currency
+		s string
+	}{
+		{ZeroCurrency, "0 H"},
+		{NewCurrency64(999), "999 H"},
+		{NewCurrency64(1e12), "1 pSC"},
+		{NewCurrency64(3e18), "3 µSC"},
+		{SiacoinPrecision.Div64(4), "250 mSC"},
+		{SiacoinPrecision, "1 SC"},
+		{SiacoinPrecision.Mul64(3).Div64(2), "1.5 SC"},
+		{SiacoinPrecision.Add(NewCurrency64(1)), "1.000000000000000000000001 SC"},
+		{SiacoinPrecision.Mul64(1234), "1.234 KSC"},
+		{SiacoinPrecision.Mul64(5e15), "5000 TSC"},
+	}
+	for _, test := range tests {
+		if s := test.c.StringSC(); s != test.s {
+			t.Errorf("expected %v to be %q, got %q", test.c, test.s, s)
+		}
+		c, err := ParseSiacoin(test.s)
+		if err != nil {
+			t.Error(err)
+		} else if !c.Equals(test.c) {
+			t.Errorf("expected %q to parse to %v, got %v", test.s, test.c, c)
+		}
+	}
+}
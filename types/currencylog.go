@@ -0,0 +1,87 @@
+package types
+
+// currencylog.go defines a Currency that keeps a trace of the operations that
+// were used to compute it, which is useful when auditing financial
+// calculations such as the conversion of prices between units. Operations are
+// only recorded when the 'currencytrace' build tag is set; in other builds the
+// arithmetic is identical to that of Currency and the trace is always empty.
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// A CurrencyLog is a Currency together with the trace of operations that
+// produced it. Like Currency, all arithmetic CurrencyLog methods return a new
+// value and never modify their receiver.
+type CurrencyLog struct {
+	Currency
+	Trace []string
+}
+
+// NewCurrencyLog returns a CurrencyLog that starts at c with an empty trace.
+func NewCurrencyLog(c Currency) CurrencyLog {
+	return CurrencyLog{Currency: c}
+}
+
+// record returns a CurrencyLog with the value c and with the operation
+// appended to the trace of cl.
+func (cl CurrencyLog) record(c Currency, op string, arg interface{}) CurrencyLog {
+	if !currencyTrace {
+		return CurrencyLog{Currency: c}
+	}
+	// Copy the trace so that values derived from the same CurrencyLog do not
+	// share the backing array.
+	trace := make([]string, len(cl.Trace), len(cl.Trace)+1)
+	copy(trace, cl.Trace)
+	return CurrencyLog{
+		Currency: c,
+		Trace:    append(trace, fmt.Sprintf("%v(%v)", op, arg)),
+	}
+}
+
+// Add returns a new CurrencyLog value c = x + y
+func (x CurrencyLog) Add(y Currency) CurrencyLog {
+	return x.record(x.Currency.Add(y), "Add", y)
+}
+
+// Div returns a new CurrencyLog value c = x / y.
+func (x CurrencyLog) Div(y Currency) CurrencyLog {
+	return x.record(x.Currency.Div(y), "Div", y)
+}
+
+// Div64 returns a new CurrencyLog value c = x / y.
+func (x CurrencyLog) Div64(y uint64) CurrencyLog {
+	return x.record(x.Currency.Div64(y), "Div", y)
+}
+
+// Mul returns a new CurrencyLog value c = x * y.
+func (x CurrencyLog) Mul(y Currency) CurrencyLog {
+	return x.record(x.Currency.Mul(y), "Mul", y)
+}
+
+// Mul64 returns a new CurrencyLog value c = x * y.
+func (x CurrencyLog) Mul64(y uint64) CurrencyLog {
+	return x.record(x.Currency.Mul64(y), "Mul", y)
+}
+
+// MulFloat returns a new CurrencyLog value y = c * x, where x is a float64.
+func (x CurrencyLog) MulFloat(y float64) CurrencyLog {
+	return x.record(x.Currency.MulFloat(y), "MulFloat", y)
+}
+
+// MulRat returns a new CurrencyLog value c = x * y, where y is a big.Rat.
+func (x CurrencyLog) MulRat(y *big.Rat) CurrencyLog {
+	return x.record(x.Currency.MulRat(y), "MulRat", y.RatString())
+}
+
+// RoundDown returns the largest multiple of y <= x.
+func (x CurrencyLog) RoundDown(y Currency) CurrencyLog {
+	return x.record(x.Currency.RoundDown(y), "RoundDown", y)
+}
+
+// Sub returns a new CurrencyLog value c = x - y. Behavior is undefined when
+// x < y.
+func (x CurrencyLog) Sub(y Currency) CurrencyLog {
+	return x.record(x.Currency.Sub(y), "Sub", y)
+}
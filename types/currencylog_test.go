@@ -0,0 +1,43 @@
+package types
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// TestCurrencyLog checks that CurrencyLog arithmetic matches Currency
+// arithmetic, and that the trace is only recorded when currency tracing is
+// enabled.
+func TestCurrencyLog(t *testing.T) {
+	base := NewCurrencyLog(NewCurrency64(1200))
+	cl := base.Mul64(4320).Div64(10).Add(NewCurrency64(8)).Sub(NewCurrency64(4)).MulRat(big.NewRat(1, 2))
+	expected := NewCurrency64(1200).Mul64(4320).Div64(10).Add(NewCurrency64(8)).Sub(NewCurrency64(4)).MulRat(big.NewRat(1, 2))
+	if !cl.Equals(expected) {
+		t.Fatalf("expected %v, got %v", expected, cl.Currency)
+	}
+
+	// Derive a second value from the same CurrencyLog; it should not share
+	// the trace of the first.
+	other := base.Mul64(4320).Mul(NewCurrency64(3))
+	if !other.Equals64(1200 * 4320 * 3) {
+		t.Fatal("expected", 1200*4320*3, "got", other.Currency)
+	}
+
+	if !currencyTrace {
+		if cl.Trace != nil || other.Trace != nil {
+			t.Fatal("operations were traced without the currencytrace build tag")
+		}
+		return
+	}
+	expTrace := []string{"Mul(4320)", "Div(10)", "Add(8)", "Sub(4)", "MulRat(1/2)"}
+	if !reflect.DeepEqual(cl.Trace, expTrace) {
+		t.Fatal("wrong trace:", cl.Trace)
+	}
+	if !reflect.DeepEqual(other.Trace, []string{"Mul(4320)", "Mul(3)"}) {
+		t.Fatal("wrong trace:", other.Trace)
+	}
+	if base.Trace != nil {
+		t.Fatal("receiver was modified:", base.Trace)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build currencytrace
+// +build currencytrace
+
+package types
+
+// currencyTrace determines whether CurrencyLog arithmetic is recorded.
+const currencyTrace = true
@@ -210,6 +210,39 @@ func TestCurrencyMarshalJSON(t *testing.T) {
 	}
 }
 
+// TestCurrencyJSONDecimal checks that the encoding/json codec represents a
+// Currency as a quoted decimal string, while the Sia encoding of the Currency
+// remains the big-endian byte slice.
+func TestCurrencyJSONDecimal(t *testing.T) {
+	c := SiacoinPrecision.Mul64(1e3)
+	b, err := json.Marshal(struct {
+		Value Currency `json:"value"`
+	}{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"value":"1000000000000000000000000000"}` {
+		t.Fatal("currency was not encoded as a decimal string:", string(b))
+	}
+	var decoded struct {
+		Value Currency `json:"value"`
+	}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	} else if decoded.Value.Cmp(c) != 0 {
+		t.Fatal("currency did not survive a JSON round trip:", decoded.Value)
+	}
+	if err := json.Unmarshal([]byte(`{"value":"-1"}`), &decoded); err != ErrNegativeCurrency {
+		t.Error("expected ErrNegativeCurrency, got", err)
+	}
+
+	// The Sia encoding is a length-prefixed big-endian byte slice.
+	expected := encoding.Marshal(c.Big().Bytes())
+	if !bytes.Equal(encoding.Marshal(c), expected) {
+		t.Error("Sia encoding of currency changed")
+	}
+}
+
 // TestCurrencyMarshalSia probes the MarshalSia and UnmarshalSia functions of
 // the currency type.
 func TestCurrencyMarshalSia(t *testing.T) {